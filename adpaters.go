@@ -119,6 +119,44 @@ func (b *bufioReaderAdapter) WriteTo(w io.Writer) (n int64, err error) {
 	return n, err
 }
 
+// UnreadByte unreads the last byte read and updates the pos.
+func (r *bytesBufferReaderAdapter) UnreadByte() error {
+	err := r.Buffer.UnreadByte()
+	if err == nil {
+		r.pos--
+	}
+	return err
+}
+
+// UnreadByte unreads the last byte read, updating the stream position.
+func (b *bufioReaderAdapter) UnreadByte() error {
+	err := b.Reader.UnreadByte()
+	if err == nil {
+		b.pos--
+	}
+	return err
+}
+
+// Peek returns the next n bytes of the buffer without consuming them.
+func (r *bytesBufferReaderAdapter) Peek(n int) ([]byte, error) {
+	b := r.Bytes()
+	if n > len(b) {
+		return b, io.EOF
+	}
+	return b[:n], nil
+}
+
+// Peek returns a copy of the next n bytes without consuming them.
+func (r *bytesReaderAdapter) Peek(n int) ([]byte, error) {
+	pos, _ := r.Reader.Seek(0, io.SeekCurrent)
+	b := make([]byte, min(n, r.Reader.Len()))
+	r.Reader.ReadAt(b, pos)
+	if len(b) < n {
+		return b, io.EOF
+	}
+	return b, nil
+}
+
 // Size returns the size of the underlying buffer.
 func (b *bufioReaderAdapter) Size() int {
 	return b.Reader.Size()
@@ -172,6 +210,8 @@ func (b *bufioReaderAdapter) Seek(offset int64, whence int) (int64, error) {
 	if target < b.pos {
 		return b.pos, ErrUnsupportedNegativeSeek
 	}
-	_, err := Discard(b, target-b.pos)
+	// bufio.Reader.Discard keeps reading until all n bytes are skipped.
+	n, err := b.Reader.Discard(int(target - b.pos))
+	b.pos += int64(n)
 	return b.pos, err
 }
@@ -0,0 +1,421 @@
+// Package amf implements the Action Message Format used by RTMP commands,
+// Flash remoting and FLV script data: AMF0, and AMF3 both on its own and
+// embedded in AMF0 through the avmplus marker.
+//
+// Values map to Go types as follows; encoding also accepts the other Go
+// integer and float types as numbers.
+//
+//	number                float64 (AMF3 integers: int32)
+//	boolean               bool
+//	string, long string   string
+//	null                  nil
+//	undefined             Undefined
+//	anonymous object      Object
+//	typed object          TypedObject
+//	ECMA array            ECMAArray
+//	strict / dense array  []any
+//	AMF3 mixed array      MixedArray
+//	date                  time.Time
+//	XML document          XML
+//	AMF3 byte array       []byte
+//
+// Decoders keep the reference tables of the message they decode; call Reset
+// between messages.
+package amf
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"math"
+	"slices"
+	"time"
+
+	"github.com/oy3o/codec"
+)
+
+// MAX_DEPTH bounds the nesting of objects and arrays.
+const MAX_DEPTH = 256
+
+// MAX_LENGTH bounds the strings, byte arrays and element counts read. Strings
+// and byte arrays are read with codec.Reader.ReadBytes, so the Reader's
+// allocation limit applies to them too.
+const MAX_LENGTH = 16 << 20
+
+var (
+	// ErrInvalidMarker indicates an unknown type marker.
+	ErrInvalidMarker = errors.New("amf: invalid type marker")
+
+	// ErrUnsupported indicates a valid value this package cannot decode or
+	// encode, such as an externalizable object or an AMF3 vector.
+	ErrUnsupported = errors.New("amf: unsupported value")
+
+	// ErrInvalidReference indicates a reference to a value not yet decoded.
+	ErrInvalidReference = errors.New("amf: invalid reference")
+
+	// ErrTooDeep indicates values nested deeper than MAX_DEPTH.
+	ErrTooDeep = errors.New("amf: nesting too deep")
+)
+
+// Undefined is the undefined value.
+type Undefined struct{}
+
+// Object is an anonymous object. Encoders write its keys in sorted order.
+type Object map[string]any
+
+// ECMAArray is an associative array.
+type ECMAArray map[string]any
+
+// TypedObject is an object of a registered class.
+type TypedObject struct {
+	Class  string
+	Fields Object
+}
+
+// MixedArray is an AMF3 array with both associative and dense parts. Arrays
+// with only a dense part decode as []any.
+type MixedArray struct {
+	Assoc map[string]any
+	Dense []any
+}
+
+// XML is an XML document.
+type XML string
+
+// AMF0 type markers.
+const (
+	marker0Number      = 0x00
+	marker0Boolean     = 0x01
+	marker0String      = 0x02
+	marker0Object      = 0x03
+	marker0Null        = 0x05
+	marker0Undefined   = 0x06
+	marker0Reference   = 0x07
+	marker0ECMAArray   = 0x08
+	marker0ObjectEnd   = 0x09
+	marker0StrictArray = 0x0A
+	marker0Date        = 0x0B
+	marker0LongString  = 0x0C
+	marker0XMLDoc      = 0x0F
+	marker0TypedObject = 0x10
+	marker0AVMPlus     = 0x11
+)
+
+// Decoder reads AMF values.
+type Decoder struct {
+	r       *codec.Reader
+	depth   int
+	objects []any    // AMF0 complex values, by reference index
+	strings []string // AMF3 string table
+	objs3   []any    // AMF3 object table
+	traits  []traits // AMF3 traits table
+}
+
+// NewDecoder returns a Decoder reading from r, whose byte order it sets to
+// big-endian.
+func NewDecoder(r *codec.Reader) *Decoder {
+	r.WithByteOrder(codec.BE)
+	return &Decoder{r: r}
+}
+
+// Reset clears the reference tables, as at the start of a message.
+func (d *Decoder) Reset() {
+	d.objects, d.strings, d.objs3, d.traits = d.objects[:0], d.strings[:0], d.objs3[:0], d.traits[:0]
+}
+
+func unexpected(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+func (d *Decoder) enter() error {
+	if d.depth++; d.depth > MAX_DEPTH {
+		return fmt.Errorf("%w: more than %d levels", ErrTooDeep, MAX_DEPTH)
+	}
+	return nil
+}
+
+func (d *Decoder) readBytes(n uint64) ([]byte, error) {
+	if n > MAX_LENGTH {
+		return nil, fmt.Errorf("%w: %d bytes", codec.ErrLengthOverflow, n)
+	}
+	b := d.r.ReadBytes(int(n))
+	if err := d.r.Err(); err != nil {
+		return nil, unexpected(err)
+	}
+	if b == nil {
+		b = []byte{}
+	}
+	return b, nil
+}
+
+func (d *Decoder) readFloat() (float64, error) {
+	var v uint64
+	d.r.ReadUint64(&v)
+	return math.Float64frombits(v), unexpected(d.r.Err())
+}
+
+func (d *Decoder) readString0(long bool) (string, error) {
+	var n uint32
+	if long {
+		d.r.ReadUint32(&n)
+	} else {
+		var n16 uint16
+		d.r.ReadUint16(&n16)
+		n = uint32(n16)
+	}
+	if err := d.r.Err(); err != nil {
+		return "", unexpected(err)
+	}
+	b, err := d.readBytes(uint64(n))
+	return string(b), err
+}
+
+// Decode reads an AMF0 value, switching to AMF3 at the avmplus marker. It
+// returns io.EOF at the end of the stream.
+func (d *Decoder) Decode() (any, error) {
+	marker, err := d.r.ReadByte()
+	if err != nil {
+		return nil, d.r.Err()
+	}
+	return d.decode0(marker)
+}
+
+func (d *Decoder) decode0(marker byte) (any, error) {
+	switch marker {
+	case marker0Number:
+		return d.readFloat()
+	case marker0Boolean:
+		b, err := d.r.ReadByte()
+		return b != 0, unexpected(err)
+	case marker0String, marker0LongString:
+		return d.readString0(marker == marker0LongString)
+	case marker0XMLDoc:
+		s, err := d.readString0(true)
+		return XML(s), err
+	case marker0Null:
+		return nil, nil
+	case marker0Undefined:
+		return Undefined{}, nil
+	case marker0Date:
+		ms, err := d.readFloat()
+		var tz int16
+		d.r.ReadInt16(&tz) // reserved, always 0
+		if err == nil {
+			err = unexpected(d.r.Err())
+		}
+		return time.UnixMilli(int64(ms)).UTC(), err
+	case marker0Reference:
+		var i uint16
+		d.r.ReadUint16(&i)
+		if err := d.r.Err(); err != nil {
+			return nil, unexpected(err)
+		}
+		if int(i) >= len(d.objects) {
+			return nil, fmt.Errorf("%w: object %d of %d", ErrInvalidReference, i, len(d.objects))
+		}
+		return d.objects[i], nil
+	case marker0AVMPlus:
+		return d.Decode3()
+	case marker0Object, marker0ECMAArray, marker0TypedObject, marker0StrictArray:
+		if err := d.enter(); err != nil {
+			return nil, err
+		}
+		defer func() { d.depth-- }()
+		return d.complex0(marker)
+	}
+	return nil, fmt.Errorf("%w: AMF0 %#x", ErrInvalidMarker, marker)
+}
+
+func (d *Decoder) complex0(marker byte) (any, error) {
+	switch marker {
+	case marker0StrictArray:
+		var n uint32
+		d.r.ReadUint32(&n)
+		if err := d.r.Err(); err != nil {
+			return nil, unexpected(err)
+		}
+		if n > MAX_LENGTH {
+			return nil, fmt.Errorf("%w: array of %d elements", codec.ErrLengthOverflow, n)
+		}
+		items := make([]any, 0, min(n, 1024))
+		d.objects = append(d.objects, items)
+		at := len(d.objects) - 1
+		for range n {
+			v, err := d.Decode()
+			if err != nil {
+				return nil, unexpected(err)
+			}
+			items = append(items, v)
+		}
+		d.objects[at] = items
+		return items, nil
+	case marker0ECMAArray:
+		var n uint32
+		d.r.ReadUint32(&n) // a hint only; the end marker terminates the array
+		if err := d.r.Err(); err != nil {
+			return nil, unexpected(err)
+		}
+		arr := ECMAArray{}
+		d.objects = append(d.objects, arr)
+		return arr, d.properties0(arr)
+	case marker0TypedObject:
+		class, err := d.readString0(false)
+		if err != nil {
+			return nil, err
+		}
+		obj := TypedObject{Class: class, Fields: Object{}}
+		d.objects = append(d.objects, obj)
+		return obj, d.properties0(obj.Fields)
+	}
+	obj := Object{}
+	d.objects = append(d.objects, obj)
+	return obj, d.properties0(obj)
+}
+
+// properties0 reads name/value pairs up to the object end marker.
+func (d *Decoder) properties0(m map[string]any) error {
+	for {
+		name, err := d.readString0(false)
+		if err != nil {
+			return err
+		}
+		marker, err := d.r.ReadByte()
+		if err != nil {
+			return unexpected(d.r.Err())
+		}
+		if name == "" && marker == marker0ObjectEnd {
+			return nil
+		}
+		if m[name], err = d.decode0(marker); err != nil {
+			return err
+		}
+	}
+}
+
+// Encoder writes AMF values.
+type Encoder struct {
+	w *codec.Writer
+}
+
+// NewEncoder returns an Encoder writing to w, whose byte order it sets to
+// big-endian.
+func NewEncoder(w *codec.Writer) *Encoder {
+	w.WithByteOrder(codec.BE)
+	return &Encoder{w: w}
+}
+
+func (e *Encoder) writeString0(s string) {
+	e.w.WriteUint16(uint16(len(s)))
+	e.w.WriteString(s)
+}
+
+func (e *Encoder) properties0(m map[string]any) error {
+	for _, k := range slices.Sorted(maps.Keys(m)) {
+		if len(k) > math.MaxUint16 {
+			return fmt.Errorf("%w: property name of %d bytes", codec.ErrLengthOverflow, len(k))
+		}
+		e.writeString0(k)
+		if err := e.Encode(m[k]); err != nil {
+			return err
+		}
+	}
+	e.w.WriteBytes([]byte{0, 0, marker0ObjectEnd})
+	return e.w.Err()
+}
+
+// number converts Go numbers to float64.
+func number(v any) (float64, bool) {
+	switch v := v.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int8:
+		return float64(v), true
+	case int16:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint:
+		return float64(v), true
+	case uint8:
+		return float64(v), true
+	case uint16:
+		return float64(v), true
+	case uint32:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	}
+	return 0, false
+}
+
+// Encode writes v as an AMF0 value.
+func (e *Encoder) Encode(v any) error {
+	w := e.w
+	if f, ok := number(v); ok {
+		w.WriteUint8(marker0Number)
+		w.WriteUint64(math.Float64bits(f))
+		return w.Err()
+	}
+	switch v := v.(type) {
+	case nil:
+		w.WriteUint8(marker0Null)
+	case Undefined:
+		w.WriteUint8(marker0Undefined)
+	case bool:
+		w.WriteUint8(marker0Boolean)
+		w.WriteBool(v)
+	case string:
+		if len(v) > math.MaxUint16 {
+			w.WriteUint8(marker0LongString)
+			w.WriteUint32(uint32(len(v)))
+			w.WriteString(v)
+		} else {
+			w.WriteUint8(marker0String)
+			e.writeString0(v)
+		}
+	case XML:
+		w.WriteUint8(marker0XMLDoc)
+		w.WriteUint32(uint32(len(v)))
+		w.WriteString(string(v))
+	case time.Time:
+		w.WriteUint8(marker0Date)
+		w.WriteUint64(math.Float64bits(float64(v.UnixMilli())))
+		w.WriteInt16(0)
+	case Object:
+		w.WriteUint8(marker0Object)
+		return e.properties0(v)
+	case ECMAArray:
+		w.WriteUint8(marker0ECMAArray)
+		w.WriteUint32(uint32(len(v)))
+		return e.properties0(v)
+	case TypedObject:
+		w.WriteUint8(marker0TypedObject)
+		e.writeString0(v.Class)
+		return e.properties0(v.Fields)
+	case []any:
+		w.WriteUint8(marker0StrictArray)
+		w.WriteUint32(uint32(len(v)))
+		for _, item := range v {
+			if err := e.Encode(item); err != nil {
+				return err
+			}
+		}
+	case MixedArray, []byte:
+		// AMF0 has no such types; switch to AMF3 for this value.
+		w.WriteUint8(marker0AVMPlus)
+		return e.Encode3(v)
+	default:
+		return fmt.Errorf("%w: %T", ErrUnsupported, v)
+	}
+	return w.Err()
+}
@@ -0,0 +1,395 @@
+package amf
+
+import (
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+	"time"
+
+	"github.com/oy3o/codec"
+)
+
+// AMF3 type markers.
+const (
+	marker3Undefined = 0x00
+	marker3Null      = 0x01
+	marker3False     = 0x02
+	marker3True      = 0x03
+	marker3Integer   = 0x04
+	marker3Double    = 0x05
+	marker3String    = 0x06
+	marker3XMLDoc    = 0x07
+	marker3Date      = 0x08
+	marker3Array     = 0x09
+	marker3Object    = 0x0A
+	marker3XML       = 0x0B
+	marker3ByteArray = 0x0C
+)
+
+// U29 is the variable-length 29-bit integer of AMF3.
+const (
+	maxU29 = 1<<29 - 1
+	minInt = -1 << 28
+	maxInt = 1<<28 - 1
+)
+
+// traits describe the class of an AMF3 object.
+type traits struct {
+	class   string
+	dynamic bool
+	members []string
+}
+
+func (d *Decoder) readU29() (uint32, error) {
+	var v uint32
+	for i := range 4 {
+		b, err := d.r.ReadByte()
+		if err != nil {
+			return 0, unexpected(d.r.Err())
+		}
+		if i == 3 {
+			return v<<8 | uint32(b), nil
+		}
+		v = v<<7 | uint32(b&0x7F)
+		if b&0x80 == 0 {
+			break
+		}
+	}
+	return v, nil
+}
+
+// readHeader reads a U29 with a reference flag in its low bit, returning
+// the remaining bits and whether they are a value rather than a reference.
+func (d *Decoder) readHeader() (uint32, bool, error) {
+	h, err := d.readU29()
+	return h >> 1, h&1 != 0, err
+}
+
+func (d *Decoder) readString3() (string, error) {
+	n, inline, err := d.readHeader()
+	if err != nil {
+		return "", err
+	}
+	if !inline {
+		if int(n) >= len(d.strings) {
+			return "", fmt.Errorf("%w: string %d of %d", ErrInvalidReference, n, len(d.strings))
+		}
+		return d.strings[n], nil
+	}
+	b, err := d.readBytes(uint64(n))
+	if err != nil || n == 0 {
+		return "", err
+	}
+	d.strings = append(d.strings, string(b))
+	return string(b), nil
+}
+
+// object3 resolves a reference to the AMF3 object table.
+func (d *Decoder) object3(i uint32) (any, error) {
+	if int(i) >= len(d.objs3) {
+		return nil, fmt.Errorf("%w: object %d of %d", ErrInvalidReference, i, len(d.objs3))
+	}
+	return d.objs3[i], nil
+}
+
+// Decode3 reads an AMF3 value. It returns io.EOF at the end of the stream.
+func (d *Decoder) Decode3() (any, error) {
+	marker, err := d.r.ReadByte()
+	if err != nil {
+		return nil, d.r.Err()
+	}
+	switch marker {
+	case marker3Undefined:
+		return Undefined{}, nil
+	case marker3Null:
+		return nil, nil
+	case marker3False, marker3True:
+		return marker == marker3True, nil
+	case marker3Integer:
+		v, err := d.readU29()
+		return int32(v<<3) >> 3, err // sign-extend 29 bits
+	case marker3Double:
+		return d.readFloat()
+	case marker3String:
+		return d.readString3()
+	case marker3XMLDoc, marker3XML, marker3ByteArray, marker3Date:
+		n, inline, err := d.readHeader()
+		if err != nil || !inline {
+			if err != nil {
+				return nil, err
+			}
+			return d.object3(n)
+		}
+		var v any
+		switch marker {
+		case marker3Date:
+			var ms float64
+			ms, err = d.readFloat()
+			v = time.UnixMilli(int64(ms)).UTC()
+		case marker3ByteArray:
+			v, err = d.readBytes(uint64(n))
+		default:
+			var b []byte
+			b, err = d.readBytes(uint64(n))
+			v = XML(b)
+		}
+		if err != nil {
+			return nil, err
+		}
+		d.objs3 = append(d.objs3, v)
+		return v, nil
+	case marker3Array, marker3Object:
+		if err := d.enter(); err != nil {
+			return nil, err
+		}
+		defer func() { d.depth-- }()
+		if marker == marker3Array {
+			return d.array3()
+		}
+		return d.objectValue3()
+	}
+	return nil, fmt.Errorf("%w: AMF3 %#x", ErrInvalidMarker, marker)
+}
+
+func (d *Decoder) array3() (any, error) {
+	n, inline, err := d.readHeader()
+	if err != nil {
+		return nil, err
+	}
+	if !inline {
+		return d.object3(n)
+	}
+	if n > MAX_LENGTH {
+		return nil, fmt.Errorf("%w: array of %d elements", codec.ErrLengthOverflow, n)
+	}
+	// Reserve the slot before the elements, which may refer to it.
+	at := len(d.objs3)
+	d.objs3 = append(d.objs3, nil)
+	assoc := map[string]any{}
+	if err := d.members3(assoc); err != nil {
+		return nil, err
+	}
+	dense := make([]any, 0, min(n, 1024))
+	for range n {
+		v, err := d.Decode3()
+		if err != nil {
+			return nil, unexpected(err)
+		}
+		dense = append(dense, v)
+	}
+	if len(assoc) == 0 {
+		d.objs3[at] = dense
+		return dense, nil
+	}
+	v := MixedArray{Assoc: assoc, Dense: dense}
+	d.objs3[at] = v
+	return v, nil
+}
+
+// members3 reads dynamic name/value pairs up to the empty name.
+func (d *Decoder) members3(m map[string]any) error {
+	for {
+		name, err := d.readString3()
+		if err != nil || name == "" {
+			return err
+		}
+		if m[name], err = d.Decode3(); err != nil {
+			return unexpected(err)
+		}
+	}
+}
+
+func (d *Decoder) objectValue3() (any, error) {
+	h, inline, err := d.readHeader()
+	if err != nil {
+		return nil, err
+	}
+	if !inline {
+		return d.object3(h)
+	}
+	var t traits
+	switch {
+	case h&1 == 0: // traits reference
+		i := h >> 1
+		if int(i) >= len(d.traits) {
+			return nil, fmt.Errorf("%w: traits %d of %d", ErrInvalidReference, i, len(d.traits))
+		}
+		t = d.traits[i]
+	case h&2 != 0:
+		class, _ := d.readString3()
+		return nil, fmt.Errorf("%w: externalizable object %q", ErrUnsupported, class)
+	default:
+		t.dynamic = h&4 != 0
+		count := h >> 3
+		if t.class, err = d.readString3(); err != nil {
+			return nil, err
+		}
+		if count > MAX_LENGTH {
+			return nil, fmt.Errorf("%w: %d sealed members", codec.ErrLengthOverflow, count)
+		}
+		t.members = make([]string, 0, min(count, 1024))
+		for range count {
+			name, err := d.readString3()
+			if err != nil {
+				return nil, err
+			}
+			t.members = append(t.members, name)
+		}
+		d.traits = append(d.traits, t)
+	}
+	fields := Object{}
+	var v any = fields
+	if t.class != "" {
+		v = TypedObject{Class: t.class, Fields: fields}
+	}
+	d.objs3 = append(d.objs3, v)
+	for _, name := range t.members {
+		if fields[name], err = d.Decode3(); err != nil {
+			return nil, unexpected(err)
+		}
+	}
+	if t.dynamic {
+		if err := d.members3(fields); err != nil {
+			return nil, err
+		}
+	}
+	return v, nil
+}
+
+func (e *Encoder) writeU29(v uint32) error {
+	w := e.w
+	switch {
+	case v < 1<<7:
+		w.WriteUint8(uint8(v))
+	case v < 1<<14:
+		w.WriteBytes([]byte{uint8(v>>7) | 0x80, uint8(v) & 0x7F})
+	case v < 1<<21:
+		w.WriteBytes([]byte{uint8(v>>14) | 0x80, uint8(v>>7) | 0x80, uint8(v) & 0x7F})
+	case v <= maxU29:
+		w.WriteBytes([]byte{uint8(v>>22) | 0x80, uint8(v>>15) | 0x80, uint8(v>>8) | 0x80, uint8(v)})
+	default:
+		return fmt.Errorf("%w: %d does not fit a U29", codec.ErrLengthOverflow, v)
+	}
+	return w.Err()
+}
+
+// writeInline writes the header of an inline value of length n.
+func (e *Encoder) writeInline(n int) error {
+	if n > maxU29>>1 {
+		return fmt.Errorf("%w: %d bytes or elements", codec.ErrLengthOverflow, n)
+	}
+	return e.writeU29(uint32(n)<<1 | 1)
+}
+
+// writeString3 writes s inline; the encoder does not build reference tables.
+func (e *Encoder) writeString3(s string) error {
+	if err := e.writeInline(len(s)); err != nil {
+		return err
+	}
+	e.w.WriteString(s)
+	return e.w.Err()
+}
+
+func (e *Encoder) members3(m map[string]any) error {
+	for _, k := range slices.Sorted(maps.Keys(m)) {
+		if k == "" {
+			return fmt.Errorf("%w: empty member name", ErrUnsupported)
+		}
+		if err := e.writeString3(k); err != nil {
+			return err
+		}
+		if err := e.Encode3(m[k]); err != nil {
+			return err
+		}
+	}
+	return e.writeString3("")
+}
+
+// object3 writes a dynamic object with no sealed members.
+func (e *Encoder) object3(class string, fields Object) error {
+	e.w.WriteUint8(marker3Object)
+	if err := e.writeU29(0x0B); err != nil { // inline object, inline dynamic traits
+		return err
+	}
+	if err := e.writeString3(class); err != nil {
+		return err
+	}
+	return e.members3(fields)
+}
+
+// Encode3 writes v as an AMF3 value. Integers within 29 bits are written as
+// AMF3 integers, other numbers as doubles; objects are written as dynamic
+// objects, and every value inline.
+func (e *Encoder) Encode3(v any) error {
+	w := e.w
+	switch v := v.(type) {
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		if f, _ := number(v); f >= minInt && f <= maxInt {
+			w.WriteUint8(marker3Integer)
+			return e.writeU29(uint32(int32(f)) & maxU29)
+		}
+	}
+	if f, ok := number(v); ok {
+		w.WriteUint8(marker3Double)
+		w.WriteUint64(math.Float64bits(f))
+		return w.Err()
+	}
+	switch v := v.(type) {
+	case nil:
+		w.WriteUint8(marker3Null)
+	case Undefined:
+		w.WriteUint8(marker3Undefined)
+	case bool:
+		if v {
+			w.WriteUint8(marker3True)
+		} else {
+			w.WriteUint8(marker3False)
+		}
+	case string:
+		w.WriteUint8(marker3String)
+		return e.writeString3(v)
+	case XML:
+		w.WriteUint8(marker3XML)
+		return e.writeString3(string(v))
+	case []byte:
+		w.WriteUint8(marker3ByteArray)
+		if err := e.writeInline(len(v)); err != nil {
+			return err
+		}
+		w.WriteBytes(v)
+	case time.Time:
+		w.WriteUint8(marker3Date)
+		w.WriteUint8(0x01)
+		w.WriteUint64(math.Float64bits(float64(v.UnixMilli())))
+	case []any:
+		return e.array3(nil, v)
+	case MixedArray:
+		return e.array3(v.Assoc, v.Dense)
+	case ECMAArray:
+		return e.array3(v, nil)
+	case Object:
+		return e.object3("", v)
+	case TypedObject:
+		return e.object3(v.Class, v.Fields)
+	default:
+		return fmt.Errorf("%w: %T", ErrUnsupported, v)
+	}
+	return w.Err()
+}
+
+func (e *Encoder) array3(assoc map[string]any, dense []any) error {
+	e.w.WriteUint8(marker3Array)
+	if err := e.writeInline(len(dense)); err != nil {
+		return err
+	}
+	if err := e.members3(assoc); err != nil {
+		return err
+	}
+	for _, item := range dense {
+		if err := e.Encode3(item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+//go:build test
+
+package amf
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/oy3o/codec"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func decoder(data string) *Decoder {
+	r, _ := codec.NewReader(bytes.NewReader([]byte(data)))
+	return NewDecoder(r)
+}
+
+func TestDecode0(t *testing.T) {
+	// An RTMP _result reply: name, transaction ID, properties, information.
+	d := decoder("\x02\x00\x07_result" +
+		"\x00\x3f\xf0\x00\x00\x00\x00\x00\x00" +
+		"\x05" +
+		"\x03\x00\x04code\x02\x00\x02ok\x00\x02up\x01\x01\x00\x00\x09" +
+		"\x07\x00\x00")
+	var values []any
+	for {
+		v, err := d.Decode()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		values = append(values, v)
+	}
+	obj := Object{"code": "ok", "up": true}
+	assert.Equal(t, []any{"_result", 1.0, nil, obj, obj}, values)
+
+	_, err := decoder("\x07\x00\x00").Decode()
+	assert.ErrorIs(t, err, ErrInvalidReference)
+	_, err = decoder("\x0d").Decode()
+	assert.ErrorIs(t, err, ErrInvalidMarker)
+	_, err = decoder("\x02\x00\x05ab").Decode()
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+}
+
+func TestDecode3(t *testing.T) {
+	v, err := decoder("\x09\x05\x01\x06\x03a\x06\x00").Decode3()
+	require.NoError(t, err)
+	assert.Equal(t, []any{"a", "a"}, v)
+
+	v, err = decoder("\x04\xff\xff\xff\xff").Decode3()
+	require.NoError(t, err)
+	assert.Equal(t, int32(-1), v)
+
+	// A typed object with sealed member x, then a second using its traits.
+	d := decoder("\x09\x05\x01" +
+		"\x0a\x13\x05Pt\x03x\x04\x01" +
+		"\x0a\x01\x04\x02")
+	v, err = d.Decode3()
+	require.NoError(t, err)
+	assert.Equal(t, []any{
+		TypedObject{Class: "Pt", Fields: Object{"x": int32(1)}},
+		TypedObject{Class: "Pt", Fields: Object{"x": int32(2)}},
+	}, v)
+
+	_, err = decoder("\x0a\x07\x05Ex").Decode3()
+	assert.ErrorIs(t, err, ErrUnsupported)
+	_, err = decoder("\x06\x02").Decode3()
+	assert.ErrorIs(t, err, ErrInvalidReference)
+
+	// A long string of 1 MiB is refused by the Reader's allocation limit.
+	r, _ := codec.NewReaderOpts(bytes.NewReader([]byte("\x0c\x00\x10\x00\x00")), codec.WithMaxAlloc(1<<10))
+	_, err = NewDecoder(r).Decode()
+	assert.ErrorIs(t, err, codec.ErrLengthOverflow)
+}
+
+func TestRoundTrip(t *testing.T) {
+	when := time.UnixMilli(1700000000123).UTC()
+	values := []any{
+		1.5, true, "str", nil, Undefined{}, when, XML("<a/>"),
+		Object{"a": 1.0, "b": []any{"x", false}},
+		ECMAArray{"k": "v"},
+		TypedObject{Class: "C", Fields: Object{"f": 2.0}},
+	}
+	var buf bytes.Buffer
+	w, _ := codec.NewWriter(&buf)
+	e := NewEncoder(w)
+	for _, v := range values {
+		require.NoError(t, e.Encode(v))
+	}
+	require.NoError(t, e.Encode([]byte{1, 2}))
+	require.NoError(t, w.Flush())
+
+	r, _ := codec.NewReader(&buf)
+	d := NewDecoder(r)
+	for _, want := range values {
+		v, err := d.Decode()
+		require.NoError(t, err)
+		assert.Equal(t, want, v)
+	}
+	v, err := d.Decode()
+	require.NoError(t, err)
+	assert.Equal(t, []byte{1, 2}, v)
+
+	values3 := []any{
+		int32(-5), int32(1 << 27), 1e10, "", "s", []byte{9}, when, XML("<b/>"),
+		[]any{int32(1), "two"},
+		MixedArray{Assoc: map[string]any{"k": true}, Dense: []any{nil}},
+		Object{"o": Object{}},
+		TypedObject{Class: "C", Fields: Object{"f": Undefined{}}},
+	}
+	buf.Reset()
+	w, _ = codec.NewWriter(&buf)
+	e = NewEncoder(w)
+	for _, v := range values3 {
+		require.NoError(t, e.Encode3(v))
+	}
+	require.NoError(t, w.Flush())
+	r, _ = codec.NewReader(&buf)
+	d = NewDecoder(r)
+	for _, want := range values3 {
+		v, err := d.Decode3()
+		require.NoError(t, err)
+		assert.Equal(t, want, v)
+	}
+	assert.ErrorIs(t, e.Encode(struct{}{}), ErrUnsupported)
+}
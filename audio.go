@@ -0,0 +1,337 @@
+package codec
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+)
+
+// SampleEncoding is the encoding of a single audio sample.
+type SampleEncoding uint8
+
+const (
+	SampleU8      SampleEncoding = iota + 1 // unsigned 8-bit PCM, as in WAV
+	SampleS16                               // signed 16-bit PCM
+	SampleS24                               // signed 24-bit PCM, packed in 3 bytes
+	SampleS32                               // signed 32-bit PCM
+	SampleFloat32                           // IEEE 754 float in [-1, 1]
+	SampleFloat64                           // IEEE 754 double in [-1, 1]
+	SampleMuLaw                             // G.711 μ-law
+	SampleALaw                              // G.711 A-law
+)
+
+// SampleFormat is an audio sample encoding and the byte order of its
+// multi-byte forms. Order is ignored by the 8-bit encodings and defaults to
+// little-endian, the order of WAV files.
+type SampleFormat struct {
+	Encoding SampleEncoding
+	Order    binary.ByteOrder
+}
+
+// width returns the size of one sample in bytes, or 0 for an unknown encoding.
+func (f SampleFormat) width() int {
+	switch f.Encoding {
+	case SampleU8, SampleMuLaw, SampleALaw:
+		return 1
+	case SampleS16:
+		return 2
+	case SampleS24:
+		return 3
+	case SampleS32, SampleFloat32:
+		return 4
+	case SampleFloat64:
+		return 8
+	}
+	return 0
+}
+
+func (f SampleFormat) order() binary.ByteOrder {
+	if f.Order == nil {
+		return LE
+	}
+	return f.Order
+}
+
+// decode returns the sample in b scaled to the full int32 range.
+func (f SampleFormat) decode(b []byte) int32 {
+	order := f.order()
+	switch f.Encoding {
+	case SampleU8:
+		return int32(int8(b[0]^0x80)) << 24
+	case SampleS16:
+		return int32(int16(order.Uint16(b))) << 16
+	case SampleS24:
+		if order == LE {
+			return int32(uint32(b[0])<<8 | uint32(b[1])<<16 | uint32(b[2])<<24)
+		}
+		return int32(uint32(b[2])<<8 | uint32(b[1])<<16 | uint32(b[0])<<24)
+	case SampleS32:
+		return int32(order.Uint32(b))
+	case SampleFloat32:
+		return floatToSample(float64(math.Float32frombits(order.Uint32(b))))
+	case SampleFloat64:
+		return floatToSample(math.Float64frombits(order.Uint64(b)))
+	case SampleMuLaw:
+		return int32(MuLawDecode(b[0])) << 16
+	default:
+		return int32(ALawDecode(b[0])) << 16
+	}
+}
+
+// encode stores the full-range sample v in b. Narrower encodings truncate.
+func (f SampleFormat) encode(b []byte, v int32) {
+	order := f.order()
+	switch f.Encoding {
+	case SampleU8:
+		b[0] = byte(v>>24) ^ 0x80
+	case SampleS16:
+		order.PutUint16(b, uint16(v>>16))
+	case SampleS24:
+		if order == LE {
+			b[0], b[1], b[2] = byte(v>>8), byte(v>>16), byte(v>>24)
+		} else {
+			b[0], b[1], b[2] = byte(v>>24), byte(v>>16), byte(v>>8)
+		}
+	case SampleS32:
+		order.PutUint32(b, uint32(v))
+	case SampleFloat32:
+		order.PutUint32(b, math.Float32bits(float32(float64(v)/(1<<31))))
+	case SampleFloat64:
+		order.PutUint64(b, math.Float64bits(float64(v)/(1<<31)))
+	case SampleMuLaw:
+		b[0] = MuLawEncode(int16(v >> 16))
+	default:
+		b[0] = ALawEncode(int16(v >> 16))
+	}
+}
+
+// floatToSample scales f to the int32 range, clipping values outside [-1, 1].
+func floatToSample(f float64) int32 {
+	switch {
+	case math.IsNaN(f):
+		return 0
+	case f >= 1:
+		return math.MaxInt32
+	case f <= -1:
+		return math.MinInt32
+	}
+	return int32(f * (1 << 31))
+}
+
+// convertSamples converts the whole samples of in from one format to another,
+// appending them to out.
+func convertSamples(out, in []byte, from, to SampleFormat) []byte {
+	fw, tw := from.width(), to.width()
+	for ; len(in) >= fw; in = in[fw:] {
+		out = append(out, empty[:tw]...)
+		to.encode(out[len(out)-tw:], from.decode(in))
+	}
+	return out
+}
+
+func checkSampleFormats(from, to SampleFormat) error {
+	if from.width() == 0 {
+		return fmt.Errorf("%w: encoding %d", ErrInvalidSampleFormat, from.Encoding)
+	}
+	if to.width() == 0 {
+		return fmt.Errorf("%w: encoding %d", ErrInvalidSampleFormat, to.Encoding)
+	}
+	return nil
+}
+
+// samplesPerBlock is the number of samples converted per underlying read or write.
+const samplesPerBlock = 1024
+
+// SampleReader converts the samples read from an io.Reader to another format.
+type SampleReader struct {
+	src      io.Reader
+	from, to SampleFormat
+	in       []byte // raw input, of which the first n bytes are pending
+	n        int
+	buf      []byte // converted samples
+	out      []byte // part of buf not yet returned
+	err      error
+}
+
+// NewSampleReader returns a reader yielding the samples of r, encoded in
+// format from, converted to format to. A stream ending inside a sample fails
+// with ErrTruncatedData.
+func NewSampleReader(r io.Reader, from, to SampleFormat) (*SampleReader, error) {
+	if r == nil {
+		return nil, ErrNilIO
+	}
+	if err := checkSampleFormats(from, to); err != nil {
+		return nil, err
+	}
+	return &SampleReader{
+		src:  r,
+		from: from,
+		to:   to,
+		in:   make([]byte, samplesPerBlock*from.width()),
+		buf:  make([]byte, 0, samplesPerBlock*to.width()),
+	}, nil
+}
+
+// Read implements io.Reader.
+func (s *SampleReader) Read(p []byte) (int, error) {
+	for len(s.out) == 0 {
+		if s.err != nil {
+			return 0, s.err
+		}
+		m, err := s.src.Read(s.in[s.n:])
+		s.n += m
+		whole := s.n - s.n%s.from.width()
+		s.out = convertSamples(s.buf[:0], s.in[:whole], s.from, s.to)
+		s.n = copy(s.in, s.in[whole:s.n])
+		if err != nil {
+			if err == io.EOF && s.n > 0 {
+				err = fmt.Errorf("%w: partial sample of %d bytes", ErrTruncatedData, s.n)
+			}
+			s.err = err
+		}
+	}
+	n := copy(p, s.out)
+	s.out = s.out[n:]
+	return n, nil
+}
+
+// SampleWriter converts the samples written to it to another format before
+// writing them to an io.Writer.
+type SampleWriter struct {
+	dst      io.Writer
+	from, to SampleFormat
+	partial  []byte // bytes of an incomplete sample carried to the next Write
+	buf      []byte
+}
+
+// NewSampleWriter returns a writer accepting samples encoded in format from
+// and writing them to w converted to format to.
+func NewSampleWriter(w io.Writer, from, to SampleFormat) (*SampleWriter, error) {
+	if w == nil {
+		return nil, ErrNilIO
+	}
+	if err := checkSampleFormats(from, to); err != nil {
+		return nil, err
+	}
+	return &SampleWriter{
+		dst:     w,
+		from:    from,
+		to:      to,
+		partial: make([]byte, 0, from.width()),
+		buf:     make([]byte, 0, samplesPerBlock*to.width()),
+	}, nil
+}
+
+// Write implements io.Writer. Samples may be split across calls.
+func (s *SampleWriter) Write(p []byte) (int, error) {
+	fw := s.from.width()
+	n := len(p)
+	if len(s.partial) > 0 {
+		k := min(fw-len(s.partial), len(p))
+		s.partial = append(s.partial, p[:k]...)
+		p = p[k:]
+		if len(s.partial) < fw {
+			return n, nil
+		}
+		if err := s.flush(s.partial); err != nil {
+			return 0, err
+		}
+		s.partial = s.partial[:0]
+	}
+	for len(p) >= fw {
+		block := p[:min(len(p)-len(p)%fw, samplesPerBlock*fw)]
+		if err := s.flush(block); err != nil {
+			return n - len(p), err
+		}
+		p = p[len(block):]
+	}
+	s.partial = append(s.partial, p...)
+	return n, nil
+}
+
+func (s *SampleWriter) flush(in []byte) error {
+	s.buf = convertSamples(s.buf[:0], in, s.from, s.to)
+	_, err := s.dst.Write(s.buf)
+	return err
+}
+
+// Close reports ErrTruncatedData if the bytes written ended inside a sample.
+// It does not close the underlying writer.
+func (s *SampleWriter) Close() error {
+	if len(s.partial) > 0 {
+		return fmt.Errorf("%w: partial sample of %d bytes", ErrTruncatedData, len(s.partial))
+	}
+	return nil
+}
+
+// MuLawEncode compresses a 16-bit linear sample to G.711 μ-law.
+func MuLawEncode(pcm int16) byte {
+	const bias, clip = 0x84 >> 2, 8159
+	v := int(pcm) >> 2
+	mask := byte(0xFF)
+	if v < 0 {
+		v, mask = -v, 0x7F
+	}
+	v = min(v, clip) + bias
+	seg := 0
+	for seg < 8 && v > 0x40<<seg-1 {
+		seg++
+	}
+	if seg >= 8 {
+		return 0x7F ^ mask
+	}
+	return (byte(seg)<<4 | byte(v>>(seg+1))&0x0F) ^ mask
+}
+
+// MuLawDecode expands a G.711 μ-law byte to a 16-bit linear sample.
+func MuLawDecode(u byte) int16 {
+	const bias = 0x84
+	u = ^u
+	t := (int(u&0x0F)<<3 + bias) << (u & 0x70 >> 4)
+	if u&0x80 != 0 {
+		return int16(bias - t)
+	}
+	return int16(t - bias)
+}
+
+// ALawEncode compresses a 16-bit linear sample to G.711 A-law.
+func ALawEncode(pcm int16) byte {
+	v := int(pcm) >> 3
+	mask := byte(0xD5)
+	if v < 0 {
+		v, mask = -v-1, 0x55
+	}
+	seg := 0
+	for seg < 8 && v > 0x20<<seg-1 {
+		seg++
+	}
+	if seg >= 8 {
+		return 0x7F ^ mask
+	}
+	a := byte(seg) << 4
+	if seg < 2 {
+		a |= byte(v>>1) & 0x0F
+	} else {
+		a |= byte(v>>seg) & 0x0F
+	}
+	return a ^ mask
+}
+
+// ALawDecode expands a G.711 A-law byte to a 16-bit linear sample.
+func ALawDecode(a byte) int16 {
+	a ^= 0x55
+	t := int(a&0x0F) << 4
+	switch seg := a & 0x70 >> 4; seg {
+	case 0:
+		t += 8
+	case 1:
+		t += 0x108
+	default:
+		t = (t + 0x108) << (seg - 1)
+	}
+	if a&0x80 != 0 {
+		return int16(t)
+	}
+	return int16(-t)
+}
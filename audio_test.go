@@ -0,0 +1,92 @@
+//go:build test
+
+package codec
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompanding(t *testing.T) {
+	assert.Equal(t, byte(0xFF), MuLawEncode(0))
+	assert.Equal(t, byte(0xD5), ALawEncode(0))
+	assert.Equal(t, int16(-32124), MuLawDecode(0x00))
+	assert.Equal(t, int16(32124), MuLawDecode(0x80))
+	assert.Equal(t, int16(8), ALawDecode(0xD5))
+	assert.Equal(t, int16(-8), ALawDecode(0x55))
+	assert.Equal(t, byte(0x80), MuLawEncode(math.MaxInt16))
+	assert.Equal(t, byte(0x2A), ALawEncode(math.MinInt16))
+
+	// Every code decodes to a value that encodes back to an equivalent code.
+	for i := range 256 {
+		b := byte(i)
+		assert.Equal(t, MuLawDecode(b), MuLawDecode(MuLawEncode(MuLawDecode(b))), "μ-law %#02x", b)
+		assert.Equal(t, b, ALawEncode(ALawDecode(b)), "A-law %#02x", b)
+	}
+}
+
+func TestSampleReader(t *testing.T) {
+	s16 := SampleFormat{Encoding: SampleS16, Order: LE}
+	pcm := []int16{0, 1000, -1000, math.MaxInt16, math.MinInt16}
+	var src bytes.Buffer
+	binary.Write(&src, LE, pcm)
+
+	for _, to := range []SampleFormat{
+		{Encoding: SampleS24, Order: BE},
+		{Encoding: SampleS32, Order: LE},
+		{Encoding: SampleFloat32, Order: BE},
+		{Encoding: SampleFloat64, Order: LE},
+	} {
+		sr, err := NewSampleReader(iotest.OneByteReader(bytes.NewReader(src.Bytes())), s16, to)
+		require.NoError(t, err)
+		wide, err := io.ReadAll(sr)
+		require.NoError(t, err)
+		require.Len(t, wide, len(pcm)*to.width())
+
+		back, err := NewSampleReader(bytes.NewReader(wide), to, s16)
+		require.NoError(t, err)
+		got, err := io.ReadAll(back)
+		require.NoError(t, err)
+		assert.Equal(t, src.Bytes(), got, "via encoding %d", to.Encoding)
+	}
+
+	u8, err := NewSampleReader(bytes.NewReader([]byte{0x80, 0xFF, 0x00}), SampleFormat{Encoding: SampleU8}, s16)
+	require.NoError(t, err)
+	got, err := io.ReadAll(u8)
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0x00, 0x00, 0x00, 0x7F, 0x00, 0x80}, got)
+
+	sr, err := NewSampleReader(bytes.NewReader([]byte{1, 2, 3}), s16, s16)
+	require.NoError(t, err)
+	_, err = io.ReadAll(sr)
+	assert.ErrorIs(t, err, ErrTruncatedData)
+
+	_, err = NewSampleReader(&src, s16, SampleFormat{})
+	assert.ErrorIs(t, err, ErrInvalidSampleFormat)
+}
+
+func TestSampleWriter(t *testing.T) {
+	s16 := SampleFormat{Encoding: SampleS16, Order: BE}
+	mu := SampleFormat{Encoding: SampleMuLaw}
+	var dst bytes.Buffer
+	sw, err := NewSampleWriter(&dst, s16, mu)
+	require.NoError(t, err)
+	n, err := sw.Write([]byte{0x00, 0x00, 0x7F})
+	require.NoError(t, err)
+	assert.Equal(t, 3, n)
+	n, err = sw.Write([]byte{0xFF})
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	require.NoError(t, sw.Close())
+	assert.Equal(t, []byte{0xFF, 0x80}, dst.Bytes())
+
+	sw.Write([]byte{0x01})
+	assert.ErrorIs(t, sw.Close(), ErrTruncatedData)
+}
@@ -0,0 +1,198 @@
+//go:build !tinygo && !codec_tiny
+
+package codec
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"testing"
+)
+
+// benchBlock is the number of bytes processed per benchmark operation by the
+// streaming benchmarks, so their MB/s figures are directly comparable.
+const benchBlock = 4096
+
+// benchBacking creates the writer or reader a streaming benchmark runs over,
+// and returns a function releasing it.
+type benchBacking struct {
+	name   string
+	writer func(b *testing.B) (io.Writer, func())
+	reader func(b *testing.B) (io.Reader, func())
+}
+
+var benchBackings = []benchBacking{
+	{
+		name: "bytes",
+		writer: func(b *testing.B) (io.Writer, func()) {
+			return NewBytesWriter(make([]byte, benchBlock)), func() {}
+		},
+		reader: func(b *testing.B) (io.Reader, func()) {
+			return NewBytesReader(make([]byte, benchBlock)), func() {}
+		},
+	},
+	{
+		name: "bufio",
+		writer: func(b *testing.B) (io.Writer, func()) {
+			return io.Discard, func() {}
+		},
+		reader: func(b *testing.B) (io.Reader, func()) {
+			return Zero, func() {}
+		},
+	},
+	{
+		name: "pipe",
+		writer: func(b *testing.B) (io.Writer, func()) {
+			c1, c2 := net.Pipe()
+			go io.Copy(io.Discard, c2)
+			return c1, func() { c1.Close(); c2.Close() }
+		},
+		reader: func(b *testing.B) (io.Reader, func()) {
+			c1, c2 := net.Pipe()
+			go io.Copy(c1, Zero)
+			return c2, func() { c1.Close(); c2.Close() }
+		},
+	},
+}
+
+// rewind resets in-memory backings between operations.
+func rewind(v any) {
+	switch x := v.(type) {
+	case *BytesWriter:
+		x.Reset()
+	case *BytesReader:
+		x.N = 0
+	}
+}
+
+func BenchmarkWriterUint64(b *testing.B) {
+	for _, backing := range benchBackings {
+		b.Run(backing.name, func(b *testing.B) {
+			dst, release := backing.writer(b)
+			defer release()
+			w, err := NewWriter(dst)
+			if err != nil {
+				b.Fatal(err)
+			}
+			b.SetBytes(benchBlock)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				rewind(dst)
+				for j := 0; j < benchBlock/8; j++ {
+					w.WriteUint64(uint64(j))
+				}
+				if err := w.Flush(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkReaderUint64(b *testing.B) {
+	for _, backing := range benchBackings {
+		b.Run(backing.name, func(b *testing.B) {
+			src, release := backing.reader(b)
+			defer release()
+			r, err := NewReaderSize(src, benchBlock)
+			if err != nil {
+				b.Fatal(err)
+			}
+			var v uint64
+			b.SetBytes(benchBlock)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				rewind(src)
+				for j := 0; j < benchBlock/8; j++ {
+					r.ReadUint64(&v)
+				}
+				if err := r.Err(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// benchList returns a list of Fixed codecs encoding to benchBlock bytes.
+func benchList() *List0[*Fixed[PODBenchmarkPayload]] {
+	items := make([]*Fixed[PODBenchmarkPayload], benchBlock/binaryPODSize)
+	for i := range items {
+		items[i] = &Fixed[PODBenchmarkPayload]{Payload: PODBenchmarkPayload{ID: uint32(i), Val1: uint64(i)}}
+	}
+	return NewList0(items)
+}
+
+// binaryPODSize is the encoded size of PODBenchmarkPayload.
+const binaryPODSize = 32
+
+func BenchmarkListWriteTo(b *testing.B) {
+	l := benchList()
+	for _, backing := range benchBackings {
+		b.Run(backing.name, func(b *testing.B) {
+			dst, release := backing.writer(b)
+			defer release()
+			b.SetBytes(int64(l.Size()))
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				rewind(dst)
+				if _, err := l.WriteTo(dst); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkListReadFrom(b *testing.B) {
+	data, err := benchList().MarshalBinary()
+	if err != nil {
+		b.Fatal(err)
+	}
+	n := len(data) / binaryPODSize
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		l := NewList0(make([]*Fixed[PODBenchmarkPayload], 0, n))
+		if _, err := l.ReadFrom(NewBytesReader(data)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkTranscodeOrder(b *testing.B) {
+	layout, err := LayoutOf[PODBenchmarkPayload]()
+	if err != nil {
+		b.Fatal(err)
+	}
+	src := bytes.Repeat([]byte{1, 2, 3, 4, 5, 6, 7, 8}, 64*benchBlock/8)
+	b.SetBytes(int64(len(src)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := TranscodeOrder(io.Discard, bytes.NewReader(src), layout); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkCopyRecords(b *testing.B) {
+	layout, err := LayoutOf[PODBenchmarkPayload]()
+	if err != nil {
+		b.Fatal(err)
+	}
+	src := bytes.Repeat([]byte{1, 2, 3, 4, 5, 6, 7, 8}, 64*benchBlock/8)
+	odd := func(r Record) bool { return r.Uint(3)%2 == 1 }
+	b.SetBytes(int64(len(src)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := CopyRecords(io.Discard, bytes.NewReader(src), layout, odd, []int{0, 3}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -1,3 +1,5 @@
+//go:build !tinygo && !codec_tiny
+
 package codec
 
 import (
@@ -19,6 +21,7 @@ type BenchmarkCodec = Fixed[BenchmarkPayload]
 
 func BenchmarkFixedMarshalBinary(b *testing.B) {
 	c := &BenchmarkCodec{Payload: BenchmarkPayload{ID: 1, Val1: 100}}
+	b.SetBytes(int64(c.Size()))
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
 		_, _ = c.MarshalBinary()
@@ -48,6 +51,7 @@ func BenchmarkStandardBinaryEncodeWithSize(b *testing.B) {
 func BenchmarkFixedMarshalTo(b *testing.B) {
 	c := &BenchmarkCodec{Payload: BenchmarkPayload{ID: 1, Val1: 100}}
 	buf := make([]byte, c.Size())
+	b.SetBytes(int64(c.Size()))
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
 		_, _ = c.MarshalTo(buf)
@@ -67,6 +71,7 @@ func BenchmarkStandardBinaryEncodeWithBuf(b *testing.B) {
 func BenchmarkFixedUnmarshalBinary(b *testing.B) {
 	c := &BenchmarkCodec{Payload: BenchmarkPayload{ID: 1, Val1: 100}}
 	data, _ := c.MarshalBinary()
+	b.SetBytes(int64(c.Size()))
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
 		var c2 BenchmarkCodec
@@ -89,6 +94,7 @@ func BenchmarkFixedReadFrom(b *testing.B) {
 	c := &BenchmarkCodec{Payload: BenchmarkPayload{ID: 1, Val1: 100}}
 	data, _ := c.MarshalBinary()
 	var c2 BenchmarkCodec
+	b.SetBytes(int64(c.Size()))
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
 		r := NewBytesReader(data)
@@ -111,6 +117,7 @@ func BenchmarkStandardBinaryRead(b *testing.B) {
 
 func BenchmarkFixedWriteTo(b *testing.B) {
 	c := &BenchmarkCodec{Payload: BenchmarkPayload{ID: 1, Val1: 100}}
+	b.SetBytes(int64(c.Size()))
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
 		_, _ = c.WriteTo(io.Discard)
@@ -126,3 +133,35 @@ func BenchmarkStandardBinaryWrite(b *testing.B) {
 		_ = binary.Size(payload)
 	}
 }
+
+type PODBenchmarkPayload struct {
+	Val1 uint64
+	Val2 uint64
+	Val3 uint64
+	ID   uint32
+	Flag [4]byte
+}
+
+func BenchmarkPODMarshalTo(b *testing.B) {
+	c, _ := NewPOD(PODBenchmarkPayload{ID: 1, Val1: 100})
+	c.WithByteOrder(binary.NativeEndian)
+	buf := make([]byte, c.Size())
+	b.SetBytes(int64(c.Size()))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, _ = c.MarshalTo(buf)
+	}
+}
+
+func BenchmarkPODUnmarshalBinary(b *testing.B) {
+	c, _ := NewPOD(PODBenchmarkPayload{ID: 1, Val1: 100})
+	c.WithByteOrder(binary.NativeEndian)
+	data, _ := c.MarshalBinary()
+	c2 := &POD[PODBenchmarkPayload]{}
+	c2.WithByteOrder(binary.NativeEndian)
+	b.SetBytes(int64(c.Size()))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = c2.UnmarshalBinary(data)
+	}
+}
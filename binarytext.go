@@ -0,0 +1,104 @@
+package codec
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"io"
+)
+
+// TextWriter is a Writer whose bytes are written to another Writer as
+// base64 or hex text, for binary structures embedded in text transports.
+// Its Count is the number of binary bytes written; Encoded is the number of
+// text bytes written to the destination.
+type TextWriter struct {
+	*Writer
+	ew    io.WriteCloser
+	dst   *Writer
+	start int64
+	end   int64 // destination count at Close, -1 while open
+}
+
+// NewBase64Writer starts a section of dst holding base64 text in enc, or in
+// base64.StdEncoding if enc is nil. Close ends the section, writing any
+// partial block and padding, after which writing continues on dst.
+func NewBase64Writer(dst *Writer, enc *base64.Encoding) (*TextWriter, error) {
+	if enc == nil {
+		enc = base64.StdEncoding
+	}
+	return newTextWriter(dst, base64.NewEncoder(enc, dst))
+}
+
+// NewHexWriter starts a section of dst holding lowercase hex text.
+func NewHexWriter(dst *Writer) (*TextWriter, error) {
+	return newTextWriter(dst, nopWriteCloser{hex.NewEncoder(dst)})
+}
+
+func newTextWriter(dst *Writer, ew io.WriteCloser) (*TextWriter, error) {
+	w, err := NewWriterSize(ew, BUFFER_SIZE)
+	if err != nil {
+		return nil, err
+	}
+	return &TextWriter{Writer: w.WithByteOrder(dst.order), ew: ew, dst: dst, start: dst.Count(), end: -1}, nil
+}
+
+// Encoded returns the number of text bytes written to the destination so
+// far, or the size of the whole section once Close returns.
+func (tw *TextWriter) Encoded() int64 {
+	if tw.end >= 0 {
+		return tw.end - tw.start
+	}
+	return tw.dst.Count() - tw.start
+}
+
+// Close flushes and terminates the text. It does not close the destination.
+func (tw *TextWriter) Close() error {
+	tw.Flush()
+	if tw.end < 0 {
+		tw.setError(tw.ew.Close())
+		tw.end = tw.dst.Count()
+	}
+	return tw.Err()
+}
+
+type nopWriteCloser struct{ io.Writer }
+
+func (nopWriteCloser) Close() error { return nil }
+
+// TextReader is a Reader over base64 or hex text read from another Reader.
+// Its Count is the number of binary bytes read; Encoded is the number of
+// text bytes consumed from the source. Malformed text latches an error like
+// any other read failure.
+//
+// The decoders read ahead, so the text must run to the end of the source;
+// bound it with an io.SectionReader when more data follows.
+type TextReader struct {
+	*Reader
+	src   *Reader
+	start int64
+}
+
+// NewBase64Reader starts reading base64 text in enc, or in
+// base64.StdEncoding if enc is nil, from src. Newlines in the text are ignored.
+func NewBase64Reader(src *Reader, enc *base64.Encoding) (*TextReader, error) {
+	if enc == nil {
+		enc = base64.StdEncoding
+	}
+	return newTextReader(src, base64.NewDecoder(enc, src))
+}
+
+// NewHexReader starts reading hex text, in either case, from src.
+func NewHexReader(src *Reader) (*TextReader, error) {
+	return newTextReader(src, hex.NewDecoder(src))
+}
+
+func newTextReader(src *Reader, dr io.Reader) (*TextReader, error) {
+	start := src.Count()
+	r, err := NewReaderSize(dr, BUFFER_SIZE)
+	if err != nil {
+		return nil, err
+	}
+	return &TextReader{Reader: r.WithByteOrder(src.order), src: src, start: start}, nil
+}
+
+// Encoded returns the number of text bytes consumed from the source.
+func (tr *TextReader) Encoded() int64 { return tr.src.Count() - tr.start }
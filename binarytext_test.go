@@ -0,0 +1,67 @@
+//go:build test
+
+package codec
+
+import (
+	"bytes"
+	"encoding/base64"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTextSection(t *testing.T) {
+	var buf bytes.Buffer
+	w, _ := NewWriter(&buf)
+	w.WriteBytes([]byte("data="))
+	tw, err := NewBase64Writer(w, base64.URLEncoding)
+	require.NoError(t, err)
+	tw.WriteUint32(0xCAFEBABE)
+	tw.WriteUint8(0xFF)
+	require.NoError(t, tw.Close())
+	assert.EqualValues(t, 5, tw.Count())
+	assert.EqualValues(t, 8, tw.Encoded())
+	require.NoError(t, w.Flush())
+	assert.Equal(t, "data=yv66vv8=", buf.String())
+
+	r, _ := NewReader(bytes.NewReader(buf.Bytes()[5:]))
+	tr, err := NewBase64Reader(r, base64.URLEncoding)
+	require.NoError(t, err)
+	var magic uint32
+	var b uint8
+	tr.ReadUint32(&magic)
+	tr.ReadUint8(&b)
+	require.NoError(t, tr.Err())
+	assert.EqualValues(t, 0xCAFEBABE, magic)
+	assert.EqualValues(t, 0xFF, b)
+	assert.EqualValues(t, 5, tr.Count())
+	assert.EqualValues(t, 8, tr.Encoded())
+	tr.ReadUint8(&b)
+	assert.True(t, tr.IsEOF())
+
+	buf.Reset()
+	w, _ = NewWriter(&buf)
+	hw, err := NewHexWriter(w)
+	require.NoError(t, err)
+	hw.WithByteOrder(LE).WriteUint16(0xBEEF)
+	require.NoError(t, hw.Close())
+	require.NoError(t, w.Flush())
+	assert.Equal(t, "efbe", buf.String())
+	assert.EqualValues(t, 4, hw.Encoded())
+
+	r, _ = NewReader(bytes.NewReader([]byte("EFBE")))
+	hr, _ := NewHexReader(r)
+	var v uint16
+	hr.WithByteOrder(LE).ReadUint16(&v)
+	require.NoError(t, hr.Err())
+	assert.EqualValues(t, 0xBEEF, v)
+
+	// Malformed text latches.
+	r, _ = NewReader(bytes.NewReader([]byte("zz00")))
+	hr, _ = NewHexReader(r)
+	hr.ReadUint16(&v)
+	assert.Error(t, hr.Err())
+	hr.ReadUint8(&b)
+	assert.Error(t, hr.Err())
+}
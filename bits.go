@@ -0,0 +1,186 @@
+package codec
+
+import "fmt"
+
+// BitOrder selects how bits are packed into bytes by BitReader and BitWriter.
+type BitOrder int
+
+const (
+	// MSBFirst packs fields starting at the most significant bit of each byte,
+	// as used by MPEG headers and most network protocols.
+	MSBFirst BitOrder = iota
+	// LSBFirst packs fields starting at the least significant bit of each byte,
+	// as used by DEFLATE.
+	LSBFirst
+)
+
+// BitReader reads sub-byte fields from a Reader. It shares the error latching
+// of the underlying Reader: after the first error all reads return zero.
+type BitReader struct {
+	r     *Reader
+	order BitOrder
+	cur   byte  // unconsumed bits of the current byte
+	nbits int   // number of unconsumed bits in cur
+	pos   int64 // total bits consumed
+}
+
+// NewBitReader creates a BitReader over r using the given bit order.
+func NewBitReader(r *Reader, order BitOrder) *BitReader {
+	return &BitReader{r: r, order: order}
+}
+
+// ReadBits reads an n-bit unsigned field, where 0 <= n <= 64.
+func (b *BitReader) ReadBits(n int) uint64 {
+	if b.r.err != nil {
+		return 0
+	}
+	if n < 0 || n > 64 {
+		b.r.setError(fmt.Errorf("%w: %d", ErrInvalidBitCount, n))
+		return 0
+	}
+
+	var v uint64
+	for got := 0; got < n; {
+		if b.nbits == 0 {
+			c, err := b.r.ReadByte()
+			if err != nil {
+				return 0
+			}
+			b.cur, b.nbits = c, 8
+		}
+		k := min(n-got, b.nbits)
+		mask := byte(1<<k - 1)
+		if b.order == MSBFirst {
+			v = v<<k | uint64(b.cur>>(b.nbits-k)&mask)
+		} else {
+			v |= uint64(b.cur&mask) << got
+			b.cur >>= k
+		}
+		b.nbits -= k
+		got += k
+	}
+	b.pos += int64(n)
+	return v
+}
+
+// ReadBool reads a single bit.
+func (b *BitReader) ReadBool() bool {
+	return b.ReadBits(1) == 1
+}
+
+// ReadSignedBits reads an n-bit two's complement field and sign-extends it.
+func (b *BitReader) ReadSignedBits(n int) int64 {
+	v := b.ReadBits(n)
+	if n == 0 || n >= 64 {
+		return int64(v)
+	}
+	shift := 64 - n
+	return int64(v<<shift) >> shift
+}
+
+// AlignByte discards the unconsumed bits of the current byte, so the next read
+// starts on a byte boundary.
+func (b *BitReader) AlignByte() {
+	b.pos += int64(b.nbits)
+	b.cur, b.nbits = 0, 0
+}
+
+// IsAligned reports whether the reader is positioned on a byte boundary.
+func (b *BitReader) IsAligned() bool { return b.nbits == 0 }
+
+// BitPos returns the total number of bits consumed, including aligned-away bits.
+func (b *BitReader) BitPos() int64 { return b.pos }
+
+// Err returns the first error encountered by the underlying Reader.
+func (b *BitReader) Err() error { return b.r.err }
+
+// BitWriter writes sub-byte fields to a Writer, flushing each byte as soon as
+// it is complete. Pending bits must be completed with AlignByte before writing
+// byte-oriented data to the underlying Writer again.
+type BitWriter struct {
+	w     *Writer
+	order BitOrder
+	fill  bool  // bit value used by AlignByte for padding
+	cur   byte  // partially filled byte
+	nbits int   // number of bits filled in cur
+	pos   int64 // total bits written
+}
+
+// NewBitWriter creates a BitWriter over w using the given bit order.
+func NewBitWriter(w *Writer, order BitOrder) *BitWriter {
+	return &BitWriter{w: w, order: order}
+}
+
+// WithFill sets the bit value AlignByte pads with and returns the configured
+// BitWriter for chaining. The default fill bit is zero.
+func (b *BitWriter) WithFill(one bool) *BitWriter {
+	b.fill = one
+	return b
+}
+
+// WriteBits writes the low n bits of v, where 0 <= n <= 64.
+func (b *BitWriter) WriteBits(v uint64, n int) {
+	if b.w.err != nil {
+		return
+	}
+	if n < 0 || n > 64 {
+		b.w.setError(fmt.Errorf("%w: %d", ErrInvalidBitCount, n))
+		return
+	}
+
+	for done := 0; done < n; {
+		k := min(n-done, 8-b.nbits)
+		mask := uint64(1)<<k - 1
+		if b.order == MSBFirst {
+			b.cur |= byte(v>>(n-done-k)&mask) << (8 - b.nbits - k)
+		} else {
+			b.cur |= byte(v>>done&mask) << b.nbits
+		}
+		b.nbits += k
+		done += k
+		if b.nbits == 8 {
+			if b.w.WriteByte(b.cur) != nil {
+				return
+			}
+			b.cur, b.nbits = 0, 0
+		}
+	}
+	b.pos += int64(n)
+}
+
+// WriteBool writes a single bit.
+func (b *BitWriter) WriteBool(v bool) {
+	if v {
+		b.WriteBits(1, 1)
+	} else {
+		b.WriteBits(0, 1)
+	}
+}
+
+// WriteSignedBits writes v as an n-bit two's complement field.
+func (b *BitWriter) WriteSignedBits(v int64, n int) {
+	b.WriteBits(uint64(v), n)
+}
+
+// AlignByte pads the current byte with the fill bit and writes it,
+// so the next write starts on a byte boundary.
+func (b *BitWriter) AlignByte() {
+	if b.nbits == 0 {
+		return
+	}
+	pad := 8 - b.nbits
+	if b.fill {
+		b.WriteBits(1<<pad-1, pad)
+	} else {
+		b.WriteBits(0, pad)
+	}
+}
+
+// IsAligned reports whether the writer is positioned on a byte boundary.
+func (b *BitWriter) IsAligned() bool { return b.nbits == 0 }
+
+// BitPos returns the total number of bits written, including padding.
+func (b *BitWriter) BitPos() int64 { return b.pos }
+
+// Err returns the first error encountered by the underlying Writer.
+func (b *BitWriter) Err() error { return b.w.err }
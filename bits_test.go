@@ -0,0 +1,99 @@
+//go:build test
+
+package codec
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBitReader(t *testing.T) {
+	data := []byte{0xB5, 0xF0, 0xAB}
+
+	r, _ := NewReader(bytes.NewReader(data))
+	br := NewBitReader(r, MSBFirst)
+	assert.EqualValues(t, 0x5, br.ReadBits(3)) // 101
+	assert.True(t, br.ReadBool())              // 1
+	assert.EqualValues(t, 0x5, br.ReadBits(4)) // 0101
+	assert.True(t, br.IsAligned())
+	assert.EqualValues(t, -1, br.ReadSignedBits(4)) // 1111
+	assert.False(t, br.IsAligned())
+	br.AlignByte()
+	assert.True(t, br.IsAligned())
+	assert.EqualValues(t, 16, br.BitPos())
+	assert.EqualValues(t, 0xAB, br.ReadBits(8))
+	require.NoError(t, br.Err())
+
+	r, _ = NewReader(bytes.NewReader(data))
+	br = NewBitReader(r, LSBFirst)
+	assert.EqualValues(t, 0x5, br.ReadBits(3))   // low bits of 0xB5
+	assert.EqualValues(t, 0x6, br.ReadBits(4))   // 110
+	assert.EqualValues(t, 0x1E1, br.ReadBits(9)) // top bit of 0xB5, then 0xF0
+	assert.EqualValues(t, 0xAB, br.ReadBits(8))
+
+	// Reads past the end latch the Reader's error.
+	assert.Zero(t, br.ReadBits(1))
+	assert.ErrorIs(t, br.Err(), io.EOF)
+
+	r, _ = NewReader(bytes.NewReader(data))
+	br = NewBitReader(r, MSBFirst)
+	br.ReadBits(65)
+	assert.ErrorIs(t, br.Err(), ErrInvalidBitCount)
+	assert.Zero(t, br.ReadBits(1))
+}
+
+func TestBitsRoundTrip(t *testing.T) {
+	for _, order := range []BitOrder{MSBFirst, LSBFirst} {
+		var buf bytes.Buffer
+		w, _ := NewWriter(&buf)
+		bw := NewBitWriter(w, order).WithFill(true)
+		bw.WriteBits(0x5, 3)
+		bw.WriteBool(true)
+		bw.WriteSignedBits(-3, 5)
+		bw.WriteBits(0xDEADBEEFCAFEF00D, 64)
+		bw.AlignByte()
+		assert.True(t, bw.IsAligned())
+		assert.EqualValues(t, 80, bw.BitPos())
+		_, err := w.Result()
+		require.NoError(t, err)
+		require.Equal(t, 10, buf.Len())
+
+		r, _ := NewReader(&buf)
+		br := NewBitReader(r, order)
+		assert.EqualValues(t, 0x5, br.ReadBits(3))
+		assert.True(t, br.ReadBool())
+		assert.EqualValues(t, -3, br.ReadSignedBits(5))
+		assert.EqualValues(t, uint64(0xDEADBEEFCAFEF00D), br.ReadBits(64))
+		assert.EqualValues(t, 0x7F, br.ReadBits(7), "padding uses the fill bit")
+		require.NoError(t, br.Err())
+	}
+}
+
+func TestBitsPacking(t *testing.T) {
+	var buf bytes.Buffer
+	w, _ := NewWriter(&buf)
+	bw := NewBitWriter(w, MSBFirst)
+	bw.WriteBits(0x1, 1)
+	bw.WriteBits(0x0, 3)
+	bw.WriteBits(0xF, 4)
+	_, _ = w.Result()
+	assert.Equal(t, []byte{0x8F}, buf.Bytes())
+
+	buf.Reset()
+	w, _ = NewWriter(&buf)
+	bw = NewBitWriter(w, LSBFirst)
+	bw.WriteBits(0x1, 1)
+	bw.WriteBits(0x0, 3)
+	bw.WriteBits(0xF, 4)
+	_, _ = w.Result()
+	assert.Equal(t, []byte{0xF1}, buf.Bytes())
+
+	r, _ := NewReader(bytes.NewReader([]byte{0x01}))
+	br := NewBitReader(r, MSBFirst)
+	br.ReadBits(65)
+	assert.ErrorIs(t, br.Err(), ErrInvalidBitCount)
+}
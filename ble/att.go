@@ -0,0 +1,366 @@
+package ble
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/oy3o/codec"
+)
+
+// L2CAP channel identifiers of the LE fixed channels.
+const (
+	CIDATT       uint16 = 0x0004
+	CIDSignaling uint16 = 0x0005
+	CIDSMP       uint16 = 0x0006
+)
+
+// L2CAP_HEADER_SIZE is the size of the L2CAP basic header: the length of
+// the payload and the channel identifier.
+const L2CAP_HEADER_SIZE = 4
+
+// Attribute protocol opcodes.
+const (
+	OpErrorResponse           uint8 = 0x01
+	OpExchangeMTURequest      uint8 = 0x02
+	OpExchangeMTUResponse     uint8 = 0x03
+	OpFindInformationRequest  uint8 = 0x04
+	OpFindInformationResponse uint8 = 0x05
+	OpReadByTypeRequest       uint8 = 0x08
+	OpReadByTypeResponse      uint8 = 0x09
+	OpReadRequest             uint8 = 0x0A
+	OpReadResponse            uint8 = 0x0B
+	OpReadBlobRequest         uint8 = 0x0C
+	OpReadBlobResponse        uint8 = 0x0D
+	OpReadByGroupTypeRequest  uint8 = 0x10
+	OpReadByGroupTypeResponse uint8 = 0x11
+	OpWriteRequest            uint8 = 0x12
+	OpWriteResponse           uint8 = 0x13
+	OpNotification            uint8 = 0x1B
+	OpIndication              uint8 = 0x1D
+	OpConfirmation            uint8 = 0x1E
+	OpWriteCommand            uint8 = 0x52
+	OpSignedWriteCommand      uint8 = 0xD2
+)
+
+// Bits of an attribute opcode: the method, the command flag of PDUs that
+// get no response, and the authentication signature flag of PDUs that end
+// with a 12-byte signature.
+const (
+	ATT_METHOD    uint8 = 0x3F
+	ATT_COMMAND   uint8 = 0x40
+	ATT_SIGNED    uint8 = 0x80
+	SIGNATURE_LEN       = 12
+)
+
+// DEFAULT_MTU is the ATT MTU of LE links before an MTU exchange.
+const DEFAULT_MTU = 23
+
+// ErrInvalidPDU indicates an attribute PDU shorter than its opcode
+// requires, or whose attribute data list does not divide into entries.
+var ErrInvalidPDU = errors.New("ble: invalid attribute PDU")
+
+// AppendL2CAP appends payload, framed by the L2CAP basic header for channel
+// cid, to dst.
+func AppendL2CAP(dst []byte, cid uint16, payload []byte) ([]byte, error) {
+	if len(payload) > 0xFFFF {
+		return nil, fmt.Errorf("%w: %d bytes of payload", codec.ErrLengthOverflow, len(payload))
+	}
+	dst = codec.LE.AppendUint16(dst, uint16(len(payload)))
+	dst = codec.LE.AppendUint16(dst, cid)
+	return append(dst, payload...), nil
+}
+
+// ParseL2CAP returns the channel identifier and payload of an L2CAP basic
+// frame, as reassembled from ACL data packets. The payload aliases b.
+func ParseL2CAP(b []byte) (cid uint16, payload []byte, err error) {
+	if len(b) < L2CAP_HEADER_SIZE || int(codec.LE.Uint16(b)) != len(b)-L2CAP_HEADER_SIZE {
+		return 0, nil, fmt.Errorf("%w: L2CAP frame of %d bytes", ErrInvalidPacket, len(b))
+	}
+	return codec.LE.Uint16(b[2:]), b[L2CAP_HEADER_SIZE:], nil
+}
+
+// UUID is an attribute type, in its 16-bit or 128-bit form, as it is sent:
+// little-endian.
+type UUID []byte
+
+// baseUUID is the Bluetooth base UUID 00000000-0000-1000-8000-00805F9B34FB,
+// little-endian; 16-bit UUIDs replace its bytes 12 and 13.
+var baseUUID = [16]byte{0xFB, 0x34, 0x9B, 0x5F, 0x80, 0x00, 0x00, 0x80, 0x00, 0x10, 0x00, 0x00}
+
+// UUID16 returns the 16-bit UUID u.
+func UUID16(u uint16) UUID { return codec.LE.AppendUint16(nil, u) }
+
+// Well-known attribute types.
+var (
+	UUIDPrimaryService   = UUID16(0x2800)
+	UUIDSecondaryService = UUID16(0x2801)
+	UUIDInclude          = UUID16(0x2802)
+	UUIDCharacteristic   = UUID16(0x2803)
+	UUIDClientConfig     = UUID16(0x2902)
+)
+
+// Long returns the 128-bit form of u.
+func (u UUID) Long() UUID {
+	if len(u) != 2 {
+		return u
+	}
+	long := baseUUID
+	copy(long[12:], u)
+	return long[:]
+}
+
+// Equal reports whether u and v are the same UUID, in either form.
+func (u UUID) Equal(v UUID) bool { return string(u.Long()) == string(v.Long()) }
+
+// String returns u in its usual big-endian notation.
+func (u UUID) String() string {
+	if len(u) == 2 {
+		return fmt.Sprintf("%04x", codec.LE.Uint16(u))
+	}
+	if len(u) != 16 {
+		return fmt.Sprintf("invalid UUID %x", []byte(u))
+	}
+	var b [16]byte
+	for i := range b {
+		b[i] = u[15-i]
+	}
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// PDU is an attribute protocol PDU: an opcode and its parameters.
+type PDU []byte
+
+// ParsePDU checks that b holds at least an opcode and, for signed PDUs, the
+// signature. The PDU aliases b.
+func ParsePDU(b []byte) (PDU, error) {
+	if len(b) == 0 || b[0]&ATT_SIGNED != 0 && len(b) < 1+SIGNATURE_LEN {
+		return nil, fmt.Errorf("%w: %d bytes", ErrInvalidPDU, len(b))
+	}
+	return PDU(b), nil
+}
+
+// Opcode returns the opcode of p.
+func (p PDU) Opcode() uint8 { return p[0] }
+
+// Method returns the opcode of p without its command and signature flags.
+func (p PDU) Method() uint8 { return p[0] & ATT_METHOD }
+
+// Command reports whether p is a command, which gets no response.
+func (p PDU) Command() bool { return p[0]&ATT_COMMAND != 0 }
+
+// Signed reports whether p ends with an authentication signature.
+func (p PDU) Signed() bool { return p[0]&ATT_SIGNED != 0 }
+
+// Params returns the parameters of p, without the signature of signed PDUs.
+func (p PDU) Params() []byte {
+	if p.Signed() {
+		return p[1 : len(p)-SIGNATURE_LEN]
+	}
+	return p[1:]
+}
+
+// Signature returns the signature of a signed PDU, or nil.
+func (p PDU) Signature() []byte {
+	if !p.Signed() {
+		return nil
+	}
+	return p[len(p)-SIGNATURE_LEN:]
+}
+
+// params returns the parameters of p if its opcode is op and they hold at
+// least n bytes.
+func (p PDU) params(op uint8, n int) ([]byte, error) {
+	if len(p) == 0 || p[0] != op || len(p.Params()) < n {
+		return nil, fmt.Errorf("%w: want opcode 0x%02x with %d bytes of parameters", ErrInvalidPDU, op, n)
+	}
+	return p.Params(), nil
+}
+
+// Handles returns the starting and ending handles of a Find Information,
+// Read By Type or Read By Group Type request, and the attribute type the
+// latter two carry.
+func (p PDU) Handles() (start, end uint16, typ UUID, err error) {
+	b, err := p.params(p.Opcode(), 4)
+	if err != nil {
+		return 0, 0, nil, err
+	}
+	switch p.Opcode() {
+	case OpFindInformationRequest:
+		if len(b) != 4 {
+			return 0, 0, nil, fmt.Errorf("%w: find information request of %d bytes", ErrInvalidPDU, len(p))
+		}
+	case OpReadByTypeRequest, OpReadByGroupTypeRequest:
+		if len(b) != 6 && len(b) != 20 {
+			return 0, 0, nil, fmt.Errorf("%w: read by type request of %d bytes", ErrInvalidPDU, len(p))
+		}
+		typ = UUID(b[4:])
+	default:
+		return 0, 0, nil, fmt.Errorf("%w: opcode 0x%02x carries no handle range", ErrInvalidPDU, p.Opcode())
+	}
+	return codec.LE.Uint16(b), codec.LE.Uint16(b[2:]), typ, nil
+}
+
+// Handle returns the attribute handle leading the parameters of Read, Read
+// Blob, Write, Notification and Indication PDUs, and the value following
+// it; for Read Blob requests, the value is the 2-byte offset.
+func (p PDU) Handle() (handle uint16, value []byte, err error) {
+	switch p.Method() {
+	case OpReadRequest, OpReadBlobRequest, OpWriteRequest, OpNotification, OpIndication:
+	default:
+		return 0, nil, fmt.Errorf("%w: opcode 0x%02x carries no handle", ErrInvalidPDU, p.Opcode())
+	}
+	b, err := p.params(p.Opcode(), 2)
+	if err != nil {
+		return 0, nil, err
+	}
+	return codec.LE.Uint16(b), b[2:], nil
+}
+
+// MTU returns the MTU of an Exchange MTU request or response.
+func (p PDU) MTU() (uint16, error) {
+	if len(p) != 3 || p[0] != OpExchangeMTURequest && p[0] != OpExchangeMTUResponse {
+		return 0, fmt.Errorf("%w: not an exchange MTU PDU", ErrInvalidPDU)
+	}
+	return codec.LE.Uint16(p[1:]), nil
+}
+
+// AttError is the content of an Error Response: the opcode of the request
+// in error, the handle it concerns and the error code.
+type AttError struct {
+	Request uint8
+	Handle  uint16
+	Code    uint8
+}
+
+func (e *AttError) Error() string {
+	return fmt.Sprintf("ble: attribute error 0x%02x on handle 0x%04x for opcode 0x%02x", e.Code, e.Handle, e.Request)
+}
+
+// Err returns the error of an Error Response.
+func (p PDU) Err() (*AttError, error) {
+	b, err := p.params(OpErrorResponse, 4)
+	if err != nil {
+		return nil, err
+	}
+	return &AttError{Request: b[0], Handle: codec.LE.Uint16(b[1:]), Code: b[3]}, nil
+}
+
+// Attribute is an entry of the attribute data list of a Find Information,
+// Read By Type or Read By Group Type response. End is only set by the
+// latter; Value is the attribute type of Find Information entries.
+type Attribute struct {
+	Handle uint16
+	End    uint16
+	Value  []byte
+}
+
+// Attributes returns the attribute data list of a Find Information, Read By
+// Type or Read By Group Type response. Values alias p.
+func (p PDU) Attributes() ([]Attribute, error) {
+	b, err := p.params(p.Opcode(), 2)
+	if err != nil {
+		return nil, err
+	}
+	var size, head int
+	switch p.Opcode() {
+	case OpFindInformationResponse:
+		switch b[0] {
+		case 1:
+			size = 4
+		case 2:
+			size = 18
+		default:
+			return nil, fmt.Errorf("%w: find information format %d", ErrInvalidPDU, b[0])
+		}
+		head = 2
+	case OpReadByTypeResponse:
+		size, head = int(b[0]), 2
+	case OpReadByGroupTypeResponse:
+		size, head = int(b[0]), 4
+	default:
+		return nil, fmt.Errorf("%w: opcode 0x%02x carries no attribute list", ErrInvalidPDU, p.Opcode())
+	}
+	list := b[1:]
+	if size < head || len(list)%size != 0 {
+		return nil, fmt.Errorf("%w: %d bytes of entries of %d bytes", ErrInvalidPDU, len(list), size)
+	}
+	attrs := make([]Attribute, 0, len(list)/size)
+	for ; len(list) > 0; list = list[size:] {
+		a := Attribute{Handle: codec.LE.Uint16(list), Value: list[head:size]}
+		if head == 4 {
+			a.End = codec.LE.Uint16(list[2:])
+		}
+		attrs = append(attrs, a)
+	}
+	return attrs, nil
+}
+
+// AppendErrorResponse appends an Error Response to dst.
+func AppendErrorResponse(dst []byte, e *AttError) []byte {
+	dst = append(dst, OpErrorResponse, e.Request)
+	return append(codec.LE.AppendUint16(dst, e.Handle), e.Code)
+}
+
+// AppendExchangeMTU appends an Exchange MTU request or response, as op
+// says, to dst.
+func AppendExchangeMTU(dst []byte, op uint8, mtu uint16) []byte {
+	return codec.LE.AppendUint16(append(dst, op), mtu)
+}
+
+// AppendHandles appends a Find Information, Read By Type or Read By Group
+// Type request, as op says, to dst; typ is nil for the first.
+func AppendHandles(dst []byte, op uint8, start, end uint16, typ UUID) []byte {
+	dst = codec.LE.AppendUint16(append(dst, op), start)
+	return append(codec.LE.AppendUint16(dst, end), typ...)
+}
+
+// AppendHandle appends a PDU made of an attribute handle and a value, such
+// as a Read request, a Write request or command, a Notification or an
+// Indication, as op says, to dst.
+func AppendHandle(dst []byte, op uint8, handle uint16, value []byte) []byte {
+	return append(codec.LE.AppendUint16(append(dst, op), handle), value...)
+}
+
+// AppendAttributes appends a Find Information, Read By Type or Read By
+// Group Type response, as op says, to dst. All entries must have values of
+// the same length, as the response lists them with a single entry size.
+func AppendAttributes(dst []byte, op uint8, attrs []Attribute) ([]byte, error) {
+	if len(attrs) == 0 {
+		return nil, fmt.Errorf("%w: empty attribute list", ErrInvalidPDU)
+	}
+	n := len(attrs[0].Value)
+	head := 2
+	if op == OpReadByGroupTypeResponse {
+		head = 4
+	}
+	dst = append(dst, op)
+	switch op {
+	case OpFindInformationResponse:
+		switch n {
+		case 2:
+			dst = append(dst, 1)
+		case 16:
+			dst = append(dst, 2)
+		default:
+			return nil, fmt.Errorf("%w: attribute type of %d bytes", ErrInvalidPDU, n)
+		}
+	case OpReadByTypeResponse, OpReadByGroupTypeResponse:
+		if head+n > 0xFF {
+			return nil, fmt.Errorf("%w: attribute value of %d bytes", codec.ErrLengthOverflow, n)
+		}
+		dst = append(dst, byte(head+n))
+	default:
+		return nil, fmt.Errorf("%w: opcode 0x%02x carries no attribute list", ErrInvalidPDU, op)
+	}
+	for _, a := range attrs {
+		if len(a.Value) != n {
+			return nil, fmt.Errorf("%w: values of %d and %d bytes in one list", ErrInvalidPDU, n, len(a.Value))
+		}
+		dst = codec.LE.AppendUint16(dst, a.Handle)
+		if head == 4 {
+			dst = codec.LE.AppendUint16(dst, a.End)
+		}
+		dst = append(dst, a.Value...)
+	}
+	return dst, nil
+}
@@ -0,0 +1,147 @@
+//go:build test
+
+package ble
+
+import (
+	"bytes"
+	"encoding/hex"
+	"io"
+	"testing"
+
+	"github.com/oy3o/codec"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// trace is an H4 capture: HCI_Reset, its Command Complete event, and a
+// Read Request for handle 3 in an ACL packet on connection 0x040.
+var trace, _ = hex.DecodeString("" +
+	"01030c00" +
+	"040e0401030c00" +
+	"024020070003000400" + "0a0300")
+
+func TestPackets(t *testing.T) {
+	r, _ := codec.NewReader(bytes.NewReader(trace))
+	var packets []Packet
+	for p, err := range Packets(r) {
+		require.NoError(t, err)
+		packets = append(packets, p)
+	}
+	require.Len(t, packets, 3)
+
+	cmd := packets[0].(*Command)
+	assert.Equal(t, OpReset, cmd.Opcode)
+	assert.Equal(t, uint8(0x03), cmd.Opcode.OGF())
+	assert.Equal(t, uint16(0x0003), cmd.Opcode.OCF())
+	assert.Empty(t, cmd.Params)
+
+	credits, op, ret, err := packets[1].(*Event).CommandComplete()
+	require.NoError(t, err)
+	assert.Equal(t, uint8(1), credits)
+	assert.Equal(t, OpReset, op)
+	assert.Equal(t, []byte{0}, ret)
+
+	acl := packets[2].(*ACL)
+	assert.Equal(t, uint16(0x040), acl.Handle)
+	assert.Equal(t, FirstFlushable, acl.PacketBoundary)
+	assert.Equal(t, uint8(0), acl.Broadcast)
+
+	cid, payload, err := ParseL2CAP(acl.Data)
+	require.NoError(t, err)
+	assert.Equal(t, CIDATT, cid)
+	pdu, err := ParsePDU(payload)
+	require.NoError(t, err)
+	handle, value, err := pdu.Handle()
+	require.NoError(t, err)
+	assert.Equal(t, uint16(3), handle)
+	assert.Empty(t, value)
+
+	var buf bytes.Buffer
+	w, _ := codec.NewWriter(&buf)
+	for _, p := range packets {
+		require.NoError(t, WritePacket(w, p))
+	}
+	require.NoError(t, w.Flush())
+	assert.Equal(t, trace, buf.Bytes())
+}
+
+func TestReadPacketErrors(t *testing.T) {
+	r, _ := codec.NewReader(bytes.NewReader(trace[:6]))
+	_, err := ReadPacket(r)
+	require.NoError(t, err)
+	_, err = ReadPacket(r)
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+
+	r, _ = codec.NewReader(bytes.NewReader([]byte{0x09, 0x00}))
+	_, err = ReadPacket(r)
+	assert.ErrorIs(t, err, ErrUnknownPacket)
+
+	_, err = ParsePacket(PacketEvent, []byte{0x0e, 0x04, 0x01})
+	assert.ErrorIs(t, err, ErrInvalidPacket)
+}
+
+func TestACLFlags(t *testing.T) {
+	b, err := AppendPacket(nil, &ACL{Handle: MAX_HANDLE, PacketBoundary: Continuing, Broadcast: 1, Data: []byte{0xAA}})
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0xFF, 0x5E, 0x01, 0x00, 0xAA}, b)
+	p, err := ParsePacket(PacketACL, b)
+	require.NoError(t, err)
+	acl := p.(*ACL)
+	assert.Equal(t, uint16(MAX_HANDLE), acl.Handle)
+	assert.Equal(t, Continuing, acl.PacketBoundary)
+	assert.Equal(t, uint8(1), acl.Broadcast)
+
+	_, err = AppendPacket(nil, &ACL{Handle: 0x1000})
+	assert.ErrorIs(t, err, ErrInvalidPacket)
+}
+
+func TestUUID(t *testing.T) {
+	assert.Equal(t, "2800", UUIDPrimaryService.String())
+	assert.Equal(t, "00002800-0000-1000-8000-00805f9b34fb", UUIDPrimaryService.Long().String())
+	assert.True(t, UUIDPrimaryService.Equal(UUIDPrimaryService.Long()))
+	assert.False(t, UUIDPrimaryService.Equal(UUIDCharacteristic))
+}
+
+func TestPDU(t *testing.T) {
+	mtu, err := PDU(AppendExchangeMTU(nil, OpExchangeMTURequest, 247)).MTU()
+	require.NoError(t, err)
+	assert.Equal(t, uint16(247), mtu)
+
+	start, end, typ, err := PDU(AppendHandles(nil, OpReadByGroupTypeRequest, 1, 0xFFFF, UUIDPrimaryService)).Handles()
+	require.NoError(t, err)
+	assert.Equal(t, uint16(1), start)
+	assert.Equal(t, uint16(0xFFFF), end)
+	assert.True(t, typ.Equal(UUIDPrimaryService))
+
+	services := []Attribute{
+		{Handle: 1, End: 5, Value: UUID16(0x1800)},
+		{Handle: 6, End: 9, Value: UUID16(0x180F)},
+	}
+	b, err := AppendAttributes(nil, OpReadByGroupTypeResponse, services)
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0x11, 6, 1, 0, 5, 0, 0x00, 0x18, 6, 0, 9, 0, 0x0F, 0x18}, b)
+	attrs, err := PDU(b).Attributes()
+	require.NoError(t, err)
+	assert.Equal(t, services, attrs)
+
+	_, err = AppendAttributes(nil, OpReadByTypeResponse, []Attribute{{Value: []byte{1}}, {Value: []byte{1, 2}}})
+	assert.ErrorIs(t, err, ErrInvalidPDU)
+
+	cmd := PDU(AppendHandle(nil, OpWriteCommand, 0x0010, []byte{1, 0}))
+	assert.True(t, cmd.Command())
+	assert.Equal(t, OpWriteRequest, cmd.Method())
+	handle, value, err := cmd.Handle()
+	require.NoError(t, err)
+	assert.Equal(t, uint16(0x10), handle)
+	assert.Equal(t, []byte{1, 0}, value)
+
+	signed, err := ParsePDU(append(AppendHandle(nil, OpSignedWriteCommand, 0x10, []byte{7}), make([]byte, SIGNATURE_LEN)...))
+	require.NoError(t, err)
+	assert.True(t, signed.Signed())
+	assert.Equal(t, []byte{0x10, 0, 7}, signed.Params())
+	assert.Len(t, signed.Signature(), SIGNATURE_LEN)
+
+	e, err := PDU(AppendErrorResponse(nil, &AttError{Request: OpReadRequest, Handle: 3, Code: 0x02})).Err()
+	require.NoError(t, err)
+	assert.Equal(t, &AttError{Request: OpReadRequest, Handle: 3, Code: 0x02}, e)
+}
@@ -0,0 +1,282 @@
+// Package ble encodes and decodes the packets Bluetooth Low Energy hosts
+// exchange with their controllers and peers: HCI command, event and ACL
+// data packets, framed by their H4 packet type on UART transports, the
+// L2CAP basic header inside ACL data, and the attribute protocol PDUs of
+// GATT. Everything in Bluetooth is little-endian, whatever codec.Order is.
+package ble
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+
+	"github.com/oy3o/codec"
+)
+
+// H4 packet types, preceding each packet on UART transports.
+const (
+	PacketCommand uint8 = 0x01
+	PacketACL     uint8 = 0x02
+	PacketSCO     uint8 = 0x03
+	PacketEvent   uint8 = 0x04
+	PacketISO     uint8 = 0x05
+)
+
+// Event codes.
+const (
+	EventDisconnectionComplete    uint8 = 0x05
+	EventEncryptionChange         uint8 = 0x08
+	EventCommandComplete          uint8 = 0x0E
+	EventCommandStatus            uint8 = 0x0F
+	EventHardwareError            uint8 = 0x10
+	EventNumberOfCompletedPackets uint8 = 0x13
+	EventLEMeta                   uint8 = 0x3E
+	EventVendor                   uint8 = 0xFF
+)
+
+// Packet boundary flags of ACL data packets.
+const (
+	FirstNonFlushable uint8 = 0b00
+	Continuing        uint8 = 0b01
+	FirstFlushable    uint8 = 0b10
+)
+
+const (
+	// MAX_HANDLE is the largest connection handle.
+	MAX_HANDLE = 0x0EFF
+	// MAX_PARAMS is the largest parameter length of a command or event.
+	MAX_PARAMS = 255
+)
+
+var (
+	// ErrInvalidPacket indicates a packet shorter than its header or its
+	// declared length, or with fields out of range.
+	ErrInvalidPacket = errors.New("ble: invalid packet")
+
+	// ErrUnknownPacket indicates an H4 packet type this package does not
+	// decode.
+	ErrUnknownPacket = errors.New("ble: unknown packet type")
+)
+
+// Opcode is a command opcode: a 6-bit opcode group field (OGF) and a 10-bit
+// opcode command field (OCF).
+type Opcode uint16
+
+// NewOpcode returns the opcode of command ocf in group ogf.
+func NewOpcode(ogf uint8, ocf uint16) Opcode { return Opcode(uint16(ogf&0x3F)<<10 | ocf&0x3FF) }
+
+// OGF returns the opcode group field.
+func (o Opcode) OGF() uint8 { return uint8(o >> 10) }
+
+// OCF returns the opcode command field.
+func (o Opcode) OCF() uint16 { return uint16(o) & 0x3FF }
+
+func (o Opcode) String() string { return fmt.Sprintf("0x%02x|0x%04x", o.OGF(), o.OCF()) }
+
+// Common opcodes.
+var (
+	OpDisconnect             = NewOpcode(0x01, 0x0006)
+	OpReset                  = NewOpcode(0x03, 0x0003)
+	OpReadBDAddr             = NewOpcode(0x04, 0x0009)
+	OpLESetAdvertisingParams = NewOpcode(0x08, 0x0006)
+	OpLESetAdvertisingData   = NewOpcode(0x08, 0x0008)
+	OpLESetAdvertiseEnable   = NewOpcode(0x08, 0x000A)
+	OpLESetScanParams        = NewOpcode(0x08, 0x000B)
+	OpLESetScanEnable        = NewOpcode(0x08, 0x000C)
+	OpLECreateConnection     = NewOpcode(0x08, 0x000D)
+)
+
+// Packet is an HCI packet: a *Command, *Event or *ACL.
+type Packet interface {
+	// PacketType returns the H4 packet type.
+	PacketType() uint8
+}
+
+// Command is an HCI command packet, sent by the host.
+type Command struct {
+	Opcode Opcode
+	Params []byte
+}
+
+// Event is an HCI event packet, sent by the controller.
+type Event struct {
+	Code   uint8
+	Params []byte
+}
+
+// ACL is an HCI ACL data packet. Its header packs the 12-bit connection
+// handle with the 2-bit packet boundary and broadcast flags.
+type ACL struct {
+	Handle         uint16
+	PacketBoundary uint8
+	Broadcast      uint8
+	Data           []byte
+}
+
+func (*Command) PacketType() uint8 { return PacketCommand }
+func (*Event) PacketType() uint8   { return PacketEvent }
+func (*ACL) PacketType() uint8     { return PacketACL }
+
+// AppendPacket appends the encoding of p, without its H4 packet type, to
+// dst.
+func AppendPacket(dst []byte, p Packet) ([]byte, error) {
+	switch p := p.(type) {
+	case *Command:
+		if len(p.Params) > MAX_PARAMS {
+			return nil, fmt.Errorf("%w: %d bytes of parameters", codec.ErrLengthOverflow, len(p.Params))
+		}
+		dst = codec.LE.AppendUint16(dst, uint16(p.Opcode))
+		return append(append(dst, byte(len(p.Params))), p.Params...), nil
+	case *Event:
+		if len(p.Params) > MAX_PARAMS {
+			return nil, fmt.Errorf("%w: %d bytes of parameters", codec.ErrLengthOverflow, len(p.Params))
+		}
+		return append(append(dst, p.Code, byte(len(p.Params))), p.Params...), nil
+	case *ACL:
+		if p.Handle > MAX_HANDLE || p.PacketBoundary > 3 || p.Broadcast > 3 {
+			return nil, fmt.Errorf("%w: handle 0x%x, flags %d and %d", ErrInvalidPacket, p.Handle, p.PacketBoundary, p.Broadcast)
+		}
+		if len(p.Data) > 0xFFFF {
+			return nil, fmt.Errorf("%w: %d bytes of data", codec.ErrLengthOverflow, len(p.Data))
+		}
+		dst = codec.LE.AppendUint16(dst, p.Handle|uint16(p.PacketBoundary)<<12|uint16(p.Broadcast)<<14)
+		dst = codec.LE.AppendUint16(dst, uint16(len(p.Data)))
+		return append(dst, p.Data...), nil
+	}
+	return nil, fmt.Errorf("%w: %T", ErrUnknownPacket, p)
+}
+
+// headerSize returns the size of the header of packets of type typ, and
+// where their length is: a byte at offset 2 for commands, a byte at offset
+// 1 for events and a uint16 at offset 2 for ACL data.
+func headerSize(typ uint8) (int, error) {
+	switch typ {
+	case PacketCommand:
+		return 3, nil
+	case PacketEvent:
+		return 2, nil
+	case PacketACL:
+		return 4, nil
+	}
+	return 0, fmt.Errorf("%w: 0x%02x", ErrUnknownPacket, typ)
+}
+
+// payloadSize returns the length a packet header declares.
+func payloadSize(typ uint8, header []byte) int {
+	switch typ {
+	case PacketCommand:
+		return int(header[2])
+	case PacketEvent:
+		return int(header[1])
+	}
+	return int(codec.LE.Uint16(header[2:]))
+}
+
+// ParsePacket decodes a packet of type typ from b, as delivered by
+// transports that carry the type out of band, such as USB. The payload
+// aliases b.
+func ParsePacket(typ uint8, b []byte) (Packet, error) {
+	size, err := headerSize(typ)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) < size || len(b)-size != payloadSize(typ, b) {
+		return nil, fmt.Errorf("%w: %d bytes", ErrInvalidPacket, len(b))
+	}
+	return newPacket(typ, b[:size], b[size:]), nil
+}
+
+func newPacket(typ uint8, header, payload []byte) Packet {
+	switch typ {
+	case PacketCommand:
+		return &Command{Opcode: Opcode(codec.LE.Uint16(header)), Params: payload}
+	case PacketEvent:
+		return &Event{Code: header[0], Params: payload}
+	}
+	h := codec.LE.Uint16(header)
+	return &ACL{Handle: h & 0x0FFF, PacketBoundary: uint8(h>>12) & 3, Broadcast: uint8(h >> 14), Data: payload}
+}
+
+// ReadPacket reads a packet preceded by its H4 packet type. A Reader at the
+// end of the stream returns io.EOF.
+func ReadPacket(r *codec.Reader) (Packet, error) {
+	var typ [1]byte
+	r.ReadBytesTo(typ[:])
+	if err := r.Err(); err != nil {
+		return nil, err
+	}
+	size, err := headerSize(typ[0])
+	if err != nil {
+		return nil, err
+	}
+	var header [4]byte
+	r.ReadBytesTo(header[:size])
+	if err := r.Err(); err != nil {
+		return nil, unexpected(err)
+	}
+	payload := r.ReadBytes(payloadSize(typ[0], header[:size]))
+	if err := r.Err(); err != nil {
+		return nil, unexpected(err)
+	}
+	return newPacket(typ[0], header[:size], payload), nil
+}
+
+// WritePacket writes p preceded by its H4 packet type.
+func WritePacket(w *codec.Writer, p Packet) error {
+	b, err := AppendPacket([]byte{p.PacketType()}, p)
+	if err != nil {
+		return err
+	}
+	w.WriteBytes(b)
+	return w.Err()
+}
+
+// Packets iterates over the H4 packets of r up to the end of the stream,
+// stopping after the first error.
+func Packets(r *codec.Reader) iter.Seq2[Packet, error] {
+	return func(yield func(Packet, error) bool) {
+		for {
+			p, err := ReadPacket(r)
+			if err == io.EOF {
+				return
+			}
+			if !yield(p, err) || err != nil {
+				return
+			}
+		}
+	}
+}
+
+// CommandComplete returns the parameters of a Command Complete event: the
+// number of commands the host may send, the opcode of the completed command
+// and its return parameters, starting with the status.
+func (e *Event) CommandComplete() (credits uint8, op Opcode, ret []byte, err error) {
+	if e.Code != EventCommandComplete || len(e.Params) < 3 {
+		return 0, 0, nil, fmt.Errorf("%w: not a command complete event", ErrInvalidPacket)
+	}
+	return e.Params[0], Opcode(codec.LE.Uint16(e.Params[1:])), e.Params[3:], nil
+}
+
+// CommandStatus returns the parameters of a Command Status event.
+func (e *Event) CommandStatus() (status, credits uint8, op Opcode, err error) {
+	if e.Code != EventCommandStatus || len(e.Params) != 4 {
+		return 0, 0, 0, fmt.Errorf("%w: not a command status event", ErrInvalidPacket)
+	}
+	return e.Params[0], e.Params[1], Opcode(codec.LE.Uint16(e.Params[2:])), nil
+}
+
+// LEMeta returns the subevent code and parameters of an LE Meta event.
+func (e *Event) LEMeta() (subevent uint8, params []byte, err error) {
+	if e.Code != EventLEMeta || len(e.Params) < 1 {
+		return 0, nil, fmt.Errorf("%w: not an LE meta event", ErrInvalidPacket)
+	}
+	return e.Params[0], e.Params[1:], nil
+}
+
+func unexpected(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,146 @@
+package codec
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// Empty is a Codec that encodes to nothing. It stands in for absent payloads,
+// e.g. variants of a Union that carry no data.
+type Empty struct{}
+
+// Statically assert that Empty implements Codec.
+var _ Codec = Empty{}
+
+func (Empty) Size() int                                { return 0 }
+func (Empty) MarshalBinary() ([]byte, error)           { return []byte{}, nil }
+func (Empty) MarshalTo(p []byte) (int, error)          { return 0, nil }
+func (Empty) WriteTo(w io.Writer) (int64, error)       { return 0, nil }
+func (Empty) ReadFrom(r io.Reader) (int64, error)      { return 0, nil }
+func (Empty) MarshalAppend(dst []byte) ([]byte, error) { return dst, nil }
+
+// UnmarshalBinary accepts only zero padding, like every other codec.
+func (Empty) UnmarshalBinary(data []byte) error { return CheckBufferNotZeros(data) }
+
+// Padding is a Codec for n reserved bytes: it writes zeros and rejects
+// non-zero bytes on decode with ErrNonZeroPadding.
+//
+//	codec.EncodeAll(w, header, codec.Padding(4), body)
+type Padding int
+
+// Statically assert that Padding implements Codec.
+var _ Codec = Padding(0)
+
+func (p Padding) Size() int { return max(int(p), 0) }
+
+func (p Padding) MarshalTo(buf []byte) (int, error) {
+	n := p.Size()
+	if len(buf) < n {
+		return 0, io.ErrShortWrite
+	}
+	clear(buf[:n])
+	return n, nil
+}
+
+func (p Padding) WriteTo(w io.Writer) (int64, error) {
+	var total int64
+	for remaining := p.Size(); remaining > 0; {
+		n, err := w.Write(empty[:min(remaining, BUFFER_SIZE)])
+		total += int64(n)
+		if err != nil {
+			return total, err
+		}
+		remaining -= n
+	}
+	return total, nil
+}
+
+func (p Padding) ReadFrom(r io.Reader) (int64, error) {
+	var buf [512]byte
+	var total int64
+	for remaining := p.Size(); remaining > 0; {
+		n, err := io.ReadFull(r, buf[:min(remaining, len(buf))])
+		total += int64(n)
+		if err != nil {
+			return total, err
+		}
+		if i := bytes.IndexFunc(buf[:n], func(c rune) bool { return c != 0 }); i >= 0 {
+			return total, fmt.Errorf("%w: at byte %d of %d", ErrNonZeroPadding, total-int64(n)+int64(i), p.Size())
+		}
+		remaining -= n
+	}
+	return total, nil
+}
+
+func (p Padding) MarshalBinary() ([]byte, error) {
+	return make([]byte, p.Size()), nil
+}
+
+func (p Padding) UnmarshalBinary(data []byte) error {
+	return UnmarshalBinaryGeneric(p, data)
+}
+
+func (p Padding) MarshalAppend(dst []byte) ([]byte, error) {
+	return MarshalAppendGeneric(p, dst)
+}
+
+// MagicError reports a magic number mismatch. It matches ErrMagicMismatch
+// with errors.Is.
+type MagicError struct {
+	Want []byte // expected magic
+	Got  []byte // bytes found instead
+}
+
+func (e *MagicError) Error() string {
+	return fmt.Sprintf("%s: want % x, got % x", ErrMagicMismatch, e.Want, e.Got)
+}
+
+func (e *MagicError) Unwrap() error { return ErrMagicMismatch }
+
+// Magic is a Codec for constant bytes such as file signatures: it writes the
+// bytes and fails with a *MagicError if different bytes are decoded.
+//
+//	codec.DecodeAll(r, codec.Magic("\x89PNG\r\n\x1a\n"), &header)
+type Magic []byte
+
+// Statically assert that Magic implements Codec.
+var _ Codec = Magic(nil)
+
+func (m Magic) Size() int { return len(m) }
+
+func (m Magic) MarshalTo(p []byte) (int, error) {
+	if len(p) < len(m) {
+		return 0, io.ErrShortWrite
+	}
+	return copy(p, m), nil
+}
+
+func (m Magic) WriteTo(w io.Writer) (int64, error) {
+	n, err := w.Write(m)
+	return int64(n), err
+}
+
+func (m Magic) ReadFrom(r io.Reader) (int64, error) {
+	got := make([]byte, len(m))
+	n, err := io.ReadFull(r, got)
+	if err != nil {
+		return int64(n), err
+	}
+	if !bytes.Equal(got, m) {
+		return int64(n), &MagicError{Want: m, Got: got}
+	}
+	return int64(n), nil
+}
+
+func (m Magic) MarshalBinary() ([]byte, error) {
+	return bytes.Clone([]byte(m)), nil
+}
+
+func (m Magic) UnmarshalBinary(data []byte) error {
+	return UnmarshalBinaryGeneric(m, data)
+}
+
+func (m Magic) MarshalAppend(dst []byte) ([]byte, error) {
+	return append(dst, m...), nil
+}
@@ -3,24 +3,56 @@ package codec
 import (
 	"bytes"
 	"sync"
+	"unsafe"
 )
 
+// CACHE_LINE is the alignment of pooled buffers. 64 bytes covers cache lines,
+// SIMD loads and most DMA/O_DIRECT requirements for memory addresses.
+const CACHE_LINE = 64
+
+// AlignedBytes returns a zeroed slice of length and capacity n whose first byte
+// is aligned to align, which must be a power of two.
+func AlignedBytes(n, align int) []byte {
+	if align <= 1 {
+		return make([]byte, n)
+	}
+	if align&(align-1) != 0 {
+		panic("codec: AlignedBytes alignment must be a power of two")
+	}
+	b := make([]byte, n+align-1)
+	off := 0
+	if addr := uintptr(unsafe.Pointer(unsafe.SliceData(b))); addr&uintptr(align-1) != 0 {
+		off = align - int(addr&uintptr(align-1))
+	}
+	return b[off : off+n : off+n]
+}
+
+// IsAligned reports whether the first byte of b is aligned to align. Every
+// address is aligned to an align of 1 or less.
+func IsAligned(b []byte, align int) bool {
+	if align <= 1 {
+		return true
+	}
+	return uintptr(unsafe.Pointer(unsafe.SliceData(b)))%uintptr(align) == 0
+}
+
 // bytesBufPool reuses buffers for decoding variable-length data.
 // This reduces GC pressure by avoiding frequent allocations. We pool *bytes.Buffer
 // because they are easily reset and resized.
 var bytesBufPool = sync.Pool{
 	New: func() any {
 		// A 4KB default is chosen to avoid re-allocations for common packet sizes.
-		return bytes.NewBuffer(make([]byte, 0, 4096))
+		return bytes.NewBuffer(AlignedBytes(4096, CACHE_LINE)[:0])
 	},
 }
 
 const CHUNK_SIZE = 32 * 1024
 
 // We need a buffer to read chunks into. 32KB is a common default size used by io.Copy.
+// Chunks are cache-line aligned so they can be handed to SIMD or DMA consumers.
 var bufPool = sync.Pool{
 	New: func() interface{} {
-		b := make([]byte, CHUNK_SIZE)
+		b := AlignedBytes(CHUNK_SIZE, CACHE_LINE)
 		return &b
 	},
 }
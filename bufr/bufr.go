@@ -0,0 +1,268 @@
+// Package bufr reads and writes the section framing of BUFR messages, the
+// WMO format of weather observations: the indicator section, the
+// identification section, an optional local section, the data description
+// section listing the descriptors of the data, the bit-packed data section
+// and the "7777" end section. Expanding descriptors against the BUFR tables
+// is left to the caller, who walks the data section with Message.Bits.
+//
+// Editions 2 to 4 are supported. Readers and Writers must use big-endian
+// byte order, the default.
+package bufr
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+
+	"github.com/oy3o/codec"
+)
+
+const (
+	// SIGNATURE opens every message.
+	SIGNATURE = "BUFR"
+	// END closes every message.
+	END = "7777"
+	// EDITION is the edition written by default.
+	EDITION = 4
+	// INDICATOR_SIZE is the size of the indicator section.
+	INDICATOR_SIZE = 8
+	// MAX_SECTION_SIZE is the largest section a 3-byte length can hold.
+	MAX_SECTION_SIZE = 1<<24 - 1
+)
+
+var (
+	// ErrInvalidMessage indicates a message that does not start with the
+	// indicator section or whose sections do not add up to its length.
+	ErrInvalidMessage = errors.New("bufr: invalid message")
+
+	// ErrInvalidSection indicates a section whose length does not fit its
+	// contents or the message.
+	ErrInvalidSection = errors.New("bufr: invalid section")
+
+	// ErrUnsupportedEdition indicates a message of an edition before 2.
+	ErrUnsupportedEdition = errors.New("bufr: unsupported edition")
+)
+
+// Descriptor is an element, replication, operator or sequence descriptor,
+// packed as F (2 bits), X (6 bits) and Y (8 bits).
+type Descriptor uint16
+
+// NewDescriptor returns the descriptor FXXYYY.
+func NewDescriptor(f, x, y uint8) Descriptor {
+	return Descriptor(f&0x03)<<14 | Descriptor(x&0x3F)<<8 | Descriptor(y)
+}
+
+func (d Descriptor) F() uint8 { return uint8(d >> 14) }
+func (d Descriptor) X() uint8 { return uint8(d>>8) & 0x3F }
+func (d Descriptor) Y() uint8 { return uint8(d) }
+
+// String returns the descriptor in its usual FXXYYY form.
+func (d Descriptor) String() string {
+	return fmt.Sprintf("%d%02d%03d", d.F(), d.X(), d.Y())
+}
+
+// Message is a BUFR message.
+type Message struct {
+	Edition uint8
+
+	// Identification is the identification section after its length. The
+	// flag announcing the optional section is set by WriteMessage.
+	Identification []byte
+
+	// Local is the optional section after its length and reserved byte,
+	// nil if absent.
+	Local []byte
+
+	Subsets     uint16
+	Observed    bool // observed rather than other data
+	Compressed  bool
+	Descriptors []Descriptor
+
+	// Data is the data section after its length and reserved byte. Editions
+	// before 4 pad it to an even length.
+	Data []byte
+}
+
+// Bits returns a BitReader over the data section, for decoding the values
+// the descriptors describe.
+func (m *Message) Bits() *codec.BitReader {
+	r, _ := codec.NewReader(codec.NewBytesReader(m.Data))
+	return codec.NewBitReader(r, codec.MSBFirst)
+}
+
+// optionalFlag returns the offset in Identification of the byte whose top
+// bit announces the optional section.
+func optionalFlag(edition uint8) int {
+	if edition >= 4 {
+		return 6
+	}
+	return 4
+}
+
+func unexpected(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// readSection reads a section after its 3-byte length, requiring at least
+// min bytes, and subtracts it from left.
+func readSection(r *codec.Reader, left *int, min int) ([]byte, error) {
+	var size uint32
+	r.ReadUint24(&size)
+	if err := r.Err(); err != nil {
+		return nil, unexpected(err)
+	}
+	if int(size) < 3+min || int(size) > *left {
+		return nil, fmt.Errorf("%w: length %d with %d bytes left", ErrInvalidSection, size, *left)
+	}
+	b := r.ReadBytes(int(size) - 3)
+	if err := r.Err(); err != nil {
+		return nil, unexpected(err)
+	}
+	*left -= int(size)
+	return b, nil
+}
+
+// ReadMessage reads a message up to its end section. A Reader at the end of
+// the stream returns io.EOF. Files with data between messages can be
+// scanned for SIGNATURE with Reader.WithResync first.
+func ReadMessage(r *codec.Reader) (*Message, error) {
+	m := new(Message)
+	var sig [4]byte
+	var length uint32
+	r.ReadBytesTo(sig[:])
+	if err := r.Err(); err != nil {
+		return nil, err
+	}
+	r.ReadUint24(&length)
+	r.ReadUint8(&m.Edition)
+	if err := r.Err(); err != nil {
+		return nil, unexpected(err)
+	}
+	if string(sig[:]) != SIGNATURE {
+		return nil, fmt.Errorf("%w: signature %q", ErrInvalidMessage, sig)
+	}
+	if m.Edition < 2 {
+		return nil, fmt.Errorf("%w: %d", ErrUnsupportedEdition, m.Edition)
+	}
+
+	left := int(length) - INDICATOR_SIZE - len(END)
+	var err error
+	if m.Identification, err = readSection(r, &left, optionalFlag(m.Edition)+1); err != nil {
+		return nil, err
+	}
+	if m.Identification[optionalFlag(m.Edition)]&0x80 != 0 {
+		local, err := readSection(r, &left, 1)
+		if err != nil {
+			return nil, err
+		}
+		m.Local = local[1:]
+	}
+	desc, err := readSection(r, &left, 4)
+	if err != nil {
+		return nil, err
+	}
+	m.Subsets = codec.BE.Uint16(desc[1:])
+	m.Observed, m.Compressed = desc[3]&0x80 != 0, desc[3]&0x40 != 0
+	m.Descriptors = make([]Descriptor, (len(desc)-4)/2)
+	for i := range m.Descriptors {
+		m.Descriptors[i] = Descriptor(codec.BE.Uint16(desc[4+2*i:]))
+	}
+	data, err := readSection(r, &left, 1)
+	if err != nil {
+		return nil, err
+	}
+	m.Data = data[1:]
+
+	var end [4]byte
+	r.ReadBytesTo(end[:])
+	if err := r.Err(); err != nil {
+		return nil, unexpected(err)
+	}
+	if string(end[:]) != END {
+		return nil, fmt.Errorf("%w: end section %q", ErrInvalidMessage, end)
+	}
+	if left != 0 {
+		return nil, fmt.Errorf("%w: %d bytes unaccounted for", ErrInvalidMessage, left)
+	}
+	return m, nil
+}
+
+// WriteMessage writes a message, computing its length and the lengths of
+// its sections. Editions before 4 pad sections to an even length.
+func WriteMessage(w *codec.Writer, m *Message) error {
+	edition := m.Edition
+	if edition == 0 {
+		edition = EDITION
+	}
+	flag := optionalFlag(edition)
+	if len(m.Identification) <= flag {
+		return fmt.Errorf("%w: identification section of %d bytes", ErrInvalidSection, len(m.Identification))
+	}
+	ident := append([]byte(nil), m.Identification...)
+	ident[flag] &^= 0x80
+	if m.Local != nil {
+		ident[flag] |= 0x80
+	}
+	var flags uint8
+	if m.Observed {
+		flags |= 0x80
+	}
+	if m.Compressed {
+		flags |= 0x40
+	}
+	desc := []byte{0, byte(m.Subsets >> 8), byte(m.Subsets), flags}
+	for _, d := range m.Descriptors {
+		desc = append(desc, byte(d>>8), byte(d))
+	}
+
+	sections := [][]byte{ident, desc, append([]byte{0}, m.Data...)}
+	if m.Local != nil {
+		sections = [][]byte{ident, append([]byte{0}, m.Local...), desc, sections[2]}
+	}
+	length := INDICATOR_SIZE + len(END)
+	sizes := make([]int, len(sections))
+	for i, s := range sections {
+		sizes[i] = 3 + len(s)
+		if edition < 4 {
+			sizes[i] = codec.Roundup(sizes[i], 2)
+		}
+		if sizes[i] > MAX_SECTION_SIZE {
+			return fmt.Errorf("%w: section of %d bytes", codec.ErrLengthOverflow, sizes[i])
+		}
+		length += sizes[i]
+	}
+	if length > MAX_SECTION_SIZE {
+		return fmt.Errorf("%w: message of %d bytes", codec.ErrLengthOverflow, length)
+	}
+
+	w.WriteString(SIGNATURE)
+	w.WriteUint24(uint32(length))
+	w.WriteUint8(edition)
+	for i, s := range sections {
+		w.WriteUint24(uint32(sizes[i]))
+		w.WriteBytes(s)
+		w.WriteZeros(int64(sizes[i] - 3 - len(s)))
+	}
+	w.WriteString(END)
+	return w.Err()
+}
+
+// Messages iterates over the messages of r up to the end of the stream,
+// stopping after the first error.
+func Messages(r *codec.Reader) iter.Seq2[*Message, error] {
+	return func(yield func(*Message, error) bool) {
+		for {
+			m, err := ReadMessage(r)
+			if err == io.EOF {
+				return
+			}
+			if !yield(m, err) || err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,95 @@
+//go:build test
+
+package bufr
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/oy3o/codec"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMessages(t *testing.T) {
+	m := &Message{
+		Edition:        4,
+		Identification: make([]byte, 19),
+		Local:          []byte("local"),
+		Subsets:        2,
+		Observed:       true,
+		Descriptors:    []Descriptor{NewDescriptor(3, 1, 11), NewDescriptor(0, 12, 101)},
+		Data:           []byte{0xAB, 0xC0},
+	}
+	assert.Equal(t, "301011", m.Descriptors[0].String())
+	assert.Equal(t, "012101", m.Descriptors[1].String())
+
+	var buf bytes.Buffer
+	w, _ := codec.NewWriter(&buf)
+	require.NoError(t, WriteMessage(w, m))
+	require.NoError(t, w.Flush())
+	data := buf.Bytes()
+	assert.Equal(t, "BUFR\x00\x00\x3c\x04", string(data[:8]))
+	assert.Len(t, data, 0x3c)
+	// The optional section flag of section 1.
+	assert.Equal(t, byte(0x80), data[8+9])
+	assert.Equal(t, END, string(data[len(data)-4:]))
+
+	r, _ := codec.NewReader(bytes.NewReader(data))
+	got, err := ReadMessage(r)
+	require.NoError(t, err)
+	m.Identification[6] = 0x80
+	assert.Equal(t, m, got)
+	bits := got.Bits()
+	assert.Equal(t, uint64(0xABC), bits.ReadBits(12))
+	require.NoError(t, bits.Err())
+	_, err = ReadMessage(r)
+	assert.Equal(t, io.EOF, err)
+
+	// Edition 3 pads sections to an even length.
+	m3 := &Message{Edition: 3, Identification: make([]byte, 15), Descriptors: []Descriptor{NewDescriptor(0, 1, 1)}, Data: []byte{1, 2, 3}}
+	buf.Reset()
+	require.NoError(t, WriteMessage(w, m3))
+	require.NoError(t, w.Flush())
+	assert.Equal(t, 8+18+10+8+4, buf.Len())
+	r, _ = codec.NewReader(&buf)
+	var n int
+	for got, err := range Messages(r) {
+		require.NoError(t, err)
+		assert.Equal(t, []Descriptor{NewDescriptor(0, 1, 1)}, got.Descriptors)
+		assert.Equal(t, []byte{1, 2, 3, 0}, got.Data)
+		assert.Nil(t, got.Local)
+		n++
+	}
+	assert.Equal(t, 1, n)
+}
+
+func TestInvalid(t *testing.T) {
+	var buf bytes.Buffer
+	w, _ := codec.NewWriter(&buf)
+	require.NoError(t, WriteMessage(w, &Message{Identification: make([]byte, 19)}))
+	require.NoError(t, w.Flush())
+	valid := buf.Bytes()
+	assert.ErrorIs(t, WriteMessage(w, &Message{Identification: make([]byte, 6)}), ErrInvalidSection)
+
+	read := func(data []byte) error {
+		r, _ := codec.NewReader(bytes.NewReader(data))
+		_, err := ReadMessage(r)
+		return err
+	}
+	require.NoError(t, read(valid))
+	assert.ErrorIs(t, read(valid[:len(valid)-1]), io.ErrUnexpectedEOF)
+
+	corrupt := func(i int, b byte) []byte {
+		data := bytes.Clone(valid)
+		data[i] = b
+		return data
+	}
+	assert.ErrorIs(t, read(corrupt(0, 'X')), ErrInvalidMessage)
+	assert.ErrorIs(t, read(corrupt(7, 1)), ErrUnsupportedEdition)
+	assert.ErrorIs(t, read(corrupt(len(valid)-1, '8')), ErrInvalidMessage)
+	assert.ErrorIs(t, read(corrupt(6, byte(len(valid)+2))), ErrInvalidMessage)
+	// A section 1 too short to hold the optional section flag.
+	assert.ErrorIs(t, read(corrupt(10, 4)), ErrInvalidSection)
+}
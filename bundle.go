@@ -0,0 +1,276 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+)
+
+// BundleFormat configures the layout of an asset bundle: a table of contents
+// of (name, offset, size, flags) entries followed by a blob section. Offsets
+// are relative to the start of the bundle.
+//
+// The table of contents is Magic, a uint32 entry count, then per entry the
+// name with a NameSize-byte length prefix, offset and size of OffsetSize
+// bytes each, for compressed bundles the uncompressed size and a
+// CompressionID byte, and uint32 flags. All integers use Order.
+type BundleFormat struct {
+	Order      binary.ByteOrder
+	Magic      []byte
+	OffsetSize int  // width of offsets and sizes: 4 or 8
+	NameSize   int  // width of name length prefixes: 1, 2 or 4
+	Align      int  // blobs start at a multiple of Align bytes
+	Compressed bool // entries carry an uncompressed size and compression method
+}
+
+// BundleEntry is an entry of a bundle's table of contents.
+type BundleEntry struct {
+	Name        string
+	Offset      int64 // start of the blob relative to the bundle
+	Size        int64 // stored size of the blob
+	RawSize     int64 // size after decompression, equal to Size if stored as is
+	Flags       uint32
+	Compression CompressionID
+}
+
+func (f *BundleFormat) check() error {
+	switch {
+	case f.OffsetSize != 4 && f.OffsetSize != 8:
+		return fmt.Errorf("%w: bundle offset width %d", ErrInvalidBitCount, f.OffsetSize*8)
+	case f.NameSize != 1 && f.NameSize != 2 && f.NameSize != 4:
+		return fmt.Errorf("%w: bundle name length width %d", ErrInvalidBitCount, f.NameSize*8)
+	}
+	return nil
+}
+
+// Bundle reads blobs from a bundle by name.
+type Bundle struct {
+	Entries []BundleEntry
+	ra      io.ReaderAt
+	index   map[string]int
+}
+
+// OpenBundle reads the table of contents of a bundle stored at the start of ra.
+func OpenBundle(ra io.ReaderAt, f BundleFormat) (*Bundle, error) {
+	if err := f.check(); err != nil {
+		return nil, err
+	}
+	r, err := NewReaderSize(io.NewSectionReader(ra, 0, math.MaxInt64), BUFFER_SIZE)
+	if err != nil {
+		return nil, err
+	}
+	r.WithByteOrder(f.Order)
+	magic := r.ReadBytes(len(f.Magic))
+	if r.Err() == nil && !bytes.Equal(magic, f.Magic) {
+		return nil, fmt.Errorf("%w: bundle magic %x", ErrMagicMismatch, magic)
+	}
+	var count uint32
+	r.ReadUint32(&count)
+	if count > MAX_DECODE_SIZE/8 {
+		return nil, fmt.Errorf("%w: %d bundle entries", ErrLengthOverflow, count)
+	}
+	b := &Bundle{ra: ra, index: make(map[string]int, count)}
+	for i := 0; i < int(count) && r.Err() == nil; i++ {
+		var e BundleEntry
+		e.Name = string(r.ReadBytes(int(readUint(r, f.NameSize))))
+		e.Offset = int64(readUint(r, f.OffsetSize))
+		e.Size = int64(readUint(r, f.OffsetSize))
+		e.RawSize = e.Size
+		if f.Compressed {
+			e.RawSize = int64(readUint(r, f.OffsetSize))
+			var id uint8
+			r.ReadUint8(&id)
+			e.Compression = CompressionID(id)
+		}
+		r.ReadUint32(&e.Flags)
+		if e.Offset < 0 || e.Size < 0 || e.RawSize < 0 {
+			return nil, fmt.Errorf("%w: bundle entry %q", ErrLengthOverflow, e.Name)
+		}
+		b.index[e.Name] = len(b.Entries)
+		b.Entries = append(b.Entries, e)
+	}
+	if err := r.Err(); err != nil {
+		return nil, unexpected(err)
+	}
+	return b, nil
+}
+
+// readUint reads an unsigned integer of size bytes in the Reader's order.
+func readUint(r *Reader, size int) uint64 {
+	switch size {
+	case 1:
+		var v uint8
+		r.ReadUint8(&v)
+		return uint64(v)
+	case 2:
+		var v uint16
+		r.ReadUint16(&v)
+		return uint64(v)
+	case 4:
+		var v uint32
+		r.ReadUint32(&v)
+		return uint64(v)
+	default:
+		var v uint64
+		r.ReadUint64(&v)
+		return v
+	}
+}
+
+// Entry returns the entry named name.
+func (b *Bundle) Entry(name string) (BundleEntry, bool) {
+	i, ok := b.index[name]
+	if !ok {
+		return BundleEntry{}, false
+	}
+	return b.Entries[i], true
+}
+
+// Section returns a reader over the stored bytes of a blob.
+func (b *Bundle) Section(e BundleEntry) *io.SectionReader {
+	return io.NewSectionReader(b.ra, e.Offset, e.Size)
+}
+
+// Open returns a reader over the contents of the blob named name,
+// decompressing it with the Compressor registered for its method.
+func (b *Bundle) Open(name string) (io.Reader, error) {
+	e, ok := b.Entry(name)
+	if !ok {
+		return nil, fmt.Errorf("%w: bundle entry %q", ErrUndefinedLabel, name)
+	}
+	if e.Compression == CompressNone {
+		return b.Section(e), nil
+	}
+	c, err := compressorOf(e.Compression)
+	if err != nil {
+		return nil, err
+	}
+	if e.Size > MAX_DECODE_SIZE || e.RawSize > MAX_DECODE_SIZE {
+		return nil, fmt.Errorf("%w: bundle entry %q of %d bytes", ErrLengthOverflow, name, e.RawSize)
+	}
+	stored := make([]byte, e.Size)
+	if _, err := b.ra.ReadAt(stored, e.Offset); err != nil {
+		return nil, unexpected(err)
+	}
+	raw, err := c.Decompress(make([]byte, 0, e.RawSize), stored, int(e.RawSize))
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(raw), nil
+}
+
+// bundleBlob is a blob added to a BundleWriter.
+type bundleBlob struct {
+	BundleEntry
+	data []byte
+}
+
+// BundleWriter writes a bundle. Blobs are collected by Add and written by
+// Close, which lays out the table of contents with Writer.WriteOffset and
+// resolves it once the blobs are placed, so the Writer must be patchable
+// (see Writer.CanPatch).
+type BundleWriter struct {
+	w     *Writer
+	f     BundleFormat
+	blobs []bundleBlob
+}
+
+// NewBundleWriter returns a BundleWriter writing a bundle of format f to w.
+func NewBundleWriter(w *Writer, f BundleFormat) *BundleWriter {
+	if err := f.check(); err != nil {
+		w.setError(err)
+	}
+	return &BundleWriter{w: w, f: f}
+}
+
+// Add adds a blob, compressed with the Compressor registered under method
+// unless method is CompressNone. Compression requires a Compressed format.
+func (bw *BundleWriter) Add(name string, data []byte, flags uint32, method CompressionID) {
+	if bw.w.err != nil {
+		return
+	}
+	e := BundleEntry{Name: name, Size: int64(len(data)), RawSize: int64(len(data)), Flags: flags, Compression: method}
+	if method != CompressNone {
+		if !bw.f.Compressed {
+			bw.w.setError(fmt.Errorf("%w: compressed entry %q in an uncompressed bundle", ErrInvalidSchema, name))
+			return
+		}
+		c, err := compressorOf(method)
+		if err != nil {
+			bw.w.setError(err)
+			return
+		}
+		if data, err = c.Compress(nil, data); err != nil {
+			bw.w.setError(err)
+			return
+		}
+		e.Size = int64(len(data))
+	}
+	bw.blobs = append(bw.blobs, bundleBlob{e, data})
+}
+
+// Close writes the table of contents and the blobs, and resolves the offsets.
+func (bw *BundleWriter) Close() error {
+	w, f := bw.w, bw.f
+	if w.err != nil {
+		return w.err
+	}
+	if !w.CanPatch() {
+		w.setError(ErrNotPatchable)
+		return w.err
+	}
+	order := w.order
+	w.WithByteOrder(f.Order)
+	defer w.WithByteOrder(order)
+
+	start := "bundle@" + strconv.FormatInt(w.count, 10)
+	w.MarkLabel(start)
+	w.Write(f.Magic)
+	w.WriteUint32(uint32(len(bw.blobs)))
+	for i, b := range bw.blobs {
+		if uint64(len(b.Name)) > 1<<(8*f.NameSize)-1 {
+			w.setError(fmt.Errorf("%w: bundle entry name of %d bytes", ErrLengthOverflow, len(b.Name)))
+			return w.err
+		}
+		if f.OffsetSize == 4 && max(b.Size, b.RawSize) > math.MaxUint32 {
+			w.setError(fmt.Errorf("%w: bundle entry %q of %d bytes", ErrLengthOverflow, b.Name, b.RawSize))
+			return w.err
+		}
+		writeUint(w, f.NameSize, uint64(len(b.Name)))
+		w.WriteString(b.Name)
+		w.WriteOffset(start+"/"+strconv.Itoa(i), start, f.OffsetSize)
+		writeUint(w, f.OffsetSize, uint64(b.Size))
+		if f.Compressed {
+			writeUint(w, f.OffsetSize, uint64(b.RawSize))
+			w.WriteUint8(uint8(b.Compression))
+		}
+		w.WriteUint32(b.Flags)
+	}
+	base, _ := w.OffsetOf(start)
+	for i, b := range bw.blobs {
+		if f.Align > 1 {
+			pos := w.count - base
+			w.WriteZeros(Roundup(pos, int64(f.Align)) - pos)
+		}
+		w.MarkLabel(start + "/" + strconv.Itoa(i))
+		w.Write(b.data)
+	}
+	return w.Resolve()
+}
+
+// writeUint writes the low size bytes of v in the Writer's order.
+func writeUint(w *Writer, size int, v uint64) {
+	switch size {
+	case 1:
+		w.WriteUint8(uint8(v))
+	case 2:
+		w.WriteUint16(uint16(v))
+	case 4:
+		w.WriteUint32(uint32(v))
+	default:
+		w.WriteUint64(v)
+	}
+}
@@ -0,0 +1,56 @@
+//go:build test
+
+package codec
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBundle(t *testing.T) {
+	format := BundleFormat{Order: LE, Magic: []byte("PAK1"), OffsetSize: 4, NameSize: 2, Align: 16, Compressed: true}
+	texture := bytes.Repeat([]byte("texel"), 100)
+
+	var buf bytes.Buffer
+	buf.WriteString("prefix") // the bundle need not start the stream
+	w, _ := NewWriter(&buf)
+	bw := NewBundleWriter(w, format)
+	bw.Add("mesh.bin", []byte{1, 2, 3}, 7, CompressNone)
+	bw.Add("tex.dds", texture, 0, CompressFlate)
+	require.NoError(t, bw.Close())
+	require.NoError(t, w.Flush())
+
+	b, err := OpenBundle(bytes.NewReader(buf.Bytes()[6:]), format)
+	require.NoError(t, err)
+	require.Len(t, b.Entries, 2)
+	mesh, ok := b.Entry("mesh.bin")
+	require.True(t, ok)
+	assert.EqualValues(t, 7, mesh.Flags)
+	assert.Zero(t, mesh.Offset%16)
+	tex, _ := b.Entry("tex.dds")
+	assert.Less(t, tex.Size, tex.RawSize)
+	assert.EqualValues(t, len(texture), tex.RawSize)
+
+	r, err := b.Open("mesh.bin")
+	require.NoError(t, err)
+	data, _ := io.ReadAll(r)
+	assert.Equal(t, []byte{1, 2, 3}, data)
+	r, err = b.Open("tex.dds")
+	require.NoError(t, err)
+	data, _ = io.ReadAll(r)
+	assert.Equal(t, texture, data)
+	_, err = b.Open("missing")
+	assert.ErrorIs(t, err, ErrUndefinedLabel)
+
+	_, err = OpenBundle(bytes.NewReader(buf.Bytes()), format)
+	assert.ErrorIs(t, err, ErrMagicMismatch)
+
+	plain := BundleFormat{Order: BE, OffsetSize: 8, NameSize: 1}
+	w, _ = NewWriter(&bytes.Buffer{})
+	NewBundleWriter(w, plain).Add("x", nil, 0, CompressFlate)
+	assert.ErrorIs(t, w.Err(), ErrInvalidSchema)
+}
@@ -0,0 +1,311 @@
+// Package cbor implements the header encoding of CBOR (RFC 8949) on top of
+// codec.Reader and codec.Writer: integers, byte and text strings, array and
+// map headers, tags and simple values. It is intended for emitting and parsing
+// CBOR envelopes around binary payloads, not for mapping arbitrary Go values.
+//
+// Writes follow the error latching of codec.Writer. Reads return the first
+// error, which is either the Reader's latched I/O error or a format error.
+package cbor
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/oy3o/codec"
+)
+
+// Major is a CBOR major type, stored in the top three bits of a header.
+type Major byte
+
+const (
+	MajorUint   Major = 0
+	MajorNegInt Major = 1
+	MajorBytes  Major = 2
+	MajorText   Major = 3
+	MajorArray  Major = 4
+	MajorMap    Major = 5
+	MajorTag    Major = 6
+	MajorSimple Major = 7 // simple values and floats
+)
+
+// Additional information values of MajorSimple.
+const (
+	simpleFalse   = 20
+	simpleTrue    = 21
+	simpleNull    = 22
+	simpleFloat16 = 25
+	simpleFloat32 = 26
+	simpleFloat64 = 27
+	indefinite    = 31
+)
+
+// MAX_LENGTH bounds the length of strings read by ReadBytes and ReadText. The
+// allocation limit of the codec.Reader, set with codec.WithMaxAlloc, applies
+// as well.
+const MAX_LENGTH = 16 << 20
+
+var (
+	// ErrUnexpectedType indicates an item of a different major type than requested.
+	ErrUnexpectedType = errors.New("cbor: unexpected major type")
+
+	// ErrInvalidHeader indicates a reserved additional information value.
+	ErrInvalidHeader = errors.New("cbor: invalid header")
+
+	// ErrIndefiniteLength indicates an indefinite-length item, which this package does not support.
+	ErrIndefiniteLength = errors.New("cbor: indefinite length not supported")
+
+	// ErrOverflow indicates an integer that does not fit the requested Go type.
+	ErrOverflow = errors.New("cbor: integer overflow")
+)
+
+// WriteHeader writes a header of the given major type with argument arg,
+// using the shortest encoding as required for deterministic CBOR.
+func WriteHeader(w *codec.Writer, major Major, arg uint64) {
+	var buf [9]byte
+	m := byte(major) << 5
+	switch {
+	case arg < 24:
+		buf[0] = m | byte(arg)
+		w.Write(buf[:1])
+	case arg <= math.MaxUint8:
+		buf[0], buf[1] = m|24, byte(arg)
+		w.Write(buf[:2])
+	case arg <= math.MaxUint16:
+		buf[0] = m | 25
+		binary.BigEndian.PutUint16(buf[1:], uint16(arg))
+		w.Write(buf[:3])
+	case arg <= math.MaxUint32:
+		buf[0] = m | 26
+		binary.BigEndian.PutUint32(buf[1:], uint32(arg))
+		w.Write(buf[:5])
+	default:
+		buf[0] = m | 27
+		binary.BigEndian.PutUint64(buf[1:], arg)
+		w.Write(buf[:9])
+	}
+}
+
+// WriteUint writes an unsigned integer.
+func WriteUint(w *codec.Writer, v uint64) { WriteHeader(w, MajorUint, v) }
+
+// WriteInt writes a signed integer.
+func WriteInt(w *codec.Writer, v int64) {
+	if v < 0 {
+		WriteHeader(w, MajorNegInt, uint64(-1-v))
+		return
+	}
+	WriteHeader(w, MajorUint, uint64(v))
+}
+
+// WriteBytes writes a byte string.
+func WriteBytes(w *codec.Writer, b []byte) {
+	WriteHeader(w, MajorBytes, uint64(len(b)))
+	w.WriteBytes(b)
+}
+
+// WriteText writes a UTF-8 text string.
+func WriteText(w *codec.Writer, s string) {
+	WriteHeader(w, MajorText, uint64(len(s)))
+	w.WriteString(s)
+}
+
+// WriteArrayHeader starts an array of n items, which must follow.
+func WriteArrayHeader(w *codec.Writer, n int) { WriteHeader(w, MajorArray, uint64(n)) }
+
+// WriteMapHeader starts a map of n key/value pairs, which must follow.
+func WriteMapHeader(w *codec.Writer, n int) { WriteHeader(w, MajorMap, uint64(n)) }
+
+// WriteTag writes a semantic tag, which applies to the item that follows.
+func WriteTag(w *codec.Writer, tag uint64) { WriteHeader(w, MajorTag, tag) }
+
+// WriteBool writes true or false.
+func WriteBool(w *codec.Writer, v bool) {
+	if v {
+		WriteHeader(w, MajorSimple, simpleTrue)
+	} else {
+		WriteHeader(w, MajorSimple, simpleFalse)
+	}
+}
+
+// WriteNull writes null.
+func WriteNull(w *codec.Writer) { WriteHeader(w, MajorSimple, simpleNull) }
+
+// WriteFloat64 writes a double-precision float.
+func WriteFloat64(w *codec.Writer, v float64) {
+	var buf [9]byte
+	buf[0] = byte(MajorSimple)<<5 | simpleFloat64
+	binary.BigEndian.PutUint64(buf[1:], math.Float64bits(v))
+	w.Write(buf[:])
+}
+
+// ReadHeader reads a header and returns its major type and argument. For
+// MajorSimple the argument of one-byte simple values is the simple value
+// itself, and that of floats is their raw bits.
+func ReadHeader(r *codec.Reader) (Major, uint64, error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return 0, 0, err
+	}
+	major, info := Major(b>>5), b&0x1F
+
+	var n int
+	switch {
+	case info < 24:
+		return major, uint64(info), nil
+	case info <= 27:
+		n = 1 << (info - 24)
+	case info == indefinite:
+		return major, 0, fmt.Errorf("%w: major type %d", ErrIndefiniteLength, major)
+	default:
+		return major, 0, fmt.Errorf("%w: additional information %d", ErrInvalidHeader, info)
+	}
+
+	var buf [8]byte
+	r.ReadBytesTo(buf[8-n:])
+	if err := r.Err(); err != nil {
+		return major, 0, err
+	}
+	return major, binary.BigEndian.Uint64(buf[:]), nil
+}
+
+// expect reads a header and checks its major type.
+func expect(r *codec.Reader, want Major) (uint64, error) {
+	major, arg, err := ReadHeader(r)
+	if err != nil {
+		return 0, err
+	}
+	if major != want {
+		return 0, fmt.Errorf("%w: got %d, want %d", ErrUnexpectedType, major, want)
+	}
+	return arg, nil
+}
+
+// ReadUint reads an unsigned integer.
+func ReadUint(r *codec.Reader) (uint64, error) { return expect(r, MajorUint) }
+
+// ReadInt reads a signed integer of either major type 0 or 1.
+func ReadInt(r *codec.Reader) (int64, error) {
+	major, arg, err := ReadHeader(r)
+	if err != nil {
+		return 0, err
+	}
+	if major != MajorUint && major != MajorNegInt {
+		return 0, fmt.Errorf("%w: got %d, want an integer", ErrUnexpectedType, major)
+	}
+	if arg > math.MaxInt64 {
+		return 0, fmt.Errorf("%w: %d does not fit int64", ErrOverflow, arg)
+	}
+	if major == MajorNegInt {
+		return -1 - int64(arg), nil
+	}
+	return int64(arg), nil
+}
+
+// readString reads the payload of a string item of the given major type.
+func readString(r *codec.Reader, major Major) ([]byte, error) {
+	n, err := expect(r, major)
+	if err != nil {
+		return nil, err
+	}
+	if n > MAX_LENGTH {
+		return nil, fmt.Errorf("%w: string of %d bytes exceeds %d", codec.ErrLengthOverflow, n, MAX_LENGTH)
+	}
+	b := r.ReadBytes(int(n))
+	if err := r.Err(); err != nil {
+		return nil, err
+	}
+	if b == nil {
+		b = []byte{}
+	}
+	return b, nil
+}
+
+// ReadBytes reads a byte string.
+func ReadBytes(r *codec.Reader) ([]byte, error) { return readString(r, MajorBytes) }
+
+// ReadText reads a text string.
+func ReadText(r *codec.Reader) (string, error) {
+	b, err := readString(r, MajorText)
+	return string(b), err
+}
+
+// ReadArrayHeader reads an array header and returns the number of items.
+func ReadArrayHeader(r *codec.Reader) (int, error) { return readCount(r, MajorArray) }
+
+// ReadMapHeader reads a map header and returns the number of pairs.
+func ReadMapHeader(r *codec.Reader) (int, error) { return readCount(r, MajorMap) }
+
+func readCount(r *codec.Reader, major Major) (int, error) {
+	n, err := expect(r, major)
+	if err != nil {
+		return 0, err
+	}
+	if n > math.MaxInt32 {
+		return 0, fmt.Errorf("%w: %d items", codec.ErrLengthOverflow, n)
+	}
+	return int(n), nil
+}
+
+// ReadTag reads a semantic tag.
+func ReadTag(r *codec.Reader) (uint64, error) { return expect(r, MajorTag) }
+
+// ReadBool reads true or false.
+func ReadBool(r *codec.Reader) (bool, error) {
+	v, err := expect(r, MajorSimple)
+	if err != nil {
+		return false, err
+	}
+	switch v {
+	case simpleFalse:
+		return false, nil
+	case simpleTrue:
+		return true, nil
+	}
+	return false, fmt.Errorf("%w: simple value %d is not a bool", ErrUnexpectedType, v)
+}
+
+// ReadFloat64 reads a float of any precision.
+func ReadFloat64(r *codec.Reader) (float64, error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	var buf [8]byte
+	switch b {
+	case byte(MajorSimple)<<5 | simpleFloat16:
+		r.ReadBytesTo(buf[:2])
+		return float16(binary.BigEndian.Uint16(buf[:])), r.Err()
+	case byte(MajorSimple)<<5 | simpleFloat32:
+		r.ReadBytesTo(buf[:4])
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(buf[:]))), r.Err()
+	case byte(MajorSimple)<<5 | simpleFloat64:
+		r.ReadBytesTo(buf[:])
+		return math.Float64frombits(binary.BigEndian.Uint64(buf[:])), r.Err()
+	}
+	return 0, fmt.Errorf("%w: header 0x%02x is not a float", ErrUnexpectedType, b)
+}
+
+// float16 converts IEEE 754 half-precision bits to a float64.
+func float16(h uint16) float64 {
+	exp := int(h>>10) & 0x1F
+	mant := float64(h & 0x3FF)
+	var v float64
+	switch exp {
+	case 0:
+		v = math.Ldexp(mant, -24)
+	case 31:
+		if mant == 0 {
+			v = math.Inf(1)
+		} else {
+			v = math.NaN()
+		}
+	default:
+		v = math.Ldexp(mant+1024, exp-25)
+	}
+	if h&0x8000 != 0 {
+		return -v
+	}
+	return v
+}
@@ -0,0 +1,105 @@
+//go:build test
+
+package cbor
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+
+	"github.com/oy3o/codec"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func encode(t *testing.T, fn func(w *codec.Writer)) string {
+	var buf bytes.Buffer
+	w, err := codec.NewWriter(&buf)
+	require.NoError(t, err)
+	fn(w)
+	require.NoError(t, w.Flush())
+	return hex.EncodeToString(buf.Bytes())
+}
+
+func reader(t *testing.T, s string) *codec.Reader {
+	b, err := hex.DecodeString(s)
+	require.NoError(t, err)
+	r, err := codec.NewReader(bytes.NewReader(b))
+	require.NoError(t, err)
+	return r
+}
+
+// Vectors from RFC 8949, Appendix A.
+func TestEncode(t *testing.T) {
+	assert.Equal(t, "17", encode(t, func(w *codec.Writer) { WriteUint(w, 23) }))
+	assert.Equal(t, "1818", encode(t, func(w *codec.Writer) { WriteUint(w, 24) }))
+	assert.Equal(t, "1903e8", encode(t, func(w *codec.Writer) { WriteUint(w, 1000) }))
+	assert.Equal(t, "1b000000e8d4a51000", encode(t, func(w *codec.Writer) { WriteUint(w, 1000000000000) }))
+	assert.Equal(t, "3863", encode(t, func(w *codec.Writer) { WriteInt(w, -100) }))
+	assert.Equal(t, "4401020304", encode(t, func(w *codec.Writer) { WriteBytes(w, []byte{1, 2, 3, 4}) }))
+	assert.Equal(t, "6449455446", encode(t, func(w *codec.Writer) { WriteText(w, "IETF") }))
+	assert.Equal(t, "fb3ff199999999999a", encode(t, func(w *codec.Writer) { WriteFloat64(w, 1.1) }))
+	assert.Equal(t, "f5f6", encode(t, func(w *codec.Writer) { WriteBool(w, true); WriteNull(w) }))
+
+	// {"a": 1, "b": [2, 3]} wrapped in tag 24 (embedded CBOR).
+	assert.Equal(t, "d818a26161016162820203", encode(t, func(w *codec.Writer) {
+		WriteTag(w, 24)
+		WriteMapHeader(w, 2)
+		WriteText(w, "a")
+		WriteUint(w, 1)
+		WriteText(w, "b")
+		WriteArrayHeader(w, 2)
+		WriteUint(w, 2)
+		WriteUint(w, 3)
+	}))
+}
+
+func TestDecode(t *testing.T) {
+	r := reader(t, "d818a26161016162820203")
+	tag, err := ReadTag(r)
+	require.NoError(t, err)
+	assert.EqualValues(t, 24, tag)
+	n, err := ReadMapHeader(r)
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+	key, err := ReadText(r)
+	require.NoError(t, err)
+	assert.Equal(t, "a", key)
+	v, err := ReadInt(r)
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, v)
+	_, err = ReadText(r)
+	require.NoError(t, err)
+	n, err = ReadArrayHeader(r)
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+
+	v, err = ReadInt(reader(t, "3903e7"))
+	require.NoError(t, err)
+	assert.EqualValues(t, -1000, v)
+
+	f, err := ReadFloat64(reader(t, "f93e00"))
+	require.NoError(t, err)
+	assert.Equal(t, 1.5, f)
+	f, err = ReadFloat64(reader(t, "fa47c35000"))
+	require.NoError(t, err)
+	assert.Equal(t, 100000.0, f)
+
+	_, err = ReadUint(reader(t, "6161"))
+	assert.ErrorIs(t, err, ErrUnexpectedType)
+	_, err = ReadArrayHeader(reader(t, "9f"))
+	assert.ErrorIs(t, err, ErrIndefiniteLength)
+	_, err = ReadUint(reader(t, "1c"))
+	assert.ErrorIs(t, err, ErrInvalidHeader)
+	_, err = ReadInt(reader(t, "3bffffffffffffffff"))
+	assert.ErrorIs(t, err, ErrOverflow)
+	_, err = ReadBytes(reader(t, "5a7fffffff"))
+	assert.ErrorIs(t, err, codec.ErrLengthOverflow)
+	r, err = codec.NewReaderOpts(bytes.NewReader([]byte{0x7a, 0, 0x10, 0, 0}), codec.WithMaxAlloc(1<<10))
+	require.NoError(t, err)
+	_, err = ReadText(r)
+	assert.ErrorIs(t, err, codec.ErrLengthOverflow)
+	b, err := ReadBytes(reader(t, "40"))
+	require.NoError(t, err)
+	assert.Equal(t, []byte{}, b)
+}
@@ -0,0 +1,88 @@
+package codec
+
+import (
+	"fmt"
+	"sync"
+
+	"golang.org/x/text/encoding"
+)
+
+// Charsets are golang.org/x/text encodings, so any of its charmaps (Latin-1,
+// EBCDIC code pages) or CJK encodings (Shift-JIS, GBK, Big5) can be used to
+// convert legacy text to and from UTF-8.
+var charsets sync.Map // map[string]encoding.Encoding
+
+// RegisterCharset makes enc available to Dynamic string fields tagged
+// `charset=name`. Registering a name again replaces the previous encoding.
+//
+//	codec.RegisterCharset("ebcdic", charmap.CodePage037)
+func RegisterCharset(name string, enc encoding.Encoding) {
+	charsets.Store(name, enc)
+}
+
+// charsetOf returns the encoding registered under name.
+func charsetOf(name string) (encoding.Encoding, error) {
+	enc, ok := charsets.Load(name)
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownCharset, name)
+	}
+	return enc.(encoding.Encoding), nil
+}
+
+// encodeText converts UTF-8 s to the bytes of enc.
+func encodeText(enc encoding.Encoding, s string) ([]byte, error) {
+	if enc == nil {
+		return []byte(s), nil
+	}
+	b, err := enc.NewEncoder().Bytes([]byte(s))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidText, err)
+	}
+	return b, nil
+}
+
+// decodeText converts bytes of enc to UTF-8.
+func decodeText(enc encoding.Encoding, b []byte) ([]byte, error) {
+	if enc == nil {
+		return b, nil
+	}
+	out, err := enc.NewDecoder().Bytes(b)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidText, err)
+	}
+	return out, nil
+}
+
+// WithCharset makes ReadString decode text from enc to UTF-8 and returns
+// the Reader for chaining. The length passed to ReadString counts encoded bytes.
+func (r *Reader) WithCharset(enc encoding.Encoding) *Reader {
+	r.charset = enc
+	return r
+}
+
+// WithCharset makes WriteText encode UTF-8 text to enc and returns the
+// Writer for chaining.
+func (w *Writer) WithCharset(enc encoding.Encoding) *Writer {
+	w.charset = enc
+	return w
+}
+
+// WriteText writes s in the Writer's charset, or as UTF-8 if none is set.
+// Use TextSize to compute the encoded length for a length prefix.
+func (w *Writer) WriteText(s string) {
+	if w.err != nil {
+		return
+	}
+	b, err := encodeText(w.charset, s)
+	if err != nil {
+		w.setError(err)
+		return
+	}
+	w.WriteBytes(b)
+}
+
+// TextSize returns the number of bytes WriteText writes for s.
+func (w *Writer) TextSize(s string) (int, error) {
+	b, err := encodeText(w.charset, s)
+	return len(b), err
+}
@@ -0,0 +1,222 @@
+package codec
+
+import (
+	"encoding/binary"
+	"hash/adler32"
+	"hash/crc32"
+	"hash/crc64"
+	"io"
+	"math/bits"
+	"slices"
+)
+
+// Checksum is a running checksum appended to or verified against a trailer,
+// as by ChecksumTrailer, ChunkFormat and Framer.WithChecksum. Every hash.Hash
+// is a Checksum. Sum appends the checksum in big-endian order, as hash.Hash
+// does; trailers in little-endian formats store it reversed.
+type Checksum interface {
+	io.Writer
+	Sum(b []byte) []byte
+	Size() int
+	Reset()
+}
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// NewCRC32 returns an IEEE CRC-32, as used by PNG, gzip and Ethernet.
+func NewCRC32() Checksum { return crc32.NewIEEE() }
+
+// NewCRC32C returns a Castagnoli CRC-32, as used by iSCSI, SCTP and ext4.
+func NewCRC32C() Checksum { return crc32.New(castagnoli) }
+
+// NewCRC64 returns a CRC-64 over table, such as crc64.MakeTable(crc64.ECMA).
+func NewCRC64(table *crc64.Table) Checksum { return crc64.New(table) }
+
+// NewAdler32 returns an Adler-32, as used by zlib.
+func NewAdler32() Checksum { return adler32.New() }
+
+// NewCRC24Q returns a CRC-24Q, as used by RTCM3 and the Qualcomm modems.
+func NewCRC24Q() Checksum { return new(crc24q) }
+
+// NewCRC16MCRF4XX returns a CRC-16/MCRF4XX, the reflected CCITT CRC with
+// initial value 0xFFFF and no final XOR, as used by MAVLink.
+func NewCRC16MCRF4XX() Checksum { return &mcrf4xx{crc: 0xFFFF} }
+
+// NewFletcher8 returns the 8-bit Fletcher checksum of u-blox UBX frames: two
+// running byte sums, the second summing the first.
+func NewFletcher8() Checksum { return new(fletcher8) }
+
+// appendTrailer appends the trailer holding the current value of c in order.
+func appendTrailer(b []byte, c Checksum, order binary.ByteOrder) []byte {
+	n := len(b)
+	b = c.Sum(b)
+	if order == LE {
+		slices.Reverse(b[n:])
+	}
+	return b
+}
+
+const (
+	xxPrime1 uint64 = 11400714785074694791
+	xxPrime2 uint64 = 14029467366897019727
+	xxPrime3 uint64 = 1609587929392839161
+	xxPrime4 uint64 = 9650029242287828579
+	xxPrime5 uint64 = 2870177450012600261
+)
+
+// XXHash64 is a streaming XXH64, the checksum of LZ4 frames and zstd, bit
+// compatible with the reference implementation. It implements hash.Hash64.
+type XXHash64 struct {
+	seed  uint64
+	v     [4]uint64
+	buf   [32]byte
+	n     int // bytes pending in buf
+	total uint64
+}
+
+// NewXXHash64 returns an XXH64 with the given seed, which is 0 in most formats.
+func NewXXHash64(seed uint64) *XXHash64 {
+	x := &XXHash64{seed: seed}
+	x.Reset()
+	return x
+}
+
+func (x *XXHash64) Reset() {
+	x.v = [4]uint64{x.seed + xxPrime1 + xxPrime2, x.seed + xxPrime2, x.seed, x.seed - xxPrime1}
+	x.n, x.total = 0, 0
+}
+
+func (x *XXHash64) Size() int      { return 8 }
+func (x *XXHash64) BlockSize() int { return 32 }
+
+func xxRound(acc, input uint64) uint64 {
+	return bits.RotateLeft64(acc+input*xxPrime2, 31) * xxPrime1
+}
+
+func xxMerge(acc, v uint64) uint64 {
+	return (acc^xxRound(0, v))*xxPrime1 + xxPrime4
+}
+
+func (x *XXHash64) stripe(p []byte) {
+	for i := range x.v {
+		x.v[i] = xxRound(x.v[i], binary.LittleEndian.Uint64(p[8*i:]))
+	}
+}
+
+func (x *XXHash64) Write(p []byte) (int, error) {
+	n := len(p)
+	x.total += uint64(n)
+	if x.n > 0 {
+		k := copy(x.buf[x.n:], p)
+		x.n += k
+		p = p[k:]
+		if x.n < len(x.buf) {
+			return n, nil
+		}
+		x.stripe(x.buf[:])
+		x.n = 0
+	}
+	for ; len(p) >= 32; p = p[32:] {
+		x.stripe(p)
+	}
+	x.n = copy(x.buf[:], p)
+	return n, nil
+}
+
+func (x *XXHash64) Sum64() uint64 {
+	var h uint64
+	if x.total >= 32 {
+		v := x.v
+		h = bits.RotateLeft64(v[0], 1) + bits.RotateLeft64(v[1], 7) + bits.RotateLeft64(v[2], 12) + bits.RotateLeft64(v[3], 18)
+		for _, vi := range v {
+			h = xxMerge(h, vi)
+		}
+	} else {
+		h = x.seed + xxPrime5
+	}
+	h += x.total
+
+	p := x.buf[:x.n]
+	for ; len(p) >= 8; p = p[8:] {
+		h ^= xxRound(0, binary.LittleEndian.Uint64(p))
+		h = bits.RotateLeft64(h, 27)*xxPrime1 + xxPrime4
+	}
+	if len(p) >= 4 {
+		h ^= uint64(binary.LittleEndian.Uint32(p)) * xxPrime1
+		h = bits.RotateLeft64(h, 23)*xxPrime2 + xxPrime3
+		p = p[4:]
+	}
+	for _, b := range p {
+		h ^= uint64(b) * xxPrime5
+		h = bits.RotateLeft64(h, 11) * xxPrime1
+	}
+
+	h ^= h >> 33
+	h *= xxPrime2
+	h ^= h >> 29
+	h *= xxPrime3
+	h ^= h >> 32
+	return h
+}
+
+func (x *XXHash64) Sum(b []byte) []byte {
+	return binary.BigEndian.AppendUint64(b, x.Sum64())
+}
+
+// crc24qTable holds the CRC-24Q of every byte, most significant bit first
+// with polynomial 0x864CFB.
+var crc24qTable = func() (t [256]uint32) {
+	for i := range t {
+		c := uint32(i) << 16
+		for range 8 {
+			c <<= 1
+			if c&0x1000000 != 0 {
+				c ^= 0x1864CFB
+			}
+		}
+		t[i] = c
+	}
+	return t
+}()
+
+type crc24q struct{ crc uint32 }
+
+func (c *crc24q) Write(p []byte) (int, error) {
+	for _, b := range p {
+		c.crc = (c.crc<<8 ^ crc24qTable[byte(c.crc>>16)^b]) & 0xFFFFFF
+	}
+	return len(p), nil
+}
+
+func (c *crc24q) Sum(b []byte) []byte { return append(b, byte(c.crc>>16), byte(c.crc>>8), byte(c.crc)) }
+func (c *crc24q) Size() int           { return 3 }
+func (c *crc24q) Reset()              { c.crc = 0 }
+
+type mcrf4xx struct{ crc uint16 }
+
+func (c *mcrf4xx) Write(p []byte) (int, error) {
+	for _, b := range p {
+		t := b ^ byte(c.crc)
+		t ^= t << 4
+		c.crc = c.crc>>8 ^ uint16(t)<<8 ^ uint16(t)<<3 ^ uint16(t>>4)
+	}
+	return len(p), nil
+}
+
+func (c *mcrf4xx) Sum(b []byte) []byte { return binary.BigEndian.AppendUint16(b, c.crc) }
+func (c *mcrf4xx) Size() int           { return 2 }
+func (c *mcrf4xx) Reset()              { c.crc = 0xFFFF }
+
+type fletcher8 struct{ a, b byte }
+
+func (f *fletcher8) Write(p []byte) (int, error) {
+	for _, c := range p {
+		f.a += c
+		f.b += f.a
+	}
+	return len(p), nil
+}
+
+func (f *fletcher8) Sum(b []byte) []byte { return append(b, f.a, f.b) }
+func (f *fletcher8) Size() int           { return 2 }
+func (f *fletcher8) Reset()              { f.a, f.b = 0, 0 }
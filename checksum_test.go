@@ -0,0 +1,82 @@
+//go:build test
+
+package codec
+
+import (
+	"bytes"
+	"encoding/hex"
+	"hash"
+	"hash/crc64"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var _ hash.Hash64 = (*XXHash64)(nil)
+
+func TestChecksums(t *testing.T) {
+	for _, tt := range []struct {
+		sum  Checksum
+		in   string
+		want string
+	}{
+		{NewCRC32(), "123456789", "cbf43926"},
+		{NewCRC32C(), "123456789", "e3069283"},
+		{NewCRC64(crc64.MakeTable(crc64.ECMA)), "123456789", "995dc9bbdf1939fa"},
+		{NewAdler32(), "Wikipedia", "11e60398"},
+		{NewCRC24Q(), "123456789", "cde703"},
+		{NewCRC16MCRF4XX(), "123456789", "6f91"},
+		{NewFletcher8(), "\x06\x01\x03\x00\xf1\x00\x01", "fc13"},
+		{NewXXHash64(0), "", "ef46db3751d8e999"},
+		{NewXXHash64(0), "abc", "44bc2cf5ad770999"},
+		{NewXXHash64(0), "Nobody inspects the spammish repetition", "fbcea83c8a378bf1"},
+	} {
+		tt.sum.Write([]byte(tt.in))
+		assert.Equal(t, tt.want, hex.EncodeToString(tt.sum.Sum(nil)), "%T(%q)", tt.sum, tt.in)
+	}
+
+	// Streaming in uneven pieces matches a single write.
+	data := bytes.Repeat([]byte("0123456789abcdef!"), 20)
+	x := NewXXHash64(42)
+	x.Write(data)
+	want := x.Sum64()
+	x.Reset()
+	for p := data; len(p) > 0; p = p[min(7, len(p)):] {
+		x.Write(p[:min(7, len(p))])
+	}
+	assert.Equal(t, want, x.Sum64())
+}
+
+func TestChecksumFraming(t *testing.T) {
+	var buf bytes.Buffer
+	f := NewFramer(&buf, &buf).WithPrefix(2, LE).WithChecksum(NewXXHash64(0))
+	require.NoError(t, f.WriteFrame([]byte("abc")))
+	require.NoError(t, f.WriteCodec(&mockCodec{Payload: mockPayload{ID: 1}}))
+	assert.Equal(t, "0300616263"+"990977adf52cbc44", hex.EncodeToString(buf.Bytes()[:13]))
+
+	p, err := f.ReadFrame()
+	require.NoError(t, err)
+	assert.Equal(t, "abc", string(p))
+	body, _, err := f.NextFrame()
+	require.NoError(t, err)
+	_, err = io.ReadAll(body)
+	require.NoError(t, err)
+
+	require.NoError(t, f.WriteFrame([]byte("abc")))
+	buf.Bytes()[3] ^= 1
+	_, err = f.ReadFrame()
+	assert.ErrorIs(t, err, ErrChecksumMismatch)
+
+	var chunks bytes.Buffer
+	format := ChunkFormat{Order: BE, Checksum: NewCRC32C, Align: 4}
+	w, _ := NewWriter(&chunks)
+	NewChunkWriter(w, format).WriteChunk(FourCC{'t', 'e', 's', 't'}, []byte{1, 2, 3})
+	require.NoError(t, w.Flush())
+	assert.Equal(t, 8+3+4+1, chunks.Len())
+	c, err := NewChunkReader(&chunks, format).Next()
+	require.NoError(t, err)
+	_, err = io.ReadAll(c.Body)
+	require.NoError(t, err)
+}
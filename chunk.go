@@ -0,0 +1,274 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"iter"
+)
+
+// FourCC is the four-character code identifying a chunk, such as "RIFF",
+// "fmt " or "IHDR".
+type FourCC [4]byte
+
+func (id FourCC) String() string { return string(id[:]) }
+
+// ChunkFormat describes a chunked container: every chunk is a FourCC and a
+// uint32 body length, the body, then an optional checksum and padding.
+type ChunkFormat struct {
+	Order       binary.ByteOrder // byte order of the length and checksum
+	LengthFirst bool             // the length precedes the FourCC, as in PNG
+	CRC         bool             // a CRC-32 (IEEE) of the FourCC and body follows the body
+	Align       int              // chunks are padded to a multiple of Align bytes, as in RIFF
+	Max         uint32           // upper bound on body lengths when reading, 0 for none
+
+	// Checksum, if set, replaces the CRC-32 selected by CRC with another
+	// checksum of the FourCC and body.
+	Checksum func() Checksum
+}
+
+var (
+	// RIFFChunks is the layout of RIFF (WAV, AVI, WebP): little-endian lengths
+	// and bodies padded to an even size.
+	RIFFChunks = ChunkFormat{Order: LE, Align: 2}
+
+	// PNGChunks is the layout of PNG: big-endian lengths before the type and a
+	// CRC after each body.
+	PNGChunks = ChunkFormat{Order: BE, LengthFirst: true, CRC: true}
+)
+
+// header encodes a chunk header.
+func (f *ChunkFormat) header(id FourCC, length uint32) (buf [8]byte, lengthAt int) {
+	if f.LengthFirst {
+		f.Order.PutUint32(buf[:4], length)
+		copy(buf[4:], id[:])
+		return buf, 0
+	}
+	copy(buf[:4], id[:])
+	f.Order.PutUint32(buf[4:], length)
+	return buf, 4
+}
+
+// checksum returns a new checksum of the format, or nil if it has none.
+func (f *ChunkFormat) checksum(id FourCC) Checksum {
+	var sum Checksum
+	switch {
+	case f.Checksum != nil:
+		sum = f.Checksum()
+	case f.CRC:
+		sum = NewCRC32()
+	default:
+		return nil
+	}
+	sum.Write(id[:])
+	return sum
+}
+
+// padding returns the number of pad bytes after a chunk with a body of length
+// bytes and its checksum, if any.
+func (f *ChunkFormat) padding(length int64, sum Checksum) int64 {
+	if f.Align <= 1 {
+		return 0
+	}
+	n := length
+	if sum != nil {
+		n += int64(sum.Size())
+	}
+	return Roundup(n, int64(f.Align)) - n
+}
+
+// Chunk is a chunk returned by ChunkReader. Body streams exactly Length bytes;
+// the checksum and padding that follow are consumed and verified when Body reaches
+// its end.
+type Chunk struct {
+	ID     FourCC
+	Length uint32
+	Body   io.Reader
+}
+
+// ChunkReader iterates the chunks of a container.
+type ChunkReader struct {
+	r    io.Reader
+	f    ChunkFormat
+	body io.Reader // body of the current chunk, drained by Next
+}
+
+// NewChunkReader returns a ChunkReader reading chunks of format f from r.
+func NewChunkReader(r io.Reader, f ChunkFormat) *ChunkReader {
+	return &ChunkReader{r: r, f: f}
+}
+
+// Next returns the next chunk. Any unread part of the previous chunk's body is
+// skipped, which is how unknown chunks are passed over. It returns io.EOF at a
+// clean end of the container.
+func (cr *ChunkReader) Next() (Chunk, error) {
+	if cr.body != nil {
+		body := cr.body
+		cr.body = nil
+		if _, err := io.Copy(io.Discard, body); err != nil {
+			return Chunk{}, err
+		}
+	}
+
+	var hdr [8]byte
+	if n, err := io.ReadFull(cr.r, hdr[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return Chunk{}, fmt.Errorf("%w: chunk header of %d bytes", ErrTruncatedData, n)
+		}
+		return Chunk{}, err
+	}
+	var c Chunk
+	if cr.f.LengthFirst {
+		c.Length = cr.f.Order.Uint32(hdr[:4])
+		copy(c.ID[:], hdr[4:])
+	} else {
+		copy(c.ID[:], hdr[:4])
+		c.Length = cr.f.Order.Uint32(hdr[4:])
+	}
+	if cr.f.Max > 0 && c.Length > cr.f.Max {
+		return Chunk{}, fmt.Errorf("%w: chunk %q of %d bytes exceeds %d", ErrLengthOverflow, c.ID, c.Length, cr.f.Max)
+	}
+
+	src := cr.r
+	sum := cr.f.checksum(c.ID)
+	if sum != nil {
+		src = io.TeeReader(cr.r, sum)
+	}
+	c.Body = ChainReader(src, int64(c.Length), func(io.Reader) error {
+		return cr.trailer(c, sum)
+	})
+	cr.body = c.Body
+	return c, nil
+}
+
+// trailer consumes and verifies what follows the body of c.
+func (cr *ChunkReader) trailer(c Chunk, sum Checksum) error {
+	if sum != nil {
+		got := make([]byte, sum.Size())
+		if _, err := io.ReadFull(cr.r, got); err != nil {
+			return err
+		}
+		if want := appendTrailer(nil, sum, cr.f.Order); !bytes.Equal(got, want) {
+			return fmt.Errorf("%w: chunk %q checksum %x, computed %x", ErrChecksumMismatch, c.ID, got, want)
+		}
+	}
+	if pad := cr.f.padding(int64(c.Length), sum); pad > 0 {
+		if _, err := io.CopyN(io.Discard, cr.r, pad); err != nil && err != io.EOF {
+			return err
+		}
+	}
+	return nil
+}
+
+// All returns an iterator over the remaining chunks. Iteration ends at a clean
+// end of the container; any other error is yielded once and ends it.
+func (cr *ChunkReader) All() iter.Seq2[Chunk, error] {
+	return func(yield func(Chunk, error) bool) {
+		for {
+			c, err := cr.Next()
+			if err == io.EOF {
+				return
+			}
+			if !yield(c, err) || err != nil {
+				return
+			}
+		}
+	}
+}
+
+// openChunk is a chunk started by Begin and not yet ended.
+type openChunk struct {
+	id       FourCC
+	lengthAt int64 // Writer position of the length field
+	n        int64 // body bytes written so far
+	sum      Checksum
+}
+
+// ChunkWriter writes the chunks of a container to a Writer. Chunks of known
+// size are written with WriteChunk; chunks whose size is only known once the
+// body is written, including containers such as RIFF LIST chunks, are opened
+// with Begin, written through the ChunkWriter and closed with End, which
+// backpatches the length. Errors are latched by the underlying Writer.
+type ChunkWriter struct {
+	w    *Writer
+	f    ChunkFormat
+	open []*openChunk
+}
+
+// NewChunkWriter returns a ChunkWriter writing chunks of format f to w.
+func NewChunkWriter(w *Writer, f ChunkFormat) *ChunkWriter {
+	return &ChunkWriter{w: w, f: f}
+}
+
+// Write writes p to the body of the innermost open chunk.
+func (cw *ChunkWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	for _, c := range cw.open {
+		c.n += int64(n)
+		if c.sum != nil {
+			c.sum.Write(p[:n])
+		}
+	}
+	return n, err
+}
+
+// WriteChunk writes a complete chunk with the given body.
+func (cw *ChunkWriter) WriteChunk(id FourCC, body []byte) {
+	if uint64(len(body)) > uint64(^uint32(0)) {
+		cw.w.setError(fmt.Errorf("%w: chunk %q of %d bytes", ErrLengthOverflow, id, len(body)))
+		return
+	}
+	hdr, _ := cw.f.header(id, uint32(len(body)))
+	cw.Write(hdr[:])
+	cw.Write(body)
+	sum := cw.f.checksum(id)
+	if sum != nil {
+		sum.Write(body)
+	}
+	cw.finish(sum, int64(len(body)))
+}
+
+// Begin starts a chunk whose length is filled in by End. It requires a
+// patchable Writer (see Writer.CanPatch).
+func (cw *ChunkWriter) Begin(id FourCC) {
+	if !cw.w.CanPatch() {
+		cw.w.setError(ErrNotPatchable)
+		return
+	}
+	hdr, lengthAt := cw.f.header(id, 0)
+	c := &openChunk{id: id, lengthAt: cw.w.Count() + int64(lengthAt)}
+	cw.Write(hdr[:])
+	c.sum = cw.f.checksum(id)
+	cw.open = append(cw.open, c)
+}
+
+// End closes the innermost chunk opened by Begin, patching its length and
+// writing its checksum and padding. It returns the Writer's latched error.
+func (cw *ChunkWriter) End() error {
+	if len(cw.open) == 0 {
+		return fmt.Errorf("%w: End without Begin", ErrInvalidSeek)
+	}
+	c := cw.open[len(cw.open)-1]
+	cw.open = cw.open[:len(cw.open)-1]
+	if c.n > int64(^uint32(0)) {
+		cw.w.setError(fmt.Errorf("%w: chunk %q of %d bytes", ErrLengthOverflow, c.id, c.n))
+		return cw.w.Err()
+	}
+	var length [4]byte
+	cw.f.Order.PutUint32(length[:], uint32(c.n))
+	cw.w.Patch(c.lengthAt, length[:])
+	cw.finish(c.sum, c.n)
+	return cw.w.Err()
+}
+
+// finish writes the checksum and padding following a body of length bytes.
+func (cw *ChunkWriter) finish(sum Checksum, length int64) {
+	if sum != nil {
+		var buf [8]byte
+		cw.Write(appendTrailer(buf[:0], sum, cw.f.Order))
+	}
+	if pad := cw.f.padding(length, sum); pad > 0 {
+		cw.Write(empty[:pad])
+	}
+}
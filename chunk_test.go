@@ -0,0 +1,97 @@
+//go:build test
+
+package codec
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChunkRIFF(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf)
+	require.NoError(t, err)
+	cw := NewChunkWriter(w, RIFFChunks)
+	cw.Begin(FourCC{'R', 'I', 'F', 'F'})
+	cw.Write([]byte("WAVE"))
+	cw.WriteChunk(FourCC{'f', 'm', 't', ' '}, []byte{1, 2, 3})
+	cw.Begin(FourCC{'d', 'a', 't', 'a'})
+	cw.Write([]byte{9, 9})
+	require.NoError(t, cw.End())
+	require.NoError(t, cw.End())
+
+	assert.Equal(t, "RIFF\x1a\x00\x00\x00WAVE"+
+		"fmt \x03\x00\x00\x00\x01\x02\x03\x00"+
+		"data\x02\x00\x00\x00\x09\x09", buf.String())
+
+	cr := NewChunkReader(&buf, RIFFChunks)
+	riff, err := cr.Next()
+	require.NoError(t, err)
+	assert.Equal(t, "RIFF", riff.ID.String())
+	form := make([]byte, 4)
+	_, err = io.ReadFull(riff.Body, form)
+	require.NoError(t, err)
+	assert.Equal(t, "WAVE", string(form))
+
+	var ids []string
+	for c, err := range NewChunkReader(riff.Body, RIFFChunks).All() {
+		require.NoError(t, err)
+		ids = append(ids, c.ID.String())
+		if c.ID.String() == "data" {
+			body, err := io.ReadAll(c.Body)
+			require.NoError(t, err)
+			assert.Equal(t, []byte{9, 9}, body)
+		}
+	}
+	assert.Equal(t, []string{"fmt ", "data"}, ids)
+	_, err = cr.Next()
+	assert.Equal(t, io.EOF, err)
+
+	w, err = NewWriter(bufio.NewWriter(io.Discard))
+	require.NoError(t, err)
+	NewChunkWriter(w, RIFFChunks).Begin(FourCC{})
+	assert.ErrorIs(t, w.Err(), ErrNotPatchable)
+}
+
+func TestChunkPNG(t *testing.T) {
+	// The IEND chunk of every PNG file.
+	iend, _ := hex.DecodeString("0000000049454e44ae426082")
+
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf)
+	require.NoError(t, err)
+	cw := NewChunkWriter(w, PNGChunks)
+	cw.WriteChunk(FourCC{'t', 'E', 'X', 't'}, []byte("a\x00b"))
+	cw.Begin(FourCC{'I', 'E', 'N', 'D'})
+	require.NoError(t, cw.End())
+	assert.Equal(t, iend, buf.Bytes()[buf.Len()-12:])
+
+	data := bytes.Clone(buf.Bytes())
+	cr := NewChunkReader(bytes.NewReader(data), PNGChunks)
+	c, err := cr.Next()
+	require.NoError(t, err)
+	assert.Equal(t, "tEXt", c.ID.String())
+	assert.EqualValues(t, 3, c.Length)
+	c, err = cr.Next() // skips the unread tEXt body
+	require.NoError(t, err)
+	assert.Equal(t, "IEND", c.ID.String())
+	_, err = cr.Next()
+	assert.Equal(t, io.EOF, err)
+
+	data[9] ^= 0xFF // corrupt the tEXt body
+	cr = NewChunkReader(bytes.NewReader(data), PNGChunks)
+	_, err = cr.Next()
+	require.NoError(t, err)
+	_, err = cr.Next()
+	assert.ErrorIs(t, err, ErrChecksumMismatch)
+
+	cr = NewChunkReader(bytes.NewReader(data), ChunkFormat{Order: BE, LengthFirst: true, Max: 2})
+	_, err = cr.Next()
+	assert.ErrorIs(t, err, ErrLengthOverflow)
+}
@@ -2,9 +2,14 @@ package codec
 
 import (
 	"encoding"
+	"fmt"
 	"io"
 )
 
+// MAX_DECODE_SIZE bounds the number of bytes Decode accepts, so a hostile or
+// corrupt stream cannot exhaust memory.
+const MAX_DECODE_SIZE = 64 << 20
+
 // Sizer is an interface for types that can report their binary size.
 // This is useful for pre-allocating buffers before encoding.
 type Sizer interface {
@@ -44,3 +49,87 @@ type Codec interface {
 	Marshaler
 	Unmarshaler
 }
+
+// Appender is implemented by codecs that can encode by appending to a slice,
+// mirroring encoding.BinaryAppender. It lets callers build packets from several
+// codecs in one reusable buffer without intermediate allocations.
+type Appender interface {
+	// MarshalAppend appends the encoding to dst and returns the extended slice.
+	MarshalAppend(dst []byte) ([]byte, error)
+}
+
+// Append appends the encoding of c to dst, using MarshalAppend when c
+// implements Appender and MarshalTo otherwise.
+func Append(dst []byte, c Codec) ([]byte, error) {
+	if a, ok := c.(Appender); ok {
+		return a.MarshalAppend(dst)
+	}
+	return MarshalAppendGeneric(c, dst)
+}
+
+// Decode allocates a T, decodes it from the entire contents of r and returns it.
+// Input larger than MAX_DECODE_SIZE fails with ErrLengthOverflow, and the
+// trailing-data checks of UnmarshalBinary apply to anything after the message.
+// T is expected to be a pointer type such as *Fixed[Header].
+func Decode[T Codec](r io.Reader) (T, error) {
+	v := newCodec[T]()
+	data, err := io.ReadAll(io.LimitReader(r, MAX_DECODE_SIZE+1))
+	if err != nil {
+		return v, err
+	}
+	if len(data) > MAX_DECODE_SIZE {
+		return v, fmt.Errorf("%w: input exceeds %d bytes", ErrLengthOverflow, MAX_DECODE_SIZE)
+	}
+	return v, v.UnmarshalBinary(data)
+}
+
+// Encode returns the encoding of v in a slice of exactly v.Size() bytes.
+func Encode[T Codec](v T) ([]byte, error) {
+	return Append(make([]byte, 0, v.Size()), v)
+}
+
+// EncodeTo streams the encoding of v to w through a buffered Writer, flushing
+// it before returning, and reports the number of bytes written.
+func EncodeTo[T Codec](w io.Writer, v T) (int64, error) {
+	cw, err := NewWriter(w)
+	if err != nil {
+		return 0, err
+	}
+	cw.WriteFrom(v)
+	return cw.Result()
+}
+
+// EncodeAll writes the encodings of codecs to w in order, e.g. a header, a
+// body and a footer, through a single buffered Writer with one final flush.
+// It returns the total number of bytes written and stops at the first error.
+func EncodeAll(w io.Writer, codecs ...Codec) (int64, error) {
+	cw, err := NewWriter(w)
+	if err != nil {
+		return 0, err
+	}
+	for _, c := range codecs {
+		cw.WriteFrom(c)
+	}
+	return cw.Result()
+}
+
+// DecodeAll decodes codecs from r in order through a single buffered Reader
+// and returns the total number of bytes consumed by them. It stops at the
+// first error.
+//
+// Buffering may read ahead of the last codec. To keep reading the stream
+// afterwards, pass a *Reader, which is used directly.
+func DecodeAll(r io.Reader, codecs ...Codec) (int64, error) {
+	cr, ok := r.(*Reader)
+	if !ok {
+		var err error
+		if cr, err = NewReaderSize(r, BUFFER_SIZE); err != nil {
+			return 0, err
+		}
+	}
+	start := cr.Count()
+	for _, c := range codecs {
+		cr.ReadTo(c)
+	}
+	return cr.Count() - start, cr.Err()
+}
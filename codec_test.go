@@ -3,9 +3,16 @@
 package codec
 
 import (
+	"bufio"
 	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"hash/crc64"
 	"io"
-	"sync"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -21,9 +28,6 @@ type mockPayload struct {
 	Data [4]byte
 }
 
-// mockCodec is an alias for a FixedSizeCodec using our mockPayload.
-type mockCodec = Fixed[mockPayload]
-
 // mockFlushingWriter helps verify that a writer's Flush method is called.
 type mockFlushingWriter struct {
 	bytes.Buffer
@@ -35,6 +39,11 @@ func (m *mockFlushingWriter) Flush() error {
 	return nil
 }
 
+// failingCodec is a Codec whose WriteTo always fails.
+type failingCodec struct{ mockCodec }
+
+func (*failingCodec) WriteTo(io.Writer) (int64, error) { return 0, io.ErrShortWrite }
+
 // --- Writer Test Suite ---
 
 type WriterTestSuite struct {
@@ -57,7 +66,7 @@ func (s *WriterTestSuite) TestConstructors() {
 }
 
 func (s *WriterTestSuite) TestBasicWrites() {
-	codec := &mockCodec{mockPayload{ID: 0xDEADBEEF, Data: [4]byte{1, 2, 3, 4}}}
+	codec := &mockCodec{Payload: mockPayload{ID: 0xDEADBEEF, Data: [4]byte{1, 2, 3, 4}}}
 
 	s.writer.WriteUint8(0xAA)
 	s.writer.WriteUint16(0xBBCC)
@@ -151,6 +160,25 @@ func (s *WriterTestSuite) TestFlush() {
 	s.Assert().Equal(1, mock.Buffer.Len())
 }
 
+func (s *WriterTestSuite) TestWriteUTF16String() {
+	s.writer.WriteUTF16String("A\U0001F600", BE, true, true)
+
+	_, err := s.writer.Result()
+	s.Require().NoError(err)
+	expected := []byte{
+		0xFE, 0xFF, // BOM
+		0x00, 0x41, // 'A'
+		0xD8, 0x3D, 0xDE, 0x00, // U+1F600 as a surrogate pair
+		0x00, 0x00, // null terminator
+	}
+	s.Assert().Equal(expected, s.buf.Bytes())
+
+	str, n, err := ReadUTF16StringUntilNull(bytes.NewReader(s.buf.Bytes()))
+	s.Require().NoError(err)
+	s.Assert().Equal("A\U0001F600", str)
+	s.Assert().EqualValues(len(expected), n)
+}
+
 // TestWriter runs the WriterTestSuite.
 func TestWriter(t *testing.T) {
 	suite.Run(t, new(WriterTestSuite))
@@ -297,6 +325,90 @@ func (s *ReaderTestSuite) TestForwardOnlySeekerErrors() {
 	s.Assert().Contains(err.Error(), "unsupported whence")
 }
 
+func (s *ReaderTestSuite) TestForwardSeekBuffered() {
+	// A source that is neither seekable nor in memory goes through bufio,
+	// which reads ahead; forward seeks must still land on the right byte.
+	data := make([]byte, 100)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	r, err := NewReaderSize(io.MultiReader(bytes.NewReader(data)), 16)
+	s.Require().NoError(err)
+
+	var b uint8
+	r.ReadUint8(&b)
+	pos, err := r.Seek(40, io.SeekCurrent)
+	s.Require().NoError(err)
+	s.Assert().EqualValues(41, pos)
+	r.ReadUint8(&b)
+	s.Assert().EqualValues(41, b)
+
+	pos, err = r.Seek(90, io.SeekStart)
+	s.Require().NoError(err)
+	s.Assert().EqualValues(90, pos)
+	r.ReadUint8(&b)
+	s.Assert().EqualValues(90, b)
+	s.Require().NoError(r.Err())
+
+	_, err = r.Seek(10, io.SeekStart)
+	s.Assert().ErrorIs(err, ErrUnsupportedNegativeSeek)
+}
+
+func (s *ReaderTestSuite) TestResync() {
+	data := []byte{0xDE, 0xAD, 0xBE, 0xEF, 0xCA, 0xFE, 0x01, 0x02, 0xCA, 0xFE, 0x03}
+	r, _ := NewReader(bytes.NewReader(data))
+
+	var skips [][2]int64
+	r.WithResync([]byte{0xCA, 0xFE}, func(offset, n int64) {
+		skips = append(skips, [2]int64{offset, n})
+	})
+
+	// 1. Garbage before the first marker is skipped and reported.
+	n, err := r.Resync()
+	s.Require().NoError(err)
+	s.Assert().EqualValues(4, n)
+	s.Assert().Equal([]byte{0x01, 0x02}, r.ReadBytes(2))
+
+	// 2. A marker directly at the current position skips nothing.
+	n, err = r.Resync()
+	s.Require().NoError(err)
+	s.Assert().EqualValues(0, n)
+	s.Assert().Equal([][2]int64{{0, 4}}, skips)
+
+	// 3. Running out of data reports the tail and latches EOF.
+	n, err = r.Resync()
+	s.Assert().ErrorIs(err, io.EOF)
+	s.Assert().EqualValues(1, n)
+	s.Assert().Equal([2]int64{10, 1}, skips[1])
+}
+
+func (s *ReaderTestSuite) TestAlignSync() {
+	m := SyncMarker{0xCA, 0xFE}
+	var buf bytes.Buffer
+	w, _ := NewWriter(&buf)
+	w.WriteBytes([]byte{1, 2, 3})
+	w.WriteSync(m)
+	w.WriteUint8(4)
+	s.Require().NoError(w.Flush())
+
+	r, _ := NewReader(bytes.NewReader(buf.Bytes()))
+	var skips int
+	r.WithResync(m, func(offset, n int64) { skips++ })
+	n, err := r.AlignSync(m)
+	s.Require().NoError(err)
+	s.Assert().EqualValues(3, n)
+	s.Assert().Equal([]byte{4}, r.ReadBytes(1))
+	s.Assert().Zero(skips, "AlignSync must not report to the resync callback")
+
+	_, err = r.AlignSync(nil)
+	s.Assert().ErrorIs(err, ErrNoSyncMarker)
+	n, err = r.AlignSync(m)
+	s.Assert().ErrorIs(err, io.EOF)
+	s.Assert().EqualValues(0, n)
+
+	s.Assert().Len(NewSyncMarker(), SYNC_SIZE)
+}
+
 // TestReader runs the ReaderTestSuite.
 func TestReader(t *testing.T) {
 	suite.Run(t, new(ReaderTestSuite))
@@ -304,56 +416,762 @@ func TestReader(t *testing.T) {
 
 // --- Standalone Codec Tests ---
 
-func TestFixedSizeCodec_SizeCache(t *testing.T) {
-	c := &mockCodec{mockPayload{ID: 1}}
-	expectedSize := 8 // uint32(4) + [4]byte(4)
-
-	// The first call populates the cache.
-	size1 := c.Size()
-	assert.Equal(t, expectedSize, size1)
-
-	// The second call should hit the cache. We verify by checking the value.
-	// In a real-world scenario, you might benchmark this.
-	size2 := c.Size()
-	assert.Equal(t, expectedSize, size2)
-
-	// Verify the cache is shared globally.
-	var wg sync.WaitGroup
-	for i := 0; i < 100; i++ {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			c2 := &mockCodec{mockPayload{ID: 2}}
-			assert.Equal(t, expectedSize, c2.Size())
-		}()
+func TestAlignedBytes(t *testing.T) {
+	for _, align := range []int{1, 8, 64, 4096} {
+		b := AlignedBytes(100, align)
+		assert.Len(t, b, 100)
+		assert.Equal(t, 100, cap(b))
+		assert.True(t, IsAligned(b, align))
 	}
-	wg.Wait()
+	assert.True(t, IsAligned(*bufPool.Get().(*[]byte), CACHE_LINE))
+	assert.True(t, IsAligned(AlignedBytes(3, 1)[1:], 0))
+	assert.Panics(t, func() { AlignedBytes(1, 3) })
 }
 
-func TestFixedSizeCodec_Errors(t *testing.T) {
-	t.Run("MarshalToShortBuffer", func(t *testing.T) {
-		c := &mockCodec{}
-		shortBuf := make([]byte, c.Size()-1)
-		_, err := c.MarshalTo(shortBuf)
-		assert.ErrorIs(t, err, io.ErrShortWrite)
-	})
+func TestMarshalAppend(t *testing.T) {
+	a := &mockCodec{Payload: mockPayload{ID: 1, Data: [4]byte{1, 2, 3, 4}}}
+	b := NewList4([]*mockCodec{a, a})
 
-	t.Run("UnmarshalWithTruncatedData", func(t *testing.T) {
-		c := &mockCodec{}
-		validData, _ := c.MarshalBinary()
-		truncatedData := validData[:len(validData)-1]
+	buf := make([]byte, 0, 64)
+	buf = append(buf, 0xFF)
+	buf, err := Append(buf, a)
+	require.NoError(t, err)
+	buf, err = Append(buf, b)
+	require.NoError(t, err)
 
-		err := c.UnmarshalBinary(truncatedData)
-		assert.ErrorIs(t, err, ErrTruncatedData)
-	})
+	want := []byte{0xFF}
+	for _, c := range []Codec{a, b} {
+		enc, err := c.MarshalBinary()
+		require.NoError(t, err)
+		want = append(want, enc...)
+	}
+	assert.Equal(t, want, buf)
+	assert.Equal(t, 64, cap(buf), "appending within capacity must not reallocate")
 
-	t.Run("UnmarshalWithTrailingData", func(t *testing.T) {
-		c := &mockCodec{}
-		validData, _ := c.MarshalBinary()
-		trailingData := append(validData, 0x01, 0x02, 0x03) // Append non-zero bytes
+	_, ok := Codec(a).(Appender)
+	assert.True(t, ok)
+}
 
-		err := c.UnmarshalBinary(trailingData)
-		require.Error(t, err)
-		assert.Contains(t, err.Error(), "non-zero byte")
-	})
+func TestEncodeReader(t *testing.T) {
+	items := make([]*mockCodec, 1000)
+	for i := range items {
+		items[i] = &mockCodec{Payload: mockPayload{ID: uint32(i)}}
+	}
+	l := NewList0(items)
+	want, err := l.MarshalBinary()
+	require.NoError(t, err)
+
+	got, err := io.ReadAll(EncodeReader(l))
+	require.NoError(t, err)
+	assert.Equal(t, want, got)
+
+	_, err = io.ReadAll(EncodeReader(&failingCodec{}))
+	assert.ErrorIs(t, err, io.ErrShortWrite)
+
+	r := EncodeReader(l)
+	buf := make([]byte, 8)
+	_, err = io.ReadFull(r, buf)
+	require.NoError(t, err)
+	assert.NoError(t, r.Close())
+}
+
+func TestListItemsIterator(t *testing.T) {
+	items := []*mockCodec{{Payload: mockPayload{ID: 1}}, {Payload: mockPayload{ID: 2}}, {Payload: mockPayload{ID: 3}}}
+	data, err := NewList8(items).MarshalBinary()
+	require.NoError(t, err)
+
+	var ids []uint32
+	for item, err := range ListItems[*mockCodec](bytes.NewReader(data), 8) {
+		require.NoError(t, err)
+		ids = append(ids, item.Payload.ID)
+	}
+	assert.Equal(t, []uint32{1, 2, 3}, ids)
+
+	var last error
+	for _, err := range ListItems[*mockCodec](bytes.NewReader(data[:20]), 8) {
+		last = err
+	}
+	assert.ErrorIs(t, last, io.ErrUnexpectedEOF)
+}
+
+func TestDecodeEncode(t *testing.T) {
+	in := &mockCodec{Payload: mockPayload{ID: 42, Data: [4]byte{1, 2, 3, 4}}}
+	data, err := Encode(in)
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0, 0, 0, 42, 1, 2, 3, 4}, data)
+	assert.Equal(t, len(data), cap(data))
+
+	out, err := Decode[*mockCodec](bytes.NewReader(data))
+	require.NoError(t, err)
+	assert.Equal(t, in, out)
+
+	_, err = Decode[*mockCodec](bytes.NewReader(append(data, 0xFF)))
+	assert.ErrorIs(t, err, ErrTrailingData)
+	_, err = Decode[*mockCodec](bytes.NewReader(data[:5]))
+	assert.ErrorIs(t, err, ErrTruncatedData)
+
+	var buf bytes.Buffer
+	n, err := EncodeTo(&buf, in)
+	require.NoError(t, err)
+	assert.EqualValues(t, 8, n)
+	assert.Equal(t, data, buf.Bytes())
+}
+
+func TestEncodeDecodeAll(t *testing.T) {
+	header := &mockCodec{Payload: mockPayload{ID: 1}}
+	body := NewList4([]*mockCodec{{Payload: mockPayload{ID: 2}}, {Payload: mockPayload{ID: 3}}})
+	footer := &Enum[uint16]{0xFFFF}
+
+	var buf bytes.Buffer
+	n, err := EncodeAll(&buf, header, body, footer)
+	require.NoError(t, err)
+	assert.EqualValues(t, 8+16+2, n)
+
+	var h mockCodec
+	b := NewList4(make([]*mockCodec, 0, 2))
+	var f Enum[uint16]
+	r, err := NewReader(&buf)
+	require.NoError(t, err)
+	n, err = DecodeAll(r, &h, b, &f)
+	require.NoError(t, err)
+	assert.EqualValues(t, 26, n)
+	assert.Equal(t, *header, h)
+	assert.Equal(t, body.Items, b.Items)
+	assert.Equal(t, *footer, f)
+
+	_, err = DecodeAll(bytes.NewReader([]byte{0, 0}), &h)
+	assert.Error(t, err)
+}
+
+func TestEmptyPaddingMagic(t *testing.T) {
+	var buf bytes.Buffer
+	n, err := EncodeAll(&buf, Magic("RIFF"), Empty{}, Padding(3), &Enum[uint8]{7})
+	require.NoError(t, err)
+	assert.EqualValues(t, 8, n)
+	assert.Equal(t, []byte{'R', 'I', 'F', 'F', 0, 0, 0, 7}, buf.Bytes())
+
+	var v Enum[uint8]
+	_, err = DecodeAll(bytes.NewReader(buf.Bytes()), Magic("RIFF"), Empty{}, Padding(3), &v)
+	require.NoError(t, err)
+	assert.EqualValues(t, 7, v.Value)
+
+	_, err = DecodeAll(bytes.NewReader(buf.Bytes()), Magic("RIFX"))
+	var magicErr *MagicError
+	require.ErrorAs(t, err, &magicErr)
+	assert.ErrorIs(t, err, ErrMagicMismatch)
+	assert.Equal(t, []byte("RIFF"), magicErr.Got)
+
+	_, err = Padding(4).ReadFrom(bytes.NewReader([]byte{0, 0, 1, 0}))
+	assert.ErrorIs(t, err, ErrNonZeroPadding)
+	assert.NoError(t, Padding(2).UnmarshalBinary([]byte{0, 0}))
+	assert.ErrorIs(t, Padding(2).UnmarshalBinary([]byte{0}), io.ErrUnexpectedEOF)
+}
+
+func TestChecksumTrailer(t *testing.T) {
+	payload := []byte("payload")
+	var stream bytes.Buffer
+	stream.Write(payload)
+	stream.Write(binary.LittleEndian.AppendUint32(nil, crc32.ChecksumIEEE(payload)))
+
+	src, verify := CRC32Trailer(bytes.NewReader(stream.Bytes()), LE)
+	got, err := io.ReadAll(ChainReader(src, int64(len(payload)), verify))
+	require.NoError(t, err)
+	assert.Equal(t, payload, got)
+
+	corrupt := bytes.Clone(stream.Bytes())
+	corrupt[0] ^= 1
+	src, verify = CRC32Trailer(bytes.NewReader(corrupt), LE)
+	_, err = io.ReadAll(ChainReader(src, int64(len(payload)), verify))
+	assert.ErrorIs(t, err, ErrChecksumMismatch)
+
+	table := crc64.MakeTable(crc64.ECMA)
+	stream.Reset()
+	stream.Write(payload)
+	stream.Write(binary.BigEndian.AppendUint64(nil, crc64.Checksum(payload, table)))
+	src, verify = CRC64Trailer(&stream, table, BE)
+	_, err = io.Copy(io.Discard, ChainReader(src, int64(len(payload)), verify))
+	require.NoError(t, err)
+
+	src, verify = CRC64Trailer(bytes.NewReader(payload), table, BE)
+	_, err = io.ReadAll(ChainReader(src, int64(len(payload)), verify))
+	assert.ErrorIs(t, err, ErrTruncatedData)
+}
+
+func TestWriterHash(t *testing.T) {
+	var buf bytes.Buffer
+	w, _ := NewWriter(&buf)
+	w.WithHash(crc32.NewIEEE())
+	w.WriteUint8(1)
+	w.WriteString("ab")
+	w.WriteUint32(0xDEADBEEF)
+	w.ReadFrom(bytes.NewReader([]byte("cd")))
+	w.WriteBytes(w.Sum(nil))
+	require.NoError(t, w.Flush())
+	payload := buf.Bytes()[:buf.Len()-4]
+	assert.Equal(t, binary.BigEndian.AppendUint32(nil, crc32.ChecksumIEEE(payload)), buf.Bytes()[len(payload):])
+
+	w.ResetHash()
+	w.WriteString("x")
+	assert.Equal(t, binary.BigEndian.AppendUint32(nil, crc32.ChecksumIEEE([]byte("x"))), w.Sum(nil))
+
+	w.WithHash(nil)
+	w.WriteString("y")
+	assert.Nil(t, w.Sum(nil))
+}
+
+func TestReaderHash(t *testing.T) {
+	payload := []byte("header\x00\x00payload")
+	stream := binary.BigEndian.AppendUint32(bytes.Clone(payload), crc32.ChecksumIEEE(payload))
+
+	for name, src := range map[string]io.Reader{
+		"bytes":    bytes.NewReader(stream),
+		"buffered": io.MultiReader(bytes.NewReader(stream)),
+	} {
+		r, err := NewReaderSize(src, BUFFER_SIZE)
+		require.NoError(t, err)
+		r.WithHash(crc32.NewIEEE())
+		assert.Equal(t, "header", string(r.ReadBytes(6)), name)
+		r.Align(8)
+		r.Seek(3, io.SeekCurrent)
+		var b byte
+		b, _ = r.ReadByte()
+		assert.Equal(t, byte('l'), b, name)
+		io.CopyN(io.Discard, r, 3)
+		sum := r.Sum(nil)
+
+		var trailer uint32
+		r.ReadUint32(&trailer)
+		require.NoError(t, r.Err(), name)
+		assert.Equal(t, binary.BigEndian.AppendUint32(nil, trailer), sum, name)
+	}
+}
+
+func TestHMACTrailer(t *testing.T) {
+	key := []byte("update-signing-key")
+	var buf bytes.Buffer
+	w, _ := NewWriter(&buf)
+	w.WithHMAC(sha256.New, key)
+	w.WriteUint32(42)
+	w.WriteString("firmware")
+	w.WriteMAC()
+	require.NoError(t, w.Flush())
+	require.Equal(t, 12+sha256.Size, buf.Len())
+
+	verify := func(data, key []byte) error {
+		src, check := HMACTrailer(bytes.NewReader(data), sha256.New, key)
+		_, err := io.ReadAll(ChainReader(src, 12, check))
+		return err
+	}
+	require.NoError(t, verify(buf.Bytes(), key))
+	assert.ErrorIs(t, verify(buf.Bytes(), []byte("wrong")), ErrChecksumMismatch)
+	tampered := bytes.Clone(buf.Bytes())
+	tampered[3] ^= 1
+	assert.ErrorIs(t, verify(tampered, key), ErrChecksumMismatch)
+
+	w, _ = NewWriter(&bytes.Buffer{})
+	w.WriteMAC()
+	assert.Error(t, w.Err())
+}
+
+func TestReaderTrace(t *testing.T) {
+	type event struct {
+		op  string
+		off int64
+		n   int
+		v   any
+	}
+	var events []event
+	r, _ := NewReader(bytes.NewReader([]byte("\x01\x00\x02abc\xff\xff\xff\xfe")))
+	r.WithTrace(func(op string, off int64, n int, v any) { events = append(events, event{op, off, n, v}) })
+	var b bool
+	var u16 uint16
+	var s string
+	var i32 int32
+	r.ReadBool(&b)
+	r.ReadUint16(&u16)
+	r.ReadString(&s, 3)
+	r.ReadInt32(&i32)
+	r.ReadInt32(&i32) // fails, so is not traced
+	assert.Equal(t, []event{
+		{"bool", 0, 1, true},
+		{"uint16", 1, 2, uint16(2)},
+		{"string", 3, 3, "abc"},
+		{"int32", 6, 4, int32(-2)},
+	}, events)
+
+	// Interned strings are traced once, as strings.
+	events = nil
+	r, _ = NewReader(bytes.NewReader([]byte("xyz")))
+	r.WithInterner(NewInterner(0)).WithTrace(func(op string, off int64, n int, v any) { events = append(events, event{op, off, n, v}) })
+	r.ReadString(&s, 3)
+	assert.Equal(t, []event{{"string", 0, 3, "xyz"}}, events)
+}
+
+func TestDecodeError(t *testing.T) {
+	r, _ := NewReader(bytes.NewReader([]byte{1, 2, 3, 4, 5}))
+	var u16 uint16
+	var u32 uint32
+	r.ReadUint16(&u16)
+	r.PushContext("Header")
+	r.PushContext("Flags")
+	r.ReadUint32(&u32)
+	r.PopContext()
+	r.PopContext()
+
+	var de *DecodeError
+	require.ErrorAs(t, r.Err(), &de)
+	assert.Equal(t, "ReadUint32", de.Op)
+	assert.EqualValues(t, 5, de.Offset)
+	assert.Equal(t, "Header.Flags", de.Context)
+	assert.ErrorIs(t, r.Err(), io.ErrUnexpectedEOF)
+	assert.Equal(t, "codec: ReadUint32 at offset 5 in Header.Flags: unexpected EOF", de.Error())
+	_, err := r.Result()
+	assert.ErrorAs(t, err, &de)
+
+	// A clean end of stream stays io.EOF.
+	r, _ = NewReader(bytes.NewReader(nil))
+	r.ReadUint8(new(uint8))
+	assert.Equal(t, io.EOF, r.Err())
+
+	pr, pw := io.Pipe()
+	pr.Close()
+	w, _ := NewWriterSize(pw, BUFFER_SIZE)
+	w.WriteUint32(1)
+	w.Flush()
+	require.ErrorAs(t, w.Err(), &de)
+	assert.Equal(t, "Flush", de.Op)
+	assert.EqualValues(t, 4, de.Offset)
+	assert.ErrorIs(t, w.Err(), io.ErrClosedPipe)
+}
+
+func TestCheckTrailingPadding(t *testing.T) {
+	sector := make([]byte, 64<<10)
+	assert.ErrorIs(t, CheckTrailingNotZeros(bytes.NewReader(sector)), ErrTrailingData)
+	require.NoError(t, CheckTrailingNotZerosLimit(bytes.NewReader(sector), int64(len(sector))))
+	assert.ErrorIs(t, CheckTrailingNotZerosLimit(bytes.NewReader(sector), int64(len(sector)-1)), ErrTrailingData)
+
+	r, _ := NewReader(bytes.NewReader(sector))
+	require.NoError(t, CheckTrailingNotZeros(r.WithMaxPadding(64<<10)))
+
+	sector[100] = 1
+	assert.ErrorIs(t, CheckBufferNotZerosLimit(sector, 64<<10), ErrTrailingData)
+	require.NoError(t, CheckBufferNotZeros(sector[:100]))
+}
+
+func TestUint24(t *testing.T) {
+	for _, tc := range []struct {
+		order binary.ByteOrder
+		wire  []byte
+	}{
+		{BE, []byte{0x12, 0x34, 0x56}},
+		{LE, []byte{0x56, 0x34, 0x12}},
+	} {
+		var buf bytes.Buffer
+		w, _ := NewWriter(&buf)
+		w.WithByteOrder(tc.order).WriteUint24(0xFF123456) // the top byte is dropped
+		require.NoError(t, w.Flush())
+		assert.Equal(t, tc.wire, buf.Bytes())
+
+		r, _ := NewReader(&buf)
+		var v uint32
+		r.WithByteOrder(tc.order).ReadUint24(&v)
+		require.NoError(t, r.Err())
+		assert.Equal(t, uint32(0x123456), v)
+	}
+	r, _ := NewReader(bytes.NewReader([]byte{1, 2}))
+	var v uint32
+	r.ReadUint24(&v)
+	assert.ErrorIs(t, r.Err(), io.ErrUnexpectedEOF)
+}
+
+func TestSynchsafe(t *testing.T) {
+	var buf bytes.Buffer
+	w, _ := NewWriter(&buf)
+	w.WriteSynchsafe32(0x0FFFFFFF)
+	w.WriteSynchsafe32(257)
+	require.NoError(t, w.Flush())
+	assert.Equal(t, []byte{0x7F, 0x7F, 0x7F, 0x7F, 0, 0, 0x02, 0x01}, buf.Bytes())
+	w.WriteSynchsafe32(MAX_SYNCHSAFE32 + 1)
+	assert.ErrorIs(t, w.Err(), ErrLengthOverflow)
+
+	r, _ := NewReader(bytes.NewReader([]byte{0, 0, 0x02, 0x01, 0, 0x80, 0, 0}))
+	var v uint32
+	r.ReadSynchsafe32(&v)
+	require.NoError(t, r.Err())
+	assert.Equal(t, uint32(257), v)
+	r.ReadSynchsafe32(&v)
+	assert.ErrorIs(t, r.Err(), ErrInvalidSynchsafe)
+}
+
+func TestReaderPeek(t *testing.T) {
+	sources := map[string]func() io.Reader{
+		"bufio":        func() io.Reader { return io.MultiReader(bytes.NewReader([]byte("abcd"))) },
+		"bytes.Reader": func() io.Reader { return bytes.NewReader([]byte("abcd")) },
+		"bytes.Buffer": func() io.Reader { return bytes.NewBufferString("abcd") },
+		"BytesReader":  func() io.Reader { return NewBytesReader([]byte("abcd")) },
+	}
+	for name, src := range sources {
+		t.Run(name, func(t *testing.T) {
+			r, err := NewReaderSize(src(), 16)
+			require.NoError(t, err)
+			b, err := r.Peek(2)
+			require.NoError(t, err)
+			assert.Equal(t, "ab", string(b))
+			assert.Zero(t, r.Count())
+
+			var v uint16
+			r.ReadUint16(&v)
+			require.NoError(t, r.Err())
+			assert.Equal(t, uint16(0x6162), v)
+
+			b, err = r.Peek(3)
+			assert.Equal(t, io.EOF, err)
+			assert.Equal(t, "cd", string(b))
+			// A short peek is not latched.
+			assert.Equal(t, "cd", string(r.ReadBytes(2)))
+			require.NoError(t, r.Err())
+			assert.Equal(t, int64(4), r.Count())
+		})
+	}
+
+	r, _ := NewReaderSize(io.MultiReader(bytes.NewReader(make([]byte, 64))), 16)
+	_, err := r.Peek(32)
+	assert.ErrorIs(t, err, bufio.ErrBufferFull)
+}
+
+func TestReaderUnread(t *testing.T) {
+	sources := map[string]struct {
+		src  func() io.Reader
+		seek bool // backs up more than one byte
+	}{
+		"bufio":        {func() io.Reader { return io.MultiReader(bytes.NewReader([]byte("abcd"))) }, false},
+		"bufio seeker": {func() io.Reader { return struct{ io.ReadSeeker }{bytes.NewReader([]byte("abcd"))} }, true},
+		"bytes.Reader": {func() io.Reader { return bytes.NewReader([]byte("abcd")) }, true},
+		"bytes.Buffer": {func() io.Reader { return bytes.NewBufferString("abcd") }, false},
+		"BytesReader":  {func() io.Reader { return NewBytesReader([]byte("abcd")) }, true},
+	}
+	for name, tc := range sources {
+		t.Run(name, func(t *testing.T) {
+			r, err := NewReaderSize(tc.src(), 16)
+			require.NoError(t, err)
+			assert.ErrorIs(t, r.UnreadByte(), ErrInvalidUnread, "nothing read yet")
+			var v uint8
+			r.ReadUint8(&v)
+			r.ReadUint8(&v)
+			require.NoError(t, r.UnreadByte())
+			assert.Equal(t, int64(1), r.Count())
+			r.ReadUint8(&v)
+			assert.Equal(t, uint8('b'), v)
+
+			r.ReadBytes(1)
+			err = r.UnreadBytes(2)
+			if !tc.seek {
+				assert.ErrorIs(t, err, ErrUnsupportedNegativeSeek)
+				require.NoError(t, r.UnreadBytes(1))
+				assert.Equal(t, "cd", string(r.ReadBytes(2)))
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, int64(1), r.Count())
+			assert.Equal(t, "bcd", string(r.ReadBytes(3)))
+			assert.ErrorIs(t, r.UnreadBytes(5), ErrInvalidUnread)
+			require.NoError(t, r.Err())
+		})
+	}
+}
+
+func TestReaderTransaction(t *testing.T) {
+	// A stream that cannot seek back.
+	r, err := NewReaderSize(io.MultiReader(bytes.NewReader([]byte("\x00\x01\x02\x03\x04\x05"))), 16)
+	require.NoError(t, err)
+	assert.ErrorIs(t, r.Commit(), ErrNoTransaction)
+
+	var v uint32
+	r.Begin()
+	r.PushContext("formatA")
+	r.ReadUint32(&v)
+	r.ReadUint32(&v)
+	assert.ErrorIs(t, r.Err(), io.ErrUnexpectedEOF)
+	require.NoError(t, r.Rollback())
+	require.NoError(t, r.Err())
+	assert.Zero(t, r.Count())
+	assert.Empty(t, r.context)
+
+	r.Begin()
+	var b uint8
+	r.ReadUint8(&b)
+	r.Begin()
+	assert.Equal(t, []byte{1, 2}, r.ReadBytes(2))
+	require.NoError(t, r.Rollback())
+	assert.Equal(t, int64(1), r.Count())
+	b2, err := r.Peek(3)
+	require.NoError(t, err)
+	assert.Equal(t, []byte{1, 2, 3}, b2)
+	r.ReadUint32(&v)
+	assert.Equal(t, uint32(0x01020304), v)
+	require.NoError(t, r.Commit())
+	assert.ErrorIs(t, r.Rollback(), ErrNoTransaction)
+	require.NoError(t, r.UnreadBytes(2))
+	var rest bytes.Buffer
+	_, err = r.WriteTo(&rest)
+	require.NoError(t, err)
+	assert.Equal(t, []byte{3, 4, 5}, rest.Bytes())
+	assert.Equal(t, int64(6), r.Count())
+
+	// After a Rollback the bytes before the replayed ones are gone, so
+	// UnreadByte must not back up the underlying stream instead.
+	r, err = NewReaderSize(io.MultiReader(bytes.NewReader([]byte("\x00\x01\x02"))), 16)
+	require.NoError(t, err)
+	r.ReadUint8(&b)
+	r.Begin()
+	r.ReadBytes(2)
+	require.NoError(t, r.Rollback())
+	assert.ErrorIs(t, r.UnreadByte(), ErrInvalidUnread)
+	assert.Equal(t, []byte{1, 2}, r.ReadBytes(2))
+	require.NoError(t, r.Err())
+}
+
+func TestReaderTransactionHash(t *testing.T) {
+	data := []byte("speculative parse")
+	for _, hashFirst := range []bool{true, false} {
+		r, err := NewReaderSize(io.MultiReader(bytes.NewReader(data)), 16)
+		require.NoError(t, err)
+		h := crc32.NewIEEE()
+		if hashFirst {
+			r.WithHash(h)
+			r.Begin()
+		} else {
+			r.Begin()
+			r.WithHash(h)
+		}
+		r.ReadBytes(11)
+		require.NoError(t, r.Rollback())
+		assert.Equal(t, data, r.ReadBytes(len(data)))
+		assert.Equal(t, crc32.ChecksumIEEE(data), h.Sum32(), "hash first: %v", hashFirst)
+
+		// Removing the hash stops it, whichever layer is on top.
+		r.WithHash(nil)
+		assert.Same(t, r.tx, r.r)
+		_, isHash := r.tx.ReaderPro.(*hashReader)
+		assert.False(t, isHash)
+	}
+}
+
+func TestWriterReserve(t *testing.T) {
+	// A destination that cannot be patched holds output until every
+	// placeholder is set.
+	var buf bytes.Buffer
+	w, _ := NewWriter(struct{ io.Writer }{&buf})
+	w.WriteUint8(0xAA)
+	outer := w.Reserve(4)
+	inner := w.Reserve(2)
+	w.WriteString("body")
+	require.NoError(t, inner.SetUint16(4))
+	require.NoError(t, w.Flush())
+	assert.Equal(t, []byte{0xAA}, buf.Bytes())
+	require.NoError(t, outer.SetUint32(uint32(w.Count()-outer.Offset()-4)))
+	w.WriteUint8(0xBB)
+	require.NoError(t, w.Flush())
+	assert.Equal(t, []byte("\xaa\x00\x00\x00\x06\x00\x04body\xbb"), buf.Bytes())
+	assert.ErrorIs(t, outer.SetUint32(0), ErrNotPatchable)
+
+	// Patchable destinations are filled in place.
+	buf.Reset()
+	w, _ = NewWriter(&buf)
+	w.WithByteOrder(LE)
+	p := w.Reserve(3)
+	w.WriteString("xy")
+	assert.Equal(t, 5, buf.Len())
+	require.NoError(t, p.SetUint24(0x010203))
+	assert.Equal(t, "\x03\x02\x01xy", buf.String())
+	assert.ErrorIs(t, p.SetUint16(1), ErrPlaceholderSize)
+}
+
+func TestWriterLengthPrefixed(t *testing.T) {
+	for _, dst := range []func(*bytes.Buffer) io.Writer{
+		func(b *bytes.Buffer) io.Writer { return b },
+		func(b *bytes.Buffer) io.Writer { return struct{ io.Writer }{b} },
+	} {
+		var buf bytes.Buffer
+		w, _ := NewWriter(dst(&buf))
+		w.BeginLengthPrefixed(4)
+		w.WriteString("ab")
+		w.BeginLengthPrefixed(1)
+		w.WriteString("cde")
+		require.NoError(t, w.EndLengthPrefixed())
+		require.NoError(t, w.EndLengthPrefixed())
+		require.NoError(t, w.Flush())
+		assert.Equal(t, "\x00\x00\x00\x06ab\x03cde", buf.String())
+		assert.ErrorIs(t, w.EndLengthPrefixed(), ErrNoLengthPrefix)
+	}
+
+	var buf bytes.Buffer
+	w, _ := NewWriter(&buf)
+	w.BeginLengthPrefixed(1)
+	w.WriteZeros(256)
+	assert.ErrorIs(t, w.EndLengthPrefixed(), ErrLengthOverflow)
+	w, _ = NewWriter(&buf)
+	w.BeginLengthPrefixed(5)
+	assert.ErrorIs(t, w.Err(), ErrLengthOverflow)
+}
+
+// memFile is an in-memory io.WriterAt.
+type memFile []byte
+
+func (m memFile) WriteAt(p []byte, off int64) (int, error) {
+	if off >= int64(len(m)) {
+		return 0, io.ErrShortWrite
+	}
+	n := copy(m[off:], p)
+	if n < len(p) {
+		return n, io.ErrShortWrite
+	}
+	return n, nil
+}
+
+func TestSectionWriter(t *testing.T) {
+	file := memFile(bytes.Repeat([]byte{'.'}, 16))
+	s := NewSectionWriter(file, 4, 8)
+	assert.Equal(t, int64(8), s.Size())
+	n, err := s.Write([]byte("abc"))
+	require.NoError(t, err)
+	assert.Equal(t, 3, n)
+	n, err = s.Write([]byte("defghijk"))
+	assert.ErrorIs(t, err, ErrSectionFull)
+	assert.Equal(t, 5, n)
+	assert.Equal(t, "....abcdefgh....", string(file))
+
+	_, err = s.Write([]byte("x"))
+	assert.ErrorIs(t, err, ErrSectionFull)
+	pos, err := s.Seek(-2, io.SeekEnd)
+	require.NoError(t, err)
+	assert.Equal(t, int64(6), pos)
+	_, err = s.Seek(-1, io.SeekStart)
+	assert.ErrorIs(t, err, ErrInvalidSeek)
+
+	// A Writer over the section latches overflow and patches in place.
+	file = memFile(bytes.Repeat([]byte{'.'}, 16))
+	w, err := NewWriter(NewSectionWriter(file, 2, 6))
+	require.NoError(t, err)
+	require.True(t, w.CanPatch())
+	p := w.Reserve(2)
+	w.WriteBytes([]byte("ab"))
+	require.NoError(t, p.SetBytes([]byte("LL")))
+	require.NoError(t, w.Flush())
+	assert.Equal(t, "..LLab..........", string(file))
+	w.WriteBytes([]byte("cdef"))
+	assert.ErrorIs(t, w.Flush(), ErrSectionFull)
+	assert.ErrorIs(t, w.Err(), ErrSectionFull)
+}
+
+func TestReaderSection(t *testing.T) {
+	// Two records, each a uint16 length and a body with a uint16 field the
+	// reader knows and trailing bytes it does not.
+	data := []byte{0, 4, 0, 1, 0xAA, 0xBB, 0, 2, 0, 2, 0xFF}
+	r, err := NewReader(bytes.NewReader(data))
+	require.NoError(t, err)
+
+	var length, v uint16
+	r.ReadUint16(&length)
+	sec := r.Section(int64(length))
+	sec.ReadUint16(&v)
+	assert.Equal(t, uint16(1), v)
+	assert.Equal(t, int64(2), sec.Count())
+	require.NoError(t, sec.Close())
+	assert.Equal(t, int64(6), r.Count())
+
+	r.ReadUint16(&length)
+	sec = r.Section(int64(length))
+	sec.ReadUint16(&v)
+	assert.Equal(t, uint16(2), v)
+	sec.ReadUint16(&v)
+	assert.ErrorIs(t, sec.Err(), io.ErrUnexpectedEOF)
+	require.NoError(t, sec.Close())
+	require.NoError(t, r.Err())
+	assert.Equal(t, int64(10), r.Count())
+	assert.Equal(t, []byte{0xFF}, r.ReadBytes(1))
+
+	// A strict section fails on unread bytes, and still skips them.
+	r, _ = NewReader(bytes.NewReader(data))
+	r.ReadUint16(&length)
+	sec = r.StrictSection(int64(length))
+	sec.ReadUint16(&v)
+	assert.ErrorIs(t, sec.Close(), ErrSectionRemainder)
+	assert.ErrorIs(t, r.Err(), ErrSectionRemainder)
+	assert.Equal(t, int64(6), r.Count())
+
+	// A section longer than the stream is truncated.
+	r, _ = NewReader(bytes.NewReader(data))
+	sec = r.Section(20)
+	assert.Len(t, sec.ReadBytes(11), 11)
+	sec.ReadBytes(1)
+	assert.ErrorIs(t, sec.Err(), io.ErrUnexpectedEOF)
+	assert.ErrorIs(t, sec.Close(), io.ErrUnexpectedEOF)
+}
+
+func TestReaderAt(t *testing.T) {
+	// A table of two uint32 offsets, each pointing to a uint16 record.
+	data := []byte{0, 0, 0, 10, 0, 0, 0, 8, 0xBE, 0xEF, 0xCA, 0xFE}
+	r, err := NewReaderAt(bytes.NewReader(data), int64(len(data)))
+	require.NoError(t, err)
+	require.True(t, r.CanReadAt())
+
+	var off uint32
+	var v uint16
+	r.ReadUint32(&off)
+	r.ReadUint16At(&v, int64(off))
+	assert.Equal(t, uint16(0xCAFE), v)
+	r.ReadUint32(&off)
+	r.ReadUint16At(&v, int64(off))
+	assert.Equal(t, uint16(0xBEEF), v)
+	require.NoError(t, r.Err())
+	assert.Equal(t, int64(8), r.Count())
+	assert.Equal(t, []byte{0xBE, 0xEF}, r.ReadBytes(2))
+
+	// Sources with random access are detected by NewReader.
+	r, _ = NewReader(NewBytesReader(data))
+	require.True(t, r.CanReadAt())
+	var u64 uint64
+	r.ReadUint64At(&u64, 4)
+	assert.Equal(t, uint64(0x00000008BEEFCAFE), u64)
+	p := make([]byte, 4)
+	n, err := r.ReadAt(p, 10)
+	assert.Equal(t, 2, n)
+	assert.ErrorIs(t, err, io.EOF)
+	require.NoError(t, r.Err())
+	r.ReadUint32At(new(uint32), 10)
+	assert.ErrorIs(t, r.Err(), io.ErrUnexpectedEOF)
+
+	r, _ = NewReader(bytes.NewBuffer(data))
+	assert.False(t, r.CanReadAt())
+	r.ReadUint8At(new(uint8), 0)
+	assert.ErrorIs(t, r.Err(), errors.ErrUnsupported)
+}
+
+func TestMapFile(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "data")
+	require.NoError(t, os.WriteFile(name, []byte{0, 0, 0, 42, 'h', 'i'}, 0o644))
+
+	br, err := MapFile(name)
+	require.NoError(t, err)
+	require.NoError(t, br.Advise(MapRandom))
+	r, err := NewReader(br)
+	require.NoError(t, err)
+	var v uint32
+	r.ReadUint32(&v)
+	assert.Equal(t, uint32(42), v)
+	assert.Equal(t, []byte("hi"), br.Next(2))
+	require.NoError(t, r.Close())
+	assert.Nil(t, br.B)
+	require.NoError(t, br.Close())
+
+	empty := filepath.Join(t.TempDir(), "empty")
+	require.NoError(t, os.WriteFile(empty, nil, 0o644))
+	br, err = MapFile(empty)
+	require.NoError(t, err)
+	assert.Equal(t, 0, br.Size())
+	require.NoError(t, br.Close())
+
+	_, err = MapFile(filepath.Join(t.TempDir(), "missing"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
 }
@@ -0,0 +1,246 @@
+// Package codectest scripts protocol exchanges against a live handler.
+//
+// A Script plays the remote peer: it sends codecs, expects codecs back and
+// checks their fields, all over a net.Pipe using codec.Reader and
+// codec.Writer for the wire. Protocol handlers get readable integration tests
+// without hand-crafted byte fixtures:
+//
+//	codectest.New().
+//		Send(&codec.Fixed[Hello]{Payload: Hello{Version: 1}}).
+//		Expect(&codec.Fixed[Welcome]{}, codectest.Field("Status", 0)).
+//		Run(t, server.Serve)
+package codectest
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/oy3o/codec"
+)
+
+// DEFAULT_TIMEOUT bounds a whole script run, so a handler that stops
+// responding fails the test instead of hanging it.
+const DEFAULT_TIMEOUT = 5 * time.Second
+
+// Check inspects a decoded codec and reports a mismatch.
+type Check func(c codec.Codec) error
+
+// step is one scripted action of the peer.
+type step struct {
+	desc string
+	run  func(r *codec.Reader, w *codec.Writer) error
+}
+
+// Script is an ordered exchange played against a handler.
+type Script struct {
+	steps   []step
+	timeout time.Duration
+}
+
+// New returns an empty script.
+func New() *Script {
+	return &Script{timeout: DEFAULT_TIMEOUT}
+}
+
+// WithTimeout sets the deadline for the whole run.
+func (s *Script) WithTimeout(d time.Duration) *Script {
+	s.timeout = d
+	return s
+}
+
+// Send writes c to the handler.
+func (s *Script) Send(c codec.Codec) *Script {
+	return s.add(fmt.Sprintf("send %T", c), func(r *codec.Reader, w *codec.Writer) error {
+		w.WriteFrom(c)
+		return w.Flush()
+	})
+}
+
+// Respond is an alias for Send that reads naturally after Expect.
+func (s *Script) Respond(c codec.Codec) *Script {
+	return s.Send(c)
+}
+
+// SendBytes writes raw bytes to the handler.
+func (s *Script) SendBytes(p []byte) *Script {
+	return s.add(fmt.Sprintf("send %d bytes", len(p)), func(r *codec.Reader, w *codec.Writer) error {
+		w.Write(p)
+		return w.Flush()
+	})
+}
+
+// Expect decodes the next message from the handler into c and runs checks on it.
+func (s *Script) Expect(c codec.Codec, checks ...Check) *Script {
+	return s.add(fmt.Sprintf("expect %T", c), func(r *codec.Reader, w *codec.Writer) error {
+		if _, err := c.ReadFrom(r); err != nil {
+			return err
+		}
+		for _, check := range checks {
+			if err := check(c); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// ExpectBytes reads len(p) bytes from the handler and compares them to p.
+func (s *Script) ExpectBytes(p []byte) *Script {
+	return s.add(fmt.Sprintf("expect %d bytes", len(p)), func(r *codec.Reader, w *codec.Writer) error {
+		got := make([]byte, len(p))
+		if _, err := io.ReadFull(r, got); err != nil {
+			return err
+		}
+		if !bytes.Equal(got, p) {
+			return fmt.Errorf("got % x, want % x", got, p)
+		}
+		return nil
+	})
+}
+
+// ExpectClose expects the handler to close the connection.
+func (s *Script) ExpectClose() *Script {
+	return s.add("expect close", func(r *codec.Reader, w *codec.Writer) error {
+		var b [1]byte
+		n, err := r.Read(b[:])
+		if n > 0 {
+			return fmt.Errorf("got byte %#02x, want close", b[0])
+		}
+		if err == io.EOF {
+			return nil
+		}
+		return err
+	})
+}
+
+func (s *Script) add(desc string, run func(r *codec.Reader, w *codec.Writer) error) *Script {
+	s.steps = append(s.steps, step{desc: desc, run: run})
+	return s
+}
+
+// Exec plays the script against handler, which serves the other end of a
+// net.Pipe. It returns the first step failure or the handler's error. A
+// handler returning io.EOF or io.ErrClosedPipe after the script hangs up is
+// not a failure.
+func (s *Script) Exec(handler func(conn net.Conn) error) error {
+	peer, conn := net.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		err := handler(conn)
+		conn.Close()
+		done <- err
+	}()
+
+	peer.SetDeadline(time.Now().Add(s.timeout))
+	r, err := codec.NewReaderSize(peer, codec.BUFFER_SIZE)
+	if err != nil {
+		peer.Close()
+		return err
+	}
+	w, err := codec.NewWriterSize(peer, codec.BUFFER_SIZE)
+	if err != nil {
+		peer.Close()
+		return err
+	}
+
+	var stepErr error
+	for i, st := range s.steps {
+		if err := st.run(r, w); err != nil {
+			stepErr = fmt.Errorf("step %d (%s): %w", i+1, st.desc, err)
+			break
+		}
+	}
+	peer.Close()
+
+	herr := <-done
+	if errors.Is(herr, io.EOF) || errors.Is(herr, io.ErrClosedPipe) {
+		herr = nil
+	}
+	if stepErr != nil {
+		if herr != nil {
+			return fmt.Errorf("%w (handler: %w)", stepErr, herr)
+		}
+		return stepErr
+	}
+	if herr != nil {
+		return fmt.Errorf("handler: %w", herr)
+	}
+	return nil
+}
+
+// Run plays the script against handler and fails t on any error.
+func (s *Script) Run(t testing.TB, handler func(conn net.Conn) error) {
+	t.Helper()
+	if err := s.Exec(handler); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Field checks that the field at the dotted path equals want. Lookups pass
+// through pointers and through the Payload or Value field of wrapper codecs
+// such as codec.Fixed, so Field("Op", 3) addresses Fixed[T].Payload.Op. want
+// is converted to the field's type when possible, so untyped constants match
+// any integer width.
+func Field(path string, want any) Check {
+	return func(c codec.Codec) error {
+		v := reflect.ValueOf(c)
+		for _, name := range strings.Split(path, ".") {
+			f, ok := lookup(v, name)
+			if !ok {
+				return fmt.Errorf("%T has no field %q", c, path)
+			}
+			v = f
+		}
+
+		w := reflect.ValueOf(want)
+		if w.IsValid() && w.Type() != v.Type() && w.Type().ConvertibleTo(v.Type()) {
+			w = w.Convert(v.Type())
+		}
+		if !w.IsValid() || !reflect.DeepEqual(v.Interface(), w.Interface()) {
+			return fmt.Errorf("field %s = %v, want %v", path, v.Interface(), want)
+		}
+		return nil
+	}
+}
+
+// lookup finds the named field in v, descending into wrapper fields.
+func lookup(v reflect.Value, name string) (reflect.Value, bool) {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return reflect.Value{}, false
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return reflect.Value{}, false
+	}
+	if f := v.FieldByName(name); f.IsValid() {
+		return f, true
+	}
+	for _, wrapper := range []string{"Payload", "Value"} {
+		if f := v.FieldByName(wrapper); f.IsValid() {
+			if found, ok := lookup(f, name); ok {
+				return found, true
+			}
+		}
+	}
+	return reflect.Value{}, false
+}
+
+// Match runs fn on the decoded codec for checks that Field cannot express.
+func Match[T codec.Codec](fn func(T) error) Check {
+	return func(c codec.Codec) error {
+		v, ok := c.(T)
+		if !ok {
+			return fmt.Errorf("got %T, want %T", c, *new(T))
+		}
+		return fn(v)
+	}
+}
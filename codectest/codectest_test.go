@@ -0,0 +1,81 @@
+//go:build test && !tinygo && !codec_tiny
+
+package codectest
+
+import (
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/oy3o/codec"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type request struct {
+	Op  uint16
+	Arg uint32
+}
+
+type reply struct {
+	Op     uint16
+	Result uint32
+}
+
+// doubler answers every request with twice its argument.
+func doubler(conn net.Conn) error {
+	for {
+		var req codec.Fixed[request]
+		if _, err := req.ReadFrom(conn); err != nil {
+			return err
+		}
+		resp := codec.Fixed[reply]{Payload: reply{Op: req.Payload.Op, Result: req.Payload.Arg * 2}}
+		if _, err := resp.WriteTo(conn); err != nil {
+			return err
+		}
+	}
+}
+
+func TestScript(t *testing.T) {
+	New().
+		Send(&codec.Fixed[request]{Payload: request{Op: 1, Arg: 21}}).
+		Expect(&codec.Fixed[reply]{}, Field("Op", 1), Field("Result", 42)).
+		SendBytes([]byte{0, 2, 0, 0, 0, 5}).
+		ExpectBytes([]byte{0, 2, 0, 0, 0, 10}).
+		Run(t, doubler)
+
+	err := New().
+		Send(&codec.Fixed[request]{Payload: request{Op: 1, Arg: 1}}).
+		Expect(&codec.Fixed[reply]{}, Field("Result", 3)).
+		Exec(doubler)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "step 2")
+	assert.Contains(t, err.Error(), "Result = 2, want 3")
+
+	err = New().
+		Send(&codec.Fixed[request]{}).
+		Expect(&codec.Fixed[reply]{}, Field("Missing", 0)).
+		Exec(doubler)
+	assert.ErrorContains(t, err, `no field "Missing"`)
+
+	err = New().
+		Send(&codec.Fixed[request]{Payload: request{Arg: 4}}).
+		Expect(&codec.Fixed[reply]{}, Match(func(r *codec.Fixed[reply]) error {
+			if r.Payload.Result%2 != 0 {
+				return errors.New("odd")
+			}
+			return nil
+		})).
+		Exec(doubler)
+	assert.NoError(t, err)
+}
+
+func TestScriptHandlerClose(t *testing.T) {
+	boom := errors.New("boom")
+	New().ExpectClose().Run(t, func(conn net.Conn) error { return nil })
+
+	err := New().
+		Expect(&codec.Fixed[reply]{}).
+		Exec(func(conn net.Conn) error { return boom })
+	assert.ErrorIs(t, err, boom)
+}
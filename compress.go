@@ -0,0 +1,269 @@
+package codec
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+)
+
+// Compression creates the streams of one compression method.
+type Compression struct {
+	NewWriter func(w io.Writer) (io.WriteCloser, error)
+	NewReader func(r io.Reader) (io.ReadCloser, error)
+}
+
+var compressions sync.Map // map[string]Compression
+
+func init() {
+	RegisterCompression("flate", Compression{
+		NewWriter: func(w io.Writer) (io.WriteCloser, error) { return flate.NewWriter(w, flate.DefaultCompression) },
+		NewReader: func(r io.Reader) (io.ReadCloser, error) { return flate.NewReader(r), nil },
+	})
+	RegisterCompression("gzip", Compression{
+		NewWriter: func(w io.Writer) (io.WriteCloser, error) { return gzip.NewWriter(w), nil },
+		NewReader: func(r io.Reader) (io.ReadCloser, error) {
+			zr, err := gzip.NewReader(r)
+			if err != nil {
+				return nil, err
+			}
+			// A section holds one member; do not read past it looking for more.
+			zr.Multistream(false)
+			return zr, nil
+		},
+	})
+	RegisterCompression("zlib", Compression{
+		NewWriter: func(w io.Writer) (io.WriteCloser, error) { return zlib.NewWriter(w), nil },
+		NewReader: func(r io.Reader) (io.ReadCloser, error) { return zlib.NewReader(r) },
+	})
+}
+
+// RegisterCompression makes a compression method available to CompressWriter
+// and CompressReader under name. "flate", "gzip" and "zlib" are built in;
+// registering a name again replaces the previous method.
+func RegisterCompression(name string, c Compression) {
+	compressions.Store(name, c)
+}
+
+func compressionOf(name string) (Compression, error) {
+	c, ok := compressions.Load(name)
+	if !ok {
+		return Compression{}, fmt.Errorf("%w: %q", ErrUnknownCompression, name)
+	}
+	return c.(Compression), nil
+}
+
+// CompressedWriter is a Writer whose bytes are compressed into another Writer.
+// Its Count is the number of uncompressed bytes written; Compressed is the
+// number of bytes the section occupies in the destination.
+type CompressedWriter struct {
+	*Writer
+	zw    io.WriteCloser
+	dst   *Writer
+	start int64
+	end   int64 // destination count at Close, -1 while open
+}
+
+// CompressWriter starts a section of dst compressed with the named method.
+// Close ends the section, after which writing continues on dst.
+func CompressWriter(dst *Writer, method string) (*CompressedWriter, error) {
+	c, err := compressionOf(method)
+	if err != nil {
+		return nil, err
+	}
+	zw, err := c.NewWriter(dst)
+	if err != nil {
+		return nil, err
+	}
+	w, err := NewWriterSize(zw, BUFFER_SIZE)
+	if err != nil {
+		return nil, err
+	}
+	return &CompressedWriter{Writer: w.WithByteOrder(dst.order), zw: zw, dst: dst, start: dst.Count(), end: -1}, nil
+}
+
+// Compressed returns the number of compressed bytes written to the destination
+// so far, or the size of the whole section once Close returns.
+func (cw *CompressedWriter) Compressed() int64 {
+	if cw.end >= 0 {
+		return cw.end - cw.start
+	}
+	return cw.dst.Count() - cw.start
+}
+
+// Close flushes and terminates the compressed stream. It does not close the
+// destination.
+func (cw *CompressedWriter) Close() error {
+	cw.Flush()
+	if cw.end < 0 {
+		cw.setError(cw.zw.Close())
+		cw.end = cw.dst.Count()
+	}
+	return cw.Err()
+}
+
+// CompressedReader is a Reader over a compressed section of another Reader.
+// Its Count is the number of uncompressed bytes read; Compressed is the
+// number of bytes consumed from the source.
+type CompressedReader struct {
+	*Reader
+	zr    io.ReadCloser
+	src   *Reader
+	start int64
+}
+
+// CompressReader starts reading a section of src compressed with the named
+// method. Decompressors that read through io.ByteReader, as flate, gzip and
+// zlib do, stop at the end of the compressed stream, so reading can continue
+// on src after the section.
+func CompressReader(src *Reader, method string) (*CompressedReader, error) {
+	c, err := compressionOf(method)
+	if err != nil {
+		return nil, err
+	}
+	start := src.Count()
+	zr, err := c.NewReader(src)
+	if err != nil {
+		return nil, err
+	}
+	r, err := NewReaderSize(zr, BUFFER_SIZE)
+	if err != nil {
+		return nil, err
+	}
+	return &CompressedReader{Reader: r.WithByteOrder(src.order), zr: zr, src: src, start: start}, nil
+}
+
+// Compressed returns the number of compressed bytes consumed from the source.
+func (cr *CompressedReader) Compressed() int64 { return cr.src.Count() - cr.start }
+
+// Close releases the decompressor. It does not close the source.
+func (cr *CompressedReader) Close() error {
+	return cr.zr.Close()
+}
+
+// CompressionID identifies a Compressor in frame headers. IDs are a wire
+// format: peers must agree on what each ID means.
+type CompressionID uint8
+
+const (
+	CompressNone   CompressionID = iota // frames stored as is
+	CompressFlate                       // built in
+	CompressGzip                        // built in
+	CompressZlib                        // built in
+	CompressZstd                        // reserved, register an implementation
+	CompressSnappy                      // registered by importing package snappy
+	CompressLZ4                         // registered by importing package lz4
+)
+
+// Compressor compresses whole buffers, such as the frames of a Framer. Both
+// methods append to dst. Decompress must fail with ErrLengthOverflow rather
+// than produce more than limit bytes.
+type Compressor interface {
+	Compress(dst, src []byte) ([]byte, error)
+	Decompress(dst, src []byte, limit int) ([]byte, error)
+}
+
+var compressors sync.Map // map[CompressionID]Compressor
+
+func init() {
+	RegisterCompressor(CompressNone, identity{})
+	for id, name := range map[CompressionID]string{CompressFlate: "flate", CompressGzip: "gzip", CompressZlib: "zlib"} {
+		c, _ := compressionOf(name)
+		RegisterCompressor(id, StreamCompressor(c))
+	}
+}
+
+// RegisterCompressor makes c available under id, so this package can frame
+// with zstd, snappy or LZ4 without depending on them:
+//
+//	codec.RegisterCompressor(codec.CompressZstd, zstdCompressor{})
+//
+// Registering an ID again replaces the previous Compressor.
+func RegisterCompressor(id CompressionID, c Compressor) {
+	compressors.Store(id, c)
+}
+
+// compressorOf returns the Compressor registered under id.
+func compressorOf(id CompressionID) (Compressor, error) {
+	c, ok := compressors.Load(id)
+	if !ok {
+		return nil, fmt.Errorf("%w: id %d", ErrUnknownCompression, id)
+	}
+	return c.(Compressor), nil
+}
+
+// Compressors returns the registered IDs in ascending order, for a peer to
+// advertise what it can decompress.
+func Compressors() []CompressionID {
+	var ids []CompressionID
+	compressors.Range(func(id, _ any) bool {
+		ids = append(ids, id.(CompressionID))
+		return true
+	})
+	slices.Sort(ids)
+	return ids
+}
+
+// Negotiate returns the first ID of preferred that is registered locally and
+// offered by the peer, or CompressNone and false if there is none.
+func Negotiate(preferred, offered []CompressionID) (CompressionID, bool) {
+	for _, id := range preferred {
+		if _, err := compressorOf(id); err == nil && slices.Contains(offered, id) {
+			return id, true
+		}
+	}
+	return CompressNone, false
+}
+
+// identity is the Compressor of CompressNone.
+type identity struct{}
+
+func (identity) Compress(dst, src []byte) ([]byte, error) { return append(dst, src...), nil }
+
+func (identity) Decompress(dst, src []byte, limit int) ([]byte, error) {
+	if len(src) > limit {
+		return dst, fmt.Errorf("%w: %d bytes exceed %d", ErrLengthOverflow, len(src), limit)
+	}
+	return append(dst, src...), nil
+}
+
+// StreamCompressor adapts a streaming Compression to a Compressor.
+func StreamCompressor(c Compression) Compressor { return streamCompressor{c} }
+
+type streamCompressor struct{ c Compression }
+
+func (s streamCompressor) Compress(dst, src []byte) ([]byte, error) {
+	buf := bytes.NewBuffer(dst)
+	zw, err := s.c.NewWriter(buf)
+	if err != nil {
+		return dst, err
+	}
+	if _, err := zw.Write(src); err != nil {
+		return dst, err
+	}
+	if err := zw.Close(); err != nil {
+		return dst, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (s streamCompressor) Decompress(dst, src []byte, limit int) ([]byte, error) {
+	zr, err := s.c.NewReader(bytes.NewReader(src))
+	if err != nil {
+		return dst, err
+	}
+	defer zr.Close()
+	buf := bytes.NewBuffer(dst)
+	n, err := buf.ReadFrom(io.LimitReader(zr, int64(limit)+1))
+	if err != nil {
+		return dst, err
+	}
+	if n > int64(limit) {
+		return dst, fmt.Errorf("%w: decompressed frame exceeds %d bytes", ErrLengthOverflow, limit)
+	}
+	return buf.Bytes(), nil
+}
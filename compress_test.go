@@ -0,0 +1,112 @@
+//go:build test
+
+package codec
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompressSection(t *testing.T) {
+	payload := bytes.Repeat([]byte("compressible "), 100)
+	for _, method := range []string{"flate", "gzip", "zlib"} {
+		var buf bytes.Buffer
+		w, _ := NewWriter(&buf)
+		w.WriteUint32(0xCAFEBABE)
+		cw, err := CompressWriter(w, method)
+		require.NoError(t, err)
+		cw.WriteUint16(uint16(len(payload)))
+		cw.WriteBytes(payload)
+		require.NoError(t, cw.Close())
+		assert.EqualValues(t, 2+len(payload), cw.Count())
+		assert.Less(t, cw.Compressed(), cw.Count())
+		w.WriteUint16(0xBEEF)
+		require.NoError(t, w.Flush())
+		assert.Equal(t, 4+cw.Compressed()+2, w.Count())
+
+		r, _ := NewReader(&buf)
+		var magic uint32
+		r.ReadUint32(&magic)
+		assert.EqualValues(t, 0xCAFEBABE, magic)
+		cr, err := CompressReader(r, method)
+		require.NoError(t, err, method)
+		var n uint16
+		cr.ReadUint16(&n)
+		got := cr.ReadBytes(int(n))
+		require.NoError(t, cr.Err(), method)
+		assert.Equal(t, payload, got)
+		_, err = cr.Read(make([]byte, 1))
+		assert.Equal(t, io.EOF, err)
+		require.NoError(t, cr.Close())
+		assert.Equal(t, cw.Compressed(), cr.Compressed(), method)
+
+		var trailer uint16
+		r.ReadUint16(&trailer)
+		require.NoError(t, r.Err())
+		assert.EqualValues(t, 0xBEEF, trailer, method)
+	}
+
+	w, _ := NewWriter(&bytes.Buffer{})
+	_, err := CompressWriter(w, "lzma")
+	assert.ErrorIs(t, err, ErrUnknownCompression)
+}
+
+// reverse is a toy Compressor standing in for a registered third-party one.
+type reverse struct{}
+
+func (reverse) Compress(dst, src []byte) ([]byte, error) {
+	for i := len(src) - 1; i >= 0; i-- {
+		dst = append(dst, src[i])
+	}
+	return dst, nil
+}
+
+func (r reverse) Decompress(dst, src []byte, limit int) ([]byte, error) {
+	if len(src) > limit {
+		return dst, ErrLengthOverflow
+	}
+	return r.Compress(dst, src)
+}
+
+func TestFramerCompression(t *testing.T) {
+	RegisterCompressor(CompressLZ4, reverse{})
+	defer compressors.Delete(CompressLZ4)
+
+	id, ok := Negotiate([]CompressionID{CompressZstd, CompressLZ4, CompressFlate}, []CompressionID{CompressFlate, CompressLZ4})
+	assert.True(t, ok)
+	assert.Equal(t, CompressLZ4, id)
+	_, ok = Negotiate([]CompressionID{CompressZstd}, Compressors())
+	assert.False(t, ok)
+	assert.Contains(t, Compressors(), CompressGzip)
+
+	payload := bytes.Repeat([]byte("frame "), 50)
+	var buf bytes.Buffer
+	for _, id := range []CompressionID{CompressNone, CompressFlate, CompressLZ4} {
+		f := NewFramer(&buf, &buf).WithCompression(id).WithChecksum(NewCRC32())
+		require.NoError(t, f.WriteFrame(payload))
+		require.NoError(t, f.WriteCodec(&mockCodec{Payload: mockPayload{ID: 9}}))
+	}
+	assert.Less(t, buf.Len(), 3*len(payload))
+
+	// The reader decodes whatever registered method each frame names.
+	f := NewFramer(&buf, nil).WithCompression(CompressNone).WithChecksum(NewCRC32())
+	for range 3 {
+		p, err := f.ReadFrame()
+		require.NoError(t, err)
+		assert.Equal(t, payload, p)
+		var m mockCodec
+		require.NoError(t, f.ReadCodec(&m))
+		assert.EqualValues(t, 9, m.Payload.ID)
+	}
+
+	buf.Reset()
+	require.NoError(t, NewFramer(nil, &buf).WithCompression(CompressFlate).WriteFrame(payload))
+	_, err := NewFramer(&buf, nil).WithCompression(CompressNone).WithMaxSize(100).ReadFrame()
+	assert.ErrorIs(t, err, ErrLengthOverflow)
+
+	assert.ErrorIs(t, NewFramer(nil, &buf).WithCompression(CompressZstd).WriteFrame(payload), ErrUnknownCompression)
+}
@@ -0,0 +1,160 @@
+package codec
+
+import "io"
+
+// CountingWriter counts the bytes written through it to an io.Writer. Unlike
+// Writer it neither buffers nor latches errors, so it fits inside custom
+// WriteTo implementations that only need an accurate count to return.
+type CountingWriter struct {
+	w io.Writer
+	n int64
+}
+
+// NewCountingWriter returns a CountingWriter writing to w.
+func NewCountingWriter(w io.Writer) *CountingWriter {
+	return &CountingWriter{w: w}
+}
+
+// Count returns the number of bytes written so far.
+func (c *CountingWriter) Count() int64 { return c.n }
+
+func (c *CountingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+func (c *CountingWriter) WriteString(s string) (int, error) {
+	n, err := io.WriteString(c.w, s)
+	c.n += int64(n)
+	return n, err
+}
+
+// ReadFrom implements io.ReaderFrom, keeping the fast path of the underlying
+// writer for io.Copy.
+func (c *CountingWriter) ReadFrom(r io.Reader) (int64, error) {
+	var n int64
+	var err error
+	if rf, ok := c.w.(io.ReaderFrom); ok {
+		n, err = rf.ReadFrom(r)
+	} else {
+		n, err = io.Copy(c.w, r)
+	}
+	c.n += n
+	return n, err
+}
+
+// Close closes the underlying writer if it implements io.Closer.
+func (c *CountingWriter) Close() error {
+	if cl, ok := c.w.(io.Closer); ok {
+		return cl.Close()
+	}
+	return nil
+}
+
+// CountingReader counts the bytes read through it from an io.Reader, without
+// buffering, for custom ReadFrom implementations.
+type CountingReader struct {
+	r io.Reader
+	n int64
+}
+
+// NewCountingReader returns a CountingReader reading from r.
+func NewCountingReader(r io.Reader) *CountingReader {
+	return &CountingReader{r: r}
+}
+
+// Count returns the number of bytes read so far.
+func (c *CountingReader) Count() int64 { return c.n }
+
+func (c *CountingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// ReadByte implements io.ByteReader, so decoders such as binary.ReadUvarint
+// do not need to wrap the reader again.
+func (c *CountingReader) ReadByte() (byte, error) {
+	if br, ok := c.r.(io.ByteReader); ok {
+		b, err := br.ReadByte()
+		if err == nil {
+			c.n++
+		}
+		return b, err
+	}
+	var b [1]byte
+	_, err := io.ReadFull(c.r, b[:])
+	if err == nil {
+		c.n++
+	}
+	return b[0], err
+}
+
+// WriteTo implements io.WriterTo, keeping the fast path of the underlying
+// reader for io.Copy.
+func (c *CountingReader) WriteTo(w io.Writer) (int64, error) {
+	var n int64
+	var err error
+	if wt, ok := c.r.(io.WriterTo); ok {
+		n, err = wt.WriteTo(w)
+	} else {
+		n, err = io.Copy(w, c.r)
+	}
+	c.n += n
+	return n, err
+}
+
+// Close closes the underlying reader if it implements io.Closer.
+func (c *CountingReader) Close() error {
+	if cl, ok := c.r.(io.Closer); ok {
+		return cl.Close()
+	}
+	return nil
+}
+
+// SizeWriter discards everything written to it and counts the bytes, for
+// measuring an encoding without producing it.
+type SizeWriter struct {
+	n int64
+}
+
+// Count returns the number of bytes written so far.
+func (s *SizeWriter) Count() int64 { return s.n }
+
+// Reset sets the count back to zero.
+func (s *SizeWriter) Reset() { s.n = 0 }
+
+func (s *SizeWriter) Write(p []byte) (int, error) {
+	s.n += int64(len(p))
+	return len(p), nil
+}
+
+func (s *SizeWriter) WriteString(str string) (int, error) {
+	s.n += int64(len(str))
+	return len(str), nil
+}
+
+func (s *SizeWriter) WriteByte(byte) error {
+	s.n++
+	return nil
+}
+
+// ReadFrom implements io.ReaderFrom, counting what r yields.
+func (s *SizeWriter) ReadFrom(r io.Reader) (int64, error) {
+	n, err := io.Copy(io.Discard, r)
+	s.n += n
+	return n, err
+}
+
+// SizeOf returns the number of bytes v writes, by running its WriteTo
+// against a SizeWriter. Codecs whose layout is hard to compute can implement
+// Size with it, so Size cannot drift from what WriteTo produces. It returns
+// -1 if WriteTo fails.
+func SizeOf(v io.WriterTo) int64 {
+	var s SizeWriter
+	if _, err := v.WriteTo(&s); err != nil {
+		return -1
+	}
+	return s.n
+}
@@ -0,0 +1,63 @@
+//go:build test
+
+package codec
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCountingWriter(t *testing.T) {
+	var buf bytes.Buffer
+	c := NewCountingWriter(&buf)
+	c.Write([]byte("abc"))
+	io.WriteString(c, "de")
+	n, err := io.Copy(c, strings.NewReader("fghij"))
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), n)
+	assert.Equal(t, int64(10), c.Count())
+	assert.Equal(t, "abcdefghij", buf.String())
+
+	c = NewCountingWriter(struct{ io.Writer }{io.Discard})
+	io.Copy(c, strings.NewReader("xyz"))
+	assert.Equal(t, int64(3), c.Count())
+}
+
+func TestCountingReader(t *testing.T) {
+	c := NewCountingReader(bytes.NewReader(binary.AppendUvarint(nil, 300)))
+	v, err := binary.ReadUvarint(c)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(300), v)
+	assert.Equal(t, int64(2), c.Count())
+
+	c = NewCountingReader(struct{ io.Reader }{strings.NewReader("hello world")})
+	p := make([]byte, 6)
+	io.ReadFull(c, p)
+	b, _ := c.ReadByte()
+	assert.Equal(t, byte('w'), b)
+	var out bytes.Buffer
+	io.Copy(&out, c)
+	assert.Equal(t, "orld", out.String())
+	assert.Equal(t, int64(11), c.Count())
+}
+
+func TestSizeOf(t *testing.T) {
+	f := &mockCodec{}
+	assert.Equal(t, int64(f.Size()), SizeOf(f))
+	assert.Equal(t, int64(5), SizeOf(strings.NewReader("hello")))
+
+	var s SizeWriter
+	s.Write([]byte("abc"))
+	s.WriteByte('d')
+	io.WriteString(&s, "ef")
+	io.Copy(&s, strings.NewReader("ghi"))
+	assert.Equal(t, int64(9), s.Count())
+	s.Reset()
+	assert.Zero(t, s.Count())
+}
@@ -0,0 +1,108 @@
+package codec
+
+import (
+	"io"
+	"sync/atomic"
+)
+
+// DebugWriter passes writes through to an io.Writer and, while enabled, tees
+// them into a hexdump of offset, hex and ASCII columns on a side writer:
+//
+//	00000000  68 65 6c 6c 6f 20 77 6f  72 6c 64 0a              |hello world.|
+//
+// Offsets count every byte written, including those written while disabled.
+// Failures of the side writer are ignored so they never break the stream.
+type DebugWriter struct {
+	w       io.Writer
+	dump    io.Writer
+	enabled atomic.Bool
+	count   int64    // bytes written through
+	start   int64    // offset of the first pending byte
+	line    [16]byte // bytes of the pending dump line
+	n       int
+	out     []byte
+}
+
+// NewDebugWriter returns an enabled DebugWriter writing to w and dumping to dump.
+func NewDebugWriter(w, dump io.Writer) *DebugWriter {
+	d := &DebugWriter{w: w, dump: dump}
+	d.enabled.Store(true)
+	return d
+}
+
+// Enable turns dumping on or off. It may be called from any goroutine.
+func (d *DebugWriter) Enable(on bool) { d.enabled.Store(on) }
+
+// Enabled reports whether writes are being dumped.
+func (d *DebugWriter) Enabled() bool { return d.enabled.Load() }
+
+// Write implements io.Writer, dumping the bytes the underlying writer accepted.
+func (d *DebugWriter) Write(p []byte) (int, error) {
+	n, err := d.w.Write(p)
+	if d.enabled.Load() {
+		for _, b := range p[:n] {
+			if d.n == 0 {
+				d.start = d.count
+			}
+			d.line[d.n] = b
+			d.n++
+			d.count++
+			if d.n == len(d.line) {
+				d.writeLine()
+			}
+		}
+	} else {
+		d.writeLine()
+		d.count += int64(n)
+	}
+	return n, err
+}
+
+// Flush dumps the pending partial line.
+func (d *DebugWriter) Flush() error {
+	d.writeLine()
+	return nil
+}
+
+// Close flushes the dump and closes the underlying writer if it implements
+// io.Closer.
+func (d *DebugWriter) Close() error {
+	d.writeLine()
+	if c, ok := d.w.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+func (d *DebugWriter) writeLine() {
+	if d.n == 0 {
+		return
+	}
+	const digits = "0123456789abcdef"
+	out := d.out[:0]
+	for shift := 28; shift >= 0; shift -= 4 {
+		out = append(out, digits[d.start>>shift&0xF])
+	}
+	out = append(out, ' ')
+	for i := range d.line {
+		if i == 8 {
+			out = append(out, ' ')
+		}
+		if i < d.n {
+			out = append(out, ' ', digits[d.line[i]>>4], digits[d.line[i]&0xF])
+		} else {
+			out = append(out, "   "...)
+		}
+	}
+	out = append(out, "  |"...)
+	for _, b := range d.line[:d.n] {
+		if b < 0x20 || b > 0x7E {
+			b = '.'
+		}
+		out = append(out, b)
+	}
+	out = append(out, "|\n"...)
+	d.dump.Write(out)
+	d.out = out
+	d.n = 0
+}
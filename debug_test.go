@@ -0,0 +1,36 @@
+//go:build test
+
+package codec
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDebugWriter(t *testing.T) {
+	var out, dump bytes.Buffer
+	d := NewDebugWriter(&out, &dump)
+	data := []byte("hello world.\nsecond line of the dump")
+	d.Write(data[:5])
+	d.Write(data[5:])
+	require.NoError(t, d.Flush())
+	assert.Equal(t, data, out.Bytes())
+	assert.Equal(t, hex.Dump(data), dump.String())
+
+	// Disabled writes are not dumped but still advance the offset.
+	dump.Reset()
+	d.Enable(false)
+	assert.False(t, d.Enabled())
+	d.Write(make([]byte, 12))
+	d.Enable(true)
+
+	w, _ := NewWriterSize(d, BUFFER_SIZE)
+	w.WriteUint32(0xCAFEBABE)
+	require.NoError(t, w.Flush())
+	require.NoError(t, d.Close())
+	assert.Equal(t, "00000030  ca fe ba be                                       |....|\n", dump.String())
+}
@@ -0,0 +1,122 @@
+package codec
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// DecoderWriter is a push-style decoder: bytes written to it are buffered until
+// they contain a complete message, which is then decoded and handed to a
+// callback. It suits event loops and proxies that receive data in arbitrary
+// chunks and cannot block on Read.
+//
+// Every Write decodes the buffered tail from its start, so a message arriving
+// in k chunks costs k decode attempts over a growing prefix: quadratic in the
+// number of chunks for large messages fed in small pieces. Bound it with
+// WithMaxSize when messages come from untrusted peers.
+//
+// Like Reader and Writer, DecoderWriter latches the first error; subsequent
+// writes return it.
+type DecoderWriter[T Codec] struct {
+	newMsg    func() T
+	onMessage func(T) error
+	buf       []byte
+	maxSize   int
+	err       error
+	acct      *MemoryAccount
+}
+
+var _ io.WriteCloser = (*DecoderWriter[Codec])(nil)
+
+// NewDecoderWriter creates a DecoderWriter. newMsg returns an empty message to
+// decode into, and onMessage is invoked with every completed message in order.
+// An error returned by onMessage stops decoding and is returned by Write.
+func NewDecoderWriter[T Codec](newMsg func() T, onMessage func(T) error) *DecoderWriter[T] {
+	return &DecoderWriter[T]{newMsg: newMsg, onMessage: onMessage}
+}
+
+// WithMaxSize limits the number of bytes buffered for one incomplete message,
+// guarding against peers that never complete a message. Exceeding it fails with
+// ErrLengthOverflow. A limit of 0 disables the check. It returns the configured
+// DecoderWriter for chaining.
+func (d *DecoderWriter[T]) WithMaxSize(n int) *DecoderWriter[T] {
+	d.maxSize = n
+	return d
+}
+
+// WithAccount charges the message buffer to a as it grows and returns the
+// DecoderWriter for chaining.
+func (d *DecoderWriter[T]) WithAccount(a *MemoryAccount) *DecoderWriter[T] {
+	d.acct = a
+	d.acct.grow(0, cap(d.buf))
+	return d
+}
+
+// Write buffers p and decodes every message it completes. It always reports
+// len(p) bytes written unless an error was latched.
+func (d *DecoderWriter[T]) Write(p []byte) (int, error) {
+	if d.err != nil {
+		return 0, d.err
+	}
+	before := cap(d.buf)
+	d.buf = append(d.buf, p...)
+	defer func() { d.acct.grow(before, cap(d.buf)) }()
+
+	off := 0
+	for off < len(d.buf) {
+		msg := d.newMsg()
+		// The size of an empty message is a lower bound for any message of its
+		// type, so skip decode attempts that cannot succeed.
+		if len(d.buf)-off < msg.Size() {
+			break
+		}
+		n, err := msg.ReadFrom(NewBytesReader(d.buf[off:]))
+		if err != nil {
+			if incomplete(err) {
+				break
+			}
+			d.err = err
+			return 0, err
+		}
+		if n == 0 {
+			d.err = fmt.Errorf("%w: message decoded from zero bytes", ErrTruncatedData)
+			return 0, d.err
+		}
+		off += int(n)
+		if err := d.onMessage(msg); err != nil {
+			d.err = err
+			return 0, err
+		}
+	}
+
+	// Keep only the incomplete tail, reusing the buffer.
+	d.buf = d.buf[:copy(d.buf, d.buf[off:])]
+	if d.maxSize > 0 && len(d.buf) > d.maxSize {
+		d.err = fmt.Errorf("%w: %d bytes buffered without a complete message", ErrLengthOverflow, len(d.buf))
+		return 0, d.err
+	}
+	return len(p), nil
+}
+
+// Buffered returns the number of bytes held for an incomplete message.
+func (d *DecoderWriter[T]) Buffered() int { return len(d.buf) }
+
+// Err returns the first error encountered.
+func (d *DecoderWriter[T]) Err() error { return d.err }
+
+// Close reports ErrTruncatedData if an incomplete message remains buffered.
+func (d *DecoderWriter[T]) Close() error {
+	if d.err != nil {
+		return d.err
+	}
+	if len(d.buf) > 0 {
+		return fmt.Errorf("%w: %d bytes of an incomplete message", ErrTruncatedData, len(d.buf))
+	}
+	return nil
+}
+
+// incomplete reports whether a decode error means more input is needed.
+func incomplete(err error) bool {
+	return errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, ErrTruncatedData)
+}
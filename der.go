@@ -0,0 +1,166 @@
+package codec
+
+import (
+	"fmt"
+	"math"
+)
+
+// DERClass is the class of an ASN.1 tag, stored in the top two bits of the identifier octet.
+type DERClass uint8
+
+const (
+	DERUniversal DERClass = iota
+	DERApplication
+	DERContextSpecific
+	DERPrivate
+)
+
+// DERTag identifies an ASN.1 element: class, primitive or constructed
+// encoding, and tag number. Numbers of 31 and above use the high-tag-number form.
+type DERTag struct {
+	Class       DERClass
+	Constructed bool
+	Number      uint32
+}
+
+const (
+	derConstructed = 0x20
+	derHighTag     = 0x1F
+	derLongLength  = 0x80
+)
+
+// WriteDERTagLength writes the identifier and length octets of a DER element
+// whose contents, length bytes long, must follow.
+func (w *Writer) WriteDERTagLength(tag DERTag, length int) {
+	if w.err != nil {
+		return
+	}
+	if length < 0 {
+		w.setError(fmt.Errorf("%w: negative DER length %d", ErrLengthOverflow, length))
+		return
+	}
+
+	var buf [16]byte
+	buf[0] = byte(tag.Class&3) << 6
+	if tag.Constructed {
+		buf[0] |= derConstructed
+	}
+	n := 1
+	if tag.Number < derHighTag {
+		buf[0] |= byte(tag.Number)
+	} else {
+		buf[0] |= derHighTag
+		// Base-128 big-endian, continuation bit on all but the last octet.
+		start := n
+		for v := tag.Number; ; v >>= 7 {
+			buf[n] = byte(v & 0x7F)
+			n++
+			if v < 0x80 {
+				break
+			}
+		}
+		for i, j := start, n-1; i < j; i, j = i+1, j-1 {
+			buf[i], buf[j] = buf[j], buf[i]
+		}
+		for i := start; i < n-1; i++ {
+			buf[i] |= 0x80
+		}
+	}
+
+	if length < derLongLength {
+		buf[n] = byte(length)
+		n++
+	} else {
+		octets := 0
+		for v := length; v > 0; v >>= 8 {
+			octets++
+		}
+		buf[n] = derLongLength | byte(octets)
+		n++
+		for i := octets - 1; i >= 0; i-- {
+			buf[n] = byte(length >> (8 * i))
+			n++
+		}
+	}
+	w.Write(buf[:n])
+}
+
+// ReadDERTagLength reads the identifier and length octets of a DER element.
+// Non-minimal tag numbers and lengths are rejected with ErrInvalidDER, and
+// the BER indefinite length form with ErrIndefiniteLength.
+func (r *Reader) ReadDERTagLength(tag *DERTag, length *int) {
+	var b byte
+	r.ReadUint8(&b)
+	if r.err != nil {
+		return
+	}
+	t := DERTag{Class: DERClass(b >> 6), Constructed: b&derConstructed != 0, Number: uint32(b & derHighTag)}
+
+	if t.Number == derHighTag {
+		var num uint64
+		for i := 0; ; i++ {
+			r.ReadUint8(&b)
+			if r.err != nil {
+				return
+			}
+			if i == 0 && b == 0x80 {
+				r.setError(fmt.Errorf("%w: tag number has leading zero octet", ErrInvalidDER))
+				return
+			}
+			num = num<<7 | uint64(b&0x7F)
+			if num > math.MaxUint32 {
+				r.setError(fmt.Errorf("%w: tag number too large", ErrInvalidDER))
+				return
+			}
+			if b&0x80 == 0 {
+				break
+			}
+		}
+		if num < derHighTag {
+			r.setError(fmt.Errorf("%w: tag number %d in high-tag-number form", ErrInvalidDER, num))
+			return
+		}
+		t.Number = uint32(num)
+	}
+
+	r.ReadUint8(&b)
+	if r.err != nil {
+		return
+	}
+	n := int(b)
+	switch {
+	case b == derLongLength:
+		r.setError(ErrIndefiniteLength)
+		return
+	case b > derLongLength:
+		octets := int(b &^ derLongLength)
+		if octets > 8 {
+			r.setError(fmt.Errorf("%w: %d length octets", ErrLengthOverflow, octets))
+			return
+		}
+		var v uint64
+		for i := 0; i < octets; i++ {
+			r.ReadUint8(&b)
+			if r.err != nil {
+				return
+			}
+			if i == 0 && b == 0 {
+				r.setError(fmt.Errorf("%w: length has leading zero octet", ErrInvalidDER))
+				return
+			}
+			v = v<<8 | uint64(b)
+		}
+		if v < derLongLength {
+			r.setError(fmt.Errorf("%w: length %d in long form", ErrInvalidDER, v))
+			return
+		}
+		if v > math.MaxInt {
+			r.setError(fmt.Errorf("%w: length %d", ErrLengthOverflow, v))
+			return
+		}
+		n = int(v)
+	}
+
+	*tag = t
+	*length = n
+}
@@ -0,0 +1,60 @@
+//go:build test
+
+package codec
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDERTagLength(t *testing.T) {
+	cases := []struct {
+		tag    DERTag
+		length int
+		want   string
+	}{
+		{DERTag{Number: 2}, 1, "0201"},                                               // INTEGER
+		{DERTag{Number: 16, Constructed: true}, 0x82, "308182"},                      // SEQUENCE
+		{DERTag{Number: 4}, 0x1234, "04821234"},                                      // OCTET STRING
+		{DERTag{Class: DERContextSpecific, Constructed: true, Number: 0}, 3, "a003"}, // [0] EXPLICIT
+		{DERTag{Class: DERApplication, Number: 31}, 0, "5f1f00"},
+		{DERTag{Class: DERPrivate, Number: 201}, 127, "df81497f"},
+	}
+	for _, c := range cases {
+		var buf bytes.Buffer
+		w, _ := NewWriter(&buf)
+		w.WriteDERTagLength(c.tag, c.length)
+		require.NoError(t, w.Flush())
+		assert.Equal(t, c.want, hex.EncodeToString(buf.Bytes()))
+
+		var tag DERTag
+		var n int
+		r, _ := NewReader(&buf)
+		r.ReadDERTagLength(&tag, &n)
+		require.NoError(t, r.Err())
+		assert.Equal(t, c.tag, tag)
+		assert.Equal(t, c.length, n)
+	}
+
+	bad := map[string]error{
+		"3080":         ErrIndefiniteLength,
+		"048105":       ErrInvalidDER,
+		"04820010":     ErrInvalidDER,
+		"1f1e00":       ErrInvalidDER,
+		"1f800100":     ErrInvalidDER,
+		"04890000":     ErrLengthOverflow,
+		"1fffffffff7f": ErrInvalidDER,
+	}
+	for s, want := range bad {
+		b, _ := hex.DecodeString(s)
+		var tag DERTag
+		var n int
+		r, _ := NewReader(bytes.NewReader(append(b, make([]byte, 16)...)))
+		r.ReadDERTagLength(&tag, &n)
+		assert.ErrorIs(t, r.Err(), want, s)
+	}
+}
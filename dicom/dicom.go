@@ -0,0 +1,579 @@
+// Package dicom reads and writes DICOM data elements on top of codec.Reader
+// and codec.Writer: the tag, value representation (VR) and length of each
+// element, sequences of items with defined or undefined lengths, and
+// encapsulated pixel data. Values are kept as raw bytes; interpreting them
+// against the data dictionary is left to the caller.
+//
+// The transfer syntax decides whether VRs are explicit and the byte order;
+// NewDecoder and NewEncoder set the byte order of the Reader or Writer from
+// it. ReadFile and WriteFile handle the file preamble and meta information.
+package dicom
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/oy3o/codec"
+)
+
+// Tag is the group and element number of a data element.
+type Tag uint32
+
+// NewTag returns the tag (group,element).
+func NewTag(group, element uint16) Tag { return Tag(group)<<16 | Tag(element) }
+
+func (t Tag) Group() uint16   { return uint16(t >> 16) }
+func (t Tag) Element() uint16 { return uint16(t) }
+
+// String returns the tag in its usual (gggg,eeee) form.
+func (t Tag) String() string { return fmt.Sprintf("(%04X,%04X)", t.Group(), t.Element()) }
+
+const (
+	TagFileMetaInformationGroupLength Tag = 0x00020000
+	TagTransferSyntaxUID              Tag = 0x00020010
+	TagPixelData                      Tag = 0x7FE00010
+
+	// Items and delimiters have no VR, even in explicit VR transfer syntaxes.
+	TagItem                 Tag = 0xFFFEE000
+	TagItemDelimitation     Tag = 0xFFFEE00D
+	TagSequenceDelimitation Tag = 0xFFFEE0DD
+)
+
+// delimiterGroup is the group of items and delimiters.
+const delimiterGroup = 0xFFFE
+
+// VR is the two letter value representation of an element.
+type VR string
+
+const (
+	OB VR = "OB"
+	OW VR = "OW"
+	SQ VR = "SQ"
+	UI VR = "UI"
+	UL VR = "UL"
+	UN VR = "UN"
+)
+
+// longVR reports whether vr has a 4-byte length in explicit VR transfer
+// syntaxes, after two reserved bytes.
+func longVR(vr VR) bool {
+	switch vr {
+	case "OB", "OD", "OF", "OL", "OV", "OW", "SQ", "SV", "UC", "UN", "UR", "UT", "UV":
+		return true
+	}
+	return false
+}
+
+// padByte returns the byte padding values of vr to an even length.
+func padByte(vr VR) byte {
+	switch vr {
+	case "AE", "AS", "CS", "DA", "DS", "DT", "IS", "LO", "LT", "PN", "SH", "ST", "TM", "UC", "UR", "UT":
+		return ' '
+	}
+	return 0
+}
+
+const (
+	// UNDEFINED_LENGTH marks sequences, items and encapsulated pixel data
+	// ended by a delimitation item rather than sized in advance.
+	UNDEFINED_LENGTH = 0xFFFFFFFF
+	// MAX_DEPTH bounds the nesting of sequences.
+	MAX_DEPTH = 64
+	// MAX_LENGTH bounds the length of a value. Reader.WithMaxAlloc lowers it.
+	MAX_LENGTH = 1 << 30
+)
+
+var (
+	// ErrInvalidElement indicates an element whose VR or length is invalid,
+	// or an item or delimiter where none may appear.
+	ErrInvalidElement = errors.New("dicom: invalid element")
+
+	// ErrTooDeep indicates sequences nested deeper than MAX_DEPTH.
+	ErrTooDeep = errors.New("dicom: sequences nested too deep")
+)
+
+// TransferSyntax is the encoding of a data set.
+type TransferSyntax struct {
+	UID      string
+	Explicit bool // VRs are written out
+	Order    binary.ByteOrder
+	Deflated bool // the data set is compressed with deflate
+}
+
+var (
+	ImplicitVRLittleEndian         = TransferSyntax{UID: "1.2.840.10008.1.2", Order: codec.LE}
+	ExplicitVRLittleEndian         = TransferSyntax{UID: "1.2.840.10008.1.2.1", Explicit: true, Order: codec.LE}
+	DeflatedExplicitVRLittleEndian = TransferSyntax{UID: "1.2.840.10008.1.2.1.99", Explicit: true, Order: codec.LE, Deflated: true}
+	ExplicitVRBigEndian            = TransferSyntax{UID: "1.2.840.10008.1.2.2", Explicit: true, Order: codec.BE}
+)
+
+// TransferSyntaxOf returns the transfer syntax identified by uid. Transfer
+// syntaxes of compressed pixel data, and any other not listed above, encode
+// the data set in explicit VR little endian.
+func TransferSyntaxOf(uid string) TransferSyntax {
+	uid = strings.TrimRight(uid, " \x00")
+	for _, ts := range []TransferSyntax{ImplicitVRLittleEndian, ExplicitVRLittleEndian, DeflatedExplicitVRLittleEndian, ExplicitVRBigEndian} {
+		if ts.UID == uid {
+			return ts
+		}
+	}
+	ts := ExplicitVRLittleEndian
+	ts.UID = uid
+	return ts
+}
+
+// Element is a data element. Exactly one of Value, Items and Fragments is
+// used: Items for sequences, Fragments for encapsulated pixel data, whose
+// first fragment is the basic offset table, and Value for the rest.
+type Element struct {
+	Tag       Tag
+	VR        VR
+	Value     []byte // raw value, including any padding to an even length
+	Items     []Dataset
+	Fragments [][]byte
+
+	// Undefined records that a sequence was ended by a delimitation item
+	// rather than sized in advance, and makes WriteElement do the same for
+	// it and its items.
+	Undefined bool
+}
+
+// Text returns the value with its padding removed, for string VRs.
+func (e *Element) Text() string { return strings.TrimRight(string(e.Value), " \x00") }
+
+// Dataset is a list of elements in ascending tag order.
+type Dataset []*Element
+
+// Find returns the element tagged t, or nil if there is none.
+func (ds Dataset) Find(t Tag) *Element {
+	for _, e := range ds {
+		if e.Tag == t {
+			return e
+		}
+	}
+	return nil
+}
+
+func unexpected(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// Decoder reads data elements.
+type Decoder struct {
+	r        *codec.Reader
+	order    binary.ByteOrder
+	explicit bool
+	lookup   func(Tag) VR
+	depth    int
+}
+
+// NewDecoder returns a Decoder reading elements encoded in ts from r, and
+// sets the byte order of r accordingly. Deflated data sets must be inflated
+// by the caller, as ReadFile does.
+func NewDecoder(r *codec.Reader, ts TransferSyntax) *Decoder {
+	r.WithByteOrder(ts.Order)
+	return &Decoder{r: r, order: ts.Order, explicit: ts.Explicit}
+}
+
+// WithDictionary looks up the VRs of elements in implicit VR transfer
+// syntaxes with lookup, which returns "" for unknown tags, and returns the
+// Decoder for chaining. It is needed to parse sequences of defined length;
+// without it such elements are read as UN values, and elements of undefined
+// length as sequences.
+func (d *Decoder) WithDictionary(lookup func(Tag) VR) *Decoder {
+	d.lookup = lookup
+	return d
+}
+
+// nested returns a Decoder for the items of a sequence read from r.
+func (d *Decoder) nested(r *codec.Reader, explicit bool) *Decoder {
+	return &Decoder{r: r, order: d.order, explicit: explicit, lookup: d.lookup, depth: d.depth + 1}
+}
+
+// sub returns a Reader over the next n bytes of d, to parse a sequence or
+// item of defined length.
+func (d *Decoder) sub(n uint32) (*codec.Reader, error) {
+	b, err := d.readValue(n)
+	if err != nil {
+		return nil, err
+	}
+	r, _ := codec.NewReader(codec.NewBytesReader(b))
+	r.WithByteOrder(d.order)
+	return r, nil
+}
+
+func (d *Decoder) readValue(n uint32) ([]byte, error) {
+	if n > MAX_LENGTH {
+		return nil, fmt.Errorf("%w: value of %d bytes", codec.ErrLengthOverflow, n)
+	}
+	b := d.r.ReadBytes(int(n))
+	if err := d.r.Err(); err != nil {
+		return nil, unexpected(err)
+	}
+	return b, nil
+}
+
+// readHeader reads the tag, VR and length of an element. A Reader at the end
+// of the stream returns io.EOF.
+func (d *Decoder) readHeader() (Tag, VR, uint32, error) {
+	var b [4]byte
+	var length uint32
+	var vr VR
+	// ReadBytesTo keeps a clean end of stream io.EOF.
+	d.r.ReadBytesTo(b[:])
+	if err := d.r.Err(); err != nil {
+		return 0, "", 0, err
+	}
+	group := d.order.Uint16(b[:])
+	tag := NewTag(group, d.order.Uint16(b[2:]))
+	switch {
+	case group == delimiterGroup:
+		d.r.ReadUint32(&length)
+	case d.explicit:
+		var b [2]byte
+		d.r.ReadBytesTo(b[:])
+		if vr = VR(b[:]); longVR(vr) {
+			var reserved uint16
+			d.r.ReadUint16(&reserved)
+			d.r.ReadUint32(&length)
+		} else {
+			var short uint16
+			d.r.ReadUint16(&short)
+			length = uint32(short)
+		}
+	default:
+		d.r.ReadUint32(&length)
+		if d.lookup != nil {
+			vr = d.lookup(tag)
+		}
+		if vr == "" {
+			switch {
+			case length != UNDEFINED_LENGTH:
+				vr = UN
+			case tag == TagPixelData:
+				vr = OB
+			default:
+				vr = SQ
+			}
+		}
+	}
+	if err := d.r.Err(); err != nil {
+		return 0, "", 0, unexpected(err)
+	}
+	return tag, vr, length, nil
+}
+
+// ReadElement reads the next element, with the items of sequences and the
+// fragments of encapsulated pixel data. A Reader at the end of the stream
+// returns io.EOF.
+func (d *Decoder) ReadElement() (*Element, error) {
+	e, err := d.readElement()
+	if err == nil && e.Tag.Group() == delimiterGroup {
+		return nil, fmt.Errorf("%w: %s outside a sequence", ErrInvalidElement, e.Tag)
+	}
+	return e, err
+}
+
+func (d *Decoder) readElement() (*Element, error) {
+	tag, vr, length, err := d.readHeader()
+	if err != nil {
+		return nil, err
+	}
+	e := &Element{Tag: tag, VR: vr}
+	switch {
+	case tag.Group() == delimiterGroup:
+		if tag != TagItemDelimitation && tag != TagSequenceDelimitation {
+			return nil, fmt.Errorf("%w: %s outside a sequence", ErrInvalidElement, tag)
+		}
+	case vr == SQ || vr == UN && length == UNDEFINED_LENGTH:
+		// UN of undefined length is a sequence in implicit VR little endian.
+		e.Items, err = d.readItems(length, d.explicit && vr == SQ)
+		e.Undefined = length == UNDEFINED_LENGTH
+	case length == UNDEFINED_LENGTH && tag == TagPixelData:
+		e.Fragments, err = d.readFragments()
+		e.Undefined = true
+	case length == UNDEFINED_LENGTH:
+		return nil, fmt.Errorf("%w: %s %s of undefined length", ErrInvalidElement, tag, vr)
+	default:
+		e.Value, err = d.readValue(length)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// readItems reads the items of a sequence.
+func (d *Decoder) readItems(length uint32, explicit bool) ([]Dataset, error) {
+	if d.depth >= MAX_DEPTH {
+		return nil, ErrTooDeep
+	}
+	r := d.r
+	if length != UNDEFINED_LENGTH {
+		var err error
+		if r, err = d.sub(length); err != nil {
+			return nil, err
+		}
+	}
+	seq := d.nested(r, explicit)
+	items := []Dataset{}
+	for {
+		tag, _, n, err := seq.readHeader()
+		if err == io.EOF && length != UNDEFINED_LENGTH {
+			return items, nil
+		}
+		if err != nil {
+			return nil, unexpected(err)
+		}
+		switch {
+		case tag == TagSequenceDelimitation && length == UNDEFINED_LENGTH:
+			return items, nil
+		case tag == TagItem:
+			item, err := seq.readItem(n)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		default:
+			return nil, fmt.Errorf("%w: %s in a sequence", ErrInvalidElement, tag)
+		}
+	}
+}
+
+// readItem reads the elements of an item.
+func (d *Decoder) readItem(length uint32) (Dataset, error) {
+	item := d
+	if length != UNDEFINED_LENGTH {
+		r, err := d.sub(length)
+		if err != nil {
+			return nil, err
+		}
+		item = &Decoder{r: r, order: d.order, explicit: d.explicit, lookup: d.lookup, depth: d.depth}
+	}
+	var ds Dataset
+	for {
+		e, err := item.readElement()
+		if err == io.EOF && length != UNDEFINED_LENGTH {
+			return ds, nil
+		}
+		if err != nil {
+			return nil, unexpected(err)
+		}
+		if e.Tag == TagItemDelimitation && length == UNDEFINED_LENGTH {
+			return ds, nil
+		}
+		if e.Tag.Group() == delimiterGroup {
+			return nil, fmt.Errorf("%w: %s in an item", ErrInvalidElement, e.Tag)
+		}
+		ds = append(ds, e)
+	}
+}
+
+// readFragments reads the items of encapsulated pixel data.
+func (d *Decoder) readFragments() ([][]byte, error) {
+	fragments := [][]byte{}
+	for {
+		tag, _, n, err := d.readHeader()
+		if err != nil {
+			return nil, unexpected(err)
+		}
+		switch {
+		case tag == TagSequenceDelimitation:
+			return fragments, nil
+		case tag == TagItem && n != UNDEFINED_LENGTH:
+			b, err := d.readValue(n)
+			if err != nil {
+				return nil, err
+			}
+			fragments = append(fragments, b)
+		default:
+			return nil, fmt.Errorf("%w: %s in pixel data", ErrInvalidElement, tag)
+		}
+	}
+}
+
+// ReadDataset reads elements up to the end of the stream.
+func (d *Decoder) ReadDataset() (Dataset, error) {
+	var ds Dataset
+	for {
+		e, err := d.ReadElement()
+		if err == io.EOF {
+			return ds, nil
+		}
+		if err != nil {
+			return ds, err
+		}
+		ds = append(ds, e)
+	}
+}
+
+// Encoder writes data elements.
+type Encoder struct {
+	w        *codec.Writer
+	order    binary.ByteOrder
+	explicit bool
+}
+
+// NewEncoder returns an Encoder writing elements encoded in ts to w, and
+// sets the byte order of w accordingly. Deflated data sets must be deflated
+// by the caller, as WriteFile does.
+func NewEncoder(w *codec.Writer, ts TransferSyntax) *Encoder {
+	w.WithByteOrder(ts.Order)
+	return &Encoder{w: w, order: ts.Order, explicit: ts.Explicit}
+}
+
+func (e *Encoder) writeHeader(tag Tag, vr VR, length uint32) error {
+	e.w.WriteUint16(tag.Group())
+	e.w.WriteUint16(tag.Element())
+	switch {
+	case tag.Group() == delimiterGroup || !e.explicit:
+		e.w.WriteUint32(length)
+	case len(vr) != 2:
+		return fmt.Errorf("%w: %s has VR %q", ErrInvalidElement, tag, vr)
+	case longVR(vr):
+		e.w.WriteString(string(vr))
+		e.w.WriteUint16(0)
+		e.w.WriteUint32(length)
+	case length > 0xFFFF:
+		return fmt.Errorf("%w: %s %s of %d bytes", codec.ErrLengthOverflow, tag, vr, length)
+	default:
+		e.w.WriteString(string(vr))
+		e.w.WriteUint16(uint16(length))
+	}
+	return e.w.Err()
+}
+
+// encode returns what f writes with an Encoder like e, to size a sequence
+// or item of defined length.
+func (e *Encoder) encode(f func(*Encoder) error) ([]byte, error) {
+	var buf bytes.Buffer
+	w, _ := codec.NewWriter(&buf)
+	w.WithByteOrder(e.order)
+	if err := f(&Encoder{w: w, order: e.order, explicit: e.explicit}); err != nil {
+		return nil, err
+	}
+	if err := w.Flush(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// WriteElement writes an element. Values of odd length are padded to an
+// even length as their VR requires.
+func (e *Encoder) WriteElement(el *Element) error {
+	switch {
+	case el.Items != nil || el.VR == SQ:
+		return e.writeSequence(el)
+	case el.Fragments != nil:
+		vr := el.VR
+		if vr == "" {
+			vr = OB
+		}
+		if err := e.writeHeader(el.Tag, vr, UNDEFINED_LENGTH); err != nil {
+			return err
+		}
+		for _, f := range el.Fragments {
+			if err := e.writeHeader(TagItem, "", uint32(len(f))); err != nil {
+				return err
+			}
+			e.w.WriteBytes(f)
+		}
+		return e.writeHeader(TagSequenceDelimitation, "", 0)
+	}
+	n := len(el.Value) + len(el.Value)&1
+	if n > MAX_LENGTH {
+		return fmt.Errorf("%w: value of %d bytes", codec.ErrLengthOverflow, n)
+	}
+	if err := e.writeHeader(el.Tag, el.VR, uint32(n)); err != nil {
+		return err
+	}
+	e.w.WriteBytes(el.Value)
+	if n > len(el.Value) {
+		e.w.WriteUint8(padByte(el.VR))
+	}
+	return e.w.Err()
+}
+
+func (e *Encoder) writeSequence(el *Element) error {
+	vr := el.VR
+	if vr == "" {
+		vr = SQ
+	}
+	// UN sequences are only recognisable with an undefined length, and
+	// hold implicit VR little endian.
+	if vr == UN {
+		if e.order != codec.LE {
+			return fmt.Errorf("%w: UN sequence %s in big endian", ErrInvalidElement, el.Tag)
+		}
+		if err := e.writeHeader(el.Tag, vr, UNDEFINED_LENGTH); err != nil {
+			return err
+		}
+		return (&Encoder{w: e.w, order: e.order}).writeItems(el.Items, true)
+	}
+	if el.Undefined {
+		if err := e.writeHeader(el.Tag, vr, UNDEFINED_LENGTH); err != nil {
+			return err
+		}
+		return e.writeItems(el.Items, true)
+	}
+	b, err := e.encode(func(e *Encoder) error { return e.writeItems(el.Items, false) })
+	if err != nil {
+		return err
+	}
+	if len(b) > MAX_LENGTH {
+		return fmt.Errorf("%w: sequence of %d bytes", codec.ErrLengthOverflow, len(b))
+	}
+	if err := e.writeHeader(el.Tag, vr, uint32(len(b))); err != nil {
+		return err
+	}
+	e.w.WriteBytes(b)
+	return e.w.Err()
+}
+
+// writeItems writes the items of a sequence, followed by a sequence
+// delimitation item if undefined is set.
+func (e *Encoder) writeItems(items []Dataset, undefined bool) error {
+	for _, item := range items {
+		if undefined {
+			if err := e.writeHeader(TagItem, "", UNDEFINED_LENGTH); err != nil {
+				return err
+			}
+			if err := e.WriteDataset(item); err != nil {
+				return err
+			}
+			if err := e.writeHeader(TagItemDelimitation, "", 0); err != nil {
+				return err
+			}
+			continue
+		}
+		b, err := e.encode(func(e *Encoder) error { return e.WriteDataset(item) })
+		if err != nil {
+			return err
+		}
+		if err := e.writeHeader(TagItem, "", uint32(len(b))); err != nil {
+			return err
+		}
+		e.w.WriteBytes(b)
+	}
+	if undefined {
+		return e.writeHeader(TagSequenceDelimitation, "", 0)
+	}
+	return e.w.Err()
+}
+
+// WriteDataset writes the elements of ds.
+func (e *Encoder) WriteDataset(ds Dataset) error {
+	for _, el := range ds {
+		if err := e.WriteElement(el); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,147 @@
+//go:build test
+
+package dicom
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/oy3o/codec"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func sample() Dataset {
+	return Dataset{
+		{Tag: NewTag(0x0008, 0x0018), VR: UI, Value: []byte("1.2.3\x00")},
+		{Tag: NewTag(0x0008, 0x1115), VR: SQ, Undefined: true, Items: []Dataset{
+			{{Tag: NewTag(0x0008, 0x1150), VR: UI, Value: []byte("1.2\x00")}},
+			{{Tag: NewTag(0x0020, 0x000E), VR: UI, Value: []byte("9.8\x00")}},
+		}},
+		{Tag: NewTag(0x0010, 0x0010), VR: "PN", Value: []byte("Doe^John")},
+		{Tag: NewTag(0x0040, 0x0275), VR: SQ, Items: []Dataset{
+			{{Tag: NewTag(0x0040, 0x0009), VR: "SH", Value: []byte("ID")}},
+			nil,
+		}},
+		{Tag: NewTag(0x0029, 0x1010), VR: UN, Undefined: true, Items: []Dataset{
+			{{Tag: NewTag(0x0029, 0x0010), VR: UN, Value: []byte("PRIVATE ")}},
+		}},
+		{Tag: TagPixelData, VR: OB, Undefined: true, Fragments: [][]byte{nil, {1, 2, 3, 4}}},
+	}
+}
+
+func TestExplicit(t *testing.T) {
+	for _, ts := range []TransferSyntax{ExplicitVRLittleEndian, ExplicitVRBigEndian} {
+		ds := sample()
+		if ts.Order == codec.BE {
+			ds = ds[:4] // UN sequences are implicit VR little endian.
+		}
+		var buf bytes.Buffer
+		w, _ := codec.NewWriter(&buf)
+		require.NoError(t, NewEncoder(w, ts).WriteDataset(ds))
+		require.NoError(t, w.Flush())
+		if ts.Order == codec.LE {
+			assert.Equal(t, "\x08\x00\x18\x00UI\x06\x001.2.3\x00", buf.String()[:14])
+		} else {
+			assert.Equal(t, "\x00\x08\x00\x18UI\x00\x061.2.3\x00", buf.String()[:14])
+		}
+
+		r, _ := codec.NewReader(&buf)
+		got, err := NewDecoder(r, ts).ReadDataset()
+		require.NoError(t, err)
+		assert.Equal(t, ds, got)
+	}
+}
+
+func TestImplicit(t *testing.T) {
+	ds := sample()
+	var buf bytes.Buffer
+	w, _ := codec.NewWriter(&buf)
+	require.NoError(t, NewEncoder(w, ImplicitVRLittleEndian).WriteDataset(ds))
+	require.NoError(t, w.Flush())
+	data := buf.Bytes()
+	assert.Equal(t, "\x08\x00\x18\x00\x06\x00\x00\x001.2.3\x00", string(data[:14]))
+
+	dict := map[Tag]VR{NewTag(0x0008, 0x0018): UI, NewTag(0x0040, 0x0275): SQ}
+	r, _ := codec.NewReader(bytes.NewReader(data))
+	got, err := NewDecoder(r, ImplicitVRLittleEndian).WithDictionary(func(t Tag) VR { return dict[t] }).ReadDataset()
+	require.NoError(t, err)
+	// Without VRs on the wire, unknown elements read as UN and undefined
+	// lengths as sequences.
+	assert.Equal(t, UI, got[0].VR)
+	assert.Equal(t, SQ, got[1].VR)
+	require.Len(t, got[1].Items, 2)
+	assert.Equal(t, ds[1].Items[1][0].Value, got[1].Items[1][0].Value)
+	assert.Equal(t, UN, got[2].VR)
+	assert.Equal(t, "Doe^John", got[2].Text())
+	assert.Equal(t, ds[3].Items[0][0].Value, got[3].Items[0][0].Value)
+	assert.Equal(t, SQ, got[4].VR)
+	assert.Equal(t, ds[5], got[5])
+
+	// Without the dictionary the defined-length sequence is a plain value.
+	r, _ = codec.NewReader(bytes.NewReader(data))
+	got, err = NewDecoder(r, ImplicitVRLittleEndian).ReadDataset()
+	require.NoError(t, err)
+	assert.Equal(t, UN, got[3].VR)
+	assert.Nil(t, got[3].Items)
+}
+
+func TestInvalid(t *testing.T) {
+	read := func(ts TransferSyntax, data string) error {
+		r, _ := codec.NewReader(bytes.NewReader([]byte(data)))
+		_, err := NewDecoder(r, ts).ReadDataset()
+		return err
+	}
+	ex := ExplicitVRLittleEndian
+	assert.ErrorIs(t, read(ex, "\x10\x00\x10\x00PN\x08\x00Doe"), io.ErrUnexpectedEOF)
+	assert.ErrorIs(t, read(ex, "\xfe\xff\x0d\xe0\x00\x00\x00\x00"), ErrInvalidElement)
+	assert.ErrorIs(t, read(ex, "\x10\x00\x10\x00OB\x00\x00\xff\xff\xff\xff"), ErrInvalidElement)
+	assert.ErrorIs(t, read(ex, "\x10\x00\x10\x00OB\x00\x00\x00\x00\x00\x7f"), codec.ErrLengthOverflow)
+	// Sequences of undefined length nested past MAX_DEPTH.
+	nested := bytes.Repeat([]byte("\x08\x00\x15\x11SQ\x00\x00\xff\xff\xff\xff\xfe\xff\x00\xe0\xff\xff\xff\xff"), MAX_DEPTH+1)
+	assert.ErrorIs(t, read(ex, string(nested)), ErrTooDeep)
+
+	var buf bytes.Buffer
+	w, _ := codec.NewWriter(&buf)
+	e := NewEncoder(w, ex)
+	assert.ErrorIs(t, e.WriteElement(&Element{Tag: NewTag(0x0010, 0x0010), VR: "PN", Value: make([]byte, 1<<16)}), codec.ErrLengthOverflow)
+	assert.ErrorIs(t, e.WriteElement(&Element{Tag: NewTag(0x0010, 0x0010)}), ErrInvalidElement)
+	// Odd values are padded as their VR requires.
+	buf.Reset()
+	require.NoError(t, e.WriteElement(&Element{Tag: NewTag(0x0010, 0x0010), VR: "PN", Value: []byte("Doe")}))
+	require.NoError(t, w.Flush())
+	assert.Equal(t, "\x10\x00\x10\x00PN\x04\x00Doe ", buf.String())
+}
+
+func TestFile(t *testing.T) {
+	for _, ts := range []TransferSyntax{ImplicitVRLittleEndian, DeflatedExplicitVRLittleEndian, TransferSyntaxOf("1.2.840.10008.1.2.4.50")} {
+		f := &File{
+			Meta: Dataset{
+				{Tag: TagFileMetaInformationGroupLength, VR: UL},
+				{Tag: TagTransferSyntaxUID, VR: UI, Value: []byte(ts.UID)},
+			},
+			Dataset: Dataset{{Tag: NewTag(0x0010, 0x0010), VR: "PN", Value: []byte("Doe^John")}},
+		}
+		var buf bytes.Buffer
+		w, _ := codec.NewWriter(&buf)
+		require.NoError(t, WriteFile(w, f))
+		require.NoError(t, w.Flush())
+		data := buf.Bytes()
+		assert.Equal(t, MAGIC, string(data[128:132]))
+		assert.Equal(t, uint32(8+len(ts.UID)+len(ts.UID)&1), codec.LE.Uint32(f.Meta[0].Value))
+
+		r, _ := codec.NewReader(bytes.NewReader(data))
+		got, err := ReadFile(r, func(Tag) VR { return "PN" })
+		require.NoError(t, err)
+		f.Meta[1].Value = append(f.Meta[1].Value, make([]byte, len(ts.UID)&1)...)
+		assert.Equal(t, f, got)
+		syntax, err := got.TransferSyntax()
+		require.NoError(t, err)
+		assert.Equal(t, ts, syntax)
+	}
+
+	r, _ := codec.NewReader(bytes.NewReader(make([]byte, 132)))
+	_, err := ReadFile(r, nil)
+	assert.ErrorIs(t, err, ErrInvalidFile)
+}
@@ -0,0 +1,127 @@
+package dicom
+
+import (
+	"compress/flate"
+	"errors"
+	"fmt"
+
+	"github.com/oy3o/codec"
+)
+
+const (
+	// PREAMBLE_SIZE is the size of the preamble opening a file.
+	PREAMBLE_SIZE = 128
+	// MAGIC follows the preamble.
+	MAGIC = "DICM"
+)
+
+// ErrInvalidFile indicates a file without the DICM prefix or a transfer
+// syntax in its meta information.
+var ErrInvalidFile = errors.New("dicom: invalid file")
+
+// File is a DICOM file: a preamble, the file meta information, always in
+// explicit VR little endian, and a data set in the transfer syntax the meta
+// information names.
+type File struct {
+	Preamble [PREAMBLE_SIZE]byte
+	Meta     Dataset // group 0002 elements
+	Dataset  Dataset
+}
+
+// TransferSyntax returns the transfer syntax of the data set.
+func (f *File) TransferSyntax() (TransferSyntax, error) {
+	uid := f.Meta.Find(TagTransferSyntaxUID)
+	if uid == nil {
+		return TransferSyntax{}, fmt.Errorf("%w: no transfer syntax", ErrInvalidFile)
+	}
+	return TransferSyntaxOf(uid.Text()), nil
+}
+
+// ReadFile reads a file up to the end of the stream. The meta information
+// is read up to the first element of another group, which needs a Reader
+// that can Peek; lookup serves as in Decoder.WithDictionary and may be nil.
+func ReadFile(r *codec.Reader, lookup func(Tag) VR) (*File, error) {
+	f := new(File)
+	var magic [4]byte
+	r.ReadBytesTo(f.Preamble[:])
+	r.ReadBytesTo(magic[:])
+	if err := r.Err(); err != nil {
+		return nil, unexpected(err)
+	}
+	if string(magic[:]) != MAGIC {
+		return nil, fmt.Errorf("%w: prefix %q", ErrInvalidFile, magic)
+	}
+
+	meta := NewDecoder(r, ExplicitVRLittleEndian)
+	for {
+		b, err := r.Peek(2)
+		if len(b) < 2 || codec.LE.Uint16(b) != 0x0002 {
+			if len(b) < 2 && err != nil && len(f.Meta) == 0 {
+				return nil, unexpected(err)
+			}
+			break
+		}
+		e, err := meta.ReadElement()
+		if err != nil {
+			return nil, unexpected(err)
+		}
+		f.Meta = append(f.Meta, e)
+	}
+	ts, err := f.TransferSyntax()
+	if err != nil {
+		return nil, err
+	}
+
+	data := r
+	if ts.Deflated {
+		if data, err = codec.NewReaderSize(flate.NewReader(r), codec.BUFFER_SIZE); err != nil {
+			return nil, err
+		}
+	}
+	if f.Dataset, err = NewDecoder(data, ts).WithDictionary(lookup).ReadDataset(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// WriteFile writes a file. The file meta information group length, if
+// present in f.Meta, is updated to the size of the meta information that
+// follows it.
+func WriteFile(w *codec.Writer, f *File) error {
+	ts, err := f.TransferSyntax()
+	if err != nil {
+		return err
+	}
+	meta := NewEncoder(w, ExplicitVRLittleEndian)
+	rest := f.Meta
+	if len(rest) > 0 && rest[0].Tag == TagFileMetaInformationGroupLength {
+		rest = rest[1:]
+		b, err := meta.encode(func(e *Encoder) error { return e.WriteDataset(rest) })
+		if err != nil {
+			return err
+		}
+		f.Meta[0].VR = UL
+		f.Meta[0].Value = codec.LE.AppendUint32(nil, uint32(len(b)))
+	}
+	w.WriteBytes(f.Preamble[:])
+	w.WriteString(MAGIC)
+	if err := meta.WriteDataset(f.Meta); err != nil {
+		return err
+	}
+
+	if !ts.Deflated {
+		return NewEncoder(w, ts).WriteDataset(f.Dataset)
+	}
+	fw, _ := flate.NewWriter(w, flate.DefaultCompression)
+	data, err := codec.NewWriter(fw)
+	if err != nil {
+		return err
+	}
+	if err := NewEncoder(data, ts).WriteDataset(f.Dataset); err != nil {
+		return err
+	}
+	if err := data.Flush(); err != nil {
+		return err
+	}
+	return fw.Close()
+}
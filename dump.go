@@ -0,0 +1,42 @@
+//go:build !tinygo && !codec_tiny
+
+package codec
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io"
+	"reflect"
+	"text/tabwriter"
+)
+
+// REDACTED replaces the bytes and value of fields tagged `codec:"redact"` in dumps.
+const REDACTED = "[REDACTED]"
+
+// Dump writes a field-by-field breakdown of the encoded payload to w, listing the
+// wire offset, field name, encoded bytes and decoded value of every field.
+// Fields tagged `codec:"redact"` are rendered as REDACTED, so dumps can stay
+// enabled in production without leaking secrets to logs.
+func (c *Fixed[Payload]) Dump(w io.Writer) error {
+	data, err := c.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	l := c.layout()
+	v := reflect.ValueOf(&c.Payload).Elem()
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	if len(l.fields) == 0 {
+		fmt.Fprintf(tw, "%04x\t%s\t%s\t%v\n", 0, v.Type(), hex.EncodeToString(data), v)
+		return tw.Flush()
+	}
+	for _, f := range l.fields {
+		if f.opts.redact {
+			fmt.Fprintf(tw, "%04x\t%s\t%s\t%s\n", f.offset, f.name, REDACTED, REDACTED)
+			continue
+		}
+		fmt.Fprintf(tw, "%04x\t%s\t%s\t%v\n", f.offset, f.name,
+			hex.EncodeToString(data[f.offset:f.offset+f.size]), v.FieldByIndex(f.index))
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,754 @@
+//go:build !tinygo && !codec_tiny
+
+package codec
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/puzpuzpuz/xsync/v4"
+)
+
+// MAX_FIELD_SIZE is the default upper bound for a decoded variable-length field
+// (in bytes for strings and byte slices, in elements for other slices).
+// It prevents a corrupted or malicious length prefix from causing huge allocations.
+// Individual fields can override it with the `max=N` tag option.
+const MAX_FIELD_SIZE = 16 << 20 // 16MB
+
+// Dynamic provides a generic `Codec` implementation for structs containing
+// variable-length fields. Unlike Fixed, it supports strings, byte slices, slices,
+// nested structs and nested codecs, driven by `codec` struct tags:
+//
+//	prefix=u8|u16|u32|u64|uvarint|synchsafe  length-prefixed field
+//	null                                     null-terminated string or byte slice
+//	size=N                                   fixed-width field, zero padded
+//	count=Field                              length is stored in the earlier sibling integer Field
+//	max=N                                    upper bound enforced when decoding
+//	charset=Name                             string encoded in a charset registered with RegisterCharset
+//
+// Count fields are filled in automatically on encode from the length of the
+// field referencing them. Fixed-size fields and length prefixes are encoded like
+// Fixed does, in the byte order of the Reader or Writer, or Order for other streams.
+type Dynamic[Payload any] struct {
+	Payload Payload
+}
+
+var _ Codec = (*Dynamic[struct{}])(nil)
+
+// prefixKind selects the encoding of a length prefix.
+type prefixKind int
+
+const (
+	prefixNone prefixKind = iota
+	prefixU8
+	prefixU16
+	prefixU32
+	prefixU64
+	prefixUvarint
+	prefixSynchsafe
+)
+
+// dynKind classifies how a Dynamic field is encoded.
+type dynKind int
+
+const (
+	dynFixed  dynKind = iota // fixed-size value, encoded like encoding/binary
+	dynBytes                 // string or []byte
+	dynSlice                 // slice of any other supported element
+	dynStruct                // nested struct with variable-length fields
+	dynCodec                 // value implementing Codec
+)
+
+// dynField is the compiled encoding plan of a single struct field.
+type dynField struct {
+	name     string
+	key      string // "Type.Field", the name recorded by a Profiler
+	index    int
+	kind     dynKind
+	typ      reflect.Type
+	size     int // wire size of dynFixed fields; fixed width of size=N fields
+	prefix   prefixKind
+	null     bool
+	charset  string // registered charset of a string field, empty for UTF-8
+	max      int
+	count    int // index of the sibling holding the length, or -1
+	countFor int // index of the sibling whose length this field carries, or -1
+	elem     *dynField
+	nested   *dynLayout
+}
+
+// dynLayout is the cached encoding plan of a struct type.
+type dynLayout struct {
+	fields []dynField
+	err    error
+}
+
+var (
+	dynCache  = xsync.NewMap[reflect.Type, *dynLayout]()
+	codecType = reflect.TypeOf((*Codec)(nil)).Elem()
+)
+
+// dynLayoutOf returns the cached plan for struct type t.
+func dynLayoutOf(t reflect.Type) *dynLayout {
+	if l, ok := dynCache.Load(t); ok {
+		return l
+	}
+	building := map[reflect.Type]*dynLayout{}
+	l := buildDynLayout(t, building)
+	if l.err != nil {
+		// Layouts compiled along the way may point back into the failed one.
+		building = map[reflect.Type]*dynLayout{t: l}
+	}
+	for bt, bl := range building {
+		dynCache.Store(bt, bl)
+	}
+	return l
+}
+
+// buildDynLayout compiles the plan for t. Layouts still being compiled are
+// kept in building, so a type reaching itself through a slice shares its
+// own plan instead of recursing forever.
+func buildDynLayout(t reflect.Type, building map[reflect.Type]*dynLayout) *dynLayout {
+	if l, ok := dynCache.Load(t); ok {
+		return l
+	}
+	if l, ok := building[t]; ok {
+		return l
+	}
+	l := &dynLayout{}
+	building[t] = l
+	if t.Kind() != reflect.Struct {
+		l.err = fmt.Errorf("%w: Dynamic payload %s is not a struct", ErrInvalidTag, t)
+	} else {
+		l.err = l.compile(t, building)
+	}
+	return l
+}
+
+// compile builds the plan for the fields of t.
+func (l *dynLayout) compile(t reflect.Type, building map[reflect.Type]*dynLayout) error {
+	names := map[string]int{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		df, err := compileField(f.Type, f.Tag.Get(TAG_NAME), building)
+		if err != nil {
+			return fmt.Errorf("%w (field %s)", err, f.Name)
+		}
+		df.name, df.index = f.Name, i
+		df.key = t.String() + "." + f.Name
+		names[f.Name] = i
+		l.fields = append(l.fields, df)
+
+		if ref, ok := parseDynTag(f.Tag.Get(TAG_NAME))["count"]; ok {
+			j, ok := names[ref]
+			if !ok || j == i {
+				return fmt.Errorf("%w: count=%s must reference an earlier field (field %s)", ErrInvalidTag, ref, f.Name)
+			}
+			if k := t.Field(j).Type.Kind(); k < reflect.Int || k > reflect.Uint64 || k == reflect.Uintptr {
+				return fmt.Errorf("%w: count field %s is not an integer", ErrInvalidTag, ref)
+			}
+			l.fields[i].count = j
+			l.fields[j].countFor = i
+		}
+	}
+	return nil
+}
+
+// parseDynTag parses `key=value` options of a Dynamic field tag.
+func parseDynTag(tag string) map[string]string {
+	opts := map[string]string{}
+	for _, opt := range strings.Split(tag, ",") {
+		if opt = strings.TrimSpace(opt); opt != "" {
+			k, v, _ := strings.Cut(opt, "=")
+			opts[k] = v
+		}
+	}
+	return opts
+}
+
+// compileField builds the plan for a value of type t with the given tag.
+func compileField(t reflect.Type, tag string, building map[reflect.Type]*dynLayout) (dynField, error) {
+	df := dynField{typ: t, count: -1, countFor: -1, max: MAX_FIELD_SIZE}
+	opts := parseDynTag(tag)
+	for k, v := range opts {
+		var err error
+		switch k {
+		case "prefix":
+			switch v {
+			case "u8":
+				df.prefix = prefixU8
+			case "u16":
+				df.prefix = prefixU16
+			case "u32":
+				df.prefix = prefixU32
+			case "u64":
+				df.prefix = prefixU64
+			case "uvarint":
+				df.prefix = prefixUvarint
+			case "synchsafe":
+				df.prefix = prefixSynchsafe
+			default:
+				err = fmt.Errorf("%w: prefix=%s", ErrInvalidTag, v)
+			}
+		case "null":
+			df.null = true
+		case "size":
+			df.size, err = strconv.Atoi(v)
+		case "max":
+			df.max, err = strconv.Atoi(v)
+		case "charset":
+			if t.Kind() != reflect.String {
+				return df, fmt.Errorf("%w: charset on non-string %s", ErrInvalidTag, t)
+			}
+			df.charset = v
+		}
+		if err != nil {
+			return df, fmt.Errorf("%w: %s=%s", ErrInvalidTag, k, v)
+		}
+	}
+	_, counted := opts["count"]
+	variable := df.prefix != prefixNone || df.null || df.size > 0 || counted
+
+	switch {
+	case t.Implements(codecType) || reflect.PointerTo(t).Implements(codecType):
+		df.kind = dynCodec
+	case t.Kind() == reflect.String || (t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8):
+		df.kind = dynBytes
+		if !variable {
+			return df, fmt.Errorf("%w: %s needs one of prefix, null, size or count", ErrInvalidTag, t)
+		}
+	case t.Kind() == reflect.Slice:
+		df.kind = dynSlice
+		if df.prefix == prefixNone && !counted {
+			return df, fmt.Errorf("%w: %s needs prefix or count", ErrInvalidTag, t)
+		}
+		elem, err := compileField(t.Elem(), "", building)
+		if err != nil {
+			return df, err
+		}
+		df.elem = &elem
+	case wireSize(t) >= 0:
+		df.kind = dynFixed
+		df.size = wireSize(t)
+	case t.Kind() == reflect.Struct:
+		df.kind = dynStruct
+		df.nested = buildDynLayout(t, building)
+		if df.nested.err != nil {
+			return df, df.nested.err
+		}
+	default:
+		return df, fmt.Errorf("%w: unsupported type %s", ErrInvalidTag, t)
+	}
+	return df, nil
+}
+
+// layout returns the cached plan of the payload type.
+func (c *Dynamic[Payload]) layout() *dynLayout {
+	return dynLayoutOf(reflect.TypeOf((*Payload)(nil)).Elem())
+}
+
+// Size returns the encoded size of the current payload.
+func (c *Dynamic[Payload]) Size() int {
+	l := c.layout()
+	if l.err != nil {
+		return 0
+	}
+	return l.sizeOf(reflect.ValueOf(&c.Payload).Elem())
+}
+
+func (l *dynLayout) sizeOf(v reflect.Value) int {
+	n := 0
+	for i := range l.fields {
+		n += l.fields[i].sizeOf(v.Field(l.fields[i].index))
+	}
+	return n
+}
+
+func prefixSize(p prefixKind, n int) int {
+	switch p {
+	case prefixU8:
+		return 1
+	case prefixU16:
+		return 2
+	case prefixU32, prefixSynchsafe:
+		return 4
+	case prefixU64:
+		return 8
+	case prefixUvarint:
+		var buf [binary.MaxVarintLen64]byte
+		return binary.PutUvarint(buf[:], uint64(n))
+	}
+	return 0
+}
+
+func (f *dynField) sizeOf(v reflect.Value) int {
+	switch f.kind {
+	case dynFixed:
+		return f.size
+	case dynBytes:
+		if f.size > 0 && f.prefix == prefixNone {
+			return f.size
+		}
+		length := v.Len()
+		if f.charset != "" {
+			if b, err := f.wireBytes(v); err == nil {
+				length = len(b)
+			}
+		}
+		n := prefixSize(f.prefix, length) + length
+		if f.null {
+			n++
+		}
+		return n
+	case dynSlice:
+		n := prefixSize(f.prefix, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			n += f.elem.sizeOf(v.Index(i))
+		}
+		return n
+	case dynStruct:
+		return f.nested.sizeOf(v)
+	case dynCodec:
+		if c := asCodec(v); c != nil {
+			return c.Size()
+		}
+	}
+	return 0
+}
+
+// wireBytes returns the encoded bytes of a string or []byte field.
+func (f *dynField) wireBytes(v reflect.Value) ([]byte, error) {
+	if v.Kind() != reflect.String {
+		return v.Bytes(), nil
+	}
+	if f.charset == "" {
+		return []byte(v.String()), nil
+	}
+	enc, err := charsetOf(f.charset)
+	if err != nil {
+		return nil, err
+	}
+	return encodeText(enc, v.String())
+}
+
+// asCodec returns v as a Codec, or nil for a nil pointer.
+func asCodec(v reflect.Value) Codec {
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return nil
+		}
+		return v.Interface().(Codec)
+	}
+	return v.Addr().Interface().(Codec)
+}
+
+// WriteTo implements `io.WriterTo`.
+func (c *Dynamic[Payload]) WriteTo(writer io.Writer) (int64, error) {
+	l := c.layout()
+	if l.err != nil {
+		return 0, l.err
+	}
+	w, err := NewWriter(writer)
+	if err != nil {
+		return 0, err
+	}
+	l.encode(w, reflect.ValueOf(&c.Payload).Elem())
+	return w.Result()
+}
+
+func (l *dynLayout) encode(w *Writer, v reflect.Value) {
+	prof := profiler.Load()
+	for i := range l.fields {
+		f := &l.fields[i]
+		fv := v.Field(f.index)
+		if f.countFor >= 0 {
+			// Derive the count from the field that references it, so the
+			// encoding can never disagree with the actual length.
+			n := v.Field(l.fields[f.countFor].index).Len()
+			fv = reflect.New(fv.Type()).Elem()
+			if fv.CanInt() && !fv.OverflowInt(int64(n)) {
+				fv.SetInt(int64(n))
+			} else if fv.CanUint() && !fv.OverflowUint(uint64(n)) {
+				fv.SetUint(uint64(n))
+			} else {
+				w.setError(fmt.Errorf("%w: %s cannot hold length %d", ErrLengthOverflow, f.name, n))
+				return
+			}
+		}
+		if prof != nil {
+			start, at := time.Now(), w.count
+			f.encode(w, fv)
+			prof.Record(f.key, ProfileEncode, w.count-at, time.Since(start))
+		} else {
+			f.encode(w, fv)
+		}
+		if w.err != nil {
+			return
+		}
+	}
+}
+
+func writePrefix(w *Writer, p prefixKind, n int) {
+	var limit uint64
+	switch p {
+	case prefixU8:
+		limit = 1<<8 - 1
+	case prefixU16:
+		limit = 1<<16 - 1
+	case prefixU32:
+		limit = 1<<32 - 1
+	case prefixSynchsafe:
+		limit = MAX_SYNCHSAFE32
+	default:
+		limit = 1<<64 - 1
+	}
+	if uint64(n) > limit {
+		w.setError(fmt.Errorf("%w: length %d does not fit in prefix", ErrLengthOverflow, n))
+		return
+	}
+	switch p {
+	case prefixU8:
+		w.WriteUint8(uint8(n))
+	case prefixU16:
+		w.WriteUint16(uint16(n))
+	case prefixU32:
+		w.WriteUint32(uint32(n))
+	case prefixU64:
+		w.WriteUint64(uint64(n))
+	case prefixUvarint:
+		var buf [binary.MaxVarintLen64]byte
+		w.WriteBytes(buf[:binary.PutUvarint(buf[:], uint64(n))])
+	case prefixSynchsafe:
+		w.WriteSynchsafe32(uint32(n))
+	}
+}
+
+func (f *dynField) encode(w *Writer, v reflect.Value) {
+	switch f.kind {
+	case dynFixed:
+		var scratch [64]byte
+		buf := scratch[:0]
+		if f.size > len(scratch) {
+			buf = make([]byte, f.size)
+		} else {
+			buf = scratch[:f.size]
+		}
+		encodeValue(buf, w.order, v)
+		w.WriteBytes(buf)
+	case dynBytes:
+		b, err := f.wireBytes(v)
+		if err != nil {
+			w.setError(fmt.Errorf("%w (field %s)", err, f.name))
+			return
+		}
+		if f.null && strings.IndexByte(string(b), 0) >= 0 {
+			w.setError(fmt.Errorf("%w: %s contains a null byte", ErrInvalidTag, f.name))
+			return
+		}
+		if f.size > 0 && f.prefix == prefixNone {
+			if len(b) > f.size {
+				w.setError(fmt.Errorf("%w: %s is %d bytes, field is %d", ErrLengthOverflow, f.name, len(b), f.size))
+				return
+			}
+			w.WriteBytes(b)
+			w.WriteZeros(int64(f.size - len(b)))
+			return
+		}
+		writePrefix(w, f.prefix, len(b))
+		w.WriteBytes(b)
+		if f.null {
+			w.WriteUint8(0)
+		}
+	case dynSlice:
+		writePrefix(w, f.prefix, v.Len())
+		for i := 0; i < v.Len() && w.err == nil; i++ {
+			f.elem.encode(w, v.Index(i))
+		}
+	case dynStruct:
+		f.nested.encode(w, v)
+	case dynCodec:
+		c := asCodec(v)
+		if c == nil {
+			// Decoding always reads the value, so a nil one would desync the stream.
+			w.setError(fmt.Errorf("%w: %s %s", ErrNilField, f.name, f.typ))
+			return
+		}
+		w.WriteFrom(c)
+	}
+}
+
+// ReadFrom implements `io.ReaderFrom`. It reads exactly the bytes of the payload.
+func (c *Dynamic[Payload]) ReadFrom(r io.Reader) (int64, error) {
+	l := c.layout()
+	if l.err != nil {
+		return 0, l.err
+	}
+	e := &exactReader{r: r, order: streamOrder(r)}
+	if cr, ok := r.(*Reader); ok {
+		e.in = cr.interner
+	}
+	err := l.decode(e, reflect.ValueOf(&c.Payload).Elem())
+	return e.n, err
+}
+
+func (l *dynLayout) decode(e *exactReader, v reflect.Value) error {
+	prof := profiler.Load()
+	for i := range l.fields {
+		if prof != nil {
+			start, at := time.Now(), e.n
+			err := l.decodeField(e, v, i)
+			prof.Record(l.fields[i].key, ProfileDecode, e.n-at, time.Since(start))
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		if err := l.decodeField(e, v, i); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// decodeField decodes field i of v, resolving its length from a count field.
+func (l *dynLayout) decodeField(e *exactReader, v reflect.Value, i int) error {
+	f := &l.fields[i]
+	n := -1
+	if f.count >= 0 {
+		cv := v.Field(l.fields[f.count].index)
+		if cv.CanInt() {
+			if cv.Int() < 0 || cv.Int() > int64(f.max) {
+				return fmt.Errorf("%w: %s length %d out of range", ErrLengthOverflow, f.name, cv.Int())
+			}
+			n = int(cv.Int())
+		} else {
+			if cv.Uint() > uint64(f.max) {
+				return fmt.Errorf("%w: %s length %d exceeds %d", ErrLengthOverflow, f.name, cv.Uint(), f.max)
+			}
+			n = int(cv.Uint())
+		}
+	}
+	return f.decode(e, v.Field(f.index), n)
+}
+
+// decodePartial decodes fields in order until one fails, recording progress
+// in pe. Nested structs are descended so pe names the innermost field.
+func (l *dynLayout) decodePartial(e *exactReader, v reflect.Value, prefix string, pe *PartialError) bool {
+	for i := range l.fields {
+		f := &l.fields[i]
+		if f.kind == dynStruct {
+			if !f.nested.decodePartial(e, v.Field(f.index), prefix+f.name+".", pe) {
+				return false
+			}
+			continue
+		}
+		offset := e.n
+		if err := l.decodeField(e, v, i); err != nil {
+			pe.Field, pe.Offset, pe.Err = prefix+f.name, offset, err
+			return false
+		}
+		pe.Decoded = append(pe.Decoded, prefix+f.name)
+	}
+	return true
+}
+
+func readPrefix(e *exactReader, p prefixKind) (uint64, error) {
+	var buf [8]byte
+	switch p {
+	case prefixU8:
+		err := e.readFull(buf[:1])
+		return uint64(buf[0]), err
+	case prefixU16:
+		err := e.readFull(buf[:2])
+		return uint64(e.order.Uint16(buf[:])), err
+	case prefixU32:
+		err := e.readFull(buf[:4])
+		return uint64(e.order.Uint32(buf[:])), err
+	case prefixU64:
+		err := e.readFull(buf[:8])
+		return e.order.Uint64(buf[:]), err
+	case prefixUvarint:
+		n, err := binary.ReadUvarint(e)
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return n, err
+	case prefixSynchsafe:
+		if err := e.readFull(buf[:4]); err != nil {
+			return 0, err
+		}
+		n, err := Synchsafe32(buf[:4])
+		return uint64(n), err
+	}
+	return 0, nil
+}
+
+// decode reads a value into v. n is the length taken from a count field, or -1.
+func (f *dynField) decode(e *exactReader, v reflect.Value, n int) error {
+	switch f.kind {
+	case dynFixed:
+		var scratch [64]byte
+		buf := scratch[:0]
+		if f.size > len(scratch) {
+			buf = make([]byte, f.size)
+		} else {
+			buf = scratch[:f.size]
+		}
+		if err := e.readFull(buf); err != nil {
+			return err
+		}
+		decodeValue(buf, e.order, v)
+		return nil
+	case dynBytes:
+		var b []byte
+		switch {
+		case f.null && f.prefix == prefixNone && n < 0:
+			for {
+				c, err := e.ReadByte()
+				if err != nil {
+					if err == io.EOF {
+						err = io.ErrUnexpectedEOF
+					}
+					return err
+				}
+				if c == 0 {
+					break
+				}
+				if len(b) >= f.max {
+					return fmt.Errorf("%w: %s exceeds %d bytes", ErrLengthOverflow, f.name, f.max)
+				}
+				b = append(b, c)
+			}
+		case f.size > 0 && f.prefix == prefixNone && n < 0:
+			b = make([]byte, f.size)
+			if err := e.readFull(b); err != nil {
+				return err
+			}
+			if v.Kind() == reflect.String {
+				if i := strings.IndexByte(string(b), 0); i >= 0 {
+					b = b[:i]
+				}
+			}
+		default:
+			if n < 0 {
+				length, err := readPrefix(e, f.prefix)
+				if err != nil {
+					return err
+				}
+				if length > uint64(f.max) {
+					return fmt.Errorf("%w: %s length %d exceeds %d", ErrLengthOverflow, f.name, length, f.max)
+				}
+				n = int(length)
+			}
+			b = make([]byte, n)
+			if err := e.readFull(b); err != nil {
+				return err
+			}
+			if f.null {
+				if _, err := e.ReadByte(); err != nil {
+					return io.ErrUnexpectedEOF
+				}
+			}
+		}
+		if f.charset != "" {
+			enc, err := charsetOf(f.charset)
+			if err != nil {
+				return err
+			}
+			if b, err = decodeText(enc, b); err != nil {
+				return fmt.Errorf("%w (field %s)", err, f.name)
+			}
+		}
+		switch {
+		case v.Kind() == reflect.String && e.in != nil:
+			v.SetString(e.in.String(b))
+		case v.Kind() == reflect.String:
+			v.SetString(string(b))
+		case e.in != nil:
+			v.SetBytes(e.in.Bytes(b))
+		default:
+			v.SetBytes(b)
+		}
+		return nil
+	case dynSlice:
+		if n < 0 {
+			length, err := readPrefix(e, f.prefix)
+			if err != nil {
+				return err
+			}
+			if length > uint64(f.max) {
+				return fmt.Errorf("%w: %s count %d exceeds %d", ErrLengthOverflow, f.name, length, f.max)
+			}
+			n = int(length)
+		}
+		s := reflect.MakeSlice(v.Type(), 0, min(n, 1024))
+		for i := 0; i < n; i++ {
+			s = reflect.Append(s, reflect.Zero(v.Type().Elem()))
+			if err := f.elem.decode(e, s.Index(i), -1); err != nil {
+				return err
+			}
+		}
+		v.Set(s)
+		return nil
+	case dynStruct:
+		return f.nested.decode(e, v)
+	case dynCodec:
+		if v.Kind() == reflect.Pointer && v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		_, err := asCodec(v).ReadFrom(e)
+		return err
+	}
+	return nil
+}
+
+// UnmarshalBestEffort decodes as many fields from data as it can, for
+// recovering what remains of truncated or corrupted input. Fields before the
+// failure hold their decoded values and the failing field may be partially
+// populated. It returns nil if every field decoded, or a *PartialError
+// describing where decoding stopped. Trailing bytes are ignored.
+func (c *Dynamic[Payload]) UnmarshalBestEffort(data []byte) error {
+	l := c.layout()
+	if l.err != nil {
+		return l.err
+	}
+	e := &exactReader{r: bytes.NewReader(data), order: Order}
+	pe := &PartialError{}
+	if l.decodePartial(e, reflect.ValueOf(&c.Payload).Elem(), "", pe) {
+		return nil
+	}
+	return pe
+}
+
+// --- Boilerplate implementations ---
+
+func (c *Dynamic[Payload]) MarshalBinary() ([]byte, error) {
+	if err := c.layout().err; err != nil {
+		return nil, err
+	}
+	return MarshalBinaryGeneric(c)
+}
+
+func (c *Dynamic[Payload]) UnmarshalBinary(data []byte) error {
+	return UnmarshalBinaryGeneric(c, data)
+}
+
+func (c *Dynamic[Payload]) MarshalTo(buf []byte) (int, error) {
+	if err := c.layout().err; err != nil {
+		return 0, err
+	}
+	return MarshalToGeneric(c, buf)
+}
+
+func (c *Dynamic[Payload]) MarshalAppend(dst []byte) ([]byte, error) {
+	if err := c.layout().err; err != nil {
+		return dst, err
+	}
+	return MarshalAppendGeneric(c, dst)
+}
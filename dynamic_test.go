@@ -0,0 +1,366 @@
+//go:build test && !tinygo && !codec_tiny
+
+package codec
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/japanese"
+)
+
+type dynPoint struct {
+	X, Y int16
+}
+
+type dynMessage struct {
+	Type    uint8
+	Name    string `codec:"prefix=u8"`
+	Host    string `codec:"null"`
+	Code    string `codec:"size=4"`
+	N       uint16
+	Points  []dynPoint `codec:"count=N"`
+	Blob    []byte     `codec:"prefix=uvarint"`
+	Header  *Fixed[mockPayload]
+	Trailer Fixed[mockPayload]
+}
+
+func TestDynamicRoundTrip(t *testing.T) {
+	in := &Dynamic[dynMessage]{dynMessage{
+		Type:    7,
+		Name:    "gopher",
+		Host:    "example.org",
+		Code:    "OK",
+		Points:  []dynPoint{{1, -1}, {2, -2}},
+		Blob:    bytes.Repeat([]byte{0xAB}, 200),
+		Header:  &Fixed[mockPayload]{Payload: mockPayload{ID: 9}},
+		Trailer: Fixed[mockPayload]{Payload: mockPayload{ID: 10}},
+	}}
+
+	data, err := in.MarshalBinary()
+	require.NoError(t, err)
+	assert.Len(t, data, in.Size())
+	assert.Equal(t, 1+7+12+4+2+8+2+200+8+8, len(data))
+
+	var out Dynamic[dynMessage]
+	n, err := out.ReadFrom(bytes.NewBuffer(append(data, 0xFF)))
+	require.NoError(t, err)
+	assert.EqualValues(t, len(data), n, "decoding must not read past the payload")
+
+	in.Payload.N = 2 // filled in from len(Points) on encode
+	assert.Equal(t, in.Payload, out.Payload)
+}
+
+func TestDynamicErrors(t *testing.T) {
+	t.Run("UntaggedString", func(t *testing.T) {
+		type bad struct{ S string }
+		_, err := (&Dynamic[bad]{}).MarshalBinary()
+		assert.ErrorIs(t, err, ErrInvalidTag)
+	})
+
+	t.Run("PrefixOverflow", func(t *testing.T) {
+		type small struct {
+			S string `codec:"prefix=u8"`
+		}
+		_, err := (&Dynamic[small]{small{S: string(make([]byte, 300))}}).MarshalBinary()
+		assert.ErrorIs(t, err, ErrLengthOverflow)
+	})
+
+	t.Run("MaxLength", func(t *testing.T) {
+		type limited struct {
+			B []byte `codec:"prefix=u16,max=4"`
+		}
+		var c Dynamic[limited]
+		err := c.UnmarshalBinary([]byte{0x00, 0x05, 1, 2, 3, 4, 5})
+		assert.ErrorIs(t, err, ErrLengthOverflow)
+	})
+
+	t.Run("NilCodec", func(t *testing.T) {
+		_, err := (&Dynamic[dynMessage]{dynMessage{Name: "x", Host: "y"}}).MarshalBinary()
+		assert.ErrorIs(t, err, ErrNilField)
+	})
+}
+
+func TestDynamicSynchsafePrefix(t *testing.T) {
+	type frame struct {
+		Body []byte `codec:"prefix=synchsafe"`
+	}
+	data, err := (&Dynamic[frame]{frame{make([]byte, 200)}}).MarshalBinary()
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0, 0, 0x01, 0x48}, data[:4])
+	var out Dynamic[frame]
+	require.NoError(t, out.UnmarshalBinary(data))
+	assert.Len(t, out.Payload.Body, 200)
+}
+
+func TestDynamicStreamOrder(t *testing.T) {
+	type frame struct {
+		ID   uint32
+		Body []byte `codec:"prefix=u16"`
+	}
+	var buf bytes.Buffer
+	w, err := NewWriterOpts(&buf, WithOrder(LE))
+	require.NoError(t, err)
+	_, err = (&Dynamic[frame]{frame{0x01020304, []byte("ab")}}).WriteTo(w)
+	require.NoError(t, err)
+	require.NoError(t, w.Flush())
+	assert.Equal(t, []byte{4, 3, 2, 1, 2, 0, 'a', 'b'}, buf.Bytes())
+
+	r, err := NewReaderOpts(bytes.NewReader(buf.Bytes()), WithOrder(LE))
+	require.NoError(t, err)
+	var out Dynamic[frame]
+	_, err = out.ReadFrom(r)
+	require.NoError(t, err)
+	assert.Equal(t, frame{0x01020304, []byte("ab")}, out.Payload)
+}
+
+type dynNode struct {
+	V        uint8
+	Children []dynNode `codec:"prefix=u8"`
+}
+
+func TestDynamicRecursive(t *testing.T) {
+	in := &Dynamic[dynNode]{dynNode{V: 1, Children: []dynNode{
+		{V: 2, Children: []dynNode{}},
+		{V: 3, Children: []dynNode{{V: 4, Children: []dynNode{}}}},
+	}}}
+
+	data, err := in.MarshalBinary()
+	require.NoError(t, err)
+	assert.Equal(t, []byte{1, 2, 2, 0, 3, 1, 4, 0}, data)
+
+	var out Dynamic[dynNode]
+	require.NoError(t, out.UnmarshalBinary(data))
+	assert.Equal(t, in.Payload, out.Payload)
+}
+
+func TestDynamicInterner(t *testing.T) {
+	type record struct {
+		Tag  string `codec:"prefix=u8"`
+		Kind []byte `codec:"prefix=u8"`
+	}
+	var buf bytes.Buffer
+	for i := 0; i < 100; i++ {
+		rec := &Dynamic[record]{record{Tag: []string{"alpha", "beta"}[i%2], Kind: []byte("k")}}
+		_, err := rec.WriteTo(&buf)
+		require.NoError(t, err)
+	}
+
+	in := NewInterner(0)
+	r, err := NewReader(&buf)
+	require.NoError(t, err)
+	r.WithInterner(in)
+
+	var first, third Dynamic[record]
+	_, err = first.ReadFrom(r)
+	require.NoError(t, err)
+	for i := 1; i < 100; i++ {
+		var rec Dynamic[record]
+		_, err = rec.ReadFrom(r)
+		require.NoError(t, err)
+		if i == 2 {
+			third = rec
+		}
+	}
+	assert.Equal(t, "alpha", third.Payload.Tag)
+	assert.Same(t, &first.Payload.Kind[0], &third.Payload.Kind[0])
+	assert.Equal(t, InternStats{Hits: 197, Misses: 3, Entries: 3, Bytes: 10}, in.Stats())
+
+	capped := NewInterner(4)
+	assert.Equal(t, "alpha", capped.String([]byte("alpha")))
+	assert.Equal(t, "alpha", capped.String([]byte("alpha")))
+	assert.Equal(t, InternStats{Misses: 2}, capped.Stats())
+}
+
+func TestReaderReadString(t *testing.T) {
+	in := NewInterner(0)
+	r, err := NewReader(bytes.NewReader([]byte("abcabc")))
+	require.NoError(t, err)
+	r.WithInterner(in)
+
+	var a, b string
+	r.ReadString(&a, 3)
+	r.ReadString(&b, 3)
+	require.NoError(t, r.Err())
+	assert.Equal(t, "abc", b)
+	assert.EqualValues(t, 1, in.Stats().Hits)
+}
+
+func TestDecoderWriter(t *testing.T) {
+	type msg struct {
+		Name string `codec:"prefix=u8"`
+		N    uint32
+	}
+	var stream []byte
+	for _, name := range []string{"a", "bb", "ccc"} {
+		enc, err := (&Dynamic[msg]{msg{Name: name, N: uint32(len(name))}}).MarshalBinary()
+		require.NoError(t, err)
+		stream = append(stream, enc...)
+	}
+
+	var got []string
+	d := NewDecoderWriter(func() *Dynamic[msg] { return &Dynamic[msg]{} }, func(m *Dynamic[msg]) error {
+		got = append(got, m.Payload.Name)
+		return nil
+	})
+	// Push one byte at a time, as a non-blocking socket might.
+	for i := range stream {
+		n, err := d.Write(stream[i : i+1])
+		require.NoError(t, err)
+		assert.Equal(t, 1, n)
+	}
+	assert.Equal(t, []string{"a", "bb", "ccc"}, got)
+	assert.NoError(t, d.Close())
+
+	_, err := d.Write(stream[:3])
+	require.NoError(t, err)
+	assert.Equal(t, 3, d.Buffered())
+	assert.ErrorIs(t, d.Close(), ErrTruncatedData)
+
+	limited := NewDecoderWriter(func() *Dynamic[msg] { return &Dynamic[msg]{} }, func(*Dynamic[msg]) error { return nil }).WithMaxSize(2)
+	_, err = limited.Write([]byte{200, 'x', 'y'})
+	assert.ErrorIs(t, err, ErrLengthOverflow)
+}
+
+func TestUnmarshalBestEffort(t *testing.T) {
+	in := &Dynamic[dynMessage]{dynMessage{
+		Type:   7,
+		Name:   "gopher",
+		Host:   "example.org",
+		Code:   "OK",
+		Points: []dynPoint{{1, -1}, {2, -2}},
+		Header: &Fixed[mockPayload]{},
+	}}
+	data, err := in.MarshalBinary()
+	require.NoError(t, err)
+
+	// Cut inside the second point.
+	var out Dynamic[dynMessage]
+	err = out.UnmarshalBestEffort(data[:1+7+12+4+2+6])
+	var pe *PartialError
+	require.ErrorAs(t, err, &pe)
+	assert.Equal(t, "Points", pe.Field)
+	assert.EqualValues(t, 1+7+12+4+2, pe.Offset)
+	assert.Equal(t, []string{"Type", "Name", "Host", "Code", "N"}, pe.Decoded)
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	assert.Equal(t, "example.org", out.Payload.Host)
+
+	require.NoError(t, out.UnmarshalBestEffort(data))
+
+	type inner struct{ A, B uint16 }
+	type outer struct {
+		X  uint8
+		In inner
+		Y  uint32 `codec:"le"`
+	}
+	var f Fixed[outer]
+	err = f.UnmarshalBestEffort([]byte{1, 0, 2, 0})
+	require.ErrorAs(t, err, &pe)
+	assert.Equal(t, "In.B", pe.Field)
+	assert.EqualValues(t, 3, pe.Offset)
+	assert.Equal(t, []string{"X", "In.A"}, pe.Decoded)
+	assert.ErrorIs(t, err, ErrTruncatedData)
+	assert.Equal(t, outer{X: 1, In: inner{A: 2}}, f.Payload)
+
+	type padded struct {
+		A uint8
+		_ [2]byte `codec:"strict"`
+		B uint8
+	}
+	var p Fixed[padded]
+	err = p.UnmarshalBestEffort([]byte{5, 0, 1, 6})
+	require.ErrorAs(t, err, &pe)
+	assert.ErrorIs(t, err, ErrNonZeroPadding)
+	assert.EqualValues(t, 1, pe.Offset)
+	assert.Equal(t, []string{"A"}, pe.Decoded)
+
+	require.NoError(t, p.UnmarshalBestEffort([]byte{5, 0, 0, 6, 9}))
+	assert.EqualValues(t, 6, p.Payload.B)
+}
+
+func TestProfiler(t *testing.T) {
+	p := NewProfiler()
+	prev := SetProfiler(p)
+	defer SetProfiler(prev)
+
+	in := &Dynamic[dynMessage]{dynMessage{
+		Name:   "gopher",
+		Points: []dynPoint{{1, -1}},
+		Blob:   make([]byte, 100),
+		Header: &Fixed[mockPayload]{},
+	}}
+	for range 3 {
+		data, err := in.MarshalBinary()
+		require.NoError(t, err)
+		var out Dynamic[dynMessage]
+		require.NoError(t, out.UnmarshalBinary(data))
+	}
+	wrapped := p.Wrap("header", &Fixed[mockPayload]{})
+	_, err := wrapped.MarshalBinary()
+	require.NoError(t, err)
+
+	stats := map[string]ProfileStat{}
+	for _, s := range p.Stats() {
+		stats[s.Op.String()+" "+s.Name] = s
+	}
+	blob := stats["encode codec.dynMessage.Blob"]
+	assert.EqualValues(t, 3, blob.Calls)
+	assert.EqualValues(t, 3*101, blob.Bytes)
+	assert.EqualValues(t, 3*7, stats["decode codec.dynMessage.Name"].Bytes)
+	assert.EqualValues(t, 8, stats["encode header"].Bytes)
+
+	var report bytes.Buffer
+	require.NoError(t, p.Report(&report))
+	assert.Contains(t, report.String(), "codec.dynMessage.Points")
+
+	p.Reset()
+	assert.Empty(t, p.Stats())
+}
+
+func TestCharsets(t *testing.T) {
+	RegisterCharset("ebcdic", charmap.CodePage037)
+	RegisterCharset("latin1", charmap.ISO8859_1)
+	RegisterCharset("sjis", japanese.ShiftJIS)
+
+	type legacy struct {
+		Name string `codec:"prefix=u8,charset=ebcdic"`
+		City string `codec:"size=6,charset=latin1"`
+		Note string `codec:"null,charset=sjis"`
+	}
+	in := &Dynamic[legacy]{legacy{Name: "ABC", City: "Zürich", Note: "日本"}}
+	data, err := in.MarshalBinary()
+	require.NoError(t, err)
+	assert.Equal(t, []byte{3, 0xC1, 0xC2, 0xC3, 'Z', 0xFC, 'r', 'i', 'c', 'h', 0x93, 0xFA, 0x96, 0x7B, 0}, data)
+	assert.Equal(t, len(data), in.Size())
+
+	var out Dynamic[legacy]
+	require.NoError(t, out.UnmarshalBinary(data))
+	assert.Equal(t, in.Payload, out.Payload)
+
+	_, err = (&Dynamic[legacy]{legacy{Name: "日"}}).MarshalBinary()
+	assert.ErrorIs(t, err, ErrInvalidText)
+	type unknown struct {
+		S string `codec:"prefix=u8,charset=klingon"`
+	}
+	_, err = (&Dynamic[unknown]{}).MarshalBinary()
+	assert.ErrorIs(t, err, ErrUnknownCharset)
+
+	var buf bytes.Buffer
+	w, _ := NewWriter(&buf)
+	w.WithCharset(charmap.CodePage037).WriteText("OK")
+	n, err := w.TextSize("OK")
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+	require.NoError(t, w.Flush())
+	assert.Equal(t, []byte{0xD6, 0xD2}, buf.Bytes())
+
+	var s string
+	r, _ := NewReader(&buf)
+	r.WithCharset(charmap.CodePage037).ReadString(&s, 2)
+	require.NoError(t, r.Err())
+	assert.Equal(t, "OK", s)
+}
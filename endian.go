@@ -0,0 +1,40 @@
+package codec
+
+import (
+	"encoding/binary"
+
+	"golang.org/x/exp/constraints"
+)
+
+// Scalar is the set of types BigEndian and LittleEndian can wrap.
+type Scalar interface {
+	constraints.Integer | constraints.Float
+}
+
+// orderedField is implemented by field wrappers that pin their own byte order.
+type orderedField interface {
+	byteOrder() binary.ByteOrder
+}
+
+// BigEndian wraps a struct field so Fixed and Dynamic encode it big-endian
+// regardless of Order, keeping mixed-endian headers declarative:
+//
+//	type Header struct {
+//		Magic  codec.BigEndian[uint32]
+//		Length codec.LittleEndian[uint16]
+//	}
+//
+// It is equivalent to tagging the field `codec:"be"`; an explicit tag wins.
+type BigEndian[T Scalar] struct {
+	Value T
+}
+
+func (BigEndian[T]) byteOrder() binary.ByteOrder { return BE }
+
+// LittleEndian wraps a struct field so it is encoded little-endian regardless
+// of Order. See BigEndian.
+type LittleEndian[T Scalar] struct {
+	Value T
+}
+
+func (LittleEndian[T]) byteOrder() binary.ByteOrder { return LE }
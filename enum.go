@@ -0,0 +1,202 @@
+package codec
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sync"
+	"unsafe"
+
+	"golang.org/x/exp/constraints"
+)
+
+// enumSet holds the registered values of an enum type.
+type enumSet[T constraints.Integer] struct {
+	mu     sync.RWMutex
+	values map[T]struct{}
+	ranges [][2]T
+}
+
+// enumSets maps a typed nil *T to the *enumSet[T] of T. Keying by a typed nil
+// pointer gives each enum type its own entry without reflection.
+var enumSets sync.Map
+
+// enumSetOf returns the set registered for T, creating it if create is set.
+func enumSetOf[T constraints.Integer](create bool) *enumSet[T] {
+	key := any((*T)(nil))
+	if s, ok := enumSets.Load(key); ok {
+		return s.(*enumSet[T])
+	}
+	if !create {
+		return nil
+	}
+	s, _ := enumSets.LoadOrStore(key, &enumSet[T]{values: make(map[T]struct{})})
+	return s.(*enumSet[T])
+}
+
+// RegisterEnum adds values to the valid values of the enum type T.
+// Declare T as a named type (e.g. `type Opcode uint8`) so that its registration
+// does not affect other integers.
+func RegisterEnum[T constraints.Integer](values ...T) {
+	s := enumSetOf[T](true)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, v := range values {
+		s.values[v] = struct{}{}
+	}
+}
+
+// RegisterEnumRange adds the inclusive range [lo, hi] to the valid values of T.
+func RegisterEnumRange[T constraints.Integer](lo, hi T) {
+	s := enumSetOf[T](true)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ranges = append(s.ranges, [2]T{lo, hi})
+}
+
+// Enum is a Codec for an integer discriminator restricted to the values
+// registered for T with RegisterEnum and RegisterEnumRange. It is encoded as
+// the underlying integer in the byte order of the Reader or Writer it is read
+// from or written to, and in Order otherwise. Invalid values are rejected on
+// both encode and decode with ErrInvalidEnum, so garbage discriminators never
+// propagate into the rest of a parser. Types without registered values accept any value.
+//
+// T should be a fixed-size integer type; int and uint take 8 bytes on 64-bit
+// platforms only.
+type Enum[T constraints.Integer] struct {
+	Value T
+}
+
+// Statically assert that Enum implements Codec.
+var _ Codec = (*Enum[uint8])(nil)
+
+// Valid reports whether the value is registered for T.
+func (c *Enum[T]) Valid() bool {
+	s := enumSetOf[T](false)
+	if s == nil {
+		return true
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if _, ok := s.values[c.Value]; ok {
+		return true
+	}
+	for _, r := range s.ranges {
+		if c.Value >= r[0] && c.Value <= r[1] {
+			return true
+		}
+	}
+	return false
+}
+
+// Size returns the size of T in bytes.
+func (c *Enum[T]) Size() int {
+	return int(unsafe.Sizeof(c.Value))
+}
+
+// MarshalTo validates the value and encodes it into p.
+func (c *Enum[T]) MarshalTo(p []byte) (int, error) {
+	return c.encode(p, Order)
+}
+
+// encode validates the value and encodes it into p in order.
+func (c *Enum[T]) encode(p []byte, order binary.ByteOrder) (int, error) {
+	if !c.Valid() {
+		return 0, fmt.Errorf("%w: %T(%d)", ErrInvalidEnum, c.Value, c.Value)
+	}
+	size := c.Size()
+	if len(p) < size {
+		return 0, io.ErrShortWrite
+	}
+	switch size {
+	case 1:
+		p[0] = byte(c.Value)
+	case 2:
+		order.PutUint16(p, uint16(c.Value))
+	case 4:
+		order.PutUint32(p, uint32(c.Value))
+	default:
+		order.PutUint64(p, uint64(c.Value))
+	}
+	return size, nil
+}
+
+// ReadFrom reads and validates the value. When r reports its position through
+// a Count method, as Reader does, errors include the offset of the value.
+func (c *Enum[T]) ReadFrom(r io.Reader) (int64, error) {
+	var offset int64 = -1
+	if cr, ok := r.(interface{ Count() int64 }); ok {
+		offset = cr.Count()
+	}
+	var buf [8]byte
+	size := c.Size()
+	if n, err := io.ReadFull(r, buf[:size]); err != nil {
+		return int64(n), err
+	}
+	v, err := c.decode(buf[:size], streamOrder(r), offset)
+	if err != nil {
+		return int64(size), err
+	}
+	c.Value = v
+	return int64(size), nil
+}
+
+// UnmarshalBinary decodes and validates the value at the start of data.
+func (c *Enum[T]) UnmarshalBinary(data []byte) error {
+	size := c.Size()
+	if len(data) < size {
+		return ErrTruncatedData
+	}
+	v, err := c.decode(data[:size], Order, 0)
+	if err != nil {
+		return err
+	}
+	c.Value = v
+	return CheckBufferNotZeros(data[size:])
+}
+
+// decode converts buf to T in order and validates it; offset is -1 if unknown.
+func (c *Enum[T]) decode(buf []byte, order binary.ByteOrder, offset int64) (T, error) {
+	var v T
+	switch len(buf) {
+	case 1:
+		v = T(buf[0])
+	case 2:
+		v = T(order.Uint16(buf))
+	case 4:
+		v = T(order.Uint32(buf))
+	default:
+		v = T(order.Uint64(buf))
+	}
+	if e := (Enum[T]{v}); !e.Valid() {
+		if offset < 0 {
+			return v, fmt.Errorf("%w: %T(%d)", ErrInvalidEnum, v, v)
+		}
+		return v, fmt.Errorf("%w: %T(%d) at offset %d", ErrInvalidEnum, v, v, offset)
+	}
+	return v, nil
+}
+
+// --- Boilerplate implementations ---
+
+func (c *Enum[T]) MarshalBinary() ([]byte, error) {
+	buf := make([]byte, c.Size())
+	if _, err := c.MarshalTo(buf); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+func (c *Enum[T]) WriteTo(w io.Writer) (int64, error) {
+	var buf [8]byte
+	n, err := c.encode(buf[:c.Size()], streamOrder(w))
+	if err != nil {
+		return 0, err
+	}
+	m, err := w.Write(buf[:n])
+	return int64(m), err
+}
+
+func (c *Enum[T]) MarshalAppend(dst []byte) ([]byte, error) {
+	return MarshalAppendGeneric(c, dst)
+}
@@ -0,0 +1,50 @@
+//go:build test
+
+package codec
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testOpcode uint16
+
+type testSigned int8
+
+func TestEnum(t *testing.T) {
+	RegisterEnum[testOpcode](1, 2, 0x100)
+	RegisterEnumRange[testOpcode](0x200, 0x2FF)
+
+	data, err := (&Enum[testOpcode]{0x100}).MarshalBinary()
+	require.NoError(t, err)
+	assert.Equal(t, []byte{1, 0}, data)
+
+	_, err = (&Enum[testOpcode]{3}).MarshalBinary()
+	assert.ErrorIs(t, err, ErrInvalidEnum)
+
+	var op Enum[testOpcode]
+	require.NoError(t, op.UnmarshalBinary([]byte{0x02, 0x80}))
+	assert.EqualValues(t, 0x280, op.Value)
+
+	r, err := NewReader(bytes.NewReader([]byte{0, 1, 0, 9}))
+	require.NoError(t, err)
+	_, err = op.ReadFrom(r)
+	require.NoError(t, err)
+	_, err = op.ReadFrom(r)
+	assert.ErrorIs(t, err, ErrInvalidEnum)
+	assert.ErrorContains(t, err, "testOpcode(9) at offset 2")
+	assert.EqualValues(t, 1, op.Value, "invalid values must not be stored")
+
+	// Unregistered types accept any value; signed values round-trip.
+	RegisterEnumRange[testSigned](-3, -1)
+	s := &Enum[testSigned]{-2}
+	data, err = s.MarshalBinary()
+	require.NoError(t, err)
+	var back Enum[testSigned]
+	require.NoError(t, back.UnmarshalBinary(data))
+	assert.EqualValues(t, -2, back.Value)
+	assert.True(t, (&Enum[uint32]{12345}).Valid())
+}
@@ -0,0 +1,89 @@
+package codec
+
+import (
+	"io"
+	"runtime"
+	"strings"
+)
+
+// failure records where a Reader or Writer latched its error.
+type failure struct {
+	set     bool
+	op      string
+	offset  int64
+	context string
+}
+
+// locate captures the failing method from the call stack. It runs once per
+// stream, when the first error is latched.
+func locate(offset int64, context []string) failure {
+	return failure{set: true, op: callerOp(), offset: offset, context: strings.Join(context, ".")}
+}
+
+// wrap returns err as a DecodeError, leaving a clean end of stream alone so
+// that err == io.EOF checks keep working.
+func (f failure) wrap(err error) error {
+	if err == nil || err == io.EOF || !f.set {
+		return err
+	}
+	return &DecodeError{Op: f.op, Offset: f.offset, Context: f.context, Err: err}
+}
+
+const pkgPrefix = "github.com/oy3o/codec."
+
+// callerOp returns the outermost exported Reader or Writer method on the
+// stack, walking through this package and the standard library until the
+// first caller outside them.
+func callerOp() string {
+	var pcs [32]uintptr
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(3, pcs[:])])
+	op := ""
+	for {
+		f, more := frames.Next()
+		if rest, ok := strings.CutPrefix(f.Function, pkgPrefix); ok {
+			for _, recv := range []string{"(*Reader).", "(*Writer)."} {
+				if m, ok := strings.CutPrefix(rest, recv); ok && m != "" && m[0] >= 'A' && m[0] <= 'Z' {
+					op = m
+				}
+			}
+		} else if !isStd(f.Function) {
+			return op
+		}
+		if !more {
+			return op
+		}
+	}
+}
+
+// isStd reports whether function belongs to the standard library, whose
+// import paths have no dot in their first element.
+func isStd(function string) bool {
+	if strings.HasPrefix(function, "main.") {
+		return false
+	}
+	first, _, nested := strings.Cut(function, "/")
+	return !nested || !strings.Contains(first, ".")
+}
+
+// PushContext appends name to the context path reported by DecodeError, so
+// pushing "Header" then "Flags" locates errors in "Header.Flags". Pair each
+// call with PopContext.
+func (r *Reader) PushContext(name string) { r.context = append(r.context, name) }
+
+// PopContext removes the last name pushed by PushContext.
+func (r *Reader) PopContext() {
+	if n := len(r.context); n > 0 {
+		r.context = r.context[:n-1]
+	}
+}
+
+// PushContext appends name to the context path reported by DecodeError.
+// Pair each call with PopContext.
+func (w *Writer) PushContext(name string) { w.context = append(w.context, name) }
+
+// PopContext removes the last name pushed by PushContext.
+func (w *Writer) PopContext() {
+	if n := len(w.context); n > 0 {
+		w.context = w.context[:n-1]
+	}
+}
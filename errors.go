@@ -1,6 +1,10 @@
 package codec
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
 
 var (
 	// ErrNilIO indicates that NewReader/NewWriter was called with an nil interface
@@ -44,4 +48,162 @@ var (
 	// ErrTruncatedData indicates that a read operation could not complete because the
 	// underlying data source (e.g., buffer, stream) ended before all expected bytes were read.
 	ErrTruncatedData = errors.New("codec: truncated data")
+
+	// ErrNoSyncMarker indicates Resync was called on a Reader without a configured sync marker.
+	ErrNoSyncMarker = errors.New("codec: resync requires a sync marker")
+
+	// ErrInvalidTimeFormat indicates an unknown TimeFormat was passed to ReadTime/WriteTime.
+	ErrInvalidTimeFormat = errors.New("codec: invalid time format")
+
+	// ErrTimeOutOfRange indicates a time or duration cannot be represented losslessly in the requested format.
+	ErrTimeOutOfRange = errors.New("codec: time not representable in format")
+
+	// ErrInvalidAddr indicates an attempt to encode the zero netip.Addr.
+	ErrInvalidAddr = errors.New("codec: invalid ip address")
+
+	// ErrInvalidAddrFamily indicates an unknown address family byte was decoded.
+	ErrInvalidAddrFamily = errors.New("codec: unknown address family")
+
+	// ErrUnknownTransformer indicates a field references a transformer that was never registered.
+	ErrUnknownTransformer = errors.New("codec: unknown field transformer")
+
+	// ErrNilField indicates a nil pointer field that Dynamic cannot encode decodably.
+	ErrNilField = errors.New("codec: nil field cannot be encoded")
+
+	// ErrInvalidBitCount indicates a bit field width outside the supported 0..64 range.
+	ErrInvalidBitCount = errors.New("codec: invalid bit count")
+
+	// ErrNotFixedSize indicates a type without a fixed binary size was used where one is required.
+	ErrNotFixedSize = errors.New("codec: type has no fixed binary size")
+
+	// ErrInvalidTag indicates a malformed or unsupported `codec` struct tag.
+	ErrInvalidTag = errors.New("codec: invalid struct tag")
+
+	// ErrLengthOverflow indicates a length that does not fit its prefix or exceeds the configured limit.
+	ErrLengthOverflow = errors.New("codec: length out of range")
+
+	// ErrNonZeroPadding indicates a strict padding region or blank field contained non-zero bytes.
+	ErrNonZeroPadding = errors.New("codec: non-zero padding")
+
+	// ErrUnknownVersion indicates a Versioned payload newer than any layout known to the decoder.
+	ErrUnknownVersion = errors.New("codec: unknown payload version")
+
+	// ErrUnknownVariant indicates a union tag or value type that is not registered in its schema.
+	ErrUnknownVariant = errors.New("codec: unknown union variant")
+
+	// ErrInvalidEnum indicates an enum value outside the values registered for its type.
+	ErrInvalidEnum = errors.New("codec: invalid enum value")
+
+	// ErrMagicMismatch indicates decoded bytes did not match an expected magic number.
+	ErrMagicMismatch = errors.New("codec: magic mismatch")
+
+	// ErrNotPatchable indicates a Writer destination that cannot rewrite bytes already written.
+	ErrNotPatchable = errors.New("codec: writer does not support patching")
+
+	// ErrUndefinedLabel indicates a reference to a label that was never marked.
+	ErrUndefinedLabel = errors.New("codec: undefined label")
+
+	// ErrInvalidDER indicates identifier or length octets that are not minimally encoded DER.
+	ErrInvalidDER = errors.New("codec: invalid DER encoding")
+
+	// ErrIndefiniteLength indicates a BER indefinite-length element, which DER forbids.
+	ErrIndefiniteLength = errors.New("codec: indefinite length not supported")
+
+	// ErrChecksumMismatch indicates data whose stored checksum does not match its contents.
+	ErrChecksumMismatch = errors.New("codec: checksum mismatch")
+
+	// ErrInvalidSchema indicates a schema that is malformed or incompatible with the one it replaces.
+	ErrInvalidSchema = errors.New("codec: invalid schema")
+
+	// ErrUnknownCharset indicates a field references a charset that was never registered.
+	ErrUnknownCharset = errors.New("codec: unknown charset")
+
+	// ErrInvalidText indicates text that cannot be represented in, or decoded from, its charset.
+	ErrInvalidText = errors.New("codec: invalid text for charset")
+
+	// ErrInvalidSampleFormat indicates an unknown or unusable audio sample format.
+	ErrInvalidSampleFormat = errors.New("codec: invalid sample format")
+
+	// ErrInvalidImageLayout indicates an unknown pixel format or mismatched image dimensions.
+	ErrInvalidImageLayout = errors.New("codec: invalid image layout")
+
+	// ErrUnknownCompression indicates a compression method that was never registered.
+	ErrUnknownCompression = errors.New("codec: unknown compression method")
+
+	// ErrInvalidAEAD indicates an AEAD unsuitable for sealed streams.
+	ErrInvalidAEAD = errors.New("codec: unsupported AEAD")
+
+	// ErrAuthFailed indicates sealed data that failed authentication: it was
+	// corrupted, tampered with, reordered or opened with the wrong key.
+	ErrAuthFailed = errors.New("codec: message authentication failed")
+
+	// ErrClosed indicates a write after Close.
+	ErrClosed = errors.New("codec: write after close")
+
+	// ErrInvalidSynchsafe indicates a synchsafe integer byte with its top bit set.
+	ErrInvalidSynchsafe = errors.New("codec: invalid synchsafe integer")
+
+	// ErrInvalidUnread indicates an unread of more bytes than were read.
+	ErrInvalidUnread = errors.New("codec: unread beyond the bytes read")
+
+	// ErrNoTransaction indicates a Commit or Rollback without a matching Begin.
+	ErrNoTransaction = errors.New("codec: no transaction in progress")
+
+	// ErrPlaceholderSize indicates a value whose size differs from the placeholder it fills.
+	ErrPlaceholderSize = errors.New("codec: value size does not match placeholder")
+
+	// ErrNoLengthPrefix indicates EndLengthPrefixed without a matching BeginLengthPrefixed.
+	ErrNoLengthPrefix = errors.New("codec: no length-prefixed region to end")
+
+	// ErrSectionFull indicates a write past the end of a SectionWriter.
+	ErrSectionFull = errors.New("codec: write past the end of the section")
+
+	// ErrSectionRemainder indicates a strict section closed before all of its bytes were read.
+	ErrSectionRemainder = errors.New("codec: unread bytes left in the section")
 )
+
+// PartialError reports where a best-effort decode stopped. Fields listed in
+// Decoded hold valid values; Field names the field that failed, as a dotted
+// path for nested structs. It unwraps to the underlying cause.
+type PartialError struct {
+	Field   string   // field at which decoding stopped
+	Offset  int64    // byte offset of Field within the payload
+	Decoded []string // fields decoded successfully, in wire order
+	Err     error    // cause, e.g. ErrTruncatedData or io.ErrUnexpectedEOF
+}
+
+func (e *PartialError) Error() string {
+	return fmt.Sprintf("codec: decoded %d fields, stopped at %s (offset %d): %v", len(e.Decoded), e.Field, e.Offset, e.Err)
+}
+
+func (e *PartialError) Unwrap() error { return e.Err }
+
+// DecodeError locates the error latched by a Reader or Writer: the method that
+// failed, the offset the stream had reached and the context path pushed with
+// PushContext. Err and Result return one for every latched error except a
+// clean io.EOF. It unwraps to the cause, so errors.Is keeps working.
+type DecodeError struct {
+	Op      string // failing method, such as "ReadUint32"; empty if unknown
+	Offset  int64  // Count when the error was latched
+	Context string // dotted path such as "Header.Flags"; empty if none was pushed
+	Err     error  // cause, e.g. io.ErrUnexpectedEOF
+}
+
+func (e *DecodeError) Error() string {
+	var b strings.Builder
+	b.WriteString("codec: ")
+	if e.Op != "" {
+		b.WriteString(e.Op)
+		b.WriteByte(' ')
+	}
+	fmt.Fprintf(&b, "at offset %d", e.Offset)
+	if e.Context != "" {
+		b.WriteString(" in ")
+		b.WriteString(e.Context)
+	}
+	b.WriteString(": ")
+	b.WriteString(e.Err.Error())
+	return b.String()
+}
+
+func (e *DecodeError) Unwrap() error { return e.Err }
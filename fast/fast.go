@@ -0,0 +1,300 @@
+// Package fast reads and writes the primitives of FAST (FIX Adapted for
+// STreaming): stop-bit encoded integers, ASCII strings and byte vectors,
+// where the high bit of a byte marks the last byte of a field, and the
+// presence maps opening each message. Templates and field operators are left
+// to the caller.
+package fast
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/oy3o/codec"
+)
+
+const (
+	// STOP_BIT marks the last byte of a field.
+	STOP_BIT = 0x80
+	// MAX_LENGTH bounds the strings, byte vectors and presence maps read.
+	MAX_LENGTH = 1 << 20
+)
+
+var (
+	// ErrOverflow indicates an integer that does not fit in 64 bits.
+	ErrOverflow = errors.New("fast: integer overflow")
+
+	// ErrInvalidString indicates an ASCII string holding a byte with the
+	// high bit set.
+	ErrInvalidString = errors.New("fast: invalid ASCII string")
+)
+
+func unexpected(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// readGroups calls fn with the 7 data bits of each byte of a stop-bit
+// encoded field. A Reader at the end of the stream returns io.EOF.
+func readGroups(r *codec.Reader, fn func(b byte) error) error {
+	for i := 0; ; i++ {
+		b, err := r.ReadByte()
+		if err != nil {
+			if i == 0 {
+				return r.Err()
+			}
+			return unexpected(r.Err())
+		}
+		if err := fn(b &^ STOP_BIT); err != nil {
+			return err
+		}
+		if b&STOP_BIT != 0 {
+			return nil
+		}
+	}
+}
+
+// ReadUint reads a stop-bit encoded unsigned integer.
+func ReadUint(r *codec.Reader) (uint64, error) {
+	var v uint64
+	err := readGroups(r, func(b byte) error {
+		if v > math.MaxUint64>>7 {
+			return ErrOverflow
+		}
+		v = v<<7 | uint64(b)
+		return nil
+	})
+	return v, err
+}
+
+// WriteUint writes a stop-bit encoded unsigned integer.
+func WriteUint(w *codec.Writer, v uint64) error {
+	var buf [10]byte
+	i := len(buf) - 1
+	buf[i] = byte(v&0x7f) | STOP_BIT
+	for v >>= 7; v != 0; v >>= 7 {
+		i--
+		buf[i] = byte(v & 0x7f)
+	}
+	w.WriteBytes(buf[i:])
+	return w.Err()
+}
+
+// ReadInt reads a stop-bit encoded signed integer, in two's complement with
+// the sign in the second highest bit of the first byte.
+func ReadInt(r *codec.Reader) (int64, error) {
+	var v int64
+	first := true
+	err := readGroups(r, func(b byte) error {
+		if first {
+			first = false
+			v = int64(b)
+			if b&0x40 != 0 {
+				v -= 0x80
+			}
+			return nil
+		}
+		if v > math.MaxInt64>>7 || v < math.MinInt64>>7 {
+			return ErrOverflow
+		}
+		v = v<<7 | int64(b)
+		return nil
+	})
+	return v, err
+}
+
+// WriteInt writes a stop-bit encoded signed integer in as few bytes as keep
+// its sign.
+func WriteInt(w *codec.Writer, v int64) error {
+	var buf [10]byte
+	i := len(buf) - 1
+	buf[i] = byte(v&0x7f) | STOP_BIT
+	for v >= 0x40 || v < -0x40 {
+		v >>= 7
+		i--
+		buf[i] = byte(v & 0x7f)
+	}
+	w.WriteBytes(buf[i:])
+	return w.Err()
+}
+
+// ReadNullableUint reads a nullable unsigned integer, stored as v+1 with 0
+// for null; ok is false for null.
+func ReadNullableUint(r *codec.Reader) (v uint64, ok bool, err error) {
+	if v, err = ReadUint(r); err != nil || v == 0 {
+		return 0, false, err
+	}
+	return v - 1, true, nil
+}
+
+// WriteNullableUint writes v as a nullable unsigned integer, or null if ok
+// is false.
+func WriteNullableUint(w *codec.Writer, v uint64, ok bool) error {
+	if !ok {
+		return WriteUint(w, 0)
+	}
+	if v == math.MaxUint64 {
+		return ErrOverflow
+	}
+	return WriteUint(w, v+1)
+}
+
+// ReadNullableInt reads a nullable signed integer, where non-negative values
+// are stored as v+1 with 0 for null; ok is false for null.
+func ReadNullableInt(r *codec.Reader) (v int64, ok bool, err error) {
+	if v, err = ReadInt(r); err != nil || v == 0 {
+		return 0, false, err
+	}
+	if v > 0 {
+		v--
+	}
+	return v, true, nil
+}
+
+// WriteNullableInt writes v as a nullable signed integer, or null if ok is
+// false.
+func WriteNullableInt(w *codec.Writer, v int64, ok bool) error {
+	switch {
+	case !ok:
+		return WriteInt(w, 0)
+	case v == math.MaxInt64:
+		return ErrOverflow
+	case v >= 0:
+		v++
+	}
+	return WriteInt(w, v)
+}
+
+// ReadASCII reads a stop-bit encoded ASCII string. The single byte 0x80
+// stands for the empty string, and 0x00 0x80 for "\x00".
+func ReadASCII(r *codec.Reader) (string, error) {
+	var b []byte
+	err := readGroups(r, func(c byte) error {
+		if len(b) == MAX_LENGTH {
+			return fmt.Errorf("%w: string over %d bytes", codec.ErrLengthOverflow, MAX_LENGTH)
+		}
+		b = append(b, c)
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	switch {
+	case len(b) == 1 && b[0] == 0:
+		return "", nil
+	case len(b) == 2 && b[0] == 0 && b[1] == 0:
+		return "\x00", nil
+	}
+	return string(b), nil
+}
+
+// WriteASCII writes s as a stop-bit encoded ASCII string.
+func WriteASCII(w *codec.Writer, s string) error {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 {
+			return fmt.Errorf("%w: byte 0x%02x", ErrInvalidString, s[i])
+		}
+	}
+	switch s {
+	case "":
+		w.WriteUint8(STOP_BIT)
+	case "\x00":
+		w.WriteUint8(0)
+		w.WriteUint8(STOP_BIT)
+	default:
+		w.WriteString(s[:len(s)-1])
+		w.WriteUint8(s[len(s)-1] | STOP_BIT)
+	}
+	return w.Err()
+}
+
+// ReadByteVector reads a byte vector: its length as an unsigned integer, then
+// its bytes.
+func ReadByteVector(r *codec.Reader) ([]byte, error) {
+	n, err := ReadUint(r)
+	if err != nil {
+		return nil, err
+	}
+	if n > MAX_LENGTH {
+		return nil, fmt.Errorf("%w: byte vector of %d bytes", codec.ErrLengthOverflow, n)
+	}
+	b := r.ReadBytes(int(n))
+	if err := r.Err(); err != nil {
+		return nil, unexpected(err)
+	}
+	return b, nil
+}
+
+// WriteByteVector writes b as a byte vector.
+func WriteByteVector(w *codec.Writer, b []byte) error {
+	if err := WriteUint(w, uint64(len(b))); err != nil {
+		return err
+	}
+	w.WriteBytes(b)
+	return w.Err()
+}
+
+// PresenceMap is the bit field opening a message or group, telling which
+// of the fields that may be absent are present. Bits past the end of an
+// encoded map read as absent.
+type PresenceMap struct {
+	bits []bool
+	pos  int
+}
+
+// ReadPresenceMap reads a presence map. A Reader at the end of the stream
+// returns io.EOF.
+func ReadPresenceMap(r *codec.Reader) (*PresenceMap, error) {
+	p := new(PresenceMap)
+	err := readGroups(r, func(b byte) error {
+		if len(p.bits) >= MAX_LENGTH {
+			return fmt.Errorf("%w: presence map over %d bits", codec.ErrLengthOverflow, MAX_LENGTH)
+		}
+		for i := 6; i >= 0; i-- {
+			p.bits = append(p.bits, b>>i&1 != 0)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Next returns the next bit of the map.
+func (p *PresenceMap) Next() bool {
+	p.pos++
+	return p.pos <= len(p.bits) && p.bits[p.pos-1]
+}
+
+// Append adds a bit to the end of the map.
+func (p *PresenceMap) Append(present bool) { p.bits = append(p.bits, present) }
+
+// Len returns the number of bits in the map.
+func (p *PresenceMap) Len() int { return len(p.bits) }
+
+// WritePresenceMap writes p, dropping trailing absent bits so the map takes
+// as few bytes as it can.
+func WritePresenceMap(w *codec.Writer, p *PresenceMap) error {
+	n := len(p.bits)
+	for n > 0 && !p.bits[n-1] {
+		n--
+	}
+	groups := max((n+6)/7, 1)
+	for g := range groups {
+		var b byte
+		for i := range 7 {
+			if k := g*7 + i; k < n && p.bits[k] {
+				b |= 0x40 >> i
+			}
+		}
+		if g == groups-1 {
+			b |= STOP_BIT
+		}
+		w.WriteUint8(b)
+	}
+	return w.Err()
+}
@@ -0,0 +1,135 @@
+//go:build test
+
+package fast
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"testing"
+
+	"github.com/oy3o/codec"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func encode(t *testing.T, fn func(w *codec.Writer) error) []byte {
+	var buf bytes.Buffer
+	w, _ := codec.NewWriter(&buf)
+	require.NoError(t, fn(w))
+	require.NoError(t, w.Flush())
+	return buf.Bytes()
+}
+
+func reader(b []byte) *codec.Reader {
+	r, _ := codec.NewReader(bytes.NewReader(b))
+	return r
+}
+
+func TestIntegers(t *testing.T) {
+	// Examples from the FAST specification.
+	uints := []struct {
+		v   uint64
+		enc []byte
+	}{
+		{0, []byte{0x80}},
+		{942755, []byte{0x39, 0x45, 0xa3}},
+		{math.MaxUint64, []byte{0x01, 0x7f, 0x7f, 0x7f, 0x7f, 0x7f, 0x7f, 0x7f, 0x7f, 0xff}},
+	}
+	for _, c := range uints {
+		assert.Equal(t, c.enc, encode(t, func(w *codec.Writer) error { return WriteUint(w, c.v) }))
+		v, err := ReadUint(reader(c.enc))
+		require.NoError(t, err)
+		assert.Equal(t, c.v, v)
+	}
+	ints := []struct {
+		v   int64
+		enc []byte
+	}{
+		{942755, []byte{0x39, 0x45, 0xa3}},
+		{-942755, []byte{0x46, 0x3a, 0xdd}},
+		{-7942755, []byte{0x7c, 0x1b, 0x1b, 0x9d}},
+		{8193, []byte{0x00, 0x40, 0x81}},
+		{-8193, []byte{0x7f, 0x3f, 0xff}},
+		{math.MinInt64, []byte{0x7f, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x80}},
+		{math.MaxInt64, []byte{0x00, 0x7f, 0x7f, 0x7f, 0x7f, 0x7f, 0x7f, 0x7f, 0x7f, 0xff}},
+	}
+	for _, c := range ints {
+		assert.Equal(t, c.enc, encode(t, func(w *codec.Writer) error { return WriteInt(w, c.v) }))
+		v, err := ReadInt(reader(c.enc))
+		require.NoError(t, err)
+		assert.Equal(t, c.v, v)
+	}
+
+	_, err := ReadUint(reader([]byte{0x7f, 0x7f, 0x7f, 0x7f, 0x7f, 0x7f, 0x7f, 0x7f, 0x7f, 0x7f, 0xff}))
+	assert.ErrorIs(t, err, ErrOverflow)
+	_, err = ReadUint(reader([]byte{0x39, 0x45}))
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	_, err = ReadUint(reader(nil))
+	assert.ErrorIs(t, err, io.EOF)
+}
+
+func TestNullable(t *testing.T) {
+	b := encode(t, func(w *codec.Writer) error {
+		WriteNullableUint(w, 0, false)
+		WriteNullableUint(w, 0, true)
+		WriteNullableInt(w, -1, true)
+		return WriteNullableInt(w, 3, true)
+	})
+	assert.Equal(t, []byte{0x80, 0x81, 0xff, 0x84}, b)
+	r := reader(b)
+	_, ok, err := ReadNullableUint(r)
+	require.NoError(t, err)
+	assert.False(t, ok)
+	u, ok, _ := ReadNullableUint(r)
+	assert.True(t, ok)
+	assert.Equal(t, uint64(0), u)
+	i, _, _ := ReadNullableInt(r)
+	assert.Equal(t, int64(-1), i)
+	i, _, _ = ReadNullableInt(r)
+	assert.Equal(t, int64(3), i)
+}
+
+func TestStrings(t *testing.T) {
+	b := encode(t, func(w *codec.Writer) error {
+		WriteASCII(w, "ABC")
+		WriteASCII(w, "")
+		WriteASCII(w, "\x00")
+		return WriteByteVector(w, []byte{0xff, 0x00})
+	})
+	assert.Equal(t, []byte{0x41, 0x42, 0xc3, 0x80, 0x00, 0x80, 0x82, 0xff, 0x00}, b)
+	r := reader(b)
+	for _, want := range []string{"ABC", "", "\x00"} {
+		s, err := ReadASCII(r)
+		require.NoError(t, err)
+		assert.Equal(t, want, s)
+	}
+	v, err := ReadByteVector(r)
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0xff, 0x00}, v)
+
+	w, _ := codec.NewWriter(io.Discard)
+	assert.ErrorIs(t, WriteASCII(w, "é"), ErrInvalidString)
+}
+
+func TestPresenceMap(t *testing.T) {
+	p := new(PresenceMap)
+	for _, bit := range []bool{true, false, true, false, false, false, false, true, false, false} {
+		p.Append(bit)
+	}
+	b := encode(t, func(w *codec.Writer) error { return WritePresenceMap(w, p) })
+	assert.Equal(t, []byte{0x50, 0xc0}, b)
+
+	got, err := ReadPresenceMap(reader(b))
+	require.NoError(t, err)
+	assert.Equal(t, 14, got.Len())
+	var bits []bool
+	for range 16 {
+		bits = append(bits, got.Next())
+	}
+	assert.Equal(t, []bool{true, false, true, false, false, false, false, true}, bits[:8])
+	assert.NotContains(t, bits[8:], true)
+
+	b = encode(t, func(w *codec.Writer) error { return WritePresenceMap(w, new(PresenceMap)) })
+	assert.Equal(t, []byte{0x80}, b)
+}
@@ -0,0 +1,275 @@
+// Package fix reads and writes FIX tagvalue messages: fields of the form
+// tag=value, each ended by SOH, opened by the BeginString (8) and BodyLength
+// (9) fields and closed by the CheckSum (10) field. The session layer and the
+// meaning of fields are left to the caller. For the FAST encoding of FIX
+// market data, see package fast.
+package fix
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"strconv"
+
+	"github.com/oy3o/codec"
+)
+
+// SOH ends every field.
+const SOH = 0x01
+
+// Tags of the fields framing every message.
+const (
+	TagBeginString = 8
+	TagBodyLength  = 9
+	TagCheckSum    = 10
+	TagMsgType     = 35
+)
+
+const (
+	// MAX_LENGTH bounds the body length of a message read by ReadMessage.
+	MAX_LENGTH = 1 << 20
+	// MAX_HEADER_FIELD bounds the BeginString and BodyLength fields.
+	MAX_HEADER_FIELD = 32
+)
+
+var (
+	// ErrInvalidMessage indicates a message whose framing fields are missing
+	// or malformed, or whose body does not parse into fields.
+	ErrInvalidMessage = errors.New("fix: invalid message")
+
+	// ErrChecksum indicates a CheckSum field that does not match the message.
+	ErrChecksum = errors.New("fix: checksum mismatch")
+
+	// ErrInvalidField indicates a field that cannot be written, such as a
+	// value holding SOH outside a data field.
+	ErrInvalidField = errors.New("fix: invalid field")
+)
+
+// dataFields maps the length fields of the standard data fields, whose
+// values may hold SOH, to the data fields they precede.
+var dataFields = map[int]int{
+	90:  91,  // SecureDataLen, SecureData
+	93:  89,  // SignatureLength, Signature
+	95:  96,  // RawDataLength, RawData
+	212: 213, // XmlDataLen, XmlData
+	348: 349, // EncodedIssuerLen, EncodedIssuer
+	350: 351, // EncodedSecurityDescLen, EncodedSecurityDesc
+	352: 353, // EncodedListExecInstLen, EncodedListExecInst
+	354: 355, // EncodedTextLen, EncodedText
+	356: 357, // EncodedSubjectLen, EncodedSubject
+	358: 359, // EncodedHeadlineLen, EncodedHeadline
+	360: 361, // EncodedAllocTextLen, EncodedAllocText
+	362: 363, // EncodedUnderlyingIssuerLen, EncodedUnderlyingIssuer
+	364: 365, // EncodedUnderlyingSecurityDescLen, EncodedUnderlyingSecurityDesc
+	445: 446, // EncodedListStatusTextLen, EncodedListStatusText
+	618: 619, // EncodedLegIssuerLen, EncodedLegIssuer
+	621: 622, // EncodedLegSecurityDescLen, EncodedLegSecurityDesc
+}
+
+// Field is a field of a message body.
+type Field struct {
+	Tag   int
+	Value []byte
+}
+
+// Message is a FIX message. Fields holds the fields between BodyLength and
+// CheckSum, starting with MsgType; both framing fields are computed by
+// WriteMessage.
+type Message struct {
+	BeginString string // such as "FIX.4.4" or "FIXT.1.1"
+	Fields      []Field
+}
+
+// Get returns the value of the first field tagged tag, or nil if there is
+// none.
+func (m *Message) Get(tag int) []byte {
+	for _, f := range m.Fields {
+		if f.Tag == tag {
+			return f.Value
+		}
+	}
+	return nil
+}
+
+// Add appends a field to the body.
+func (m *Message) Add(tag int, value string) {
+	m.Fields = append(m.Fields, Field{Tag: tag, Value: []byte(value)})
+}
+
+// Checksum returns the FIX checksum of b, the sum of its bytes modulo 256.
+func Checksum(b []byte) uint8 {
+	var sum uint8
+	for _, c := range b {
+		sum += c
+	}
+	return sum
+}
+
+func unexpected(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// readHeaderField reads a tag=value field of at most MAX_HEADER_FIELD bytes,
+// appending it to raw.
+func readHeaderField(r *codec.Reader, tag int, raw []byte) ([]byte, []byte, error) {
+	start := len(raw)
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			if len(raw) == 0 {
+				return nil, nil, r.Err()
+			}
+			return nil, nil, unexpected(r.Err())
+		}
+		raw = append(raw, b)
+		if b == SOH {
+			break
+		}
+		if len(raw)-start > MAX_HEADER_FIELD {
+			return nil, nil, fmt.Errorf("%w: field %d over %d bytes", ErrInvalidMessage, tag, MAX_HEADER_FIELD)
+		}
+	}
+	prefix := strconv.Itoa(tag) + "="
+	field := raw[start : len(raw)-1]
+	value, ok := bytes.CutPrefix(field, []byte(prefix))
+	if !ok {
+		return nil, nil, fmt.Errorf("%w: %q where field %d belongs", ErrInvalidMessage, field, tag)
+	}
+	return value, raw, nil
+}
+
+// ReadMessage reads a message and checks its body length and checksum. A
+// Reader at the end of the stream returns io.EOF.
+func ReadMessage(r *codec.Reader) (*Message, error) {
+	begin, raw, err := readHeaderField(r, TagBeginString, nil)
+	if err != nil {
+		return nil, err
+	}
+	length, raw, err := readHeaderField(r, TagBodyLength, raw)
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(string(length))
+	if err != nil || n < 0 {
+		return nil, fmt.Errorf("%w: body length %q", ErrInvalidMessage, length)
+	}
+	if n > MAX_LENGTH {
+		return nil, fmt.Errorf("%w: body of %d bytes", codec.ErrLengthOverflow, n)
+	}
+	body := r.ReadBytes(n)
+	var trailer [7]byte
+	r.ReadBytesTo(trailer[:])
+	if err := r.Err(); err != nil {
+		return nil, unexpected(err)
+	}
+	if string(trailer[:3]) != "10=" || trailer[6] != SOH {
+		return nil, fmt.Errorf("%w: %q where the checksum belongs", ErrInvalidMessage, trailer)
+	}
+	sum, err := strconv.Atoi(string(trailer[3:6]))
+	if err != nil {
+		return nil, fmt.Errorf("%w: checksum %q", ErrInvalidMessage, trailer[3:6])
+	}
+	if want := Checksum(raw) + Checksum(body); sum != int(want) {
+		return nil, fmt.Errorf("%w: %03d, computed %03d", ErrChecksum, sum, want)
+	}
+	m := &Message{BeginString: string(begin)}
+	if m.Fields, err = ParseFields(body); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// ParseFields splits a message body into fields. The values of data fields
+// may hold SOH, and extend for the length given by the preceding length
+// field. Values alias body.
+func ParseFields(body []byte) ([]Field, error) {
+	var fields []Field
+	dataTag, dataLen := 0, -1
+	for len(body) > 0 {
+		eq := bytes.IndexByte(body, '=')
+		if eq < 0 {
+			return nil, fmt.Errorf("%w: field %q without a value", ErrInvalidMessage, body)
+		}
+		tag, err := strconv.Atoi(string(body[:eq]))
+		if err != nil || tag <= 0 {
+			return nil, fmt.Errorf("%w: tag %q", ErrInvalidMessage, body[:eq])
+		}
+		body = body[eq+1:]
+		end := bytes.IndexByte(body, SOH)
+		if tag == dataTag && dataLen >= 0 {
+			end = dataLen
+			if end >= len(body) || body[end] != SOH {
+				return nil, fmt.Errorf("%w: data field %d of %d bytes", ErrInvalidMessage, tag, dataLen)
+			}
+		}
+		if end < 0 {
+			return nil, fmt.Errorf("%w: field %d not ended by SOH", ErrInvalidMessage, tag)
+		}
+		value := body[:end]
+		body = body[end+1:]
+		dataTag, dataLen = 0, -1
+		if next, ok := dataFields[tag]; ok {
+			if n, err := strconv.Atoi(string(value)); err == nil && n >= 0 {
+				dataTag, dataLen = next, n
+			}
+		}
+		fields = append(fields, Field{Tag: tag, Value: value})
+	}
+	return fields, nil
+}
+
+// AppendFields appends the encoding of fields to b.
+func AppendFields(b []byte, fields []Field) ([]byte, error) {
+	data := 0
+	for _, f := range fields {
+		if f.Tag <= 0 {
+			return nil, fmt.Errorf("%w: tag %d", ErrInvalidField, f.Tag)
+		}
+		if f.Tag != data && bytes.IndexByte(f.Value, SOH) >= 0 {
+			return nil, fmt.Errorf("%w: field %d holds SOH", ErrInvalidField, f.Tag)
+		}
+		data = dataFields[f.Tag]
+		b = strconv.AppendInt(b, int64(f.Tag), 10)
+		b = append(b, '=')
+		b = append(b, f.Value...)
+		b = append(b, SOH)
+	}
+	return b, nil
+}
+
+// WriteMessage writes a message, computing its body length and checksum.
+func WriteMessage(w *codec.Writer, m *Message) error {
+	body, err := AppendFields(nil, m.Fields)
+	if err != nil {
+		return err
+	}
+	if bytes.IndexByte([]byte(m.BeginString), SOH) >= 0 {
+		return fmt.Errorf("%w: BeginString holds SOH", ErrInvalidField)
+	}
+	head := fmt.Appendf(nil, "8=%s\x019=%d\x01", m.BeginString, len(body))
+	w.WriteBytes(head)
+	w.WriteBytes(body)
+	w.WriteBytes(fmt.Appendf(nil, "10=%03d\x01", Checksum(head)+Checksum(body)))
+	return w.Err()
+}
+
+// Messages iterates over the messages of r up to the end of the stream,
+// stopping after the first error.
+func Messages(r *codec.Reader) iter.Seq2[*Message, error] {
+	return func(yield func(*Message, error) bool) {
+		for {
+			m, err := ReadMessage(r)
+			if err == io.EOF {
+				return
+			}
+			if !yield(m, err) || err != nil {
+				return
+			}
+		}
+	}
+}
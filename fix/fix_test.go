@@ -0,0 +1,85 @@
+//go:build test
+
+package fix
+
+import (
+	"bytes"
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/oy3o/codec"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// heartbeat is the example from the FIX specification, with | for SOH.
+const heartbeat = "8=FIX.4.2|9=65|35=A|49=SERVER|56=CLIENT|34=177|52=20090107-18:15:16|98=0|108=30|10=062|"
+
+func soh(s string) []byte { return []byte(strings.ReplaceAll(s, "|", "\x01")) }
+
+func TestReadMessage(t *testing.T) {
+	r, _ := codec.NewReader(bytes.NewReader(append(soh(heartbeat), soh(heartbeat)...)))
+	var n int
+	for m, err := range Messages(r) {
+		require.NoError(t, err)
+		assert.Equal(t, "FIX.4.2", m.BeginString)
+		assert.Equal(t, "A", string(m.Get(TagMsgType)))
+		assert.Equal(t, "30", string(m.Get(108)))
+		assert.Nil(t, m.Get(TagCheckSum))
+		n++
+	}
+	assert.Equal(t, 2, n)
+
+	bad := soh(strings.Replace(heartbeat, "10=062", "10=063", 1))
+	r, _ = codec.NewReader(bytes.NewReader(bad))
+	_, err := ReadMessage(r)
+	assert.ErrorIs(t, err, ErrChecksum)
+
+	bad = soh(strings.Replace(heartbeat, "9=65", "9=64", 1))
+	r, _ = codec.NewReader(bytes.NewReader(bad))
+	_, err = ReadMessage(r)
+	assert.ErrorIs(t, err, ErrInvalidMessage)
+
+	r, _ = codec.NewReader(bytes.NewReader(soh(heartbeat)[:40]))
+	_, err = ReadMessage(r)
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+}
+
+func TestWriteMessage(t *testing.T) {
+	m := &Message{BeginString: "FIX.4.2"}
+	for _, f := range []string{"35=A", "49=SERVER", "56=CLIENT", "34=177", "52=20090107-18:15:16", "98=0", "108=30"} {
+		tag, value, _ := strings.Cut(f, "=")
+		n, _ := strconv.Atoi(tag)
+		m.Add(n, value)
+	}
+	var buf bytes.Buffer
+	w, _ := codec.NewWriter(&buf)
+	require.NoError(t, WriteMessage(w, m))
+	require.NoError(t, w.Flush())
+	assert.Equal(t, soh(heartbeat), buf.Bytes())
+
+	m.Add(999, "a\x01b")
+	assert.ErrorIs(t, WriteMessage(w, m), ErrInvalidField)
+}
+
+func TestDataField(t *testing.T) {
+	m := &Message{BeginString: "FIXT.1.1"}
+	m.Add(TagMsgType, "B")
+	m.Add(95, "5")
+	m.Add(96, "a\x01=b\x01")
+	m.Add(58, "text")
+	var buf bytes.Buffer
+	w, _ := codec.NewWriter(&buf)
+	require.NoError(t, WriteMessage(w, m))
+	require.NoError(t, w.Flush())
+
+	r, _ := codec.NewReader(bytes.NewReader(buf.Bytes()))
+	got, err := ReadMessage(r)
+	require.NoError(t, err)
+	assert.Equal(t, m, got)
+
+	_, err = ParseFields([]byte("95=9\x0196=short\x01"))
+	assert.ErrorIs(t, err, ErrInvalidMessage)
+}
@@ -1,3 +1,5 @@
+//go:build !tinygo && !codec_tiny
+
 package codec
 
 import (
@@ -17,8 +19,12 @@ var sizeCache = xsync.NewMap[reflect.Type, int]()
 //
 // Constraint: The `Body` type MUST NOT contain variable-size fields like slices,
 // maps, or strings, as this will cause `binary.Size` to fail.
+//
+// Fixed carries an unexported byte order set by WithByteOrder, so composite
+// literals must name the field: Fixed[T]{Payload: v}.
 type Fixed[Payload any] struct {
 	Payload Payload
+	order   binary.ByteOrder // set by WithByteOrder, nil for Order
 }
 
 // Statically assert that FixedSizeCodec implements Codec.
@@ -35,20 +41,63 @@ func (c *Fixed[Payload]) Size() int {
 	}
 
 	// If not cached, perform the expensive reflection-based calculation.
-	size := binary.Size(&c.Payload)
+	// The layout accounts for tags such as bitfields that change the wire size.
+	size := c.layout().size
 
 	// Store the result for subsequent calls.
 	sizeCache.Store(bodyType, size)
 	return size
 }
 
+// WithByteOrder sets the byte order of the wire format in place of the
+// global Order, so formats of different endianness can coexist, and returns
+// the configured Fixed for chaining. Fields with their own order, through
+// tags or the BigEndian and LittleEndian wrappers, keep it.
+func (c *Fixed[Payload]) WithByteOrder(order binary.ByteOrder) *Fixed[Payload] {
+	c.order = order
+	return c
+}
+
+// byteOrder returns the configured byte order, defaulting to Order.
+func (c *Fixed[Payload]) byteOrder() binary.ByteOrder {
+	if c.order == nil {
+		return Order
+	}
+	return c.order
+}
+
+// writeToOrder implements orderInheritor.
+func (c *Fixed[Payload]) writeToOrder(w io.Writer, order binary.ByteOrder) (int64, error) {
+	if c.order != nil {
+		return c.WriteTo(w)
+	}
+	// Encode a copy, so items shared between writers are never modified.
+	tmp := Fixed[Payload]{Payload: c.Payload, order: order}
+	return tmp.WriteTo(w)
+}
+
+// inheritOrder implements orderInheritor.
+func (c *Fixed[Payload]) inheritOrder(order binary.ByteOrder) {
+	if c.order == nil {
+		c.order = order
+	}
+}
+
+// layout returns the cached wire layout of the payload type.
+func (c *Fixed[Payload]) layout() *fixedLayout {
+	return layoutOf(reflect.TypeOf((*Payload)(nil)).Elem())
+}
+
 // MarshalBinary implements the standard `encoding.BinaryMarshaler` interface.
 // Note: This method allocates a new byte slice. For performance-critical paths,
 // use `MarshalTo` or `WriteTo` instead.
 func (c *Fixed[Payload]) MarshalBinary() ([]byte, error) {
+	if err := c.layout().err; err != nil {
+		return nil, err
+	}
 	buf := make([]byte, c.Size())
-	if _, err := binary.Encode(buf, Order, &c.Payload); err != nil {
-		return nil, io.ErrShortWrite // binary.Encode only returns unexported buffer too small error, it means fewer bytes were written than expected
+	if _, err := c.MarshalTo(buf); err != nil {
+		return nil, err
 	}
 	return buf, nil
 }
@@ -56,7 +105,26 @@ func (c *Fixed[Payload]) MarshalBinary() ([]byte, error) {
 // UnmarshalBinary implements the standard `encoding.BinaryUnmarshaler` interface.
 // It calls `CheckTrailingNotZeros` to prevent bugs from truncated or oversized payloads.
 func (c *Fixed[Payload]) UnmarshalBinary(data []byte) error {
-	n, err := binary.Decode(data, Order, &c.Payload)
+	if l := c.layout(); !l.plain() {
+		if l.err != nil {
+			return l.err
+		}
+		if len(data) < l.size {
+			return ErrTruncatedData
+		}
+		// Transformers work in place, so never touch the caller's buffer.
+		buf := make([]byte, l.size)
+		copy(buf, data)
+		if err := l.applyTransforms(buf, false); err != nil {
+			return err
+		}
+		if err := l.decode(buf, c.byteOrder(), reflect.ValueOf(&c.Payload).Elem()); err != nil {
+			return err
+		}
+		return CheckBufferNotZeros(data[l.size:])
+	}
+
+	n, err := binary.Decode(data, c.byteOrder(), &c.Payload)
 	if err != nil {
 		return ErrTruncatedData // binary.Decode always returns unexported buffer too small error, it means the data is truncated
 	}
@@ -71,17 +139,47 @@ func (c *Fixed[Payload]) UnmarshalBinary(data []byte) error {
 // ReadFrom implements `io.ReaderFrom` for efficient, allocation-free reading
 // directly from a stream into the struct.
 func (c *Fixed[Payload]) ReadFrom(r io.Reader) (int64, error) {
-	err := binary.Read(r, Order, &c.Payload)
+	if l := c.layout(); !l.plain() {
+		if l.err != nil {
+			return 0, l.err
+		}
+		buf := make([]byte, l.size)
+		if n, err := io.ReadFull(r, buf); err != nil {
+			return int64(n), err
+		}
+		return int64(l.size), c.UnmarshalBinary(buf)
+	}
+
+	err := binary.Read(r, c.byteOrder(), &c.Payload)
 	if err != nil {
 		return 0, err
 	}
 	return int64(c.Size()), nil
 }
 
+// UnmarshalBestEffort decodes the fields that data fully covers, for
+// recovering what remains of truncated or corrupted input. It returns nil if
+// every field decoded, or a *PartialError describing where decoding stopped.
+// Trailing bytes are ignored.
+func (c *Fixed[Payload]) UnmarshalBestEffort(data []byte) error {
+	l := c.layout()
+	if l.err != nil {
+		return l.err
+	}
+	if pe := l.decodePartial(data, c.byteOrder(), reflect.ValueOf(&c.Payload).Elem()); pe != nil {
+		return pe
+	}
+	return nil
+}
+
 // WriteTo implements `io.WriterTo` for efficient, allocation-free writing
 // directly to a stream (e.g., a network connection or file).
 func (c *Fixed[Payload]) WriteTo(w io.Writer) (int64, error) {
-	err := binary.Write(w, Order, &c.Payload)
+	if !c.layout().plain() {
+		return WriteToGeneric(c, w)
+	}
+
+	err := binary.Write(w, c.byteOrder(), &c.Payload)
 	if err != nil {
 		return 0, err
 	}
@@ -91,9 +189,18 @@ func (c *Fixed[Payload]) WriteTo(w io.Writer) (int64, error) {
 // MarshalTo marshals the struct into the provided slice `p`.
 // This is the most performant marshalling option as it avoids memory allocation.
 func (c *Fixed[Payload]) MarshalTo(p []byte) (int, error) {
-	n, err := binary.Encode(p, Order, &c.Payload)
+	if l := c.layout(); !l.plain() {
+		return l.encode(p, c.byteOrder(), reflect.ValueOf(&c.Payload).Elem())
+	}
+
+	n, err := binary.Encode(p, c.byteOrder(), &c.Payload)
 	if err != nil {
 		return n, io.ErrShortWrite // binary.Encode only returns unexported buffer too small error, it means fewer bytes were written than expected
 	}
 	return n, nil
 }
+
+// MarshalAppend implements `Appender`, appending the encoding to dst.
+func (c *Fixed[Payload]) MarshalAppend(dst []byte) ([]byte, error) {
+	return MarshalAppendGeneric(c, dst)
+}
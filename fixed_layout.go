@@ -0,0 +1,629 @@
+//go:build !tinygo && !codec_tiny
+
+package codec
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/puzpuzpuz/xsync/v4"
+)
+
+// TAG_NAME is the struct tag key consulted by the reflection-based codecs.
+// Options are comma separated, e.g. `codec:"bits=3"` or `codec:"le,transform:pii"`.
+// The tag `codec:"-"` excludes a field from the encoding.
+const TAG_NAME = "codec"
+
+// tagOptions holds the parsed options of a single `codec` struct tag.
+type tagOptions struct {
+	transform string           // name of a registered Transformer
+	redact    bool             // hide the field in dumps and traces
+	bits      int              // width of a bitfield, 0 for byte-aligned fields
+	order     binary.ByteOrder // per-field byte order overriding Order, nil to inherit
+	skip      bool             // field is not encoded
+	pad       int              // zero bytes preceding the field
+	strict    bool             // decode verifies padding and blank fields are zero
+	since     int              // first payload version containing the field, see Versioned
+}
+
+// parseTag splits a `codec` struct tag into its options.
+func parseTag(tag string) (tagOptions, error) {
+	var opts tagOptions
+	for _, opt := range strings.Split(tag, ",") {
+		opt = strings.TrimSpace(opt)
+		key, value, _ := strings.Cut(opt, ":")
+		if k, v, ok := strings.Cut(opt, "="); ok {
+			key, value = k, v
+		}
+		switch key {
+		case "transform":
+			opts.transform = value
+		case "redact":
+			opts.redact = true
+		case "bits":
+			n, err := strconv.Atoi(value)
+			if err != nil || n <= 0 || n > 64 {
+				return opts, fmt.Errorf("%w: bits=%s", ErrInvalidTag, value)
+			}
+			opts.bits = n
+		case "be":
+			opts.order = BE
+		case "le":
+			opts.order = LE
+		case "-":
+			opts.skip = true
+		case "pad":
+			n, err := strconv.Atoi(value)
+			if err != nil || n <= 0 {
+				return opts, fmt.Errorf("%w: pad=%s", ErrInvalidTag, value)
+			}
+			opts.pad = n
+		case "strict":
+			opts.strict = true
+		case "since":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 || n > math.MaxUint16 {
+				return opts, fmt.Errorf("%w: since=%s", ErrInvalidTag, value)
+			}
+			opts.since = n
+		}
+	}
+	return opts, nil
+}
+
+// fieldLayout describes where a field lives in the wire encoding.
+type fieldLayout struct {
+	name   string
+	index  []int // reflect field index path from the payload root
+	offset int   // byte offset; for bitfields, the offset of the enclosing group
+	size   int   // byte size; for bitfields, the size of the enclosing group
+	bit    int   // bit offset of a bitfield within its group, MSB first
+	blank  bool  // blank (_) fields are written as zeros and skipped on decode
+	opts   tagOptions
+}
+
+// fixedLayout is the cached wire layout of a Fixed payload type.
+// Payloads without tagged fields keep the encoding/binary fast path.
+type fixedLayout struct {
+	size       int
+	fields     []fieldLayout // leaf fields in wire order
+	transforms []fieldLayout
+	packed     bool          // contains bitfields, so encoding/binary cannot be used
+	mixed      bool          // contains per-field byte orders
+	padding    []fieldLayout // pad regions and blank fields verified on decode
+	gaps       bool          // contains skipped fields or pad regions
+	version    int           // fields with a later since tag are omitted, -1 keeps all
+	latest     int           // highest since tag found
+	err        error         // validation error reported on first use
+}
+
+// layoutCache caches layouts per payload type, mirroring sizeCache.
+var layoutCache = xsync.NewMap[reflect.Type, *fixedLayout]()
+
+// versionKey identifies the layout of a payload type at one version.
+type versionKey struct {
+	t       reflect.Type
+	version int
+}
+
+// versionCache caches the per-version layouts used by Versioned.
+var versionCache = xsync.NewMap[versionKey, *fixedLayout]()
+
+// layoutOf returns the cached layout of t, computing and validating it on first use.
+func layoutOf(t reflect.Type) *fixedLayout {
+	if l, ok := layoutCache.Load(t); ok {
+		return l
+	}
+	l := newLayout(t, -1)
+	layoutCache.Store(t, l)
+	return l
+}
+
+// versionLayoutOf returns the cached layout of t restricted to the fields
+// present in the given version.
+func versionLayoutOf(t reflect.Type, version int) *fixedLayout {
+	key := versionKey{t, version}
+	if l, ok := versionCache.Load(key); ok {
+		return l
+	}
+	l := newLayout(t, version)
+	versionCache.Store(key, l)
+	return l
+}
+
+// newLayout computes and validates the layout of t at version.
+func newLayout(t reflect.Type, version int) *fixedLayout {
+	l := &fixedLayout{size: wireSize(t), version: version}
+	if t.Kind() == reflect.Struct {
+		var g bitGroup
+		l.size = l.closeGroup(&g, l.walk(t, nil, "", 0, nil, &g))
+		if errors.Is(l.err, ErrNotFixedSize) {
+			l.size = -1
+		}
+	}
+	if l.size < 0 && l.err == nil {
+		l.err = fmt.Errorf("%w: %s", ErrNotFixedSize, t)
+	}
+	return l
+}
+
+// bitGroup tracks a run of consecutive bitfields sharing bytes.
+type bitGroup struct {
+	start int // index into fields of the first member
+	bits  int // total bits so far
+}
+
+// walk records the leaf fields of struct t, descending into nested structs,
+// and returns the offset following the struct. Fields without a byte order tag
+// inherit order from the enclosing struct field, if it has one.
+// Offsets follow encoding/binary, which lays fields out without padding.
+func (l *fixedLayout) walk(t reflect.Type, index []int, prefix string, offset int, order binary.ByteOrder, g *bitGroup) int {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		fl := fieldLayout{
+			name:  prefix + f.Name,
+			index: append(index[:len(index):len(index)], i),
+			size:  wireSize(f.Type),
+			blank: f.Name == "_",
+		}
+		if tag, ok := f.Tag.Lookup(TAG_NAME); ok {
+			opts, err := parseTag(tag)
+			if err != nil && l.err == nil {
+				l.err = fmt.Errorf("%w (field %s)", err, fl.name)
+			}
+			fl.opts = opts
+		}
+		l.latest = max(l.latest, fl.opts.since)
+		if fl.opts.skip || (l.version >= 0 && fl.opts.since > l.version) {
+			l.gaps = true
+			continue
+		}
+		if fl.opts.order == nil {
+			fl.opts.order = wrapperOrder(f.Type, order)
+		}
+		l.mixed = l.mixed || fl.opts.order != nil
+		if fl.opts.pad > 0 {
+			offset = l.closeGroup(g, offset)
+			l.padding = append(l.padding, fieldLayout{name: fl.name, offset: offset, size: fl.opts.pad, blank: true, opts: fl.opts})
+			l.gaps = true
+			offset += fl.opts.pad
+		}
+
+		if fl.opts.bits > 0 {
+			if err := checkBitfield(f.Type, fl.opts.bits); err != nil && l.err == nil {
+				l.err = fmt.Errorf("%w (field %s)", err, fl.name)
+			}
+			if g.bits == 0 {
+				g.start = len(l.fields)
+			}
+			fl.bit = g.bits
+			g.bits += fl.opts.bits
+			l.packed = true
+			l.fields = append(l.fields, fl)
+			continue
+		}
+		offset = l.closeGroup(g, offset)
+		fl.offset = offset
+
+		if fl.opts.transform != "" {
+			l.transforms = append(l.transforms, fl)
+		}
+		if f.Type.Kind() == reflect.Struct && !fl.opts.redact {
+			offset = l.closeGroup(g, l.walk(f.Type, fl.index, fl.name+".", offset, fl.opts.order, g))
+			continue
+		}
+		if fl.size < 0 && l.err == nil {
+			l.err = fmt.Errorf("%w: field %s of type %s", ErrNotFixedSize, fl.name, f.Type)
+		}
+		if fl.blank && fl.opts.strict {
+			l.padding = append(l.padding, fl)
+			l.gaps = true
+		}
+		l.fields = append(l.fields, fl)
+		offset += fl.size
+	}
+	return offset
+}
+
+// closeGroup finishes the pending bitfield group at offset and returns the
+// offset following it. A group must fill a whole number of bytes.
+func (l *fixedLayout) closeGroup(g *bitGroup, offset int) int {
+	if g.bits == 0 {
+		return offset
+	}
+	if g.bits%8 != 0 && l.err == nil {
+		l.err = fmt.Errorf("%w: bitfields starting at %s span %d bits, not a whole number of bytes",
+			ErrInvalidTag, l.fields[g.start].name, g.bits)
+	}
+	size := (g.bits + 7) / 8
+	for i := g.start; i < len(l.fields); i++ {
+		l.fields[i].offset, l.fields[i].size = offset, size
+	}
+	g.bits = 0
+	return offset + size
+}
+
+// checkBitfield validates that a field of type t can hold a bits-wide value.
+func checkBitfield(t reflect.Type, bits int) error {
+	switch t.Kind() {
+	case reflect.Bool:
+		if bits != 1 {
+			return fmt.Errorf("%w: bool bitfield must be 1 bit", ErrInvalidTag)
+		}
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if bits > t.Bits() {
+			return fmt.Errorf("%w: %d bits do not fit in %s", ErrInvalidTag, bits, t)
+		}
+	default:
+		return fmt.Errorf("%w: bitfield on non-integer type %s", ErrInvalidTag, t)
+	}
+	return nil
+}
+
+// plain reports whether the payload can be encoded by encoding/binary alone.
+func (l *fixedLayout) plain() bool {
+	return len(l.transforms) == 0 && !l.packed && !l.mixed && !l.gaps && l.err == nil
+}
+
+// encode writes v into buf field by field and applies transformers.
+func (l *fixedLayout) encode(buf []byte, order binary.ByteOrder, v reflect.Value) (int, error) {
+	if l.err != nil {
+		return 0, l.err
+	}
+	if len(buf) < l.size {
+		return 0, io.ErrShortWrite
+	}
+	buf = buf[:l.size]
+	clear(buf)
+	for _, f := range l.fields {
+		if f.blank {
+			continue
+		}
+		fv := v.FieldByIndex(f.index)
+		if f.opts.bits > 0 {
+			if !fitsBits(fv, f.opts.bits) {
+				return 0, fmt.Errorf("%w: %s value %v does not fit in %d bits", ErrLengthOverflow, f.name, fv.Interface(), f.opts.bits)
+			}
+			putBits(buf[f.offset:f.offset+f.size], f.bit, f.opts.bits, rawBits(fv))
+			continue
+		}
+		encodeValue(buf[f.offset:f.offset+f.size], f.byteOrder(order), fv)
+	}
+	return l.size, l.applyTransforms(buf, true)
+}
+
+// decode reads v from buf field by field. Transformers, if any, must already
+// have been reversed on buf.
+func (l *fixedLayout) decode(buf []byte, order binary.ByteOrder, v reflect.Value) error {
+	if l.err != nil {
+		return l.err
+	}
+	if len(buf) < l.size {
+		return ErrTruncatedData
+	}
+	for _, p := range l.padding {
+		if !p.opts.strict {
+			continue
+		}
+		for _, c := range buf[p.offset : p.offset+p.size] {
+			if c != 0 {
+				return fmt.Errorf("%w: at %s", ErrNonZeroPadding, p.name)
+			}
+		}
+	}
+	for _, f := range l.fields {
+		if f.blank {
+			continue
+		}
+		fv := v.FieldByIndex(f.index)
+		if f.opts.bits > 0 {
+			setBits(fv, getBits(buf[f.offset:f.offset+f.size], f.bit, f.opts.bits), f.opts.bits)
+			continue
+		}
+		decodeValue(buf[f.offset:f.offset+f.size], f.byteOrder(order), fv)
+	}
+	return nil
+}
+
+// decodePartial decodes the fields of v that data fully covers, in wire
+// order, stopping at the first field that is truncated, fails its
+// transformer or lies past non-zero strict padding.
+func (l *fixedLayout) decodePartial(data []byte, order binary.ByteOrder, v reflect.Value) *PartialError {
+	buf := make([]byte, l.size)
+	avail := copy(buf, data)
+
+	// stop is the offset decoding cannot pass, and stopErr the reason.
+	stop, stopErr, stopField := l.size, error(nil), ""
+	limit := func(offset int, name string, err error) {
+		if offset < stop {
+			stop, stopErr, stopField = offset, err, name
+		}
+	}
+	if avail < l.size {
+		limit(avail, "", ErrTruncatedData)
+	}
+	for _, f := range l.transforms {
+		if f.offset+f.size > avail {
+			continue
+		}
+		t, ok := transformers.Load(f.opts.transform)
+		if !ok {
+			limit(f.offset, f.name, fmt.Errorf("%w: %q", ErrUnknownTransformer, f.opts.transform))
+		} else if err := t.Decode(buf[f.offset : f.offset+f.size]); err != nil {
+			limit(f.offset, f.name, fmt.Errorf("codec: transform %q: %w", f.opts.transform, err))
+		}
+	}
+	for _, p := range l.padding {
+		if !p.opts.strict || p.offset+p.size > avail {
+			continue
+		}
+		for _, c := range buf[p.offset : p.offset+p.size] {
+			if c != 0 {
+				limit(p.offset, p.name, ErrNonZeroPadding)
+				break
+			}
+		}
+	}
+
+	pe := &PartialError{}
+	for _, f := range l.fields {
+		if f.offset+f.size > stop {
+			pe.Field, pe.Offset, pe.Err = f.name, int64(f.offset), stopErr
+			if stopField != "" {
+				pe.Field, pe.Offset = stopField, int64(stop)
+			}
+			return pe
+		}
+		if f.blank {
+			continue
+		}
+		fv := v.FieldByIndex(f.index)
+		if f.opts.bits > 0 {
+			setBits(fv, getBits(buf[f.offset:f.offset+f.size], f.bit, f.opts.bits), f.opts.bits)
+		} else {
+			decodeValue(buf[f.offset:f.offset+f.size], f.byteOrder(order), fv)
+		}
+		pe.Decoded = append(pe.Decoded, f.name)
+	}
+	if stopErr != nil {
+		pe.Field, pe.Offset, pe.Err = stopField, int64(stop), stopErr
+		return pe
+	}
+	return nil
+}
+
+// byteOrder returns the byte order of the field, falling back to def.
+func (f *fieldLayout) byteOrder(def binary.ByteOrder) binary.ByteOrder {
+	if f.opts.order != nil {
+		return f.opts.order
+	}
+	return def
+}
+
+// putBits stores the low n bits of x at bit offset off of group, MSB first.
+func putBits(group []byte, off, n int, x uint64) {
+	for j := 0; j < n; j++ {
+		if x>>(n-1-j)&1 == 1 {
+			p := off + j
+			group[p/8] |= 0x80 >> (p % 8)
+		}
+	}
+}
+
+// getBits loads n bits at bit offset off of group, MSB first.
+func getBits(group []byte, off, n int) uint64 {
+	var x uint64
+	for j := 0; j < n; j++ {
+		p := off + j
+		x = x<<1 | uint64(group[p/8]>>(7-p%8)&1)
+	}
+	return x
+}
+
+// rawBits returns the two's complement bits of an integer or bool value.
+func rawBits(v reflect.Value) uint64 {
+	switch v.Kind() {
+	case reflect.Bool:
+		if v.Bool() {
+			return 1
+		}
+		return 0
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return uint64(v.Int())
+	default:
+		return v.Uint()
+	}
+}
+
+// fitsBits reports whether an integer or bool value can be stored in n bits,
+// signed values as n-bit two's complement.
+func fitsBits(v reflect.Value, n int) bool {
+	if n >= 64 {
+		return true
+	}
+	switch v.Kind() {
+	case reflect.Bool:
+		return true
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		x := v.Int()
+		return x >= -1<<(n-1) && x < 1<<(n-1)
+	default:
+		return v.Uint()>>n == 0
+	}
+}
+
+// setBits stores n raw bits into an integer or bool value, sign-extending
+// signed values.
+func setBits(v reflect.Value, x uint64, n int) {
+	switch v.Kind() {
+	case reflect.Bool:
+		v.SetBool(x != 0)
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		shift := 64 - n
+		v.SetInt(int64(x<<shift) >> shift)
+	default:
+		v.SetUint(x)
+	}
+}
+
+// orderedFieldType is the reflect type of the orderedField interface.
+var orderedFieldType = reflect.TypeFor[orderedField]()
+
+// wrapperOrder returns the byte order pinned by t if it is a BigEndian or
+// LittleEndian wrapper, and def otherwise.
+func wrapperOrder(t reflect.Type, def binary.ByteOrder) binary.ByteOrder {
+	if t.Implements(orderedFieldType) {
+		return reflect.Zero(t).Interface().(orderedField).byteOrder()
+	}
+	return def
+}
+
+// encodeValue encodes a fixed-size value into buf following encoding/binary rules.
+func encodeValue(buf []byte, order binary.ByteOrder, v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Bool:
+		if v.Bool() {
+			buf[0] = 1
+		}
+	case reflect.Int8, reflect.Uint8:
+		buf[0] = byte(rawBits(v))
+	case reflect.Int16, reflect.Uint16:
+		order.PutUint16(buf, uint16(rawBits(v)))
+	case reflect.Int32, reflect.Uint32:
+		order.PutUint32(buf, uint32(rawBits(v)))
+	case reflect.Int64, reflect.Uint64:
+		order.PutUint64(buf, rawBits(v))
+	case reflect.Float32:
+		order.PutUint32(buf, math.Float32bits(float32(v.Float())))
+	case reflect.Float64:
+		order.PutUint64(buf, math.Float64bits(v.Float()))
+	case reflect.Complex64:
+		c := v.Complex()
+		order.PutUint32(buf, math.Float32bits(float32(real(c))))
+		order.PutUint32(buf[4:], math.Float32bits(float32(imag(c))))
+	case reflect.Complex128:
+		c := v.Complex()
+		order.PutUint64(buf, math.Float64bits(real(c)))
+		order.PutUint64(buf[8:], math.Float64bits(imag(c)))
+	case reflect.Array:
+		size := wireSize(v.Type().Elem())
+		for i := 0; i < v.Len(); i++ {
+			encodeValue(buf[i*size:], order, v.Index(i))
+		}
+	case reflect.Struct:
+		order = wrapperOrder(v.Type(), order)
+		off := 0
+		for i := 0; i < v.NumField(); i++ {
+			f := v.Type().Field(i)
+			if f.Name != "_" {
+				encodeValue(buf[off:], order, v.Field(i))
+			}
+			off += wireSize(f.Type)
+		}
+	}
+}
+
+// decodeValue decodes a fixed-size value from buf following encoding/binary rules.
+func decodeValue(buf []byte, order binary.ByteOrder, v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Bool:
+		v.SetBool(buf[0] != 0)
+	case reflect.Int8:
+		v.SetInt(int64(int8(buf[0])))
+	case reflect.Uint8:
+		v.SetUint(uint64(buf[0]))
+	case reflect.Int16:
+		v.SetInt(int64(int16(order.Uint16(buf))))
+	case reflect.Uint16:
+		v.SetUint(uint64(order.Uint16(buf)))
+	case reflect.Int32:
+		v.SetInt(int64(int32(order.Uint32(buf))))
+	case reflect.Uint32:
+		v.SetUint(uint64(order.Uint32(buf)))
+	case reflect.Int64:
+		v.SetInt(int64(order.Uint64(buf)))
+	case reflect.Uint64:
+		v.SetUint(order.Uint64(buf))
+	case reflect.Float32:
+		v.SetFloat(float64(math.Float32frombits(order.Uint32(buf))))
+	case reflect.Float64:
+		v.SetFloat(math.Float64frombits(order.Uint64(buf)))
+	case reflect.Complex64:
+		v.SetComplex(complex(
+			float64(math.Float32frombits(order.Uint32(buf))),
+			float64(math.Float32frombits(order.Uint32(buf[4:]))),
+		))
+	case reflect.Complex128:
+		v.SetComplex(complex(
+			math.Float64frombits(order.Uint64(buf)),
+			math.Float64frombits(order.Uint64(buf[8:])),
+		))
+	case reflect.Array:
+		size := wireSize(v.Type().Elem())
+		for i := 0; i < v.Len(); i++ {
+			decodeValue(buf[i*size:], order, v.Index(i))
+		}
+	case reflect.Struct:
+		order = wrapperOrder(v.Type(), order)
+		off := 0
+		for i := 0; i < v.NumField(); i++ {
+			f := v.Type().Field(i)
+			if f.Name != "_" {
+				decodeValue(buf[off:], order, v.Field(i))
+			}
+			off += wireSize(f.Type)
+		}
+	}
+}
+
+// wireSizeCache caches the plain encoding/binary size of field types.
+// It is separate from sizeCache, which holds the size of whole payloads
+// including their tag-driven layout.
+var wireSizeCache = xsync.NewMap[reflect.Type, int]()
+
+// wireSize returns the encoding/binary size of t, or -1 if t is not fixed-size.
+func wireSize(t reflect.Type) int {
+	if size, ok := wireSizeCache.Load(t); ok {
+		return size
+	}
+	size := binary.Size(reflect.New(t).Interface())
+	wireSizeCache.Store(t, size)
+	return size
+}
+
+// LayoutOf derives the RecordLayout of a fixed-size type, flattening nested
+// structs and arrays into their scalar fields as encoding/binary lays them out.
+func LayoutOf[T any]() (RecordLayout, error) {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	if wireSize(t) < 0 {
+		return nil, fmt.Errorf("%w: %s", ErrNotFixedSize, t)
+	}
+	return appendLayout(nil, t), nil
+}
+
+func appendLayout(l RecordLayout, t reflect.Type) RecordLayout {
+	switch t.Kind() {
+	case reflect.Array:
+		for i := 0; i < t.Len(); i++ {
+			l = appendLayout(l, t.Elem())
+		}
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			l = appendLayout(l, t.Field(i).Type)
+		}
+	case reflect.Complex64:
+		l = append(l, 4, 4)
+	case reflect.Complex128:
+		l = append(l, 8, 8)
+	default:
+		l = append(l, wireSize(t))
+	}
+	return l
+}
@@ -0,0 +1,184 @@
+// Package flv reads and writes the tag framing of FLV files: the file
+// header, then tags each followed by the size of the tag, so the file can
+// be walked backwards. Tag bodies are left to the caller; script data tags
+// hold AMF0 values, see package amf.
+//
+// Readers and Writers must use big-endian byte order, the default.
+package flv
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+
+	"github.com/oy3o/codec"
+)
+
+const (
+	// SIGNATURE opens every FLV file.
+	SIGNATURE = "FLV"
+	// HEADER_SIZE is the size of a version 1 file header.
+	HEADER_SIZE = 9
+	// TAG_HEADER_SIZE is the size of a tag header.
+	TAG_HEADER_SIZE = 11
+	// MAX_DATA_SIZE is the largest tag body the header can hold.
+	MAX_DATA_SIZE = 1<<24 - 1
+)
+
+// Tag types.
+const (
+	TagAudio  = 8
+	TagVideo  = 9
+	TagScript = 18
+)
+
+var (
+	// ErrInvalidHeader indicates a file that does not start with an FLV
+	// header.
+	ErrInvalidHeader = errors.New("flv: invalid header")
+
+	// ErrTagSize indicates a previous tag size that does not match the tag
+	// it follows.
+	ErrTagSize = errors.New("flv: previous tag size mismatch")
+)
+
+// Header is the file header.
+type Header struct {
+	Version uint8 // 1
+	Audio   bool  // audio tags are present
+	Video   bool  // video tags are present
+}
+
+// Tag is an FLV tag.
+type Tag struct {
+	Type      uint8
+	Filter    bool   // the body is encrypted
+	Timestamp uint32 // milliseconds
+	StreamID  uint32 // always 0
+	Data      []byte
+}
+
+func unexpected(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// ReadHeader reads the file header and the zero previous tag size that
+// follows it, skipping any header extension.
+func ReadHeader(r *codec.Reader) (Header, error) {
+	var h Header
+	var sig [3]byte
+	var flags uint8
+	var offset, prev uint32
+	r.ReadBytesTo(sig[:])
+	r.ReadUint8(&h.Version)
+	r.ReadUint8(&flags)
+	r.ReadUint32(&offset)
+	if err := r.Err(); err != nil {
+		return h, unexpected(err)
+	}
+	if string(sig[:]) != SIGNATURE || offset < HEADER_SIZE {
+		return h, fmt.Errorf("%w: signature %q, data offset %d", ErrInvalidHeader, sig, offset)
+	}
+	h.Audio, h.Video = flags&0x04 != 0, flags&0x01 != 0
+	codec.Discard(r, int64(offset-HEADER_SIZE))
+	r.ReadUint32(&prev)
+	if err := r.Err(); err != nil {
+		return h, unexpected(err)
+	}
+	if prev != 0 {
+		return h, fmt.Errorf("%w: %d before the first tag", ErrTagSize, prev)
+	}
+	return h, nil
+}
+
+// WriteHeader writes the file header and the zero previous tag size.
+func WriteHeader(w *codec.Writer, h Header) error {
+	var flags uint8
+	if h.Audio {
+		flags |= 0x04
+	}
+	if h.Video {
+		flags |= 0x01
+	}
+	version := h.Version
+	if version == 0 {
+		version = 1
+	}
+	w.WriteString(SIGNATURE)
+	w.WriteUint8(version)
+	w.WriteUint8(flags)
+	w.WriteUint32(HEADER_SIZE)
+	w.WriteUint32(0)
+	return w.Err()
+}
+
+// ReadTag reads a tag and checks the previous tag size that follows it. A
+// Reader at the end of the stream returns io.EOF.
+func ReadTag(r *codec.Reader, t *Tag) error {
+	var typ uint8
+	var size, ts, prev uint32
+	var tsExt uint8
+	r.ReadUint8(&typ)
+	if err := r.Err(); err != nil {
+		return err
+	}
+	r.ReadUint24(&size)
+	r.ReadUint24(&ts)
+	r.ReadUint8(&tsExt)
+	r.ReadUint24(&t.StreamID)
+	if err := r.Err(); err != nil {
+		return unexpected(err)
+	}
+	t.Type, t.Filter = typ&0x1F, typ&0x20 != 0
+	t.Timestamp = uint32(tsExt)<<24 | ts
+	// ReadBytes honours the Reader's allocation limit for the 24-bit size.
+	t.Data = r.ReadBytes(int(size))
+	r.ReadUint32(&prev)
+	if err := r.Err(); err != nil {
+		return unexpected(err)
+	}
+	if prev != TAG_HEADER_SIZE+size {
+		return fmt.Errorf("%w: %d after a tag of %d bytes", ErrTagSize, prev, TAG_HEADER_SIZE+size)
+	}
+	return nil
+}
+
+// WriteTag writes a tag followed by its size.
+func WriteTag(w *codec.Writer, t *Tag) error {
+	if len(t.Data) > MAX_DATA_SIZE {
+		return fmt.Errorf("%w: tag body of %d bytes", codec.ErrLengthOverflow, len(t.Data))
+	}
+	typ := t.Type & 0x1F
+	if t.Filter {
+		typ |= 0x20
+	}
+	w.WriteUint8(typ)
+	w.WriteUint24(uint32(len(t.Data)))
+	w.WriteUint24(t.Timestamp)
+	w.WriteUint8(uint8(t.Timestamp >> 24))
+	w.WriteUint24(t.StreamID)
+	w.WriteBytes(t.Data)
+	w.WriteUint32(uint32(TAG_HEADER_SIZE + len(t.Data)))
+	return w.Err()
+}
+
+// Tags iterates over the tags of r up to the end of the stream, stopping
+// after the first error.
+func Tags(r *codec.Reader) iter.Seq2[*Tag, error] {
+	return func(yield func(*Tag, error) bool) {
+		for {
+			t := new(Tag)
+			err := ReadTag(r, t)
+			if err == io.EOF {
+				return
+			}
+			if !yield(t, err) || err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,59 @@
+//go:build test
+
+package flv
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/oy3o/codec"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFLV(t *testing.T) {
+	var buf bytes.Buffer
+	w, _ := codec.NewWriter(&buf)
+	tags := []*Tag{
+		{Type: TagScript, Data: []byte{0x02, 0x00, 0x0a}},
+		{Type: TagVideo, Timestamp: 0x01020304, Data: []byte{0x17, 0x00}},
+		{Type: TagAudio, Filter: true, Timestamp: 40},
+	}
+	require.NoError(t, WriteHeader(w, Header{Audio: true, Video: true}))
+	for _, tag := range tags {
+		require.NoError(t, WriteTag(w, tag))
+	}
+	require.NoError(t, w.Flush())
+
+	data := buf.Bytes()
+	assert.Equal(t, []byte("FLV\x01\x05\x00\x00\x00\x09\x00\x00\x00\x00"), data[:13])
+	// The video tag: size, timestamp with its extension byte, stream ID.
+	video := data[13+11+3+4:]
+	assert.Equal(t, []byte{TagVideo, 0, 0, 2, 0x02, 0x03, 0x04, 0x01, 0, 0, 0, 0x17, 0x00, 0, 0, 0, 13}, video[:17])
+
+	r, _ := codec.NewReader(bytes.NewReader(data))
+	h, err := ReadHeader(r)
+	require.NoError(t, err)
+	assert.Equal(t, Header{Version: 1, Audio: true, Video: true}, h)
+	var got []*Tag
+	for tag, err := range Tags(r) {
+		require.NoError(t, err)
+		got = append(got, tag)
+	}
+	assert.Equal(t, tags, got)
+
+	data[len(data)-1]++
+	r, _ = codec.NewReader(bytes.NewReader(data[13:]))
+	var tag Tag
+	require.NoError(t, ReadTag(r, &tag))
+	require.NoError(t, ReadTag(r, &tag))
+	assert.ErrorIs(t, ReadTag(r, &tag), ErrTagSize)
+
+	// A huge declared size is refused before allocating.
+	r, _ = codec.NewReaderOpts(bytes.NewReader([]byte{TagVideo, 0xFF, 0xFF, 0xFF, 0, 0, 0, 0, 0, 0, 0}), codec.WithMaxAlloc(1<<10))
+	assert.ErrorIs(t, ReadTag(r, &tag), codec.ErrLengthOverflow)
+
+	r, _ = codec.NewReader(bytes.NewReader([]byte("FLX\x01\x05\x00\x00\x00\x09")))
+	_, err = ReadHeader(r)
+	assert.ErrorIs(t, err, ErrInvalidHeader)
+}
@@ -0,0 +1,482 @@
+package codec
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"iter"
+	"math"
+)
+
+// FRAME_UVARINT selects a uvarint length prefix in Framer.WithPrefix.
+const FRAME_UVARINT = 0
+
+// Framer delimits messages on a stream with a length prefix, the usual message
+// boundary of TCP protocols. By default the prefix is a big-endian uint32 and
+// frames are limited to MAX_DECODE_SIZE bytes; both are configurable. With a
+// sync marker, each frame is preceded by the marker and the reader realigns
+// to the next marker, so a corrupt frame costs only itself. With a checksum,
+// each frame body is followed by a trailer verified when the frame is read.
+//
+// Either side may be nil if the Framer is used in one direction only.
+type Framer struct {
+	src   io.Reader
+	dst   io.Writer
+	r     *Reader
+	w     *Writer
+	width int
+	order binary.ByteOrder
+	max   int
+	sync  SyncMarker
+	hook  Hook
+	ctx   context.Context
+	acct  *MemoryAccount
+	sum   Checksum
+
+	compress bool          // frames carry a compression ID, set by WithCompression
+	method   CompressionID // compression of written frames
+	zbuf     []byte        // compressed frame being written
+	dbuf     []byte        // decompressed frame returned by ReadFrame
+
+	buf     []byte    // frame buffer reused by ReadFrame
+	body    io.Reader // unread remainder of the frame returned by NextFrame
+	skipped int64
+}
+
+// NewFramer returns a Framer reading frames from r and writing frames to w.
+func NewFramer(r io.Reader, w io.Writer) *Framer {
+	return &Framer{src: r, dst: w, width: 4, order: BE, max: MAX_DECODE_SIZE, ctx: context.Background()}
+}
+
+// WithPrefix sets the width of the length prefix in bytes (1, 2, 4 or 8, or
+// FRAME_UVARINT) and its byte order.
+func (f *Framer) WithPrefix(width int, order binary.ByteOrder) *Framer {
+	f.width, f.order = width, order
+	return f
+}
+
+// WithMaxSize sets the largest frame accepted in either direction.
+func (f *Framer) WithMaxSize(n int) *Framer {
+	f.max = n
+	return f
+}
+
+// WithSync makes every frame start with the marker m.
+func (f *Framer) WithSync(m SyncMarker) *Framer {
+	f.sync = m
+	return f
+}
+
+// WithChecksum appends a trailer holding the checksum of the body, in the
+// prefix byte order, after every frame. The prefix does not count the trailer.
+// A frame whose trailer does not match fails with ErrChecksumMismatch.
+func (f *Framer) WithChecksum(c Checksum) *Framer {
+	f.sum = c
+	return f
+}
+
+// WithCompression makes every frame start with a CompressionID byte and
+// compresses written frames with the Compressor registered under id. Frames
+// are read with whichever registered Compressor their ID names, so both
+// sides must enable compression but need not agree on the method; see
+// Negotiate. The prefix and the frame size limit apply to the compressed
+// frame, and the limit also bounds decompressed frames.
+func (f *Framer) WithCompression(id CompressionID) *Framer {
+	f.compress, f.method = true, id
+	return f
+}
+
+// WithHook makes h observe every frame written or read. Frames of raw bytes
+// are named "frame", frames of codecs by their type.
+func (f *Framer) WithHook(h Hook) *Framer {
+	f.hook = h
+	return f
+}
+
+// WithContext sets the context passed to the hook, for instance one carrying
+// the trace span of the request the frames belong to.
+func (f *Framer) WithContext(ctx context.Context) *Framer {
+	f.ctx = ctx
+	return f
+}
+
+// observe starts observing an operation, returning the function ending it.
+func (f *Framer) observe(op ProfileOp, c Codec) func(n int64, err error) {
+	if f.hook == nil {
+		return nil
+	}
+	name := "frame"
+	if c != nil {
+		name = fmt.Sprintf("%T", c)
+	}
+	return f.hook(f.ctx, op, name)
+}
+
+// WithAccount charges the buffers the Framer allocates to a. It must be set
+// before the first frame is written or read.
+func (f *Framer) WithAccount(a *MemoryAccount) *Framer {
+	f.acct = a
+	return f
+}
+
+// Resynced returns the total number of bytes skipped while realigning to the
+// sync marker, which is non-zero only if the stream was corrupt.
+func (f *Framer) Resynced() int64 { return f.skipped }
+
+// limit returns the largest length the prefix can carry, capped by the max size.
+func (f *Framer) limit() (uint64, error) {
+	var capacity uint64
+	switch f.width {
+	case FRAME_UVARINT, 8:
+		capacity = 1<<63 - 1
+	case 1, 2, 4:
+		capacity = 1<<(8*f.width) - 1
+	default:
+		return 0, fmt.Errorf("%w: frame prefix width %d", ErrInvalidBitCount, f.width*8)
+	}
+	if f.max > 0 {
+		capacity = min(capacity, uint64(f.max))
+	}
+	return capacity, nil
+}
+
+func (f *Framer) writer() (*Writer, error) {
+	if f.w == nil {
+		if f.dst == nil {
+			return nil, ErrNilIO
+		}
+		w, err := NewWriterSize(f.dst, BUFFER_SIZE)
+		if err != nil {
+			return nil, err
+		}
+		f.w = w.WithByteOrder(f.order).WithAccount(f.acct)
+	}
+	return f.w, f.w.Err()
+}
+
+// writeHeader writes the sync marker and the prefix of an n-byte frame.
+func (f *Framer) writeHeader(w *Writer, n int) error {
+	limit, err := f.limit()
+	if err != nil {
+		return err
+	}
+	if uint64(n) > limit {
+		return fmt.Errorf("%w: frame of %d bytes exceeds %d", ErrLengthOverflow, n, limit)
+	}
+	if f.sync != nil {
+		w.WriteSync(f.sync)
+	}
+	switch f.width {
+	case FRAME_UVARINT:
+		var buf [binary.MaxVarintLen64]byte
+		w.Write(binary.AppendUvarint(buf[:0], uint64(n)))
+	case 1:
+		w.WriteUint8(uint8(n))
+	case 2:
+		w.WriteUint16(uint16(n))
+	case 4:
+		w.WriteUint32(uint32(n))
+	case 8:
+		w.WriteUint64(uint64(n))
+	}
+	return nil
+}
+
+// WriteFrame writes p as one frame and flushes it.
+func (f *Framer) WriteFrame(p []byte) (err error) {
+	if done := f.observe(ProfileEncode, nil); done != nil {
+		defer func() { done(int64(len(p)), err) }()
+	}
+	return f.writeFrame(p)
+}
+
+func (f *Framer) writeFrame(p []byte) error {
+	w, err := f.writer()
+	if err != nil {
+		return err
+	}
+	if f.compress {
+		c, err := compressorOf(f.method)
+		if err != nil {
+			return err
+		}
+		old := cap(f.zbuf)
+		f.zbuf, err = c.Compress(append(f.zbuf[:0], byte(f.method)), p)
+		f.acct.grow(old, cap(f.zbuf))
+		if err != nil {
+			return err
+		}
+		p = f.zbuf
+	}
+	if err := f.writeHeader(w, len(p)); err != nil {
+		return err
+	}
+	w.WriteBytes(p)
+	if f.sum != nil {
+		f.sum.Reset()
+		f.sum.Write(p)
+		f.writeTrailer(w)
+	}
+	return w.Flush()
+}
+
+// WriteCodec writes the encoding of c as one frame and flushes it. The prefix
+// is taken from c.Size(), so c is streamed without an intermediate buffer.
+func (f *Framer) WriteCodec(c Codec) (err error) {
+	if done := f.observe(ProfileEncode, c); done != nil {
+		defer func() { done(int64(c.Size()), err) }()
+	}
+	if f.compress {
+		p, err := c.MarshalBinary()
+		if err != nil {
+			return err
+		}
+		return f.writeFrame(p)
+	}
+	w, err := f.writer()
+	if err != nil {
+		return err
+	}
+	n := c.Size()
+	if err := f.writeHeader(w, n); err != nil {
+		return err
+	}
+	start := w.Count()
+	if f.sum != nil {
+		f.sum.Reset()
+		inner := w.w
+		w.w = &hashWriter{WriterPro: inner, h: f.sum}
+		w.WriteFrom(c)
+		w.w = inner
+	} else {
+		w.WriteFrom(c)
+	}
+	if w.Err() == nil && w.Count()-start != int64(n) {
+		return fmt.Errorf("%w: %T wrote %d bytes, Size reported %d", ErrLengthOverflow, c, w.Count()-start, n)
+	}
+	if f.sum != nil {
+		f.writeTrailer(w)
+	}
+	return w.Flush()
+}
+
+// writeTrailer writes the checksum of the frame body.
+func (f *Framer) writeTrailer(w *Writer) {
+	var buf [8]byte
+	w.WriteBytes(appendTrailer(buf[:0], f.sum, f.order))
+}
+
+// verifyTrailer reads the trailer of a frame body already fed to the checksum.
+func (f *Framer) verifyTrailer(r io.Reader) error {
+	var buf [8]byte
+	want := appendTrailer(buf[:0], f.sum, f.order)
+	got := make([]byte, len(want))
+	if _, err := io.ReadFull(r, got); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	if !bytes.Equal(got, want) {
+		return fmt.Errorf("%w: frame trailer %x, computed %x", ErrChecksumMismatch, got, want)
+	}
+	return nil
+}
+
+func (f *Framer) reader() (*Reader, error) {
+	if f.r == nil {
+		if f.src == nil {
+			return nil, ErrNilIO
+		}
+		r, err := NewReaderSize(f.src, BUFFER_SIZE)
+		if err != nil {
+			return nil, err
+		}
+		f.r = r.WithByteOrder(f.order).WithAccount(f.acct)
+	}
+	if f.body != nil {
+		// Skip whatever the caller left unread of the previous streamed frame.
+		body := f.body
+		f.body = nil
+		if _, err := io.Copy(io.Discard, body); err != nil {
+			return nil, err
+		}
+	}
+	return f.r, nil
+}
+
+// readHeader reads the sync marker and prefix of the next frame. It returns
+// io.EOF if the stream ends cleanly before the frame.
+func (f *Framer) readHeader(r *Reader) (int, error) {
+	limit, err := f.limit()
+	if err != nil {
+		return 0, err
+	}
+	start := r.Count()
+	if f.sync != nil {
+		skipped, err := r.AlignSync(f.sync)
+		f.skipped += skipped
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	var n uint64
+	if f.width == FRAME_UVARINT {
+		n, err = binary.ReadUvarint(r)
+	} else {
+		var buf [8]byte
+		p := buf[:f.width]
+		if _, err = io.ReadFull(r, p); err == nil {
+			switch f.width {
+			case 1:
+				n = uint64(p[0])
+			case 2:
+				n = uint64(f.order.Uint16(p))
+			case 4:
+				n = uint64(f.order.Uint32(p))
+			case 8:
+				n = f.order.Uint64(p)
+			}
+		}
+	}
+	if err != nil {
+		if err == io.EOF && r.Count() != start {
+			return 0, io.ErrUnexpectedEOF
+		}
+		return 0, err
+	}
+	if n > limit {
+		return 0, fmt.Errorf("%w: frame of %d bytes exceeds %d", ErrLengthOverflow, n, limit)
+	}
+	return int(n), nil
+}
+
+// ReadFrame reads the next complete frame. The returned slice is reused by
+// the next call to ReadFrame. It returns io.EOF at a clean end of stream.
+func (f *Framer) ReadFrame() (p []byte, err error) {
+	if done := f.observe(ProfileDecode, nil); done != nil {
+		defer func() { done(int64(len(p)), err) }()
+	}
+	return f.readFrame()
+}
+
+func (f *Framer) readFrame() ([]byte, error) {
+	r, err := f.reader()
+	if err != nil {
+		return nil, err
+	}
+	n, err := f.readHeader(r)
+	if err != nil {
+		return nil, err
+	}
+	if cap(f.buf) < n {
+		old := cap(f.buf)
+		f.buf = make([]byte, n)
+		f.acct.grow(old, cap(f.buf))
+	}
+	f.buf = f.buf[:n]
+	r.ReadBytesTo(f.buf)
+	if err := r.Err(); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	if f.sum != nil {
+		f.sum.Reset()
+		f.sum.Write(f.buf)
+		if err := f.verifyTrailer(r); err != nil {
+			return nil, err
+		}
+	}
+	if f.compress {
+		return f.decompress(f.buf)
+	}
+	return f.buf, nil
+}
+
+// decompress decodes a frame of a Framer with compression.
+func (f *Framer) decompress(p []byte) ([]byte, error) {
+	if len(p) == 0 {
+		return nil, fmt.Errorf("%w: frame without compression id", ErrTruncatedData)
+	}
+	c, err := compressorOf(CompressionID(p[0]))
+	if err != nil {
+		return nil, err
+	}
+	limit := f.max
+	if limit <= 0 {
+		limit = math.MaxInt
+	}
+	old := cap(f.dbuf)
+	f.dbuf, err = c.Decompress(f.dbuf[:0], p[1:], limit)
+	f.acct.grow(old, cap(f.dbuf))
+	if err != nil {
+		return nil, err
+	}
+	return f.dbuf, nil
+}
+
+// NextFrame returns a reader streaming the next frame and its length, for
+// frames too large to buffer. Any unread part is skipped by the next read.
+// With a checksum, the trailer is verified when the body reaches its end.
+// With compression, the frame is read and decompressed in full.
+func (f *Framer) NextFrame() (io.Reader, int, error) {
+	if f.compress {
+		p, err := f.readFrame()
+		if err != nil {
+			return nil, 0, err
+		}
+		return bytes.NewReader(p), len(p), nil
+	}
+	r, err := f.reader()
+	if err != nil {
+		return nil, 0, err
+	}
+	n, err := f.readHeader(r)
+	if err != nil {
+		return nil, 0, err
+	}
+	if f.sum != nil {
+		f.sum.Reset()
+		f.body = ChainReader(io.TeeReader(r, f.sum), int64(n), func(io.Reader) error {
+			return f.verifyTrailer(r)
+		})
+	} else {
+		f.body = io.LimitReader(r, int64(n))
+	}
+	return f.body, n, nil
+}
+
+// ReadCodec reads the next frame and decodes it into c with UnmarshalBinary,
+// so the frame must hold exactly the encoding of c.
+func (f *Framer) ReadCodec(c Codec) (err error) {
+	var p []byte
+	if done := f.observe(ProfileDecode, c); done != nil {
+		defer func() { done(int64(len(p)), err) }()
+	}
+	if p, err = f.readFrame(); err != nil {
+		return err
+	}
+	return c.UnmarshalBinary(p)
+}
+
+// All returns an iterator over the remaining frames. Each frame is only valid
+// until the next iteration. Iteration ends at a clean end of stream; any other
+// error is yielded once and ends it.
+func (f *Framer) All() iter.Seq2[[]byte, error] {
+	return func(yield func([]byte, error) bool) {
+		for {
+			p, err := f.ReadFrame()
+			if err == io.EOF {
+				return
+			}
+			if !yield(p, err) || err != nil {
+				return
+			}
+		}
+	}
+}
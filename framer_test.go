@@ -0,0 +1,163 @@
+//go:build test
+
+package codec
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFramer(t *testing.T) {
+	var buf bytes.Buffer
+	f := NewFramer(&buf, &buf)
+	require.NoError(t, f.WriteFrame([]byte("hello")))
+	require.NoError(t, f.WriteCodec(&mockCodec{Payload: mockPayload{ID: 7}}))
+	require.NoError(t, f.WriteFrame(nil))
+	assert.Equal(t, []byte("\x00\x00\x00\x05hello"), buf.Bytes()[:9])
+
+	p, err := f.ReadFrame()
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(p))
+	var m mockCodec
+	require.NoError(t, f.ReadCodec(&m))
+	assert.EqualValues(t, 7, m.Payload.ID)
+	p, err = f.ReadFrame()
+	require.NoError(t, err)
+	assert.Empty(t, p)
+	_, err = f.ReadFrame()
+	assert.Equal(t, io.EOF, err)
+
+	// Prefix widths and byte orders.
+	for _, width := range []int{1, 2, 8, FRAME_UVARINT} {
+		buf.Reset()
+		f := NewFramer(&buf, &buf).WithPrefix(width, LE)
+		require.NoError(t, f.WriteFrame(bytes.Repeat([]byte{1}, 200)))
+		require.NoError(t, f.WriteFrame([]byte{2}))
+		var sizes []int
+		for p, err := range f.All() {
+			require.NoError(t, err)
+			sizes = append(sizes, len(p))
+		}
+		assert.Equal(t, []int{200, 1}, sizes, "width %d", width)
+	}
+
+	buf.Reset()
+	f = NewFramer(&buf, &buf).WithPrefix(1, BE)
+	assert.ErrorIs(t, f.WriteFrame(make([]byte, 256)), ErrLengthOverflow)
+	f = NewFramer(bytes.NewReader([]byte{0, 0, 1, 0}), nil).WithMaxSize(255)
+	_, err = f.ReadFrame()
+	assert.ErrorIs(t, err, ErrLengthOverflow)
+	_, err = NewFramer(bytes.NewReader([]byte{0, 0, 0, 4, 1}), nil).ReadFrame()
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	_, err = NewFramer(bytes.NewReader([]byte{0, 0}), nil).ReadFrame()
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	assert.ErrorIs(t, NewFramer(nil, nil).WriteFrame(nil), ErrNilIO)
+}
+
+func TestFramerStreamAndSync(t *testing.T) {
+	marker := NewSyncMarker()
+	var buf bytes.Buffer
+	f := NewFramer(nil, &buf).WithSync(marker)
+	require.NoError(t, f.WriteFrame([]byte("first")))
+	require.NoError(t, f.WriteFrame([]byte("second")))
+	require.NoError(t, f.WriteFrame([]byte("third")))
+
+	// Corrupt the length of the second frame: the reader resyncs to the third.
+	data := buf.Bytes()
+	second := SYNC_SIZE + 4 + 5
+	data[second+SYNC_SIZE] = 0xFF
+	r := NewFramer(bytes.NewReader(data), nil).WithSync(marker).WithMaxSize(1 << 10)
+	body, n, err := r.NextFrame()
+	require.NoError(t, err)
+	assert.Equal(t, 5, n)
+	head := make([]byte, 2)
+	_, err = io.ReadFull(body, head)
+	require.NoError(t, err)
+	assert.Equal(t, "fi", string(head))
+	_, err = r.ReadFrame() // skips the rest of "first"
+	assert.ErrorIs(t, err, ErrLengthOverflow)
+	p, err := r.ReadFrame()
+	require.NoError(t, err)
+	assert.Equal(t, "third", string(p))
+	assert.EqualValues(t, 6, r.Resynced())
+}
+
+func TestFramerPipe(t *testing.T) {
+	a, b := net.Pipe()
+	defer a.Close()
+	go func() {
+		defer b.Close()
+		f := NewFramer(b, b)
+		for p, err := range f.All() {
+			if err != nil || f.WriteFrame(bytes.ToUpper(p)) != nil {
+				return
+			}
+		}
+	}()
+
+	f := NewFramer(a, a)
+	require.NoError(t, f.WriteFrame([]byte("ping")))
+	p, err := f.ReadFrame()
+	require.NoError(t, err)
+	assert.Equal(t, "PING", string(p))
+}
+
+func TestFramerHook(t *testing.T) {
+	p := NewProfiler()
+	var buf bytes.Buffer
+	f := NewFramer(&buf, &buf).WithHook(p.Hook())
+	require.NoError(t, f.WriteFrame([]byte("abc")))
+	require.NoError(t, f.WriteCodec(&mockCodec{}))
+	_, err := f.ReadFrame()
+	require.NoError(t, err)
+	require.NoError(t, f.ReadCodec(&mockCodec{}))
+
+	stats := map[string]ProfileStat{}
+	for _, s := range p.Stats() {
+		stats[s.Op.String()+" "+s.Name] = s
+	}
+	assert.EqualValues(t, 3, stats["encode frame"].Bytes)
+	assert.EqualValues(t, 3, stats["decode frame"].Bytes)
+	name := fmt.Sprintf("%T", &mockCodec{})
+	assert.EqualValues(t, 8, stats["encode "+name].Bytes)
+	assert.EqualValues(t, 1, stats["decode "+name].Calls)
+}
+
+func TestMemoryAccount(t *testing.T) {
+	service := NewMemoryAccount(nil)
+	conn := NewMemoryAccount(service)
+
+	a, b := net.Pipe()
+	defer a.Close()
+	go func() {
+		defer b.Close()
+		NewFramer(nil, b).WriteFrame(make([]byte, 10000))
+	}()
+	f := NewFramer(a, nil).WithAccount(conn)
+	_, err := f.ReadFrame()
+	require.NoError(t, err)
+	assert.EqualValues(t, BUFFER_SIZE+10000, conn.Current())
+	assert.EqualValues(t, BUFFER_SIZE+10000, service.Current())
+
+	d := NewDecoderWriter(func() *mockCodec { return &mockCodec{} }, func(*mockCodec) error { return nil }).WithAccount(conn)
+	d.Write(make([]byte, 20))
+	assert.Greater(t, conn.Current(), int64(BUFFER_SIZE+10000))
+
+	peak := service.Peak()
+	conn.Close()
+	assert.Zero(t, conn.Current())
+	assert.Zero(t, service.Current())
+	assert.Equal(t, peak, service.Peak())
+	service.ResetPeak()
+	assert.Zero(t, service.Peak())
+
+	var none *MemoryAccount
+	none.Add(1)
+	assert.Zero(t, none.Current())
+}
@@ -5,6 +5,7 @@ import (
 	"encoding"
 	"fmt"
 	"io"
+	"slices"
 )
 
 // MarshalBinaryGeneric provides a generic `encoding.BinaryMarshaler` implementation.
@@ -104,3 +105,18 @@ func MarshalToGeneric[T interface {
 	}
 	return int(n), nil
 }
+
+// MarshalAppendGeneric provides a generic `MarshalAppend` implementation. It grows
+// dst by Size bytes at most once and encodes in place with MarshalTo.
+func MarshalAppendGeneric[T interface {
+	Size() int
+	MarshalTo([]byte) (int, error)
+}](v T, dst []byte) ([]byte, error) {
+	start, size := len(dst), v.Size()
+	dst = slices.Grow(dst, size)
+	n, err := v.MarshalTo(dst[start : start+size])
+	if err != nil {
+		return dst[:start], err
+	}
+	return dst[:start+n], nil
+}
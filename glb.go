@@ -0,0 +1,118 @@
+package codec
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+const (
+	// GLB_HEADER_SIZE is the size of the GLB file header.
+	GLB_HEADER_SIZE = 12
+	// GLB_VERSION is the GLB container version written and accepted.
+	GLB_VERSION = 2
+)
+
+var (
+	// GLBChunks is the chunk layout of glTF binary files: little-endian
+	// lengths before the type, and bodies padded to 4 bytes by the writer.
+	GLBChunks = ChunkFormat{Order: LE, LengthFirst: true, Align: 4}
+
+	GLBJSON = FourCC{'J', 'S', 'O', 'N'}
+	GLBBIN  = FourCC{'B', 'I', 'N', 0}
+
+	glbMagic = FourCC{'g', 'l', 'T', 'F'}
+)
+
+// GLBReader reads a glTF binary container: a header, the JSON chunk, then
+// an optional BIN chunk and any extension chunks, read with Next.
+type GLBReader struct {
+	*ChunkReader
+	Version uint32
+	Length  uint32 // total file length declared by the header
+}
+
+// NewGLBReader reads the GLB header from r.
+func NewGLBReader(r io.Reader) (*GLBReader, error) {
+	var hdr [GLB_HEADER_SIZE]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	if FourCC(hdr[:4]) != glbMagic {
+		return nil, fmt.Errorf("%w: GLB magic %q", ErrMagicMismatch, hdr[:4])
+	}
+	g := &GLBReader{Version: LE.Uint32(hdr[4:]), Length: LE.Uint32(hdr[8:])}
+	if g.Version != GLB_VERSION {
+		return nil, fmt.Errorf("%w: GLB version %d", ErrUnknownVersion, g.Version)
+	}
+	if g.Length < GLB_HEADER_SIZE {
+		return nil, fmt.Errorf("%w: GLB length %d", ErrLengthOverflow, g.Length)
+	}
+	format := GLBChunks
+	format.Max = g.Length - GLB_HEADER_SIZE
+	g.ChunkReader = NewChunkReader(io.LimitReader(r, int64(format.Max)), format)
+	return g, nil
+}
+
+// JSON reads the JSON chunk, which must come first. Padding spaces are kept,
+// as they are valid JSON whitespace.
+func (g *GLBReader) JSON() ([]byte, error) {
+	c, err := g.Next()
+	if err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	if c.ID != GLBJSON {
+		return nil, fmt.Errorf("%w: first GLB chunk is %q, not JSON", ErrMagicMismatch, c.ID)
+	}
+	return io.ReadAll(c.Body)
+}
+
+// WriteGLB writes a glTF binary container holding json and, unless bin is
+// nil, a BIN chunk streamed from binSize bytes of bin. The JSON chunk is
+// padded with spaces and the BIN chunk with zeros, as the format requires.
+func WriteGLB(w *Writer, json []byte, bin io.Reader, binSize int64) {
+	if w.err != nil {
+		return
+	}
+	jsonLen := Roundup(int64(len(json)), 4)
+	length := GLB_HEADER_SIZE + 8 + jsonLen
+	binLen := Roundup(binSize, 4)
+	if bin != nil {
+		length += 8 + binLen
+	}
+	if binSize < 0 || length > int64(^uint32(0)) {
+		w.setError(fmt.Errorf("%w: GLB of %d bytes", ErrLengthOverflow, length))
+		return
+	}
+
+	var hdr [GLB_HEADER_SIZE + 8]byte
+	copy(hdr[:4], glbMagic[:])
+	LE.PutUint32(hdr[4:], GLB_VERSION)
+	LE.PutUint32(hdr[8:], uint32(length))
+	LE.PutUint32(hdr[12:], uint32(jsonLen))
+	copy(hdr[16:], GLBJSON[:])
+	w.Write(hdr[:])
+	w.Write(json)
+	w.Write(bytes.Repeat([]byte{' '}, int(jsonLen)-len(json)))
+
+	if bin == nil {
+		return
+	}
+	var chunk [8]byte
+	LE.PutUint32(chunk[:], uint32(binLen))
+	copy(chunk[4:], GLBBIN[:])
+	w.Write(chunk[:])
+	if n, err := io.CopyN(w, bin, binSize); err != nil && w.err == nil {
+		if err == io.EOF {
+			err = fmt.Errorf("%w: BIN chunk of %d bytes, declared %d", ErrTruncatedData, n, binSize)
+		}
+		w.setError(err)
+	}
+	w.WriteZeros(binLen - binSize)
+}
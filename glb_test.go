@@ -0,0 +1,48 @@
+//go:build test
+
+package codec
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGLB(t *testing.T) {
+	json := []byte(`{"asset":{"version":"2.0"}}`)
+	bin := []byte{1, 2, 3, 4, 5}
+	var buf bytes.Buffer
+	w, _ := NewWriter(&buf)
+	WriteGLB(w, json, bytes.NewReader(bin), int64(len(bin)))
+	require.NoError(t, w.Flush())
+	require.Equal(t, 12+8+28+8+8, buf.Len())
+	assert.Equal(t, "glTF\x02\x00\x00\x00\x40\x00\x00\x00\x1c\x00\x00\x00JSON", buf.String()[:20])
+
+	g, err := NewGLBReader(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	assert.EqualValues(t, buf.Len(), g.Length)
+	got, err := g.JSON()
+	require.NoError(t, err)
+	assert.Equal(t, string(json)+" ", string(got))
+
+	c, err := g.Next()
+	require.NoError(t, err)
+	assert.Equal(t, GLBBIN, c.ID)
+	data, err := io.ReadAll(c.Body)
+	require.NoError(t, err)
+	assert.Equal(t, []byte{1, 2, 3, 4, 5, 0, 0, 0}, data)
+	_, err = g.Next()
+	assert.Equal(t, io.EOF, err)
+
+	w, _ = NewWriter(&bytes.Buffer{})
+	WriteGLB(w, json, bytes.NewReader(bin), 6)
+	assert.ErrorIs(t, w.Err(), ErrTruncatedData)
+
+	bad := bytes.Clone(buf.Bytes())
+	bad[4] = 1
+	_, err = NewGLBReader(bytes.NewReader(bad))
+	assert.ErrorIs(t, err, ErrUnknownVersion)
+}
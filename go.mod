@@ -6,6 +6,7 @@ require (
 	github.com/puzpuzpuz/xsync/v4 v4.2.0
 	github.com/stretchr/testify v1.11.1
 	golang.org/x/exp v0.0.0-20251209150349-8475f28825e9
+	golang.org/x/text v0.36.0
 )
 
 require (
@@ -0,0 +1,263 @@
+// Package grib2 reads and writes the section framing of GRIB edition 2
+// messages, the WMO format of gridded weather and climate data: the
+// indicator section, numbered sections each prefixed with its length, and
+// the "7777" end section. Section contents are left to the caller, apart
+// from grid point data with simple packing, see SimplePacking.
+//
+// Readers and Writers must use big-endian byte order, the default.
+package grib2
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"math"
+
+	"github.com/oy3o/codec"
+)
+
+const (
+	// SIGNATURE opens every message.
+	SIGNATURE = "GRIB"
+	// END closes every message.
+	END = "7777"
+	// EDITION is the only edition supported.
+	EDITION = 2
+	// INDICATOR_SIZE is the size of the indicator section.
+	INDICATOR_SIZE = 16
+	// MAX_LENGTH bounds the length of a message, which the indicator section
+	// declares as a 64-bit integer.
+	MAX_LENGTH = 1 << 30
+
+	sectionHeaderSize = 5
+	endMarker         = 0x37373737 // END read as a section length
+)
+
+// Section numbers.
+const (
+	SectionIdentification = 1
+	SectionLocal          = 2
+	SectionGrid           = 3
+	SectionProduct        = 4
+	SectionRepresentation = 5
+	SectionBitmap         = 6
+	SectionData           = 7
+)
+
+var (
+	// ErrInvalidMessage indicates a message that does not start with the
+	// indicator section or whose sections do not add up to its length.
+	ErrInvalidMessage = errors.New("grib2: invalid message")
+
+	// ErrInvalidSection indicates a section with an invalid length or
+	// number, or contents too short for their template.
+	ErrInvalidSection = errors.New("grib2: invalid section")
+
+	// ErrUnsupportedEdition indicates a message of an edition other than 2.
+	ErrUnsupportedEdition = errors.New("grib2: unsupported edition")
+
+	// ErrUnsupportedTemplate indicates a data representation template other
+	// than simple packing.
+	ErrUnsupportedTemplate = errors.New("grib2: unsupported template")
+)
+
+// Section is a section of a message other than the indicator and end
+// sections.
+type Section struct {
+	Number uint8
+	Data   []byte // after the length and number
+}
+
+// Bits returns a BitReader over the data of the section, for unpacking the
+// bit-packed values of the data section.
+func (s *Section) Bits() *codec.BitReader {
+	r, _ := codec.NewReader(codec.NewBytesReader(s.Data))
+	return codec.NewBitReader(r, codec.MSBFirst)
+}
+
+// Message is a GRIB2 message. Sections 2 to 7 may repeat to hold several
+// fields on the same grid.
+type Message struct {
+	Discipline uint8 // 0 for meteorological products
+	Edition    uint8 // 2
+	Sections   []Section
+}
+
+// Section returns the first section numbered n, or nil if there is none.
+func (m *Message) Section(n uint8) *Section {
+	for i := range m.Sections {
+		if m.Sections[i].Number == n {
+			return &m.Sections[i]
+		}
+	}
+	return nil
+}
+
+func unexpected(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// ReadMessage reads a message up to its end section. A Reader at the end of
+// the stream returns io.EOF. Files with data between messages can be
+// scanned for SIGNATURE with Reader.WithResync first.
+func ReadMessage(r *codec.Reader) (*Message, error) {
+	m := new(Message)
+	var sig [4]byte
+	var reserved uint16
+	var length uint64
+	r.ReadBytesTo(sig[:])
+	if err := r.Err(); err != nil {
+		return nil, err
+	}
+	r.ReadUint16(&reserved)
+	r.ReadUint8(&m.Discipline)
+	r.ReadUint8(&m.Edition)
+	r.ReadUint64(&length)
+	if err := r.Err(); err != nil {
+		return nil, unexpected(err)
+	}
+	if string(sig[:]) != SIGNATURE {
+		return nil, fmt.Errorf("%w: signature %q", ErrInvalidMessage, sig)
+	}
+	if m.Edition != EDITION {
+		return nil, fmt.Errorf("%w: %d", ErrUnsupportedEdition, m.Edition)
+	}
+	if length > MAX_LENGTH {
+		return nil, fmt.Errorf("%w: message of %d bytes", codec.ErrLengthOverflow, length)
+	}
+
+	for left := int64(length) - INDICATOR_SIZE; ; {
+		if left < int64(len(END)) {
+			return nil, fmt.Errorf("%w: no end section within %d bytes", ErrInvalidMessage, length)
+		}
+		var size uint32
+		r.ReadUint32(&size)
+		if err := r.Err(); err != nil {
+			return nil, unexpected(err)
+		}
+		if size == endMarker {
+			if left != int64(len(END)) {
+				return nil, fmt.Errorf("%w: end section %d bytes before the end", ErrInvalidMessage, left-int64(len(END)))
+			}
+			return m, nil
+		}
+		if size < sectionHeaderSize || int64(size) > left {
+			return nil, fmt.Errorf("%w: length %d with %d bytes left", ErrInvalidSection, size, left)
+		}
+		var s Section
+		r.ReadUint8(&s.Number)
+		s.Data = r.ReadBytes(int(size) - sectionHeaderSize)
+		if err := r.Err(); err != nil {
+			return nil, unexpected(err)
+		}
+		if s.Number < SectionIdentification || s.Number > SectionData {
+			return nil, fmt.Errorf("%w: number %d", ErrInvalidSection, s.Number)
+		}
+		m.Sections = append(m.Sections, s)
+		left -= int64(size)
+	}
+}
+
+// WriteMessage writes a message, computing its length and the lengths of
+// its sections.
+func WriteMessage(w *codec.Writer, m *Message) error {
+	length := INDICATOR_SIZE + len(END)
+	for _, s := range m.Sections {
+		length += sectionHeaderSize + len(s.Data)
+	}
+	if length > MAX_LENGTH {
+		return fmt.Errorf("%w: message of %d bytes", codec.ErrLengthOverflow, length)
+	}
+	edition := m.Edition
+	if edition == 0 {
+		edition = EDITION
+	}
+	w.WriteString(SIGNATURE)
+	w.WriteUint16(0)
+	w.WriteUint8(m.Discipline)
+	w.WriteUint8(edition)
+	w.WriteUint64(uint64(length))
+	for _, s := range m.Sections {
+		w.WriteUint32(uint32(sectionHeaderSize + len(s.Data)))
+		w.WriteUint8(s.Number)
+		w.WriteBytes(s.Data)
+	}
+	w.WriteString(END)
+	return w.Err()
+}
+
+// Messages iterates over the messages of r up to the end of the stream,
+// stopping after the first error.
+func Messages(r *codec.Reader) iter.Seq2[*Message, error] {
+	return func(yield func(*Message, error) bool) {
+		for {
+			m, err := ReadMessage(r)
+			if err == io.EOF {
+				return
+			}
+			if !yield(m, err) || err != nil {
+				return
+			}
+		}
+	}
+}
+
+// SimplePacking holds the parameters of data representation template 5.0,
+// grid point data with simple packing. A packed value X stands for
+//
+//	Y = (Reference + X * 2^BinaryScale) / 10^DecimalScale
+type SimplePacking struct {
+	Count        uint32 // number of packed values
+	Reference    float32
+	BinaryScale  int16
+	DecimalScale int16
+	Bits         uint8 // bits per packed value, 0 if all values are Reference
+}
+
+// ParseSimplePacking parses a data representation section.
+func ParseSimplePacking(s *Section) (SimplePacking, error) {
+	var p SimplePacking
+	if s.Number != SectionRepresentation || len(s.Data) < 15 {
+		return p, fmt.Errorf("%w: section %d of %d bytes is no data representation", ErrInvalidSection, s.Number, len(s.Data))
+	}
+	d := s.Data
+	if template := codec.BE.Uint16(d[4:]); template != 0 {
+		return p, fmt.Errorf("%w: data representation template %d", ErrUnsupportedTemplate, template)
+	}
+	p.Count = codec.BE.Uint32(d)
+	p.Reference = math.Float32frombits(codec.BE.Uint32(d[6:]))
+	p.BinaryScale = signMagnitude(codec.BE.Uint16(d[10:]))
+	p.DecimalScale = signMagnitude(codec.BE.Uint16(d[12:]))
+	p.Bits = d[14]
+	return p, nil
+}
+
+// Unpack decodes the values of a data section packed with p. Points masked
+// out by a bitmap section are not included.
+func (p SimplePacking) Unpack(s *Section) ([]float64, error) {
+	if s.Number != SectionData || p.Bits > 64 || uint64(p.Count)*uint64(p.Bits) > uint64(len(s.Data))*8 {
+		return nil, fmt.Errorf("%w: section %d of %d bytes cannot hold %d values of %d bits", ErrInvalidSection, s.Number, len(s.Data), p.Count, p.Bits)
+	}
+	ref := float64(p.Reference)
+	scale := math.Pow(2, float64(p.BinaryScale))
+	div := math.Pow(10, float64(p.DecimalScale))
+	values := make([]float64, p.Count)
+	bits := s.Bits()
+	for i := range values {
+		values[i] = (ref + float64(bits.ReadBits(int(p.Bits)))*scale) / div
+	}
+	return values, bits.Err()
+}
+
+// signMagnitude decodes the signed integers of GRIB, whose top bit is the
+// sign.
+func signMagnitude(v uint16) int16 {
+	if v&0x8000 != 0 {
+		return -int16(v & 0x7FFF)
+	}
+	return int16(v)
+}
@@ -0,0 +1,87 @@
+//go:build test
+
+package grib2
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/oy3o/codec"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMessages(t *testing.T) {
+	m := &Message{Edition: 2, Sections: []Section{
+		{Number: SectionIdentification, Data: make([]byte, 16)},
+		{Number: SectionGrid, Data: []byte{1, 2, 3}},
+		{Number: SectionProduct, Data: []byte{4}},
+		// 4 values, template 5.0, R = 10, E = 1, D = -1 in sign-magnitude, 4 bits.
+		{Number: SectionRepresentation, Data: []byte{0, 0, 0, 4, 0, 0, 0x41, 0x20, 0, 0, 0, 1, 0x80, 1, 4, 0}},
+		{Number: SectionData, Data: []byte{0x01, 0x23}},
+	}}
+	var buf bytes.Buffer
+	w, _ := codec.NewWriter(&buf)
+	require.NoError(t, WriteMessage(w, m))
+	require.NoError(t, WriteMessage(w, m))
+	require.NoError(t, w.Flush())
+	data := buf.Bytes()
+	n := len(data) / 2
+	assert.Equal(t, "GRIB\x00\x00\x00\x02", string(data[:8]))
+	assert.Equal(t, uint64(n), codec.BE.Uint64(data[8:]))
+	assert.Equal(t, END, string(data[n-4:n]))
+
+	r, _ := codec.NewReader(bytes.NewReader(data))
+	var count int
+	for got, err := range Messages(r) {
+		require.NoError(t, err)
+		assert.Equal(t, m, got)
+		count++
+	}
+	assert.Equal(t, 2, count)
+
+	p, err := ParseSimplePacking(m.Section(SectionRepresentation))
+	require.NoError(t, err)
+	assert.Equal(t, SimplePacking{Count: 4, Reference: 10, BinaryScale: 1, DecimalScale: -1, Bits: 4}, p)
+	values, err := p.Unpack(m.Section(SectionData))
+	require.NoError(t, err)
+	assert.InDeltaSlice(t, []float64{100, 120, 140, 160}, values, 1e-9)
+	p.Count = 5
+	_, err = p.Unpack(m.Section(SectionData))
+	assert.ErrorIs(t, err, ErrInvalidSection)
+	_, err = ParseSimplePacking(m.Section(SectionData))
+	assert.ErrorIs(t, err, ErrInvalidSection)
+	assert.Nil(t, m.Section(SectionBitmap))
+}
+
+func TestInvalid(t *testing.T) {
+	var buf bytes.Buffer
+	w, _ := codec.NewWriter(&buf)
+	require.NoError(t, WriteMessage(w, &Message{Sections: []Section{{Number: SectionIdentification, Data: []byte{9}}}}))
+	require.NoError(t, w.Flush())
+	valid := buf.Bytes()
+
+	read := func(data []byte) error {
+		r, _ := codec.NewReader(bytes.NewReader(data))
+		_, err := ReadMessage(r)
+		return err
+	}
+	require.NoError(t, read(valid))
+	assert.Equal(t, io.EOF, read(nil))
+	assert.ErrorIs(t, read(valid[:20]), io.ErrUnexpectedEOF)
+
+	corrupt := func(i int, b byte) []byte {
+		data := bytes.Clone(valid)
+		data[i] = b
+		return data
+	}
+	assert.ErrorIs(t, read(corrupt(0, 'X')), ErrInvalidMessage)
+	assert.ErrorIs(t, read(corrupt(7, 1)), ErrUnsupportedEdition)
+	// Section number 8 and a section longer than the message.
+	assert.ErrorIs(t, read(corrupt(20, 8)), ErrInvalidSection)
+	assert.ErrorIs(t, read(corrupt(19, 0xFF)), ErrInvalidSection)
+	// A message length past the end section.
+	assert.ErrorIs(t, read(corrupt(15, byte(len(valid)+4))), ErrInvalidMessage)
+	assert.ErrorIs(t, read(corrupt(8, 0xFF)), codec.ErrLengthOverflow)
+}
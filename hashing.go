@@ -0,0 +1,187 @@
+package codec
+
+import (
+	"crypto/hmac"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+)
+
+// hashWriter feeds every byte written to the underlying WriterPro to a hash.
+type hashWriter struct {
+	WriterPro
+	h Checksum
+}
+
+func (w *hashWriter) Write(p []byte) (int, error) {
+	n, err := w.WriterPro.Write(p)
+	w.h.Write(p[:n])
+	return n, err
+}
+
+func (w *hashWriter) WriteString(s string) (int, error) {
+	n, err := w.WriterPro.WriteString(s)
+	io.WriteString(w.h, s[:n])
+	return n, err
+}
+
+func (w *hashWriter) WriteByte(b byte) error {
+	err := w.WriterPro.WriteByte(b)
+	if err == nil {
+		w.h.Write([]byte{b})
+	}
+	return err
+}
+
+func (w *hashWriter) ReadFrom(r io.Reader) (int64, error) {
+	return w.WriterPro.ReadFrom(io.TeeReader(r, w.h))
+}
+
+// WithHash feeds every byte written from now on to h, so a payload can be
+// followed by its checksum without wrapping the destination in an
+// io.MultiWriter. Bytes later rewritten by Patch are hashed as originally
+// written. Passing nil stops hashing. It returns the Writer for chaining.
+func (w *Writer) WithHash(h hash.Hash) *Writer {
+	if hw, ok := w.w.(*hashWriter); ok {
+		w.w = hw.WriterPro
+	}
+	w.hash = h
+	if h != nil {
+		w.w = &hashWriter{WriterPro: w.w, h: h}
+	}
+	return w
+}
+
+// Sum appends the checksum of the bytes hashed so far to b, as hash.Hash.Sum
+// does. Without a hash it returns b unchanged.
+func (w *Writer) Sum(b []byte) []byte {
+	if w.hash == nil {
+		return b
+	}
+	return w.hash.Sum(b)
+}
+
+// ResetHash restarts the hash, for instance at the start of the next record.
+func (w *Writer) ResetHash() {
+	if w.hash != nil {
+		w.hash.Reset()
+	}
+}
+
+// hashReader feeds every byte consumed from the underlying ReaderPro to a hash.
+type hashReader struct {
+	ReaderPro
+	h Checksum
+}
+
+func (r *hashReader) Read(p []byte) (int, error) {
+	n, err := r.ReaderPro.Read(p)
+	r.h.Write(p[:n])
+	return n, err
+}
+
+func (r *hashReader) ReadByte() (byte, error) {
+	b, err := r.ReaderPro.ReadByte()
+	if err == nil {
+		r.h.Write([]byte{b})
+	}
+	return b, err
+}
+
+// Peek does not consume, so nothing is hashed.
+func (r *hashReader) Peek(n int) ([]byte, error) {
+	if p, ok := r.ReaderPro.(peeker); ok {
+		return p.Peek(n)
+	}
+	return nil, errors.ErrUnsupported
+}
+
+// UnreadByte leaves the byte hashed; it is hashed again when read again,
+// as after a backward Seek.
+func (r *hashReader) UnreadByte() error {
+	if u, ok := r.ReaderPro.(io.ByteScanner); ok {
+		return u.UnreadByte()
+	}
+	return errors.ErrUnsupported
+}
+
+func (r *hashReader) WriteTo(w io.Writer) (int64, error) {
+	return r.ReaderPro.WriteTo(io.MultiWriter(w, r.h))
+}
+
+// Seek hashes the bytes skipped by a forward seek as if they had been read.
+func (r *hashReader) Seek(offset int64, whence int) (int64, error) {
+	if whence == io.SeekEnd {
+		return r.ReaderPro.Seek(offset, whence)
+	}
+	cur, err := r.ReaderPro.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return cur, err
+	}
+	target := offset
+	if whence == io.SeekCurrent {
+		target += cur
+	}
+	if target < cur {
+		return r.ReaderPro.Seek(offset, whence)
+	}
+	n, err := io.CopyN(r.h, r.ReaderPro, target-cur)
+	return cur + n, err
+}
+
+// WithHash feeds every byte consumed from now on to h, including bytes
+// skipped by Align, Discard or a forward Seek, so a trailing checksum can be
+// verified without reading the payload twice. Bytes read again after a
+// backward Seek are hashed again, but bytes replayed after a Rollback are
+// not. Passing nil stops hashing. It returns the Reader for chaining.
+func (r *Reader) WithHash(h hash.Hash) *Reader {
+	// The hash sits below the transaction layer installed by Begin, which
+	// replays bytes it has already consumed.
+	src := &r.r
+	if r.tx != nil {
+		src = &r.tx.ReaderPro
+	}
+	if hr, ok := (*src).(*hashReader); ok {
+		*src = hr.ReaderPro
+	}
+	r.hash = h
+	if h != nil {
+		*src = &hashReader{ReaderPro: *src, h: h}
+	}
+	return r
+}
+
+// Sum appends the checksum of the bytes hashed so far to b, as hash.Hash.Sum
+// does. Without a hash it returns b unchanged.
+func (r *Reader) Sum(b []byte) []byte {
+	if r.hash == nil {
+		return b
+	}
+	return r.hash.Sum(b)
+}
+
+// ResetHash restarts the hash.
+func (r *Reader) ResetHash() {
+	if r.hash != nil {
+		r.hash.Reset()
+	}
+}
+
+// WithHMAC starts authenticating everything written with an HMAC of h under
+// key; WriteMAC appends the tag. Readers verify it with HMACTrailer.
+func (w *Writer) WithHMAC(h func() hash.Hash, key []byte) *Writer {
+	return w.WithHash(hmac.New(h, key))
+}
+
+// WriteMAC appends the tag of the hash set by WithHMAC, or the sum of any
+// hash set by WithHash, and stops hashing.
+func (w *Writer) WriteMAC() {
+	if w.hash == nil {
+		w.setError(fmt.Errorf("%w: WriteMAC without WithHMAC", ErrInvalidSchema))
+		return
+	}
+	tag := w.Sum(nil)
+	w.WithHash(nil)
+	w.WriteBytes(tag)
+}
@@ -0,0 +1,213 @@
+package hl7
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"iter"
+)
+
+// Delimiters are the separators and escape character of a message, declared
+// by its header segment.
+type Delimiters struct {
+	Field        byte
+	Component    byte
+	Repetition   byte
+	Escape       byte
+	Subcomponent byte
+}
+
+// DefaultDelimiters are the delimiters recommended by the standard,
+// declared as "MSH|^~\&".
+var DefaultDelimiters = Delimiters{Field: '|', Component: '^', Repetition: '~', Escape: '\\', Subcomponent: '&'}
+
+// Segment is a segment of a message.
+type Segment struct {
+	ID string
+	// Fields are the fields after the ID, as split. In header segments the
+	// field separator is field 1, so Fields[0] is field 2, the encoding
+	// characters; use Field to index by field number.
+	Fields [][]byte
+}
+
+// header reports whether id names a segment declaring the delimiters.
+func header(id string) bool { return id == "MSH" || id == "BHS" || id == "FHS" }
+
+// Field returns field n, counting from 1 as the standard does, or nil if the
+// segment has fewer fields. Field 1 of a header segment is the field
+// separator d declares.
+func (s *Segment) Field(d Delimiters, n int) []byte {
+	if header(s.ID) {
+		if n == 1 {
+			return []byte{d.Field}
+		}
+		n--
+	}
+	if n < 1 || n > len(s.Fields) {
+		return nil
+	}
+	return s.Fields[n-1]
+}
+
+// Message is a parsed message.
+type Message struct {
+	Delimiters Delimiters
+	Segments   []Segment
+}
+
+// Segments iterates over the segments of msg, which are ended by carriage
+// returns. Line feeds are accepted too, and empty segments skipped.
+func Segments(msg []byte) iter.Seq[[]byte] {
+	return func(yield func([]byte) bool) {
+		for len(msg) > 0 {
+			i := bytes.IndexAny(msg, "\r\n")
+			if i < 0 {
+				i = len(msg)
+			}
+			seg := msg[:i]
+			msg = msg[min(i+1, len(msg)):]
+			if len(seg) > 0 && !yield(seg) {
+				return
+			}
+		}
+	}
+}
+
+// ParseDelimiters returns the delimiters declared by the header segment
+// opening msg.
+func ParseDelimiters(msg []byte) (Delimiters, error) {
+	if len(msg) < 8 || !header(string(msg[:3])) {
+		return Delimiters{}, fmt.Errorf("%w: no header segment", ErrInvalidMessage)
+	}
+	d := Delimiters{Field: msg[3], Component: msg[4], Repetition: msg[5], Escape: msg[6], Subcomponent: msg[7]}
+	seen := map[byte]bool{}
+	for _, b := range []byte{d.Field, d.Component, d.Repetition, d.Escape, d.Subcomponent} {
+		if seen[b] || b == CARRIAGE_RETURN || b == '\n' {
+			return Delimiters{}, fmt.Errorf("%w: delimiters %q", ErrInvalidMessage, msg[3:8])
+		}
+		seen[b] = true
+	}
+	return d, nil
+}
+
+// ParseMessage splits msg into segments and fields. The fields alias msg.
+func ParseMessage(msg []byte) (*Message, error) {
+	d, err := ParseDelimiters(msg)
+	if err != nil {
+		return nil, err
+	}
+	m := &Message{Delimiters: d}
+	for seg := range Segments(msg) {
+		fields := bytes.Split(seg, []byte{d.Field})
+		m.Segments = append(m.Segments, Segment{ID: string(fields[0]), Fields: fields[1:]})
+	}
+	return m, nil
+}
+
+// Segment returns the first segment named id, or nil if there is none.
+func (m *Message) Segment(id string) *Segment {
+	for i := range m.Segments {
+		if m.Segments[i].ID == id {
+			return &m.Segments[i]
+		}
+	}
+	return nil
+}
+
+// Bytes joins the segments of m back into a message, ending each with a
+// carriage return.
+func (m *Message) Bytes() []byte {
+	var b []byte
+	for _, s := range m.Segments {
+		b = append(b, s.ID...)
+		for _, f := range s.Fields {
+			b = append(append(b, m.Delimiters.Field), f...)
+		}
+		b = append(b, CARRIAGE_RETURN)
+	}
+	return b
+}
+
+// Repetitions splits a field into its repetitions.
+func (d Delimiters) Repetitions(field []byte) [][]byte {
+	return bytes.Split(field, []byte{d.Repetition})
+}
+
+// Components splits a field into its components.
+func (d Delimiters) Components(field []byte) [][]byte {
+	return bytes.Split(field, []byte{d.Component})
+}
+
+// Subcomponents splits a component into its subcomponents.
+func (d Delimiters) Subcomponents(component []byte) [][]byte {
+	return bytes.Split(component, []byte{d.Subcomponent})
+}
+
+// EscapeText replaces the delimiters in text by their escape sequences.
+func (d Delimiters) EscapeText(text []byte) []byte {
+	var b []byte
+	for _, c := range text {
+		var code byte
+		switch c {
+		case d.Field:
+			code = 'F'
+		case d.Component:
+			code = 'S'
+		case d.Subcomponent:
+			code = 'T'
+		case d.Repetition:
+			code = 'R'
+		case d.Escape:
+			code = 'E'
+		default:
+			b = append(b, c)
+			continue
+		}
+		b = append(b, d.Escape, code, d.Escape)
+	}
+	return b
+}
+
+// UnescapeText replaces the escape sequences of the delimiters and of
+// hexadecimal data (\Xhh...\) in text by what they stand for. Other
+// sequences, such as formatting commands, are kept as they are.
+func (d Delimiters) UnescapeText(text []byte) []byte {
+	if bytes.IndexByte(text, d.Escape) < 0 {
+		return text
+	}
+	var b []byte
+	for len(text) > 0 {
+		i := bytes.IndexByte(text, d.Escape)
+		j := -1
+		if i >= 0 {
+			j = bytes.IndexByte(text[i+1:], d.Escape)
+		}
+		if j < 0 {
+			return append(b, text...)
+		}
+		b = append(b, text[:i]...)
+		seq := text[i+1 : i+1+j]
+		text = text[i+j+2:]
+		switch {
+		case len(seq) == 1 && seq[0] == 'F':
+			b = append(b, d.Field)
+		case len(seq) == 1 && seq[0] == 'S':
+			b = append(b, d.Component)
+		case len(seq) == 1 && seq[0] == 'T':
+			b = append(b, d.Subcomponent)
+		case len(seq) == 1 && seq[0] == 'R':
+			b = append(b, d.Repetition)
+		case len(seq) == 1 && seq[0] == 'E':
+			b = append(b, d.Escape)
+		case len(seq) > 1 && seq[0] == 'X' && len(seq)%2 == 1:
+			if v, err := hex.AppendDecode(b, seq[1:]); err == nil {
+				b = v
+				break
+			}
+			fallthrough
+		default:
+			b = append(append(append(b, d.Escape), seq...), d.Escape)
+		}
+	}
+	return b
+}
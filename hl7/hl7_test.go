@@ -0,0 +1,91 @@
+//go:build test
+
+package hl7
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/oy3o/codec"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const adt = "MSH|^~\\&|SENDER|FAC|RCV|FAC|20240101120000||ADT^A01|MSG001|P|2.5\r" +
+	"PID|1||12345^^^HOSP~67890^^^LAB||Doe^John^Q||19700101|M\r" +
+	"OBX|1|ED|PDF^Report||^application^pdf^Base64^JVBERi0=|||N|||F\r"
+
+func TestMLLP(t *testing.T) {
+	var buf bytes.Buffer
+	w, _ := codec.NewWriter(&buf)
+	require.NoError(t, WriteMessage(w, []byte(adt)))
+	require.NoError(t, WriteMessage(w, []byte("MSH|^~\\&|A\r")))
+	require.NoError(t, w.Flush())
+	assert.Equal(t, byte(START_BLOCK), buf.Bytes()[0])
+	assert.Equal(t, "\x1c\x0d", buf.String()[len(adt)+1:len(adt)+3])
+	assert.ErrorIs(t, WriteMessage(w, []byte("a\x1cb")), ErrFraming)
+
+	r, _ := codec.NewReader(bytes.NewReader(buf.Bytes()))
+	var got []string
+	for msg, err := range Messages(r) {
+		require.NoError(t, err)
+		got = append(got, string(msg))
+	}
+	assert.Equal(t, []string{adt, "MSH|^~\\&|A\r"}, got)
+
+	for data, want := range map[string]error{
+		"x\x0bMSH\x1c\x0d": ErrFraming,
+		"\x0bMSH\x1cx":     ErrFraming,
+		"\x0bMSH\x0b":      ErrFraming,
+		"\x0bMSH":          io.ErrUnexpectedEOF,
+	} {
+		r, _ := codec.NewReader(bytes.NewReader([]byte(data)))
+		_, err := ReadMessage(r)
+		assert.ErrorIs(t, err, want, "%q", data)
+	}
+}
+
+func TestParseMessage(t *testing.T) {
+	m, err := ParseMessage([]byte(adt))
+	require.NoError(t, err)
+	d := m.Delimiters
+	assert.Equal(t, DefaultDelimiters, d)
+	require.Len(t, m.Segments, 3)
+	assert.Equal(t, adt, string(m.Bytes()))
+
+	msh := m.Segment("MSH")
+	assert.Equal(t, "|", string(msh.Field(d, 1)))
+	assert.Equal(t, "^~\\&", string(msh.Field(d, 2)))
+	assert.Equal(t, "ADT^A01", string(msh.Field(d, 9)))
+	assert.Equal(t, "2.5", string(msh.Field(d, 12)))
+	assert.Nil(t, msh.Field(d, 13))
+
+	pid := m.Segment("PID")
+	ids := d.Repetitions(pid.Field(d, 3))
+	require.Len(t, ids, 2)
+	assert.Equal(t, "LAB", string(d.Components(ids[1])[3]))
+	assert.Equal(t, "John", string(d.Components(pid.Field(d, 5))[1]))
+	assert.Nil(t, m.Segment("ZZZ"))
+
+	_, err = ParseMessage([]byte("PID|1"))
+	assert.ErrorIs(t, err, ErrInvalidMessage)
+	_, err = ParseMessage([]byte("MSH||~\\&|"))
+	assert.ErrorIs(t, err, ErrInvalidMessage)
+
+	// Line feeds and blank lines between segments are tolerated.
+	m, err = ParseMessage([]byte("MSH|^~\\&|A\r\n\nPID|1\n"))
+	require.NoError(t, err)
+	assert.Len(t, m.Segments, 2)
+}
+
+func TestEscape(t *testing.T) {
+	d := DefaultDelimiters
+	text := []byte("a|b^c&d~e\\f")
+	escaped := d.EscapeText(text)
+	assert.Equal(t, "a\\F\\b\\S\\c\\T\\d\\R\\e\\E\\f", string(escaped))
+	assert.Equal(t, text, d.UnescapeText(escaped))
+	assert.Equal(t, "\x00\xffz", string(d.UnescapeText([]byte("\\X00FF\\z"))))
+	// Formatting commands and unterminated sequences are kept.
+	assert.Equal(t, "line\\.br\\next\\X", string(d.UnescapeText([]byte("line\\.br\\next\\X"))))
+}
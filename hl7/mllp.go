@@ -0,0 +1,109 @@
+// Package hl7 reads and writes HL7 version 2 messages: the MLLP block
+// framing of the minimal lower layer protocol that carries them over TCP,
+// and helpers splitting messages into segments, fields, repetitions,
+// components and subcomponents, with the escape sequences of the
+// delimiters. Interpreting fields is left to the caller.
+package hl7
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+
+	"github.com/oy3o/codec"
+)
+
+const (
+	// START_BLOCK opens an MLLP block.
+	START_BLOCK = 0x0B
+	// END_BLOCK closes an MLLP block, followed by CARRIAGE_RETURN.
+	END_BLOCK = 0x1C
+	// CARRIAGE_RETURN follows END_BLOCK, and separates segments.
+	CARRIAGE_RETURN = 0x0D
+	// MAX_LENGTH bounds the size of a message read by ReadMessage.
+	MAX_LENGTH = 16 << 20
+)
+
+var (
+	// ErrFraming indicates data outside MLLP blocks, or a message holding
+	// the block delimiters.
+	ErrFraming = errors.New("hl7: invalid MLLP framing")
+
+	// ErrInvalidMessage indicates a message that does not start with a
+	// header segment declaring its delimiters.
+	ErrInvalidMessage = errors.New("hl7: invalid message")
+)
+
+// ReadMessage reads an MLLP block and returns the message it holds. A Reader
+// at the end of the stream returns io.EOF. Data between blocks is an error;
+// Reader.WithResync with the START_BLOCK byte can skip it.
+func ReadMessage(r *codec.Reader) ([]byte, error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return nil, r.Err()
+	}
+	if b != START_BLOCK {
+		return nil, fmt.Errorf("%w: 0x%02x before the start block", ErrFraming, b)
+	}
+	var msg []byte
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, unexpected(r.Err())
+		}
+		switch b {
+		case END_BLOCK:
+			if b, err = r.ReadByte(); err != nil {
+				return nil, unexpected(r.Err())
+			}
+			if b != CARRIAGE_RETURN {
+				return nil, fmt.Errorf("%w: 0x%02x after the end block", ErrFraming, b)
+			}
+			return msg, nil
+		case START_BLOCK:
+			return nil, fmt.Errorf("%w: start block inside a block", ErrFraming)
+		}
+		if len(msg) == MAX_LENGTH {
+			return nil, fmt.Errorf("%w: message over %d bytes", codec.ErrLengthOverflow, MAX_LENGTH)
+		}
+		msg = append(msg, b)
+	}
+}
+
+// WriteMessage writes msg in an MLLP block.
+func WriteMessage(w *codec.Writer, msg []byte) error {
+	for _, b := range msg {
+		if b == START_BLOCK || b == END_BLOCK {
+			return fmt.Errorf("%w: message holds block delimiter 0x%02x", ErrFraming, b)
+		}
+	}
+	w.WriteUint8(START_BLOCK)
+	w.WriteBytes(msg)
+	w.WriteUint8(END_BLOCK)
+	w.WriteUint8(CARRIAGE_RETURN)
+	return w.Err()
+}
+
+// Messages iterates over the MLLP blocks of r up to the end of the stream,
+// stopping after the first error.
+func Messages(r *codec.Reader) iter.Seq2[[]byte, error] {
+	return func(yield func([]byte, error) bool) {
+		for {
+			msg, err := ReadMessage(r)
+			if err == io.EOF {
+				return
+			}
+			if !yield(msg, err) || err != nil {
+				return
+			}
+		}
+	}
+}
+
+func unexpected(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,23 @@
+package codec
+
+import (
+	"context"
+	"time"
+)
+
+// Hook observes encode and decode operations for tracing and metrics. It is
+// called when an operation starts, with the operation and the name of what is
+// being processed, and returns a function that is called exactly once when the
+// operation ends, with the number of bytes processed and the error, if any.
+type Hook func(ctx context.Context, op ProfileOp, name string) (done func(n int64, err error))
+
+// Hook returns a Hook recording into p, so a Profiler can observe anything
+// that accepts hooks, such as a Framer.
+func (p *Profiler) Hook() Hook {
+	return func(ctx context.Context, op ProfileOp, name string) func(int64, error) {
+		start := time.Now()
+		return func(n int64, err error) {
+			p.Record(name, op, n, time.Since(start))
+		}
+	}
+}
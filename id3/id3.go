@@ -0,0 +1,259 @@
+// Package id3 reads and writes ID3v2.3 and ID3v2.4 tags, the metadata at
+// the start of MP3 files: the tag header, extended header, frames and
+// padding, undoing unsynchronisation on read. Frame bodies are kept raw;
+// DecodeText and AppendText handle the text frames (T000 to TZZZ) that make
+// up most tags.
+package id3
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/oy3o/codec"
+)
+
+const (
+	// IDENTIFIER opens every tag.
+	IDENTIFIER = "ID3"
+	// HEADER_SIZE is the size of the tag header, and of a frame header.
+	HEADER_SIZE = 10
+)
+
+// Tag header flags.
+const (
+	FlagUnsynchronisation = 0x80
+	FlagExtendedHeader    = 0x40
+	FlagExperimental      = 0x20
+	FlagFooter            = 0x10 // ID3v2.4 only
+)
+
+// ID3v2.4 frame flags this package interprets; the others are kept as is.
+const (
+	frameUnsynchronised = 0x0002
+)
+
+var (
+	// ErrInvalidTag indicates a malformed tag header or frame.
+	ErrInvalidTag = errors.New("id3: invalid tag")
+
+	// ErrUnsupportedVersion indicates an ID3v2.2 or unknown tag version.
+	ErrUnsupportedVersion = errors.New("id3: unsupported version")
+)
+
+// Frame is an ID3v2 frame. Data is the frame body after unsynchronisation is
+// undone; compressed or encrypted bodies are left as stored.
+type Frame struct {
+	ID    string // four characters A-Z and 0-9
+	Flags uint16
+	Data  []byte
+}
+
+// Tag is an ID3v2 tag.
+type Tag struct {
+	Version  uint8 // 3 or 4
+	Revision uint8
+	Flags    uint8
+	Frames   []Frame
+}
+
+// Frame returns the first frame with the given ID, or nil.
+func (t *Tag) Frame(id string) *Frame {
+	for i := range t.Frames {
+		if t.Frames[i].ID == id {
+			return &t.Frames[i]
+		}
+	}
+	return nil
+}
+
+// Text returns the first string of the text frame id, or "" if there is none.
+func (t *Tag) Text(id string) string {
+	f := t.Frame(id)
+	if f == nil {
+		return ""
+	}
+	values, err := DecodeText(f.Data)
+	if err != nil || len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+// SetText replaces the text frame id with values, or removes it if values
+// is empty. Text is UTF-8 in ID3v2.4 tags and UTF-16 in ID3v2.3 tags, which
+// cannot hold UTF-8; ID3v2.3 readers expect a single value.
+func (t *Tag) SetText(id string, values ...string) error {
+	frames := t.Frames[:0]
+	for _, f := range t.Frames {
+		if f.ID != id {
+			frames = append(frames, f)
+		}
+	}
+	t.Frames = frames
+	if len(values) == 0 {
+		return nil
+	}
+	enc := byte(EncodingUTF8)
+	if t.Version == 3 {
+		enc = EncodingUTF16
+	}
+	data, err := AppendText(nil, enc, values...)
+	if err != nil {
+		return err
+	}
+	t.Frames = append(t.Frames, Frame{ID: id, Data: data})
+	return nil
+}
+
+func unexpected(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// resync undoes unsynchronisation, removing the zero byte inserted after
+// every 0xFF.
+func resync(b []byte) []byte {
+	out := b[:0]
+	for i := 0; i < len(b); i++ {
+		out = append(out, b[i])
+		if b[i] == 0xFF && i+1 < len(b) && b[i+1] == 0 {
+			i++
+		}
+	}
+	return out
+}
+
+func validID(id []byte) bool {
+	for _, c := range id {
+		if (c < 'A' || c > 'Z') && (c < '0' || c > '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// ReadTag reads a tag, including its padding and footer, so r is left at
+// the audio that follows.
+func ReadTag(r *codec.Reader) (*Tag, error) {
+	var hdr [6]byte
+	var size uint32
+	r.ReadBytesTo(hdr[:])
+	r.ReadSynchsafe32(&size)
+	if err := r.Err(); err != nil {
+		return nil, unexpected(err)
+	}
+	if string(hdr[:3]) != IDENTIFIER {
+		return nil, fmt.Errorf("%w: identifier %q", ErrInvalidTag, hdr[:3])
+	}
+	t := &Tag{Version: hdr[3], Revision: hdr[4], Flags: hdr[5]}
+	if t.Version != 3 && t.Version != 4 {
+		return nil, fmt.Errorf("%w: ID3v2.%d", ErrUnsupportedVersion, t.Version)
+	}
+	// ReadBytes honours the Reader's allocation limit, as the 28-bit size
+	// comes straight from the input.
+	body := r.ReadBytes(int(size))
+	if t.Flags&FlagFooter != 0 && t.Version == 4 {
+		codec.Discard(r, HEADER_SIZE)
+	}
+	if err := r.Err(); err != nil {
+		return nil, unexpected(err)
+	}
+	if t.Version == 3 && t.Flags&FlagUnsynchronisation != 0 {
+		body = resync(body)
+	}
+	if t.Flags&FlagExtendedHeader != 0 {
+		if len(body) < 4 {
+			return nil, fmt.Errorf("%w: truncated extended header", ErrInvalidTag)
+		}
+		var n uint32
+		if t.Version == 4 {
+			// The size of an ID3v2.4 extended header includes itself.
+			v, err := codec.Synchsafe32(body)
+			if err != nil {
+				return nil, err
+			}
+			n = v
+		} else {
+			n = 4 + (uint32(body[0])<<24 | uint32(body[1])<<16 | uint32(body[2])<<8 | uint32(body[3]))
+		}
+		if n < 4 || n > uint32(len(body)) {
+			return nil, fmt.Errorf("%w: extended header of %d bytes", ErrInvalidTag, n)
+		}
+		body = body[n:]
+	}
+	for len(body) >= HEADER_SIZE && body[0] != 0 {
+		f := Frame{ID: string(body[:4]), Flags: uint16(body[8])<<8 | uint16(body[9])}
+		if !validID(body[:4]) {
+			return nil, fmt.Errorf("%w: frame ID %q", ErrInvalidTag, body[:4])
+		}
+		var n uint32
+		if t.Version == 4 {
+			v, err := codec.Synchsafe32(body[4:8])
+			if err != nil {
+				return nil, fmt.Errorf("%w: frame %s: %w", ErrInvalidTag, f.ID, err)
+			}
+			n = v
+		} else {
+			n = uint32(body[4])<<24 | uint32(body[5])<<16 | uint32(body[6])<<8 | uint32(body[7])
+		}
+		body = body[HEADER_SIZE:]
+		if n > uint32(len(body)) {
+			return nil, fmt.Errorf("%w: frame %s of %d bytes, %d left", ErrInvalidTag, f.ID, n, len(body))
+		}
+		f.Data = bytes.Clone(body[:n])
+		if t.Version == 4 && (f.Flags&frameUnsynchronised != 0 || t.Flags&FlagUnsynchronisation != 0) {
+			f.Data = resync(f.Data)
+			f.Flags &^= frameUnsynchronised
+		}
+		t.Frames = append(t.Frames, f)
+		body = body[n:]
+	}
+	return t, nil
+}
+
+// WriteTag writes t followed by padding zero bytes. Frames are written
+// without unsynchronisation, extended header or footer, whatever t.Flags
+// says; a Version of 0 writes ID3v2.4.
+func WriteTag(w *codec.Writer, t *Tag, padding int) error {
+	version := t.Version
+	if version == 0 {
+		version = 4
+	}
+	if version != 3 && version != 4 {
+		return fmt.Errorf("%w: ID3v2.%d", ErrUnsupportedVersion, version)
+	}
+	size := padding
+	for _, f := range t.Frames {
+		if len(f.ID) != 4 || !validID([]byte(f.ID)) {
+			return fmt.Errorf("%w: frame ID %q", ErrInvalidTag, f.ID)
+		}
+		if len(f.Data) > codec.MAX_SYNCHSAFE32 {
+			return fmt.Errorf("%w: frame %s of %d bytes", codec.ErrLengthOverflow, f.ID, len(f.Data))
+		}
+		size += HEADER_SIZE + len(f.Data)
+	}
+	if size > codec.MAX_SYNCHSAFE32 {
+		return fmt.Errorf("%w: tag of %d bytes", codec.ErrLengthOverflow, size)
+	}
+	w.WriteString(IDENTIFIER)
+	w.WriteBytes([]byte{version, t.Revision, t.Flags &^ (FlagUnsynchronisation | FlagExtendedHeader | FlagFooter)})
+	w.WriteSynchsafe32(uint32(size))
+	for _, f := range t.Frames {
+		w.WriteString(f.ID)
+		if version == 4 {
+			w.WriteSynchsafe32(uint32(len(f.Data)))
+		} else {
+			n := len(f.Data)
+			w.WriteBytes([]byte{byte(n >> 24), byte(n >> 16), byte(n >> 8), byte(n)})
+		}
+		flags := f.Flags &^ frameUnsynchronised
+		w.WriteBytes([]byte{byte(flags >> 8), byte(flags)})
+		w.WriteBytes(f.Data)
+	}
+	w.WriteZeros(int64(padding))
+	return w.Err()
+}
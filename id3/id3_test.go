@@ -0,0 +1,93 @@
+//go:build test
+
+package id3
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/oy3o/codec"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTag(t *testing.T) {
+	tag := &Tag{Version: 4}
+	require.NoError(t, tag.SetText("TIT2", "Title"))
+	require.NoError(t, tag.SetText("TPE1", "A", "B"))
+	tag.Frames = append(tag.Frames, Frame{ID: "PRIV", Data: make([]byte, 200)})
+
+	var buf bytes.Buffer
+	w, _ := codec.NewWriter(&buf)
+	require.NoError(t, WriteTag(w, tag, 16))
+	require.NoError(t, w.Flush())
+	buf.WriteString("audio")
+
+	data := buf.Bytes()
+	assert.Equal(t, []byte("ID3\x04\x00\x00\x00\x00\x02\x00"), data[:10]) // 10+6 + 10+4 + 10+200 + 16
+	assert.Equal(t, []byte("TIT2\x00\x00\x00\x06\x00\x00\x03Title"), data[10:26])
+	assert.Equal(t, []byte{0, 0, 0x01, 0x48}, data[44:48]) // synchsafe 200
+
+	r, _ := codec.NewReader(&buf)
+	got, err := ReadTag(r)
+	require.NoError(t, err)
+	assert.Equal(t, tag, got)
+	assert.Equal(t, "Title", got.Text("TIT2"))
+	values, err := DecodeText(got.Frame("TPE1").Data)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"A", "B"}, values)
+	assert.Equal(t, "audio", string(r.ReadBytes(5)))
+}
+
+func TestTagV3(t *testing.T) {
+	// An unsynchronised ID3v2.3 tag with an extended header and a UTF-16 title.
+	title, err := AppendText(nil, EncodingUTF16, "ÿé")
+	require.NoError(t, err)
+	assert.Equal(t, []byte{1, 0xFF, 0xFE, 0xFF, 0x00, 0xE9, 0x00}, title)
+
+	var body []byte
+	body = append(body, 0, 0, 0, 6, 0, 0, 0, 0, 0, 0) // extended header
+	body = append(body, "TIT2"...)
+	body = append(body, 0, 0, 0, byte(len(title)), 0, 0)
+	for _, b := range title {
+		body = append(body, b)
+		if b == 0xFF {
+			body = append(body, 0)
+		}
+	}
+	data := []byte{'I', 'D', '3', 3, 0, FlagUnsynchronisation | FlagExtendedHeader, 0, 0, 0, byte(len(body))}
+	r, _ := codec.NewReader(bytes.NewReader(append(data, body...)))
+	tag, err := ReadTag(r)
+	require.NoError(t, err)
+	assert.Equal(t, "ÿé", tag.Text("TIT2"))
+
+	require.NoError(t, tag.SetText("TALB", "Album"))
+	var buf bytes.Buffer
+	w, _ := codec.NewWriter(&buf)
+	require.NoError(t, WriteTag(w, tag, 0))
+	require.NoError(t, w.Flush())
+	r, _ = codec.NewReader(&buf)
+	got, err := ReadTag(r)
+	require.NoError(t, err)
+	assert.Equal(t, "Album", got.Text("TALB"))
+	assert.Equal(t, byte(EncodingUTF16), got.Frame("TALB").Data[0])
+}
+
+func TestTagErrors(t *testing.T) {
+	r, _ := codec.NewReader(bytes.NewReader([]byte("ID3\x02\x00\x00\x00\x00\x00\x00")))
+	_, err := ReadTag(r)
+	assert.ErrorIs(t, err, ErrUnsupportedVersion)
+
+	r, _ = codec.NewReader(bytes.NewReader([]byte("ID3\x04\x00\x00\x00\x00\x00\x0atit2\x00\x00\x00\x00\x00\x00")))
+	_, err = ReadTag(r)
+	assert.ErrorIs(t, err, ErrInvalidTag)
+
+	// A huge declared size is refused before allocating.
+	r, _ = codec.NewReaderOpts(bytes.NewReader([]byte("ID3\x04\x00\x00\x7f\x7f\x7f\x7f")), codec.WithMaxAlloc(1<<10))
+	_, err = ReadTag(r)
+	assert.ErrorIs(t, err, codec.ErrLengthOverflow)
+
+	_, err = DecodeText([]byte{9, 'x'})
+	assert.ErrorIs(t, err, codec.ErrUnknownCharset)
+	assert.ErrorIs(t, WriteTag(nil, &Tag{Frames: []Frame{{ID: "T"}}}, 0), ErrInvalidTag)
+}
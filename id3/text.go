@@ -0,0 +1,84 @@
+package id3
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/oy3o/codec"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/unicode"
+)
+
+// Text encodings, the first byte of text frames.
+const (
+	EncodingISO88591 = 0
+	EncodingUTF16    = 1 // with a byte order mark
+	EncodingUTF16BE  = 2 // ID3v2.4 only
+	EncodingUTF8     = 3 // ID3v2.4 only
+)
+
+func textEncoding(enc byte) (encoding.Encoding, int, error) {
+	switch enc {
+	case EncodingISO88591:
+		return charmap.ISO8859_1, 1, nil
+	case EncodingUTF16:
+		return unicode.UTF16(unicode.LittleEndian, unicode.UseBOM), 2, nil
+	case EncodingUTF16BE:
+		return unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM), 2, nil
+	case EncodingUTF8:
+		return unicode.UTF8, 1, nil
+	}
+	return nil, 0, fmt.Errorf("%w: text encoding %d", codec.ErrUnknownCharset, enc)
+}
+
+// DecodeText decodes the body of a text frame: an encoding byte, then
+// strings separated by null terminators. A trailing terminator is optional.
+func DecodeText(data []byte) ([]string, error) {
+	if len(data) == 0 {
+		return nil, fmt.Errorf("%w: empty text frame", ErrInvalidTag)
+	}
+	enc, width, err := textEncoding(data[0])
+	if err != nil {
+		return nil, err
+	}
+	data = data[1:]
+	null := make([]byte, width)
+	var values []string
+	for len(data) > 0 {
+		end := len(data)
+		for i := 0; i+width <= len(data); i += width {
+			if bytes.Equal(data[i:i+width], null) {
+				end = i
+				break
+			}
+		}
+		s, err := enc.NewDecoder().Bytes(data[:end])
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", codec.ErrInvalidText, err)
+		}
+		values = append(values, string(s))
+		data = data[min(end+width, len(data)):]
+	}
+	return values, nil
+}
+
+// AppendText appends the body of a text frame holding values in enc to dst.
+func AppendText(dst []byte, enc byte, values ...string) ([]byte, error) {
+	e, width, err := textEncoding(enc)
+	if err != nil {
+		return dst, err
+	}
+	dst = append(dst, enc)
+	for i, v := range values {
+		if i > 0 {
+			dst = append(dst, make([]byte, width)...)
+		}
+		b, err := e.NewEncoder().Bytes([]byte(v))
+		if err != nil {
+			return dst, fmt.Errorf("%w: %w", codec.ErrInvalidText, err)
+		}
+		dst = append(dst, b...)
+	}
+	return dst, nil
+}
@@ -0,0 +1,162 @@
+package codec
+
+import (
+	"io"
+	"sync"
+)
+
+// InternStats reports the effectiveness of an Interner.
+type InternStats struct {
+	Hits    int64 // lookups answered with an existing value
+	Misses  int64 // lookups that allocated a new value
+	Entries int   // distinct values held
+	Bytes   int   // total bytes held
+}
+
+// Interner deduplicates strings and byte slices produced while decoding, so
+// a stream of records sharing a small vocabulary allocates each value once.
+// It is safe for concurrent use.
+//
+// Once the held values reach the size cap, new values are still returned
+// but no longer retained.
+type Interner struct {
+	mu      sync.Mutex
+	strs    map[string]string
+	bufs    map[string][]byte
+	maxSize int
+	stats   InternStats
+}
+
+// NewInterner creates an Interner retaining at most maxSize bytes of values.
+// A maxSize of 0 or less means no limit.
+func NewInterner(maxSize int) *Interner {
+	return &Interner{
+		strs:    make(map[string]string),
+		bufs:    make(map[string][]byte),
+		maxSize: maxSize,
+	}
+}
+
+// String returns a string equal to b, shared with earlier calls where possible.
+func (in *Interner) String(b []byte) string {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	// The compiler does not allocate for a map lookup keyed by string(b).
+	if s, ok := in.strs[string(b)]; ok {
+		in.stats.Hits++
+		return s
+	}
+	in.stats.Misses++
+	s := string(b)
+	if in.retain(len(s)) {
+		in.strs[s] = s
+	}
+	return s
+}
+
+// Bytes returns a byte slice equal to b, shared with earlier calls where
+// possible. The result must be treated as read-only.
+func (in *Interner) Bytes(b []byte) []byte {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	if v, ok := in.bufs[string(b)]; ok {
+		in.stats.Hits++
+		return v
+	}
+	in.stats.Misses++
+	v := append([]byte(nil), b...)
+	if in.retain(len(v)) {
+		in.bufs[string(v)] = v
+	}
+	return v
+}
+
+// retain accounts for a new entry of n bytes if the size cap allows it.
+func (in *Interner) retain(n int) bool {
+	if in.maxSize > 0 && in.stats.Bytes+n > in.maxSize {
+		return false
+	}
+	in.stats.Entries++
+	in.stats.Bytes += n
+	return true
+}
+
+// Stats returns a snapshot of the interner statistics.
+func (in *Interner) Stats() InternStats {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	return in.stats
+}
+
+// Reset drops all held values and clears the statistics.
+func (in *Interner) Reset() {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	clear(in.strs)
+	clear(in.bufs)
+	in.stats = InternStats{}
+}
+
+// WithInterner makes ReadString and decoders driven by r, such as Dynamic,
+// deduplicate the strings and byte slices they produce through in.
+// It returns the configured Reader for chaining.
+func (r *Reader) WithInterner(in *Interner) *Reader {
+	r.interner = in
+	return r
+}
+
+// ReadString reads n bytes as a string, interning it if an Interner is set.
+func (r *Reader) ReadString(dest *string, n int) {
+	r.readString(dest, n)
+	if r.trace != nil && r.err == nil && n > 0 {
+		r.traced("string", n, *dest)
+	}
+}
+
+func (r *Reader) readString(dest *string, n int) {
+	if r.err != nil {
+		return
+	}
+	if n <= 0 {
+		*dest = ""
+		return
+	}
+	if !r.alloc(n) {
+		return
+	}
+	if r.charset != nil {
+		b := r.readFull(n)
+		if r.err != nil {
+			return
+		}
+		b, err := decodeText(r.charset, b)
+		if err != nil {
+			r.setError(err)
+			return
+		}
+		if r.interner != nil {
+			*dest = r.interner.String(b)
+		} else {
+			*dest = string(b)
+		}
+		return
+	}
+	if r.interner == nil {
+		if b := r.readFull(n); r.err == nil {
+			*dest = string(b)
+		}
+		return
+	}
+
+	bufPtr := bufPool.Get().(*[]byte)
+	defer bufPool.Put(bufPtr)
+	buf := *bufPtr
+	if len(buf) < n {
+		buf = make([]byte, n)
+	}
+	if _, err := io.ReadFull(r, buf[:n]); err != nil {
+		r.err = err
+		return
+	}
+	*dest = r.interner.String(buf[:n])
+}
@@ -0,0 +1,270 @@
+//go:build !tinygo && !codec_tiny
+
+// Package itch decodes and encodes the messages of NASDAQ TotalView-ITCH
+// 5.0, the fixed-layout market data feed. Each message is a type byte
+// followed by a body whose layout codec.Fixed handles in big-endian order,
+// so decoding a message into a caller's struct does not allocate.
+//
+// Messages arrive framed by MoldUDP64 over multicast or by SoupBinTCP, see
+// packages moldudp64 and soupbintcp. Message types not listed here are left
+// to the caller, who can decode them the same way with Unmarshal.
+package itch
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/oy3o/codec"
+)
+
+var (
+	// ErrInvalidMessage indicates a message whose length does not match its
+	// type, or decoded into a struct of another type.
+	ErrInvalidMessage = errors.New("itch: invalid message")
+
+	// ErrUnknownMessage indicates a message type Decode does not know.
+	ErrUnknownMessage = errors.New("itch: unknown message type")
+)
+
+// Timestamp is the number of nanoseconds since midnight, in 6 big-endian
+// bytes.
+type Timestamp [6]byte
+
+// NewTimestamp returns the Timestamp of d since midnight.
+func NewTimestamp(d time.Duration) Timestamp {
+	var t Timestamp
+	for i := range t {
+		t[i] = byte(uint64(d) >> (8 * (5 - i)))
+	}
+	return t
+}
+
+// Duration returns the time since midnight.
+func (t Timestamp) Duration() time.Duration {
+	var v uint64
+	for _, b := range t {
+		v = v<<8 | uint64(b)
+	}
+	return time.Duration(v)
+}
+
+// Price is a price with 4 implied decimal places.
+type Price uint32
+
+// Float64 returns the price in currency units.
+func (p Price) Float64() float64 { return float64(p) / 1e4 }
+
+func (p Price) String() string { return fmt.Sprintf("%d.%04d", p/1e4, p%1e4) }
+
+// Alpha returns the text of a left-justified, space-padded alpha field.
+func Alpha(field []byte) string { return strings.TrimRight(string(field), " ") }
+
+// SetAlpha fills an alpha field with s, left-justified and padded with
+// spaces. Text longer than the field is cut.
+func SetAlpha(field []byte, s string) {
+	n := copy(field, s)
+	for i := n; i < len(field); i++ {
+		field[i] = ' '
+	}
+}
+
+// Header opens the body of every message.
+type Header struct {
+	StockLocate    uint16
+	TrackingNumber uint16
+	Timestamp      Timestamp
+}
+
+// Message is implemented by the message types, which return their type
+// byte.
+type Message interface {
+	Type() byte
+}
+
+// SystemEvent ('S') signals a market or data feed handler event.
+type SystemEvent struct {
+	Header
+	EventCode byte // 'O', 'S', 'Q', 'M', 'E' or 'C'
+}
+
+// StockDirectory ('R') describes a security at the start of the day.
+type StockDirectory struct {
+	Header
+	Stock                       [8]byte
+	MarketCategory              byte
+	FinancialStatusIndicator    byte
+	RoundLotSize                uint32
+	RoundLotsOnly               byte
+	IssueClassification         byte
+	IssueSubType                [2]byte
+	Authenticity                byte
+	ShortSaleThresholdIndicator byte
+	IPOFlag                     byte
+	LULDReferencePriceTier      byte
+	ETPFlag                     byte
+	ETPLeverageFactor           uint32
+	InverseIndicator            byte
+}
+
+// StockTradingAction ('H') gives the trading state of a security.
+type StockTradingAction struct {
+	Header
+	Stock        [8]byte
+	TradingState byte
+	Reserved     byte
+	Reason       [4]byte
+}
+
+// AddOrder ('A') adds an order to the book.
+type AddOrder struct {
+	Header
+	OrderReference uint64
+	Side           byte // 'B' or 'S'
+	Shares         uint32
+	Stock          [8]byte
+	Price          Price
+}
+
+// AddOrderMPID ('F') adds an order attributed to a market participant.
+type AddOrderMPID struct {
+	Header
+	OrderReference uint64
+	Side           byte
+	Shares         uint32
+	Stock          [8]byte
+	Price          Price
+	Attribution    [4]byte
+}
+
+// OrderExecuted ('E') executes shares of an order at its price.
+type OrderExecuted struct {
+	Header
+	OrderReference uint64
+	ExecutedShares uint32
+	MatchNumber    uint64
+}
+
+// OrderExecutedWithPrice ('C') executes shares of an order at another
+// price.
+type OrderExecutedWithPrice struct {
+	Header
+	OrderReference uint64
+	ExecutedShares uint32
+	MatchNumber    uint64
+	Printable      byte
+	ExecutionPrice Price
+}
+
+// OrderCancel ('X') cancels part of an order.
+type OrderCancel struct {
+	Header
+	OrderReference uint64
+	CanceledShares uint32
+}
+
+// OrderDelete ('D') removes an order from the book.
+type OrderDelete struct {
+	Header
+	OrderReference uint64
+}
+
+// OrderReplace ('U') replaces an order by a new one.
+type OrderReplace struct {
+	Header
+	OriginalOrderReference uint64
+	NewOrderReference      uint64
+	Shares                 uint32
+	Price                  Price
+}
+
+// Trade ('P') reports a match of a non-displayed order.
+type Trade struct {
+	Header
+	OrderReference uint64
+	Side           byte
+	Shares         uint32
+	Stock          [8]byte
+	Price          Price
+	MatchNumber    uint64
+}
+
+func (SystemEvent) Type() byte            { return 'S' }
+func (StockDirectory) Type() byte         { return 'R' }
+func (StockTradingAction) Type() byte     { return 'H' }
+func (AddOrder) Type() byte               { return 'A' }
+func (AddOrderMPID) Type() byte           { return 'F' }
+func (OrderExecuted) Type() byte          { return 'E' }
+func (OrderExecutedWithPrice) Type() byte { return 'C' }
+func (OrderCancel) Type() byte            { return 'X' }
+func (OrderDelete) Type() byte            { return 'D' }
+func (OrderReplace) Type() byte           { return 'U' }
+func (Trade) Type() byte                  { return 'P' }
+
+// decoders holds Decode's constructor for each known message type.
+var decoders = map[byte]func([]byte) (Message, error){
+	'S': decode[SystemEvent],
+	'R': decode[StockDirectory],
+	'H': decode[StockTradingAction],
+	'A': decode[AddOrder],
+	'F': decode[AddOrderMPID],
+	'E': decode[OrderExecuted],
+	'C': decode[OrderExecutedWithPrice],
+	'X': decode[OrderCancel],
+	'D': decode[OrderDelete],
+	'U': decode[OrderReplace],
+	'P': decode[Trade],
+}
+
+func decode[T Message](b []byte) (Message, error) {
+	m := new(T)
+	if err := Unmarshal(b, m); err != nil {
+		return nil, err
+	}
+	return *m, nil
+}
+
+// Decode decodes a message of a known type, returned by value, such as
+// AddOrder.
+func Decode(b []byte) (Message, error) {
+	if len(b) == 0 {
+		return nil, fmt.Errorf("%w: empty message", ErrInvalidMessage)
+	}
+	fn, ok := decoders[b[0]]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownMessage, b[0])
+	}
+	return fn(b)
+}
+
+// Size returns the size of messages of type T, type byte included.
+func Size[T Message]() int {
+	f := codec.Fixed[T]{}
+	return 1 + f.Size()
+}
+
+// Unmarshal decodes b, a message of type T, into m without allocating.
+func Unmarshal[T Message](b []byte, m *T) error {
+	f := codec.Fixed[T]{}
+	f.WithByteOrder(codec.BE)
+	if len(b) == 0 || b[0] != (*m).Type() {
+		return fmt.Errorf("%w: not a %T", ErrInvalidMessage, *m)
+	}
+	if len(b) != 1+f.Size() {
+		return fmt.Errorf("%w: %T of %d bytes", ErrInvalidMessage, *m, len(b))
+	}
+	if err := f.UnmarshalBinary(b[1:]); err != nil {
+		return err
+	}
+	*m = f.Payload
+	return nil
+}
+
+// Append appends the encoding of m, type byte included, to dst. T must be
+// a concrete message type.
+func Append[T Message](dst []byte, m T) ([]byte, error) {
+	f := codec.Fixed[T]{Payload: m}
+	f.WithByteOrder(codec.BE)
+	return f.MarshalAppend(append(dst, m.Type()))
+}
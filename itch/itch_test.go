@@ -0,0 +1,71 @@
+//go:build test && !tinygo && !codec_tiny
+
+package itch
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSizes(t *testing.T) {
+	// Message lengths from the ITCH 5.0 specification.
+	assert.Equal(t, 12, Size[SystemEvent]())
+	assert.Equal(t, 39, Size[StockDirectory]())
+	assert.Equal(t, 25, Size[StockTradingAction]())
+	assert.Equal(t, 36, Size[AddOrder]())
+	assert.Equal(t, 40, Size[AddOrderMPID]())
+	assert.Equal(t, 31, Size[OrderExecuted]())
+	assert.Equal(t, 36, Size[OrderExecutedWithPrice]())
+	assert.Equal(t, 23, Size[OrderCancel]())
+	assert.Equal(t, 19, Size[OrderDelete]())
+	assert.Equal(t, 35, Size[OrderReplace]())
+	assert.Equal(t, 44, Size[Trade]())
+}
+
+func TestAddOrder(t *testing.T) {
+	m := AddOrder{
+		Header:         Header{StockLocate: 1, TrackingNumber: 2, Timestamp: NewTimestamp(9*time.Hour + 30*time.Minute)},
+		OrderReference: 0x0102030405060708,
+		Side:           'B',
+		Shares:         100,
+		Price:          1234500,
+	}
+	SetAlpha(m.Stock[:], "AAPL")
+	b, err := Append(nil, m)
+	require.NoError(t, err)
+	require.Len(t, b, 36)
+	assert.Equal(t, byte('A'), b[0])
+	assert.Equal(t, []byte{0, 1, 0, 2}, b[1:5])
+	assert.Equal(t, []byte{1, 2, 3, 4, 5, 6, 7, 8}, b[11:19])
+	assert.Equal(t, "AAPL    ", string(b[24:32]))
+
+	got, err := Decode(b)
+	require.NoError(t, err)
+	assert.Equal(t, m, got)
+	a := got.(AddOrder)
+	assert.Equal(t, "AAPL", Alpha(a.Stock[:]))
+	assert.Equal(t, 9*time.Hour+30*time.Minute, a.Timestamp.Duration())
+	assert.Equal(t, "123.4500", a.Price.String())
+
+	var into AddOrder
+	allocs := testing.AllocsPerRun(100, func() {
+		if err := Unmarshal(b, &into); err != nil {
+			t.Fatal(err)
+		}
+	})
+	assert.Zero(t, allocs)
+	assert.Equal(t, m, into)
+}
+
+func TestDecodeErrors(t *testing.T) {
+	b, _ := Append(nil, OrderDelete{OrderReference: 7})
+	_, err := Decode(b[:len(b)-1])
+	assert.ErrorIs(t, err, ErrInvalidMessage)
+	_, err = Decode([]byte{'?'})
+	assert.ErrorIs(t, err, ErrUnknownMessage)
+	var m AddOrder
+	assert.ErrorIs(t, Unmarshal(b, &m), ErrInvalidMessage)
+}
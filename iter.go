@@ -0,0 +1,97 @@
+package codec
+
+import (
+	"fmt"
+	"io"
+	"iter"
+)
+
+// ListItems returns an iterator decoding a stream of T written by a List with
+// the given alignment, yielding each item as soon as it is decoded. Iteration
+// ends at a clean io.EOF between items; any other error is yielded once with
+// a zero item and ends the iteration.
+//
+//	for msg, err := range codec.ListItems[*Msg](r, 4) {
+//		if err != nil {
+//			return err
+//		}
+//		handle(msg)
+//	}
+func ListItems[T Codec](r io.Reader, alignment int) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		var zero T
+		for {
+			item := newCodec[T]()
+			n, err := item.ReadFrom(r)
+			if err != nil {
+				if err == io.EOF && n == 0 {
+					return
+				}
+				yield(zero, err)
+				return
+			}
+			if !yield(item, nil) {
+				return
+			}
+			if alignment > 1 {
+				if padding := Roundup(n, int64(alignment)) - n; padding > 0 {
+					// The last item is not padded, so EOF here ends the stream.
+					if skipped, err := Discard(r, padding); err != nil {
+						if err == io.EOF && skipped == 0 {
+							return
+						}
+						yield(zero, err)
+						return
+					}
+				}
+			}
+		}
+	}
+}
+
+// Records returns an iterator over the fixed-layout records of r, in the same
+// streaming fashion as CopyRecords. A yielded Record is only valid until the
+// next iteration. A trailing partial record is yielded as ErrTruncatedData.
+func Records(r io.Reader, layout RecordLayout) iter.Seq2[Record, error] {
+	return func(yield func(Record, error) bool) {
+		size := layout.Size()
+		if size <= 0 {
+			yield(Record{}, fmt.Errorf("%w: empty record layout", ErrInvalidTag))
+			return
+		}
+
+		bufPtr := bufPool.Get().(*[]byte)
+		defer bufPool.Put(bufPtr)
+		buf := *bufPtr
+		if len(buf) < size {
+			buf = make([]byte, size)
+		}
+		buf = buf[:len(buf)/size*size]
+
+		rec := Record{layout: layout, offsets: layout.offsets(), order: streamOrder(r)}
+		for {
+			n, err := io.ReadFull(r, buf)
+			whole := n / size * size
+			for off := 0; off < whole; off += size {
+				rec.b = buf[off : off+size]
+				if !yield(rec, nil) {
+					return
+				}
+			}
+			switch err {
+			case nil:
+				continue
+			case io.EOF:
+				return
+			case io.ErrUnexpectedEOF:
+				if n != whole {
+					yield(Record{}, fmt.Errorf("%w: %d trailing bytes", ErrTruncatedData, n-whole))
+				}
+				return
+			default:
+				yield(Record{}, err)
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package kafka
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+
+	"github.com/oy3o/codec"
+	"github.com/oy3o/codec/lz4"
+	"github.com/oy3o/codec/snappy"
+)
+
+// ErrUnsupportedCompression indicates records compressed with a codec this
+// package does not implement, such as zstd.
+var ErrUnsupportedCompression = fmt.Errorf("%w: kafka records", codec.ErrUnknownCompression)
+
+// decompress returns the records of a batch compressed with c.
+func decompress(c Compression, data []byte) ([]byte, error) {
+	var zr io.Reader
+	switch c {
+	case CompressNone:
+		return data, nil
+	case CompressGzip:
+		gz, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidBatch, err)
+		}
+		zr = gz
+	case CompressSnappy:
+		return snappy.DecodeXerial(nil, data, MAX_BATCH_SIZE)
+	case CompressLZ4:
+		zr = lz4.NewReader(bytes.NewReader(data))
+	default:
+		return nil, fmt.Errorf("%w: codec %d", ErrUnsupportedCompression, c)
+	}
+	out, err := io.ReadAll(io.LimitReader(zr, MAX_BATCH_SIZE+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(out) > MAX_BATCH_SIZE {
+		return nil, fmt.Errorf("%w: records decompress to more than %d bytes", codec.ErrLengthOverflow, MAX_BATCH_SIZE)
+	}
+	return out, nil
+}
+
+// compress returns records compressed with c.
+func compress(c Compression, records []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	var zw io.WriteCloser
+	switch c {
+	case CompressNone:
+		return records, nil
+	case CompressGzip:
+		zw = gzip.NewWriter(&buf)
+	case CompressSnappy:
+		return snappy.EncodeXerial(nil, records, 32<<10), nil
+	case CompressLZ4:
+		zw = lz4.NewWriter(&buf)
+	default:
+		return nil, fmt.Errorf("%w: codec %d", ErrUnsupportedCompression, c)
+	}
+	if _, err := zw.Write(records); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
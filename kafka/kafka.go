@@ -0,0 +1,365 @@
+// Package kafka implements the Kafka record batch format (magic 2) on top of
+// codec.Reader and codec.Writer, for tools that inspect log segments and
+// network captures. It covers the batch header, the varint-encoded records
+// and their headers, the CRC-32C over the batch, and the gzip, snappy and
+// lz4 compression of the records.
+//
+// A batch longer than MAX_BATCH_SIZE is rejected with ErrInvalidBatch before
+// it is read, one whose CRC-32C does not match with codec.ErrChecksumMismatch,
+// and one of another message format with ErrUnsupportedMagic. ReadBatch reads
+// the batch with codec.Reader.ReadBytes, so the Reader's allocation limit
+// applies as well.
+package kafka
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"iter"
+	"math"
+
+	"github.com/oy3o/codec"
+)
+
+// MAX_BATCH_SIZE bounds the batch length read by ReadBatch, and the size of
+// its records once decompressed.
+const MAX_BATCH_SIZE = 64 << 20
+
+// MAGIC is the only record batch version this package reads and writes.
+const MAGIC = 2
+
+const (
+	// HEADER_SIZE is the size of the batch header, up to the first record.
+	HEADER_SIZE = 61
+	// crcStart is the offset of the first byte covered by the CRC.
+	crcStart = 21
+	// lengthSize is the size of the base offset and the batch length, which
+	// the batch length does not count.
+	lengthSize = 12
+)
+
+var (
+	// ErrInvalidBatch indicates a record batch with inconsistent fields.
+	ErrInvalidBatch = errors.New("kafka: invalid record batch")
+
+	// ErrUnsupportedMagic indicates a batch of a message format other than MAGIC.
+	ErrUnsupportedMagic = errors.New("kafka: unsupported magic")
+)
+
+// Compression is the codec of the records, in the low bits of Attributes.
+type Compression int16
+
+const (
+	CompressNone Compression = iota
+	CompressGzip
+	CompressSnappy // xerial framing
+	CompressLZ4    // LZ4 frame
+	CompressZstd   // recognized but not supported
+)
+
+// Batch attribute bits above the compression.
+const (
+	AttrLogAppendTime    int16 = 1 << 3 // timestamps set by the broker
+	AttrTransactional    int16 = 1 << 4
+	AttrControl          int16 = 1 << 5
+	AttrHasDeleteHorizon int16 = 1 << 6
+
+	attrCompression int16 = 7
+)
+
+// Header is a record header. A nil Value is encoded as null.
+type Header struct {
+	Key   string
+	Value []byte
+}
+
+// Record is a record of a batch. Its timestamp and offset are deltas from the
+// batch's; a nil Key or Value is encoded as null.
+type Record struct {
+	Attributes     int8
+	TimestampDelta int64
+	OffsetDelta    int32
+	Key            []byte
+	Value          []byte
+	Headers        []Header
+}
+
+// Batch is a record batch. LastOffsetDelta and MaxTimestamp are written as
+// set, so producers must keep them consistent with the records.
+type Batch struct {
+	BaseOffset           int64
+	PartitionLeaderEpoch int32
+	Attributes           int16
+	LastOffsetDelta      int32
+	BaseTimestamp        int64
+	MaxTimestamp         int64
+	ProducerID           int64
+	ProducerEpoch        int16
+	BaseSequence         int32
+	Records              []Record
+}
+
+// Compression returns the codec of the records.
+func (b *Batch) Compression() Compression { return Compression(b.Attributes & attrCompression) }
+
+// Offset returns the absolute offset of r.
+func (b *Batch) Offset(r *Record) int64 { return b.BaseOffset + int64(r.OffsetDelta) }
+
+// Timestamp returns the timestamp of r in milliseconds since the epoch.
+func (b *Batch) Timestamp(r *Record) int64 {
+	if b.Attributes&AttrLogAppendTime != 0 {
+		return b.MaxTimestamp
+	}
+	return b.BaseTimestamp + r.TimestampDelta
+}
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// ReadBatch reads a record batch, verifying its CRC and decompressing its
+// records. A Reader at the end of a segment returns io.EOF.
+func ReadBatch(r *codec.Reader, b *Batch) error {
+	var prefix [lengthSize]byte
+	r.ReadBytesTo(prefix[:])
+	if err := r.Err(); err != nil {
+		return err // io.EOF between batches, io.ErrUnexpectedEOF inside one
+	}
+	length := int32(binary.BigEndian.Uint32(prefix[8:]))
+	if length < HEADER_SIZE-lengthSize || length > MAX_BATCH_SIZE {
+		return fmt.Errorf("%w: batch length %d", ErrInvalidBatch, length)
+	}
+	body := r.ReadBytes(int(length))
+	if err := r.Err(); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	return parseBatch(prefix[:], body, b)
+}
+
+// ParseBatch decodes the record batch that makes up data.
+func ParseBatch(data []byte, b *Batch) error {
+	if len(data) < HEADER_SIZE {
+		return fmt.Errorf("%w: %d bytes", ErrInvalidBatch, len(data))
+	}
+	if length := binary.BigEndian.Uint32(data[8:]); int64(length) != int64(len(data)-lengthSize) {
+		return fmt.Errorf("%w: batch length %d for %d bytes", ErrInvalidBatch, length, len(data)-lengthSize)
+	}
+	return parseBatch(data[:lengthSize], data[lengthSize:], b)
+}
+
+// parseBatch decodes a batch split after its length, so ReadBatch can read
+// the body on its own. Offsets into body are those of the batch minus
+// lengthSize.
+func parseBatch(prefix, body []byte, b *Batch) error {
+	const o = lengthSize
+	if magic := body[16-o]; magic != MAGIC {
+		return fmt.Errorf("%w: %d", ErrUnsupportedMagic, magic)
+	}
+	stored := binary.BigEndian.Uint32(body[17-o:])
+	if sum := crc32.Checksum(body[crcStart-o:], castagnoli); sum != stored {
+		return fmt.Errorf("%w: batch crc %08x, computed %08x", codec.ErrChecksumMismatch, stored, sum)
+	}
+
+	be := binary.BigEndian
+	b.BaseOffset = int64(be.Uint64(prefix))
+	b.PartitionLeaderEpoch = int32(be.Uint32(body[12-o:]))
+	b.Attributes = int16(be.Uint16(body[21-o:]))
+	b.LastOffsetDelta = int32(be.Uint32(body[23-o:]))
+	b.BaseTimestamp = int64(be.Uint64(body[27-o:]))
+	b.MaxTimestamp = int64(be.Uint64(body[35-o:]))
+	b.ProducerID = int64(be.Uint64(body[43-o:]))
+	b.ProducerEpoch = int16(be.Uint16(body[51-o:]))
+	b.BaseSequence = int32(be.Uint32(body[53-o:]))
+	count := int32(be.Uint32(body[57-o:]))
+	records, err := decompress(b.Compression(), body[HEADER_SIZE-o:])
+	if err != nil {
+		return err
+	}
+	// Every record takes at least 7 bytes, which bounds the allocation.
+	if count < 0 || int64(count) > int64(len(records)/7) {
+		return fmt.Errorf("%w: %d records in %d bytes", ErrInvalidBatch, count, len(records))
+	}
+
+	rr, err := codec.NewReader(bytes.NewReader(records))
+	if err != nil {
+		return err
+	}
+	b.Records = make([]Record, count)
+	for i := range b.Records {
+		if err := readRecord(rr, &b.Records[i]); err != nil {
+			return fmt.Errorf("record %d: %w", i, err)
+		}
+	}
+	if rr.Count() != int64(len(records)) {
+		return fmt.Errorf("%w: %d bytes after the last record", ErrInvalidBatch, int64(len(records))-rr.Count())
+	}
+	return nil
+}
+
+// Batches returns an iterator over the record batches of a log segment. It
+// stops after the first error.
+func Batches(r *codec.Reader) iter.Seq2[*Batch, error] {
+	return func(yield func(*Batch, error) bool) {
+		for {
+			b := new(Batch)
+			err := ReadBatch(r, b)
+			if err == io.EOF {
+				return
+			}
+			if !yield(b, err) || err != nil {
+				return
+			}
+		}
+	}
+}
+
+// readVarint reads a zigzag varint that fits in 32 bits.
+func readVarint(r *codec.Reader) (int64, error) {
+	v, err := binary.ReadVarint(r)
+	if err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return 0, err
+	}
+	if v < math.MinInt32 || v > math.MaxInt32 {
+		return 0, fmt.Errorf("%w: varint %d out of range", ErrInvalidBatch, v)
+	}
+	return v, nil
+}
+
+// readNullable reads a varint length and that many bytes, or nil for -1.
+func readNullable(r *codec.Reader) ([]byte, error) {
+	n, err := readVarint(r)
+	if err != nil || n == -1 {
+		return nil, err
+	}
+	if n < -1 || n > MAX_BATCH_SIZE {
+		return nil, fmt.Errorf("%w: length %d", ErrInvalidBatch, n)
+	}
+	p := r.ReadBytes(int(n))
+	if err := r.Err(); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	if p == nil {
+		p = []byte{}
+	}
+	return p, nil
+}
+
+func readRecord(r *codec.Reader, rec *Record) error {
+	length, err := readVarint(r)
+	if err != nil {
+		return err
+	}
+	start := r.Count()
+	var attrs int8
+	r.ReadInt8(&attrs)
+	rec.Attributes = attrs
+	if rec.TimestampDelta, err = binary.ReadVarint(r); err != nil {
+		return fmt.Errorf("%w: timestamp delta: %w", ErrInvalidBatch, err)
+	}
+	delta, err := readVarint(r)
+	if err != nil {
+		return err
+	}
+	rec.OffsetDelta = int32(delta)
+	if rec.Key, err = readNullable(r); err != nil {
+		return err
+	}
+	if rec.Value, err = readNullable(r); err != nil {
+		return err
+	}
+	n, err := readVarint(r)
+	if err != nil {
+		return err
+	}
+	if n < 0 || n > length {
+		return fmt.Errorf("%w: %d headers", ErrInvalidBatch, n)
+	}
+	rec.Headers = nil
+	for range n {
+		key, err := readNullable(r)
+		if err != nil {
+			return err
+		}
+		value, err := readNullable(r)
+		if err != nil {
+			return err
+		}
+		rec.Headers = append(rec.Headers, Header{Key: string(key), Value: value})
+	}
+	if got := r.Count() - start; got != length {
+		return fmt.Errorf("%w: record of %d bytes declares %d", ErrInvalidBatch, got, length)
+	}
+	return nil
+}
+
+// WriteBatch writes b, compressing its records with b.Compression() and
+// computing the batch length, record count and CRC.
+func WriteBatch(w *codec.Writer, b *Batch) error {
+	var records bytes.Buffer
+	for i := range b.Records {
+		appendRecord(&records, &b.Records[i])
+	}
+	data, err := compress(b.Compression(), records.Bytes())
+	if err != nil {
+		return err
+	}
+	if len(b.Records) > math.MaxInt32 || HEADER_SIZE-lengthSize+len(data) > math.MaxInt32 {
+		return fmt.Errorf("%w: batch of %d bytes", codec.ErrLengthOverflow, len(data))
+	}
+
+	be := binary.BigEndian
+	head := make([]byte, HEADER_SIZE)
+	be.PutUint64(head, uint64(b.BaseOffset))
+	be.PutUint32(head[8:], uint32(HEADER_SIZE-lengthSize+len(data)))
+	be.PutUint32(head[12:], uint32(b.PartitionLeaderEpoch))
+	head[16] = MAGIC
+	be.PutUint16(head[21:], uint16(b.Attributes))
+	be.PutUint32(head[23:], uint32(b.LastOffsetDelta))
+	be.PutUint64(head[27:], uint64(b.BaseTimestamp))
+	be.PutUint64(head[35:], uint64(b.MaxTimestamp))
+	be.PutUint64(head[43:], uint64(b.ProducerID))
+	be.PutUint16(head[51:], uint16(b.ProducerEpoch))
+	be.PutUint32(head[53:], uint32(b.BaseSequence))
+	be.PutUint32(head[57:], uint32(len(b.Records)))
+	crc := crc32.Update(crc32.Checksum(head[crcStart:], castagnoli), castagnoli, data)
+	be.PutUint32(head[17:], crc)
+
+	w.WriteBytes(head)
+	w.WriteBytes(data)
+	return w.Err()
+}
+
+func appendNullable(buf *bytes.Buffer, p []byte) {
+	if p == nil {
+		buf.Write(binary.AppendVarint(buf.AvailableBuffer(), -1))
+		return
+	}
+	buf.Write(binary.AppendVarint(buf.AvailableBuffer(), int64(len(p))))
+	buf.Write(p)
+}
+
+func appendRecord(buf *bytes.Buffer, rec *Record) {
+	var body bytes.Buffer
+	body.WriteByte(byte(rec.Attributes))
+	body.Write(binary.AppendVarint(body.AvailableBuffer(), rec.TimestampDelta))
+	body.Write(binary.AppendVarint(body.AvailableBuffer(), int64(rec.OffsetDelta)))
+	appendNullable(&body, rec.Key)
+	appendNullable(&body, rec.Value)
+	body.Write(binary.AppendVarint(body.AvailableBuffer(), int64(len(rec.Headers))))
+	for _, h := range rec.Headers {
+		appendNullable(&body, []byte(h.Key))
+		appendNullable(&body, h.Value)
+	}
+	buf.Write(binary.AppendVarint(buf.AvailableBuffer(), int64(body.Len())))
+	buf.Write(body.Bytes())
+}
@@ -0,0 +1,94 @@
+//go:build test
+
+package kafka
+
+import (
+	"bytes"
+	"encoding/hex"
+	"io"
+	"testing"
+
+	"github.com/oy3o/codec"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// A batch at offset 42 holding one record with key "k", value "hello" and a
+// header "h" with a null value.
+const batchHex = "000000000000002a000000410000000702c975cdc50000000000000000018bcfe568000000018bcfe56805" +
+	"ffffffffffffffffffffffffffff000000011e000a00026b0a68656c6c6f02026801"
+
+func TestReadBatch(t *testing.T) {
+	data, _ := hex.DecodeString(batchHex)
+	r, _ := codec.NewReader(bytes.NewReader(data))
+	var b Batch
+	require.NoError(t, ReadBatch(r, &b))
+	assert.EqualValues(t, 42, b.BaseOffset)
+	assert.EqualValues(t, 7, b.PartitionLeaderEpoch)
+	assert.Equal(t, CompressNone, b.Compression())
+	assert.EqualValues(t, -1, b.ProducerID)
+	require.Len(t, b.Records, 1)
+	rec := &b.Records[0]
+	assert.Equal(t, "k", string(rec.Key))
+	assert.Equal(t, "hello", string(rec.Value))
+	assert.Equal(t, []Header{{Key: "h"}}, rec.Headers)
+	assert.EqualValues(t, 42, b.Offset(rec))
+	assert.EqualValues(t, 1700000000005, b.Timestamp(rec))
+	assert.Equal(t, io.EOF, ReadBatch(r, &b))
+
+	// Writing reproduces the bytes.
+	var buf bytes.Buffer
+	w, _ := codec.NewWriter(&buf)
+	require.NoError(t, WriteBatch(w, &b))
+	assert.Equal(t, data, buf.Bytes())
+
+	corrupt := bytes.Clone(data)
+	corrupt[len(corrupt)-2] ^= 1
+	assert.ErrorIs(t, ParseBatch(corrupt, &b), codec.ErrChecksumMismatch)
+	r, _ = codec.NewReader(bytes.NewReader(data[:30]))
+	assert.ErrorIs(t, ReadBatch(r, &b), io.ErrUnexpectedEOF)
+	corrupt = bytes.Clone(data)
+	corrupt[16] = 1
+	assert.ErrorIs(t, ParseBatch(corrupt, &b), ErrUnsupportedMagic)
+	corrupt = bytes.Clone(data)
+	corrupt[8] = 0x7F
+	r, _ = codec.NewReader(bytes.NewReader(corrupt))
+	assert.ErrorIs(t, ReadBatch(r, &b), ErrInvalidBatch)
+	r, _ = codec.NewReaderOpts(bytes.NewReader(data), codec.WithMaxAlloc(32))
+	assert.ErrorIs(t, ReadBatch(r, &b), codec.ErrLengthOverflow)
+}
+
+func TestBatchCompression(t *testing.T) {
+	var buf bytes.Buffer
+	w, _ := codec.NewWriter(&buf)
+	var records []Record
+	for i := range 100 {
+		records = append(records, Record{
+			OffsetDelta:    int32(i),
+			TimestampDelta: int64(i),
+			Value:          bytes.Repeat([]byte("payload "), 20),
+			Headers:        []Header{{Key: "trace", Value: []byte{byte(i)}}},
+		})
+	}
+	codecs := []Compression{CompressNone, CompressGzip, CompressSnappy, CompressLZ4}
+	for i, c := range codecs {
+		b := Batch{BaseOffset: int64(100 * i), Attributes: int16(c), LastOffsetDelta: 99, Records: records}
+		require.NoError(t, WriteBatch(w, &b))
+	}
+	require.NoError(t, w.Flush())
+	assert.Less(t, buf.Len(), 2*(HEADER_SIZE+100*200))
+
+	r, _ := codec.NewReader(&buf)
+	i := 0
+	for b, err := range Batches(r) {
+		require.NoError(t, err)
+		assert.Equal(t, codecs[i], b.Compression())
+		assert.EqualValues(t, 100*i+99, b.Offset(&b.Records[99]))
+		assert.Equal(t, records, b.Records)
+		i++
+	}
+	assert.Equal(t, len(codecs), i)
+
+	w, _ = codec.NewWriter(&buf)
+	assert.ErrorIs(t, WriteBatch(w, &Batch{Attributes: int16(CompressZstd)}), codec.ErrUnknownCompression)
+}
@@ -0,0 +1,122 @@
+package codec
+
+import (
+	"fmt"
+	"io"
+)
+
+// relocation is an offset field written by WriteOffset and fixed up by Resolve.
+type relocation struct {
+	field        *Placeholder // reserved for the offset
+	target, base string       // the field holds offset(target) - offset(base)
+}
+
+// MarkLabel records the current position under name, replacing any earlier
+// position of the same name.
+func (w *Writer) MarkLabel(name string) {
+	if w.labels == nil {
+		w.labels = make(map[string]int64)
+	}
+	w.labels[name] = w.count
+}
+
+// OffsetOf returns the position recorded for name by MarkLabel.
+func (w *Writer) OffsetOf(name string) (int64, bool) {
+	pos, ok := w.labels[name]
+	return pos, ok
+}
+
+// WriteOffset writes a size-byte placeholder (1, 2, 4 or 8) that Resolve
+// replaces with the distance from label base to label target, encoded in the
+// Writer's byte order. An empty base measures from the start of the Writer.
+// Labels may be marked before or after the placeholder, so forward references
+// such as jump tables and tables of contents can be written in one pass. The
+// placeholder is taken with Reserve, so Writers that cannot patch hold back
+// their output from the first one until Resolve.
+func (w *Writer) WriteOffset(target, base string, size int) {
+	if w.err != nil {
+		return
+	}
+	switch size {
+	case 1, 2, 4, 8:
+	default:
+		w.setError(fmt.Errorf("%w: offset width %d", ErrInvalidBitCount, size*8))
+		return
+	}
+	w.relocs = append(w.relocs, relocation{field: w.Reserve(size), target: target, base: base})
+}
+
+// Resolve is the relocation pass: it computes every offset written by
+// WriteOffset from the final label positions and patches it in place. Negative
+// distances are encoded in two's complement. It flushes the Writer when
+// patching through io.WriterAt, and writes out the output held back by
+// WriteOffset when the destination cannot patch.
+func (w *Writer) Resolve() error {
+	if w.err != nil {
+		return w.err
+	}
+	var buf [8]byte
+	for _, r := range w.relocs {
+		target, ok := w.labels[r.target]
+		if !ok {
+			w.setError(fmt.Errorf("%w: %q", ErrUndefinedLabel, r.target))
+			return w.err
+		}
+		var base int64
+		if r.base != "" {
+			if base, ok = w.labels[r.base]; !ok {
+				w.setError(fmt.Errorf("%w: %q", ErrUndefinedLabel, r.base))
+				return w.err
+			}
+		}
+		v, size := target-base, r.field.n
+		if bits := size * 8; bits < 64 && (v < -(1<<(bits-1)) || v > 1<<bits-1) {
+			w.setError(fmt.Errorf("%w: offset %d from %q to %q does not fit %d bytes", ErrLengthOverflow, v, r.base, r.target, size))
+			return w.err
+		}
+		switch size {
+		case 1:
+			buf[0] = byte(v)
+		case 2:
+			w.order.PutUint16(buf[:], uint16(v))
+		case 4:
+			w.order.PutUint32(buf[:], uint32(v))
+		default:
+			w.order.PutUint64(buf[:], uint64(v))
+		}
+		if err := r.field.SetBytes(buf[:size]); err != nil {
+			return err
+		}
+	}
+	w.relocs = w.relocs[:0]
+	return nil
+}
+
+// MarkLabel records the current read position under name.
+func (r *Reader) MarkLabel(name string) {
+	if r.labels == nil {
+		r.labels = make(map[string]int64)
+	}
+	r.labels[name] = r.count
+}
+
+// OffsetOf returns the position recorded for name by MarkLabel.
+func (r *Reader) OffsetOf(name string) (int64, bool) {
+	pos, ok := r.labels[name]
+	return pos, ok
+}
+
+// SeekLabel moves the read position to a label, or to a position relative to
+// it, using the Reader's Seek. Forward-only sources can only seek ahead.
+func (r *Reader) SeekLabel(name string, delta int64) {
+	pos, ok := r.labels[name]
+	if !ok {
+		r.setError(fmt.Errorf("%w: %q", ErrUndefinedLabel, name))
+		return
+	}
+	if pos+delta < 0 {
+		r.setError(ErrInvalidSeek)
+		return
+	}
+	r.Seek(pos+delta, io.SeekStart)
+}
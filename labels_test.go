@@ -0,0 +1,108 @@
+//go:build test
+
+package codec
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeTOC writes a header holding the offsets and sizes of two sections
+// that follow it.
+func writeTOC(w *Writer) {
+	w.WriteString("TOC")
+	w.WriteOffset("a", "", 2)
+	w.WriteOffset("b", "", 2)
+	w.WriteOffset("end", "b", 1)
+	w.MarkLabel("a")
+	w.WriteString("alpha")
+	w.MarkLabel("b")
+	w.WriteString("be")
+	w.MarkLabel("end")
+}
+
+func TestWriterLabels(t *testing.T) {
+	want := []byte("TOC\x00\x08\x00\x0d\x02alphabe")
+
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf)
+	require.NoError(t, err)
+	writeTOC(w)
+	require.NoError(t, w.Resolve())
+	assert.Equal(t, want, buf.Bytes())
+
+	bw := NewBytesWriter(make([]byte, 32))
+	bw.Write([]byte{0xEE})
+	w, err = NewWriter(bw)
+	require.NoError(t, err)
+	writeTOC(w)
+	require.NoError(t, w.Resolve())
+	assert.Equal(t, append([]byte{0xEE}, want...), bw.Bytes())
+
+	f, err := os.Create(filepath.Join(t.TempDir(), "toc"))
+	require.NoError(t, err)
+	defer f.Close()
+	f.Write([]byte{0xEE})
+	w, err = NewWriter(f)
+	require.NoError(t, err)
+	writeTOC(w)
+	require.NoError(t, w.Resolve())
+	require.NoError(t, w.Flush())
+	data, err := os.ReadFile(f.Name())
+	require.NoError(t, err)
+	assert.Equal(t, append([]byte{0xEE}, want...), data)
+
+	// Destinations that cannot patch are held back until Resolve.
+	buf.Reset()
+	bufw := bufio.NewWriter(struct{ io.Writer }{&buf})
+	w, err = NewWriter(bufw)
+	require.NoError(t, err)
+	assert.False(t, w.CanPatch())
+	w.WriteString("TOC")
+	w.WriteOffset("a", "", 2)
+	w.WriteString("alpha")
+	w.MarkLabel("a")
+	require.NoError(t, w.Flush())
+	require.NoError(t, bufw.Flush())
+	assert.Equal(t, "TOC", buf.String())
+	require.NoError(t, w.Resolve())
+	require.NoError(t, w.Flush())
+	require.NoError(t, bufw.Flush())
+	assert.Equal(t, "TOC\x00\x0aalpha", buf.String())
+
+	w, err = NewWriter(&buf)
+	require.NoError(t, err)
+	w.WriteOffset("missing", "", 4)
+	assert.ErrorIs(t, w.Resolve(), ErrUndefinedLabel)
+
+	w, err = NewWriter(&buf)
+	require.NoError(t, err)
+	w.WriteOffset("far", "", 1)
+	w.WriteZeros(300)
+	w.MarkLabel("far")
+	assert.ErrorIs(t, w.Resolve(), ErrLengthOverflow)
+}
+
+func TestReaderLabels(t *testing.T) {
+	r, err := NewReader(bytes.NewReader([]byte("abcdef")))
+	require.NoError(t, err)
+	r.ReadBytes(2)
+	r.MarkLabel("c")
+	r.ReadBytes(3)
+	pos, ok := r.OffsetOf("c")
+	assert.True(t, ok)
+	assert.EqualValues(t, 2, pos)
+
+	r.SeekLabel("c", 1)
+	assert.Equal(t, []byte("def"), r.ReadBytes(3))
+
+	r.SeekLabel("nope", 0)
+	assert.ErrorIs(t, r.Err(), ErrUndefinedLabel)
+}
@@ -1,6 +1,7 @@
 package codec
 
 import (
+	"encoding/binary"
 	"io"
 	"reflect"
 )
@@ -11,11 +12,57 @@ type List interface {
 	Codecs() []Codec
 }
 
-// listOptions defines the configuration for encoding and decoding a list of codecs.
-type listOptions struct {
+// ListOptions defines the configuration for encoding and decoding a list of codecs.
+type ListOptions struct {
 	// Alignment specifies the byte boundary to which each item (except the last) should be padded.
 	// A value of 0 or 1 means no alignment. Common values are 4 or 8.
 	Alignment int
+
+	// Order is the byte order of items that do not set their own with
+	// WithByteOrder, such as Fixed and Versioned items; nil means the global Order.
+	Order binary.ByteOrder
+}
+
+// orderInheritor is implemented by codecs with a per-instance byte order,
+// which adopt the order of their container unless they have their own.
+type orderInheritor interface {
+	// writeToOrder encodes like WriteTo, using order if the codec has no
+	// order of its own. It never modifies the codec.
+	writeToOrder(w io.Writer, order binary.ByteOrder) (int64, error)
+	// inheritOrder adopts order if the codec has none. Containers only call
+	// it on items they allocate themselves while decoding.
+	inheritOrder(order binary.ByteOrder)
+}
+
+// orderedItem is an io.WriterTo encoding item with its container's order.
+type orderedItem struct {
+	item  orderInheritor
+	order binary.ByteOrder
+}
+
+func (o orderedItem) WriteTo(w io.Writer) (int64, error) {
+	return o.item.writeToOrder(w, o.order)
+}
+
+// writeItem writes item, passing down the list's byte order.
+func (l *list[T]) writeItem(w *Writer, item T) {
+	if l.options.Order != nil {
+		if oi, ok := any(item).(orderInheritor); ok {
+			w.WriteFrom(orderedItem{oi, l.options.Order})
+			return
+		}
+	}
+	w.WriteFrom(item)
+}
+
+// inherit passes the list's byte order to a freshly decoded item.
+func (l *list[T]) inherit(item T) {
+	if l.options.Order == nil {
+		return
+	}
+	if oi, ok := any(item).(orderInheritor); ok {
+		oi.inheritOrder(l.options.Order)
+	}
 }
 
 // list is a generic, high-performance codec for handling slices of any type
@@ -23,7 +70,7 @@ type listOptions struct {
 // streaming reads and writes.
 type list[T Codec] struct {
 	Items   []T
-	options *listOptions
+	options *ListOptions
 }
 
 // Statically ensure that List implements Codec.
@@ -44,9 +91,9 @@ type (
 )
 
 // NewList creates a new List codec with the given items and options.
-func NewList[T Codec](items []T, options *listOptions) *list[T] {
+func NewList[T Codec](items []T, options *ListOptions) *list[T] {
 	if options == nil {
-		options = &listOptions{Alignment: 0}
+		options = &ListOptions{Alignment: 0}
 	}
 	return &list[T]{
 		Items:   items,
@@ -56,17 +103,17 @@ func NewList[T Codec](items []T, options *listOptions) *list[T] {
 
 // no Align type List
 func NewList0[T Codec](items []T) *List0[T] {
-	return &List0[T]{list[T]{Items: items, options: &listOptions{Alignment: 0}}}
+	return &List0[T]{list[T]{Items: items, options: &ListOptions{Alignment: 0}}}
 }
 
 // 4 Align type List
 func NewList4[T Codec](items []T) *List4[T] {
-	return &List4[T]{list[T]{Items: items, options: &listOptions{Alignment: 4}}}
+	return &List4[T]{list[T]{Items: items, options: &ListOptions{Alignment: 4}}}
 }
 
 // 8 Align type List
 func NewList8[T Codec](items []T) *List8[T] {
-	return &List8[T]{list[T]{Items: items, options: &listOptions{Alignment: 8}}}
+	return &List8[T]{list[T]{Items: items, options: &ListOptions{Alignment: 8}}}
 }
 
 func (l *list[T]) Len() int {
@@ -104,7 +151,7 @@ func (l *list[T]) WriteTo(writer io.Writer) (int64, error) {
 	lastIndex := len(l.Items) - 1
 
 	for i, item := range l.Items {
-		w.WriteFrom(item)
+		l.writeItem(w, item)
 
 		if i < lastIndex && l.options.Alignment > 1 {
 			w.Align(l.options.Alignment)
@@ -122,16 +169,9 @@ func (l *list[T]) ReadFrom(reader io.Reader) (int64, error) {
 	count := cap(l.Items)
 	readEOF := count == 0
 
-	// Create a new instance of the concrete type T for decoding into.
-	var item T
-
-	elemType := reflect.TypeOf(item)
-	if elemType.Kind() == reflect.Ptr {
-		elemType = elemType.Elem()
-	}
-
 	for i := 0; readEOF || i < count; i++ {
-		newItem := reflect.New(elemType).Interface().(T)
+		newItem := newCodec[T]()
+		l.inherit(newItem)
 
 		// Try to read the next item.
 		read, err := newItem.ReadFrom(reader)
@@ -172,6 +212,17 @@ func (l *list[T]) ReadFrom(reader io.Reader) (int64, error) {
 	return n, nil
 }
 
+// newCodec creates a new instance of the concrete type T for decoding into.
+// T is expected to be a pointer type, as codecs decode through pointer receivers.
+func newCodec[T Codec]() T {
+	var item T
+	elemType := reflect.TypeOf(item)
+	if elemType.Kind() == reflect.Ptr {
+		elemType = elemType.Elem()
+	}
+	return reflect.New(elemType).Interface().(T)
+}
+
 // --- Boilerplate implementations ---
 
 func (l *list[T]) MarshalBinary() ([]byte, error) {
@@ -185,3 +236,7 @@ func (l *list[T]) UnmarshalBinary(data []byte) error {
 func (l *list[T]) MarshalTo(buf []byte) (int, error) {
 	return MarshalToGeneric(l, buf)
 }
+
+func (l *list[T]) MarshalAppend(dst []byte) ([]byte, error) {
+	return MarshalAppendGeneric(l, dst)
+}
@@ -0,0 +1,317 @@
+package lz4
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/oy3o/codec"
+)
+
+const (
+	// MAGIC starts every LZ4 frame.
+	MAGIC = 0x184D2204
+	// BLOCK_SIZE is the largest block Writer produces, matching the 64 KiB
+	// block maximum of its frame descriptor.
+	BLOCK_SIZE = 64 << 10
+
+	skippableMagic = 0x184D2A50 // the low nibble is free
+	skippableMask  = 0xFFFFFFF0
+
+	flagVersion       = 0x40
+	flagIndependent   = 0x20
+	flagBlockChecksum = 0x10
+	flagContentSize   = 0x08
+	flagContentSum    = 0x04
+	flagDictID        = 0x01
+
+	uncompressedBit = 1 << 31
+	windowSize      = 64 << 10
+)
+
+// blockMax returns the block size of a BD byte.
+func blockMax(bd byte) (int, error) {
+	id := bd >> 4 & 7
+	if id < 4 || bd&0x8F != 0 {
+		return 0, fmt.Errorf("%w: block descriptor %#x", ErrCorrupt, bd)
+	}
+	return 1 << (8 + 2*id), nil
+}
+
+// headerChecksum returns the HC byte of a frame descriptor.
+func headerChecksum(desc []byte) byte { return byte(checksum32(desc) >> 8) }
+
+// Writer compresses to a single LZ4 frame of independent 64 KiB blocks
+// followed by a content checksum. Close must be called to end the frame.
+type Writer struct {
+	dst     io.Writer
+	buf     []byte // pending content
+	out     []byte
+	sum     *xxh32
+	err     error
+	started bool
+}
+
+// NewWriter returns a Writer compressing to w.
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{dst: w, buf: make([]byte, 0, BLOCK_SIZE), sum: newXXH32()}
+}
+
+func (z *Writer) write(p []byte) error {
+	if _, err := z.dst.Write(p); err != nil {
+		z.err = err
+	}
+	return z.err
+}
+
+func (z *Writer) header() error {
+	z.started = true
+	h := []byte{0, 0, 0, 0, flagVersion | flagIndependent | flagContentSum, 4 << 4, 0}
+	binary.LittleEndian.PutUint32(h, MAGIC)
+	h[6] = headerChecksum(h[4:6])
+	return z.write(h)
+}
+
+// Write implements io.Writer, compressing a block whenever one is full.
+func (z *Writer) Write(p []byte) (int, error) {
+	if z.err != nil {
+		return 0, z.err
+	}
+	n := len(p)
+	for len(p) > 0 {
+		k := min(BLOCK_SIZE-len(z.buf), len(p))
+		z.buf = append(z.buf, p[:k]...)
+		p = p[k:]
+		if len(z.buf) == BLOCK_SIZE {
+			if err := z.block(); err != nil {
+				return n - len(p), err
+			}
+		}
+	}
+	return n, nil
+}
+
+// block writes the pending content as a block, stored uncompressed if
+// compression does not shrink it.
+func (z *Writer) block() error {
+	if !z.started {
+		if err := z.header(); err != nil {
+			return err
+		}
+	}
+	if len(z.buf) == 0 {
+		return nil
+	}
+	z.sum.Write(z.buf)
+	z.out = CompressBlock(append(z.out[:0], 0, 0, 0, 0), z.buf)
+	size := uint32(len(z.out) - 4)
+	if len(z.out)-4 >= len(z.buf) {
+		z.out = append(z.out[:4], z.buf...)
+		size = uint32(len(z.buf)) | uncompressedBit
+	}
+	binary.LittleEndian.PutUint32(z.out, size)
+	z.buf = z.buf[:0]
+	return z.write(z.out)
+}
+
+// Close writes the pending block, the end mark and the content checksum.
+// It does not close the underlying writer.
+func (z *Writer) Close() error {
+	if z.err != nil {
+		return z.err
+	}
+	if err := z.block(); err != nil {
+		return err
+	}
+	var end [8]byte
+	binary.LittleEndian.PutUint32(end[4:], z.sum.Sum32())
+	if err := z.write(end[:]); err != nil {
+		return err
+	}
+	z.err = codec.ErrClosed
+	return nil
+}
+
+// Reader decompresses a stream of LZ4 frames, skipping skippable frames.
+// It verifies header, block and content checksums, and supports linked
+// blocks; frames using a preset dictionary are rejected.
+type Reader struct {
+	src io.Reader
+	err error
+
+	inFrame  bool
+	flags    byte
+	max      int
+	size     uint64 // content size from the descriptor, if flagContentSize
+	sum      *xxh32
+	produced uint64
+
+	buf  []byte // compressed block
+	hist []byte // decoded window, the tail of which is returned by Read
+	out  []byte // decoded content not yet returned
+}
+
+// NewReader returns a Reader decompressing r.
+func NewReader(r io.Reader) *Reader {
+	return &Reader{src: r, sum: newXXH32()}
+}
+
+// Read implements io.Reader.
+func (z *Reader) Read(p []byte) (int, error) {
+	for len(z.out) == 0 {
+		if z.err != nil {
+			return 0, z.err
+		}
+		z.err = z.next()
+	}
+	n := copy(p, z.out)
+	z.out = z.out[n:]
+	return n, nil
+}
+
+// next decodes the next block, or the header of the next frame.
+func (z *Reader) next() error {
+	if !z.inFrame {
+		return z.header()
+	}
+	var prefix [4]byte
+	if _, err := io.ReadFull(z.src, prefix[:]); err != nil {
+		return unexpected(err)
+	}
+	size := binary.LittleEndian.Uint32(prefix[:])
+	if size == 0 {
+		return z.end()
+	}
+	stored := size&uncompressedBit != 0
+	size &^= uncompressedBit
+	if int(size) > z.max {
+		return fmt.Errorf("%w: block of %d bytes exceeds %d", codec.ErrLengthOverflow, size, z.max)
+	}
+	if cap(z.buf) < int(size) {
+		z.buf = make([]byte, size)
+	}
+	z.buf = z.buf[:size]
+	if _, err := io.ReadFull(z.src, z.buf); err != nil {
+		return unexpected(err)
+	}
+	if z.flags&flagBlockChecksum != 0 {
+		if err := z.verify(checksum32(z.buf), "block"); err != nil {
+			return err
+		}
+	}
+
+	// Linked blocks may refer to the previous 64 KiB of content.
+	if z.flags&flagIndependent != 0 {
+		z.hist = z.hist[:0]
+	} else if len(z.hist) > windowSize {
+		z.hist = append(z.hist[:0], z.hist[len(z.hist)-windowSize:]...)
+	}
+	start := len(z.hist)
+	if stored {
+		z.hist = append(z.hist, z.buf...)
+	} else {
+		var err error
+		if z.hist, err = DecompressBlock(z.hist, z.buf, z.max); err != nil {
+			return err
+		}
+	}
+	z.out = z.hist[start:]
+	z.sum.Write(z.out)
+	z.produced += uint64(len(z.out))
+	return nil
+}
+
+// header reads a frame descriptor, skipping any skippable frames before it.
+func (z *Reader) header() error {
+	var b [4]byte
+	for {
+		if _, err := io.ReadFull(z.src, b[:]); err != nil {
+			if err == io.ErrUnexpectedEOF {
+				return fmt.Errorf("%w: truncated frame magic", ErrCorrupt)
+			}
+			return err
+		}
+		magic := binary.LittleEndian.Uint32(b[:])
+		if magic == MAGIC {
+			break
+		}
+		if magic&skippableMask != skippableMagic {
+			return fmt.Errorf("%w: frame magic %#x", ErrCorrupt, magic)
+		}
+		if _, err := io.ReadFull(z.src, b[:]); err != nil {
+			return unexpected(err)
+		}
+		if _, err := codec.Discard(z.src, int64(binary.LittleEndian.Uint32(b[:]))); err != nil {
+			return unexpected(err)
+		}
+	}
+
+	desc := make([]byte, 2, 14)
+	if _, err := io.ReadFull(z.src, desc); err != nil {
+		return unexpected(err)
+	}
+	flags := desc[0]
+	if flags&0xC0 != flagVersion || flags&0x02 != 0 {
+		return fmt.Errorf("%w: frame flags %#x", ErrCorrupt, flags)
+	}
+	if flags&flagDictID != 0 {
+		return fmt.Errorf("%w: preset dictionaries are not supported", ErrCorrupt)
+	}
+	max, err := blockMax(desc[1])
+	if err != nil {
+		return err
+	}
+	extra := 1 // the header checksum
+	if flags&flagContentSize != 0 {
+		extra += 8
+	}
+	desc = desc[:2+extra]
+	if _, err := io.ReadFull(z.src, desc[2:]); err != nil {
+		return unexpected(err)
+	}
+	hc := desc[len(desc)-1]
+	desc = desc[:len(desc)-1]
+	if hc != headerChecksum(desc) {
+		return fmt.Errorf("%w: frame header checksum", codec.ErrChecksumMismatch)
+	}
+	if flags&flagContentSize != 0 {
+		z.size = binary.LittleEndian.Uint64(desc[2:])
+	}
+	z.inFrame, z.flags, z.max = true, flags, max
+	z.hist = z.hist[:0]
+	z.sum.Reset()
+	z.produced = 0
+	return nil
+}
+
+// end finishes a frame at its end mark.
+func (z *Reader) end() error {
+	z.inFrame = false
+	if z.flags&flagContentSize != 0 && z.produced != z.size {
+		return fmt.Errorf("%w: frame of %d bytes declares %d", ErrCorrupt, z.produced, z.size)
+	}
+	if z.flags&flagContentSum != 0 {
+		return z.verify(z.sum.Sum32(), "content")
+	}
+	return nil
+}
+
+// verify reads a checksum and compares it with sum.
+func (z *Reader) verify(sum uint32, what string) error {
+	var b [4]byte
+	if _, err := io.ReadFull(z.src, b[:]); err != nil {
+		return unexpected(err)
+	}
+	if stored := binary.LittleEndian.Uint32(b[:]); stored != sum {
+		return fmt.Errorf("%w: %s checksum %08x, computed %08x", codec.ErrChecksumMismatch, what, stored, sum)
+	}
+	return nil
+}
+
+// unexpected reports a stream ending inside a frame.
+func unexpected(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
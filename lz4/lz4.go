@@ -0,0 +1,184 @@
+// Package lz4 implements the LZ4 block format and the LZ4 frame format, for
+// the many game and telemetry formats that embed raw LZ4 blocks whose sizes
+// come from surrounding headers.
+//
+// Importing the package registers LZ4 with codec: the block format as the
+// Compressor for codec.CompressLZ4, used by Framer and bundles, and the frame
+// format as the "lz4" Compression, used by CompressWriter and CompressReader.
+package lz4
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/oy3o/codec"
+)
+
+// ErrCorrupt indicates LZ4 data that cannot be decoded.
+var ErrCorrupt = errors.New("lz4: corrupt input")
+
+const (
+	minMatch     = 4
+	lastLiterals = 5  // the last 5 bytes of a block are always literals
+	mfLimit      = 12 // the last match starts at least 12 bytes before the end
+	maxOffset    = 1<<16 - 1
+	hashLog      = 14
+)
+
+func init() {
+	codec.RegisterCompressor(codec.CompressLZ4, Compressor{})
+	codec.RegisterCompression("lz4", codec.Compression{
+		NewWriter: func(w io.Writer) (io.WriteCloser, error) { return NewWriter(w), nil },
+		NewReader: func(r io.Reader) (io.ReadCloser, error) { return io.NopCloser(NewReader(r)), nil },
+	})
+}
+
+// Compressor is a codec.Compressor using the LZ4 block format.
+type Compressor struct{}
+
+func (Compressor) Compress(dst, src []byte) ([]byte, error) { return CompressBlock(dst, src), nil }
+
+func (Compressor) Decompress(dst, src []byte, limit int) ([]byte, error) {
+	return DecompressBlock(dst, src, limit)
+}
+
+// CompressBound returns the largest size of a compressed block of n bytes.
+func CompressBound(n int) int { return n + n/255 + 16 }
+
+func hash(seq uint32) uint32 { return seq * 2654435761 >> (32 - hashLog) }
+
+// CompressBlock appends the LZ4 block encoding of src to dst.
+func CompressBlock(dst, src []byte) []byte {
+	if len(src) < mfLimit+1 {
+		return appendSequence(dst, src, 0, 0)
+	}
+	table := make([]int32, 1<<hashLog) // position+1 of the last occurrence of each hash
+	anchor := 0
+	limit := len(src) - mfLimit
+	for i := 0; i < limit; {
+		seq := binary.LittleEndian.Uint32(src[i:])
+		h := hash(seq)
+		ref := int(table[h]) - 1
+		table[h] = int32(i + 1)
+		if ref < 0 || i-ref > maxOffset || binary.LittleEndian.Uint32(src[ref:]) != seq {
+			i++
+			continue
+		}
+		n := minMatch
+		for i+n < len(src)-lastLiterals && src[ref+n] == src[i+n] {
+			n++
+		}
+		dst = appendSequence(dst, src[anchor:i], i-ref, n)
+		i += n
+		anchor = i
+	}
+	return appendSequence(dst, src[anchor:], 0, 0)
+}
+
+// appendSequence appends a sequence of literals and a match, or only the
+// literals if offset is 0.
+func appendSequence(dst, literals []byte, offset, n int) []byte {
+	ll, ml := len(literals), n-minMatch
+	token := byte(min(ll, 15)) << 4
+	if offset > 0 {
+		token |= byte(min(ml, 15))
+	}
+	dst = append(dst, token)
+	if ll >= 15 {
+		dst = appendLength(dst, ll-15)
+	}
+	dst = append(dst, literals...)
+	if offset == 0 {
+		return dst
+	}
+	dst = append(dst, byte(offset), byte(offset>>8))
+	if ml >= 15 {
+		dst = appendLength(dst, ml-15)
+	}
+	return dst
+}
+
+func appendLength(dst []byte, n int) []byte {
+	for ; n >= 255; n -= 255 {
+		dst = append(dst, 255)
+	}
+	return append(dst, byte(n))
+}
+
+// readLength reads the extension bytes of a length field.
+func readLength(src []byte, i int) (int, int, error) {
+	n := 0
+	for {
+		if i >= len(src) {
+			return 0, i, ErrCorrupt
+		}
+		b := src[i]
+		i++
+		n += int(b)
+		if b != 255 {
+			return n, i, nil
+		}
+	}
+}
+
+// DecompressBlock appends the decoding of the LZ4 block src to dst. Matches
+// may reach back into dst, which holds the history of linked blocks. It fails
+// with codec.ErrLengthOverflow rather than append more than limit bytes.
+func DecompressBlock(dst, src []byte, limit int) ([]byte, error) {
+	start := len(dst)
+	for i := 0; i < len(src); {
+		token := src[i]
+		i++
+		ll := int(token >> 4)
+		if ll == 15 {
+			n, next, err := readLength(src, i)
+			if err != nil {
+				return dst, err
+			}
+			ll, i = ll+n, next
+		}
+		if ll > len(src)-i {
+			return dst, fmt.Errorf("%w: %d literals past the end of the block", ErrCorrupt, ll)
+		}
+		if ll > limit-(len(dst)-start) {
+			return dst, fmt.Errorf("%w: block decodes to more than %d bytes", codec.ErrLengthOverflow, limit)
+		}
+		dst = append(dst, src[i:i+ll]...)
+		i += ll
+		if i == len(src) {
+			return dst, nil
+		}
+
+		if i+2 > len(src) {
+			return dst, fmt.Errorf("%w: truncated match offset", ErrCorrupt)
+		}
+		offset := int(src[i]) | int(src[i+1])<<8
+		i += 2
+		if offset == 0 || offset > len(dst) {
+			return dst, fmt.Errorf("%w: match offset %d", ErrCorrupt, offset)
+		}
+		n := int(token&15) + minMatch
+		if token&15 == 15 {
+			ext, next, err := readLength(src, i)
+			if err != nil {
+				return dst, err
+			}
+			n, i = n+ext, next
+		}
+		if n > limit-(len(dst)-start) {
+			return dst, fmt.Errorf("%w: block decodes to more than %d bytes", codec.ErrLengthOverflow, limit)
+		}
+		pos := len(dst) - offset
+		if offset >= n {
+			dst = append(dst, dst[pos:pos+n]...)
+		} else {
+			// Overlapping matches repeat the last offset bytes.
+			for k := range n {
+				dst = append(dst, dst[pos+k])
+			}
+		}
+	}
+	return dst, fmt.Errorf("%w: empty block", ErrCorrupt)
+}
@@ -0,0 +1,162 @@
+//go:build test
+
+package lz4
+
+import (
+	"bytes"
+	"encoding/hex"
+	"io"
+	"math/rand"
+	"testing"
+
+	"github.com/oy3o/codec"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const sample = "hello hello hello hello hello, lz4 frame!"
+
+func unhex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+func TestXXH32(t *testing.T) {
+	assert.EqualValues(t, 0x02CC5D05, checksum32(nil))
+	assert.EqualValues(t, 0x32D153FF, checksum32([]byte("abc")))
+
+	// Streaming across stripe boundaries matches one-shot hashing.
+	data := bytes.Repeat([]byte("0123456789"), 10)
+	x := newXXH32()
+	for _, n := range []int{3, 20, 1, 76} {
+		x.Write(data[:n])
+		data = data[n:]
+	}
+	assert.Equal(t, checksum32(bytes.Repeat([]byte("0123456789"), 10)), x.Sum32())
+}
+
+func TestBlock(t *testing.T) {
+	random := make([]byte, 5000)
+	rand.New(rand.NewSource(1)).Read(random)
+	inputs := [][]byte{
+		nil,
+		[]byte("short"),
+		[]byte(sample),
+		bytes.Repeat([]byte{'a'}, 1000), // overlapping matches
+		bytes.Repeat([]byte("abcdefghij"), 30000),
+		random,
+	}
+	for _, in := range inputs {
+		c := CompressBlock(nil, in)
+		assert.LessOrEqual(t, len(c), CompressBound(len(in)))
+		out, err := DecompressBlock(nil, c, len(in))
+		require.NoError(t, err)
+		assert.Equal(t, len(in), len(out))
+		assert.True(t, bytes.Equal(in, out))
+	}
+	assert.Less(t, len(CompressBlock(nil, bytes.Repeat([]byte("abcdefghij"), 30000))), 2000)
+
+	c := CompressBlock(nil, bytes.Repeat([]byte{'a'}, 1000))
+	_, err := DecompressBlock(nil, c, 999)
+	assert.ErrorIs(t, err, codec.ErrLengthOverflow)
+	_, err = DecompressBlock(nil, c[:len(c)-3], 1000)
+	assert.ErrorIs(t, err, ErrCorrupt)
+	_, err = DecompressBlock(nil, []byte{0x04, 0x08, 0x00, 0x00}, 100) // offset before the start
+	assert.ErrorIs(t, err, ErrCorrupt)
+
+	// Matches may reach back into the history already in dst.
+	out, err := DecompressBlock([]byte("abcdefgh"), []byte{0x04, 0x08, 0x00, 0x00}, 8)
+	require.NoError(t, err)
+	assert.Equal(t, "abcdefghabcdefgh", string(out))
+}
+
+func TestFrame(t *testing.T) {
+	// Frames written by the reference lz4 tool, without and with block checksums.
+	for _, s := range []string{
+		"04224d186440a7170000006f68656c6c6f20060004c02c206c7a34206672616d652100000000c3887299",
+		"04224d187440bd170000006f68656c6c6f20060004c02c206c7a34206672616d65215cd3a9ac00000000c3887299",
+	} {
+		out, err := io.ReadAll(NewReader(bytes.NewReader(unhex(s))))
+		require.NoError(t, err)
+		assert.Equal(t, sample, string(out))
+	}
+
+	in := bytes.Repeat([]byte("telemetry sample "), 10000)
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	_, err := w.Write(in)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	_, err = w.Write([]byte{1})
+	assert.ErrorIs(t, err, codec.ErrClosed)
+	assert.Less(t, buf.Len(), len(in)/10)
+
+	// A skippable frame and a second frame follow.
+	frame := bytes.Clone(buf.Bytes())
+	buf.Write([]byte{0x5A, 0x2A, 0x4D, 0x18, 3, 0, 0, 0, 1, 2, 3})
+	w = NewWriter(&buf)
+	w.Write([]byte("tail"))
+	require.NoError(t, w.Close())
+	out, err := io.ReadAll(NewReader(&buf))
+	require.NoError(t, err)
+	assert.Equal(t, append(bytes.Clone(in), "tail"...), out)
+
+	corrupt := bytes.Clone(frame)
+	corrupt[len(corrupt)-1] ^= 1
+	_, err = io.ReadAll(NewReader(bytes.NewReader(corrupt)))
+	assert.ErrorIs(t, err, codec.ErrChecksumMismatch)
+	corrupt = bytes.Clone(frame)
+	corrupt[5] ^= 0x10
+	_, err = io.ReadAll(NewReader(bytes.NewReader(corrupt)))
+	assert.ErrorIs(t, err, codec.ErrChecksumMismatch)
+	_, err = io.ReadAll(NewReader(bytes.NewReader(frame[:len(frame)-6])))
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	_, err = io.ReadAll(NewReader(bytes.NewReader([]byte("not lz4"))))
+	assert.ErrorIs(t, err, ErrCorrupt)
+}
+
+func TestLinkedFrame(t *testing.T) {
+	// Two linked blocks: the second is a match into the first.
+	desc := []byte{flagVersion, 4 << 4}
+	frame := []byte{0x04, 0x22, 0x4D, 0x18}
+	frame = append(frame, desc...)
+	frame = append(frame, headerChecksum(desc))
+	frame = append(frame, 8, 0, 0, 0x80)
+	frame = append(frame, "abcdefgh"...)
+	frame = append(frame, 4, 0, 0, 0, 0x04, 0x08, 0x00, 0x00)
+	frame = append(frame, 0, 0, 0, 0)
+	out, err := io.ReadAll(NewReader(bytes.NewReader(frame)))
+	require.NoError(t, err)
+	assert.Equal(t, "abcdefghabcdefgh", string(out))
+}
+
+func TestRegistered(t *testing.T) {
+	var buf bytes.Buffer
+	f := codec.NewFramer(&buf, &buf).WithCompression(codec.CompressLZ4)
+	payload := bytes.Repeat([]byte("frame "), 500)
+	require.NoError(t, f.WriteFrame(payload))
+	assert.Less(t, buf.Len(), len(payload)/4)
+	p, err := f.ReadFrame()
+	require.NoError(t, err)
+	assert.Equal(t, payload, p)
+
+	buf.Reset()
+	w, err := codec.NewWriter(&buf)
+	require.NoError(t, err)
+	cw, err := codec.CompressWriter(w, "lz4")
+	require.NoError(t, err)
+	cw.WriteBytes(payload)
+	require.NoError(t, cw.Close())
+	require.NoError(t, w.Flush())
+
+	r, err := codec.NewReader(&buf)
+	require.NoError(t, err)
+	cr, err := codec.CompressReader(r, "lz4")
+	require.NoError(t, err)
+	out, err := io.ReadAll(cr)
+	require.NoError(t, err)
+	assert.Equal(t, payload, out)
+}
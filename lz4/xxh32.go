@@ -0,0 +1,95 @@
+package lz4
+
+import (
+	"encoding/binary"
+	"math/bits"
+)
+
+const (
+	prime1 uint32 = 2654435761
+	prime2 uint32 = 2246822519
+	prime3 uint32 = 3266489917
+	prime4 uint32 = 668265263
+	prime5 uint32 = 374761393
+)
+
+// xxh32 is the streaming XXH32 hash used by LZ4 frame checksums.
+type xxh32 struct {
+	v     [4]uint32
+	buf   [16]byte
+	n     int // bytes pending in buf
+	total uint64
+}
+
+func newXXH32() *xxh32 {
+	x := &xxh32{}
+	x.Reset()
+	return x
+}
+
+func (x *xxh32) Reset() {
+	var seed uint32
+	x.v = [4]uint32{seed + prime1 + prime2, seed + prime2, seed, seed - prime1}
+	x.n, x.total = 0, 0
+}
+
+func round(acc, in uint32) uint32 {
+	return bits.RotateLeft32(acc+in*prime2, 13) * prime1
+}
+
+func (x *xxh32) stripe(p []byte) {
+	for i := range x.v {
+		x.v[i] = round(x.v[i], binary.LittleEndian.Uint32(p[4*i:]))
+	}
+}
+
+func (x *xxh32) Write(p []byte) (int, error) {
+	n := len(p)
+	x.total += uint64(n)
+	if x.n > 0 {
+		k := copy(x.buf[x.n:], p)
+		x.n += k
+		p = p[k:]
+		if x.n < 16 {
+			return n, nil
+		}
+		x.stripe(x.buf[:])
+		x.n = 0
+	}
+	for ; len(p) >= 16; p = p[16:] {
+		x.stripe(p)
+	}
+	x.n = copy(x.buf[:], p)
+	return n, nil
+}
+
+func (x *xxh32) Sum32() uint32 {
+	var h uint32
+	if x.total >= 16 {
+		h = bits.RotateLeft32(x.v[0], 1) + bits.RotateLeft32(x.v[1], 7) +
+			bits.RotateLeft32(x.v[2], 12) + bits.RotateLeft32(x.v[3], 18)
+	} else {
+		h = x.v[2] + prime5 // v[2] holds the seed
+	}
+	h += uint32(x.total)
+	p := x.buf[:x.n]
+	for ; len(p) >= 4; p = p[4:] {
+		h = bits.RotateLeft32(h+binary.LittleEndian.Uint32(p)*prime3, 17) * prime4
+	}
+	for _, b := range p {
+		h = bits.RotateLeft32(h+uint32(b)*prime5, 11) * prime1
+	}
+	h ^= h >> 15
+	h *= prime2
+	h ^= h >> 13
+	h *= prime3
+	h ^= h >> 16
+	return h
+}
+
+// checksum32 returns the XXH32 of p with seed 0.
+func checksum32(p []byte) uint32 {
+	x := newXXH32()
+	x.Write(p)
+	return x.Sum32()
+}
@@ -0,0 +1,20 @@
+//go:build !tinygo
+
+package codec
+
+import "syscall"
+
+var adviceFlags = [...]int{
+	MapNormal:     syscall.MADV_NORMAL,
+	MapSequential: syscall.MADV_SEQUENTIAL,
+	MapRandom:     syscall.MADV_RANDOM,
+	MapWillNeed:   syscall.MADV_WILLNEED,
+	MapDontNeed:   syscall.MADV_DONTNEED,
+}
+
+func madvise(b []byte, advice MapAdvice) error {
+	if advice < 0 || int(advice) >= len(adviceFlags) {
+		return syscall.EINVAL
+	}
+	return syscall.Madvise(b, adviceFlags[advice])
+}
@@ -0,0 +1,5 @@
+//go:build !linux || tinygo
+
+package codec
+
+func madvise([]byte, MapAdvice) error { return nil }
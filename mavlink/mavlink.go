@@ -0,0 +1,334 @@
+// Package mavlink reads and writes MAVLink v1 and v2 frames, the telemetry
+// and command packets of drones and ground stations.
+//
+// A frame is a magic byte, a header giving the payload length, sequence
+// number, system and component ids and message id, the payload and a
+// CRC-16/MCRF4XX over everything after the magic. The CRC is finished with
+// the CRC extra of the message, a byte derived from its definition, so both
+// ends must agree on the message set: frames are read and written with a
+// CRCExtra table. A v2 frame may also carry a signature authenticating it
+// with a shared secret key.
+//
+// Decoding payloads is left to the caller. MAVLink 2 senders drop the
+// trailing zero bytes of payloads, see Truncate, and receivers extend them
+// back to their full size, see Extend.
+package mavlink
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"time"
+
+	"github.com/oy3o/codec"
+)
+
+const (
+	// MAGIC_V1 opens a MAVLink 1 frame.
+	MAGIC_V1 = 0xFE
+	// MAGIC_V2 opens a MAVLink 2 frame.
+	MAGIC_V2 = 0xFD
+	// MAX_PAYLOAD is the largest payload the length byte holds.
+	MAX_PAYLOAD = 255
+	// SIGNATURE_SIZE is the size of the signature of a v2 frame.
+	SIGNATURE_SIZE = 13
+	// MAX_MESSAGE_ID_V1 is the largest message id of a v1 frame.
+	MAX_MESSAGE_ID_V1 = 0xFF
+	// MAX_MESSAGE_ID_V2 is the largest message id of a v2 frame.
+	MAX_MESSAGE_ID_V2 = 0xFFFFFF
+)
+
+// FLAG_SIGNED is the incompatibility flag of a signed v2 frame, the only one
+// defined.
+const FLAG_SIGNED = 0x01
+
+var (
+	// ErrMagic indicates a frame that does not start with a magic byte.
+	ErrMagic = errors.New("mavlink: missing magic byte")
+
+	// ErrChecksum indicates a frame whose CRC does not match.
+	ErrChecksum = errors.New("mavlink: CRC mismatch")
+
+	// ErrUnknownMessage indicates a message id missing from the CRCExtra
+	// table, so its CRC cannot be computed.
+	ErrUnknownMessage = errors.New("mavlink: unknown message")
+
+	// ErrIncompatible indicates a v2 frame with incompatibility flags this
+	// package does not know, which receivers must drop.
+	ErrIncompatible = errors.New("mavlink: unknown incompatibility flags")
+
+	// ErrInvalidFrame indicates a frame whose fields do not fit its version.
+	ErrInvalidFrame = errors.New("mavlink: invalid frame")
+)
+
+// CRCExtra maps message ids to their CRC extra.
+type CRCExtra map[uint32]uint8
+
+// Common holds the CRC extras of frequent messages of the common message
+// set. Extend a copy with the rest of the dialect in use.
+var Common = CRCExtra{
+	0:  50,  // HEARTBEAT
+	1:  124, // SYS_STATUS
+	2:  137, // SYSTEM_TIME
+	4:  237, // PING
+	24: 24,  // GPS_RAW_INT
+	30: 39,  // ATTITUDE
+	33: 104, // GLOBAL_POSITION_INT
+	76: 152, // COMMAND_LONG
+	77: 143, // COMMAND_ACK
+}
+
+// Signature authenticates a v2 frame.
+type Signature struct {
+	LinkID    uint8
+	Timestamp uint64 // 48 bits, see SignatureTime
+	Value     [6]byte
+}
+
+// signatureEpoch is the origin of signature timestamps.
+var signatureEpoch = time.Date(2015, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// SignatureTime returns the signature timestamp of t: the number of 10
+// microsecond units since the start of 2015.
+func SignatureTime(t time.Time) uint64 {
+	return uint64(t.Sub(signatureEpoch) / (10 * time.Microsecond))
+}
+
+// Frame is a MAVLink frame.
+type Frame struct {
+	Version       int // 1 or 2
+	IncompatFlags uint8
+	CompatFlags   uint8
+	Sequence      uint8
+	SystemID      uint8
+	ComponentID   uint8
+	MessageID     uint32
+	Payload       []byte
+	Signature     *Signature // v2 only; sets FLAG_SIGNED when written
+}
+
+// Truncate drops the trailing zero bytes of a payload, keeping at least
+// one byte, as MAVLink 2 senders do.
+func Truncate(payload []byte) []byte {
+	n := len(payload)
+	for n > 1 && payload[n-1] == 0 {
+		n--
+	}
+	return payload[:n]
+}
+
+// Extend returns payload zero-extended to size bytes, the size of its
+// message, as MAVLink 2 receivers do. Longer payloads are returned as they
+// are.
+func Extend(payload []byte, size int) []byte {
+	if len(payload) >= size {
+		return payload
+	}
+	return append(bytes.Clone(payload), make([]byte, size-len(payload))...)
+}
+
+// appendHeader appends the header of f, after the magic byte.
+func (f *Frame) appendHeader(dst []byte) ([]byte, error) {
+	if len(f.Payload) > MAX_PAYLOAD {
+		return nil, fmt.Errorf("%w: payload of %d bytes", codec.ErrLengthOverflow, len(f.Payload))
+	}
+	switch f.Version {
+	case 1:
+		if f.MessageID > MAX_MESSAGE_ID_V1 || f.Signature != nil {
+			return nil, fmt.Errorf("%w: v1 frame with message %d or a signature", ErrInvalidFrame, f.MessageID)
+		}
+		return append(dst, byte(len(f.Payload)), f.Sequence, f.SystemID, f.ComponentID, byte(f.MessageID)), nil
+	case 2:
+		if f.MessageID > MAX_MESSAGE_ID_V2 {
+			return nil, fmt.Errorf("%w: message %d", ErrInvalidFrame, f.MessageID)
+		}
+		flags := f.IncompatFlags &^ FLAG_SIGNED
+		if f.Signature != nil {
+			flags |= FLAG_SIGNED
+		}
+		id := f.MessageID
+		return append(dst, byte(len(f.Payload)), flags, f.CompatFlags, f.Sequence, f.SystemID, f.ComponentID,
+			byte(id), byte(id>>8), byte(id>>16)), nil
+	}
+	return nil, fmt.Errorf("%w: version %d", ErrInvalidFrame, f.Version)
+}
+
+// checksum returns the CRC of a header, after the magic byte, and payload.
+func checksum(header, payload []byte, id uint32, extras CRCExtra) (uint16, error) {
+	extra, ok := extras[id]
+	if !ok {
+		return 0, fmt.Errorf("%w: %d", ErrUnknownMessage, id)
+	}
+	c := codec.NewCRC16MCRF4XX()
+	c.Write(header)
+	c.Write(payload)
+	c.Write([]byte{extra})
+	return codec.BE.Uint16(c.Sum(nil)), nil
+}
+
+// AppendFrame appends the encoding of f to dst, with the CRC extra of its
+// message from extras. The signature, if any, is written as it is; see Sign.
+func AppendFrame(dst []byte, f *Frame, extras CRCExtra) ([]byte, error) {
+	magic := byte(MAGIC_V1)
+	if f.Version == 2 {
+		magic = MAGIC_V2
+	}
+	start := len(dst) + 1
+	dst, err := f.appendHeader(append(dst, magic))
+	if err != nil {
+		return nil, err
+	}
+	crc, err := checksum(dst[start:], f.Payload, f.MessageID, extras)
+	if err != nil {
+		return nil, err
+	}
+	dst = append(dst, f.Payload...)
+	dst = codec.LE.AppendUint16(dst, crc)
+	if s := f.Signature; s != nil {
+		dst = append(dst, s.LinkID)
+		dst = appendUint48(dst, s.Timestamp)
+		dst = append(dst, s.Value[:]...)
+	}
+	return dst, nil
+}
+
+func appendUint48(dst []byte, v uint64) []byte {
+	return append(dst, byte(v), byte(v>>8), byte(v>>16), byte(v>>24), byte(v>>32), byte(v>>40))
+}
+
+// WriteFrame writes f, with the CRC extra of its message from extras.
+func WriteFrame(w *codec.Writer, f *Frame, extras CRCExtra) error {
+	b, err := AppendFrame(nil, f, extras)
+	if err != nil {
+		return err
+	}
+	w.WriteBytes(b)
+	return w.Err()
+}
+
+// signature computes the signature of f for key, link and timestamp: the
+// first 6 bytes of the SHA-256 of the key, the frame up to its CRC, the link
+// id and the timestamp.
+func (f *Frame) signature(key []byte, extras CRCExtra, link uint8, timestamp uint64) ([6]byte, error) {
+	g := *f
+	g.Signature = &Signature{} // sets FLAG_SIGNED in the header
+	b, err := AppendFrame(nil, &g, extras)
+	if err != nil {
+		return [6]byte{}, err
+	}
+	h := sha256.New()
+	h.Write(key)
+	h.Write(b[:len(b)-SIGNATURE_SIZE])
+	h.Write(appendUint48([]byte{link}, timestamp))
+	var v [6]byte
+	copy(v[:], h.Sum(nil))
+	return v, nil
+}
+
+// Sign signs a v2 frame with the 32-byte secret key of the link.
+func (f *Frame) Sign(key []byte, extras CRCExtra, link uint8, timestamp uint64) error {
+	if f.Version != 2 {
+		return fmt.Errorf("%w: signing a v%d frame", ErrInvalidFrame, f.Version)
+	}
+	v, err := f.signature(key, extras, link, timestamp)
+	if err != nil {
+		return err
+	}
+	f.Signature = &Signature{LinkID: link, Timestamp: timestamp, Value: v}
+	return nil
+}
+
+// Verify reports whether f carries a valid signature for key. Checking that
+// timestamps increase, against replays, is left to the caller.
+func (f *Frame) Verify(key []byte, extras CRCExtra) bool {
+	if f.Version != 2 || f.Signature == nil {
+		return false
+	}
+	v, err := f.signature(key, extras, f.Signature.LinkID, f.Signature.Timestamp)
+	return err == nil && v == f.Signature.Value
+}
+
+// ReadFrame reads a frame and verifies its CRC with the CRC extra of its
+// message from extras. A Reader at the end of the stream returns io.EOF.
+//
+// A frame whose message is missing from extras is returned with
+// ErrUnknownMessage and its CRC unchecked, so routers can still forward it.
+func ReadFrame(r *codec.Reader, extras CRCExtra) (*Frame, error) {
+	var magic [1]byte
+	r.ReadBytesTo(magic[:])
+	if err := r.Err(); err != nil {
+		return nil, err
+	}
+	f := new(Frame)
+	var header []byte
+	switch magic[0] {
+	case MAGIC_V1:
+		f.Version, header = 1, make([]byte, 5)
+	case MAGIC_V2:
+		f.Version, header = 2, make([]byte, 9)
+	default:
+		return nil, fmt.Errorf("%w: 0x%02x", ErrMagic, magic[0])
+	}
+	r.ReadBytesTo(header)
+	if err := r.Err(); err != nil {
+		return nil, unexpected(err)
+	}
+	if f.Version == 1 {
+		f.Sequence, f.SystemID, f.ComponentID, f.MessageID = header[1], header[2], header[3], uint32(header[4])
+	} else {
+		f.IncompatFlags, f.CompatFlags, f.Sequence, f.SystemID, f.ComponentID = header[1], header[2], header[3], header[4], header[5]
+		f.MessageID = uint32(header[6]) | uint32(header[7])<<8 | uint32(header[8])<<16
+	}
+	f.Payload = r.ReadBytes(int(header[0]))
+	var crc [2]byte
+	r.ReadBytesTo(crc[:])
+	var sig [SIGNATURE_SIZE]byte
+	if f.IncompatFlags&FLAG_SIGNED != 0 {
+		r.ReadBytesTo(sig[:])
+	}
+	if err := r.Err(); err != nil {
+		return nil, unexpected(err)
+	}
+	if f.IncompatFlags&FLAG_SIGNED != 0 {
+		f.Signature = &Signature{LinkID: sig[0], Timestamp: uint64(sig[1]) | uint64(sig[2])<<8 | uint64(sig[3])<<16 |
+			uint64(sig[4])<<24 | uint64(sig[5])<<32 | uint64(sig[6])<<40}
+		copy(f.Signature.Value[:], sig[7:])
+	}
+	if f.IncompatFlags&^FLAG_SIGNED != 0 {
+		return nil, fmt.Errorf("%w: 0x%02x", ErrIncompatible, f.IncompatFlags)
+	}
+	want, err := checksum(header, f.Payload, f.MessageID, extras)
+	if err != nil {
+		return f, err
+	}
+	if got := codec.LE.Uint16(crc[:]); got != want {
+		return nil, fmt.Errorf("%w: 0x%04x, computed 0x%04x", ErrChecksum, got, want)
+	}
+	return f, nil
+}
+
+// Frames iterates over the frames of r up to the end of the stream,
+// stopping after the first error.
+func Frames(r *codec.Reader, extras CRCExtra) iter.Seq2[*Frame, error] {
+	return func(yield func(*Frame, error) bool) {
+		for {
+			f, err := ReadFrame(r, extras)
+			if err == io.EOF {
+				return
+			}
+			if !yield(f, err) || err != nil {
+				return
+			}
+		}
+	}
+}
+
+func unexpected(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
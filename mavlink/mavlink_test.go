@@ -0,0 +1,81 @@
+//go:build test
+
+package mavlink
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/oy3o/codec"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// heartbeat is the payload of a HEARTBEAT from a quadrotor autopilot.
+var heartbeat = []byte{0, 0, 0, 0, 2, 3, 0x51, 4, 3}
+
+func TestFrames(t *testing.T) {
+	v1 := &Frame{Version: 1, Sequence: 7, SystemID: 1, ComponentID: 1, Payload: heartbeat}
+	v2 := &Frame{Version: 2, Sequence: 8, SystemID: 1, ComponentID: 1, MessageID: 33, Payload: Truncate([]byte{1, 2, 3, 0, 0, 0})}
+	var buf bytes.Buffer
+	w, _ := codec.NewWriter(&buf)
+	require.NoError(t, WriteFrame(w, v1, Common))
+	require.NoError(t, WriteFrame(w, v2, Common))
+	require.NoError(t, w.Flush())
+	assert.Equal(t, []byte{MAGIC_V1, 9, 7, 1, 1, 0}, buf.Bytes()[:6])
+	assert.Equal(t, []byte{MAGIC_V2, 3, 0, 0, 8, 1, 1, 33, 0, 0, 1, 2, 3}, buf.Bytes()[17:30])
+
+	r, _ := codec.NewReader(bytes.NewReader(buf.Bytes()))
+	var got []*Frame
+	for f, err := range Frames(r, Common) {
+		require.NoError(t, err)
+		got = append(got, f)
+	}
+	require.Len(t, got, 2)
+	assert.Equal(t, v1, got[0])
+	assert.Equal(t, v2, got[1])
+	assert.Equal(t, []byte{1, 2, 3, 0, 0, 0, 0, 0}, Extend(got[1].Payload, 8))
+
+	bad := bytes.Clone(buf.Bytes())
+	bad[8] ^= 1
+	r, _ = codec.NewReader(bytes.NewReader(bad))
+	_, err := ReadFrame(r, Common)
+	assert.ErrorIs(t, err, ErrChecksum)
+
+	r, _ = codec.NewReader(bytes.NewReader(buf.Bytes()))
+	f, err := ReadFrame(r, CRCExtra{})
+	assert.ErrorIs(t, err, ErrUnknownMessage)
+	assert.Equal(t, v1, f)
+
+	r, _ = codec.NewReader(bytes.NewReader(buf.Bytes()[:10]))
+	_, err = ReadFrame(r, Common)
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+
+	_, err = AppendFrame(nil, &Frame{Version: 1, MessageID: 300}, Common)
+	assert.ErrorIs(t, err, ErrInvalidFrame)
+}
+
+func TestSignature(t *testing.T) {
+	key := bytes.Repeat([]byte{0x42}, 32)
+	f := &Frame{Version: 2, SystemID: 1, ComponentID: 1, Payload: Truncate(heartbeat)}
+	ts := SignatureTime(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	require.NoError(t, f.Sign(key, Common, 5, ts))
+
+	b, err := AppendFrame(nil, f, Common)
+	require.NoError(t, err)
+	assert.Equal(t, byte(FLAG_SIGNED), b[2])
+	assert.Len(t, b, 10+len(f.Payload)+2+SIGNATURE_SIZE)
+
+	r, _ := codec.NewReader(bytes.NewReader(b))
+	got, err := ReadFrame(r, Common)
+	require.NoError(t, err)
+	assert.Equal(t, ts, got.Signature.Timestamp)
+	assert.True(t, got.Verify(key, Common))
+	assert.False(t, got.Verify(bytes.Repeat([]byte{0x43}, 32), Common))
+	got.Sequence++
+	assert.False(t, got.Verify(key, Common))
+
+	assert.ErrorIs(t, (&Frame{Version: 1}).Sign(key, Common, 0, ts), ErrInvalidFrame)
+}
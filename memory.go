@@ -0,0 +1,90 @@
+package codec
+
+import "sync/atomic"
+
+// MemoryAccount tracks the buffer memory held by the codec components of one
+// stream, such as the buffers of its Reader and Writer, the frame buffer of a
+// Framer or the pending bytes of a DecoderWriter. Accounts form a tree: bytes
+// charged to a per-connection account are also charged to its parent, so a
+// service can budget and alert on the codec-layer memory of all connections.
+//
+// Components charge an account as their buffers grow. Since they do not know
+// when the stream ends, the owner calls Close to release what the stream held.
+// A nil *MemoryAccount is valid and records nothing.
+type MemoryAccount struct {
+	parent *MemoryAccount
+	cur    atomic.Int64
+	peak   atomic.Int64
+}
+
+// NewMemoryAccount returns an account reporting to parent, which may be nil.
+func NewMemoryAccount(parent *MemoryAccount) *MemoryAccount {
+	return &MemoryAccount{parent: parent}
+}
+
+// Add charges n bytes, or releases them if n is negative.
+func (a *MemoryAccount) Add(n int64) {
+	for ; a != nil; a = a.parent {
+		cur := a.cur.Add(n)
+		for {
+			peak := a.peak.Load()
+			if cur <= peak || a.peak.CompareAndSwap(peak, cur) {
+				break
+			}
+		}
+	}
+}
+
+// Current returns the bytes currently held.
+func (a *MemoryAccount) Current() int64 {
+	if a == nil {
+		return 0
+	}
+	return a.cur.Load()
+}
+
+// Peak returns the high-water mark of Current.
+func (a *MemoryAccount) Peak() int64 {
+	if a == nil {
+		return 0
+	}
+	return a.peak.Load()
+}
+
+// ResetPeak lowers the high-water mark to the current usage, starting a new
+// reporting interval.
+func (a *MemoryAccount) ResetPeak() {
+	if a != nil {
+		a.peak.Store(a.cur.Load())
+	}
+}
+
+// Close releases everything still charged to the account, including from its
+// parents. The peak is kept for reporting.
+func (a *MemoryAccount) Close() {
+	if a != nil {
+		a.Add(-a.cur.Load())
+	}
+}
+
+// grow charges the growth of a buffer from capacity before to after.
+func (a *MemoryAccount) grow(before, after int) {
+	if a != nil && after != before {
+		a.Add(int64(after - before))
+	}
+}
+
+// WithAccount charges the buffer allocated by the Reader, if any, to a and
+// returns the Reader for chaining. Readers over in-memory sources or existing
+// buffers allocate none.
+func (r *Reader) WithAccount(a *MemoryAccount) *Reader {
+	a.grow(0, r.held)
+	return r
+}
+
+// WithAccount charges the buffer allocated by the Writer, if any, to a and
+// returns the Writer for chaining.
+func (w *Writer) WithAccount(a *MemoryAccount) *Writer {
+	a.grow(0, w.held)
+	return w
+}
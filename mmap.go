@@ -0,0 +1,69 @@
+package codec
+
+import (
+	"fmt"
+	"os"
+)
+
+// MapAdvice hints how a mapped file will be read, so the kernel can tune
+// read-ahead and page reclaim.
+type MapAdvice int
+
+const (
+	MapNormal     MapAdvice = iota // default read-ahead
+	MapSequential                  // read ahead aggressively, drop pages soon after use
+	MapRandom                      // do not read ahead
+	MapWillNeed                    // read the pages now
+	MapDontNeed                    // the pages may be dropped
+)
+
+// MapFile maps the file name read-only into memory and returns a
+// BytesReader over it, so a Reader can decode files far larger than the
+// heap through the zero-copy BytesReader paths. Pages are loaded on demand
+// and shared with the page cache; the mapping starts with MapSequential
+// advice. Close the BytesReader, or a Reader over it, to unmap the file.
+// Slices returned by Next and Peek, and values decoded from them without
+// copying, point into the mapping: touching them after Close crashes the
+// program, so copy what must outlive it.
+//
+// The file must not be truncated while mapped: reading pages past its new
+// end crashes the program. On platforms without mmap, the file is read into
+// memory instead.
+func MapFile(name string) (*BytesReader, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	size := info.Size()
+	if size == 0 {
+		return NewBytesReader(nil), nil
+	}
+	if int64(int(size)) != size {
+		return nil, fmt.Errorf("%w: file of %d bytes", ErrLengthOverflow, size)
+	}
+	b, mapped, err := mapFile(f, int(size))
+	if err != nil {
+		return nil, err
+	}
+	r := NewBytesReader(b)
+	if mapped {
+		r.mapped = b
+		r.Advise(MapSequential)
+	}
+	return r, nil
+}
+
+// Advise passes a hint about the coming reads to the mapping of a
+// BytesReader returned by MapFile. Hints are only advisory: Advise does
+// nothing on platforms without madvise, nor for BytesReaders over the heap.
+func (r *BytesReader) Advise(advice MapAdvice) error {
+	if r.mapped == nil {
+		return nil
+	}
+	return madvise(r.mapped, advice)
+}
@@ -0,0 +1,19 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd || dragonfly || solaris) || tinygo
+
+package codec
+
+import (
+	"io"
+	"os"
+)
+
+// mapFile reads the file into memory where mmap is not available.
+func mapFile(f *os.File, size int) ([]byte, bool, error) {
+	b := make([]byte, size)
+	if _, err := io.ReadFull(f, b); err != nil {
+		return nil, false, err
+	}
+	return b, false, nil
+}
+
+func munmap([]byte) error { return nil }
@@ -0,0 +1,18 @@
+//go:build (linux || darwin || freebsd || netbsd || openbsd || dragonfly || solaris) && !tinygo
+
+package codec
+
+import (
+	"os"
+	"syscall"
+)
+
+func mapFile(f *os.File, size int) ([]byte, bool, error) {
+	b, err := syscall.Mmap(int(f.Fd()), 0, size, syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, false, &os.PathError{Op: "mmap", Path: f.Name(), Err: err}
+	}
+	return b, true, nil
+}
+
+func munmap(b []byte) error { return syscall.Munmap(b) }
@@ -0,0 +1,53 @@
+//go:build test && (tinygo || codec_tiny)
+
+package codec
+
+import "io"
+
+// mockCodec is a hand-written Codec for mockPayload, standing in for
+// Fixed[mockPayload] when the reflection-based codecs are excluded.
+type mockCodec struct {
+	Payload mockPayload
+}
+
+func (c *mockCodec) Size() int { return 8 }
+
+func (c *mockCodec) MarshalTo(p []byte) (int, error) {
+	if len(p) < 8 {
+		return 0, io.ErrShortWrite
+	}
+	Order.PutUint32(p, c.Payload.ID)
+	copy(p[4:8], c.Payload.Data[:])
+	return 8, nil
+}
+
+func (c *mockCodec) MarshalBinary() ([]byte, error) {
+	buf := make([]byte, 8)
+	_, err := c.MarshalTo(buf)
+	return buf, err
+}
+
+func (c *mockCodec) UnmarshalBinary(data []byte) error {
+	if len(data) < 8 {
+		return ErrTruncatedData
+	}
+	c.Payload.ID = Order.Uint32(data)
+	copy(c.Payload.Data[:], data[4:8])
+	return CheckBufferNotZeros(data[8:])
+}
+
+func (c *mockCodec) WriteTo(w io.Writer) (int64, error) {
+	return WriteToGeneric(c, w)
+}
+
+func (c *mockCodec) ReadFrom(r io.Reader) (int64, error) {
+	var buf [8]byte
+	if _, err := io.ReadFull(r, buf[:]); err != nil {
+		return 0, err
+	}
+	return 8, c.UnmarshalBinary(buf[:])
+}
+
+func (c *mockCodec) MarshalAppend(dst []byte) ([]byte, error) {
+	return MarshalAppendGeneric(c, dst)
+}
@@ -0,0 +1,139 @@
+// Package moldudp64 parses and builds the datagrams of MoldUDP64, the
+// session protocol carrying sequenced messages such as ITCH over UDP
+// multicast. A downstream packet holds a header naming the session, the
+// sequence number of its first message and a message count, followed by
+// that many messages each prefixed by a big-endian uint16 length. Lost
+// messages are requested again with a request packet of the header alone.
+//
+// Packets are parsed from and appended to datagram buffers; parsed
+// messages alias the datagram, so no message is copied.
+package moldudp64
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/oy3o/codec"
+)
+
+const (
+	// HEADER_SIZE is the size of the packet header.
+	HEADER_SIZE = 20
+	// SESSION_SIZE is the size of the session name.
+	SESSION_SIZE = 10
+	// HEARTBEAT is the message count of a heartbeat packet.
+	HEARTBEAT = 0
+	// END_OF_SESSION is the message count of the packet ending a session.
+	END_OF_SESSION = 0xFFFF
+	// MAX_PACKET_SIZE bounds a packet to what a UDP datagram carries.
+	MAX_PACKET_SIZE = math.MaxUint16 - 8
+)
+
+// ErrInvalidPacket indicates a packet shorter than its header or the
+// messages it declares.
+var ErrInvalidPacket = errors.New("moldudp64: invalid packet")
+
+// Header opens every packet. In a request packet, Count is the number of
+// messages requested from Sequence on.
+type Header struct {
+	Session  [SESSION_SIZE]byte
+	Sequence uint64
+	Count    uint16
+}
+
+// SessionName returns the session name without its padding.
+func (h *Header) SessionName() string { return strings.TrimRight(string(h.Session[:]), " ") }
+
+// SetSessionName sets the session name, left-justified and padded with
+// spaces.
+func (h *Header) SetSessionName(s string) {
+	n := copy(h.Session[:], s)
+	for i := n; i < SESSION_SIZE; i++ {
+		h.Session[i] = ' '
+	}
+}
+
+// Heartbeat reports whether the packet is a heartbeat.
+func (h *Header) Heartbeat() bool { return h.Count == HEARTBEAT }
+
+// EndOfSession reports whether the packet ends the session.
+func (h *Header) EndOfSession() bool { return h.Count == END_OF_SESSION }
+
+// Packet is a downstream packet.
+type Packet struct {
+	Header
+	Messages [][]byte
+}
+
+// ParseHeader parses the header of a packet.
+func ParseHeader(b []byte) (Header, error) {
+	var h Header
+	if len(b) < HEADER_SIZE {
+		return h, fmt.Errorf("%w: %d bytes", ErrInvalidPacket, len(b))
+	}
+	copy(h.Session[:], b)
+	h.Sequence = codec.BE.Uint64(b[10:])
+	h.Count = codec.BE.Uint16(b[18:])
+	return h, nil
+}
+
+// AppendHeader appends the encoding of h to dst; it is also the whole of a
+// request packet.
+func AppendHeader(dst []byte, h Header) []byte {
+	dst = append(dst, h.Session[:]...)
+	dst = codec.BE.AppendUint64(dst, h.Sequence)
+	return codec.BE.AppendUint16(dst, h.Count)
+}
+
+// ParsePacket parses a downstream packet. The messages alias b.
+func ParsePacket(b []byte) (*Packet, error) {
+	h, err := ParseHeader(b)
+	if err != nil {
+		return nil, err
+	}
+	p := &Packet{Header: h}
+	n := int(h.Count)
+	if h.EndOfSession() {
+		n = 0
+	}
+	b = b[HEADER_SIZE:]
+	for i := range n {
+		if len(b) < 2 {
+			return nil, fmt.Errorf("%w: message %d of %d missing", ErrInvalidPacket, i, n)
+		}
+		size := int(codec.BE.Uint16(b))
+		if len(b) < 2+size {
+			return nil, fmt.Errorf("%w: message %d of %d bytes truncated", ErrInvalidPacket, i, size)
+		}
+		p.Messages = append(p.Messages, b[2:2+size])
+		b = b[2+size:]
+	}
+	return p, nil
+}
+
+// AppendPacket appends the encoding of p to dst, setting the message count
+// from p.Messages unless p ends the session.
+func AppendPacket(dst []byte, p *Packet) ([]byte, error) {
+	h := p.Header
+	if !h.EndOfSession() {
+		if len(p.Messages) >= END_OF_SESSION {
+			return nil, fmt.Errorf("%w: %d messages", codec.ErrLengthOverflow, len(p.Messages))
+		}
+		h.Count = uint16(len(p.Messages))
+	}
+	start := len(dst)
+	dst = AppendHeader(dst, h)
+	for _, m := range p.Messages {
+		if len(m) > math.MaxUint16 {
+			return nil, fmt.Errorf("%w: message of %d bytes", codec.ErrLengthOverflow, len(m))
+		}
+		dst = codec.BE.AppendUint16(dst, uint16(len(m)))
+		dst = append(dst, m...)
+	}
+	if len(dst)-start > MAX_PACKET_SIZE {
+		return nil, fmt.Errorf("%w: packet of %d bytes", codec.ErrLengthOverflow, len(dst)-start)
+	}
+	return dst, nil
+}
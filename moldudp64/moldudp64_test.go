@@ -0,0 +1,43 @@
+//go:build test
+
+package moldudp64
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPacket(t *testing.T) {
+	p := &Packet{Header: Header{Sequence: 1000}, Messages: [][]byte{[]byte("first"), {}, []byte("third")}}
+	p.SetSessionName("SESSION1")
+	b, err := AppendPacket(nil, p)
+	require.NoError(t, err)
+	assert.Len(t, b, HEADER_SIZE+2+5+2+2+5)
+	assert.Equal(t, "SESSION1  ", string(b[:SESSION_SIZE]))
+
+	got, err := ParsePacket(b)
+	require.NoError(t, err)
+	assert.Equal(t, "SESSION1", got.SessionName())
+	assert.Equal(t, uint64(1000), got.Sequence)
+	assert.Equal(t, uint16(3), got.Count)
+	assert.Equal(t, []byte("third"), got.Messages[2])
+
+	_, err = ParsePacket(b[:len(b)-1])
+	assert.ErrorIs(t, err, ErrInvalidPacket)
+	_, err = ParsePacket(b[:HEADER_SIZE-1])
+	assert.ErrorIs(t, err, ErrInvalidPacket)
+
+	hb, err := ParsePacket(AppendHeader(nil, Header{Sequence: 1003}))
+	require.NoError(t, err)
+	assert.True(t, hb.Heartbeat())
+
+	end := &Packet{Header: Header{Sequence: 1003, Count: END_OF_SESSION}}
+	b, err = AppendPacket(nil, end)
+	require.NoError(t, err)
+	got, err = ParsePacket(b)
+	require.NoError(t, err)
+	assert.True(t, got.EndOfSession())
+	assert.Empty(t, got.Messages)
+}
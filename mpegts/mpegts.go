@@ -0,0 +1,313 @@
+// Package mpegts reads and writes MPEG transport stream packets: the
+// 188-byte packets of broadcast and HLS streams, with their header,
+// adaptation field and payload. Reassembling PES packets and sections from
+// the payloads is left to the caller.
+package mpegts
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/oy3o/codec"
+)
+
+const (
+	// PACKET_SIZE is the size of a transport stream packet.
+	PACKET_SIZE = 188
+	// SYNC_BYTE starts every packet.
+	SYNC_BYTE = 0x47
+
+	// PID_PAT carries the program association table.
+	PID_PAT = 0x0000
+	// PID_NULL carries stuffing packets.
+	PID_NULL = 0x1FFF
+
+	headerSize = 4
+)
+
+var (
+	// ErrSync indicates a packet that does not start with SYNC_BYTE.
+	ErrSync = errors.New("mpegts: lost sync")
+
+	// ErrInvalidPacket indicates an adaptation field that does not fit its
+	// packet.
+	ErrInvalidPacket = errors.New("mpegts: invalid packet")
+
+	// ErrContinuity indicates a packet whose continuity counter does not
+	// follow the previous packet of its PID, meaning packets were lost.
+	ErrContinuity = errors.New("mpegts: continuity counter mismatch")
+)
+
+// Packet is a transport stream packet.
+type Packet struct {
+	TransportError    bool
+	PayloadStart      bool // the payload starts a PES packet or section
+	Priority          bool
+	PID               uint16
+	Scrambling        uint8 // 2 bits
+	ContinuityCounter uint8 // 4 bits
+	Adaptation        *AdaptationField
+	Payload           []byte // nil if the packet has no payload
+}
+
+// AdaptationField carries timing and stuffing. Stuffing is computed by
+// WritePacket and not set by the caller.
+type AdaptationField struct {
+	Discontinuity   bool
+	RandomAccess    bool
+	ESPriority      bool
+	HasPCR          bool
+	PCR             uint64 // 27 MHz program clock reference
+	HasOPCR         bool
+	OPCR            uint64
+	HasSplice       bool
+	SpliceCountdown int8
+	Private         []byte // transport private data
+	Extension       []byte // adaptation field extension, after its length
+	Stuffing        int    // 0xFF bytes ending the field
+}
+
+// size returns the encoded size of the field, without stuffing or the
+// length byte.
+func (a *AdaptationField) size() int {
+	n := 1
+	if a.HasPCR {
+		n += 6
+	}
+	if a.HasOPCR {
+		n += 6
+	}
+	if a.HasSplice {
+		n++
+	}
+	if a.Private != nil {
+		n += 1 + len(a.Private)
+	}
+	if a.Extension != nil {
+		n += 1 + len(a.Extension)
+	}
+	return n
+}
+
+func unexpected(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// ParsePacket parses a packet of PACKET_SIZE bytes.
+func ParsePacket(b []byte, p *Packet) error {
+	if len(b) != PACKET_SIZE {
+		return fmt.Errorf("%w: %d bytes", ErrInvalidPacket, len(b))
+	}
+	if b[0] != SYNC_BYTE {
+		return fmt.Errorf("%w: %#02x", ErrSync, b[0])
+	}
+	r, _ := codec.NewReader(bytes.NewReader(b[1:]))
+	return readPacket(r, p)
+}
+
+// readPacket reads a packet after its sync byte.
+func readPacket(r *codec.Reader, p *Packet) error {
+	br := codec.NewBitReader(r, codec.MSBFirst)
+	p.TransportError = br.ReadBool()
+	p.PayloadStart = br.ReadBool()
+	p.Priority = br.ReadBool()
+	p.PID = uint16(br.ReadBits(13))
+	p.Scrambling = uint8(br.ReadBits(2))
+	control := br.ReadBits(2)
+	p.ContinuityCounter = uint8(br.ReadBits(4))
+	if err := r.Err(); err != nil {
+		return unexpected(err)
+	}
+	left := PACKET_SIZE - headerSize
+	p.Adaptation = nil
+	if control&2 != 0 {
+		var length uint8
+		r.ReadUint8(&length)
+		if err := r.Err(); err != nil {
+			return unexpected(err)
+		}
+		max := left - 1
+		if control&1 != 0 {
+			max-- // room for at least one payload byte
+		}
+		if int(length) > max {
+			return fmt.Errorf("%w: adaptation field of %d bytes", ErrInvalidPacket, length)
+		}
+		field := make([]byte, length)
+		r.ReadBytesTo(field)
+		if err := r.Err(); err != nil {
+			return unexpected(err)
+		}
+		left -= 1 + int(length)
+		if length > 0 {
+			a, err := parseAdaptation(field)
+			if err != nil {
+				return err
+			}
+			p.Adaptation = a
+		} else {
+			p.Adaptation = &AdaptationField{}
+		}
+	}
+	p.Payload = nil
+	if control&1 != 0 {
+		p.Payload = make([]byte, left)
+		r.ReadBytesTo(p.Payload)
+	} else {
+		codec.Discard(r, int64(left))
+	}
+	return unexpected(r.Err())
+}
+
+func parseAdaptation(field []byte) (*AdaptationField, error) {
+	r, _ := codec.NewReader(bytes.NewReader(field))
+	br := codec.NewBitReader(r, codec.MSBFirst)
+	a := &AdaptationField{}
+	a.Discontinuity = br.ReadBool()
+	a.RandomAccess = br.ReadBool()
+	a.ESPriority = br.ReadBool()
+	a.HasPCR = br.ReadBool()
+	a.HasOPCR = br.ReadBool()
+	a.HasSplice = br.ReadBool()
+	private := br.ReadBool()
+	extension := br.ReadBool()
+	readPCR := func() uint64 {
+		base := br.ReadBits(33)
+		br.ReadBits(6) // reserved
+		return base*300 + br.ReadBits(9)
+	}
+	if a.HasPCR {
+		a.PCR = readPCR()
+	}
+	if a.HasOPCR {
+		a.OPCR = readPCR()
+	}
+	if a.HasSplice {
+		a.SpliceCountdown = int8(br.ReadBits(8))
+	}
+	if private {
+		a.Private = r.ReadBytes(int(br.ReadBits(8)))
+		if a.Private == nil {
+			a.Private = []byte{}
+		}
+	}
+	if extension {
+		a.Extension = r.ReadBytes(int(br.ReadBits(8)))
+		if a.Extension == nil {
+			a.Extension = []byte{}
+		}
+	}
+	if err := r.Err(); err != nil {
+		return nil, fmt.Errorf("%w: adaptation field: %w", ErrInvalidPacket, unexpected(err))
+	}
+	a.Stuffing = len(field) - int(r.Count())
+	return a, nil
+}
+
+// AppendPacket appends p to dst as PACKET_SIZE bytes, filling the space the
+// payload leaves with adaptation field stuffing.
+func AppendPacket(dst []byte, p *Packet) ([]byte, error) {
+	room := PACKET_SIZE - headerSize
+	a := p.Adaptation
+	if a != nil {
+		room -= 1 + a.size()
+	}
+	if a != nil && (len(a.Private) > 255 || len(a.Extension) > 255) {
+		return dst, fmt.Errorf("%w: adaptation field data over 255 bytes", codec.ErrLengthOverflow)
+	}
+	if len(p.Payload) > room {
+		return dst, fmt.Errorf("%w: payload of %d bytes, room for %d", codec.ErrLengthOverflow, len(p.Payload), room)
+	}
+	stuffing := room - len(p.Payload)
+	if p.Payload == nil && a == nil || stuffing > 0 && a == nil {
+		a = &AdaptationField{}
+		if stuffing == 1 {
+			// A lone length byte of 0 fills a single byte.
+			stuffing = -1
+		} else {
+			stuffing -= 2
+		}
+	}
+	var buf bytes.Buffer
+	w, _ := codec.NewWriter(&buf)
+	bw := codec.NewBitWriter(w, codec.MSBFirst)
+	bw.WriteBits(SYNC_BYTE, 8)
+	bw.WriteBool(p.TransportError)
+	bw.WriteBool(p.PayloadStart)
+	bw.WriteBool(p.Priority)
+	bw.WriteBits(uint64(p.PID), 13)
+	bw.WriteBits(uint64(p.Scrambling), 2)
+	var control uint64
+	if a != nil {
+		control |= 2
+	}
+	if p.Payload != nil {
+		control |= 1
+	}
+	bw.WriteBits(control, 2)
+	bw.WriteBits(uint64(p.ContinuityCounter), 4)
+	if a != nil {
+		if stuffing < 0 {
+			bw.WriteBits(0, 8)
+		} else {
+			bw.WriteBits(uint64(a.size()+stuffing), 8)
+			writeAdaptation(bw, a)
+			for range stuffing {
+				bw.WriteBits(0xFF, 8)
+			}
+		}
+	}
+	w.WriteBytes(p.Payload)
+	if err := w.Flush(); err != nil {
+		return dst, err
+	}
+	return append(dst, buf.Bytes()...), nil
+}
+
+func writeAdaptation(bw *codec.BitWriter, a *AdaptationField) {
+	bw.WriteBool(a.Discontinuity)
+	bw.WriteBool(a.RandomAccess)
+	bw.WriteBool(a.ESPriority)
+	bw.WriteBool(a.HasPCR)
+	bw.WriteBool(a.HasOPCR)
+	bw.WriteBool(a.HasSplice)
+	bw.WriteBool(a.Private != nil)
+	bw.WriteBool(a.Extension != nil)
+	writePCR := func(pcr uint64) {
+		bw.WriteBits(pcr/300, 33)
+		bw.WriteBits(0x3F, 6)
+		bw.WriteBits(pcr%300, 9)
+	}
+	if a.HasPCR {
+		writePCR(a.PCR)
+	}
+	if a.HasOPCR {
+		writePCR(a.OPCR)
+	}
+	if a.HasSplice {
+		bw.WriteBits(uint64(uint8(a.SpliceCountdown)), 8)
+	}
+	for _, b := range [][]byte{a.Private, a.Extension} {
+		if b != nil {
+			bw.WriteBits(uint64(len(b)), 8)
+			for _, c := range b {
+				bw.WriteBits(uint64(c), 8)
+			}
+		}
+	}
+}
+
+// WritePacket writes p as PACKET_SIZE bytes. See AppendPacket.
+func WritePacket(w *codec.Writer, p *Packet) error {
+	b, err := AppendPacket(make([]byte, 0, PACKET_SIZE), p)
+	if err != nil {
+		return err
+	}
+	w.WriteBytes(b)
+	return w.Err()
+}
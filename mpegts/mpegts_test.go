@@ -0,0 +1,109 @@
+//go:build test
+
+package mpegts
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/oy3o/codec"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPacket(t *testing.T) {
+	p := &Packet{
+		PayloadStart:      true,
+		PID:               0x100,
+		ContinuityCounter: 7,
+		Adaptation:        &AdaptationField{RandomAccess: true, HasPCR: true, PCR: 27_000_000*10 + 123, Private: []byte{1, 2}},
+		Payload:           []byte("pes"),
+	}
+	b, err := AppendPacket(nil, p)
+	require.NoError(t, err)
+	require.Len(t, b, PACKET_SIZE)
+	assert.Equal(t, []byte{SYNC_BYTE, 0x41, 0x00, 0x37, 184 - 3 - 1}, b[:5])
+	assert.Equal(t, "pes", string(b[PACKET_SIZE-3:]))
+
+	var got Packet
+	require.NoError(t, ParsePacket(b, &got))
+	p.Adaptation.Stuffing = 184 - 1 - 3 - p.Adaptation.size()
+	assert.Equal(t, p, &got)
+
+	// Short payloads are padded with a one byte, then a stuffed, field.
+	for _, n := range []int{183, 100} {
+		b, err := AppendPacket(nil, &Packet{PID: 1, Payload: make([]byte, n)})
+		require.NoError(t, err)
+		require.Len(t, b, PACKET_SIZE)
+		require.NoError(t, ParsePacket(b, &got))
+		assert.Len(t, got.Payload, n)
+	}
+	b, err = AppendPacket(nil, &Packet{PID: PID_NULL})
+	require.NoError(t, err)
+	require.NoError(t, ParsePacket(b, &got))
+	assert.Nil(t, got.Payload)
+
+	_, err = AppendPacket(nil, &Packet{Payload: make([]byte, 185)})
+	assert.ErrorIs(t, err, codec.ErrLengthOverflow)
+	b[4] = 184
+	assert.ErrorIs(t, ParsePacket(b, &got), ErrInvalidPacket)
+	b[0] = 0
+	assert.ErrorIs(t, ParsePacket(b, &got), ErrSync)
+}
+
+func stream(t *testing.T, packets ...*Packet) []byte {
+	var b []byte
+	for _, p := range packets {
+		var err error
+		b, err = AppendPacket(b, p)
+		require.NoError(t, err)
+	}
+	return b
+}
+
+func TestReader(t *testing.T) {
+	data := stream(t,
+		&Packet{PID: 0x20, ContinuityCounter: 14, Payload: []byte{1}},
+		&Packet{PID: 0x20, ContinuityCounter: 15, Payload: []byte{2}},
+		&Packet{PID: 0x20, ContinuityCounter: 15, Payload: []byte{2}}, // duplicate
+		&Packet{PID: 0x20, ContinuityCounter: 0, Payload: []byte{3}},
+		&Packet{PID: 0x20, ContinuityCounter: 2, Payload: []byte{4}},
+	)
+	r, _ := codec.NewReader(bytes.NewReader(data))
+	tr := NewReader(r)
+	var p Packet
+	for range 4 {
+		require.NoError(t, tr.ReadPacket(&p))
+	}
+	assert.ErrorIs(t, tr.ReadPacket(&p), ErrContinuity)
+	assert.Equal(t, byte(4), p.Payload[0])
+	assert.Equal(t, io.EOF, tr.ReadPacket(&p))
+}
+
+func TestReaderResync(t *testing.T) {
+	good := stream(t, &Packet{PID: 0x30, Payload: []byte{9}})
+	data := append([]byte{0, 1, 2}, good...)
+
+	r, _ := codec.NewReader(bytes.NewReader(data))
+	assert.ErrorIs(t, NewReader(r).ReadPacket(new(Packet)), ErrSync)
+
+	var skipped int64
+	r, _ = codec.NewReader(bytes.NewReader(data))
+	tr := NewReader(r).WithResync(func(off, n int64) { skipped += n })
+	var p Packet
+	require.NoError(t, tr.ReadPacket(&p))
+	assert.Equal(t, byte(9), p.Payload[0])
+	assert.EqualValues(t, 3, skipped)
+}
+
+func TestReaderCorrupt(t *testing.T) {
+	bad := stream(t, &Packet{PID: 0x30, Payload: []byte{9}})
+	bad[4] = 200 // adaptation field longer than the packet
+	good := stream(t, &Packet{PID: 0x31, Payload: []byte{8}})
+	r, _ := codec.NewReader(bytes.NewReader(append(bad, good...)))
+	tr := NewReader(r).WithResync(nil)
+	var p Packet
+	require.NoError(t, tr.ReadPacket(&p))
+	assert.Equal(t, uint16(0x31), p.PID)
+}
@@ -0,0 +1,91 @@
+package mpegts
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/oy3o/codec"
+)
+
+// Reader reads packets from a stream, checking the continuity counter of
+// every PID.
+type Reader struct {
+	r      *codec.Reader
+	resync bool
+	onSkip codec.ResyncCallback
+	last   [PID_NULL + 1]int8 // continuity counter per PID, -1 if unseen
+}
+
+// NewReader returns a Reader reading packets from r.
+func NewReader(r *codec.Reader) *Reader {
+	tr := &Reader{r: r}
+	for i := range tr.last {
+		tr.last[i] = -1
+	}
+	return tr
+}
+
+// WithResync makes ReadPacket recover from lost sync and corrupt packets by
+// skipping to the next SYNC_BYTE instead of failing. Skipped ranges are
+// reported to onSkip, which may be nil. It returns the Reader for chaining.
+func (tr *Reader) WithResync(onSkip codec.ResyncCallback) *Reader {
+	tr.r.WithResync([]byte{SYNC_BYTE}, nil)
+	tr.resync, tr.onSkip = true, onSkip
+	return tr
+}
+
+// ReadPacket reads the next packet. A Reader at the end of the stream
+// returns io.EOF.
+//
+// A packet whose continuity counter shows that packets of its PID were lost
+// is returned along with an error wrapping ErrContinuity; reading may
+// continue after it.
+func (tr *Reader) ReadPacket(p *Packet) error {
+	r := tr.r
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return r.Err()
+		}
+		if b != SYNC_BYTE {
+			if !tr.resync {
+				return fmt.Errorf("%w: %#02x at offset %d", ErrSync, b, r.Count()-1)
+			}
+			start := r.Count() - 1
+			n, err := r.Resync()
+			if tr.onSkip != nil {
+				tr.onSkip(start, n+1)
+			}
+			if err != nil {
+				return unexpected(err)
+			}
+		}
+		err = readPacket(r, p)
+		if tr.resync && errors.Is(err, ErrInvalidPacket) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		return tr.continuity(p)
+	}
+}
+
+// continuity checks the counter of a packet against the last of its PID.
+// Packets without payload do not advance the counter, and a packet may be
+// sent twice.
+func (tr *Reader) continuity(p *Packet) error {
+	if p.PID == PID_NULL {
+		return nil
+	}
+	last := tr.last[p.PID]
+	cc := int8(p.ContinuityCounter)
+	tr.last[p.PID] = cc
+	switch {
+	case last < 0, cc == last, p.Adaptation != nil && p.Adaptation.Discontinuity:
+		return nil
+	case p.Payload != nil && cc == (last+1)&0x0F:
+		return nil
+	}
+	return fmt.Errorf("%w: PID %#x counter %d after %d", ErrContinuity, p.PID, cc, last)
+}
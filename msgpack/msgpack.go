@@ -0,0 +1,412 @@
+// Package msgpack implements the format bytes of MessagePack on top of
+// codec.Reader and codec.Writer: integers, floats, booleans, nil, string and
+// binary headers, and array and map headers. It frames binary blobs for
+// interop with MessagePack services; it does not map arbitrary Go values.
+//
+// Writes follow the error latching of codec.Writer and always pick the
+// smallest format. Reads return the first error, which is either the Reader's
+// latched I/O error or a format error.
+package msgpack
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/oy3o/codec"
+)
+
+// Format bytes. The fix formats carry their value or length in the low bits.
+const (
+	PosFixint byte = 0x00 // 0xxxxxxx
+	FixMap    byte = 0x80 // 1000xxxx
+	FixArray  byte = 0x90 // 1001xxxx
+	FixStr    byte = 0xa0 // 101xxxxx
+	Nil       byte = 0xc0
+	False     byte = 0xc2
+	True      byte = 0xc3
+	Bin8      byte = 0xc4
+	Bin16     byte = 0xc5
+	Bin32     byte = 0xc6
+	Float32   byte = 0xca
+	Float64   byte = 0xcb
+	Uint8     byte = 0xcc
+	Uint16    byte = 0xcd
+	Uint32    byte = 0xce
+	Uint64    byte = 0xcf
+	Int8      byte = 0xd0
+	Int16     byte = 0xd1
+	Int32     byte = 0xd2
+	Int64     byte = 0xd3
+	Str8      byte = 0xd9
+	Str16     byte = 0xda
+	Str32     byte = 0xdb
+	Array16   byte = 0xdc
+	Array32   byte = 0xdd
+	Map16     byte = 0xde
+	Map32     byte = 0xdf
+	NegFixint byte = 0xe0 // 111xxxxx
+)
+
+// Largest lengths and smallest value of the fix formats.
+const (
+	fixMapMax = 15
+	fixArrMax = 15
+	fixStrMax = 31
+	negFixMin = -32
+)
+
+// MAX_LENGTH bounds the length of strings and binaries read by ReadString and
+// ReadBytes. The allocation limit of the codec.Reader applies as well.
+const MAX_LENGTH = 16 << 20
+
+var (
+	// ErrUnexpectedFormat indicates a format byte of a different family than requested.
+	ErrUnexpectedFormat = errors.New("msgpack: unexpected format")
+
+	// ErrOverflow indicates an integer that does not fit the requested Go type.
+	ErrOverflow = errors.New("msgpack: integer overflow")
+)
+
+// writeFormat writes a format byte followed by arg as a size-byte big-endian integer.
+func writeFormat(w *codec.Writer, format byte, arg uint64, size int) {
+	var buf [9]byte
+	buf[0] = format
+	binary.BigEndian.PutUint64(buf[1:], arg<<(64-8*size))
+	w.Write(buf[:1+size])
+}
+
+// WriteNil writes nil.
+func WriteNil(w *codec.Writer) { w.WriteUint8(Nil) }
+
+// WriteBool writes true or false.
+func WriteBool(w *codec.Writer, v bool) {
+	if v {
+		w.WriteUint8(True)
+	} else {
+		w.WriteUint8(False)
+	}
+}
+
+// WriteUint writes an unsigned integer.
+func WriteUint(w *codec.Writer, v uint64) {
+	switch {
+	case v <= math.MaxInt8:
+		w.WriteUint8(byte(v))
+	case v <= math.MaxUint8:
+		writeFormat(w, Uint8, v, 1)
+	case v <= math.MaxUint16:
+		writeFormat(w, Uint16, v, 2)
+	case v <= math.MaxUint32:
+		writeFormat(w, Uint32, v, 4)
+	default:
+		writeFormat(w, Uint64, v, 8)
+	}
+}
+
+// WriteInt writes a signed integer. Non-negative values use the unsigned formats.
+func WriteInt(w *codec.Writer, v int64) {
+	switch {
+	case v >= 0:
+		WriteUint(w, uint64(v))
+	case v >= negFixMin:
+		w.WriteUint8(byte(v))
+	case v >= math.MinInt8:
+		writeFormat(w, Int8, uint64(v)&0xff, 1)
+	case v >= math.MinInt16:
+		writeFormat(w, Int16, uint64(v)&0xffff, 2)
+	case v >= math.MinInt32:
+		writeFormat(w, Int32, uint64(v)&0xffffffff, 4)
+	default:
+		writeFormat(w, Int64, uint64(v), 8)
+	}
+}
+
+// WriteFloat32 writes a single-precision float.
+func WriteFloat32(w *codec.Writer, v float32) {
+	writeFormat(w, Float32, uint64(math.Float32bits(v)), 4)
+}
+
+// WriteFloat64 writes a double-precision float.
+func WriteFloat64(w *codec.Writer, v float64) {
+	writeFormat(w, Float64, math.Float64bits(v), 8)
+}
+
+// WriteStrHeader starts a string of n bytes, which must follow.
+func WriteStrHeader(w *codec.Writer, n int) {
+	switch {
+	case n <= fixStrMax:
+		w.WriteUint8(FixStr | byte(n))
+	case n <= math.MaxUint8:
+		writeFormat(w, Str8, uint64(n), 1)
+	case n <= math.MaxUint16:
+		writeFormat(w, Str16, uint64(n), 2)
+	default:
+		writeFormat(w, Str32, uint64(n), 4)
+	}
+}
+
+// WriteString writes a UTF-8 string.
+func WriteString(w *codec.Writer, s string) {
+	WriteStrHeader(w, len(s))
+	w.WriteString(s)
+}
+
+// WriteBinHeader starts a binary of n bytes, which must follow.
+func WriteBinHeader(w *codec.Writer, n int) {
+	switch {
+	case n <= math.MaxUint8:
+		writeFormat(w, Bin8, uint64(n), 1)
+	case n <= math.MaxUint16:
+		writeFormat(w, Bin16, uint64(n), 2)
+	default:
+		writeFormat(w, Bin32, uint64(n), 4)
+	}
+}
+
+// WriteBytes writes a binary.
+func WriteBytes(w *codec.Writer, b []byte) {
+	WriteBinHeader(w, len(b))
+	w.WriteBytes(b)
+}
+
+// WriteArrayHeader starts an array of n items, which must follow.
+func WriteArrayHeader(w *codec.Writer, n int) {
+	switch {
+	case n <= fixArrMax:
+		w.WriteUint8(FixArray | byte(n))
+	case n <= math.MaxUint16:
+		writeFormat(w, Array16, uint64(n), 2)
+	default:
+		writeFormat(w, Array32, uint64(n), 4)
+	}
+}
+
+// WriteMapHeader starts a map of n key/value pairs, which must follow.
+func WriteMapHeader(w *codec.Writer, n int) {
+	switch {
+	case n <= fixMapMax:
+		w.WriteUint8(FixMap | byte(n))
+	case n <= math.MaxUint16:
+		writeFormat(w, Map16, uint64(n), 2)
+	default:
+		writeFormat(w, Map32, uint64(n), 4)
+	}
+}
+
+// readArg reads a size-byte big-endian argument.
+func readArg(r *codec.Reader, size int) (uint64, error) {
+	var buf [8]byte
+	r.ReadBytesTo(buf[8-size:])
+	if err := r.Err(); err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint64(buf[:]), nil
+}
+
+// unexpected reports a format byte that does not belong to the wanted family.
+func unexpected(b byte, want string) error {
+	return fmt.Errorf("%w: 0x%02x is not %s", ErrUnexpectedFormat, b, want)
+}
+
+// readInteger reads an integer of any format. neg reports a negative value,
+// in which case v holds its two's complement bits.
+func readInteger(r *codec.Reader) (v uint64, neg bool, err error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return 0, false, err
+	}
+	switch {
+	case b <= 0x7f:
+		return uint64(b), false, nil
+	case b >= NegFixint:
+		return uint64(int64(int8(b))), true, nil
+	case b >= Uint8 && b <= Uint64:
+		v, err = readArg(r, 1<<(b-Uint8))
+		return v, false, err
+	case b >= Int8 && b <= Int64:
+		size := 1 << (b - Int8)
+		if v, err = readArg(r, size); err != nil {
+			return 0, false, err
+		}
+		shift := 64 - 8*size
+		s := int64(v<<shift) >> shift
+		return uint64(s), s < 0, nil
+	}
+	return 0, false, unexpected(b, "an integer")
+}
+
+// ReadUint reads a non-negative integer of any format.
+func ReadUint(r *codec.Reader) (uint64, error) {
+	v, neg, err := readInteger(r)
+	if err != nil {
+		return 0, err
+	}
+	if neg {
+		return 0, fmt.Errorf("%w: %d is negative", ErrOverflow, int64(v))
+	}
+	return v, nil
+}
+
+// ReadInt reads an integer of any format.
+func ReadInt(r *codec.Reader) (int64, error) {
+	v, neg, err := readInteger(r)
+	if err != nil {
+		return 0, err
+	}
+	if !neg && v > math.MaxInt64 {
+		return 0, fmt.Errorf("%w: %d does not fit int64", ErrOverflow, v)
+	}
+	return int64(v), nil
+}
+
+// ReadNil reads nil.
+func ReadNil(r *codec.Reader) error {
+	b, err := r.ReadByte()
+	if err != nil {
+		return err
+	}
+	if b != Nil {
+		return unexpected(b, "nil")
+	}
+	return nil
+}
+
+// ReadBool reads true or false.
+func ReadBool(r *codec.Reader) (bool, error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return false, err
+	}
+	switch b {
+	case False:
+		return false, nil
+	case True:
+		return true, nil
+	}
+	return false, unexpected(b, "a bool")
+}
+
+// ReadFloat64 reads a float of either precision.
+func ReadFloat64(r *codec.Reader) (float64, error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	switch b {
+	case Float32:
+		v, err := readArg(r, 4)
+		return float64(math.Float32frombits(uint32(v))), err
+	case Float64:
+		v, err := readArg(r, 8)
+		return math.Float64frombits(v), err
+	}
+	return 0, unexpected(b, "a float")
+}
+
+// ReadStrHeader reads a string header and returns the length in bytes.
+func ReadStrHeader(r *codec.Reader) (int, error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	switch {
+	case b&0xe0 == FixStr:
+		return int(b &^ 0xe0), nil
+	case b >= Str8 && b <= Str32:
+		return readLength(r, 1<<(b-Str8))
+	}
+	return 0, unexpected(b, "a string")
+}
+
+// ReadBinHeader reads a binary header and returns the length in bytes.
+func ReadBinHeader(r *codec.Reader) (int, error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	if b >= Bin8 && b <= Bin32 {
+		return readLength(r, 1<<(b-Bin8))
+	}
+	return 0, unexpected(b, "a binary")
+}
+
+// ReadArrayHeader reads an array header and returns the number of items.
+func ReadArrayHeader(r *codec.Reader) (int, error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	switch {
+	case b&0xf0 == FixArray:
+		return int(b & 0x0f), nil
+	case b == Array16:
+		return readLength(r, 2)
+	case b == Array32:
+		return readLength(r, 4)
+	}
+	return 0, unexpected(b, "an array")
+}
+
+// ReadMapHeader reads a map header and returns the number of pairs.
+func ReadMapHeader(r *codec.Reader) (int, error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	switch {
+	case b&0xf0 == FixMap:
+		return int(b & 0x0f), nil
+	case b == Map16:
+		return readLength(r, 2)
+	case b == Map32:
+		return readLength(r, 4)
+	}
+	return 0, unexpected(b, "a map")
+}
+
+func readLength(r *codec.Reader, size int) (int, error) {
+	n, err := readArg(r, size)
+	if err != nil {
+		return 0, err
+	}
+	if n > math.MaxInt32 {
+		return 0, fmt.Errorf("%w: %d items", codec.ErrLengthOverflow, n)
+	}
+	return int(n), nil
+}
+
+// readPayload reads n bytes of string or binary payload.
+func readPayload(r *codec.Reader, n int) ([]byte, error) {
+	if n > MAX_LENGTH {
+		return nil, fmt.Errorf("%w: %d bytes exceeds %d", codec.ErrLengthOverflow, n, MAX_LENGTH)
+	}
+	b := r.ReadBytes(n)
+	if err := r.Err(); err != nil {
+		return nil, err
+	}
+	if b == nil {
+		b = []byte{}
+	}
+	return b, nil
+}
+
+// ReadString reads a string.
+func ReadString(r *codec.Reader) (string, error) {
+	n, err := ReadStrHeader(r)
+	if err != nil {
+		return "", err
+	}
+	b, err := readPayload(r, n)
+	return string(b), err
+}
+
+// ReadBytes reads a binary.
+func ReadBytes(r *codec.Reader) ([]byte, error) {
+	n, err := ReadBinHeader(r)
+	if err != nil {
+		return nil, err
+	}
+	return readPayload(r, n)
+}
@@ -0,0 +1,111 @@
+//go:build test
+
+package msgpack
+
+import (
+	"bytes"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/oy3o/codec"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func encode(t *testing.T, fn func(w *codec.Writer)) string {
+	var buf bytes.Buffer
+	w, err := codec.NewWriter(&buf)
+	require.NoError(t, err)
+	fn(w)
+	require.NoError(t, w.Flush())
+	return hex.EncodeToString(buf.Bytes())
+}
+
+func reader(t *testing.T, s string) *codec.Reader {
+	b, err := hex.DecodeString(s)
+	require.NoError(t, err)
+	r, err := codec.NewReader(bytes.NewReader(b))
+	require.NoError(t, err)
+	return r
+}
+
+func TestEncode(t *testing.T) {
+	assert.Equal(t, "7f", encode(t, func(w *codec.Writer) { WriteUint(w, 127) }))
+	assert.Equal(t, "cc80", encode(t, func(w *codec.Writer) { WriteUint(w, 128) }))
+	assert.Equal(t, "cd03e8", encode(t, func(w *codec.Writer) { WriteInt(w, 1000) }))
+	assert.Equal(t, "cf0000000100000000", encode(t, func(w *codec.Writer) { WriteUint(w, 1<<32) }))
+	assert.Equal(t, "e0", encode(t, func(w *codec.Writer) { WriteInt(w, -32) }))
+	assert.Equal(t, "d0df", encode(t, func(w *codec.Writer) { WriteInt(w, -33) }))
+	assert.Equal(t, "d1fc18", encode(t, func(w *codec.Writer) { WriteInt(w, -1000) }))
+	assert.Equal(t, "c0c2c3", encode(t, func(w *codec.Writer) { WriteNil(w); WriteBool(w, false); WriteBool(w, true) }))
+	assert.Equal(t, "cb3ff199999999999a", encode(t, func(w *codec.Writer) { WriteFloat64(w, 1.1) }))
+	assert.Equal(t, "ca3fc00000", encode(t, func(w *codec.Writer) { WriteFloat32(w, 1.5) }))
+	assert.Equal(t, "a3616263", encode(t, func(w *codec.Writer) { WriteString(w, "abc") }))
+	assert.Equal(t, "c4020102", encode(t, func(w *codec.Writer) { WriteBytes(w, []byte{1, 2}) }))
+	assert.Equal(t, "d920", encode(t, func(w *codec.Writer) { WriteStrHeader(w, 32) }))
+	assert.Equal(t, "dc0010", encode(t, func(w *codec.Writer) { WriteArrayHeader(w, 16) }))
+	assert.Equal(t, "df00010000", encode(t, func(w *codec.Writer) { WriteMapHeader(w, 1<<16) }))
+
+	// {"a": 1, "b": [2, 3]}
+	assert.Equal(t, "82a16101a162920203", encode(t, func(w *codec.Writer) {
+		WriteMapHeader(w, 2)
+		WriteString(w, "a")
+		WriteUint(w, 1)
+		WriteString(w, "b")
+		WriteArrayHeader(w, 2)
+		WriteUint(w, 2)
+		WriteUint(w, 3)
+	}))
+}
+
+func TestDecode(t *testing.T) {
+	r := reader(t, "82a16101a162920203")
+	n, err := ReadMapHeader(r)
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+	key, err := ReadString(r)
+	require.NoError(t, err)
+	assert.Equal(t, "a", key)
+	v, err := ReadInt(r)
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, v)
+	_, err = ReadString(r)
+	require.NoError(t, err)
+	n, err = ReadArrayHeader(r)
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+
+	for hexed, want := range map[string]int64{"e0": -32, "d0df": -33, "d1fc18": -1000, "d2ffff0000": -65536, "cd03e8": 1000} {
+		v, err := ReadInt(reader(t, hexed))
+		require.NoError(t, err, hexed)
+		assert.Equal(t, want, v, hexed)
+	}
+
+	f, err := ReadFloat64(reader(t, "ca3fc00000"))
+	require.NoError(t, err)
+	assert.Equal(t, 1.5, f)
+
+	s, err := ReadString(reader(t, "d903"+hex.EncodeToString([]byte("xyz"))))
+	require.NoError(t, err)
+	assert.Equal(t, "xyz", s)
+	b, err := ReadBytes(reader(t, "c50003"+strings.Repeat("ff", 3)))
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0xff, 0xff, 0xff}, b)
+	require.NoError(t, ReadNil(reader(t, "c0")))
+
+	_, err = ReadUint(reader(t, "ff"))
+	assert.ErrorIs(t, err, ErrOverflow)
+	_, err = ReadInt(reader(t, "cfffffffffffffffff"))
+	assert.ErrorIs(t, err, ErrOverflow)
+	_, err = ReadBool(reader(t, "c0"))
+	assert.ErrorIs(t, err, ErrUnexpectedFormat)
+	_, err = ReadString(reader(t, "c40101"))
+	assert.ErrorIs(t, err, ErrUnexpectedFormat)
+	_, err = ReadBytes(reader(t, "c67fffffff"))
+	assert.ErrorIs(t, err, codec.ErrLengthOverflow)
+	r, err = codec.NewReaderOpts(bytes.NewReader([]byte{0xdb, 0, 0x10, 0, 0}), codec.WithMaxAlloc(1<<10))
+	require.NoError(t, err)
+	_, err = ReadString(r)
+	assert.ErrorIs(t, err, codec.ErrLengthOverflow)
+}
@@ -0,0 +1,188 @@
+// Package mysqlwire frames the packets of the MySQL client/server protocol on
+// top of codec.Reader and codec.Writer, for proxies and backup tools.
+//
+// A packet is a 3-byte little-endian payload length and a sequence ID,
+// followed by the payload. Payloads of MAX_PACKET_SIZE bytes or more are
+// split into packets of exactly MAX_PACKET_SIZE bytes, ended by a shorter,
+// possibly empty, packet. Inside payloads, integers and strings are often
+// length-encoded.
+//
+// The sequence ID of every packet is checked, so a lost or reordered packet
+// fails with ErrSequence. Each packet is checked against the caller's limit
+// before it is read, so a payload over the limit fails with
+// codec.ErrLengthOverflow having buffered at most limit bytes. Packets and
+// strings are read with codec.Reader.ReadBytes, so the Reader's allocation
+// limit applies to each of them too. Malformed length-encoded values fail
+// with ErrInvalidPacket.
+package mysqlwire
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/oy3o/codec"
+)
+
+// MAX_PACKET_SIZE is the largest payload of a single packet; a packet of
+// this size is continued by the next.
+const MAX_PACKET_SIZE = 1<<24 - 1
+
+// Header bytes with a special meaning in length-encoded positions and
+// response packets.
+const (
+	OK   byte = 0x00
+	NULL byte = 0xFB // a NULL column in text result rows
+	EOF  byte = 0xFE
+	ERR  byte = 0xFF
+)
+
+var (
+	// ErrSequence indicates a packet whose sequence ID is not the expected one.
+	ErrSequence = errors.New("mysqlwire: packet out of sequence")
+
+	// ErrInvalidPacket indicates a malformed payload, such as a reserved
+	// length-encoded integer prefix.
+	ErrInvalidPacket = errors.New("mysqlwire: invalid packet")
+)
+
+// unexpected reports a stream ending inside a packet.
+func unexpected(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// ReadPacket reads a payload of at most limit bytes, reassembling continued
+// packets. seq holds the sequence ID expected next and is advanced past the
+// packets read; it is reset to 0 at the start of each command. A Reader at
+// the end of the stream returns io.EOF.
+func ReadPacket(r *codec.Reader, seq *uint8, limit int) ([]byte, error) {
+	var payload []byte
+	var head [4]byte
+	for first := true; ; first = false {
+		r.ReadBytesTo(head[:])
+		if err := r.Err(); err != nil {
+			if !first {
+				err = unexpected(err)
+			}
+			return nil, err
+		}
+		n := int(head[0]) | int(head[1])<<8 | int(head[2])<<16
+		if head[3] != *seq {
+			return nil, fmt.Errorf("%w: sequence ID %d, expected %d", ErrSequence, head[3], *seq)
+		}
+		*seq++
+		if n > limit-len(payload) {
+			return nil, fmt.Errorf("%w: payload exceeds %d bytes", codec.ErrLengthOverflow, limit)
+		}
+		b := r.ReadBytes(n)
+		if err := r.Err(); err != nil {
+			return nil, unexpected(err)
+		}
+		if payload == nil {
+			payload = b
+		} else {
+			payload = append(payload, b...)
+		}
+		if n < MAX_PACKET_SIZE {
+			return payload, nil
+		}
+	}
+}
+
+// WritePacket writes payload, splitting it into as many packets as needed.
+// seq holds the sequence ID of the first packet and is advanced past them.
+func WritePacket(w *codec.Writer, seq *uint8, payload []byte) error {
+	for {
+		n := min(len(payload), MAX_PACKET_SIZE)
+		w.WriteBytes([]byte{byte(n), byte(n >> 8), byte(n >> 16), *seq})
+		w.WriteBytes(payload[:n])
+		*seq++
+		payload = payload[n:]
+		if n < MAX_PACKET_SIZE {
+			return w.Err()
+		}
+	}
+}
+
+// ReadLenEncInt reads a length-encoded integer. null reports the NULL
+// marker of text result rows, in which case v is 0.
+func ReadLenEncInt(r *codec.Reader) (v uint64, null bool, err error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return 0, false, unexpected(r.Err())
+	}
+	size := 0
+	switch b {
+	case NULL:
+		return 0, true, nil
+	case 0xFC:
+		size = 2
+	case 0xFD:
+		size = 3
+	case 0xFE:
+		size = 8
+	case ERR:
+		return 0, false, fmt.Errorf("%w: length-encoded integer prefix %#x", ErrInvalidPacket, b)
+	default:
+		return uint64(b), false, nil
+	}
+	var buf [8]byte
+	r.ReadBytesTo(buf[:size])
+	if err := r.Err(); err != nil {
+		return 0, false, unexpected(err)
+	}
+	return binary.LittleEndian.Uint64(buf[:]), false, nil
+}
+
+// AppendLenEncInt appends the length-encoded form of v to b.
+func AppendLenEncInt(b []byte, v uint64) []byte {
+	switch {
+	case v < uint64(NULL):
+		return append(b, byte(v))
+	case v < 1<<16:
+		return append(b, 0xFC, byte(v), byte(v>>8))
+	case v < 1<<24:
+		return append(b, 0xFD, byte(v), byte(v>>8), byte(v>>16))
+	default:
+		return binary.LittleEndian.AppendUint64(append(b, 0xFE), v)
+	}
+}
+
+// WriteLenEncInt writes v as a length-encoded integer.
+func WriteLenEncInt(w *codec.Writer, v uint64) {
+	var buf [9]byte
+	w.WriteBytes(AppendLenEncInt(buf[:0], v))
+}
+
+// ReadLenEncString reads a length-encoded string of at most limit bytes. A
+// NULL column is returned as nil with null set.
+func ReadLenEncString(r *codec.Reader, limit int) (s []byte, null bool, err error) {
+	n, null, err := ReadLenEncInt(r)
+	if err != nil || null {
+		return nil, null, err
+	}
+	if n > uint64(limit) {
+		return nil, false, fmt.Errorf("%w: string of %d bytes exceeds %d", codec.ErrLengthOverflow, n, limit)
+	}
+	s = r.ReadBytes(int(n))
+	if err := r.Err(); err != nil {
+		return nil, false, unexpected(err)
+	}
+	if s == nil {
+		s = []byte{}
+	}
+	return s, false, nil
+}
+
+// WriteLenEncString writes s prefixed by its length-encoded length.
+func WriteLenEncString(w *codec.Writer, s []byte) {
+	WriteLenEncInt(w, uint64(len(s)))
+	w.WriteBytes(s)
+}
+
+// WriteNull writes the NULL marker of a text result row column.
+func WriteNull(w *codec.Writer) { w.WriteUint8(NULL) }
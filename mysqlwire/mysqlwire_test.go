@@ -0,0 +1,117 @@
+//go:build test
+
+package mysqlwire
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/oy3o/codec"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPacket(t *testing.T) {
+	var buf bytes.Buffer
+	w, _ := codec.NewWriter(&buf)
+	var seq uint8
+	require.NoError(t, WritePacket(w, &seq, []byte("\x03SELECT 1")))
+	require.NoError(t, WritePacket(w, &seq, nil))
+	assert.Equal(t, "\x09\x00\x00\x00\x03SELECT 1\x00\x00\x00\x01", buf.String())
+	assert.EqualValues(t, 2, seq)
+
+	r, _ := codec.NewReader(&buf)
+	seq = 0
+	p, err := ReadPacket(r, &seq, 1024)
+	require.NoError(t, err)
+	assert.Equal(t, "\x03SELECT 1", string(p))
+	p, err = ReadPacket(r, &seq, 1024)
+	require.NoError(t, err)
+	assert.Empty(t, p)
+	_, err = ReadPacket(r, &seq, 1024)
+	assert.Equal(t, io.EOF, err)
+
+	r, _ = codec.NewReader(bytes.NewReader([]byte("\x01\x00\x00\x05x")))
+	seq = 0
+	_, err = ReadPacket(r, &seq, 1024)
+	assert.ErrorIs(t, err, ErrSequence)
+	r, _ = codec.NewReader(bytes.NewReader([]byte("\x01\x04\x00\x00")))
+	_, err = ReadPacket(r, new(uint8), 1024)
+	assert.ErrorIs(t, err, codec.ErrLengthOverflow)
+	r, _ = codec.NewReader(bytes.NewReader([]byte("\x05\x00\x00\x00ab")))
+	_, err = ReadPacket(r, new(uint8), 1024)
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	r, _ = codec.NewReaderOpts(bytes.NewReader([]byte("\xff\xff\x00\x00")), codec.WithMaxAlloc(1<<10))
+	_, err = ReadPacket(r, new(uint8), MAX_PACKET_SIZE)
+	assert.ErrorIs(t, err, codec.ErrLengthOverflow)
+}
+
+func TestContinuation(t *testing.T) {
+	payload := bytes.Repeat([]byte{0xAB}, MAX_PACKET_SIZE)
+	var buf bytes.Buffer
+	w, _ := codec.NewWriter(&buf)
+	seq := uint8(255)
+	require.NoError(t, WritePacket(w, &seq, payload))
+	// An exact multiple ends with an empty packet, and IDs wrap.
+	assert.Equal(t, MAX_PACKET_SIZE+8, buf.Len())
+	assert.EqualValues(t, 1, seq)
+	assert.Equal(t, []byte{0, 0, 0, 0}, buf.Bytes()[buf.Len()-4:])
+
+	r, _ := codec.NewReader(&buf)
+	seq = 255
+	p, err := ReadPacket(r, &seq, 1<<25)
+	require.NoError(t, err)
+	assert.Equal(t, len(payload), len(p))
+	assert.EqualValues(t, 1, seq)
+
+	buf.Reset()
+	seq = 0
+	WritePacket(w, &seq, append(payload, 1, 2))
+	r, _ = codec.NewReader(&buf)
+	seq = 0
+	_, err = ReadPacket(r, &seq, MAX_PACKET_SIZE+1)
+	assert.ErrorIs(t, err, codec.ErrLengthOverflow)
+}
+
+func TestLenEnc(t *testing.T) {
+	var buf bytes.Buffer
+	w, _ := codec.NewWriter(&buf)
+	values := []uint64{0, 250, 251, 1<<16 - 1, 1 << 16, 1<<24 - 1, 1 << 24, 1<<64 - 1}
+	for _, v := range values {
+		WriteLenEncInt(w, v)
+	}
+	WriteLenEncString(w, []byte("def"))
+	WriteNull(w)
+	require.NoError(t, w.Flush())
+	assert.Equal(t, []byte{0, 250, 0xFC, 251, 0}, buf.Bytes()[:5])
+
+	r, _ := codec.NewReader(&buf)
+	for _, want := range values {
+		v, null, err := ReadLenEncInt(r)
+		require.NoError(t, err)
+		assert.False(t, null)
+		assert.Equal(t, want, v)
+	}
+	s, null, err := ReadLenEncString(r, 10)
+	require.NoError(t, err)
+	assert.False(t, null)
+	assert.Equal(t, "def", string(s))
+	s, null, err = ReadLenEncString(r, 10)
+	require.NoError(t, err)
+	assert.True(t, null)
+	assert.Nil(t, s)
+
+	r, _ = codec.NewReader(bytes.NewReader([]byte{0xFF}))
+	_, _, err = ReadLenEncInt(r)
+	assert.ErrorIs(t, err, ErrInvalidPacket)
+	r, _ = codec.NewReader(bytes.NewReader([]byte{0xFD, 1}))
+	_, _, err = ReadLenEncInt(r)
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	r, _ = codec.NewReader(bytes.NewReader([]byte{5, 'a'}))
+	_, _, err = ReadLenEncString(r, 4)
+	assert.ErrorIs(t, err, codec.ErrLengthOverflow)
+	r, _ = codec.NewReaderOpts(bytes.NewReader([]byte{0xFC, 0xFF, 0xFF}), codec.WithMaxAlloc(1<<10))
+	_, _, err = ReadLenEncString(r, 1<<20)
+	assert.ErrorIs(t, err, codec.ErrLengthOverflow)
+}
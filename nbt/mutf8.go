@@ -0,0 +1,71 @@
+package nbt
+
+import (
+	"bytes"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// decodeMUTF8 converts Java's modified UTF-8, which encodes NUL as C0 80 and
+// supplementary characters as surrogate pairs, to UTF-8. Malformed
+// sequences become U+FFFD.
+func decodeMUTF8(b []byte) string {
+	if !bytes.Contains(b, []byte{0xC0, 0x80}) && bytes.IndexByte(b, 0xED) < 0 {
+		return string(b)
+	}
+	units := make([]uint16, 0, len(b))
+	for i := 0; i < len(b); {
+		c := b[i]
+		switch {
+		case c < 0x80:
+			units = append(units, uint16(c))
+			i++
+		case c&0xE0 == 0xC0 && i+1 < len(b) && b[i+1]&0xC0 == 0x80:
+			units = append(units, uint16(c&0x1F)<<6|uint16(b[i+1]&0x3F))
+			i += 2
+		case c&0xF0 == 0xE0 && i+2 < len(b) && b[i+1]&0xC0 == 0x80 && b[i+2]&0xC0 == 0x80:
+			units = append(units, uint16(c&0x0F)<<12|uint16(b[i+1]&0x3F)<<6|uint16(b[i+2]&0x3F))
+			i += 3
+		default:
+			units = append(units, utf8.RuneError)
+			i++
+		}
+	}
+	return string(utf16.Decode(units))
+}
+
+// encodeMUTF8 converts UTF-8 to Java's modified UTF-8.
+func encodeMUTF8(s string) []byte {
+	plain := strings.IndexByte(s, 0) < 0
+	for _, r := range s {
+		if r >= 0x10000 {
+			plain = false
+			break
+		}
+	}
+	if plain {
+		return []byte(s)
+	}
+	b := make([]byte, 0, len(s)+8)
+	for _, r := range s {
+		if r >= 0x10000 {
+			hi, lo := utf16.EncodeRune(r)
+			b = appendUnit(appendUnit(b, uint16(hi)), uint16(lo))
+			continue
+		}
+		b = appendUnit(b, uint16(r))
+	}
+	return b
+}
+
+func appendUnit(b []byte, u uint16) []byte {
+	switch {
+	case u != 0 && u < 0x80:
+		return append(b, byte(u))
+	case u < 0x800:
+		return append(b, 0xC0|byte(u>>6), 0x80|byte(u&0x3F))
+	default:
+		return append(b, 0xE0|byte(u>>12), 0x80|byte(u>>6&0x3F), 0x80|byte(u&0x3F))
+	}
+}
@@ -0,0 +1,453 @@
+// Package nbt implements Minecraft's Named Binary Tag format on top of
+// codec.Reader and codec.Writer: a streaming token API with Decoder and
+// Encoder, and a tree API with ReadValue and WriteValue.
+//
+// Java edition files are big-endian, which is the codec default; Bedrock
+// edition files are little-endian, so set the Reader or Writer byte order to
+// codec.LE for them. Saved files are usually gzip-wrapped: NewReader unwraps
+// them, and codec.CompressWriter with "gzip" wraps them. Strings use Java's
+// modified UTF-8 on the wire and UTF-8 in Go.
+//
+// Decoding refuses nesting deeper than MAX_DEPTH with ErrTooDeep and arrays
+// longer than MAX_LENGTH with codec.ErrLengthOverflow, so untrusted files
+// cannot exhaust the stack or memory. Encoder checks that tokens form a
+// well-formed tree, failing with ErrUnbalanced otherwise.
+package nbt
+
+import (
+	"bufio"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/oy3o/codec"
+)
+
+// Tag is the type of a tag.
+type Tag byte
+
+const (
+	TagEnd Tag = iota
+	TagByte
+	TagShort
+	TagInt
+	TagLong
+	TagFloat
+	TagDouble
+	TagByteArray
+	TagString
+	TagList
+	TagCompound
+	TagIntArray
+	TagLongArray
+)
+
+var tagNames = [...]string{"End", "Byte", "Short", "Int", "Long", "Float", "Double",
+	"ByteArray", "String", "List", "Compound", "IntArray", "LongArray"}
+
+func (t Tag) String() string {
+	if int(t) < len(tagNames) {
+		return tagNames[t]
+	}
+	return fmt.Sprintf("Tag(%d)", byte(t))
+}
+
+// MAX_DEPTH bounds the nesting of compounds and lists, as the game does.
+const MAX_DEPTH = 512
+
+// MAX_LENGTH bounds the number of elements of the arrays read by Decoder.
+// Byte arrays and strings are read with codec.Reader.ReadBytes, so the
+// Reader's allocation limit applies to them too.
+const MAX_LENGTH = 16 << 20
+
+var (
+	// ErrInvalidTag indicates an unknown tag type, or a tag where none may appear.
+	ErrInvalidTag = errors.New("nbt: invalid tag")
+
+	// ErrTooDeep indicates compounds and lists nested deeper than MAX_DEPTH.
+	ErrTooDeep = errors.New("nbt: nesting too deep")
+
+	// ErrUnbalanced indicates tokens written out of structure, such as an
+	// element of the wrong type in a list, or an End with nothing open.
+	ErrUnbalanced = errors.New("nbt: unbalanced tokens")
+)
+
+// Token is a tag of a stream. Compounds and lists are opened by a token of
+// their tag and closed by a TagEnd token. Which value field is set depends
+// on Tag: Int for Byte, Short, Int and Long; Float for Float and Double;
+// String, Bytes, Ints and Longs for the rest; Elem and Len for List.
+type Token struct {
+	Tag    Tag
+	Name   string // name within a compound or of the root, empty in lists
+	Int    int64
+	Float  float64
+	String string
+	Bytes  []byte
+	Ints   []int32
+	Longs  []int64
+	Elem   Tag // element tag of a List
+	Len    int // element count of a List
+}
+
+// frame is an open compound, or an open list with left elements to go.
+type frame struct {
+	list bool
+	elem Tag
+	left int
+}
+
+// NewReader returns a Reader over r, decompressing it if it is gzip or zlib
+// wrapped, as level.dat and most saved NBT are.
+func NewReader(r io.Reader) (*codec.Reader, error) {
+	br := bufio.NewReaderSize(r, codec.BUFFER_SIZE)
+	magic, _ := br.Peek(2)
+	switch {
+	case len(magic) == 2 && magic[0] == 0x1F && magic[1] == 0x8B:
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		return codec.NewReaderSize(zr, codec.BUFFER_SIZE)
+	case len(magic) == 2 && magic[0] == 0x78:
+		zr, err := zlib.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		return codec.NewReaderSize(zr, codec.BUFFER_SIZE)
+	}
+	return codec.NewReaderSize(br, codec.BUFFER_SIZE)
+}
+
+// Decoder reads the tokens of NBT data.
+type Decoder struct {
+	r       *codec.Reader
+	stack   []frame
+	unnamed bool
+}
+
+// NewDecoder returns a Decoder reading from r.
+func NewDecoder(r *codec.Reader) *Decoder { return &Decoder{r: r} }
+
+// WithUnnamedRoot expects root tags without a name, as in network NBT since
+// Java edition 1.20.2, and returns the Decoder for chaining.
+func (d *Decoder) WithUnnamedRoot() *Decoder {
+	d.unnamed = true
+	return d
+}
+
+// Depth returns the number of open compounds and lists.
+func (d *Decoder) Depth() int { return len(d.stack) }
+
+// unexpected reports a stream ending inside a tag.
+func unexpected(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+func (d *Decoder) readTag() (Tag, error) {
+	var b uint8
+	d.r.ReadUint8(&b)
+	if err := d.r.Err(); err != nil {
+		return 0, err
+	}
+	if b > byte(TagLongArray) {
+		return 0, fmt.Errorf("%w: type %d", ErrInvalidTag, b)
+	}
+	return Tag(b), nil
+}
+
+func (d *Decoder) readString() (string, error) {
+	var n uint16
+	d.r.ReadUint16(&n)
+	b := d.r.ReadBytes(int(n))
+	if err := d.r.Err(); err != nil {
+		return "", unexpected(err)
+	}
+	return decodeMUTF8(b), nil
+}
+
+// readLength reads the int32 element count of an array or list.
+func (d *Decoder) readLength(tag Tag) (int, error) {
+	var n int32
+	d.r.ReadInt32(&n)
+	if err := d.r.Err(); err != nil {
+		return 0, unexpected(err)
+	}
+	if n < 0 || n > MAX_LENGTH {
+		return 0, fmt.Errorf("%w: %s of %d elements", codec.ErrLengthOverflow, tag, n)
+	}
+	return int(n), nil
+}
+
+// Token returns the next token. After a root tag has been read completely,
+// the next call reads the following root, and io.EOF is returned at the end
+// of the stream.
+func (d *Decoder) Token() (Token, error) {
+	var t Token
+	var err error
+	if len(d.stack) == 0 {
+		if t.Tag, err = d.readTag(); err != nil {
+			return t, err
+		}
+		if t.Tag == TagEnd {
+			return t, fmt.Errorf("%w: End at the root", ErrInvalidTag)
+		}
+		if !d.unnamed {
+			if t.Name, err = d.readString(); err != nil {
+				return t, err
+			}
+		}
+		return t, d.payload(&t)
+	}
+
+	top := &d.stack[len(d.stack)-1]
+	if top.list {
+		if top.left == 0 {
+			d.stack = d.stack[:len(d.stack)-1]
+			return t, nil
+		}
+		top.left--
+		t.Tag = top.elem
+		return t, d.payload(&t)
+	}
+	if t.Tag, err = d.readTag(); err != nil {
+		return t, unexpected(err)
+	}
+	if t.Tag == TagEnd {
+		d.stack = d.stack[:len(d.stack)-1]
+		return t, nil
+	}
+	if t.Name, err = d.readString(); err != nil {
+		return t, err
+	}
+	return t, d.payload(&t)
+}
+
+// payload reads the value of t, opening a frame for compounds and lists.
+func (d *Decoder) payload(t *Token) error {
+	r := d.r
+	switch t.Tag {
+	case TagByte:
+		var v int8
+		r.ReadInt8(&v)
+		t.Int = int64(v)
+	case TagShort:
+		var v int16
+		r.ReadInt16(&v)
+		t.Int = int64(v)
+	case TagInt:
+		var v int32
+		r.ReadInt32(&v)
+		t.Int = int64(v)
+	case TagLong:
+		r.ReadInt64(&t.Int)
+	case TagFloat:
+		var v uint32
+		r.ReadUint32(&v)
+		t.Float = float64(math.Float32frombits(v))
+	case TagDouble:
+		var v uint64
+		r.ReadUint64(&v)
+		t.Float = math.Float64frombits(v)
+	case TagByteArray:
+		n, err := d.readLength(t.Tag)
+		if err != nil {
+			return err
+		}
+		t.Bytes = r.ReadBytes(n)
+		if t.Bytes == nil && r.Err() == nil {
+			t.Bytes = []byte{}
+		}
+	case TagString:
+		var err error
+		t.String, err = d.readString()
+		return err
+	case TagIntArray:
+		n, err := d.readLength(t.Tag)
+		if err != nil {
+			return err
+		}
+		t.Ints = make([]int32, n)
+		for i := range t.Ints {
+			r.ReadInt32(&t.Ints[i])
+		}
+	case TagLongArray:
+		n, err := d.readLength(t.Tag)
+		if err != nil {
+			return err
+		}
+		t.Longs = make([]int64, n)
+		for i := range t.Longs {
+			r.ReadInt64(&t.Longs[i])
+		}
+	case TagList, TagCompound:
+		if len(d.stack) >= MAX_DEPTH {
+			return fmt.Errorf("%w: more than %d levels", ErrTooDeep, MAX_DEPTH)
+		}
+		if t.Tag == TagCompound {
+			d.stack = append(d.stack, frame{})
+			break
+		}
+		elem, err := d.readTag()
+		if err != nil {
+			return unexpected(err)
+		}
+		n, err := d.readLength(t.Tag)
+		if err != nil {
+			return err
+		}
+		if elem == TagEnd && n > 0 {
+			return fmt.Errorf("%w: list of %d End tags", ErrInvalidTag, n)
+		}
+		t.Elem, t.Len = elem, n
+		d.stack = append(d.stack, frame{list: true, elem: elem, left: n})
+	}
+	return unexpected(r.Err())
+}
+
+// Skip reads and discards tokens up to and including the End that closes
+// the innermost open compound or list.
+func (d *Decoder) Skip() error {
+	depth := len(d.stack)
+	for len(d.stack) >= depth && depth > 0 {
+		if _, err := d.Token(); err != nil {
+			return unexpected(err)
+		}
+	}
+	return nil
+}
+
+// Encoder writes NBT data as tokens.
+type Encoder struct {
+	w       *codec.Writer
+	stack   []frame
+	unnamed bool
+}
+
+// NewEncoder returns an Encoder writing to w.
+func NewEncoder(w *codec.Writer) *Encoder { return &Encoder{w: w} }
+
+// WithUnnamedRoot writes root tags without a name, as in network NBT since
+// Java edition 1.20.2, and returns the Encoder for chaining.
+func (e *Encoder) WithUnnamedRoot() *Encoder {
+	e.unnamed = true
+	return e
+}
+
+func (e *Encoder) writeString(s string) error {
+	b := encodeMUTF8(s)
+	if len(b) > math.MaxUint16 {
+		return fmt.Errorf("%w: string of %d bytes", codec.ErrLengthOverflow, len(b))
+	}
+	e.w.WriteUint16(uint16(len(b)))
+	e.w.WriteBytes(b)
+	return nil
+}
+
+func (e *Encoder) writeLength(n int) error {
+	if n > math.MaxInt32 {
+		return fmt.Errorf("%w: %d elements", codec.ErrLengthOverflow, n)
+	}
+	e.w.WriteInt32(int32(n))
+	return nil
+}
+
+// WriteToken writes t. Tokens must be balanced: lists receive exactly Len
+// elements of their Elem tag, and every compound and list is closed by a
+// TagEnd token.
+func (e *Encoder) WriteToken(t Token) error {
+	if t.Tag > TagLongArray {
+		return fmt.Errorf("%w: type %d", ErrInvalidTag, byte(t.Tag))
+	}
+	if t.Tag == TagEnd {
+		if len(e.stack) == 0 {
+			return fmt.Errorf("%w: End with nothing open", ErrUnbalanced)
+		}
+		top := e.stack[len(e.stack)-1]
+		if top.list && top.left > 0 {
+			return fmt.Errorf("%w: list closed with %d elements to go", ErrUnbalanced, top.left)
+		}
+		if !top.list {
+			e.w.WriteUint8(byte(TagEnd))
+		}
+		e.stack = e.stack[:len(e.stack)-1]
+		return e.w.Err()
+	}
+
+	switch {
+	case len(e.stack) == 0:
+		e.w.WriteUint8(byte(t.Tag))
+		if !e.unnamed {
+			if err := e.writeString(t.Name); err != nil {
+				return err
+			}
+		}
+	case e.stack[len(e.stack)-1].list:
+		top := &e.stack[len(e.stack)-1]
+		if top.left == 0 || top.elem != t.Tag {
+			return fmt.Errorf("%w: %s in a list of %s with %d to go", ErrUnbalanced, t.Tag, top.elem, top.left)
+		}
+		top.left--
+	default:
+		e.w.WriteUint8(byte(t.Tag))
+		if err := e.writeString(t.Name); err != nil {
+			return err
+		}
+	}
+
+	w := e.w
+	switch t.Tag {
+	case TagByte:
+		w.WriteInt8(int8(t.Int))
+	case TagShort:
+		w.WriteInt16(int16(t.Int))
+	case TagInt:
+		w.WriteInt32(int32(t.Int))
+	case TagLong:
+		w.WriteInt64(t.Int)
+	case TagFloat:
+		w.WriteUint32(math.Float32bits(float32(t.Float)))
+	case TagDouble:
+		w.WriteUint64(math.Float64bits(t.Float))
+	case TagByteArray:
+		if err := e.writeLength(len(t.Bytes)); err != nil {
+			return err
+		}
+		w.WriteBytes(t.Bytes)
+	case TagString:
+		if err := e.writeString(t.String); err != nil {
+			return err
+		}
+	case TagIntArray:
+		if err := e.writeLength(len(t.Ints)); err != nil {
+			return err
+		}
+		for _, v := range t.Ints {
+			w.WriteInt32(v)
+		}
+	case TagLongArray:
+		if err := e.writeLength(len(t.Longs)); err != nil {
+			return err
+		}
+		for _, v := range t.Longs {
+			w.WriteInt64(v)
+		}
+	case TagCompound:
+		e.stack = append(e.stack, frame{})
+	case TagList:
+		if t.Elem > TagLongArray || t.Elem == TagEnd && t.Len > 0 {
+			return fmt.Errorf("%w: list of %s", ErrInvalidTag, t.Elem)
+		}
+		w.WriteUint8(byte(t.Elem))
+		if err := e.writeLength(t.Len); err != nil {
+			return err
+		}
+		e.stack = append(e.stack, frame{list: true, elem: t.Elem, left: t.Len})
+	}
+	return w.Err()
+}
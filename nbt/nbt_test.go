@@ -0,0 +1,132 @@
+//go:build test
+
+package nbt
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"io"
+	"testing"
+
+	"github.com/oy3o/codec"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// hello_world.nbt from the original specification.
+const helloWorld = "\x0a\x00\x0bhello world\x08\x00\x04name\x00\x09Bananrama\x00"
+
+func TestTokens(t *testing.T) {
+	r, _ := codec.NewReader(bytes.NewReader([]byte(helloWorld)))
+	d := NewDecoder(r)
+	var tokens []Token
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		tokens = append(tokens, tok)
+	}
+	assert.Equal(t, []Token{
+		{Tag: TagCompound, Name: "hello world"},
+		{Tag: TagString, Name: "name", String: "Bananrama"},
+		{Tag: TagEnd},
+	}, tokens)
+
+	var buf bytes.Buffer
+	w, _ := codec.NewWriter(&buf)
+	e := NewEncoder(w)
+	for _, tok := range tokens {
+		require.NoError(t, e.WriteToken(tok))
+	}
+	require.NoError(t, w.Flush())
+	assert.Equal(t, helloWorld, buf.String())
+
+	assert.ErrorIs(t, e.WriteToken(Token{Tag: TagEnd}), ErrUnbalanced)
+	require.NoError(t, e.WriteToken(Token{Tag: TagList, Elem: TagInt, Len: 1}))
+	assert.ErrorIs(t, e.WriteToken(Token{Tag: TagByte}), ErrUnbalanced)
+	assert.ErrorIs(t, e.WriteToken(Token{Tag: TagEnd}), ErrUnbalanced)
+}
+
+func TestValues(t *testing.T) {
+	root := Compound{
+		"byte":   int8(-1),
+		"short":  int16(300),
+		"int":    int32(1 << 20),
+		"long":   int64(-1 << 40),
+		"float":  float32(0.5),
+		"double": 2.25,
+		"bytes":  []byte{1, 2, 3},
+		"name":   "nul\x00 and \U0001F600",
+		"ints":   []int32{1, -1},
+		"longs":  []int64{1 << 40},
+		"list":   List{Elem: TagCompound, Items: []any{Compound{"x": int32(1)}, Compound{}}},
+		"empty":  List{Elem: TagEnd, Items: []any{}},
+		"nested": List{Elem: TagList, Items: []any{List{Elem: TagShort, Items: []any{int16(7)}}}},
+	}
+	for _, order := range []binary.ByteOrder{codec.BE, codec.LE} {
+		var buf bytes.Buffer
+		w, _ := codec.NewWriter(&buf)
+		w.WithByteOrder(order)
+		require.NoError(t, WriteValue(NewEncoder(w), "Level", root))
+		require.NoError(t, w.Flush())
+		// Modified UTF-8 never contains a zero byte inside a string.
+		assert.NotContains(t, buf.String(), "nul\x00")
+
+		r, _ := codec.NewReader(&buf)
+		r.WithByteOrder(order)
+		name, v, err := ReadValue(NewDecoder(r))
+		require.NoError(t, err)
+		assert.Equal(t, "Level", name)
+		assert.Equal(t, root, v)
+		_, _, err = ReadValue(NewDecoder(r))
+		assert.Equal(t, io.EOF, err)
+	}
+}
+
+func TestGzipAndLimits(t *testing.T) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write([]byte(helloWorld))
+	zw.Close()
+	r, err := NewReader(&buf)
+	require.NoError(t, err)
+	name, v, err := ReadValue(NewDecoder(r))
+	require.NoError(t, err)
+	assert.Equal(t, "hello world", name)
+	assert.Equal(t, Compound{"name": "Bananrama"}, v)
+
+	r, _ = NewReader(bytes.NewReader([]byte(helloWorld)))
+	d := NewDecoder(r)
+	_, err = d.Token()
+	require.NoError(t, err)
+	require.NoError(t, d.Skip())
+	assert.Zero(t, d.Depth())
+
+	// Unnamed roots, as in network NBT.
+	r, _ = codec.NewReader(bytes.NewReader([]byte("\x08\x00\x02hi")))
+	_, v, err = ReadValue(NewDecoder(r).WithUnnamedRoot())
+	require.NoError(t, err)
+	assert.Equal(t, "hi", v)
+
+	// Lists of one list each, nested past MAX_DEPTH.
+	deep := bytes.Repeat([]byte("\x09\x00\x00\x00\x01"), MAX_DEPTH+1)
+	r, _ = codec.NewReader(bytes.NewReader(append([]byte("\x09\x00\x00"), deep...)))
+	_, _, err = ReadValue(NewDecoder(r))
+	assert.ErrorIs(t, err, ErrTooDeep)
+
+	r, _ = codec.NewReader(bytes.NewReader([]byte(helloWorld[:20])))
+	_, _, err = ReadValue(NewDecoder(r))
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	r, _ = codec.NewReader(bytes.NewReader([]byte("\x0b\x00\x00\x7f\xff\xff\xff")))
+	_, _, err = ReadValue(NewDecoder(r))
+	assert.ErrorIs(t, err, codec.ErrLengthOverflow)
+	r, _ = codec.NewReaderOpts(bytes.NewReader([]byte("\x07\x00\x00\x00\x10\x00\x00")), codec.WithMaxAlloc(1<<10))
+	_, _, err = ReadValue(NewDecoder(r))
+	assert.ErrorIs(t, err, codec.ErrLengthOverflow)
+	r, _ = codec.NewReader(bytes.NewReader([]byte("\x0d\x00\x00")))
+	_, _, err = ReadValue(NewDecoder(r))
+	assert.ErrorIs(t, err, ErrInvalidTag)
+}
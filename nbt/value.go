@@ -0,0 +1,174 @@
+package nbt
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+)
+
+// Compound is a decoded compound tag.
+type Compound map[string]any
+
+// List is a decoded list tag. Elem records the element tag, so that empty
+// lists keep it.
+type List struct {
+	Elem  Tag
+	Items []any
+}
+
+// Values of the tree API have these Go types:
+//
+//	Byte int8, Short int16, Int int32, Long int64, Float float32,
+//	Double float64, ByteArray []byte, String string, List List,
+//	Compound Compound, IntArray []int32, LongArray []int64
+func tagOf(v any) (Tag, error) {
+	switch v.(type) {
+	case int8:
+		return TagByte, nil
+	case int16:
+		return TagShort, nil
+	case int32:
+		return TagInt, nil
+	case int64:
+		return TagLong, nil
+	case float32:
+		return TagFloat, nil
+	case float64:
+		return TagDouble, nil
+	case []byte:
+		return TagByteArray, nil
+	case string:
+		return TagString, nil
+	case List:
+		return TagList, nil
+	case Compound:
+		return TagCompound, nil
+	case []int32:
+		return TagIntArray, nil
+	case []int64:
+		return TagLongArray, nil
+	}
+	return TagEnd, fmt.Errorf("%w: no tag for %T", ErrInvalidTag, v)
+}
+
+// ReadValue reads the next complete tag from d and returns its name and
+// value. It returns io.EOF at the end of the stream.
+func ReadValue(d *Decoder) (string, any, error) {
+	t, err := d.Token()
+	if err != nil {
+		return "", nil, err
+	}
+	if t.Tag == TagEnd {
+		return "", nil, fmt.Errorf("%w: End where a value was expected", ErrUnbalanced)
+	}
+	v, err := value(d, t)
+	return t.Name, v, err
+}
+
+func value(d *Decoder, t Token) (any, error) {
+	switch t.Tag {
+	case TagByte:
+		return int8(t.Int), nil
+	case TagShort:
+		return int16(t.Int), nil
+	case TagInt:
+		return int32(t.Int), nil
+	case TagLong:
+		return t.Int, nil
+	case TagFloat:
+		return float32(t.Float), nil
+	case TagDouble:
+		return t.Float, nil
+	case TagByteArray:
+		return t.Bytes, nil
+	case TagString:
+		return t.String, nil
+	case TagIntArray:
+		return t.Ints, nil
+	case TagLongArray:
+		return t.Longs, nil
+	case TagList:
+		l := List{Elem: t.Elem, Items: make([]any, 0, min(t.Len, 1024))}
+		for {
+			item, err := d.Token()
+			if err != nil {
+				return nil, unexpected(err)
+			}
+			if item.Tag == TagEnd && len(l.Items) == t.Len {
+				return l, nil
+			}
+			v, err := value(d, item)
+			if err != nil {
+				return nil, err
+			}
+			l.Items = append(l.Items, v)
+		}
+	}
+	c := Compound{}
+	for {
+		field, err := d.Token()
+		if err != nil {
+			return nil, unexpected(err)
+		}
+		if field.Tag == TagEnd {
+			return c, nil
+		}
+		if c[field.Name], err = value(d, field); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// WriteValue writes v as a complete tag named name; the name is ignored
+// inside lists. Compound fields are written in sorted order.
+func WriteValue(e *Encoder, name string, v any) error {
+	tag, err := tagOf(v)
+	if err != nil {
+		return err
+	}
+	t := Token{Tag: tag, Name: name}
+	switch v := v.(type) {
+	case int8:
+		t.Int = int64(v)
+	case int16:
+		t.Int = int64(v)
+	case int32:
+		t.Int = int64(v)
+	case int64:
+		t.Int = v
+	case float32:
+		t.Float = float64(v)
+	case float64:
+		t.Float = v
+	case []byte:
+		t.Bytes = v
+	case string:
+		t.String = v
+	case []int32:
+		t.Ints = v
+	case []int64:
+		t.Longs = v
+	case List:
+		t.Elem, t.Len = v.Elem, len(v.Items)
+		if err := e.WriteToken(t); err != nil {
+			return err
+		}
+		for _, item := range v.Items {
+			if err := WriteValue(e, "", item); err != nil {
+				return err
+			}
+		}
+		return e.WriteToken(Token{Tag: TagEnd})
+	case Compound:
+		if err := e.WriteToken(t); err != nil {
+			return err
+		}
+		for _, k := range slices.Sorted(maps.Keys(v)) {
+			if err := WriteValue(e, k, v[k]); err != nil {
+				return err
+			}
+		}
+		return e.WriteToken(Token{Tag: TagEnd})
+	}
+	return e.WriteToken(t)
+}
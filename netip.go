@@ -0,0 +1,226 @@
+package codec
+
+import (
+	"fmt"
+	"io"
+	"net/netip"
+)
+
+// Address family numbers used by the "family byte + address" layout.
+// The values follow the IANA address family registry, as used by STUN and PROXY v2.
+const (
+	FamilyIPv4 uint8 = 1
+	FamilyIPv6 uint8 = 2
+)
+
+// ReadAddr4 reads a 4-byte IPv4 address.
+func (r *Reader) ReadAddr4(dest *netip.Addr) {
+	var b [4]byte
+	r.ReadBytesTo(b[:])
+	if r.err == nil {
+		*dest = netip.AddrFrom4(b)
+	}
+}
+
+// ReadAddr16 reads a 16-byte IPv6 (or IPv4-mapped) address.
+func (r *Reader) ReadAddr16(dest *netip.Addr) {
+	var b [16]byte
+	r.ReadBytesTo(b[:])
+	if r.err == nil {
+		*dest = netip.AddrFrom16(b)
+	}
+}
+
+// ReadAddrFamily reads a family byte followed by a 4- or 16-byte address.
+func (r *Reader) ReadAddrFamily(dest *netip.Addr) {
+	var family uint8
+	r.ReadUint8(&family)
+	switch {
+	case r.err != nil:
+	case family == FamilyIPv4:
+		r.ReadAddr4(dest)
+	case family == FamilyIPv6:
+		r.ReadAddr16(dest)
+	default:
+		r.setError(fmt.Errorf("%w: %d", ErrInvalidAddrFamily, family))
+	}
+}
+
+// ReadAddrPort reads an address with ReadAddrFamily followed by a 16-bit port.
+func (r *Reader) ReadAddrPort(dest *netip.AddrPort) {
+	var addr netip.Addr
+	var port uint16
+	r.ReadAddrFamily(&addr)
+	r.ReadUint16(&port)
+	if r.err == nil {
+		*dest = netip.AddrPortFrom(addr, port)
+	}
+}
+
+// WriteAddr writes the raw address bytes: 4 bytes for IPv4, 16 bytes otherwise.
+func (w *Writer) WriteAddr(v netip.Addr) {
+	if w.err != nil {
+		return
+	}
+	if !v.IsValid() {
+		w.setError(ErrInvalidAddr)
+		return
+	}
+	if v.Is4() {
+		b := v.As4()
+		_, _ = w.Write(b[:])
+	} else {
+		b := v.As16()
+		_, _ = w.Write(b[:])
+	}
+}
+
+// WriteAddr16 writes the address in its 16-byte form, mapping IPv4 addresses
+// into IPv6 space.
+func (w *Writer) WriteAddr16(v netip.Addr) {
+	if w.err != nil {
+		return
+	}
+	if !v.IsValid() {
+		w.setError(ErrInvalidAddr)
+		return
+	}
+	b := v.As16()
+	_, _ = w.Write(b[:])
+}
+
+// WriteAddrFamily writes a family byte followed by the raw address bytes.
+func (w *Writer) WriteAddrFamily(v netip.Addr) {
+	if w.err != nil {
+		return
+	}
+	if !v.IsValid() {
+		w.setError(ErrInvalidAddr)
+		return
+	}
+	if v.Is4() {
+		w.WriteUint8(FamilyIPv4)
+	} else {
+		w.WriteUint8(FamilyIPv6)
+	}
+	w.WriteAddr(v)
+}
+
+// WriteAddrPort writes an address with WriteAddrFamily followed by a 16-bit port.
+func (w *Writer) WriteAddrPort(v netip.AddrPort) {
+	w.WriteAddrFamily(v.Addr())
+	w.WriteUint16(v.Port())
+}
+
+// Addr is a Codec for netip.Addr using the "family byte + address" layout.
+type Addr struct {
+	netip.Addr
+}
+
+// AddrPort is a Codec for netip.AddrPort using the "family byte + address + port" layout.
+type AddrPort struct {
+	netip.AddrPort
+}
+
+var (
+	_ Codec = (*Addr)(nil)
+	_ Codec = (*AddrPort)(nil)
+)
+
+func addrSize(a netip.Addr) int {
+	if a.Is4() {
+		return 1 + 4
+	}
+	return 1 + 16
+}
+
+func (a *Addr) Size() int { return addrSize(a.Addr) }
+
+func (a *Addr) WriteTo(writer io.Writer) (int64, error) {
+	w, err := NewWriter(writer)
+	if err != nil {
+		return 0, err
+	}
+	w.WriteAddrFamily(a.Addr)
+	return w.Result()
+}
+
+// ReadFrom reads the address directly from r without buffering, so no bytes
+// beyond the address are consumed.
+func (a *Addr) ReadFrom(r io.Reader) (int64, error) {
+	var b [17]byte
+	n, err := io.ReadFull(r, b[:1])
+	if err != nil {
+		return int64(n), err
+	}
+	switch b[0] {
+	case FamilyIPv4:
+		m, err := io.ReadFull(r, b[1:5])
+		if err != nil {
+			return int64(n + m), err
+		}
+		a.Addr = netip.AddrFrom4([4]byte(b[1:5]))
+		return int64(n + m), nil
+	case FamilyIPv6:
+		m, err := io.ReadFull(r, b[1:17])
+		if err != nil {
+			return int64(n + m), err
+		}
+		a.Addr = netip.AddrFrom16([16]byte(b[1:17]))
+		return int64(n + m), nil
+	default:
+		return int64(n), fmt.Errorf("%w: %d", ErrInvalidAddrFamily, b[0])
+	}
+}
+
+func (a *Addr) MarshalBinary() ([]byte, error) {
+	return MarshalBinaryGeneric(a)
+}
+
+func (a *Addr) UnmarshalBinary(data []byte) error {
+	return UnmarshalBinaryGeneric(a, data)
+}
+
+func (a *Addr) MarshalTo(buf []byte) (int, error) {
+	return MarshalToGeneric(a, buf)
+}
+
+func (a *AddrPort) Size() int { return addrSize(a.Addr()) + 2 }
+
+func (a *AddrPort) WriteTo(writer io.Writer) (int64, error) {
+	w, err := NewWriter(writer)
+	if err != nil {
+		return 0, err
+	}
+	w.WriteAddrPort(a.AddrPort)
+	return w.Result()
+}
+
+// ReadFrom reads the address and port directly from r without buffering.
+func (a *AddrPort) ReadFrom(r io.Reader) (int64, error) {
+	var addr Addr
+	n, err := addr.ReadFrom(r)
+	if err != nil {
+		return n, err
+	}
+	var b [2]byte
+	m, err := io.ReadFull(r, b[:])
+	n += int64(m)
+	if err != nil {
+		return n, err
+	}
+	a.AddrPort = netip.AddrPortFrom(addr.Addr, streamOrder(r).Uint16(b[:]))
+	return n, nil
+}
+
+func (a *AddrPort) MarshalBinary() ([]byte, error) {
+	return MarshalBinaryGeneric(a)
+}
+
+func (a *AddrPort) UnmarshalBinary(data []byte) error {
+	return UnmarshalBinaryGeneric(a, data)
+}
+
+func (a *AddrPort) MarshalTo(buf []byte) (int, error) {
+	return MarshalToGeneric(a, buf)
+}
@@ -0,0 +1,30 @@
+//go:build test
+
+package codec
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAddrPortCodec(t *testing.T) {
+	for _, s := range []string{"192.0.2.1:80", "[2001:db8::1]:8443"} {
+		in := &AddrPort{netip.MustParseAddrPort(s)}
+		data, err := in.MarshalBinary()
+		require.NoError(t, err)
+		assert.Len(t, data, in.Size())
+
+		var out AddrPort
+		require.NoError(t, out.UnmarshalBinary(data))
+		assert.Equal(t, in.AddrPort, out.AddrPort)
+	}
+}
+
+func TestAddrCodecUnknownFamily(t *testing.T) {
+	var a Addr
+	err := a.UnmarshalBinary([]byte{9, 1, 2, 3, 4})
+	assert.ErrorIs(t, err, ErrInvalidAddrFamily)
+}
@@ -0,0 +1,235 @@
+//go:build !tinygo && !codec_tiny
+
+// Package nvme encodes and decodes NVMe submission queue entries (64-byte
+// commands) and completion queue entries (16 bytes), little-endian as the
+// controller reads them from memory. Fields packed into a single byte are
+// codec.Fixed bitfields; those spread over command dwords, such as the
+// number of blocks and FUA bit of a Read, have accessors instead.
+package nvme
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/oy3o/codec"
+)
+
+// Admin command opcodes.
+const (
+	ADMIN_DELETE_IO_SQ = 0x00
+	ADMIN_CREATE_IO_SQ = 0x01
+	ADMIN_GET_LOG_PAGE = 0x02
+	ADMIN_DELETE_IO_CQ = 0x04
+	ADMIN_CREATE_IO_CQ = 0x05
+	ADMIN_IDENTIFY     = 0x06
+	ADMIN_ABORT        = 0x08
+	ADMIN_SET_FEATURES = 0x09
+	ADMIN_GET_FEATURES = 0x0A
+)
+
+// NVM command set opcodes.
+const (
+	FLUSH              = 0x00
+	WRITE              = 0x01
+	READ               = 0x02
+	WRITE_ZEROES       = 0x08
+	DATASET_MANAGEMENT = 0x09
+)
+
+// Identify CNS values, selecting the data structure returned.
+const (
+	CNS_NAMESPACE      = 0x00
+	CNS_CONTROLLER     = 0x01
+	CNS_ACTIVE_NS_LIST = 0x02
+	CNS_NS_DESCRIPTORS = 0x03
+)
+
+const (
+	// COMMAND_SIZE is the size of a submission queue entry.
+	COMMAND_SIZE = 64
+	// COMPLETION_SIZE is the size of a completion queue entry.
+	COMPLETION_SIZE = 16
+	// MAX_BLOCKS is the largest number of blocks of a Read or Write.
+	MAX_BLOCKS = 1 << 16
+)
+
+// ErrInvalidEntry indicates a queue entry of the wrong size.
+var ErrInvalidEntry = errors.New("nvme: invalid queue entry")
+
+// Command is a submission queue entry. PRP1 and PRP2 hold the data pointer,
+// as PRP entries or, per PSDT, an SGL descriptor.
+type Command struct {
+	Opcode uint8
+	PSDT   uint8 `codec:"bits=2"` // PRP or SGL data transfer
+	_      uint8 `codec:"bits=4"`
+	Fuse   uint8 `codec:"bits=2"` // fused operation
+	CID    uint16
+	NSID   uint32
+	CDW2   uint32
+	CDW3   uint32
+	MPTR   uint64 // metadata pointer
+	PRP1   uint64
+	PRP2   uint64
+	CDW10  uint32
+	CDW11  uint32
+	CDW12  uint32
+	CDW13  uint32
+	CDW14  uint32
+	CDW15  uint32
+}
+
+// NewRead returns a Read of n blocks from slba of namespace nsid into the
+// buffer at prp1 and prp2. It fails with codec.ErrLengthOverflow if n is 0
+// or exceeds MAX_BLOCKS.
+func NewRead(nsid uint32, slba uint64, n int, prp1, prp2 uint64) (Command, error) {
+	c := Command{Opcode: READ, NSID: nsid, PRP1: prp1, PRP2: prp2}
+	c.SetSLBA(slba)
+	return c, c.SetBlocks(n)
+}
+
+// NewWrite returns a Write of n blocks to slba of namespace nsid from the
+// buffer at prp1 and prp2. It fails with codec.ErrLengthOverflow if n is 0
+// or exceeds MAX_BLOCKS.
+func NewWrite(nsid uint32, slba uint64, n int, prp1, prp2 uint64) (Command, error) {
+	c, err := NewRead(nsid, slba, n, prp1, prp2)
+	c.Opcode = WRITE
+	return c, err
+}
+
+// NewIdentify returns an Identify of the structure cns selects, returned
+// into the 4 KiB buffer at prp1.
+func NewIdentify(cns uint8, nsid uint32, prp1 uint64) Command {
+	return Command{Opcode: ADMIN_IDENTIFY, NSID: nsid, PRP1: prp1, CDW10: uint32(cns)}
+}
+
+// SLBA returns the starting LBA of a Read or Write, from CDW10 and CDW11.
+func (c *Command) SLBA() uint64 { return uint64(c.CDW11)<<32 | uint64(c.CDW10) }
+
+// SetSLBA sets the starting LBA of a Read or Write.
+func (c *Command) SetSLBA(lba uint64) { c.CDW10, c.CDW11 = uint32(lba), uint32(lba>>32) }
+
+// Blocks returns the number of blocks of a Read or Write, which CDW12
+// holds 0-based.
+func (c *Command) Blocks() int { return int(c.CDW12&0xFFFF) + 1 }
+
+// SetBlocks sets the number of blocks of a Read or Write. It fails with
+// codec.ErrLengthOverflow, leaving c unchanged, if n is 0 or exceeds
+// MAX_BLOCKS.
+func (c *Command) SetBlocks(n int) error {
+	if n <= 0 || n > MAX_BLOCKS {
+		return fmt.Errorf("%w: %d blocks, want 1 to %d", codec.ErrLengthOverflow, n, MAX_BLOCKS)
+	}
+	c.CDW12 = c.CDW12&^0xFFFF | uint32(n-1)
+	return nil
+}
+
+// FUA reports whether a Read or Write bypasses the volatile write cache.
+func (c *Command) FUA() bool { return c.CDW12&(1<<30) != 0 }
+
+// SetFUA sets the force unit access bit of a Read or Write.
+func (c *Command) SetFUA(on bool) { c.CDW12 = setBit(c.CDW12, 30, on) }
+
+// LimitedRetry reports whether a Read or Write limits error recovery.
+func (c *Command) LimitedRetry() bool { return c.CDW12&(1<<31) != 0 }
+
+// SetLimitedRetry sets the limited retry bit of a Read or Write.
+func (c *Command) SetLimitedRetry(on bool) { c.CDW12 = setBit(c.CDW12, 31, on) }
+
+func setBit(v uint32, bit int, on bool) uint32 {
+	if on {
+		return v | 1<<bit
+	}
+	return v &^ (1 << bit)
+}
+
+// Completion is a completion queue entry.
+type Completion struct {
+	DW0    uint32 // command specific
+	DW1    uint32
+	SQHead uint16 // submission queue head pointer
+	SQID   uint16
+	CID    uint16
+	Status uint16 // phase tag and status field
+}
+
+// Status code types.
+const (
+	GENERIC          = 0x0
+	COMMAND_SPECIFIC = 0x1
+	MEDIA_ERROR      = 0x2
+	PATH_RELATED     = 0x3
+	VENDOR_SPECIFIC  = 0x7
+)
+
+// Phase returns the phase tag, which the controller inverts on each pass
+// through the queue so the host can tell new entries from old.
+func (c *Completion) Phase() bool { return c.Status&1 != 0 }
+
+// StatusCode returns the status code, 0 for success.
+func (c *Completion) StatusCode() uint8 { return uint8(c.Status >> 1) }
+
+// StatusCodeType returns the status code type.
+func (c *Completion) StatusCodeType() uint8 { return uint8(c.Status>>9) & 0x7 }
+
+// RetryDelay returns the command retry delay index.
+func (c *Completion) RetryDelay() uint8 { return uint8(c.Status>>12) & 0x3 }
+
+// More reports whether the error log has more information.
+func (c *Completion) More() bool { return c.Status&(1<<14) != 0 }
+
+// DoNotRetry reports whether retrying the command is expected to fail too.
+func (c *Completion) DoNotRetry() bool { return c.Status&(1<<15) != 0 }
+
+// OK reports whether the command succeeded.
+func (c *Completion) OK() bool { return c.StatusCode() == 0 && c.StatusCodeType() == 0 }
+
+// Err returns an error describing a failed command, or nil.
+func (c *Completion) Err() error {
+	if c.OK() {
+		return nil
+	}
+	return &StatusError{Type: c.StatusCodeType(), Code: c.StatusCode(), DNR: c.DoNotRetry()}
+}
+
+// StatusError is the status of a failed command.
+type StatusError struct {
+	Type uint8
+	Code uint8
+	DNR  bool
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("nvme: status type 0x%x code 0x%02x", e.Type, e.Code)
+}
+
+// ParseCommand decodes a submission queue entry into c without allocating.
+func ParseCommand(b []byte, c *Command) error { return parse(b, c, COMMAND_SIZE) }
+
+// AppendCommand appends the encoding of c to dst.
+func AppendCommand(dst []byte, c *Command) ([]byte, error) { return appendEntry(dst, c) }
+
+// ParseCompletion decodes a completion queue entry into c without
+// allocating.
+func ParseCompletion(b []byte, c *Completion) error { return parse(b, c, COMPLETION_SIZE) }
+
+// AppendCompletion appends the encoding of c to dst.
+func AppendCompletion(dst []byte, c *Completion) ([]byte, error) { return appendEntry(dst, c) }
+
+func parse[T any](b []byte, v *T, size int) error {
+	if len(b) != size {
+		return fmt.Errorf("%w: %T of %d bytes", ErrInvalidEntry, *v, len(b))
+	}
+	f := codec.Fixed[T]{}
+	f.WithByteOrder(codec.LE)
+	if err := f.UnmarshalBinary(b); err != nil {
+		return err
+	}
+	*v = f.Payload
+	return nil
+}
+
+func appendEntry[T any](dst []byte, v *T) ([]byte, error) {
+	f := codec.Fixed[T]{Payload: *v}
+	f.WithByteOrder(codec.LE)
+	return f.MarshalAppend(dst)
+}
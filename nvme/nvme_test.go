@@ -0,0 +1,75 @@
+//go:build test && !tinygo && !codec_tiny
+
+package nvme
+
+import (
+	"testing"
+
+	"github.com/oy3o/codec"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommand(t *testing.T) {
+	c, err := NewRead(1, 0x1_0000_0010, 8, 0x1000, 0)
+	require.NoError(t, err)
+	c.CID = 0x42
+	c.PSDT = 1
+	c.SetFUA(true)
+	b, err := AppendCommand(nil, &c)
+	require.NoError(t, err)
+	require.Len(t, b, COMMAND_SIZE)
+	assert.Equal(t, []byte{READ, 0x40, 0x42, 0x00, 1, 0, 0, 0}, b[:8])
+	assert.Equal(t, []byte{0x00, 0x10, 0, 0, 0, 0, 0, 0}, b[24:32])
+	assert.Equal(t, []byte{0x10, 0, 0, 0, 1, 0, 0, 0, 7, 0, 0, 0x40}, b[40:52])
+
+	var out Command
+	require.NoError(t, ParseCommand(b, &out))
+	assert.Equal(t, c, out)
+	assert.Equal(t, uint64(0x1_0000_0010), out.SLBA())
+	assert.Equal(t, 8, out.Blocks())
+	assert.True(t, out.FUA())
+	assert.False(t, out.LimitedRetry())
+	out.SetFUA(false)
+	assert.False(t, out.FUA())
+
+	assert.ErrorIs(t, ParseCommand(b[:63], &out), ErrInvalidEntry)
+	assert.ErrorIs(t, c.SetBlocks(MAX_BLOCKS+1), codec.ErrLengthOverflow)
+	assert.Equal(t, 8, c.Blocks())
+	_, err = NewWrite(1, 0, 0, 0x1000, 0)
+	assert.ErrorIs(t, err, codec.ErrLengthOverflow)
+	w, err := NewWrite(1, 0, MAX_BLOCKS, 0x1000, 0)
+	require.NoError(t, err)
+	assert.Equal(t, uint8(WRITE), w.Opcode)
+	assert.Equal(t, MAX_BLOCKS, w.Blocks())
+
+	id := NewIdentify(CNS_CONTROLLER, 0, 0x2000)
+	assert.Equal(t, uint8(ADMIN_IDENTIFY), id.Opcode)
+	assert.Equal(t, uint32(CNS_CONTROLLER), id.CDW10)
+}
+
+func TestCompletion(t *testing.T) {
+	// LBA out of range, do not retry, phase 1.
+	b := []byte{0, 0, 0, 0, 0, 0, 0, 0, 0x05, 0x00, 0x01, 0x00, 0x42, 0x00, 0x01, 0x81}
+	var c Completion
+	require.NoError(t, ParseCompletion(b, &c))
+	assert.Equal(t, uint16(5), c.SQHead)
+	assert.Equal(t, uint16(1), c.SQID)
+	assert.Equal(t, uint16(0x42), c.CID)
+	assert.True(t, c.Phase())
+	assert.Equal(t, uint8(0x80), c.StatusCode())
+	assert.Equal(t, uint8(GENERIC), c.StatusCodeType())
+	assert.True(t, c.DoNotRetry())
+	assert.False(t, c.OK())
+	var se *StatusError
+	require.ErrorAs(t, c.Err(), &se)
+	assert.Equal(t, uint8(0x80), se.Code)
+
+	out, err := AppendCompletion(nil, &c)
+	require.NoError(t, err)
+	assert.Equal(t, b, out)
+
+	c.Status = 1
+	assert.True(t, c.OK())
+	assert.NoError(t, c.Err())
+}
@@ -0,0 +1,134 @@
+package codec
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"io"
+
+	"golang.org/x/text/encoding"
+)
+
+// Option configures a Reader built by NewReaderOpts or a Writer built by
+// NewWriterOpts. Options that concern only one side, such as WithTrace, are
+// ignored by the other.
+type Option func(*options)
+
+type options struct {
+	order      binary.ByteOrder
+	size       int
+	maxAlloc   int
+	maxPadding int64
+	trace      TraceFunc
+	hash       hash.Hash
+	charset    encoding.Encoding
+	interner   *Interner
+	account    *MemoryAccount
+}
+
+func newOptions(opts []Option) options {
+	o := options{order: Order, size: BUFFER_SIZE}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// WithOrder sets the byte order, in place of the global Order.
+func WithOrder(order binary.ByteOrder) Option {
+	return func(o *options) { o.order = order }
+}
+
+// WithBufferSize sets the size of the buffer allocated for sources and
+// destinations that are not already buffered; the default is BUFFER_SIZE.
+func WithBufferSize(n int) Option {
+	return func(o *options) { o.size = n }
+}
+
+// WithMaxAlloc makes a Reader fail with ErrLengthOverflow instead of
+// allocating more than n bytes for a single ReadBytes or ReadString, so a
+// corrupt length cannot exhaust memory. 0 means no limit.
+func WithMaxAlloc(n int) Option {
+	return func(o *options) { o.maxAlloc = n }
+}
+
+// WithMaxPadding is Reader.WithMaxPadding as an Option.
+func WithMaxPadding(n int64) Option {
+	return func(o *options) { o.maxPadding = n }
+}
+
+// WithTrace is Reader.WithTrace as an Option.
+func WithTrace(fn TraceFunc) Option {
+	return func(o *options) { o.trace = fn }
+}
+
+// WithHash is Reader.WithHash or Writer.WithHash as an Option. A hash must
+// not be shared by a Reader and a Writer.
+func WithHash(h hash.Hash) Option {
+	return func(o *options) { o.hash = h }
+}
+
+// WithCharset is Reader.WithCharset or Writer.WithCharset as an Option.
+func WithCharset(enc encoding.Encoding) Option {
+	return func(o *options) { o.charset = enc }
+}
+
+// WithInterner is Reader.WithInterner as an Option.
+func WithInterner(in *Interner) Option {
+	return func(o *options) { o.interner = in }
+}
+
+// WithAccount is Reader.WithAccount or Writer.WithAccount as an Option.
+func WithAccount(a *MemoryAccount) Option {
+	return func(o *options) { o.account = a }
+}
+
+// NewReaderOpts creates a Reader configured by opts. Unlike NewReader it
+// does not depend on the global Order when WithOrder is given. Sources are
+// wrapped as NewReaderSize does, with a buffer of BUFFER_SIZE unless
+// WithBufferSize says otherwise. In-memory sources (*BytesReader,
+// *bytes.Reader and *bytes.Buffer) are read directly, so WithBufferSize has
+// no effect on them:
+//
+//	r, err := codec.NewReaderOpts(conn,
+//		codec.WithOrder(codec.LE),
+//		codec.WithMaxAlloc(1<<20),
+//	)
+func NewReaderOpts(r io.Reader, opts ...Option) (*Reader, error) {
+	o := newOptions(opts)
+	cr, err := NewReaderSize(r, o.size)
+	if err != nil {
+		return nil, err
+	}
+	cr.maxAlloc = o.maxAlloc
+	cr.maxPadding = o.maxPadding
+	cr.WithByteOrder(o.order).WithTrace(o.trace).WithCharset(o.charset).WithInterner(o.interner).WithAccount(o.account)
+	if o.hash != nil {
+		cr.WithHash(o.hash)
+	}
+	return cr, nil
+}
+
+// NewWriterOpts creates a Writer configured by opts. See NewReaderOpts.
+func NewWriterOpts(w io.Writer, opts ...Option) (*Writer, error) {
+	o := newOptions(opts)
+	cw, err := NewWriterSize(w, o.size)
+	if err != nil {
+		return nil, err
+	}
+	cw.WithByteOrder(o.order).WithCharset(o.charset).WithAccount(o.account)
+	if o.hash != nil {
+		cw.WithHash(o.hash)
+	}
+	return cw, nil
+}
+
+// alloc reports whether n bytes may be allocated for one read, latching
+// ErrLengthOverflow if not.
+func (r *Reader) alloc(n int) bool {
+	if r.maxAlloc > 0 && n > r.maxAlloc {
+		r.setError(fmt.Errorf("%w: read of %d bytes exceeds %d", ErrLengthOverflow, n, r.maxAlloc))
+		return false
+	}
+	return true
+}
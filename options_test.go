@@ -0,0 +1,92 @@
+//go:build test
+
+package codec
+
+import (
+	"bytes"
+	"hash/crc32"
+	"io"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReaderOpts(t *testing.T) {
+	data := []byte{0x01, 0x02, 0x03, 0x04, 'a', 'b', 'c', 'd', 'e'}
+	var ops []string
+	h := crc32.NewIEEE()
+	r, err := NewReaderOpts(io.MultiReader(bytes.NewReader(data)),
+		WithOrder(LE),
+		WithMaxAlloc(4),
+		WithHash(h),
+		WithTrace(func(op string, off int64, n int, v any) { ops = append(ops, op) }),
+	)
+	require.NoError(t, err)
+	assert.Equal(t, BUFFER_SIZE, r.Size())
+
+	var v uint32
+	var s string
+	r.ReadUint32(&v)
+	r.ReadString(&s, 4)
+	require.NoError(t, r.Err())
+	assert.Equal(t, uint32(0x04030201), v)
+	assert.Equal(t, "abcd", s)
+	assert.Equal(t, []string{"uint32", "string"}, ops)
+	assert.Equal(t, crc32.ChecksumIEEE(data[:8]), h.Sum32())
+
+	r.ReadBytes(5)
+	assert.ErrorIs(t, r.Err(), ErrLengthOverflow)
+
+	_, err = NewReaderOpts(io.MultiReader(), WithBufferSize(1))
+	assert.ErrorIs(t, err, ErrSizeTooSmall)
+}
+
+func TestWriterOpts(t *testing.T) {
+	var buf bytes.Buffer
+	h := crc32.NewIEEE()
+	account := NewMemoryAccount(nil)
+	w, err := NewWriterOpts(struct{ io.Writer }{&buf}, WithOrder(LE), WithHash(h), WithBufferSize(64), WithAccount(account))
+	require.NoError(t, err)
+	assert.EqualValues(t, 64, account.Current())
+	w.WriteUint16(0x0102)
+	require.NoError(t, w.Flush())
+	assert.Equal(t, []byte{0x02, 0x01}, buf.Bytes())
+	assert.Equal(t, crc32.ChecksumIEEE(buf.Bytes()), h.Sum32())
+}
+
+func TestStreamOrder(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriterOpts(&buf, WithOrder(LE))
+	require.NoError(t, err)
+	schema := NewUnionSchema(TagU16).Register(0x0102, func() Codec { return &Enum[uint32]{} })
+	u := schema.New(&Enum[uint32]{0x03040506})
+	_, err = u.WriteTo(w)
+	require.NoError(t, err)
+	_, err = (&AddrPort{netip.MustParseAddrPort("1.2.3.4:258")}).WriteTo(w)
+	require.NoError(t, err)
+	require.NoError(t, w.Flush())
+	assert.Equal(t, []byte{2, 1, 6, 5, 4, 3, 1, 1, 2, 3, 4, 2, 1}, buf.Bytes())
+
+	r, err := NewReaderOpts(bytes.NewReader(buf.Bytes()), WithOrder(LE))
+	require.NoError(t, err)
+	out := &Union{Schema: schema}
+	var ap AddrPort
+	_, err = out.ReadFrom(r)
+	require.NoError(t, err)
+	_, err = ap.ReadFrom(r)
+	require.NoError(t, err)
+	assert.Equal(t, u.Value, out.Value)
+	assert.Equal(t, uint16(258), ap.Port())
+
+	// A Reader wrapping another keeps its order, and so do records read from it.
+	r, err = NewReaderOpts(bytes.NewReader([]byte{1, 2, 3}), WithOrder(LE))
+	require.NoError(t, err)
+	inner, err := NewReader(r)
+	require.NoError(t, err)
+	for rec, err := range Records(inner, RecordLayout{2, 1}) {
+		require.NoError(t, err)
+		assert.EqualValues(t, 0x0201, rec.Uint(0))
+	}
+}
@@ -0,0 +1,46 @@
+// Package codecotel bridges codec hooks to OpenTelemetry tracing. It lives in
+// its own module so the core package does not depend on OpenTelemetry.
+//
+//	tracer := otel.Tracer("rpc")
+//	f := codec.NewFramer(conn, conn).
+//		WithHook(codecotel.Hook(tracer)).
+//		WithContext(ctx)
+package codecotel
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"github.com/oy3o/codec"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Attribute keys set on codec spans.
+const (
+	NameKey  = attribute.Key("codec.name")
+	BytesKey = attribute.Key("codec.bytes")
+)
+
+// Hook returns a codec.Hook recording a span per operation, named
+// "codec.encode" or "codec.decode" and parented to the hook's context. Spans
+// carry the codec name and byte count; failed operations record the error and
+// an error status. A clean io.EOF ends a stream and is not an error.
+func Hook(tracer trace.Tracer) codec.Hook {
+	return func(ctx context.Context, op codec.ProfileOp, name string) func(int64, error) {
+		_, span := tracer.Start(ctx, "codec."+op.String(),
+			trace.WithSpanKind(trace.SpanKindInternal),
+			trace.WithAttributes(NameKey.String(name)),
+		)
+		return func(n int64, err error) {
+			span.SetAttributes(BytesKey.Int64(n))
+			if err != nil && !errors.Is(err, io.EOF) {
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
+			}
+			span.End()
+		}
+	}
+}
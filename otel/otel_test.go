@@ -0,0 +1,42 @@
+//go:build test
+
+package codecotel
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/oy3o/codec"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestHook(t *testing.T) {
+	rec := tracetest.NewSpanRecorder()
+	tracer := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec)).Tracer("test")
+	ctx, parent := tracer.Start(context.Background(), "rpc")
+
+	var buf bytes.Buffer
+	f := codec.NewFramer(&buf, &buf).WithHook(Hook(tracer)).WithContext(ctx).WithMaxSize(4)
+	require.NoError(t, f.WriteFrame([]byte("ping")))
+	_, err := f.ReadFrame()
+	require.NoError(t, err)
+	assert.Error(t, f.WriteFrame([]byte("too long")))
+	parent.End()
+
+	spans := rec.Ended()
+	require.Len(t, spans, 4)
+	enc, dec, failed := spans[0], spans[1], spans[2]
+	assert.Equal(t, "codec.encode", enc.Name())
+	assert.Equal(t, "codec.decode", dec.Name())
+	assert.Equal(t, parent.SpanContext().SpanID(), enc.Parent().SpanID())
+	assert.Contains(t, enc.Attributes(), attribute.Int64("codec.bytes", 4))
+	assert.Contains(t, dec.Attributes(), attribute.String("codec.name", "frame"))
+	assert.Equal(t, codes.Unset, dec.Status().Code)
+	assert.Equal(t, codes.Error, failed.Status().Code)
+}
@@ -0,0 +1,168 @@
+//go:build !tinygo && !codec_tiny
+
+// Package ouch decodes and encodes the messages of NASDAQ OUCH 4.2, the
+// fixed-layout order entry protocol carried by SoupBinTCP, see package
+// soupbintcp. As in package itch, each message is a type byte followed by a
+// body that codec.Fixed handles in big-endian order. Type bytes are reused
+// across directions, so inbound and outbound messages decode separately.
+package ouch
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/oy3o/codec"
+	"github.com/oy3o/codec/itch"
+)
+
+var (
+	// ErrInvalidMessage indicates a message whose length does not match its
+	// type, or decoded into a struct of another type.
+	ErrInvalidMessage = errors.New("ouch: invalid message")
+
+	// ErrUnknownMessage indicates a message type the decoders do not know.
+	ErrUnknownMessage = errors.New("ouch: unknown message type")
+)
+
+// Price is a price with 4 implied decimal places.
+type Price = itch.Price
+
+// Message is implemented by the message types, which return their type
+// byte.
+type Message interface {
+	Type() byte
+}
+
+// EnterOrder ('O', inbound) enters a new order.
+type EnterOrder struct {
+	OrderToken       [14]byte
+	BuySellIndicator byte // 'B', 'S', 'T' or 'E'
+	Shares           uint32
+	Stock            [8]byte
+	Price            Price
+	TimeInForce      uint32 // seconds, 0 for immediate or cancel
+	Firm             [4]byte
+	Display          byte
+	Capacity         byte
+	IntermarketSweep byte
+	MinimumQuantity  uint32
+	CrossType        byte
+	CustomerType     byte
+}
+
+// CancelOrder ('X', inbound) reduces an order to Shares, 0 to cancel it.
+type CancelOrder struct {
+	OrderToken [14]byte
+	Shares     uint32
+}
+
+// Accepted ('A', outbound) acknowledges an entered order.
+type Accepted struct {
+	Timestamp        uint64 // nanoseconds since midnight
+	OrderToken       [14]byte
+	BuySellIndicator byte
+	Shares           uint32
+	Stock            [8]byte
+	Price            Price
+	TimeInForce      uint32
+	Firm             [4]byte
+	Display          byte
+	OrderReference   uint64
+	Capacity         byte
+	IntermarketSweep byte
+	MinimumQuantity  uint32
+	CrossType        byte
+	OrderState       byte
+	BBOWeight        byte
+}
+
+// Canceled ('C', outbound) reports shares removed from an order.
+type Canceled struct {
+	Timestamp       uint64
+	OrderToken      [14]byte
+	DecrementShares uint32
+	Reason          byte
+}
+
+// Executed ('E', outbound) reports an execution of an order.
+type Executed struct {
+	Timestamp      uint64
+	OrderToken     [14]byte
+	ExecutedShares uint32
+	ExecutionPrice Price
+	LiquidityFlag  byte
+	MatchNumber    uint64
+}
+
+func (EnterOrder) Type() byte  { return 'O' }
+func (CancelOrder) Type() byte { return 'X' }
+func (Accepted) Type() byte    { return 'A' }
+func (Canceled) Type() byte    { return 'C' }
+func (Executed) Type() byte    { return 'E' }
+
+var inbound = map[byte]func([]byte) (Message, error){
+	'O': decode[EnterOrder],
+	'X': decode[CancelOrder],
+}
+
+var outbound = map[byte]func([]byte) (Message, error){
+	'A': decode[Accepted],
+	'C': decode[Canceled],
+	'E': decode[Executed],
+}
+
+func decode[T Message](b []byte) (Message, error) {
+	m := new(T)
+	if err := Unmarshal(b, m); err != nil {
+		return nil, err
+	}
+	return *m, nil
+}
+
+func decodeWith(decoders map[byte]func([]byte) (Message, error), b []byte) (Message, error) {
+	if len(b) == 0 {
+		return nil, fmt.Errorf("%w: empty message", ErrInvalidMessage)
+	}
+	fn, ok := decoders[b[0]]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownMessage, b[0])
+	}
+	return fn(b)
+}
+
+// DecodeInbound decodes a message sent by a client, returned by value.
+func DecodeInbound(b []byte) (Message, error) { return decodeWith(inbound, b) }
+
+// DecodeOutbound decodes a message sent by the server, returned by value.
+func DecodeOutbound(b []byte) (Message, error) { return decodeWith(outbound, b) }
+
+// Size returns the size of messages of type T, type byte included.
+func Size[T Message]() int {
+	f := codec.Fixed[T]{}
+	return 1 + f.Size()
+}
+
+// Unmarshal decodes b, a message of type T, into m without allocating.
+func Unmarshal[T Message](b []byte, m *T) error {
+	f := codec.Fixed[T]{}
+	f.WithByteOrder(codec.BE)
+	if len(b) == 0 || b[0] != (*m).Type() {
+		return fmt.Errorf("%w: not a %T", ErrInvalidMessage, *m)
+	}
+	if len(b) != 1+f.Size() {
+		return fmt.Errorf("%w: %T of %d bytes", ErrInvalidMessage, *m, len(b))
+	}
+	if err := f.UnmarshalBinary(b[1:]); err != nil {
+		return err
+	}
+	*m = f.Payload
+	return nil
+}
+
+// Append appends the encoding of m, type byte included, to dst. T must be
+// a concrete message type.
+func Append[T Message](dst []byte, m T) ([]byte, error) {
+	f := codec.Fixed[T]{Payload: m}
+	f.WithByteOrder(codec.BE)
+	return f.MarshalAppend(append(dst, m.Type()))
+}
@@ -0,0 +1,42 @@
+//go:build test && !tinygo && !codec_tiny
+
+package ouch
+
+import (
+	"testing"
+
+	"github.com/oy3o/codec/itch"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMessages(t *testing.T) {
+	// Message lengths from the OUCH 4.2 specification.
+	assert.Equal(t, 49, Size[EnterOrder]())
+	assert.Equal(t, 19, Size[CancelOrder]())
+	assert.Equal(t, 66, Size[Accepted]())
+	assert.Equal(t, 28, Size[Canceled]())
+	assert.Equal(t, 40, Size[Executed]())
+
+	order := EnterOrder{BuySellIndicator: 'B', Shares: 500, Price: 100000, TimeInForce: 99999, Display: 'Y', Capacity: 'A', IntermarketSweep: 'N', CrossType: 'N', CustomerType: 'R'}
+	itch.SetAlpha(order.OrderToken[:], "ORD1")
+	itch.SetAlpha(order.Stock[:], "MSFT")
+	itch.SetAlpha(order.Firm[:], "ABCD")
+	b, err := Append(nil, order)
+	require.NoError(t, err)
+	got, err := DecodeInbound(b)
+	require.NoError(t, err)
+	assert.Equal(t, order, got)
+
+	_, err = DecodeOutbound(b)
+	assert.ErrorIs(t, err, ErrUnknownMessage)
+
+	exec := Executed{Timestamp: 1, ExecutedShares: 200, ExecutionPrice: 99900, LiquidityFlag: 'A', MatchNumber: 42}
+	b, err = Append(nil, exec)
+	require.NoError(t, err)
+	got, err = DecodeOutbound(b)
+	require.NoError(t, err)
+	assert.Equal(t, exec, got)
+	_, err = DecodeOutbound(b[:10])
+	assert.ErrorIs(t, err, ErrInvalidMessage)
+}
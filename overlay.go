@@ -0,0 +1,114 @@
+//go:build !tinygo && !codec_tiny
+
+package codec
+
+import (
+	"encoding/binary"
+	"reflect"
+	"unsafe"
+
+	"github.com/puzpuzpuz/xsync/v4"
+)
+
+// podInfo describes whether a type's in-memory representation can be used
+// as its wire representation.
+type podInfo struct {
+	size      int  // wire size as reported by encoding/binary
+	align     int  // required memory alignment
+	identical bool // memory layout equals wire layout, ignoring byte order
+	multibyte bool // contains fields wider than one byte, so byte order matters
+}
+
+// podCache caches podInfo per type, mirroring sizeCache.
+var podCache = xsync.NewMap[reflect.Type, podInfo]()
+
+// hostLittleEndian reports the byte order of the running machine.
+var hostLittleEndian = func() bool {
+	x := uint16(1)
+	return *(*byte)(unsafe.Pointer(&x)) == 1
+}()
+
+// isLittleEndian reports whether order encodes little-endian.
+func isLittleEndian(order binary.ByteOrder) bool {
+	return order.Uint16([]byte{1, 0}) == 1
+}
+
+// podOf returns the cached podInfo of t.
+func podOf(t reflect.Type) podInfo {
+	if info, ok := podCache.Load(t); ok {
+		return info
+	}
+	info := podInfo{size: binary.Size(reflect.New(t).Interface()), align: t.Align()}
+	info.identical, info.multibyte = podLayout(t)
+	info.identical = info.identical && info.size == int(t.Size())
+	podCache.Store(t, info)
+	return info
+}
+
+// podLayout reports whether t contains no pointers and no padding, and whether
+// it contains multi-byte scalars. Booleans are excluded because aliasing bytes
+// other than 0 and 1 as a Go bool is undefined.
+func podLayout(t reflect.Type) (identical, multibyte bool) {
+	switch t.Kind() {
+	case reflect.Int8, reflect.Uint8:
+		return true, false
+	case reflect.Int16, reflect.Uint16, reflect.Int32, reflect.Uint32, reflect.Int64, reflect.Uint64,
+		reflect.Float32, reflect.Float64, reflect.Complex64, reflect.Complex128:
+		return true, true
+	case reflect.Array:
+		return podLayout(t.Elem())
+	case reflect.Struct:
+		var offset uintptr
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			ok, mb := podLayout(f.Type)
+			if !ok || f.Offset != offset || f.Name == "_" {
+				return false, false
+			}
+			multibyte = multibyte || mb
+			offset += f.Type.Size()
+		}
+		return offset == t.Size(), multibyte
+	default:
+		return false, false
+	}
+}
+
+// canAlias reports whether values of type t can be read from or written to
+// their wire encoding in order by plain memory copies.
+func canAlias(t reflect.Type, order binary.ByteOrder) bool {
+	info := podOf(t)
+	return info.identical && (!info.multibyte || isLittleEndian(order) == hostLittleEndian)
+}
+
+// Overlay returns a *T for the next binary.Size(T) bytes of r and advances r past them.
+//
+// When T contains no pointers or booleans, its memory layout has no padding,
+// the host byte order matches order, and the bytes are suitably aligned, the
+// returned pointer ALIASES r.B: no decoding or copying takes place, modifying
+// *T modifies the buffer, and the pointer must not outlive the buffer (or the
+// mapping backing it). Otherwise Overlay falls back to decoding a copy, so
+// callers always get a correct value.
+func Overlay[T any](r *BytesReader, order binary.ByteOrder) (*T, error) {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	info := podOf(t)
+	if info.size < 0 {
+		return nil, ErrNotFixedSize
+	}
+	if r.Available() < info.size {
+		return nil, ErrTruncatedData
+	}
+	b := r.B[r.N : r.N+info.size]
+
+	if info.size > 0 && canAlias(t, order) && uintptr(unsafe.Pointer(&b[0]))%uintptr(info.align) == 0 {
+		r.N += info.size
+		return (*T)(unsafe.Pointer(&b[0])), nil
+	}
+
+	v := new(T)
+	if _, err := binary.Decode(b, order, v); err != nil {
+		return nil, ErrTruncatedData
+	}
+	r.N += info.size
+	return v, nil
+}
@@ -0,0 +1,304 @@
+// Package pgwire frames the messages of the PostgreSQL frontend/backend
+// protocol on top of codec.Reader and codec.Writer, for proxies and poolers
+// that need to parse the protocol with proper limits.
+//
+// A message is a type byte followed by a big-endian int32 length that counts
+// itself and the body. The startup packet, SSLRequest, GSSENCRequest and
+// CancelRequest that open a connection have no type byte. Strings inside
+// bodies are NUL-terminated.
+//
+// Lengths are checked against the caller's limit, DEFAULT_MESSAGE_SIZE unless
+// given, or MAX_STARTUP_SIZE for the startup packet, before the body is
+// allocated, and bodies are read with codec.Reader.ReadBytes so its
+// allocation limit applies too. Malformed lengths and bodies fail with
+// ErrInvalidMessage.
+package pgwire
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+
+	"github.com/oy3o/codec"
+)
+
+// MAX_MESSAGE_SIZE is the largest message the server accepts. Pass it as the
+// limit of ReadMessage to accept everything the server does.
+const MAX_MESSAGE_SIZE = 1 << 30
+
+// DEFAULT_MESSAGE_SIZE is the limit used when a read is given a limit of 0
+// or less. The whole body is allocated once its length is known, so the
+// default stays well below MAX_MESSAGE_SIZE.
+const DEFAULT_MESSAGE_SIZE = 8 << 20
+
+// MAX_STARTUP_SIZE bounds the startup packet, as the server does.
+const MAX_STARTUP_SIZE = 10000
+
+// Startup request codes, sent in place of a protocol version.
+const (
+	PROTOCOL_3    uint32 = 3 << 16
+	CancelRequest uint32 = 80877102
+	SSLRequest    uint32 = 80877103
+	GSSENCRequest uint32 = 80877104
+)
+
+// Frontend message types.
+const (
+	Bind         byte = 'B'
+	Close        byte = 'C'
+	CopyFail     byte = 'f'
+	Describe     byte = 'D'
+	Execute      byte = 'E'
+	Flush        byte = 'H'
+	Parse        byte = 'P'
+	Password     byte = 'p' // also SASL and GSS responses
+	Query        byte = 'Q'
+	Sync         byte = 'S'
+	Terminate    byte = 'X'
+	CopyData     byte = 'd' // both directions
+	CopyDone     byte = 'c' // both directions
+	FunctionCall byte = 'F'
+)
+
+// Backend message types.
+const (
+	Authentication       byte = 'R'
+	BackendKeyData       byte = 'K'
+	BindComplete         byte = '2'
+	CloseComplete        byte = '3'
+	CommandComplete      byte = 'C'
+	CopyInResponse       byte = 'G'
+	CopyOutResponse      byte = 'H'
+	DataRow              byte = 'D'
+	EmptyQueryResponse   byte = 'I'
+	ErrorResponse        byte = 'E'
+	NoData               byte = 'n'
+	NoticeResponse       byte = 'N'
+	NotificationResponse byte = 'A'
+	ParameterDescription byte = 't'
+	ParameterStatus      byte = 'S'
+	ParseComplete        byte = '1'
+	PortalSuspended      byte = 's'
+	ReadyForQuery        byte = 'Z'
+	RowDescription       byte = 'T'
+)
+
+// ErrInvalidMessage indicates a message whose length or contents are malformed.
+var ErrInvalidMessage = errors.New("pgwire: invalid message")
+
+// Message is a typed protocol message.
+type Message struct {
+	Type byte
+	Body []byte
+}
+
+// readLength reads a length that counts itself and returns the body size.
+func readLength(r *codec.Reader, limit int) (int, error) {
+	var length int32
+	r.ReadInt32(&length)
+	if err := r.Err(); err != nil {
+		return 0, err
+	}
+	if length < 4 {
+		return 0, fmt.Errorf("%w: length %d", ErrInvalidMessage, length)
+	}
+	if int64(length) > int64(limit) {
+		return 0, fmt.Errorf("%w: message of %d bytes exceeds %d", codec.ErrLengthOverflow, length, limit)
+	}
+	return int(length) - 4, nil
+}
+
+func readBody(r *codec.Reader, n int) ([]byte, error) {
+	body := r.ReadBytes(n)
+	if err := r.Err(); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return body, nil
+}
+
+// ReadMessage reads a typed message of at most limit bytes, counting the
+// length but not the type byte; a limit of 0 or less means
+// DEFAULT_MESSAGE_SIZE. A Reader at the end of the stream returns io.EOF.
+func ReadMessage(r *codec.Reader, limit int) (Message, error) {
+	if limit <= 0 {
+		limit = DEFAULT_MESSAGE_SIZE
+	}
+	var m Message
+	r.ReadUint8(&m.Type)
+	if err := r.Err(); err != nil {
+		return m, err
+	}
+	n, err := readLength(r, limit)
+	if err != nil {
+		return m, err
+	}
+	m.Body, err = readBody(r, n)
+	return m, err
+}
+
+// WriteMessage writes a typed message.
+func WriteMessage(w *codec.Writer, typ byte, body []byte) error {
+	if len(body) > MAX_MESSAGE_SIZE-4 {
+		return fmt.Errorf("%w: message body of %d bytes", codec.ErrLengthOverflow, len(body))
+	}
+	w.WriteUint8(typ)
+	w.WriteUint32(uint32(4 + len(body)))
+	w.WriteBytes(body)
+	return w.Err()
+}
+
+// Startup is the first packet of a connection. Code is a protocol version,
+// such as PROTOCOL_3, or a request code such as SSLRequest. Params holds the
+// parameters of a startup message, and Body the raw contents after the code.
+type Startup struct {
+	Code   uint32
+	Params map[string]string
+	Body   []byte
+}
+
+// ReadStartup reads the untyped packet that opens a connection, parsing the
+// parameters of a protocol 3 startup message.
+func ReadStartup(r *codec.Reader) (Startup, error) {
+	var s Startup
+	n, err := readLength(r, MAX_STARTUP_SIZE)
+	if err != nil {
+		return s, err
+	}
+	if n < 4 {
+		return s, fmt.Errorf("%w: startup packet of %d bytes", ErrInvalidMessage, n+4)
+	}
+	body, err := readBody(r, n)
+	if err != nil {
+		return s, err
+	}
+	s.Code, s.Body = binary.BigEndian.Uint32(body), body[4:]
+	if s.Code>>16 != 3 {
+		return s, nil
+	}
+	s.Params = make(map[string]string)
+	for p := s.Body; ; {
+		key, rest, err := cutString(p)
+		if err != nil {
+			return s, err
+		}
+		if key == "" {
+			return s, nil
+		}
+		value, rest, err := cutString(rest)
+		if err != nil {
+			return s, err
+		}
+		s.Params[key] = value
+		p = rest
+	}
+}
+
+// WriteStartup writes a protocol 3 startup message carrying params, which
+// must include "user".
+func WriteStartup(w *codec.Writer, params map[string]string) error {
+	var body bytes.Buffer
+	body.Write(binary.BigEndian.AppendUint32(nil, PROTOCOL_3))
+	for _, k := range slices.Sorted(maps.Keys(params)) {
+		if err := appendString(&body, k); err != nil {
+			return err
+		}
+		if err := appendString(&body, params[k]); err != nil {
+			return err
+		}
+	}
+	body.WriteByte(0)
+	return WriteRequest(w, body.Bytes())
+}
+
+// WriteRequest writes an untyped packet, such as the 4-byte code of an
+// SSLRequest, prefixed by its length.
+func WriteRequest(w *codec.Writer, body []byte) error {
+	if len(body) > MAX_STARTUP_SIZE-4 {
+		return fmt.Errorf("%w: startup packet of %d bytes", codec.ErrLengthOverflow, len(body)+4)
+	}
+	w.WriteUint32(uint32(4 + len(body)))
+	w.WriteBytes(body)
+	return w.Err()
+}
+
+// cutString splits a NUL-terminated string off the front of p.
+func cutString(p []byte) (string, []byte, error) {
+	i := bytes.IndexByte(p, 0)
+	if i < 0 {
+		return "", nil, fmt.Errorf("%w: unterminated string", ErrInvalidMessage)
+	}
+	return string(p[:i]), p[i+1:], nil
+}
+
+func appendString(buf *bytes.Buffer, s string) error {
+	if bytes.IndexByte([]byte(s), 0) >= 0 {
+		return fmt.Errorf("%w: string %q contains NUL", ErrInvalidMessage, s)
+	}
+	buf.WriteString(s)
+	buf.WriteByte(0)
+	return nil
+}
+
+// ReadString reads a NUL-terminated string of at most limit bytes, not
+// counting the terminator, from a message body.
+func ReadString(r *codec.Reader, limit int) (string, error) {
+	var buf []byte
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return "", unexpected(err)
+		}
+		if b == 0 {
+			return string(buf), nil
+		}
+		if len(buf) == limit {
+			return "", fmt.Errorf("%w: string longer than %d bytes", codec.ErrLengthOverflow, limit)
+		}
+		buf = append(buf, b)
+	}
+}
+
+// WriteString writes s followed by a NUL. Strings containing NUL fail with
+// ErrInvalidMessage, as they cannot be framed.
+func WriteString(w *codec.Writer, s string) error {
+	if bytes.IndexByte([]byte(s), 0) >= 0 {
+		return fmt.Errorf("%w: string %q contains NUL", ErrInvalidMessage, s)
+	}
+	w.WriteString(s)
+	w.WriteUint8(0)
+	return w.Err()
+}
+
+// Fields parses the body of an ErrorResponse or NoticeResponse into its
+// fields, keyed by field type such as 'S' (severity), 'C' (SQLSTATE code) and
+// 'M' (message).
+func Fields(body []byte) (map[byte]string, error) {
+	fields := make(map[byte]string)
+	for len(body) > 0 && body[0] != 0 {
+		value, rest, err := cutString(body[1:])
+		if err != nil {
+			return nil, err
+		}
+		fields[body[0]] = value
+		body = rest
+	}
+	if len(body) == 0 {
+		return nil, fmt.Errorf("%w: unterminated fields", ErrInvalidMessage)
+	}
+	return fields, nil
+}
+
+// unexpected reports a stream ending inside a message.
+func unexpected(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,94 @@
+//go:build test
+
+package pgwire
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/oy3o/codec"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStartup(t *testing.T) {
+	var buf bytes.Buffer
+	w, _ := codec.NewWriter(&buf)
+	require.NoError(t, WriteRequest(w, []byte{0x04, 0xd2, 0x16, 0x2f}))
+	require.NoError(t, WriteStartup(w, map[string]string{"user": "app", "database": "db"}))
+	assert.Equal(t, "\x00\x00\x00\x08\x04\xd2\x16\x2f", buf.String()[:8])
+	assert.Equal(t, "\x00\x00\x00\x1e\x00\x03\x00\x00database\x00db\x00user\x00app\x00\x00", buf.String()[8:])
+
+	r, _ := codec.NewReader(&buf)
+	s, err := ReadStartup(r)
+	require.NoError(t, err)
+	assert.Equal(t, SSLRequest, s.Code)
+	assert.Nil(t, s.Params)
+	s, err = ReadStartup(r)
+	require.NoError(t, err)
+	assert.Equal(t, PROTOCOL_3, s.Code)
+	assert.Equal(t, map[string]string{"user": "app", "database": "db"}, s.Params)
+
+	r, _ = codec.NewReader(bytes.NewReader([]byte{0, 1, 0, 0}))
+	_, err = ReadStartup(r)
+	assert.ErrorIs(t, err, codec.ErrLengthOverflow)
+	r, _ = codec.NewReader(bytes.NewReader([]byte("\x00\x00\x00\x0b\x00\x03\x00\x00usr")))
+	_, err = ReadStartup(r)
+	assert.ErrorIs(t, err, ErrInvalidMessage)
+	w, _ = codec.NewWriter(&buf)
+	assert.ErrorIs(t, WriteStartup(w, map[string]string{"user": "a\x00b"}), ErrInvalidMessage)
+}
+
+func TestMessages(t *testing.T) {
+	var buf bytes.Buffer
+	w, _ := codec.NewWriter(&buf)
+	require.NoError(t, WriteMessage(w, Query, []byte("SELECT 1\x00")))
+	require.NoError(t, WriteMessage(w, ReadyForQuery, []byte{'I'}))
+	assert.Equal(t, "Q\x00\x00\x00\x0dSELECT 1\x00Z\x00\x00\x00\x05I", buf.String())
+
+	r, _ := codec.NewReader(&buf)
+	m, err := ReadMessage(r, 0)
+	require.NoError(t, err)
+	assert.Equal(t, Query, m.Type)
+	body, _ := codec.NewReader(bytes.NewReader(m.Body))
+	q, err := ReadString(body, 100)
+	require.NoError(t, err)
+	assert.Equal(t, "SELECT 1", q)
+	m, err = ReadMessage(r, 0)
+	require.NoError(t, err)
+	assert.Equal(t, Message{ReadyForQuery, []byte{'I'}}, m)
+	_, err = ReadMessage(r, 0)
+	assert.Equal(t, io.EOF, err)
+
+	r, _ = codec.NewReader(bytes.NewReader([]byte("D\x00\x01\x00\x00")))
+	_, err = ReadMessage(r, 1024)
+	assert.ErrorIs(t, err, codec.ErrLengthOverflow)
+	// A 512 MiB header is refused by default, and by the Reader's
+	// allocation limit when the server's cap is asked for.
+	r, _ = codec.NewReader(bytes.NewReader([]byte("D\x20\x00\x00\x00")))
+	_, err = ReadMessage(r, 0)
+	assert.ErrorIs(t, err, codec.ErrLengthOverflow)
+	r, _ = codec.NewReaderOpts(bytes.NewReader([]byte("D\x20\x00\x00\x00")), codec.WithMaxAlloc(1<<20))
+	_, err = ReadMessage(r, MAX_MESSAGE_SIZE)
+	assert.ErrorIs(t, err, codec.ErrLengthOverflow)
+	r, _ = codec.NewReader(bytes.NewReader([]byte("D\x00\x00\x00\x03")))
+	_, err = ReadMessage(r, 0)
+	assert.ErrorIs(t, err, ErrInvalidMessage)
+	r, _ = codec.NewReader(bytes.NewReader([]byte("D\x00\x00\x00\x08ab")))
+	_, err = ReadMessage(r, 0)
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+
+	body, _ = codec.NewReader(bytes.NewReader([]byte("abcdef\x00")))
+	_, err = ReadString(body, 3)
+	assert.ErrorIs(t, err, codec.ErrLengthOverflow)
+	body, _ = codec.NewReader(bytes.NewReader([]byte("abc")))
+	_, err = ReadString(body, 10)
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+
+	fields, err := Fields([]byte("SERROR\x00C42P01\x00Mrelation does not exist\x00\x00"))
+	require.NoError(t, err)
+	assert.Equal(t, map[byte]string{'S': "ERROR", 'C': "42P01", 'M': "relation does not exist"}, fields)
+	_, err = Fields([]byte("SERROR\x00"))
+	assert.ErrorIs(t, err, ErrInvalidMessage)
+}
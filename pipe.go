@@ -0,0 +1,21 @@
+package codec
+
+import "io"
+
+// EncodeReader returns a reader that streams the encoding of c, for APIs that
+// demand an io.Reader such as http.Request.Body or multipart writers. The
+// encoding is produced by c.WriteTo in a separate goroutine, so the message is
+// never buffered as a whole.
+//
+// An error from WriteTo is returned by Read once the bytes written before it
+// are consumed. Closing the reader early makes the pending WriteTo fail with
+// io.ErrClosedPipe and releases the goroutine; c must not be modified until
+// the reader reports io.EOF or is closed.
+func EncodeReader(c Codec) io.ReadCloser {
+	pr, pw := io.Pipe()
+	go func() {
+		_, err := c.WriteTo(pw)
+		pw.CloseWithError(err)
+	}()
+	return pr
+}
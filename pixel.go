@@ -0,0 +1,142 @@
+package codec
+
+import "fmt"
+
+// PixelFormat is the channel order of 8-bit-per-channel pixels.
+type PixelFormat uint8
+
+const (
+	PixelRGB  PixelFormat = iota + 1 // R, G, B
+	PixelBGR                         // B, G, R, as in 24-bit BMP and TGA
+	PixelRGBA                        // R, G, B, A
+	PixelBGRA                        // B, G, R, A, as in 32-bit BMP, TGA and DDS
+	PixelARGB                        // A, R, G, B
+	PixelABGR                        // A, B, G, R
+)
+
+// channels holds the byte offsets of red, green, blue and alpha within a
+// pixel, alpha being -1 if the format has none.
+type channels struct {
+	width      int
+	r, g, b, a int
+}
+
+var pixelChannels = [...]channels{
+	PixelRGB:  {3, 0, 1, 2, -1},
+	PixelBGR:  {3, 2, 1, 0, -1},
+	PixelRGBA: {4, 0, 1, 2, 3},
+	PixelBGRA: {4, 2, 1, 0, 3},
+	PixelARGB: {4, 1, 2, 3, 0},
+	PixelABGR: {4, 3, 2, 1, 0},
+}
+
+func (f PixelFormat) channels() (channels, bool) {
+	if f == 0 || int(f) >= len(pixelChannels) {
+		return channels{}, false
+	}
+	return pixelChannels[f], true
+}
+
+// BytesPerPixel returns the size of one pixel, or 0 for an unknown format.
+func (f PixelFormat) BytesPerPixel() int {
+	c, _ := f.channels()
+	return c.width
+}
+
+// SwizzleRow converts the pixels of src from one channel order to another into
+// dst and returns the number of pixels converted, limited by whichever slice
+// holds fewer. Alpha is set to 0xFF when the source has none and dropped when
+// the destination has none. dst and src must not overlap unless both formats
+// have the same size.
+func SwizzleRow(dst, src []byte, to, from PixelFormat) (int, error) {
+	fc, ok := from.channels()
+	if !ok {
+		return 0, fmt.Errorf("%w: pixel format %d", ErrInvalidImageLayout, from)
+	}
+	tc, ok := to.channels()
+	if !ok {
+		return 0, fmt.Errorf("%w: pixel format %d", ErrInvalidImageLayout, to)
+	}
+	n := min(len(src)/fc.width, len(dst)/tc.width)
+	switch {
+	case fc == tc:
+		copy(dst, src[:n*fc.width])
+	case fc.width == 3 && tc.width == 3:
+		// RGB <-> BGR, the most common case.
+		for i := 0; i < 3*n; i += 3 {
+			dst[i], dst[i+1], dst[i+2] = src[i+2], src[i+1], src[i]
+		}
+	default:
+		for i := range n {
+			s := src[i*fc.width : (i+1)*fc.width]
+			d := dst[i*tc.width : (i+1)*tc.width]
+			alpha := byte(0xFF)
+			if fc.a >= 0 {
+				alpha = s[fc.a]
+			}
+			d[tc.r], d[tc.g], d[tc.b] = s[fc.r], s[fc.g], s[fc.b]
+			if tc.a >= 0 {
+				d[tc.a] = alpha
+			}
+		}
+	}
+	return n, nil
+}
+
+// ImageLayout describes how the pixels of an image are laid out in memory.
+type ImageLayout struct {
+	Format   PixelFormat
+	Width    int  // pixels per row
+	Height   int  // number of rows
+	Align    int  // rows are padded to a multiple of Align bytes, 4 for BMP; 0 or 1 for none
+	BottomUp bool // the first row in memory is the bottom row of the image, as in BMP
+}
+
+// Stride returns the number of bytes per row including padding.
+func (l ImageLayout) Stride() int {
+	n := l.Width * l.Format.BytesPerPixel()
+	if l.Align > 1 {
+		n = (n + l.Align - 1) / l.Align * l.Align
+	}
+	return n
+}
+
+// Size returns the number of bytes of an image in this layout.
+func (l ImageLayout) Size() int { return l.Stride() * l.Height }
+
+// row returns the memory index of image row y, counted from the top.
+func (l ImageLayout) row(y int) int {
+	if l.BottomUp {
+		return l.Height - 1 - y
+	}
+	return y
+}
+
+// ConvertImage converts an image from one layout to another, swapping channel
+// order, re-padding rows and flipping the row order as needed. Both layouts
+// must have the same dimensions. Row padding in dst is zeroed.
+func ConvertImage(dst []byte, to ImageLayout, src []byte, from ImageLayout) error {
+	if to.Width != from.Width || to.Height != from.Height || to.Width < 0 || to.Height < 0 {
+		return fmt.Errorf("%w: %dx%d image converted to %dx%d", ErrInvalidImageLayout, from.Width, from.Height, to.Width, to.Height)
+	}
+	if from.Format.BytesPerPixel() == 0 || to.Format.BytesPerPixel() == 0 {
+		return fmt.Errorf("%w: pixel formats %d and %d", ErrInvalidImageLayout, from.Format, to.Format)
+	}
+	if len(src) < from.Size() {
+		return fmt.Errorf("%w: image of %d bytes, layout needs %d", ErrTruncatedData, len(src), from.Size())
+	}
+	if len(dst) < to.Size() {
+		return fmt.Errorf("%w: buffer of %d bytes, layout needs %d", ErrLengthOverflow, len(dst), to.Size())
+	}
+	fs, ts := from.Stride(), to.Stride()
+	used := to.Width * to.Format.BytesPerPixel()
+	for y := range to.Height {
+		d := dst[to.row(y)*ts:][:ts]
+		s := src[from.row(y)*fs:][:fs]
+		if _, err := SwizzleRow(d[:used], s, to.Format, from.Format); err != nil {
+			return err
+		}
+		clear(d[used:])
+	}
+	return nil
+}
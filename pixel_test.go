@@ -0,0 +1,60 @@
+//go:build test
+
+package codec
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSwizzleRow(t *testing.T) {
+	src := []byte{1, 2, 3, 4, 5, 6}
+	dst := make([]byte, 8)
+	n, err := SwizzleRow(dst, src, PixelBGRA, PixelRGB)
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, []byte{3, 2, 1, 0xFF, 6, 5, 4, 0xFF}, dst)
+
+	back := make([]byte, 6)
+	n, err = SwizzleRow(back, dst, PixelRGB, PixelBGRA)
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, src, back)
+
+	n, err = SwizzleRow(back, src, PixelBGR, PixelRGB)
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, []byte{3, 2, 1, 6, 5, 4}, back)
+
+	_, err = SwizzleRow(dst, src, PixelFormat(99), PixelRGB)
+	assert.ErrorIs(t, err, ErrInvalidImageLayout)
+}
+
+func TestConvertImage(t *testing.T) {
+	// A 3x2 24-bit BMP: bottom-up BGR rows padded to 4 bytes.
+	bmp := ImageLayout{Format: PixelBGR, Width: 3, Height: 2, Align: 4, BottomUp: true}
+	require.Equal(t, 12, bmp.Stride())
+	src := []byte{
+		30, 20, 10, 31, 21, 11, 32, 22, 12, 0, 0, 0, // bottom row
+		60, 50, 40, 61, 51, 41, 62, 52, 42, 0, 0, 0, // top row
+	}
+	rgba := ImageLayout{Format: PixelRGBA, Width: 3, Height: 2}
+	dst := make([]byte, rgba.Size())
+	require.NoError(t, ConvertImage(dst, rgba, src, bmp))
+	assert.Equal(t, []byte{
+		40, 50, 60, 0xFF, 41, 51, 61, 0xFF, 42, 52, 62, 0xFF,
+		10, 20, 30, 0xFF, 11, 21, 31, 0xFF, 12, 22, 32, 0xFF,
+	}, dst)
+
+	back := make([]byte, bmp.Size())
+	for i := range back {
+		back[i] = 0xEE
+	}
+	require.NoError(t, ConvertImage(back, bmp, dst, rgba))
+	assert.Equal(t, src, back)
+
+	assert.ErrorIs(t, ConvertImage(dst, rgba, src[:20], bmp), ErrTruncatedData)
+	assert.ErrorIs(t, ConvertImage(dst, ImageLayout{Format: PixelRGBA, Width: 2, Height: 3}, src, bmp), ErrInvalidImageLayout)
+}
@@ -0,0 +1,113 @@
+//go:build !tinygo && !codec_tiny
+
+package codec
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"reflect"
+	"unsafe"
+)
+
+// POD is a Fixed codec that encodes and decodes by copying the payload's memory
+// directly, skipping encoding/binary entirely. It is only constructed through
+// NewPOD, which verifies that the payload is plain old data: no pointers,
+// booleans, padding or codec tags, so its in-memory layout is its wire layout.
+//
+// The memory copy path applies when the host byte order matches the codec's
+// byte order (Order unless set with WithByteOrder) or the payload has no
+// multi-byte fields. Otherwise POD falls back to encoding/binary, so results
+// are always identical to Fixed's for the same byte order.
+type POD[Payload any] struct {
+	Fixed[Payload]
+}
+
+// Statically assert that POD implements Codec.
+var _ Codec = (*POD[struct{}])(nil)
+
+// NewPOD returns a POD codec wrapping p, or ErrNotFixedSize if the payload
+// layout does not qualify for memory copies.
+func NewPOD[Payload any](p Payload) (*POD[Payload], error) {
+	t := reflect.TypeOf((*Payload)(nil)).Elem()
+	if !podOf(t).identical || !layoutOf(t).plain() {
+		return nil, fmt.Errorf("%w: %s is not plain old data", ErrNotFixedSize, t)
+	}
+	return &POD[Payload]{Fixed: Fixed[Payload]{Payload: p}}, nil
+}
+
+// WithByteOrder sets the byte order of the wire format and returns the
+// configured POD for chaining. Use binary.NativeEndian to always take the
+// memory copy path.
+func (c *POD[Payload]) WithByteOrder(order binary.ByteOrder) *POD[Payload] {
+	c.order = order
+	return c
+}
+
+// fast reports whether the memory copy path applies.
+func (c *POD[Payload]) fast() bool {
+	return canAlias(reflect.TypeOf((*Payload)(nil)).Elem(), c.byteOrder())
+}
+
+// bytes returns the payload's memory as a byte slice.
+func (c *POD[Payload]) bytes() []byte {
+	return unsafe.Slice((*byte)(unsafe.Pointer(&c.Payload)), unsafe.Sizeof(c.Payload))
+}
+
+func (c *POD[Payload]) MarshalBinary() ([]byte, error) {
+	buf := make([]byte, c.Size())
+	if _, err := c.MarshalTo(buf); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+func (c *POD[Payload]) UnmarshalBinary(data []byte) error {
+	b := c.bytes()
+	if len(data) < len(b) {
+		return ErrTruncatedData
+	}
+	if c.fast() {
+		copy(b, data)
+	} else if _, err := binary.Decode(data, c.byteOrder(), &c.Payload); err != nil {
+		return ErrTruncatedData
+	}
+	return CheckBufferNotZeros(data[len(b):])
+}
+
+func (c *POD[Payload]) ReadFrom(r io.Reader) (int64, error) {
+	if !c.fast() {
+		if err := binary.Read(r, c.byteOrder(), &c.Payload); err != nil {
+			return 0, err
+		}
+		return int64(c.Size()), nil
+	}
+	n, err := io.ReadFull(r, c.bytes())
+	return int64(n), err
+}
+
+func (c *POD[Payload]) WriteTo(w io.Writer) (int64, error) {
+	if !c.fast() {
+		if err := binary.Write(w, c.byteOrder(), &c.Payload); err != nil {
+			return 0, err
+		}
+		return int64(c.Size()), nil
+	}
+	n, err := w.Write(c.bytes())
+	return int64(n), err
+}
+
+func (c *POD[Payload]) MarshalTo(p []byte) (int, error) {
+	b := c.bytes()
+	if len(p) < len(b) {
+		return 0, io.ErrShortWrite
+	}
+	if !c.fast() {
+		return binary.Encode(p, c.byteOrder(), &c.Payload)
+	}
+	return copy(p, b), nil
+}
+
+func (c *POD[Payload]) MarshalAppend(dst []byte) ([]byte, error) {
+	return MarshalAppendGeneric(c, dst)
+}
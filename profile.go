@@ -0,0 +1,169 @@
+package codec
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"text/tabwriter"
+	"time"
+)
+
+// ProfileOp distinguishes encode and decode samples.
+type ProfileOp uint8
+
+const (
+	ProfileEncode ProfileOp = iota
+	ProfileDecode
+)
+
+func (op ProfileOp) String() string {
+	if op == ProfileDecode {
+		return "decode"
+	}
+	return "encode"
+}
+
+// ProfileStat accumulates the samples of one field or codec.
+type ProfileStat struct {
+	Name  string
+	Op    ProfileOp
+	Calls int64
+	Bytes int64
+	Time  time.Duration
+}
+
+type profileKey struct {
+	name string
+	op   ProfileOp
+}
+
+// Profiler records time and bytes per field or codec across many operations.
+// Once installed with SetProfiler, Dynamic records every field it encodes or
+// decodes under "Type.Field", so the fields dominating a message's latency
+// rank first in Report. Other codecs can be measured with Wrap. Nested fields
+// are also counted in the totals of their enclosing field.
+type Profiler struct {
+	mu    sync.Mutex
+	stats map[profileKey]*ProfileStat
+}
+
+// NewProfiler returns an empty Profiler.
+func NewProfiler() *Profiler {
+	return &Profiler{stats: make(map[profileKey]*ProfileStat)}
+}
+
+// profiler is the Profiler installed by SetProfiler, or nil.
+var profiler atomic.Pointer[Profiler]
+
+// SetProfiler installs p as the process-wide profiler and returns the
+// previous one. Passing nil disables profiling, which then costs a single
+// atomic load per encode or decode.
+func SetProfiler(p *Profiler) *Profiler {
+	return profiler.Swap(p)
+}
+
+// Record adds one sample.
+func (p *Profiler) Record(name string, op ProfileOp, bytes int64, d time.Duration) {
+	key := profileKey{name, op}
+	p.mu.Lock()
+	s, ok := p.stats[key]
+	if !ok {
+		s = &ProfileStat{Name: name, Op: op}
+		p.stats[key] = s
+	}
+	s.Calls++
+	s.Bytes += bytes
+	s.Time += d
+	p.mu.Unlock()
+}
+
+// Stats returns a snapshot of all entries, ranked by total time.
+func (p *Profiler) Stats() []ProfileStat {
+	p.mu.Lock()
+	stats := make([]ProfileStat, 0, len(p.stats))
+	for _, s := range p.stats {
+		stats = append(stats, *s)
+	}
+	p.mu.Unlock()
+	slices.SortFunc(stats, func(a, b ProfileStat) int {
+		if c := cmp.Compare(b.Time, a.Time); c != 0 {
+			return c
+		}
+		if c := cmp.Compare(a.Name, b.Name); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Op, b.Op)
+	})
+	return stats
+}
+
+// Reset discards all samples.
+func (p *Profiler) Reset() {
+	p.mu.Lock()
+	clear(p.stats)
+	p.mu.Unlock()
+}
+
+// Report writes the ranked entries as a table.
+func (p *Profiler) Report(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "time\tcalls\tavg\tbytes\top\tname\t")
+	for _, s := range p.Stats() {
+		avg := time.Duration(0)
+		if s.Calls > 0 {
+			avg = s.Time / time.Duration(s.Calls)
+		}
+		fmt.Fprintf(tw, "%v\t%d\t%v\t%d\t%s\t%s\t\n", s.Time, s.Calls, avg, s.Bytes, s.Op, s.Name)
+	}
+	return tw.Flush()
+}
+
+// Wrap returns c instrumented to record its encodes and decodes under name.
+func (p *Profiler) Wrap(name string, c Codec) Codec {
+	return &profiled{Codec: c, p: p, name: name}
+}
+
+// profiled is a Codec recording its operations in a Profiler.
+type profiled struct {
+	Codec
+	p    *Profiler
+	name string
+}
+
+func (c *profiled) WriteTo(w io.Writer) (int64, error) {
+	start := time.Now()
+	n, err := c.Codec.WriteTo(w)
+	c.p.Record(c.name, ProfileEncode, n, time.Since(start))
+	return n, err
+}
+
+func (c *profiled) MarshalTo(buf []byte) (int, error) {
+	start := time.Now()
+	n, err := c.Codec.MarshalTo(buf)
+	c.p.Record(c.name, ProfileEncode, int64(n), time.Since(start))
+	return n, err
+}
+
+func (c *profiled) MarshalBinary() ([]byte, error) {
+	start := time.Now()
+	b, err := c.Codec.MarshalBinary()
+	c.p.Record(c.name, ProfileEncode, int64(len(b)), time.Since(start))
+	return b, err
+}
+
+func (c *profiled) ReadFrom(r io.Reader) (int64, error) {
+	start := time.Now()
+	n, err := c.Codec.ReadFrom(r)
+	c.p.Record(c.name, ProfileDecode, n, time.Since(start))
+	return n, err
+}
+
+func (c *profiled) UnmarshalBinary(data []byte) error {
+	start := time.Now()
+	err := c.Codec.UnmarshalBinary(data)
+	c.p.Record(c.name, ProfileDecode, int64(len(data)), time.Since(start))
+	return err
+}
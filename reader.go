@@ -4,7 +4,10 @@ import (
 	"bufio"
 	"bytes"
 	"encoding/binary"
+	"hash"
 	"io"
+
+	"golang.org/x/text/encoding"
 )
 
 // Zero is an io.Reader that reads an infinite stream of zero bytes.
@@ -37,6 +40,31 @@ type Reader struct {
 	count int64 // total bytes read
 	err   error // first error encountered.
 	order binary.ByteOrder
+
+	sync   []byte         // marker scanned for by Resync.
+	onSkip ResyncCallback // notified of byte ranges skipped by Resync.
+
+	interner *Interner // deduplicates decoded strings and byte slices.
+
+	labels map[string]int64 // positions recorded by MarkLabel.
+
+	held int // size of the buffer allocated by this Reader.
+
+	charset encoding.Encoding // text encoding of ReadString, nil for UTF-8.
+
+	hash hash.Hash // fed every consumed byte, set by WithHash.
+
+	trace TraceFunc // reports primitive reads, set by WithTrace.
+
+	context []string // path pushed by PushContext.
+	failed  failure  // where err was latched.
+
+	maxPadding int64 // trailing bytes allowed by CheckTrailingNotZeros, 0 for MAX_PADDING.
+	maxAlloc   int   // largest single allocation of ReadBytes and ReadString, 0 for no limit.
+
+	tx *txReader // keeps bytes for Rollback, installed by Begin.
+
+	at io.ReaderAt // source of ReadAt, nil if it has no random access.
 }
 
 var _ ReaderPro = (*Reader)(nil)
@@ -46,12 +74,13 @@ func NewReaderSize(r io.Reader, size int) (*Reader, error) {
 	if r == nil {
 		return nil, ErrNilIO
 	}
+	at, _ := r.(io.ReaderAt)
 
 	switch reader := r.(type) {
 	// Reuse the underlying buffer if it's already a compatible Reader.
 	case *Reader:
 		if reader.r.Size() >= size {
-			return &Reader{r: reader.r, order: Order}, nil
+			return &Reader{r: reader.r, order: reader.order, at: reader.at}, nil
 		}
 
 	// prevent unpredictable double-buffering.
@@ -63,9 +92,9 @@ func NewReaderSize(r io.Reader, size int) (*Reader, error) {
 
 	// underlying is a buf so we don't need buffering
 	case *BytesReader:
-		return &Reader{r: reader, order: Order}, nil
+		return &Reader{r: reader, order: Order, at: at}, nil
 	case *bytes.Reader:
-		return &Reader{r: &bytesReaderAdapter{reader}, order: Order}, nil
+		return &Reader{r: &bytesReaderAdapter{reader}, order: Order, at: at}, nil
 	case *bytes.Buffer:
 		return &Reader{r: &bytesBufferReaderAdapter{Buffer: reader}, order: Order}, nil
 	}
@@ -74,10 +103,15 @@ func NewReaderSize(r io.Reader, size int) (*Reader, error) {
 		return nil, ErrSizeTooSmall
 	}
 
-	// default use bufio
+	// default use bufio. Only a real seeker is kept: bufio reads ahead, so
+	// the offset of a forward-only wrapper would not match ours.
+	br := bufio.NewReaderSize(r, size)
+	seeker, _ := r.(io.ReadSeeker)
 	return &Reader{
-		r:     &bufioReaderAdapter{Reader: bufio.NewReaderSize(r, size), seeker: ForwardSeeker(r)},
+		r:     &bufioReaderAdapter{Reader: br, seeker: seeker},
 		order: Order,
+		held:  br.Size(),
+		at:    at,
 	}, nil
 }
 
@@ -93,6 +127,14 @@ func (r *Reader) WithByteOrder(order binary.ByteOrder) *Reader {
 	return r
 }
 
+// WithMaxPadding sets the number of trailing zero bytes CheckTrailingNotZeros
+// accepts after a payload read from r, in place of MAX_PADDING, and returns
+// the Reader for chaining.
+func (r *Reader) WithMaxPadding(n int64) *Reader {
+	r.maxPadding = n
+	return r
+}
+
 // Close closes the underlying reader if it implements io.Closer.
 func (r *Reader) Close() error {
 	return r.r.Close()
@@ -138,19 +180,20 @@ func (r *Reader) WriteTo(w io.Writer) (int64, error) {
 
 func (r *Reader) Size() int    { return r.r.Size() }
 func (r *Reader) Count() int64 { return r.count }
-func (r *Reader) Err() error   { return r.err }
+func (r *Reader) Err() error   { return r.failed.wrap(r.err) }
 func (r *Reader) IsEOF() bool  { return r.err == io.EOF }
 
 // setError records the first non-nil error.
 func (r *Reader) setError(err error) {
 	if r.err == nil && err != nil {
 		r.err = err
+		r.failed = locate(r.count, r.context)
 	}
 }
 
 // Result returns the total bytes read and the final error state.
 func (r *Reader) Result() (int64, error) {
-	return r.count, r.err
+	return r.count, r.failed.wrap(r.err)
 }
 
 // ReadTo reads data from this reader into an io.ReaderFrom.
@@ -169,7 +212,7 @@ func (r *Reader) ReadTo(w io.ReaderFrom) {
 
 // readFull is an internal helper to read an exact number of bytes.
 func (r *Reader) readFull(n int) []byte {
-	if r.err != nil {
+	if r.err != nil || !r.alloc(n) {
 		return nil
 	}
 	buf := make([]byte, n)
@@ -191,7 +234,13 @@ func (r *Reader) ReadBytes(n int) []byte {
 	if n <= 0 {
 		return nil
 	}
-	return r.readFull(n)
+	b := r.readFull(n)
+	if r.err == nil {
+		if r.trace != nil {
+			r.traced("bytes", n, b)
+		}
+	}
+	return b
 }
 
 func (r *Reader) ReadBytesTo(dest []byte) {
@@ -203,6 +252,10 @@ func (r *Reader) ReadBytesTo(dest []byte) {
 	}
 	if _, err := io.ReadFull(r, dest); err != nil {
 		r.err = err
+		return
+	}
+	if r.trace != nil {
+		r.traced("bytes", len(dest), dest)
 	}
 }
 
@@ -223,8 +276,11 @@ func (r *Reader) ReadBool(dest *bool) {
 	if err == nil {
 		r.count++
 		*dest = b != 0
+		if r.trace != nil {
+			r.traced("bool", 1, *dest)
+		}
 	} else {
-		r.err = err
+		r.setError(err)
 	}
 }
 
@@ -236,7 +292,7 @@ func (r *Reader) ReadByte() (byte, error) {
 	if err == nil {
 		r.count++
 	} else {
-		r.err = err
+		r.setError(err)
 	}
 	return b, err
 }
@@ -249,8 +305,11 @@ func (r *Reader) ReadUint8(dest *uint8) {
 	if err == nil {
 		r.count++
 		*dest = b
+		if r.trace != nil {
+			r.traced("uint8", 1, *dest)
+		}
 	} else {
-		r.err = err
+		r.setError(err)
 	}
 }
 
@@ -258,6 +317,24 @@ func (r *Reader) ReadUint16(dest *uint16) {
 	buf := r.readFull(2)
 	if r.err == nil {
 		*dest = r.order.Uint16(buf)
+		if r.trace != nil {
+			r.traced("uint16", 2, *dest)
+		}
+	}
+}
+
+// ReadUint24 reads a 3-byte unsigned integer, as used by RTMP and MySQL headers.
+func (r *Reader) ReadUint24(dest *uint32) {
+	buf := r.readFull(3)
+	if r.err == nil {
+		if r.order == LE {
+			*dest = uint32(buf[0]) | uint32(buf[1])<<8 | uint32(buf[2])<<16
+		} else {
+			*dest = uint32(buf[0])<<16 | uint32(buf[1])<<8 | uint32(buf[2])
+		}
+		if r.trace != nil {
+			r.traced("uint24", 3, *dest)
+		}
 	}
 }
 
@@ -265,6 +342,9 @@ func (r *Reader) ReadUint32(dest *uint32) {
 	buf := r.readFull(4)
 	if r.err == nil {
 		*dest = r.order.Uint32(buf)
+		if r.trace != nil {
+			r.traced("uint32", 4, *dest)
+		}
 	}
 }
 
@@ -272,6 +352,9 @@ func (r *Reader) ReadUint64(dest *uint64) {
 	buf := r.readFull(8)
 	if r.err == nil {
 		*dest = r.order.Uint64(buf)
+		if r.trace != nil {
+			r.traced("uint64", 8, *dest)
+		}
 	}
 }
 
@@ -283,8 +366,11 @@ func (r *Reader) ReadInt8(dest *int8) {
 	if err == nil {
 		r.count++
 		*dest = int8(b)
+		if r.trace != nil {
+			r.traced("int8", 1, *dest)
+		}
 	} else {
-		r.err = err
+		r.setError(err)
 	}
 }
 
@@ -292,6 +378,9 @@ func (r *Reader) ReadInt16(dest *int16) {
 	buf := r.readFull(2)
 	if r.err == nil {
 		*dest = int16(r.order.Uint16(buf))
+		if r.trace != nil {
+			r.traced("int16", 2, *dest)
+		}
 	}
 }
 
@@ -299,6 +388,9 @@ func (r *Reader) ReadInt32(dest *int32) {
 	buf := r.readFull(4)
 	if r.err == nil {
 		*dest = int32(r.order.Uint32(buf))
+		if r.trace != nil {
+			r.traced("int32", 4, *dest)
+		}
 	}
 }
 
@@ -306,5 +398,8 @@ func (r *Reader) ReadInt64(dest *int64) {
 	buf := r.readFull(8)
 	if r.err == nil {
 		*dest = int64(r.order.Uint64(buf))
+		if r.trace != nil {
+			r.traced("int64", 8, *dest)
+		}
 	}
 }
@@ -0,0 +1,96 @@
+package codec
+
+import (
+	"errors"
+	"io"
+)
+
+// NewReaderAt creates a Reader over the first size bytes of r, for formats
+// driven by offset tables, such as the central directory of a ZIP archive or
+// the section headers of an ELF file. It reads sequentially from offset 0
+// like any Reader, and from any offset with ReadAt and the *At methods.
+func NewReaderAt(r io.ReaderAt, size int64) (*Reader, error) {
+	if r == nil {
+		return nil, ErrNilIO
+	}
+	return NewReaderSize(io.NewSectionReader(r, 0, size), BUFFER_SIZE)
+}
+
+// CanReadAt reports whether the source of r supports ReadAt: a BytesReader,
+// bytes.Reader or any other io.ReaderAt, such as an *os.File.
+func (r *Reader) CanReadAt() bool { return r.at != nil }
+
+// ReadAt implements io.ReaderAt, reading from offset off of the source
+// without moving the sequential position, Count or the hash. Like Peek, it
+// does not latch its errors and returns the latched error, if any. Sources
+// without random access fail with errors.ErrUnsupported.
+func (r *Reader) ReadAt(p []byte, off int64) (int, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+	if r.at == nil {
+		return 0, errors.ErrUnsupported
+	}
+	return r.at.ReadAt(p, off)
+}
+
+// readAt fills buf from offset off, latching the error on failure. Reads
+// past the end of the source fail with io.ErrUnexpectedEOF.
+func (r *Reader) readAt(buf []byte, off int64) bool {
+	if r.err != nil {
+		return false
+	}
+	if r.at == nil {
+		r.setError(errors.ErrUnsupported)
+		return false
+	}
+	n, err := r.at.ReadAt(buf, off)
+	if n == len(buf) {
+		return true
+	}
+	if err == nil || err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	r.setError(err)
+	return false
+}
+
+// ReadBytesAt reads n bytes at offset off into a new byte slice.
+func (r *Reader) ReadBytesAt(n int, off int64) []byte {
+	if n <= 0 || r.err != nil || !r.alloc(n) {
+		return nil
+	}
+	buf := make([]byte, n)
+	if !r.readAt(buf, off) {
+		return nil
+	}
+	return buf
+}
+
+func (r *Reader) ReadUint8At(dest *uint8, off int64) {
+	var buf [1]byte
+	if r.readAt(buf[:], off) {
+		*dest = buf[0]
+	}
+}
+
+func (r *Reader) ReadUint16At(dest *uint16, off int64) {
+	var buf [2]byte
+	if r.readAt(buf[:], off) {
+		*dest = r.order.Uint16(buf[:])
+	}
+}
+
+func (r *Reader) ReadUint32At(dest *uint32, off int64) {
+	var buf [4]byte
+	if r.readAt(buf[:], off) {
+		*dest = r.order.Uint32(buf[:])
+	}
+}
+
+func (r *Reader) ReadUint64At(dest *uint64, off int64) {
+	var buf [8]byte
+	if r.readAt(buf[:], off) {
+		*dest = r.order.Uint64(buf[:])
+	}
+}
@@ -6,6 +6,8 @@ import "io"
 type BytesReader struct {
 	B []byte // destination slice
 	N int    // current read position
+
+	mapped []byte // memory mapping made by MapFile, unmapped by Close.
 }
 
 // NewBytesReader creates a new BytesReader.
@@ -13,9 +15,16 @@ func NewBytesReader(b []byte) *BytesReader {
 	return &BytesReader{B: b}
 }
 
-// Close closes the underlying reader if it implements io.Closer.
+// Close unmaps the file of a BytesReader returned by MapFile; slices
+// obtained from it before must not be used afterwards. For other
+// BytesReaders it does nothing.
 func (r *BytesReader) Close() error {
-	return nil
+	if r.mapped == nil {
+		return nil
+	}
+	b := r.mapped
+	r.B, r.N, r.mapped = nil, 0, nil
+	return munmap(b)
 }
 
 // Read implements the [io.Reader] interface.
@@ -79,6 +88,22 @@ func (r *BytesReader) Seek(offset int64, whence int) (int64, error) {
 	return abs, nil
 }
 
+// ReadAt implements the [io.ReaderAt] interface. It does not move the read
+// position.
+func (r *BytesReader) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, ErrInvalidSeek
+	}
+	if off >= int64(len(r.B)) {
+		return 0, io.EOF
+	}
+	n := copy(p, r.B[off:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
 // Next returns the next n bytes from the reader.
 func (r *BytesReader) Next(n int) []byte {
 	if r.N >= len(r.B) {
@@ -89,6 +114,25 @@ func (r *BytesReader) Next(n int) []byte {
 	return b
 }
 
+// Peek returns the next n bytes without advancing the reader, or the bytes
+// left and io.EOF if there are fewer. The slice aliases the underlying buffer.
+func (r *BytesReader) Peek(n int) ([]byte, error) {
+	b := r.B[min(r.N, len(r.B)):]
+	if n > len(b) {
+		return b, io.EOF
+	}
+	return b[:n], nil
+}
+
+// UnreadByte implements the [io.ByteScanner] interface.
+func (r *BytesReader) UnreadByte() error {
+	if r.N <= 0 {
+		return ErrInvalidUnread
+	}
+	r.N--
+	return nil
+}
+
 // Reset allows the underlying byte slice to be reused.
 func (w *BytesReader) Reset() {
 	w.N = 0
@@ -1,7 +1,12 @@
 package codec
 
 import (
+	"crypto/hmac"
+	"crypto/subtle"
+	"encoding/binary"
 	"fmt"
+	"hash"
+	"hash/crc64"
 	"io"
 )
 
@@ -119,6 +124,50 @@ func (r *ChainedReader) WriteTo(w io.Writer) (n int64, err error) {
 	return n, nil
 }
 
+// ChecksumTrailer prepares a ChainReader for a payload followed by a checksum
+// trailer of h.Size() bytes. It returns a reader to pass to ChainReader in
+// place of r, which feeds the main stream to h as it is read, and a callback
+// that reads the trailer from r and fails with ErrChecksumMismatch unless it
+// equals the checksum in the given byte order. The comparison takes constant
+// time, so h may be a MAC.
+//
+//	src, verify := codec.ChecksumTrailer(r, crc32.NewIEEE(), codec.BE)
+//	cr := codec.ChainReader(src, payloadSize, verify)
+func ChecksumTrailer(r io.Reader, h Checksum, order binary.ByteOrder) (io.Reader, ChainedReaderCallback) {
+	verify := func(io.Reader) error {
+		trailer := make([]byte, h.Size())
+		if _, err := io.ReadFull(r, trailer); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return fmt.Errorf("%w: checksum trailer: %w", ErrTruncatedData, err)
+		}
+		sum := appendTrailer(nil, h, order)
+		if subtle.ConstantTimeCompare(trailer, sum) != 1 {
+			return fmt.Errorf("%w: trailer %x, computed %x", ErrChecksumMismatch, trailer, sum)
+		}
+		return nil
+	}
+	return io.TeeReader(r, h), verify
+}
+
+// HMACTrailer is ChecksumTrailer with an HMAC of h under key, verifying the
+// tag written by Writer.WriteMAC.
+func HMACTrailer(r io.Reader, h func() hash.Hash, key []byte) (io.Reader, ChainedReaderCallback) {
+	return ChecksumTrailer(r, hmac.New(h, key), BE)
+}
+
+// CRC32Trailer is ChecksumTrailer with an IEEE CRC-32.
+func CRC32Trailer(r io.Reader, order binary.ByteOrder) (io.Reader, ChainedReaderCallback) {
+	return ChecksumTrailer(r, NewCRC32(), order)
+}
+
+// CRC64Trailer is ChecksumTrailer with a CRC-64 over table, such as
+// crc64.MakeTable(crc64.ECMA).
+func CRC64Trailer(r io.Reader, table *crc64.Table, order binary.ByteOrder) (io.Reader, ChainedReaderCallback) {
+	return ChecksumTrailer(r, NewCRC64(table), order)
+}
+
 // ChainedReadSeeker embeds a ChainedReader to add seeking capability.
 // It is returned by ChainReader when the underlying reader implements io.Seeker.
 type ChainedReadSeeker struct {
@@ -0,0 +1,43 @@
+package codec
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+// exactReader reads from a stream without read-ahead, so decoding never
+// consumes bytes beyond the end of the payload.
+type exactReader struct {
+	r   io.Reader
+	n   int64
+	one [1]byte
+	in  *Interner
+
+	order binary.ByteOrder // decodes multi-byte values, see streamOrder.
+}
+
+func (e *exactReader) Read(p []byte) (int, error) {
+	n, err := e.r.Read(p)
+	e.n += int64(n)
+	return n, err
+}
+
+func (e *exactReader) ReadByte() (byte, error) {
+	if br, ok := e.r.(io.ByteReader); ok {
+		b, err := br.ReadByte()
+		if err == nil {
+			e.n++
+		}
+		return b, err
+	}
+	_, err := io.ReadFull(e, e.one[:])
+	return e.one[0], err
+}
+
+func (e *exactReader) readFull(p []byte) error {
+	_, err := io.ReadFull(e, p)
+	if err == io.EOF && len(p) > 0 {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -1,6 +1,8 @@
 package codec
 
 import (
+	"bufio"
+	"errors"
 	"io"
 )
 
@@ -133,3 +135,81 @@ func (r *PeekableReader) WriteTo(w io.Writer) (n int64, err error) {
 
 	return n, err
 }
+
+// peeker is implemented by the sources of a Reader.
+type peeker interface {
+	Peek(n int) ([]byte, error)
+}
+
+// Peek returns the next n bytes without consuming them, for sniffing a
+// format or looking at a discriminator before choosing how to decode it.
+// Fewer than n bytes are returned with io.EOF at the end of the stream, and
+// with bufio.ErrBufferFull if n exceeds the buffer of a buffered Reader, as
+// set by NewReaderSize. The slice is only valid until the next read.
+//
+// Peek does not advance Count and does not latch its errors, so a failed
+// peek leaves the Reader usable; it returns the latched error, if any.
+func (r *Reader) Peek(n int) ([]byte, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	if n < 0 {
+		return nil, bufio.ErrNegativeCount
+	}
+	p, ok := r.r.(peeker)
+	if !ok {
+		return nil, errors.ErrUnsupported
+	}
+	return p.Peek(n)
+}
+
+// UnreadByte implements io.ByteScanner, unreading the last byte read so a
+// parser that overshot can back up. As with bufio.Reader, a buffered Reader
+// unreads a single byte, and not after a Peek; see UnreadBytes for more.
+//
+// Like Peek, UnreadByte does not latch its errors and returns the latched
+// error, if any.
+func (r *Reader) UnreadByte() error {
+	if r.err != nil {
+		return r.err
+	}
+	if r.count <= 0 {
+		return ErrInvalidUnread
+	}
+	u, ok := r.r.(io.ByteScanner)
+	if !ok {
+		return errors.ErrUnsupported
+	}
+	if err := u.UnreadByte(); err != nil {
+		return err
+	}
+	r.count--
+	return nil
+}
+
+// UnreadBytes backs up n bytes, so they are read again. Sources in memory,
+// such as a BytesReader or bytes.Reader, and buffered Readers over an
+// io.Seeker back up any distance. Other sources back up a single byte with
+// UnreadByte and fail with ErrUnsupportedNegativeSeek beyond it.
+//
+// Like Peek, UnreadBytes does not latch its errors and returns the latched
+// error, if any.
+func (r *Reader) UnreadBytes(n int) error {
+	if r.err != nil {
+		return r.err
+	}
+	if n < 0 || int64(n) > r.count {
+		return ErrInvalidUnread
+	}
+	if n == 0 {
+		return nil
+	}
+	if n == 1 && r.UnreadByte() == nil {
+		return nil
+	}
+	if _, err := r.r.Seek(-int64(n), io.SeekCurrent); err != nil {
+		return err
+	}
+	r.count -= int64(n)
+	return nil
+}
@@ -0,0 +1,66 @@
+package codec
+
+import "bytes"
+
+// ResyncCallback is invoked by Reader.Resync with the absolute offset and the
+// length of the byte range that was skipped while searching for the sync marker.
+type ResyncCallback func(offset, n int64)
+
+// WithResync configures a sync marker (e.g., a frame magic) that Resync scans
+// for after corruption is detected. The optional callback is notified of every
+// skipped byte range, which is useful for salvage reports.
+func (r *Reader) WithResync(marker []byte, onSkip ResyncCallback) *Reader {
+	r.sync = bytes.Clone(marker)
+	r.onSkip = onSkip
+	return r
+}
+
+// Resync recovers from a corrupted frame. It discards the latched error and
+// scans forward until the configured sync marker has been consumed, so decoding
+// can continue with the bytes following the marker.
+//
+// It returns the number of bytes skipped before the marker. If the stream ends
+// before a marker is found, the skipped range is still reported and the
+// end-of-stream error is latched and returned.
+func (r *Reader) Resync() (int64, error) {
+	if len(r.sync) == 0 {
+		return 0, ErrNoSyncMarker
+	}
+	r.err, r.failed = nil, failure{}
+	return r.skipPast(r.sync, r.onSkip)
+}
+
+// skipPast consumes bytes until marker has been read, reporting the skipped
+// range to onSkip if it is not nil.
+func (r *Reader) skipPast(marker []byte, onSkip ResyncCallback) (int64, error) {
+	start := r.count
+	window := make([]byte, 0, len(marker))
+	for {
+		b, err := r.r.ReadByte()
+		if err != nil {
+			r.setError(err)
+			skipped := r.count - start
+			if skipped > 0 && onSkip != nil {
+				onSkip(start, skipped)
+			}
+			return skipped, err
+		}
+		r.count++
+
+		// Slide the window over the stream; markers are short so a plain
+		// comparison is cheaper than maintaining a failure table.
+		if len(window) == len(marker) {
+			copy(window, window[1:])
+			window = window[:len(window)-1]
+		}
+		window = append(window, b)
+
+		if bytes.Equal(window, marker) {
+			skipped := r.count - start - int64(len(marker))
+			if skipped > 0 && onSkip != nil {
+				onSkip(start, skipped)
+			}
+			return skipped, nil
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package codec
+
+import (
+	"fmt"
+	"io"
+)
+
+// Section returns a Reader over the next n bytes of r, for decoding a
+// length-delimited structure nested in the stream. The child starts at Count
+// 0, shares the byte order and limits of r and ends with io.EOF after n
+// bytes; reads that the stream cannot satisfy fail with io.ErrUnexpectedEOF.
+//
+// Closing the child skips whatever it left unread, so r resumes right after
+// the section even when the child failed or the structure has fields the
+// caller does not know. Every byte read or skipped advances r, and is hashed
+// if r is hashing. Reading from r before the child is closed leaves both out
+// of step.
+func (r *Reader) Section(n int64) *Reader {
+	return r.section(n, false)
+}
+
+// StrictSection is like Section, but closing the child with unread bytes is
+// a protocol error: Close skips them, then latches ErrSectionRemainder on r
+// and returns it.
+func (r *Reader) StrictSection(n int64) *Reader {
+	return r.section(n, true)
+}
+
+func (r *Reader) section(n int64, strict bool) *Reader {
+	s := &sectionReader{parent: r, size: max(n, 0), strict: strict}
+	s.n = s.size
+	child := &Reader{
+		r:          s,
+		order:      r.order,
+		interner:   r.interner,
+		charset:    r.charset,
+		maxPadding: r.maxPadding,
+		maxAlloc:   r.maxAlloc,
+	}
+	if n < 0 {
+		child.setError(fmt.Errorf("%w: section of %d bytes", ErrLengthOverflow, n))
+	}
+	return child
+}
+
+// sectionReader reads at most size bytes of a parent Reader.
+type sectionReader struct {
+	parent *Reader
+	size   int64
+	n      int64 // bytes left
+	strict bool
+	closed bool
+	one    [1]byte
+}
+
+func (s *sectionReader) Read(p []byte) (int, error) {
+	if s.n <= 0 {
+		return 0, io.EOF
+	}
+	if int64(len(p)) > s.n {
+		p = p[:s.n]
+	}
+	n, err := s.parent.Read(p)
+	s.n -= int64(n)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+func (s *sectionReader) ReadByte() (byte, error) {
+	_, err := io.ReadFull(s, s.one[:])
+	return s.one[0], err
+}
+
+func (s *sectionReader) Peek(n int) ([]byte, error) {
+	if int64(n) <= s.n {
+		return s.parent.Peek(n)
+	}
+	p, err := s.parent.Peek(int(s.n))
+	if err == nil {
+		err = io.EOF
+	}
+	return p, err
+}
+
+func (s *sectionReader) WriteTo(w io.Writer) (int64, error) {
+	lr := &io.LimitedReader{R: s.parent, N: s.n}
+	n, err := io.Copy(w, lr)
+	s.n = lr.N
+	if err == nil && s.n > 0 {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+// Seek only moves forward, skipping bytes of the parent. Offsets are
+// relative to the start of the section.
+func (s *sectionReader) Seek(offset int64, whence int) (int64, error) {
+	pos := s.size - s.n
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += pos
+	case io.SeekEnd:
+		offset += s.size
+	default:
+		return pos, ErrInvalidWhence
+	}
+	if offset < pos {
+		return pos, ErrUnsupportedNegativeSeek
+	}
+	if offset > s.size {
+		return pos, ErrInvalidSeek
+	}
+	err := s.skip(offset - pos)
+	return s.size - s.n, err
+}
+
+func (s *sectionReader) skip(n int64) error {
+	m, err := io.CopyN(io.Discard, s.parent, n)
+	s.n -= m
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+func (s *sectionReader) Size() int { return s.parent.Size() }
+
+// Close skips the rest of the section. The parent is not closed.
+func (s *sectionReader) Close() error {
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	left := s.n
+	if err := s.skip(left); err != nil {
+		return err
+	}
+	if s.strict && left > 0 {
+		err := fmt.Errorf("%w: %d of %d bytes", ErrSectionRemainder, left, s.size)
+		s.parent.setError(err)
+		return err
+	}
+	return nil
+}
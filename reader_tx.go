@@ -0,0 +1,197 @@
+package codec
+
+import (
+	"errors"
+	"io"
+)
+
+// txState is the Reader state saved by Begin.
+type txState struct {
+	pos     int // position in the txReader buffer
+	count   int64
+	err     error
+	failed  failure
+	context int
+}
+
+// txReader keeps the bytes consumed from its ReaderPro during transactions,
+// and replays them after a Rollback.
+type txReader struct {
+	ReaderPro
+	buf   []byte    // bytes consumed since the outermost Begin, and any to replay
+	pos   int       // bytes of buf consumed
+	marks []txState // open transactions, innermost last
+}
+
+// recording reports whether a transaction is open.
+func (t *txReader) recording() bool { return len(t.marks) > 0 }
+
+// append records p as consumed.
+func (t *txReader) append(p []byte) {
+	t.buf = append(t.buf, p...)
+	t.pos = len(t.buf)
+}
+
+// Write implements io.Writer for WriteTo, recording what is copied.
+func (t *txReader) Write(p []byte) (int, error) {
+	t.append(p)
+	return len(p), nil
+}
+
+func (t *txReader) Read(p []byte) (int, error) {
+	if t.pos < len(t.buf) {
+		n := copy(p, t.buf[t.pos:])
+		t.pos += n
+		return n, nil
+	}
+	if !t.recording() {
+		t.buf, t.pos = t.buf[:0], 0
+		return t.ReaderPro.Read(p)
+	}
+	n, err := t.ReaderPro.Read(p)
+	t.append(p[:n])
+	return n, err
+}
+
+func (t *txReader) ReadByte() (byte, error) {
+	if t.pos < len(t.buf) {
+		t.pos++
+		return t.buf[t.pos-1], nil
+	}
+	if !t.recording() {
+		t.buf, t.pos = t.buf[:0], 0
+		return t.ReaderPro.ReadByte()
+	}
+	b, err := t.ReaderPro.ReadByte()
+	if err == nil {
+		t.append([]byte{b})
+	}
+	return b, err
+}
+
+func (t *txReader) WriteTo(w io.Writer) (int64, error) {
+	var n int64
+	if t.pos < len(t.buf) {
+		m, err := w.Write(t.buf[t.pos:])
+		t.pos += m
+		n += int64(m)
+		if err != nil {
+			return n, err
+		}
+	}
+	if t.recording() {
+		w = io.MultiWriter(w, t)
+	}
+	m, err := t.ReaderPro.WriteTo(w)
+	return n + m, err
+}
+
+// UnreadByte backs up within the recorded bytes, or in the ReaderPro. At the
+// start of bytes left to replay, as after Rollback, the ReaderPro is already
+// past them, so there is nothing to back up to.
+func (t *txReader) UnreadByte() error {
+	if t.pos > 0 {
+		t.pos--
+		return nil
+	}
+	if len(t.buf) > 0 {
+		return ErrInvalidUnread
+	}
+	if u, ok := t.ReaderPro.(io.ByteScanner); ok {
+		return u.UnreadByte()
+	}
+	return ErrInvalidUnread
+}
+
+// Peek returns bytes left to replay followed by those of the ReaderPro.
+func (t *txReader) Peek(n int) ([]byte, error) {
+	rest := t.buf[t.pos:]
+	if n <= len(rest) {
+		return rest[:n], nil
+	}
+	p, ok := t.ReaderPro.(peeker)
+	if !ok {
+		return nil, errors.ErrUnsupported
+	}
+	b, err := p.Peek(n - len(rest))
+	if len(rest) == 0 {
+		return b, err
+	}
+	return append(append(make([]byte, 0, len(rest)+len(b)), rest...), b...), err
+}
+
+// Seek moves within the recorded bytes, and reads forward through them
+// while a transaction is open or bytes are left to replay.
+func (t *txReader) Seek(offset int64, whence int) (int64, error) {
+	if !t.recording() && t.pos == len(t.buf) {
+		t.buf, t.pos = t.buf[:0], 0
+		return t.ReaderPro.Seek(offset, whence)
+	}
+	end, err := t.ReaderPro.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return 0, err
+	}
+	cur := end - int64(len(t.buf)-t.pos)
+	target := offset
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		target += cur
+	default:
+		return cur, ErrInvalidWhence
+	}
+	switch {
+	case target >= cur:
+		n, err := io.CopyN(io.Discard, t, target-cur)
+		return cur + n, err
+	case cur-target <= int64(t.pos):
+		t.pos -= int(cur - target)
+		return target, nil
+	}
+	return cur, ErrInvalidSeek
+}
+
+// Begin starts a transaction: the bytes consumed from now on are kept, so
+// Rollback can return to this point even on a stream that cannot seek.
+// Transactions nest, and each must end with Commit or Rollback.
+//
+// Kept bytes are held in memory until the outermost transaction ends, so
+// transactions are meant for short speculative parses, such as trying one
+// format and then another.
+func (r *Reader) Begin() {
+	t := r.tx
+	if t == nil {
+		t = &txReader{ReaderPro: r.r}
+		r.r, r.tx = t, t
+	}
+	if !t.recording() {
+		// Only bytes left to replay are worth keeping.
+		t.buf = append(t.buf[:0], t.buf[t.pos:]...)
+		t.pos = 0
+	}
+	t.marks = append(t.marks, txState{pos: t.pos, count: r.count, err: r.err, failed: r.failed, context: len(r.context)})
+}
+
+// Commit ends the innermost transaction, keeping what was read.
+func (r *Reader) Commit() error {
+	if r.tx == nil || !r.tx.recording() {
+		return ErrNoTransaction
+	}
+	r.tx.marks = r.tx.marks[:len(r.tx.marks)-1]
+	return nil
+}
+
+// Rollback ends the innermost transaction and returns to where it began:
+// the bytes read since are read again, and Count, the latched error and the
+// context pushed by PushContext are restored.
+func (r *Reader) Rollback() error {
+	if r.tx == nil || !r.tx.recording() {
+		return ErrNoTransaction
+	}
+	s := r.tx.marks[len(r.tx.marks)-1]
+	r.tx.marks = r.tx.marks[:len(r.tx.marks)-1]
+	r.tx.pos = s.pos
+	r.count, r.err, r.failed = s.count, s.err, s.failed
+	r.context = r.context[:s.context]
+	return nil
+}
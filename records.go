@@ -0,0 +1,123 @@
+package codec
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+)
+
+// Record is a view of one fixed-layout record during CopyRecords. Fields are
+// decoded on demand in the byte order of the source, Order unless it is a
+// Reader, so a filter only pays for the fields it reads.
+// The view is only valid for the duration of the filter call.
+type Record struct {
+	b       []byte
+	layout  RecordLayout
+	offsets []int
+	order   binary.ByteOrder
+}
+
+// Bytes returns the encoded record.
+func (r Record) Bytes() []byte { return r.b }
+
+// Field returns the encoded bytes of field i.
+func (r Record) Field(i int) []byte {
+	return r.b[r.offsets[i] : r.offsets[i]+r.layout[i]]
+}
+
+// Uint decodes field i as an unsigned integer of its width (1, 2, 4 or 8 bytes).
+func (r Record) Uint(i int) uint64 {
+	b := r.Field(i)
+	switch len(b) {
+	case 1:
+		return uint64(b[0])
+	case 2:
+		return uint64(r.order.Uint16(b))
+	case 4:
+		return uint64(r.order.Uint32(b))
+	case 8:
+		return r.order.Uint64(b)
+	}
+	panic(fmt.Sprintf("codec: field %d has non-scalar width %d", i, len(b)))
+}
+
+// Int decodes field i as a sign-extended integer of its width.
+func (r Record) Int(i int) int64 {
+	v := r.Uint(i)
+	shift := 64 - 8*r.layout[i]
+	return int64(v<<shift) >> shift
+}
+
+// Float decodes field i as a float32 or float64, according to its width.
+func (r Record) Float(i int) float64 {
+	if r.layout[i] == 4 {
+		return float64(math.Float32frombits(uint32(r.Uint(i))))
+	}
+	return math.Float64frombits(r.Uint(i))
+}
+
+// offsets returns the byte offset of every field in l.
+func (l RecordLayout) offsets() []int {
+	offs := make([]int, len(l))
+	off := 0
+	for i, w := range l {
+		offs[i] = off
+		off += w
+	}
+	return offs
+}
+
+// CopyRecords streams fixed-layout records from src to dst, keeping only the
+// records for which filter returns true. A nil filter keeps every record.
+//
+// With a nil projection, kept records are copied verbatim. Otherwise only the
+// listed fields are written, in the listed order, so unselected columns can be
+// dropped without decoding them.
+//
+// It returns the number of bytes written. A trailing partial record is reported
+// as ErrTruncatedData.
+func CopyRecords(dst io.Writer, src io.Reader, layout RecordLayout, filter func(Record) bool, projection []int) (int64, error) {
+	for _, i := range projection {
+		if i < 0 || i >= len(layout) {
+			return 0, fmt.Errorf("%w: projected field %d out of range", ErrInvalidTag, i)
+		}
+	}
+
+	var out []byte
+	var written int64
+	flush := func() error {
+		if len(out) == 0 {
+			return nil
+		}
+		m, err := dst.Write(out)
+		written += int64(m)
+		out = out[:0]
+		return err
+	}
+
+	for rec, err := range Records(src, layout) {
+		if err != nil {
+			if ferr := flush(); ferr != nil {
+				return written, ferr
+			}
+			return written, err
+		}
+		if filter != nil && !filter(rec) {
+			continue
+		}
+		if projection == nil {
+			out = append(out, rec.b...)
+		} else {
+			for _, i := range projection {
+				out = append(out, rec.Field(i)...)
+			}
+		}
+		if len(out) >= CHUNK_SIZE {
+			if err := flush(); err != nil {
+				return written, err
+			}
+		}
+	}
+	return written, flush()
+}
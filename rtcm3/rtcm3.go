@@ -0,0 +1,120 @@
+// Package rtcm3 reads and writes the transport frames of RTCM SC-104
+// version 3, the differential GNSS correction stream: a preamble byte, 6
+// reserved bits and a 10-bit payload length, the payload and a CRC-24Q over
+// everything before it. Messages are bit-packed, most significant bit first;
+// Frame.Bits reads them, starting with the 12-bit message number.
+package rtcm3
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+
+	"github.com/oy3o/codec"
+)
+
+const (
+	// PREAMBLE opens every frame.
+	PREAMBLE = 0xD3
+	// MAX_PAYLOAD is the largest payload the 10-bit length holds.
+	MAX_PAYLOAD = 1023
+)
+
+var (
+	// ErrPreamble indicates a frame that does not start with the preamble.
+	ErrPreamble = errors.New("rtcm3: missing preamble")
+
+	// ErrChecksum indicates a frame whose CRC does not match.
+	ErrChecksum = errors.New("rtcm3: CRC mismatch")
+)
+
+// Frame is an RTCM3 frame.
+type Frame struct {
+	Payload []byte
+}
+
+// Bits returns a BitReader over the payload.
+func (f *Frame) Bits() *codec.BitReader {
+	r, _ := codec.NewReader(codec.NewBytesReader(f.Payload))
+	return codec.NewBitReader(r, codec.MSBFirst)
+}
+
+// MessageType returns the message number opening the payload, such as 1005
+// for the station coordinates, or 0 for an empty payload.
+func (f *Frame) MessageType() uint16 {
+	if len(f.Payload) < 2 {
+		return 0
+	}
+	return uint16(f.Bits().ReadBits(12))
+}
+
+// ReadFrame reads a frame and verifies its CRC. A Reader at the end of the
+// stream returns io.EOF. Reader.WithResync with the PREAMBLE byte can skip
+// to the next frame after an error, though the preamble also occurs inside
+// payloads.
+func ReadFrame(r *codec.Reader) (*Frame, error) {
+	var head [3]byte
+	r.ReadBytesTo(head[:1])
+	if err := r.Err(); err != nil {
+		return nil, err
+	}
+	if head[0] != PREAMBLE {
+		return nil, fmt.Errorf("%w: 0x%02x", ErrPreamble, head[0])
+	}
+	r.ReadBytesTo(head[1:])
+	n := int(codec.BE.Uint16(head[1:]) & MAX_PAYLOAD)
+	f := &Frame{Payload: r.ReadBytes(n)}
+	var crc [3]byte
+	r.ReadBytesTo(crc[:])
+	if err := r.Err(); err != nil {
+		return nil, unexpected(err)
+	}
+	c := codec.NewCRC24Q()
+	c.Write(head[:])
+	c.Write(f.Payload)
+	if want := c.Sum(nil); !bytes.Equal(crc[:], want) {
+		return nil, fmt.Errorf("%w: % x, computed % x", ErrChecksum, crc, want)
+	}
+	return f, nil
+}
+
+// WriteFrame writes a frame with its CRC, with the reserved bits zero.
+func WriteFrame(w *codec.Writer, f *Frame) error {
+	if len(f.Payload) > MAX_PAYLOAD {
+		return fmt.Errorf("%w: payload of %d bytes", codec.ErrLengthOverflow, len(f.Payload))
+	}
+	head := []byte{PREAMBLE, 0, 0}
+	codec.BE.PutUint16(head[1:], uint16(len(f.Payload)))
+	c := codec.NewCRC24Q()
+	c.Write(head)
+	c.Write(f.Payload)
+	w.WriteBytes(head)
+	w.WriteBytes(f.Payload)
+	w.WriteBytes(c.Sum(nil))
+	return w.Err()
+}
+
+// Frames iterates over the frames of r up to the end of the stream,
+// stopping after the first error.
+func Frames(r *codec.Reader) iter.Seq2[*Frame, error] {
+	return func(yield func(*Frame, error) bool) {
+		for {
+			f, err := ReadFrame(r)
+			if err == io.EOF {
+				return
+			}
+			if !yield(f, err) || err != nil {
+				return
+			}
+		}
+	}
+}
+
+func unexpected(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
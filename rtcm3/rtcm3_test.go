@@ -0,0 +1,56 @@
+//go:build test
+
+package rtcm3
+
+import (
+	"bytes"
+	"encoding/hex"
+	"io"
+	"testing"
+
+	"github.com/oy3o/codec"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// station is the message 1005 example of the RTCM 10403 standard.
+var station, _ = hex.DecodeString("d300133ed7d30202980edeef34b4bd62ac0941986f33360b98")
+
+func TestReadFrame(t *testing.T) {
+	r, _ := codec.NewReader(bytes.NewReader(append(station, station...)))
+	var n int
+	for f, err := range Frames(r) {
+		require.NoError(t, err)
+		assert.Len(t, f.Payload, 19)
+		assert.Equal(t, uint16(1005), f.MessageType())
+		bits := f.Bits()
+		bits.ReadBits(12)
+		assert.Equal(t, uint64(2003), bits.ReadBits(12)) // reference station id
+		n++
+	}
+	assert.Equal(t, 2, n)
+
+	bad := bytes.Clone(station)
+	bad[10] ^= 1
+	r, _ = codec.NewReader(bytes.NewReader(bad))
+	_, err := ReadFrame(r)
+	assert.ErrorIs(t, err, ErrChecksum)
+
+	r, _ = codec.NewReader(bytes.NewReader(station[1:]))
+	_, err = ReadFrame(r)
+	assert.ErrorIs(t, err, ErrPreamble)
+
+	r, _ = codec.NewReader(bytes.NewReader(station[:20]))
+	_, err = ReadFrame(r)
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+}
+
+func TestWriteFrame(t *testing.T) {
+	var buf bytes.Buffer
+	w, _ := codec.NewWriter(&buf)
+	require.NoError(t, WriteFrame(w, &Frame{Payload: station[3:22]}))
+	require.NoError(t, w.Flush())
+	assert.Equal(t, station, buf.Bytes())
+
+	assert.ErrorIs(t, WriteFrame(w, &Frame{Payload: make([]byte, MAX_PAYLOAD+1)}), codec.ErrLengthOverflow)
+}
@@ -0,0 +1,362 @@
+// Package rtmp implements the RTMP chunk stream: splitting messages into
+// chunks with compressed headers, and reassembling them on the other side.
+// Message payloads are left to the caller; commands and data messages are
+// AMF, see package amf.
+package rtmp
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/oy3o/codec"
+)
+
+const (
+	// DEFAULT_CHUNK_SIZE is the chunk size of a new connection.
+	DEFAULT_CHUNK_SIZE = 128
+	// MAX_CHUNK_SIZE bounds the chunk size a peer may set.
+	MAX_CHUNK_SIZE = 1<<24 - 1
+	// MAX_MESSAGE_SIZE is the largest message length the header can hold.
+	MAX_MESSAGE_SIZE = 1<<24 - 1
+	// MAX_CHUNK_STREAM_ID is the largest chunk stream ID.
+	MAX_CHUNK_STREAM_ID = 65599
+
+	// extendedTimestamp marks a timestamp carried in the extended field.
+	extendedTimestamp = 0xFFFFFF
+)
+
+// Message types.
+const (
+	TypeSetChunkSize     = 1
+	TypeAbort            = 2
+	TypeAck              = 3
+	TypeUserControl      = 4
+	TypeWindowAckSize    = 5
+	TypeSetPeerBandwidth = 6
+	TypeAudio            = 8
+	TypeVideo            = 9
+	TypeDataAMF3         = 15
+	TypeSharedObjectAMF3 = 16
+	TypeCommandAMF3      = 17
+	TypeDataAMF0         = 18
+	TypeSharedObjectAMF0 = 19
+	TypeCommandAMF0      = 20
+	TypeAggregate        = 22
+)
+
+// CONTROL_STREAM is the chunk stream of protocol control messages.
+const CONTROL_STREAM = 2
+
+var (
+	// ErrInvalidChunk indicates a chunk header that does not follow from
+	// the previous chunks of its stream.
+	ErrInvalidChunk = errors.New("rtmp: invalid chunk")
+
+	// ErrInvalidChunkSize indicates a chunk size outside 1 to MAX_CHUNK_SIZE.
+	ErrInvalidChunkSize = errors.New("rtmp: invalid chunk size")
+)
+
+// Message is an RTMP message.
+type Message struct {
+	ChunkStreamID uint32 // 2 to MAX_CHUNK_STREAM_ID
+	Timestamp     uint32 // milliseconds, wrapping
+	Type          uint8
+	StreamID      uint32 // message stream, 0 for control messages
+	Payload       []byte
+}
+
+// header is the state a chunk stream carries from chunk to chunk.
+type header struct {
+	timestamp uint32
+	delta     uint32
+	length    uint32
+	typ       uint8
+	streamID  uint32
+	extended  bool // the last full header used the extended timestamp
+	started   bool
+}
+
+func unexpected(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// ChunkReader reassembles messages from chunks. Set Chunk Size and Abort
+// messages take effect as they are read, and are returned like any other.
+type ChunkReader struct {
+	r       *codec.Reader
+	size    uint32
+	streams map[uint32]*readStream
+}
+
+type readStream struct {
+	header
+	payload []byte // the message being reassembled
+}
+
+// NewChunkReader returns a ChunkReader reading from r, whose byte order it
+// sets to big-endian.
+func NewChunkReader(r *codec.Reader) *ChunkReader {
+	r.WithByteOrder(codec.BE)
+	return &ChunkReader{r: r, size: DEFAULT_CHUNK_SIZE, streams: map[uint32]*readStream{}}
+}
+
+// ChunkSize returns the size of the chunks being read.
+func (c *ChunkReader) ChunkSize() int { return int(c.size) }
+
+// ReadMessage reads chunks until a message is complete. It returns io.EOF
+// at the end of the stream if no chunk has begun.
+func (c *ChunkReader) ReadMessage() (*Message, error) {
+	for first := true; ; first = false {
+		m, err := c.readChunk()
+		if err != nil {
+			if !first || len(c.partial()) > 0 {
+				err = unexpected(err)
+			}
+			return nil, err
+		}
+		if m == nil {
+			continue
+		}
+		if err := c.control(m); err != nil {
+			return nil, err
+		}
+		return m, nil
+	}
+}
+
+// partial returns a chunk stream with a message under way, if any.
+func (c *ChunkReader) partial() []uint32 {
+	var ids []uint32
+	for id, s := range c.streams {
+		if len(s.payload) > 0 {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// control applies protocol control messages to the reader.
+func (c *ChunkReader) control(m *Message) error {
+	if m.StreamID != 0 || len(m.Payload) < 4 {
+		return nil
+	}
+	v := uint32(m.Payload[0])<<24 | uint32(m.Payload[1])<<16 | uint32(m.Payload[2])<<8 | uint32(m.Payload[3])
+	switch m.Type {
+	case TypeSetChunkSize:
+		v &= 0x7FFFFFFF
+		if v == 0 || v > MAX_CHUNK_SIZE {
+			return fmt.Errorf("%w: %d", ErrInvalidChunkSize, v)
+		}
+		c.size = v
+	case TypeAbort:
+		if s := c.streams[v]; s != nil {
+			s.payload = s.payload[:0]
+		}
+	}
+	return nil
+}
+
+// readBasicHeader reads the format and chunk stream ID of a chunk.
+func (c *ChunkReader) readBasicHeader() (uint8, uint32, error) {
+	b, err := c.r.ReadByte()
+	if err != nil {
+		return 0, 0, c.r.Err()
+	}
+	format, id := b>>6, uint32(b&0x3F)
+	switch id {
+	case 0:
+		var lo uint8
+		c.r.ReadUint8(&lo)
+		id = 64 + uint32(lo)
+	case 1:
+		var v uint16
+		c.r.WithByteOrder(codec.LE).ReadUint16(&v)
+		c.r.WithByteOrder(codec.BE)
+		id = 64 + uint32(v)
+	}
+	return format, id, unexpected(c.r.Err())
+}
+
+// readChunk reads one chunk, returning the message it completes, if any.
+func (c *ChunkReader) readChunk() (*Message, error) {
+	format, id, err := c.readBasicHeader()
+	if err != nil {
+		return nil, err
+	}
+	s := c.streams[id]
+	if s == nil {
+		if format != 0 {
+			return nil, fmt.Errorf("%w: stream %d begins with format %d", ErrInvalidChunk, id, format)
+		}
+		s = &readStream{}
+		c.streams[id] = s
+	}
+	r := c.r
+	fresh := len(s.payload) == 0 // this chunk begins a message
+	if format != 3 && !fresh {
+		return nil, fmt.Errorf("%w: stream %d: format %d inside a message", ErrInvalidChunk, id, format)
+	}
+	var ts uint32
+	if format <= 2 {
+		r.ReadUint24(&ts)
+	}
+	if format <= 1 {
+		r.ReadUint24(&s.length)
+		r.ReadUint8(&s.typ)
+	}
+	if format == 0 {
+		r.WithByteOrder(codec.LE).ReadUint32(&s.streamID)
+		r.WithByteOrder(codec.BE)
+	}
+	if format <= 2 {
+		s.extended = ts == extendedTimestamp
+	}
+	if s.extended {
+		r.ReadUint32(&ts)
+	}
+	if err := r.Err(); err != nil {
+		return nil, unexpected(err)
+	}
+	if fresh {
+		switch format {
+		case 0:
+			// A following format 3 chunk repeats the timestamp field as a
+			// delta, as FFmpeg and librtmp read it.
+			s.timestamp, s.delta = ts, ts
+		case 1, 2:
+			s.timestamp, s.delta = s.timestamp+ts, ts
+		case 3:
+			s.timestamp += s.delta
+		}
+	}
+	if s.length > MAX_MESSAGE_SIZE {
+		return nil, fmt.Errorf("%w: message of %d bytes", codec.ErrLengthOverflow, s.length)
+	}
+	n := min(s.length-uint32(len(s.payload)), c.size)
+	start := len(s.payload)
+	s.payload = append(s.payload, make([]byte, n)...)
+	r.ReadBytesTo(s.payload[start:])
+	if err := r.Err(); err != nil {
+		return nil, unexpected(err)
+	}
+	if uint32(len(s.payload)) < s.length {
+		return nil, nil
+	}
+	m := &Message{ChunkStreamID: id, Timestamp: s.timestamp, Type: s.typ, StreamID: s.streamID, Payload: s.payload}
+	s.payload = nil
+	return m, nil
+}
+
+// ChunkWriter splits messages into chunks, compressing each header against
+// the previous message of its chunk stream.
+type ChunkWriter struct {
+	w       *codec.Writer
+	size    uint32
+	streams map[uint32]*header
+}
+
+// NewChunkWriter returns a ChunkWriter writing to w, whose byte order it
+// sets to big-endian.
+func NewChunkWriter(w *codec.Writer) *ChunkWriter {
+	w.WithByteOrder(codec.BE)
+	return &ChunkWriter{w: w, size: DEFAULT_CHUNK_SIZE, streams: map[uint32]*header{}}
+}
+
+// ChunkSize returns the size of the chunks being written.
+func (c *ChunkWriter) ChunkSize() int { return int(c.size) }
+
+// SetChunkSize sends a Set Chunk Size message and writes later chunks with
+// size n.
+func (c *ChunkWriter) SetChunkSize(n int) error {
+	if n < 1 || n > MAX_CHUNK_SIZE {
+		return fmt.Errorf("%w: %d", ErrInvalidChunkSize, n)
+	}
+	payload := []byte{byte(n >> 24), byte(n >> 16), byte(n >> 8), byte(n)}
+	if err := c.WriteMessage(&Message{ChunkStreamID: CONTROL_STREAM, Type: TypeSetChunkSize, Payload: payload}); err != nil {
+		return err
+	}
+	c.size = uint32(n)
+	return nil
+}
+
+func (c *ChunkWriter) writeBasicHeader(format uint8, id uint32) {
+	w := c.w
+	switch {
+	case id < 64:
+		w.WriteUint8(format<<6 | uint8(id))
+	case id < 64+256:
+		w.WriteUint8(format << 6)
+		w.WriteUint8(uint8(id - 64))
+	default:
+		w.WriteUint8(format<<6 | 1)
+		w.WithByteOrder(codec.LE).WriteUint16(uint16(id - 64))
+		w.WithByteOrder(codec.BE)
+	}
+}
+
+// WriteMessage writes m as chunks. The first chunk uses the smallest header
+// format the previous message of the chunk stream allows; the others use
+// format 3.
+func (c *ChunkWriter) WriteMessage(m *Message) error {
+	if m.ChunkStreamID < 2 || m.ChunkStreamID > MAX_CHUNK_STREAM_ID {
+		return fmt.Errorf("%w: chunk stream %d", ErrInvalidChunk, m.ChunkStreamID)
+	}
+	if len(m.Payload) > MAX_MESSAGE_SIZE {
+		return fmt.Errorf("%w: message of %d bytes", codec.ErrLengthOverflow, len(m.Payload))
+	}
+	s := c.streams[m.ChunkStreamID]
+	if s == nil {
+		s = &header{}
+		c.streams[m.ChunkStreamID] = s
+	}
+	length, delta := uint32(len(m.Payload)), m.Timestamp-s.timestamp
+	var format uint8
+	switch {
+	case !s.started || m.StreamID != s.streamID || m.Timestamp < s.timestamp:
+		format, delta = 0, m.Timestamp
+	case length != s.length || m.Type != s.typ:
+		format = 1
+	case delta != s.delta:
+		format = 2
+	default:
+		format = 3
+	}
+	ts := delta
+	if format <= 2 {
+		s.extended = ts >= extendedTimestamp
+	}
+	*s = header{timestamp: m.Timestamp, delta: delta, length: length, typ: m.Type, streamID: m.StreamID, extended: s.extended, started: true}
+
+	w := c.w
+	for off := uint32(0); off == 0 || off < length; {
+		if off == 0 {
+			c.writeBasicHeader(format, m.ChunkStreamID)
+			if format <= 2 {
+				w.WriteUint24(min(ts, extendedTimestamp))
+			}
+			if format <= 1 {
+				w.WriteUint24(length)
+				w.WriteUint8(m.Type)
+			}
+			if format == 0 {
+				w.WithByteOrder(codec.LE).WriteUint32(m.StreamID)
+				w.WithByteOrder(codec.BE)
+			}
+		} else {
+			c.writeBasicHeader(3, m.ChunkStreamID)
+		}
+		if s.extended {
+			w.WriteUint32(ts)
+		}
+		n := min(length-off, c.size)
+		w.WriteBytes(m.Payload[off : off+n])
+		if off += n; length == 0 {
+			break
+		}
+	}
+	return w.Err()
+}
@@ -0,0 +1,100 @@
+//go:build test
+
+package rtmp
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/oy3o/codec"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func chunkReader(data []byte) *ChunkReader {
+	r, _ := codec.NewReader(bytes.NewReader(data))
+	return NewChunkReader(r)
+}
+
+func TestChunkWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w, _ := codec.NewWriter(&buf)
+	c := NewChunkWriter(w)
+	payload := bytes.Repeat([]byte{0xAB}, 130)
+	require.NoError(t, c.WriteMessage(&Message{ChunkStreamID: 3, Timestamp: 1, Type: TypeCommandAMF0, StreamID: 1, Payload: payload}))
+	require.NoError(t, c.WriteMessage(&Message{ChunkStreamID: 3, Timestamp: 2, Type: TypeCommandAMF0, StreamID: 1, Payload: payload[:2]}))
+	require.NoError(t, w.Flush())
+
+	want := []byte{0x03, 0, 0, 1, 0, 0, 130, TypeCommandAMF0, 1, 0, 0, 0}
+	want = append(want, payload[:128]...)
+	want = append(want, 0xC3, 0xAB, 0xAB)
+	want = append(want, 0x43, 0, 0, 1, 0, 0, 2, TypeCommandAMF0, 0xAB, 0xAB)
+	assert.Equal(t, want, buf.Bytes())
+
+	cr := chunkReader(buf.Bytes())
+	m, err := cr.ReadMessage()
+	require.NoError(t, err)
+	assert.Equal(t, &Message{ChunkStreamID: 3, Timestamp: 1, Type: TypeCommandAMF0, StreamID: 1, Payload: payload}, m)
+	m, err = cr.ReadMessage()
+	require.NoError(t, err)
+	assert.Equal(t, uint32(2), m.Timestamp)
+	_, err = cr.ReadMessage()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestChunkRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	w, _ := codec.NewWriter(&buf)
+	c := NewChunkWriter(w)
+	messages := []*Message{
+		{ChunkStreamID: 4, Timestamp: 0, Type: TypeAudio, StreamID: 1, Payload: []byte{1}},
+		{ChunkStreamID: 4, Timestamp: 20, Type: TypeAudio, StreamID: 1, Payload: []byte{2}},
+		{ChunkStreamID: 4, Timestamp: 40, Type: TypeAudio, StreamID: 1, Payload: []byte{3}},
+		{ChunkStreamID: 100, Timestamp: 0x1000000, Type: TypeVideo, StreamID: 1, Payload: bytes.Repeat([]byte{4}, 300)},
+		{ChunkStreamID: 100, Timestamp: 0x2000001, Type: TypeVideo, StreamID: 1, Payload: bytes.Repeat([]byte{5}, 300)},
+		{ChunkStreamID: 4, Timestamp: 60, Type: TypeAudio, StreamID: 1},
+		{ChunkStreamID: 1000, Timestamp: 5, Type: TypeDataAMF0, StreamID: 2, Payload: []byte{6}},
+	}
+	require.NoError(t, c.SetChunkSize(256))
+	for _, m := range messages {
+		require.NoError(t, c.WriteMessage(m))
+	}
+	require.NoError(t, w.Flush())
+
+	cr := chunkReader(buf.Bytes())
+	m, err := cr.ReadMessage()
+	require.NoError(t, err)
+	assert.Equal(t, uint8(TypeSetChunkSize), m.Type)
+	assert.Equal(t, 256, cr.ChunkSize())
+	for _, want := range messages {
+		m, err := cr.ReadMessage()
+		require.NoError(t, err)
+		assert.Equal(t, want, m)
+	}
+
+	assert.ErrorIs(t, c.SetChunkSize(0), ErrInvalidChunkSize)
+	assert.ErrorIs(t, c.WriteMessage(&Message{ChunkStreamID: 1}), ErrInvalidChunk)
+}
+
+func TestChunkReaderErrors(t *testing.T) {
+	_, err := chunkReader([]byte{0xC3, 0}).ReadMessage()
+	assert.ErrorIs(t, err, ErrInvalidChunk)
+
+	// A message cut short after its first chunk.
+	data := append([]byte{0x03, 0, 0, 0, 0, 0, 200, TypeAudio, 0, 0, 0, 0}, make([]byte, 128)...)
+	cr := chunkReader(data)
+	_, err = cr.ReadMessage()
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+
+	// Abort discards the message under way on stream 3.
+	data = append(data, 0x02, 0, 0, 0, 0, 0, 4, TypeAbort, 0, 0, 0, 0, 0, 0, 0, 3)
+	data = append(data, 0x03, 0, 0, 0, 0, 0, 1, TypeAudio, 0, 0, 0, 0, 7)
+	cr = chunkReader(data)
+	m, err := cr.ReadMessage()
+	require.NoError(t, err)
+	assert.Equal(t, uint8(TypeAbort), m.Type)
+	m, err = cr.ReadMessage()
+	require.NoError(t, err)
+	assert.Equal(t, []byte{7}, m.Payload)
+}
@@ -0,0 +1,623 @@
+// Package sbe decodes and encodes FIX Simple Binary Encoding messages at run
+// time, driven by the XML message schema rather than generated code. A
+// message is the header composite the schema names, giving the block length,
+// template, schema and version, followed by the fixed-size block of the
+// message, its repeating groups, each with a dimension composite, and its
+// var data, each prefixed by its length.
+//
+// Decoded messages are Values keyed by field name:
+//
+//   - integers decode to int64 or uint64, floats to float64, and arrays of
+//     them to slices of those;
+//   - characters decode to strings, without trailing NULs;
+//   - enums decode to the name of their value, sets to the names of their
+//     choices, and composites to Values;
+//   - optional fields holding their null value, and fields newer than the
+//     message version, decode to nil;
+//   - groups decode to []Values, and var data to []byte.
+//
+// Encoding takes the same Values, with any Go integer or float type for
+// numbers and strings for var data as well.
+//
+// ReadFrame and WriteFrame carry messages over streams with the Simple Open
+// Framing Header.
+package sbe
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"slices"
+	"strconv"
+
+	"github.com/oy3o/codec"
+)
+
+var (
+	// ErrInvalidSchema indicates a schema that is not well-formed or refers
+	// to unknown types.
+	ErrInvalidSchema = errors.New("sbe: invalid schema")
+
+	// ErrInvalidMessage indicates a message that is truncated, does not
+	// match its template, or holds values its types cannot encode.
+	ErrInvalidMessage = errors.New("sbe: invalid message")
+
+	// ErrUnknownTemplate indicates a message whose template the schema does
+	// not define.
+	ErrUnknownTemplate = errors.New("sbe: unknown template")
+)
+
+// MAX_GROUP_ENTRIES bounds the entries of a group decoded, as the entries
+// of empty blocks take no bytes to check against.
+const MAX_GROUP_ENTRIES = 1 << 16
+
+// Values holds the decoded fields of a message, group entry or composite.
+type Values map[string]any
+
+// Decoded is a decoded message.
+type Decoded struct {
+	Message *Message
+	Version uint16 // the version the message was encoded with
+	Values  Values
+	Size    int // bytes of the message, header included
+}
+
+// Decode decodes the message opening b.
+func (s *Schema) Decode(b []byte) (*Decoded, error) {
+	if len(b) < s.Header.Size {
+		return nil, fmt.Errorf("%w: %d bytes for the header", ErrInvalidMessage, len(b))
+	}
+	h := s.decodeType(s.Header, b, false).(Values)
+	blockLength, template, schema, version := toUint(h["blockLength"]), toUint(h["templateId"]), toUint(h["schemaId"]), toUint(h["version"])
+	if schema != uint64(s.ID) {
+		return nil, fmt.Errorf("%w: schema %d, want %d", ErrUnknownTemplate, schema, s.ID)
+	}
+	m := s.Message(uint16(template))
+	if m == nil {
+		return nil, fmt.Errorf("%w: %d", ErrUnknownTemplate, template)
+	}
+	d := &decoder{s: s, b: b, pos: s.Header.Size, version: int(version)}
+	v, err := d.block(&m.Block, int(blockLength))
+	if err != nil {
+		return nil, err
+	}
+	return &Decoded{Message: m, Version: uint16(version), Values: v, Size: d.pos}, nil
+}
+
+// decoder walks the groups and var data of a message.
+type decoder struct {
+	s       *Schema
+	b       []byte
+	pos     int
+	version int
+}
+
+func (d *decoder) take(n int, what string) ([]byte, error) {
+	if n < 0 || len(d.b)-d.pos < n {
+		return nil, fmt.Errorf("%w: %s truncated", ErrInvalidMessage, what)
+	}
+	d.pos += n
+	return d.b[d.pos-n : d.pos], nil
+}
+
+// block decodes a block of blockLength bytes, as the encoder's version set
+// it, then its groups and var data.
+func (d *decoder) block(b *Block, blockLength int) (Values, error) {
+	fixed, err := d.take(blockLength, b.Name)
+	if err != nil {
+		return nil, err
+	}
+	v := Values{}
+	for _, f := range b.Fields {
+		switch {
+		case f.Constant != "":
+			v[f.Name] = f.Constant
+		case f.SinceVersion > d.version || f.Offset+f.size() > len(fixed):
+			v[f.Name] = nil
+		default:
+			v[f.Name] = d.s.decodeType(f.Type, fixed[f.Offset:], f.Optional)
+		}
+	}
+	for _, g := range b.Groups {
+		if g.SinceVersion > d.version {
+			continue
+		}
+		raw, err := d.take(g.Dimension.Size, g.Name)
+		if err != nil {
+			return nil, err
+		}
+		dim := d.s.decodeType(g.Dimension, raw, false).(Values)
+		n := toUint(dim["numInGroup"])
+		if n > MAX_GROUP_ENTRIES {
+			return nil, fmt.Errorf("%w: group %s of %d entries", codec.ErrLengthOverflow, g.Name, n)
+		}
+		entries := make([]Values, 0, n)
+		for range n {
+			e, err := d.block(&g.Block, int(toUint(dim["blockLength"])))
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, e)
+		}
+		v[g.Name] = entries
+	}
+	for _, data := range b.Data {
+		if data.SinceVersion > d.version {
+			continue
+		}
+		l := data.Type.member("length")
+		raw, err := d.take(data.Type.Size, data.Name)
+		if err != nil {
+			return nil, err
+		}
+		n := toUint(d.s.decodeType(l.Type, raw[l.Offset:], false))
+		if v[data.Name], err = d.take(int(min(n, math.MaxInt32)), data.Name); err != nil {
+			return nil, err
+		}
+	}
+	return v, nil
+}
+
+// rawBits reads the raw bits of a scalar of primitive type prim.
+func rawBits(b []byte, prim string, order binary.ByteOrder) uint64 {
+	switch primitives[prim] {
+	case 1:
+		return uint64(b[0])
+	case 2:
+		return uint64(order.Uint16(b))
+	case 4:
+		return uint64(order.Uint32(b))
+	}
+	return order.Uint64(b)
+}
+
+// putBits writes the raw bits of a scalar of primitive type prim.
+func putBits(b []byte, prim string, order binary.ByteOrder, x uint64) {
+	switch primitives[prim] {
+	case 1:
+		b[0] = byte(x)
+	case 2:
+		order.PutUint16(b, uint16(x))
+	case 4:
+		order.PutUint32(b, uint32(x))
+	default:
+		order.PutUint64(b, x)
+	}
+}
+
+// scalar converts raw bits to the Go value of prim.
+func scalar(x uint64, prim string) any {
+	switch prim {
+	case "char":
+		return string([]byte{byte(x)})
+	case "int8":
+		return int64(int8(x))
+	case "int16":
+		return int64(int16(x))
+	case "int32":
+		return int64(int32(x))
+	case "int64":
+		return int64(x)
+	case "float":
+		return float64(math.Float32frombits(uint32(x)))
+	case "double":
+		return math.Float64frombits(x)
+	}
+	return x
+}
+
+// nullBits returns the raw bits of the null value of t.
+func nullBits(t *Type) uint64 {
+	size := primitives[t.Primitive]
+	if t.Null != "" {
+		switch t.Primitive {
+		case "char":
+			return uint64(t.Null[0])
+		case "float":
+			f, _ := strconv.ParseFloat(t.Null, 32)
+			return uint64(math.Float32bits(float32(f)))
+		case "double":
+			f, _ := strconv.ParseFloat(t.Null, 64)
+			return math.Float64bits(f)
+		case "int8", "int16", "int32", "int64":
+			i, _ := strconv.ParseInt(t.Null, 10, 64)
+			return uint64(i) & (math.MaxUint64 >> (64 - 8*size))
+		}
+		u, _ := strconv.ParseUint(t.Null, 10, 64)
+		return u
+	}
+	switch t.Primitive {
+	case "char":
+		return 0
+	case "float":
+		return uint64(math.Float32bits(float32(math.NaN())))
+	case "double":
+		return math.Float64bits(math.NaN())
+	case "int8", "int16", "int32", "int64":
+		return 1 << (8*size - 1)
+	}
+	return math.MaxUint64 >> (64 - 8*size)
+}
+
+// isNull reports whether raw bits hold the null value of t. Any NaN is the
+// default null of floats.
+func isNull(t *Type, x uint64) bool {
+	if t.Null == "" {
+		switch v := scalar(x, t.Primitive).(type) {
+		case float64:
+			return math.IsNaN(v)
+		}
+	}
+	return x == nullBits(t)
+}
+
+// decodeType decodes a value of type t from the start of b.
+func (s *Schema) decodeType(t *Type, b []byte, optional bool) any {
+	if t.Constant != "" {
+		return t.Constant
+	}
+	switch t.Kind {
+	case Composite:
+		v := Values{}
+		for _, m := range t.Members {
+			if m.Type.Kind == Primitive && m.Type.Length == 0 {
+				continue // varData, decoded by the block
+			}
+			v[m.Name] = s.decodeType(m.Type, b[m.Offset:], m.Type.Optional)
+		}
+		return v
+
+	case Enum:
+		x := rawBits(b, t.Primitive, s.Order)
+		if optional && isNull(t, x) {
+			return nil
+		}
+		key := fmt.Sprint(scalar(x, t.Primitive))
+		for _, e := range t.Values {
+			if e.Value == key {
+				return e.Name
+			}
+		}
+		return scalar(x, t.Primitive)
+
+	case Set:
+		x := rawBits(b, t.Primitive, s.Order)
+		names := []string{}
+		for _, c := range t.Values {
+			if bit, _ := strconv.Atoi(c.Value); x>>bit&1 != 0 {
+				names = append(names, c.Name)
+			}
+		}
+		return names
+	}
+
+	size := primitives[t.Primitive]
+	if t.Primitive == "char" {
+		text := b[:t.Length]
+		if i := slices.Index(text, 0); i >= 0 {
+			text = text[:i]
+		}
+		if optional && len(text) == 0 && isNull(t, uint64(b[0])) {
+			return nil
+		}
+		return string(text)
+	}
+	if t.Length == 1 {
+		x := rawBits(b, t.Primitive, s.Order)
+		if optional && isNull(t, x) {
+			return nil
+		}
+		return scalar(x, t.Primitive)
+	}
+	switch t.Primitive {
+	case "float", "double":
+		v := make([]float64, t.Length)
+		for i := range v {
+			v[i] = scalar(rawBits(b[i*size:], t.Primitive, s.Order), t.Primitive).(float64)
+		}
+		return v
+	case "int8", "int16", "int32", "int64":
+		v := make([]int64, t.Length)
+		for i := range v {
+			v[i] = scalar(rawBits(b[i*size:], t.Primitive, s.Order), t.Primitive).(int64)
+		}
+		return v
+	}
+	v := make([]uint64, t.Length)
+	for i := range v {
+		v[i] = rawBits(b[i*size:], t.Primitive, s.Order)
+	}
+	return v
+}
+
+// toUint returns an integer decoded by decodeType as a uint64.
+func toUint(v any) uint64 {
+	switch v := v.(type) {
+	case uint64:
+		return v
+	case int64:
+		return uint64(v)
+	}
+	return 0
+}
+
+// toBits converts a Go number, or a one-character string, to the raw bits
+// of a scalar of primitive type prim.
+func toBits(v any, prim string) (uint64, bool) {
+	if s, ok := v.(string); ok {
+		if prim == "char" && len(s) == 1 {
+			return uint64(s[0]), true
+		}
+		return 0, false
+	}
+	rv := reflect.ValueOf(v)
+	switch {
+	case prim == "float" && (rv.CanFloat() || rv.CanInt() || rv.CanUint()):
+		return uint64(math.Float32bits(float32(toFloat(rv)))), true
+	case prim == "double" && (rv.CanFloat() || rv.CanInt() || rv.CanUint()):
+		return math.Float64bits(toFloat(rv)), true
+	case rv.CanInt():
+		return uint64(rv.Int()), true
+	case rv.CanUint():
+		return rv.Uint(), true
+	}
+	return 0, false
+}
+
+func toFloat(rv reflect.Value) float64 {
+	switch {
+	case rv.CanFloat():
+		return rv.Float()
+	case rv.CanInt():
+		return float64(rv.Int())
+	}
+	return float64(rv.Uint())
+}
+
+// encodeType encodes v as a value of type t at the start of b, which is
+// zeroed. A nil v encodes the null value of optional types, and zero
+// otherwise.
+func (s *Schema) encodeType(t *Type, b []byte, v any, optional bool, name string) error {
+	if t.Constant != "" {
+		return nil
+	}
+	invalid := func() error {
+		return fmt.Errorf("%w: %s cannot hold %v (%T)", ErrInvalidMessage, name, v, v)
+	}
+	if v == nil {
+		if optional && t.Kind != Composite && t.Kind != Set {
+			size := primitives[t.Primitive]
+			for i := range t.Length {
+				putBits(b[i*size:], t.Primitive, s.Order, nullBits(t))
+			}
+		}
+		return nil
+	}
+	switch t.Kind {
+	case Composite:
+		values, ok := v.(Values)
+		if !ok {
+			m, ok := v.(map[string]any)
+			if !ok {
+				return invalid()
+			}
+			values = m
+		}
+		for _, m := range t.Members {
+			if m.Type.Kind == Primitive && m.Type.Length == 0 {
+				continue
+			}
+			if err := s.encodeType(m.Type, b[m.Offset:], values[m.Name], m.Type.Optional, name+"."+m.Name); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case Enum:
+		key, ok := v.(string)
+		if !ok {
+			return invalid()
+		}
+		for _, e := range t.Values {
+			if e.Name != key {
+				continue
+			}
+			var x uint64
+			switch t.Primitive {
+			case "char":
+				if len(e.Value) != 1 {
+					return invalid()
+				}
+				x = uint64(e.Value[0])
+			case "int8", "int16", "int32", "int64":
+				i, err := strconv.ParseInt(e.Value, 10, 64)
+				if err != nil {
+					return invalid()
+				}
+				x = uint64(i)
+			default:
+				u, err := strconv.ParseUint(e.Value, 10, 64)
+				if err != nil {
+					return invalid()
+				}
+				x = u
+			}
+			putBits(b, t.Primitive, s.Order, x)
+			return nil
+		}
+		return invalid()
+
+	case Set:
+		names, ok := v.([]string)
+		if !ok {
+			return invalid()
+		}
+		var x uint64
+		for _, n := range names {
+			i := slices.IndexFunc(t.Values, func(c Value) bool { return c.Name == n })
+			if i < 0 {
+				return invalid()
+			}
+			bit, _ := strconv.Atoi(t.Values[i].Value)
+			x |= 1 << bit
+		}
+		putBits(b, t.Primitive, s.Order, x)
+		return nil
+	}
+
+	size := primitives[t.Primitive]
+	if text, ok := v.(string); ok && t.Primitive == "char" {
+		if len(text) > t.Length {
+			return invalid()
+		}
+		copy(b, text)
+		return nil
+	}
+	if t.Length == 1 {
+		x, ok := toBits(v, t.Primitive)
+		if !ok {
+			return invalid()
+		}
+		putBits(b, t.Primitive, s.Order, x)
+		return nil
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice || rv.Len() > t.Length {
+		return invalid()
+	}
+	for i := range rv.Len() {
+		x, ok := toBits(rv.Index(i).Interface(), t.Primitive)
+		if !ok {
+			return invalid()
+		}
+		putBits(b[i*size:], t.Primitive, s.Order, x)
+	}
+	return nil
+}
+
+// Append appends the encoding of a message of template m to dst, with the
+// header of the schema's version.
+func (s *Schema) Append(dst []byte, m *Message, v Values) ([]byte, error) {
+	start := len(dst)
+	dst = append(dst, make([]byte, s.Header.Size)...)
+	h := Values{"blockLength": m.BlockLength, "templateId": m.ID, "schemaId": s.ID, "version": s.Version}
+	if err := s.encodeType(s.Header, dst[start:], h, false, "header"); err != nil {
+		return nil, err
+	}
+	return s.appendBlock(dst, &m.Block, v)
+}
+
+func (s *Schema) appendBlock(dst []byte, b *Block, v Values) ([]byte, error) {
+	start := len(dst)
+	dst = append(dst, make([]byte, b.BlockLength)...)
+	for _, f := range b.Fields {
+		if err := s.encodeType(f.Type, dst[start+f.Offset:], v[f.Name], f.Optional, f.Name); err != nil {
+			return nil, err
+		}
+	}
+	for _, g := range b.Groups {
+		var entries []Values
+		switch e := v[g.Name].(type) {
+		case nil:
+		case []Values:
+			entries = e
+		case []map[string]any:
+			for _, m := range e {
+				entries = append(entries, m)
+			}
+		default:
+			return nil, fmt.Errorf("%w: group %s of %T", ErrInvalidMessage, g.Name, e)
+		}
+		at := len(dst)
+		dst = append(dst, make([]byte, g.Dimension.Size)...)
+		dim := Values{"blockLength": g.BlockLength, "numInGroup": len(entries)}
+		if err := s.encodeType(g.Dimension, dst[at:], dim, false, g.Name); err != nil {
+			return nil, err
+		}
+		if toUint(s.decodeType(g.Dimension, dst[at:], false).(Values)["numInGroup"]) != uint64(len(entries)) {
+			return nil, fmt.Errorf("%w: group %s of %d entries", codec.ErrLengthOverflow, g.Name, len(entries))
+		}
+		for _, e := range entries {
+			var err error
+			if dst, err = s.appendBlock(dst, &g.Block, e); err != nil {
+				return nil, err
+			}
+		}
+	}
+	for _, d := range b.Data {
+		var data []byte
+		switch e := v[d.Name].(type) {
+		case nil:
+		case []byte:
+			data = e
+		case string:
+			data = []byte(e)
+		default:
+			return nil, fmt.Errorf("%w: data %s of %T", ErrInvalidMessage, d.Name, e)
+		}
+		l := d.Type.member("length")
+		if size := primitives[l.Type.Primitive]; size < 8 && uint64(len(data)) > math.MaxUint64>>(64-8*size) {
+			return nil, fmt.Errorf("%w: data %s of %d bytes", codec.ErrLengthOverflow, d.Name, len(data))
+		}
+		at := len(dst)
+		dst = append(dst, make([]byte, d.Type.Size)...)
+		putBits(dst[at+l.Offset:], l.Type.Primitive, s.Order, uint64(len(data)))
+		dst = append(dst, data...)
+	}
+	return dst, nil
+}
+
+// Encoding types of the Simple Open Framing Header.
+const (
+	SBE_BIG_ENDIAN    = 0x5BE0
+	SBE_LITTLE_ENDIAN = 0xEB50
+)
+
+const (
+	// FRAME_HEADER_SIZE is the size of the Simple Open Framing Header.
+	FRAME_HEADER_SIZE = 6
+	// MAX_FRAME_SIZE bounds the size of a frame read by ReadFrame.
+	MAX_FRAME_SIZE = 16 << 20
+)
+
+// ReadFrame reads a message framed by the Simple Open Framing Header: a
+// big-endian uint32 length counting the header, and a uint16 encoding
+// type. A Reader at the end of the stream returns io.EOF.
+func ReadFrame(r *codec.Reader) (msg []byte, encoding uint16, err error) {
+	var head [FRAME_HEADER_SIZE]byte
+	r.ReadBytesTo(head[:])
+	if err := r.Err(); err != nil {
+		return nil, 0, err
+	}
+	n := codec.BE.Uint32(head[:])
+	if n < FRAME_HEADER_SIZE {
+		return nil, 0, fmt.Errorf("%w: frame of %d bytes", ErrInvalidMessage, n)
+	}
+	if n > MAX_FRAME_SIZE {
+		return nil, 0, fmt.Errorf("%w: frame of %d bytes", codec.ErrLengthOverflow, n)
+	}
+	msg = r.ReadBytes(int(n) - FRAME_HEADER_SIZE)
+	if err := r.Err(); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, 0, err
+	}
+	return msg, codec.BE.Uint16(head[4:]), nil
+}
+
+// WriteFrame writes msg framed by the Simple Open Framing Header.
+func WriteFrame(w *codec.Writer, encoding uint16, msg []byte) error {
+	if len(msg) > math.MaxUint32-FRAME_HEADER_SIZE {
+		return fmt.Errorf("%w: frame of %d bytes", codec.ErrLengthOverflow, len(msg))
+	}
+	var head [FRAME_HEADER_SIZE]byte
+	codec.BE.PutUint32(head[:], uint32(FRAME_HEADER_SIZE+len(msg)))
+	codec.BE.PutUint16(head[4:], encoding)
+	w.WriteBytes(head[:])
+	w.WriteBytes(msg)
+	return w.Err()
+}
@@ -0,0 +1,178 @@
+//go:build test
+
+package sbe
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/oy3o/codec"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// carSchema is a cut-down version of the example schema of the SBE
+// reference implementation.
+const carSchema = `<?xml version="1.0" encoding="UTF-8"?>
+<sbe:messageSchema xmlns:sbe="http://fixprotocol.io/2016/sbe"
+                   package="baseline" id="1" version="1" byteOrder="littleEndian">
+    <types>
+        <composite name="messageHeader">
+            <type name="blockLength" primitiveType="uint16"/>
+            <type name="templateId" primitiveType="uint16"/>
+            <type name="schemaId" primitiveType="uint16"/>
+            <type name="version" primitiveType="uint16"/>
+        </composite>
+        <composite name="groupSizeEncoding">
+            <type name="blockLength" primitiveType="uint16"/>
+            <type name="numInGroup" primitiveType="uint16"/>
+        </composite>
+        <composite name="varStringEncoding">
+            <type name="length" primitiveType="uint32" maxValue="1073741824"/>
+            <type name="varData" primitiveType="uint8" length="0" characterEncoding="UTF-8"/>
+        </composite>
+    </types>
+    <types>
+        <type name="ModelYear" primitiveType="uint16"/>
+        <type name="VehicleCode" primitiveType="char" length="6" characterEncoding="ASCII"/>
+        <type name="someNumbers" primitiveType="int32" length="4"/>
+        <type name="Ron" primitiveType="uint8" minValue="90" maxValue="110"/>
+        <composite name="Booster">
+            <enum name="BoostType" encodingType="char">
+                <validValue name="TURBO">T</validValue>
+                <validValue name="SUPERCHARGER">S</validValue>
+            </enum>
+            <type name="horsePower" primitiveType="uint8"/>
+        </composite>
+        <composite name="Engine">
+            <type name="capacity" primitiveType="uint16"/>
+            <type name="numCylinders" primitiveType="uint8"/>
+            <type name="maxRpm" primitiveType="uint16" presence="constant">9000</type>
+            <ref name="booster" type="Booster"/>
+        </composite>
+        <enum name="BooleanType" encodingType="uint8">
+            <validValue name="F">0</validValue>
+            <validValue name="T">1</validValue>
+        </enum>
+        <enum name="Model" encodingType="char">
+            <validValue name="A">A</validValue>
+            <validValue name="B">B</validValue>
+            <validValue name="C">C</validValue>
+        </enum>
+        <set name="OptionalExtras" encodingType="uint8">
+            <choice name="sunRoof">0</choice>
+            <choice name="sportsPack">1</choice>
+            <choice name="cruiseControl">2</choice>
+        </set>
+    </types>
+    <sbe:message name="Car" id="1">
+        <field name="serialNumber" id="1" type="uint64"/>
+        <field name="modelYear" id="2" type="ModelYear"/>
+        <field name="available" id="3" type="BooleanType"/>
+        <field name="code" id="4" type="Model"/>
+        <field name="someNumbers" id="5" type="someNumbers"/>
+        <field name="vehicleCode" id="6" type="VehicleCode"/>
+        <field name="extras" id="7" type="OptionalExtras"/>
+        <field name="discountedModel" id="8" type="Model" presence="constant" valueRef="Model.C"/>
+        <field name="engine" id="9" type="Engine"/>
+        <field name="rating" id="10" type="uint8" presence="optional"/>
+        <group name="fuelFigures" id="11" dimensionType="groupSizeEncoding">
+            <field name="speed" id="12" type="uint16"/>
+            <field name="mpg" id="13" type="float"/>
+            <data name="usageDescription" id="14" type="varStringEncoding"/>
+        </group>
+        <data name="manufacturer" id="15" type="varStringEncoding"/>
+        <data name="model" id="16" type="varStringEncoding"/>
+    </sbe:message>
+</sbe:messageSchema>`
+
+func TestSchema(t *testing.T) {
+	s, err := ParseSchema(strings.NewReader(carSchema))
+	require.NoError(t, err)
+	assert.Equal(t, "baseline", s.Package)
+	assert.Equal(t, 8, s.Header.Size)
+	car := s.MessageByName("Car")
+	require.NotNil(t, car)
+	assert.Same(t, car, s.Message(1))
+	// 8+2+1+1+16+6+1+(2+1+1+1)+1, the constant fields taking no space
+	assert.Equal(t, 41, car.BlockLength)
+	assert.Equal(t, 5, s.Types["Engine"].Size)
+
+	_, err = ParseSchema(strings.NewReader(`<messageSchema id="1"><types><composite name="messageHeader"><ref name="x" type="nope"/></composite></types></messageSchema>`))
+	assert.ErrorIs(t, err, ErrInvalidSchema)
+}
+
+func TestRoundTrip(t *testing.T) {
+	s, err := ParseSchema(strings.NewReader(carSchema))
+	require.NoError(t, err)
+	car := s.MessageByName("Car")
+	in := Values{
+		"serialNumber": uint64(1234),
+		"modelYear":    2013,
+		"available":    "T",
+		"code":         "A",
+		"someNumbers":  []int64{1, 2, 3, 4},
+		"vehicleCode":  "abcdef",
+		"extras":       []string{"sunRoof", "cruiseControl"},
+		"engine":       Values{"capacity": 2000, "numCylinders": 4, "booster": Values{"BoostType": "TURBO", "horsePower": 200}},
+		"fuelFigures": []Values{
+			{"speed": 30, "mpg": 35.9, "usageDescription": "Urban Cycle"},
+			{"speed": 55, "mpg": 49.0, "usageDescription": "Combined Cycle"},
+		},
+		"manufacturer": "Honda",
+		"model":        []byte("Civic VTi"),
+	}
+	b, err := s.Append(nil, car, in)
+	require.NoError(t, err)
+	assert.Equal(t, []byte{41, 0, 1, 0, 1, 0, 1, 0}, b[:8])
+	assert.Equal(t, 8+41+4+2*(6+4)+len("Urban Cycle")+len("Combined Cycle")+4+5+4+9, len(b))
+
+	d, err := s.Decode(b)
+	require.NoError(t, err)
+	assert.Same(t, car, d.Message)
+	assert.Equal(t, len(b), d.Size)
+	v := d.Values
+	assert.Equal(t, uint64(1234), v["serialNumber"])
+	assert.Equal(t, uint64(2013), v["modelYear"])
+	assert.Equal(t, "T", v["available"])
+	assert.Equal(t, "A", v["code"])
+	assert.Equal(t, "C", v["discountedModel"])
+	assert.Equal(t, []int64{1, 2, 3, 4}, v["someNumbers"])
+	assert.Equal(t, "abcdef", v["vehicleCode"])
+	assert.Equal(t, []string{"sunRoof", "cruiseControl"}, v["extras"])
+	assert.Nil(t, v["rating"])
+	engine := v["engine"].(Values)
+	assert.Equal(t, "9000", engine["maxRpm"])
+	assert.Equal(t, "TURBO", engine["booster"].(Values)["BoostType"])
+	fuel := v["fuelFigures"].([]Values)
+	require.Len(t, fuel, 2)
+	assert.Equal(t, uint64(55), fuel[1]["speed"])
+	assert.InDelta(t, 35.9, fuel[0]["mpg"], 1e-5)
+	assert.Equal(t, []byte("Combined Cycle"), fuel[1]["usageDescription"])
+	assert.Equal(t, []byte("Honda"), v["manufacturer"])
+	assert.Equal(t, []byte("Civic VTi"), v["model"])
+
+	_, err = s.Decode(b[:len(b)-1])
+	assert.ErrorIs(t, err, ErrInvalidMessage)
+	b[2] = 9
+	_, err = s.Decode(b)
+	assert.ErrorIs(t, err, ErrUnknownTemplate)
+
+	_, err = s.Append(nil, car, Values{"code": "Z"})
+	assert.ErrorIs(t, err, ErrInvalidMessage)
+}
+
+func TestFrame(t *testing.T) {
+	var buf bytes.Buffer
+	w, _ := codec.NewWriter(&buf)
+	require.NoError(t, WriteFrame(w, SBE_LITTLE_ENDIAN, []byte("message")))
+	require.NoError(t, w.Flush())
+	assert.Equal(t, []byte{0, 0, 0, 13, 0xEB, 0x50}, buf.Bytes()[:6])
+
+	r, _ := codec.NewReader(bytes.NewReader(buf.Bytes()))
+	msg, enc, err := ReadFrame(r)
+	require.NoError(t, err)
+	assert.Equal(t, uint16(SBE_LITTLE_ENDIAN), enc)
+	assert.Equal(t, "message", string(msg))
+}
@@ -0,0 +1,472 @@
+package sbe
+
+import (
+	"encoding/binary"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/oy3o/codec"
+)
+
+// primitives maps the primitive types to their sizes.
+var primitives = map[string]int{
+	"char": 1, "int8": 1, "uint8": 1,
+	"int16": 2, "uint16": 2,
+	"int32": 4, "uint32": 4, "float": 4,
+	"int64": 8, "uint64": 8, "double": 8,
+}
+
+// Kind is the kind of an encoded type.
+type Kind int
+
+const (
+	Primitive Kind = iota
+	Composite
+	Enum
+	Set
+)
+
+// Type is an encoded type of a schema.
+type Type struct {
+	Name string
+	Kind Kind
+	// Primitive is the primitive type, or the encoding type of an enum or
+	// set. It is empty for composites.
+	Primitive string
+	// Length is the number of elements of a primitive type: 1 for scalars,
+	// and 0 for the variable-length part of var data.
+	Length int
+	// Constant holds the value of a constant type, which takes no space.
+	Constant string
+	Optional bool
+	Null     string    // nullValue, empty for the default of the primitive
+	Members  []*Member // composite members
+	Values   []Value   // enum valid values or set choices
+	Size     int       // encoded size in bytes
+}
+
+// Member is a member of a composite.
+type Member struct {
+	Name   string
+	Offset int
+	Type   *Type
+}
+
+// Value is a valid value of an enum, or a choice of a set with the bit it
+// sets.
+type Value struct {
+	Name  string
+	Value string
+}
+
+// member returns the member named name, or nil.
+func (t *Type) member(name string) *Member {
+	for _, m := range t.Members {
+		if m.Name == name {
+			return m
+		}
+	}
+	return nil
+}
+
+// Field is a field of the fixed-size block of a message or group entry.
+type Field struct {
+	Name         string
+	ID           int
+	Type         *Type
+	Offset       int
+	Optional     bool
+	Constant     string // value of a constant field, which takes no space
+	SinceVersion int
+}
+
+// size returns the bytes the field takes in its block.
+func (f *Field) size() int {
+	if f.Constant != "" {
+		return 0
+	}
+	return f.Type.Size
+}
+
+// Block is the layout shared by messages and group entries: a fixed-size
+// block of fields, then repeating groups, then var data.
+type Block struct {
+	Name         string
+	ID           int
+	BlockLength  int
+	SinceVersion int
+	Fields       []*Field
+	Groups       []*Group
+	Data         []*Data
+}
+
+// Group is a repeating group.
+type Group struct {
+	Block
+	Dimension *Type // the composite giving blockLength and numInGroup
+}
+
+// Data is a var data field.
+type Data struct {
+	Name         string
+	ID           int
+	Type         *Type // the composite giving length and varData
+	SinceVersion int
+}
+
+// Message is a message template.
+type Message struct {
+	Block
+}
+
+// Schema is a parsed message schema.
+type Schema struct {
+	Package  string
+	ID       uint16
+	Version  uint16
+	Order    binary.ByteOrder
+	Header   *Type // the message header composite
+	Types    map[string]*Type
+	Messages []*Message
+
+	nodes map[string]*node // type definitions not resolved yet
+	ids   map[uint16]*Message
+}
+
+// Message returns the template of id, or nil.
+func (s *Schema) Message(id uint16) *Message { return s.ids[id] }
+
+// MessageByName returns the template named name, or nil.
+func (s *Schema) MessageByName(name string) *Message {
+	for _, m := range s.Messages {
+		if m.Name == name {
+			return m
+		}
+	}
+	return nil
+}
+
+// node is an XML element kept with its children in document order, which
+// composites and messages depend on.
+type node struct {
+	XMLName  xml.Name
+	Attrs    []xml.Attr `xml:",any,attr"`
+	Text     string     `xml:",chardata"`
+	Children []node     `xml:",any"`
+}
+
+func (n *node) attr(name string) string {
+	for _, a := range n.Attrs {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+func (n *node) intAttr(name string, def int) (int, error) {
+	v := n.attr(name)
+	if v == "" {
+		return def, nil
+	}
+	i, err := strconv.Atoi(v)
+	if err != nil || i < 0 {
+		return 0, fmt.Errorf("%w: %s=%q on %s", ErrInvalidSchema, name, v, n.XMLName.Local)
+	}
+	return i, nil
+}
+
+// ParseSchema parses an SBE XML message schema.
+func ParseSchema(r io.Reader) (*Schema, error) {
+	var root node
+	if err := xml.NewDecoder(r).Decode(&root); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidSchema, err)
+	}
+	if root.XMLName.Local != "messageSchema" {
+		return nil, fmt.Errorf("%w: root element %s", ErrInvalidSchema, root.XMLName.Local)
+	}
+	s := &Schema{
+		Package: root.attr("package"),
+		Order:   codec.LE,
+		Types:   map[string]*Type{},
+		nodes:   map[string]*node{},
+		ids:     map[uint16]*Message{},
+	}
+	id, err := root.intAttr("id", 0)
+	if err != nil {
+		return nil, err
+	}
+	version, err := root.intAttr("version", 0)
+	if err != nil {
+		return nil, err
+	}
+	s.ID, s.Version = uint16(id), uint16(version)
+	switch root.attr("byteOrder") {
+	case "", "littleEndian":
+	case "bigEndian":
+		s.Order = codec.BE
+	default:
+		return nil, fmt.Errorf("%w: byteOrder %q", ErrInvalidSchema, root.attr("byteOrder"))
+	}
+
+	for i := range root.Children {
+		if root.Children[i].XMLName.Local != "types" {
+			continue
+		}
+		for j := range root.Children[i].Children {
+			n := &root.Children[i].Children[j]
+			if name := n.attr("name"); name != "" {
+				s.nodes[name] = n
+			}
+		}
+	}
+	for name := range s.nodes {
+		if _, err := s.lookup(name); err != nil {
+			return nil, err
+		}
+	}
+	header := root.attr("headerType")
+	if header == "" {
+		header = "messageHeader"
+	}
+	if s.Header, err = s.lookup(header); err != nil {
+		return nil, err
+	}
+	for _, m := range []string{"blockLength", "templateId", "schemaId", "version"} {
+		if s.Header.member(m) == nil {
+			return nil, fmt.Errorf("%w: header %s without %s", ErrInvalidSchema, header, m)
+		}
+	}
+
+	for i := range root.Children {
+		n := &root.Children[i]
+		if n.XMLName.Local != "message" {
+			continue
+		}
+		m := new(Message)
+		if err := s.block(n, &m.Block); err != nil {
+			return nil, err
+		}
+		if m.ID < 0 || m.ID > 0xFFFF || s.ids[uint16(m.ID)] != nil {
+			return nil, fmt.Errorf("%w: message id %d", ErrInvalidSchema, m.ID)
+		}
+		s.ids[uint16(m.ID)] = m
+		s.Messages = append(s.Messages, m)
+	}
+	return s, nil
+}
+
+// lookup returns the type named name: a primitive, or a type of the schema,
+// resolving its definition on first use.
+func (s *Schema) lookup(name string) (*Type, error) {
+	if t, ok := s.Types[name]; ok {
+		if t == nil {
+			return nil, fmt.Errorf("%w: type %s refers to itself", ErrInvalidSchema, name)
+		}
+		return t, nil
+	}
+	n, ok := s.nodes[name]
+	if !ok {
+		if size, ok := primitives[name]; ok {
+			return &Type{Name: name, Primitive: name, Length: 1, Size: size}, nil
+		}
+		return nil, fmt.Errorf("%w: unknown type %q", ErrInvalidSchema, name)
+	}
+	s.Types[name] = nil // in progress
+	t, err := s.define(n)
+	if err != nil {
+		return nil, err
+	}
+	s.Types[name] = t
+	return t, nil
+}
+
+// define builds the type an element defines.
+func (s *Schema) define(n *node) (*Type, error) {
+	t := &Type{Name: n.attr("name"), Optional: n.attr("presence") == "optional", Null: n.attr("nullValue")}
+	switch n.XMLName.Local {
+	case "type":
+		t.Primitive = n.attr("primitiveType")
+		size, ok := primitives[t.Primitive]
+		if !ok {
+			return nil, fmt.Errorf("%w: type %s of primitive %q", ErrInvalidSchema, t.Name, t.Primitive)
+		}
+		var err error
+		if t.Length, err = n.intAttr("length", 1); err != nil {
+			return nil, err
+		}
+		if n.attr("presence") == "constant" {
+			t.Constant = strings.TrimSpace(n.Text)
+			if t.Constant == "" {
+				return nil, fmt.Errorf("%w: constant %s without a value", ErrInvalidSchema, t.Name)
+			}
+		} else {
+			t.Size = size * t.Length
+		}
+
+	case "enum", "set":
+		t.Kind = Enum
+		if n.XMLName.Local == "set" {
+			t.Kind = Set
+		}
+		enc, err := s.lookup(n.attr("encodingType"))
+		if err != nil {
+			return nil, err
+		}
+		if enc.Kind != Primitive || enc.Length != 1 {
+			return nil, fmt.Errorf("%w: %s encoded as %s", ErrInvalidSchema, t.Name, enc.Name)
+		}
+		t.Primitive, t.Length, t.Size = enc.Primitive, 1, enc.Size
+		if t.Null == "" {
+			t.Null = enc.Null
+		}
+		for _, c := range n.Children {
+			if c.XMLName.Local == "validValue" || c.XMLName.Local == "choice" {
+				t.Values = append(t.Values, Value{Name: c.attr("name"), Value: strings.TrimSpace(c.Text)})
+			}
+		}
+		if t.Kind == Set {
+			for _, v := range t.Values {
+				if bit, err := strconv.Atoi(v.Value); err != nil || bit < 0 || bit >= 8*t.Size {
+					return nil, fmt.Errorf("%w: choice %s of %s", ErrInvalidSchema, v.Name, t.Name)
+				}
+			}
+		}
+
+	case "composite":
+		t.Kind = Composite
+		offset := 0
+		for i := range n.Children {
+			c := &n.Children[i]
+			var mt *Type
+			var err error
+			switch c.XMLName.Local {
+			case "ref":
+				mt, err = s.lookup(c.attr("type"))
+			case "type", "enum", "set", "composite":
+				mt, err = s.define(c)
+			default:
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			if offset, err = c.intAttr("offset", offset); err != nil {
+				return nil, err
+			}
+			t.Members = append(t.Members, &Member{Name: c.attr("name"), Offset: offset, Type: mt})
+			offset += mt.Size
+			t.Size = max(t.Size, offset)
+		}
+
+	default:
+		return nil, fmt.Errorf("%w: element %s", ErrInvalidSchema, n.XMLName.Local)
+	}
+	return t, nil
+}
+
+// block builds the layout of a message or group element.
+func (s *Schema) block(n *node, b *Block) error {
+	var err error
+	b.Name = n.attr("name")
+	if b.ID, err = n.intAttr("id", 0); err != nil {
+		return err
+	}
+	if b.SinceVersion, err = n.intAttr("sinceVersion", 0); err != nil {
+		return err
+	}
+	offset := 0
+	for i := range n.Children {
+		c := &n.Children[i]
+		switch c.XMLName.Local {
+		case "field":
+			f, err := s.field(c, offset)
+			if err != nil {
+				return err
+			}
+			offset = f.Offset + f.size()
+			b.Fields = append(b.Fields, f)
+
+		case "group":
+			g := new(Group)
+			dim := c.attr("dimensionType")
+			if dim == "" {
+				dim = "groupSizeEncoding"
+			}
+			if g.Dimension, err = s.lookup(dim); err != nil {
+				return err
+			}
+			if g.Dimension.member("blockLength") == nil || g.Dimension.member("numInGroup") == nil {
+				return fmt.Errorf("%w: dimension %s without blockLength and numInGroup", ErrInvalidSchema, dim)
+			}
+			if err := s.block(c, &g.Block); err != nil {
+				return err
+			}
+			b.Groups = append(b.Groups, g)
+
+		case "data":
+			d := &Data{Name: c.attr("name")}
+			if d.ID, err = c.intAttr("id", 0); err != nil {
+				return err
+			}
+			if d.SinceVersion, err = c.intAttr("sinceVersion", 0); err != nil {
+				return err
+			}
+			if d.Type, err = s.lookup(c.attr("type")); err != nil {
+				return err
+			}
+			if l := d.Type.member("length"); l == nil || l.Type.Kind != Primitive || d.Type.member("varData") == nil {
+				return fmt.Errorf("%w: data %s of type %s without length and varData", ErrInvalidSchema, d.Name, d.Type.Name)
+			}
+			b.Data = append(b.Data, d)
+		}
+	}
+	if b.BlockLength, err = n.intAttr("blockLength", offset); err != nil {
+		return err
+	}
+	if b.BlockLength < offset {
+		return fmt.Errorf("%w: %s blockLength %d under its fields' %d bytes", ErrInvalidSchema, b.Name, b.BlockLength, offset)
+	}
+	return nil
+}
+
+// field builds a field element placed at offset unless it gives its own.
+func (s *Schema) field(n *node, offset int) (*Field, error) {
+	f := &Field{Name: n.attr("name")}
+	var err error
+	if f.ID, err = n.intAttr("id", 0); err != nil {
+		return nil, err
+	}
+	if f.SinceVersion, err = n.intAttr("sinceVersion", 0); err != nil {
+		return nil, err
+	}
+	if f.Type, err = s.lookup(n.attr("type")); err != nil {
+		return nil, err
+	}
+	if f.Offset, err = n.intAttr("offset", offset); err != nil {
+		return nil, err
+	}
+	switch n.attr("presence") {
+	case "optional":
+		f.Optional = true
+	case "constant":
+		ref := n.attr("valueRef")
+		if ref == "" {
+			ref = strings.TrimSpace(n.Text)
+		}
+		_, f.Constant, _ = strings.Cut(ref, ".")
+		if f.Constant == "" {
+			f.Constant = ref
+		}
+		if f.Constant == "" {
+			return nil, fmt.Errorf("%w: constant field %s without a value", ErrInvalidSchema, f.Name)
+		}
+	default:
+		f.Optional = f.Type.Optional
+		f.Constant = f.Type.Constant
+	}
+	return f, nil
+}
@@ -0,0 +1,34 @@
+#!/usr/bin/env bash
+# Compare benchmark results of the working tree against a git revision.
+#
+# Usage: scripts/benchcmp.sh [base-rev] [bench-regex] [count]
+#
+# The base revision is checked out into a temporary worktree, both trees are
+# benchmarked with -benchmem, and the results are compared with benchstat
+# (installed on demand) so regressions show up as significant deltas.
+set -euo pipefail
+
+base=${1:-HEAD~1}
+bench=${2:-.}
+count=${3:-6}
+
+root=$(git rev-parse --show-toplevel)
+tmp=$(mktemp -d)
+trap 'git -C "$root" worktree remove --force "$tmp/base" >/dev/null 2>&1 || true; rm -rf "$tmp"' EXIT
+
+git -C "$root" worktree add --detach "$tmp/base" "$base" >/dev/null
+
+run() {
+	(cd "$1" && go test -run '^$' -bench "$bench" -benchmem -count "$count" .)
+}
+
+echo "benchmarking $base ..." >&2
+run "$tmp/base" >"$tmp/old.txt"
+echo "benchmarking working tree ..." >&2
+run "$root" >"$tmp/new.txt"
+
+if command -v benchstat >/dev/null 2>&1; then
+	benchstat "$tmp/old.txt" "$tmp/new.txt"
+else
+	go run golang.org/x/perf/cmd/benchstat@latest "$tmp/old.txt" "$tmp/new.txt"
+fi
@@ -0,0 +1,17 @@
+#!/usr/bin/env bash
+# Build, vet and test the module in the default and codec_tiny profiles, as
+# CI does, so code that only one profile compiles is caught before review.
+#
+# Usage: scripts/check.sh
+set -euo pipefail
+
+cd "$(git rev-parse --show-toplevel)"
+
+test -z "$(gofmt -l .)" || { gofmt -l . >&2; echo "gofmt: files above need formatting" >&2; exit 1; }
+
+for tags in "" codec_tiny; do
+	echo "profile: ${tags:-default}" >&2
+	go build -tags "$tags" ./...
+	go vet -tags "$tags" ./...
+	go test -tags "test $tags" ./...
+done
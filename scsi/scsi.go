@@ -0,0 +1,328 @@
+//go:build !tinygo && !codec_tiny
+
+// Package scsi encodes the command descriptor blocks (CDBs) of common SCSI
+// commands and decodes the data they return. Each CDB is an operation code
+// followed by a body whose big-endian layout, flag bits included, codec.Fixed
+// handles through bitfield tags, so building a READ(16) does not take
+// hand-written shifts. Transports, such as SG_IO or USB mass storage, are
+// left to the caller.
+package scsi
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/oy3o/codec"
+)
+
+// Operation codes.
+const (
+	TEST_UNIT_READY   = 0x00
+	REQUEST_SENSE     = 0x03
+	INQUIRY           = 0x12
+	READ_CAPACITY_10  = 0x25
+	READ_10           = 0x28
+	WRITE_10          = 0x2A
+	SYNCHRONIZE_CACHE = 0x35
+	READ_16           = 0x88
+	WRITE_16          = 0x8A
+	SERVICE_ACTION_IN = 0x9E
+	READ_CAPACITY_16  = 0x10 // service action of SERVICE_ACTION_IN
+)
+
+// Sense keys.
+const (
+	NO_SENSE        = 0x0
+	RECOVERED_ERROR = 0x1
+	NOT_READY       = 0x2
+	MEDIUM_ERROR    = 0x3
+	HARDWARE_ERROR  = 0x4
+	ILLEGAL_REQUEST = 0x5
+	UNIT_ATTENTION  = 0x6
+	DATA_PROTECT    = 0x7
+	BLANK_CHECK     = 0x8
+	ABORTED_COMMAND = 0xB
+	MISCOMPARE      = 0xE
+)
+
+var (
+	// ErrInvalidCDB indicates a CDB of the wrong length or operation code.
+	ErrInvalidCDB = errors.New("scsi: invalid command descriptor block")
+
+	// ErrShortData indicates returned data shorter than its layout.
+	ErrShortData = errors.New("scsi: data too short")
+
+	// ErrInvalidSense indicates sense data of an unknown response code.
+	ErrInvalidSense = errors.New("scsi: invalid sense data")
+)
+
+// Command is the body of a CDB, which knows its operation code.
+type Command interface {
+	Opcode() uint8
+}
+
+// TestUnitReady is the TEST UNIT READY command.
+type TestUnitReady struct {
+	_       [4]byte
+	Control uint8
+}
+
+// RequestSense is the REQUEST SENSE command. Desc asks for descriptor
+// format sense data.
+type RequestSense struct {
+	_                uint8 `codec:"bits=7"`
+	Desc             bool  `codec:"bits=1"`
+	_                [2]byte
+	AllocationLength uint8
+	Control          uint8
+}
+
+// Inquiry is the INQUIRY command. EVPD asks for the vital product data page
+// PageCode instead of the standard data.
+type Inquiry struct {
+	_                uint8 `codec:"bits=7"`
+	EVPD             bool  `codec:"bits=1"`
+	PageCode         uint8
+	AllocationLength uint16
+	Control          uint8
+}
+
+// ReadCapacity10 is the READ CAPACITY(10) command.
+type ReadCapacity10 struct {
+	_       [8]byte
+	Control uint8
+}
+
+// ReadCapacity16 is the READ CAPACITY(16) command. Create it with
+// NewReadCapacity16, which sets the service action.
+type ReadCapacity16 struct {
+	_                uint8 `codec:"bits=3"`
+	ServiceAction    uint8 `codec:"bits=5"`
+	_                [8]byte
+	AllocationLength uint32
+	_                uint8
+	Control          uint8
+}
+
+// NewReadCapacity16 returns a READ CAPACITY(16) command for up to n bytes of
+// data.
+func NewReadCapacity16(n uint32) ReadCapacity16 {
+	return ReadCapacity16{ServiceAction: READ_CAPACITY_16, AllocationLength: n}
+}
+
+// Read10 is the READ(10) command: Blocks logical blocks from LBA. Protect
+// is the RDPROTECT field; DPO and FUA disable caching.
+type Read10 struct {
+	Protect uint8 `codec:"bits=3"`
+	DPO     bool  `codec:"bits=1"`
+	FUA     bool  `codec:"bits=1"`
+	_       uint8 `codec:"bits=3"`
+	LBA     uint32
+	_       uint8 `codec:"bits=3"`
+	Group   uint8 `codec:"bits=5"`
+	Blocks  uint16
+	Control uint8
+}
+
+// Write10 is the WRITE(10) command, laid out like Read10. Protect is the
+// WRPROTECT field.
+type Write10 Read10
+
+// Read16 is the READ(16) command, for LBAs beyond 32 bits.
+type Read16 struct {
+	Protect uint8 `codec:"bits=3"`
+	DPO     bool  `codec:"bits=1"`
+	FUA     bool  `codec:"bits=1"`
+	_       uint8 `codec:"bits=3"`
+	LBA     uint64
+	Blocks  uint32
+	_       uint8 `codec:"bits=3"`
+	Group   uint8 `codec:"bits=5"`
+	Control uint8
+}
+
+// Write16 is the WRITE(16) command, laid out like Read16.
+type Write16 Read16
+
+// SynchronizeCache10 is the SYNCHRONIZE CACHE(10) command. Zero Blocks
+// synchronizes through the last block; Immed returns before it completes.
+type SynchronizeCache10 struct {
+	_       uint8 `codec:"bits=6"`
+	Immed   bool  `codec:"bits=1"`
+	_       uint8 `codec:"bits=1"`
+	LBA     uint32
+	_       uint8 `codec:"bits=3"`
+	Group   uint8 `codec:"bits=5"`
+	Blocks  uint16
+	Control uint8
+}
+
+func (TestUnitReady) Opcode() uint8      { return TEST_UNIT_READY }
+func (RequestSense) Opcode() uint8       { return REQUEST_SENSE }
+func (Inquiry) Opcode() uint8            { return INQUIRY }
+func (ReadCapacity10) Opcode() uint8     { return READ_CAPACITY_10 }
+func (ReadCapacity16) Opcode() uint8     { return SERVICE_ACTION_IN }
+func (Read10) Opcode() uint8             { return READ_10 }
+func (Write10) Opcode() uint8            { return WRITE_10 }
+func (Read16) Opcode() uint8             { return READ_16 }
+func (Write16) Opcode() uint8            { return WRITE_16 }
+func (SynchronizeCache10) Opcode() uint8 { return SYNCHRONIZE_CACHE }
+
+// Size returns the size of CDBs of type T, operation code included.
+func Size[T Command]() int {
+	f := codec.Fixed[T]{}
+	return 1 + f.Size()
+}
+
+// Unmarshal decodes b, a CDB of type T, into c without allocating.
+func Unmarshal[T Command](b []byte, c *T) error {
+	f := codec.Fixed[T]{}
+	f.WithByteOrder(codec.BE)
+	if len(b) != 1+f.Size() || b[0] != (*c).Opcode() {
+		return fmt.Errorf("%w: not a %T", ErrInvalidCDB, *c)
+	}
+	if err := f.UnmarshalBinary(b[1:]); err != nil {
+		return err
+	}
+	*c = f.Payload
+	return nil
+}
+
+// Append appends the CDB of c, operation code included, to dst.
+func Append[T Command](dst []byte, c T) ([]byte, error) {
+	f := codec.Fixed[T]{Payload: c}
+	f.WithByteOrder(codec.BE)
+	return f.MarshalAppend(append(dst, c.Opcode()))
+}
+
+// InquiryData is the standard INQUIRY data.
+type InquiryData struct {
+	Qualifier        uint8 `codec:"bits=3"`
+	DeviceType       uint8 `codec:"bits=5"`
+	RMB              bool  `codec:"bits=1"`
+	_                uint8 `codec:"bits=7"`
+	Version          uint8
+	_                uint8 `codec:"bits=2"`
+	NormACA          bool  `codec:"bits=1"`
+	HiSup            bool  `codec:"bits=1"`
+	ResponseFormat   uint8 `codec:"bits=4"`
+	AdditionalLength uint8
+	Flags            [3]byte
+	Vendor           [8]byte
+	Product          [16]byte
+	Revision         [4]byte
+}
+
+// Peripheral device types.
+const (
+	DIRECT_ACCESS = 0x00
+	SEQUENTIAL    = 0x01
+	CD_DVD        = 0x05
+	ENCLOSURE     = 0x0D
+)
+
+// VendorID returns the vendor identification, without its padding.
+func (d *InquiryData) VendorID() string { return trim(d.Vendor[:]) }
+
+// ProductID returns the product identification, without its padding.
+func (d *InquiryData) ProductID() string { return trim(d.Product[:]) }
+
+// ProductRevision returns the product revision level, without its padding.
+func (d *InquiryData) ProductRevision() string { return trim(d.Revision[:]) }
+
+func trim(b []byte) string { return string(bytes.TrimRight(b, " \x00")) }
+
+// ReadCapacity10Data is the data of READ CAPACITY(10).
+type ReadCapacity10Data struct {
+	LastLBA   uint32 // 0xFFFFFFFF when READ CAPACITY(16) is needed
+	BlockSize uint32
+}
+
+// Capacity returns the capacity in bytes.
+func (d *ReadCapacity10Data) Capacity() uint64 {
+	return (uint64(d.LastLBA) + 1) * uint64(d.BlockSize)
+}
+
+// ReadCapacity16Data is the data of READ CAPACITY(16).
+type ReadCapacity16Data struct {
+	LastLBA          uint64
+	BlockSize        uint32
+	_                uint8  `codec:"bits=4"`
+	ProtectionType   uint8  `codec:"bits=3"`
+	ProtectionEnable bool   `codec:"bits=1"`
+	PIExponent       uint8  `codec:"bits=4"`
+	PhysicalExponent uint8  `codec:"bits=4"` // logical blocks per physical block, log2
+	LBPME            bool   `codec:"bits=1"` // thin provisioned
+	LBPRZ            bool   `codec:"bits=1"` // unmapped blocks read as zeros
+	LowestAlignedLBA uint16 `codec:"bits=14"`
+	_                [16]byte
+}
+
+// Capacity returns the capacity in bytes.
+func (d *ReadCapacity16Data) Capacity() uint64 { return (d.LastLBA + 1) * uint64(d.BlockSize) }
+
+// SenseData is fixed format sense data.
+type SenseData struct {
+	Valid            bool  `codec:"bits=1"` // Information is set
+	ResponseCode     uint8 `codec:"bits=7"`
+	_                uint8
+	Filemark         bool  `codec:"bits=1"`
+	EOM              bool  `codec:"bits=1"`
+	ILI              bool  `codec:"bits=1"`
+	_                uint8 `codec:"bits=1"`
+	Key              uint8 `codec:"bits=4"`
+	Information      uint32
+	AdditionalLength uint8
+	CommandSpecific  uint32
+	ASC              uint8
+	ASCQ             uint8
+	FRU              uint8
+	SKSV             bool   `codec:"bits=1"` // KeySpecific is set
+	KeySpecific      uint32 `codec:"bits=23"`
+}
+
+// Sense data response codes.
+const (
+	FIXED_CURRENT       = 0x70
+	FIXED_DEFERRED      = 0x71
+	DESCRIPTOR_CURRENT  = 0x72
+	DESCRIPTOR_DEFERRED = 0x73
+)
+
+// ParseData decodes data returned by a command into d. Data longer than
+// the layout of T, as devices may return, has its extra bytes ignored.
+func ParseData[T any](b []byte, d *T) error {
+	f := codec.Fixed[T]{}
+	f.WithByteOrder(codec.BE)
+	size := f.Size()
+	if len(b) < size {
+		return fmt.Errorf("%w: %T of %d bytes", ErrShortData, *d, len(b))
+	}
+	if err := f.UnmarshalBinary(b[:size]); err != nil {
+		return err
+	}
+	*d = f.Payload
+	return nil
+}
+
+// ParseSense returns the sense key and additional sense code and qualifier
+// of sense data in either fixed or descriptor format.
+func ParseSense(b []byte) (key, asc, ascq uint8, err error) {
+	if len(b) == 0 {
+		return 0, 0, 0, fmt.Errorf("%w: empty", ErrInvalidSense)
+	}
+	switch b[0] & 0x7F {
+	case FIXED_CURRENT, FIXED_DEFERRED:
+		if len(b) < 14 {
+			return 0, 0, 0, fmt.Errorf("%w: %d bytes", ErrShortData, len(b))
+		}
+		return b[2] & 0x0F, b[12], b[13], nil
+	case DESCRIPTOR_CURRENT, DESCRIPTOR_DEFERRED:
+		if len(b) < 4 {
+			return 0, 0, 0, fmt.Errorf("%w: %d bytes", ErrShortData, len(b))
+		}
+		return b[1] & 0x0F, b[2], b[3], nil
+	}
+	return 0, 0, 0, fmt.Errorf("%w: response code 0x%02x", ErrInvalidSense, b[0]&0x7F)
+}
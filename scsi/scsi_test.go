@@ -0,0 +1,90 @@
+//go:build test && !tinygo && !codec_tiny
+
+package scsi
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCDB(t *testing.T) {
+	assert.Equal(t, 6, Size[TestUnitReady]())
+	assert.Equal(t, 6, Size[Inquiry]())
+	assert.Equal(t, 10, Size[Read10]())
+	assert.Equal(t, 10, Size[SynchronizeCache10]())
+	assert.Equal(t, 16, Size[Read16]())
+	assert.Equal(t, 16, Size[ReadCapacity16]())
+
+	b, err := Append(nil, Inquiry{EVPD: true, PageCode: 0x80, AllocationLength: 252})
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0x12, 0x01, 0x80, 0x00, 0xFC, 0x00}, b)
+
+	b, err = Append(nil, Read10{FUA: true, LBA: 0x01020304, Group: 3, Blocks: 8})
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0x28, 0x08, 1, 2, 3, 4, 0x03, 0x00, 0x08, 0x00}, b)
+	var r Read10
+	require.NoError(t, Unmarshal(b, &r))
+	assert.True(t, r.FUA)
+	assert.Equal(t, uint32(0x01020304), r.LBA)
+	var w Write10
+	assert.ErrorIs(t, Unmarshal(b, &w), ErrInvalidCDB)
+
+	b, err = Append(nil, Write16{Protect: 1, DPO: true, LBA: 1 << 40, Blocks: 256})
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0x8A, 0x30, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0, 1, 0, 0, 0}, b)
+
+	b, err = Append(nil, NewReadCapacity16(32))
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0x9E, 0x10, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 32, 0, 0}, b)
+}
+
+func TestParseData(t *testing.T) {
+	inquiry := append([]byte{0x00, 0x80, 0x06, 0x12, 0x5B, 0, 0, 0x02},
+		[]byte("ATA     Samsung SSD 870 1B6Q")...)
+	inquiry = append(inquiry, make([]byte, 60)...) // vendor specific and version descriptors
+	var d InquiryData
+	require.NoError(t, ParseData(inquiry, &d))
+	assert.Equal(t, uint8(DIRECT_ACCESS), d.DeviceType)
+	assert.True(t, d.RMB)
+	assert.True(t, d.HiSup)
+	assert.Equal(t, uint8(2), d.ResponseFormat)
+	assert.Equal(t, "ATA", d.VendorID())
+	assert.Equal(t, "Samsung SSD 870", d.ProductID())
+	assert.Equal(t, "1B6Q", d.ProductRevision())
+
+	var c ReadCapacity16Data
+	data := []byte{0, 0, 0, 0, 0x74, 0x70, 0x6D, 0xAF, 0, 0, 0x02, 0x00, 0x00, 0x03, 0xC0, 0x00}
+	require.NoError(t, ParseData(append(data, make([]byte, 16)...), &c))
+	assert.Equal(t, uint64(0x74706DB0)*512, c.Capacity())
+	assert.Equal(t, uint8(3), c.PhysicalExponent)
+	assert.True(t, c.LBPME)
+	assert.True(t, c.LBPRZ)
+	assert.ErrorIs(t, ParseData(data, &c), ErrShortData)
+}
+
+func TestSense(t *testing.T) {
+	// Fixed format: ILLEGAL REQUEST, invalid field in CDB, field pointer 2.
+	fixed := []byte{0xF0, 0, 0x05, 0, 0, 0, 7, 10, 0, 0, 0, 0, 0x24, 0x00, 0, 0xC0, 0x00, 0x02}
+	var s SenseData
+	require.NoError(t, ParseData(fixed, &s))
+	assert.True(t, s.Valid)
+	assert.Equal(t, uint8(FIXED_CURRENT), s.ResponseCode)
+	assert.Equal(t, uint8(ILLEGAL_REQUEST), s.Key)
+	assert.Equal(t, uint32(7), s.Information)
+	assert.True(t, s.SKSV)
+	assert.Equal(t, uint32(0x400002), s.KeySpecific)
+
+	key, asc, ascq, err := ParseSense(fixed)
+	require.NoError(t, err)
+	assert.Equal(t, []uint8{ILLEGAL_REQUEST, 0x24, 0x00}, []uint8{key, asc, ascq})
+
+	key, asc, ascq, err = ParseSense([]byte{0x72, UNIT_ATTENTION, 0x29, 0x00, 0, 0, 0, 0})
+	require.NoError(t, err)
+	assert.Equal(t, []uint8{UNIT_ATTENTION, 0x29, 0x00}, []uint8{key, asc, ascq})
+
+	_, _, _, err = ParseSense(bytes.Repeat([]byte{0x7F}, 18))
+	assert.ErrorIs(t, err, ErrInvalidSense)
+}
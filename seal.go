@@ -0,0 +1,241 @@
+package codec
+
+import (
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+)
+
+const (
+	// SEAL_FRAME_SIZE is the default plaintext size of a sealed frame.
+	SEAL_FRAME_SIZE = 64 << 10
+	// MAX_SEAL_FRAME_SIZE bounds the plaintext size of a sealed frame.
+	MAX_SEAL_FRAME_SIZE = 16 << 20
+
+	sealFinal = 1 << 31 // final-frame flag in the length prefix
+)
+
+// Sealed streams are a random nonce prefix followed by frames, each a uint32
+// big-endian length with the final-frame flag in its top bit, then the
+// sealed plaintext. The nonce of a frame is the prefix, the frame counter as
+// a uint32 and a byte holding the final flag, so frames cannot be reordered,
+// dropped or duplicated, and a stream cut short at a frame boundary is
+// detected by the missing final frame.
+
+// sealNonce builds the nonce of frame counter.
+func sealNonce(nonce []byte, counter uint32, final bool) {
+	n := len(nonce)
+	binary.BigEndian.PutUint32(nonce[n-5:], counter)
+	nonce[n-1] = 0
+	if final {
+		nonce[n-1] = 1
+	}
+}
+
+func checkAEAD(aead cipher.AEAD) error {
+	if aead.NonceSize() < 8 {
+		return fmt.Errorf("%w: nonce of %d bytes, need at least 8", ErrInvalidAEAD, aead.NonceSize())
+	}
+	return nil
+}
+
+// SealWriter encrypts a stream into AEAD-sealed frames. Wrap it in a Writer
+// to produce encrypted formats with the usual API:
+//
+//	block, _ := aes.NewCipher(key)
+//	gcm, _ := cipher.NewGCM(block)
+//	sw, _ := codec.NewSealWriter(file, gcm)
+//	w, _ := codec.NewWriter(sw)
+//	...
+//	w.Flush()
+//	sw.Close()
+//
+// A random nonce prefix is drawn per stream, so a key may seal many streams.
+// Close must be called to write the final frame.
+type SealWriter struct {
+	dst     io.Writer
+	aead    cipher.AEAD
+	nonce   []byte
+	counter uint32
+	size    int
+	buf     []byte // pending plaintext
+	out     []byte
+	err     error
+	started bool
+}
+
+// NewSealWriter returns a SealWriter sealing to w with aead, such as AES-GCM.
+func NewSealWriter(w io.Writer, aead cipher.AEAD) (*SealWriter, error) {
+	if w == nil {
+		return nil, ErrNilIO
+	}
+	if err := checkAEAD(aead); err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce[:len(nonce)-5]); err != nil {
+		return nil, err
+	}
+	return &SealWriter{dst: w, aead: aead, nonce: nonce, size: SEAL_FRAME_SIZE}, nil
+}
+
+// WithFrameSize sets the plaintext size of frames, at most
+// MAX_SEAL_FRAME_SIZE. It must be set before the first Write.
+func (s *SealWriter) WithFrameSize(n int) *SealWriter {
+	s.size = min(max(n, 1), MAX_SEAL_FRAME_SIZE)
+	return s
+}
+
+// Write implements io.Writer, sealing a frame whenever a full frame of
+// plaintext is pending.
+func (s *SealWriter) Write(p []byte) (int, error) {
+	if s.err != nil {
+		return 0, s.err
+	}
+	n := len(p)
+	for len(p) > 0 {
+		k := min(s.size-len(s.buf), len(p))
+		s.buf = append(s.buf, p[:k]...)
+		p = p[k:]
+		// Keep a full frame pending: only Close knows which frame is final.
+		if len(s.buf) == s.size && len(p) > 0 {
+			if err := s.seal(false); err != nil {
+				return n - len(p), err
+			}
+		}
+	}
+	return n, nil
+}
+
+func (s *SealWriter) seal(final bool) error {
+	if !s.started {
+		s.started = true
+		if _, err := s.dst.Write(s.nonce[:len(s.nonce)-5]); err != nil {
+			s.err = err
+			return err
+		}
+	}
+	if s.counter == math.MaxUint32 && !final {
+		s.err = fmt.Errorf("%w: sealed frame counter exhausted", ErrLengthOverflow)
+		return s.err
+	}
+	sealNonce(s.nonce, s.counter, final)
+	s.out = append(s.out[:0], 0, 0, 0, 0)
+	s.out = s.aead.Seal(s.out, s.nonce, s.buf, nil)
+	length := uint32(len(s.out) - 4)
+	if final {
+		length |= sealFinal
+	}
+	binary.BigEndian.PutUint32(s.out, length)
+	if _, err := s.dst.Write(s.out); err != nil {
+		s.err = err
+		return err
+	}
+	s.counter++
+	s.buf = s.buf[:0]
+	return nil
+}
+
+// Close seals the pending plaintext as the final frame. It does not close
+// the underlying writer.
+func (s *SealWriter) Close() error {
+	if s.err != nil {
+		return s.err
+	}
+	if err := s.seal(true); err != nil {
+		return err
+	}
+	s.err = ErrClosed
+	return nil
+}
+
+// OpenReader decrypts a stream written by SealWriter. Every frame is
+// authenticated before any of its plaintext is returned; a stream ending
+// before its final frame fails with io.ErrUnexpectedEOF.
+type OpenReader struct {
+	src     io.Reader
+	aead    cipher.AEAD
+	nonce   []byte
+	counter uint32
+	started bool
+	final   bool
+	buf     []byte
+	out     []byte // plaintext not yet returned
+	err     error
+}
+
+// NewOpenReader returns an OpenReader opening frames of r with aead.
+func NewOpenReader(r io.Reader, aead cipher.AEAD) (*OpenReader, error) {
+	if r == nil {
+		return nil, ErrNilIO
+	}
+	if err := checkAEAD(aead); err != nil {
+		return nil, err
+	}
+	return &OpenReader{src: r, aead: aead, nonce: make([]byte, aead.NonceSize())}, nil
+}
+
+// Read implements io.Reader.
+func (o *OpenReader) Read(p []byte) (int, error) {
+	for len(o.out) == 0 {
+		if o.err != nil {
+			return 0, o.err
+		}
+		o.err = o.open()
+	}
+	n := copy(p, o.out)
+	o.out = o.out[n:]
+	return n, nil
+}
+
+// open reads and authenticates the next frame.
+func (o *OpenReader) open() error {
+	if o.final {
+		return io.EOF
+	}
+	if !o.started {
+		o.started = true
+		if _, err := io.ReadFull(o.src, o.nonce[:len(o.nonce)-5]); err != nil {
+			return unexpected(err)
+		}
+	}
+	var prefix [4]byte
+	if _, err := io.ReadFull(o.src, prefix[:]); err != nil {
+		return unexpected(err)
+	}
+	length := binary.BigEndian.Uint32(prefix[:])
+	o.final = length&sealFinal != 0
+	length &^= sealFinal
+	if int(length) > MAX_SEAL_FRAME_SIZE+o.aead.Overhead() || int(length) < o.aead.Overhead() {
+		return fmt.Errorf("%w: sealed frame of %d bytes", ErrLengthOverflow, length)
+	}
+	if cap(o.buf) < int(length) {
+		o.buf = make([]byte, length)
+	}
+	o.buf = o.buf[:length]
+	if _, err := io.ReadFull(o.src, o.buf); err != nil {
+		return unexpected(err)
+	}
+	sealNonce(o.nonce, o.counter, o.final)
+	out, err := o.aead.Open(o.buf[:0], o.nonce, o.buf, nil)
+	if err != nil {
+		return fmt.Errorf("%w: sealed frame %d", ErrAuthFailed, o.counter)
+	}
+	o.counter++
+	o.out = out
+	if o.final && len(out) == 0 {
+		return io.EOF
+	}
+	return nil
+}
+
+// unexpected reports a stream ending inside a sealed stream.
+func unexpected(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
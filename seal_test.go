@@ -0,0 +1,66 @@
+//go:build test
+
+package codec
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSealedStream(t *testing.T) {
+	block, err := aes.NewCipher(make([]byte, 16))
+	require.NoError(t, err)
+	gcm, err := cipher.NewGCM(block)
+	require.NoError(t, err)
+
+	seal := func(payload []byte) []byte {
+		var buf bytes.Buffer
+		sw, err := NewSealWriter(&buf, gcm)
+		require.NoError(t, err)
+		w, _ := NewWriterSize(sw.WithFrameSize(16), BUFFER_SIZE)
+		w.WriteBytes(payload)
+		require.NoError(t, w.Flush())
+		require.NoError(t, sw.Close())
+		_, err = sw.Write([]byte{1})
+		assert.ErrorIs(t, err, ErrClosed)
+		return buf.Bytes()
+	}
+	open := func(sealed []byte) ([]byte, error) {
+		or, err := NewOpenReader(bytes.NewReader(sealed), gcm)
+		require.NoError(t, err)
+		return io.ReadAll(or)
+	}
+
+	for _, n := range []int{0, 5, 16, 40} {
+		payload := bytes.Repeat([]byte{byte(n)}, n)
+		sealed := seal(payload)
+		frames := max(1, (n+15)/16)
+		assert.Equal(t, 7+frames*(4+16)+n, len(sealed), "%d bytes", n)
+		got, err := open(sealed)
+		require.NoError(t, err)
+		assert.Equal(t, payload, got)
+	}
+
+	// Each stream draws a fresh nonce prefix.
+	assert.NotEqual(t, seal([]byte("same")), seal([]byte("same")))
+
+	sealed := seal(bytes.Repeat([]byte{7}, 40))
+	tampered := bytes.Clone(sealed)
+	tampered[7+4] ^= 1
+	_, err = open(tampered)
+	assert.ErrorIs(t, err, ErrAuthFailed)
+
+	// Dropping the final frame, or flagging an earlier one as final, is detected.
+	_, err = open(sealed[:7+2*(4+16+16)])
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	forged := bytes.Clone(sealed)
+	forged[7] |= 0x80
+	_, err = open(forged)
+	assert.ErrorIs(t, err, ErrAuthFailed)
+}
@@ -0,0 +1,257 @@
+package snappy
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+
+	"github.com/oy3o/codec"
+)
+
+const (
+	// MAGIC is the body of the stream identifier chunk that starts a framed stream.
+	MAGIC = "sNaPpY"
+	// CHUNK_SIZE is the most content a chunk of a framed stream may hold.
+	CHUNK_SIZE = 64 << 10
+
+	chunkCompressed   = 0x00
+	chunkUncompressed = 0x01
+	chunkPadding      = 0xFE
+	chunkStream       = 0xFF
+)
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// maskedCRC returns the masked CRC-32C that framed chunks carry.
+func maskedCRC(p []byte) uint32 {
+	c := crc32.Checksum(p, castagnoli)
+	return (c>>15 | c<<17) + 0xa282ead8
+}
+
+// Writer compresses to the Snappy framing format. Close must be called to
+// write the last chunk.
+type Writer struct {
+	dst     io.Writer
+	buf     []byte // pending content
+	out     []byte
+	err     error
+	started bool
+}
+
+// NewWriter returns a Writer compressing to w.
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{dst: w, buf: make([]byte, 0, CHUNK_SIZE)}
+}
+
+func (z *Writer) write(p []byte) error {
+	if _, err := z.dst.Write(p); err != nil {
+		z.err = err
+	}
+	return z.err
+}
+
+// Write implements io.Writer, compressing a chunk whenever one is full.
+func (z *Writer) Write(p []byte) (int, error) {
+	if z.err != nil {
+		return 0, z.err
+	}
+	n := len(p)
+	for len(p) > 0 {
+		k := min(CHUNK_SIZE-len(z.buf), len(p))
+		z.buf = append(z.buf, p[:k]...)
+		p = p[k:]
+		if len(z.buf) == CHUNK_SIZE {
+			if err := z.chunk(); err != nil {
+				return n - len(p), err
+			}
+		}
+	}
+	return n, nil
+}
+
+// chunk writes the pending content as a chunk, stored uncompressed if
+// compression does not shrink it.
+func (z *Writer) chunk() error {
+	if !z.started {
+		z.started = true
+		if err := z.write([]byte("\xff\x06\x00\x00" + MAGIC)); err != nil {
+			return err
+		}
+	}
+	if len(z.buf) == 0 {
+		return nil
+	}
+	z.out = append(z.out[:0], chunkCompressed, 0, 0, 0, 0, 0, 0, 0)
+	z.out = Encode(z.out, z.buf)
+	if len(z.out)-8 >= len(z.buf) {
+		z.out = append(z.out[:8], z.buf...)
+		z.out[0] = chunkUncompressed
+	}
+	size := len(z.out) - 4
+	z.out[1], z.out[2], z.out[3] = byte(size), byte(size>>8), byte(size>>16)
+	binary.LittleEndian.PutUint32(z.out[4:], maskedCRC(z.buf))
+	z.buf = z.buf[:0]
+	return z.write(z.out)
+}
+
+// Close writes the pending chunk. It does not close the underlying writer.
+func (z *Writer) Close() error {
+	if z.err != nil {
+		return z.err
+	}
+	if err := z.chunk(); err != nil {
+		return err
+	}
+	z.err = codec.ErrClosed
+	return nil
+}
+
+// Reader decompresses the Snappy framing format, verifying chunk checksums
+// and skipping padding and skippable chunks.
+type Reader struct {
+	src     io.Reader
+	err     error
+	started bool
+	buf     []byte // chunk body
+	dec     []byte // decompressed chunk
+	out     []byte // decoded content not yet returned
+}
+
+// NewReader returns a Reader decompressing r.
+func NewReader(r io.Reader) *Reader {
+	return &Reader{src: r}
+}
+
+// Read implements io.Reader.
+func (z *Reader) Read(p []byte) (int, error) {
+	for len(z.out) == 0 {
+		if z.err != nil {
+			return 0, z.err
+		}
+		z.err = z.next()
+	}
+	n := copy(p, z.out)
+	z.out = z.out[n:]
+	return n, nil
+}
+
+// next reads the next chunk.
+func (z *Reader) next() error {
+	var head [4]byte
+	if _, err := io.ReadFull(z.src, head[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return fmt.Errorf("%w: truncated chunk header", ErrCorrupt)
+		}
+		return err
+	}
+	kind := head[0]
+	size := int(head[1]) | int(head[2])<<8 | int(head[3])<<16
+	if !z.started && kind != chunkStream {
+		return fmt.Errorf("%w: missing stream identifier", ErrCorrupt)
+	}
+	if kind >= 0x02 && kind <= 0x7F {
+		return fmt.Errorf("%w: reserved chunk type %#x", ErrCorrupt, kind)
+	}
+	if kind == chunkCompressed && size > 4+MaxEncodedLen(CHUNK_SIZE) || kind == chunkUncompressed && size > 4+CHUNK_SIZE {
+		return fmt.Errorf("%w: chunk of %d bytes", codec.ErrLengthOverflow, size)
+	}
+	if kind >= 0x80 && kind != chunkStream {
+		// Padding and skippable chunks.
+		_, err := codec.Discard(z.src, int64(size))
+		return unexpected(err)
+	}
+	if cap(z.buf) < size {
+		z.buf = make([]byte, size)
+	}
+	z.buf = z.buf[:size]
+	if _, err := io.ReadFull(z.src, z.buf); err != nil {
+		return unexpected(err)
+	}
+
+	switch kind {
+	case chunkStream:
+		if string(z.buf) != MAGIC {
+			return fmt.Errorf("%w: stream identifier %q", ErrCorrupt, z.buf)
+		}
+		z.started = true
+		return nil
+	case chunkCompressed, chunkUncompressed:
+		if size < 4 {
+			return fmt.Errorf("%w: chunk of %d bytes", ErrCorrupt, size)
+		}
+		out := z.buf[4:]
+		if kind == chunkCompressed {
+			var err error
+			if z.dec, err = Decode(z.dec[:0], out, CHUNK_SIZE); err != nil {
+				return err
+			}
+			out = z.dec
+		}
+		sum := binary.LittleEndian.Uint32(z.buf)
+		if computed := maskedCRC(out); sum != computed {
+			return fmt.Errorf("%w: chunk checksum %08x, computed %08x", codec.ErrChecksumMismatch, sum, computed)
+		}
+		z.out = out
+	}
+	return nil
+}
+
+// xerialMagic starts the xerial framing written by snappy-java, which Kafka
+// uses for snappy-compressed message sets.
+var xerialMagic = []byte("\x82SNAPPY\x00")
+
+// DecodeXerial appends the content of src to dst. src is either xerial
+// framing, a header followed by blocks each prefixed by a big-endian uint32
+// length, or a single raw block. It fails with codec.ErrLengthOverflow rather
+// than append more than limit bytes.
+func DecodeXerial(dst, src []byte, limit int) ([]byte, error) {
+	if !bytes.HasPrefix(src, xerialMagic) {
+		return Decode(dst, src, limit)
+	}
+	if len(src) < 16 {
+		return dst, fmt.Errorf("%w: truncated xerial header", ErrCorrupt)
+	}
+	start := len(dst)
+	for src = src[16:]; len(src) > 0; {
+		if len(src) < 4 {
+			return dst, fmt.Errorf("%w: truncated xerial block length", ErrCorrupt)
+		}
+		n := binary.BigEndian.Uint32(src)
+		if uint64(n) > uint64(len(src)-4) {
+			return dst, fmt.Errorf("%w: xerial block of %d bytes", ErrCorrupt, n)
+		}
+		var err error
+		if dst, err = Decode(dst, src[4:4+n], limit-(len(dst)-start)); err != nil {
+			return dst, err
+		}
+		src = src[4+n:]
+	}
+	return dst, nil
+}
+
+// EncodeXerial appends the xerial framing of src to dst, in blocks of at
+// most blockSize bytes; snappy-java uses 32 KiB.
+func EncodeXerial(dst, src []byte, blockSize int) []byte {
+	blockSize = max(blockSize, 1)
+	dst = append(dst, xerialMagic...)
+	dst = binary.BigEndian.AppendUint32(dst, 1) // version
+	dst = binary.BigEndian.AppendUint32(dst, 1) // minimum compatible version
+	for len(src) > 0 {
+		n := min(blockSize, len(src))
+		at := len(dst)
+		dst = Encode(append(dst, 0, 0, 0, 0), src[:n])
+		binary.BigEndian.PutUint32(dst[at:], uint32(len(dst)-at-4))
+		src = src[n:]
+	}
+	return dst
+}
+
+// unexpected reports a stream ending inside a chunk.
+func unexpected(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,209 @@
+// Package snappy implements the Snappy raw block format, the Snappy framing
+// format and the xerial framing used by Kafka, for interop with message sets
+// and LevelDB-adjacent files.
+//
+// Importing the package registers Snappy with codec: the block format as the
+// Compressor for codec.CompressSnappy, used by Framer and bundles, and the
+// framing format as the "snappy" Compression, used by CompressWriter and
+// CompressReader.
+package snappy
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+
+	"github.com/oy3o/codec"
+)
+
+// ErrCorrupt indicates Snappy data that cannot be decoded.
+var ErrCorrupt = errors.New("snappy: corrupt input")
+
+const (
+	tagLiteral = 0
+	tagCopy1   = 1
+	tagCopy2   = 2
+	tagCopy4   = 3
+
+	minMatch  = 4
+	maxOffset = 1<<16 - 1
+	hashLog   = 14
+)
+
+func init() {
+	codec.RegisterCompressor(codec.CompressSnappy, Compressor{})
+	codec.RegisterCompression("snappy", codec.Compression{
+		NewWriter: func(w io.Writer) (io.WriteCloser, error) { return NewWriter(w), nil },
+		NewReader: func(r io.Reader) (io.ReadCloser, error) { return io.NopCloser(NewReader(r)), nil },
+	})
+}
+
+// Compressor is a codec.Compressor using the Snappy block format.
+type Compressor struct{}
+
+func (Compressor) Compress(dst, src []byte) ([]byte, error) { return Encode(dst, src), nil }
+
+func (Compressor) Decompress(dst, src []byte, limit int) ([]byte, error) {
+	return Decode(dst, src, limit)
+}
+
+// MaxEncodedLen returns the largest size of the block encoding of n bytes.
+func MaxEncodedLen(n int) int { return 32 + n + n/6 }
+
+func hash(seq uint32) uint32 { return seq * 0x1e35a7bd >> (32 - hashLog) }
+
+// Encode appends the Snappy block encoding of src to dst.
+func Encode(dst, src []byte) []byte {
+	dst = binary.AppendUvarint(dst, uint64(len(src)))
+	if len(src) < minMatch+1 {
+		return appendLiteral(dst, src)
+	}
+	table := make([]int32, 1<<hashLog) // position+1 of the last occurrence of each hash
+	anchor := 0
+	for i := 0; i+minMatch <= len(src); {
+		seq := binary.LittleEndian.Uint32(src[i:])
+		h := hash(seq)
+		ref := int(table[h]) - 1
+		table[h] = int32(i + 1)
+		if ref < 0 || i-ref > maxOffset || binary.LittleEndian.Uint32(src[ref:]) != seq {
+			i++
+			continue
+		}
+		n := minMatch
+		for i+n < len(src) && src[ref+n] == src[i+n] {
+			n++
+		}
+		dst = appendLiteral(dst, src[anchor:i])
+		dst = appendCopy(dst, i-ref, n)
+		i += n
+		anchor = i
+	}
+	return appendLiteral(dst, src[anchor:])
+}
+
+func appendLiteral(dst, lit []byte) []byte {
+	if len(lit) == 0 {
+		return dst
+	}
+	n := uint32(len(lit) - 1)
+	switch {
+	case n < 60:
+		dst = append(dst, byte(n)<<2|tagLiteral)
+	case n < 1<<8:
+		dst = append(dst, 60<<2|tagLiteral, byte(n))
+	case n < 1<<16:
+		dst = append(dst, 61<<2|tagLiteral, byte(n), byte(n>>8))
+	case n < 1<<24:
+		dst = append(dst, 62<<2|tagLiteral, byte(n), byte(n>>8), byte(n>>16))
+	default:
+		dst = append(dst, 63<<2|tagLiteral, byte(n), byte(n>>8), byte(n>>16), byte(n>>24))
+	}
+	return append(dst, lit...)
+}
+
+// appendCopy appends copies of n bytes at offset, at most 64 bytes each.
+func appendCopy(dst []byte, offset, n int) []byte {
+	for n >= 68 {
+		dst = append(dst, 63<<2|tagCopy2, byte(offset), byte(offset>>8))
+		n -= 64
+	}
+	if n > 64 {
+		// Leave at least 4 bytes so the last copy may use the short form.
+		dst = append(dst, 59<<2|tagCopy2, byte(offset), byte(offset>>8))
+		n -= 60
+	}
+	if n >= 4 && n < 12 && offset < 2048 {
+		return append(dst, byte(offset>>8)<<5|byte(n-4)<<2|tagCopy1, byte(offset))
+	}
+	return append(dst, byte(n-1)<<2|tagCopy2, byte(offset), byte(offset>>8))
+}
+
+// DecodedLen returns the decoded length declared by the block src.
+func DecodedLen(src []byte) (int, error) {
+	n, k := binary.Uvarint(src)
+	if k <= 0 || n > 1<<32-1 {
+		return 0, fmt.Errorf("%w: invalid length preamble", ErrCorrupt)
+	}
+	return int(n), nil
+}
+
+// Decode appends the decoding of the Snappy block src to dst. It fails with
+// codec.ErrLengthOverflow if the block declares more than limit bytes.
+func Decode(dst, src []byte, limit int) ([]byte, error) {
+	size, err := DecodedLen(src)
+	if err != nil {
+		return dst, err
+	}
+	if size > limit {
+		return dst, fmt.Errorf("%w: block decodes to %d bytes, limit %d", codec.ErrLengthOverflow, size, limit)
+	}
+	_, i := binary.Uvarint(src)
+	start := len(dst)
+	dst = slices.Grow(dst, size)
+	for i < len(src) {
+		tag := src[i]
+		var offset, n int
+		switch tag & 3 {
+		case tagLiteral:
+			n = int(tag >> 2)
+			i++
+			if n >= 60 {
+				k := n - 59
+				if i+k > len(src) {
+					return dst, fmt.Errorf("%w: truncated literal length", ErrCorrupt)
+				}
+				n = 0
+				for j := k - 1; j >= 0; j-- {
+					n = n<<8 | int(src[i+j])
+				}
+				i += k
+			}
+			n++
+			if n > len(src)-i || n > size-(len(dst)-start) {
+				return dst, fmt.Errorf("%w: literal of %d bytes", ErrCorrupt, n)
+			}
+			dst = append(dst, src[i:i+n]...)
+			i += n
+			continue
+		case tagCopy1:
+			if i+2 > len(src) {
+				return dst, fmt.Errorf("%w: truncated copy", ErrCorrupt)
+			}
+			n = int(tag>>2&7) + 4
+			offset = int(tag>>5)<<8 | int(src[i+1])
+			i += 2
+		case tagCopy2:
+			if i+3 > len(src) {
+				return dst, fmt.Errorf("%w: truncated copy", ErrCorrupt)
+			}
+			n = int(tag>>2) + 1
+			offset = int(binary.LittleEndian.Uint16(src[i+1:]))
+			i += 3
+		case tagCopy4:
+			if i+5 > len(src) {
+				return dst, fmt.Errorf("%w: truncated copy", ErrCorrupt)
+			}
+			n = int(tag>>2) + 1
+			offset = int(binary.LittleEndian.Uint32(src[i+1:]))
+			i += 5
+		}
+		if offset == 0 || offset > len(dst)-start || n > size-(len(dst)-start) {
+			return dst, fmt.Errorf("%w: copy of %d bytes at offset %d", ErrCorrupt, n, offset)
+		}
+		pos := len(dst) - offset
+		if offset >= n {
+			dst = append(dst, dst[pos:pos+n]...)
+		} else {
+			// Overlapping copies repeat the last offset bytes.
+			for k := range n {
+				dst = append(dst, dst[pos+k])
+			}
+		}
+	}
+	if len(dst)-start != size {
+		return dst, fmt.Errorf("%w: block decodes to %d bytes, declares %d", ErrCorrupt, len(dst)-start, size)
+	}
+	return dst, nil
+}
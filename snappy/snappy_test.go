@@ -0,0 +1,130 @@
+//go:build test
+
+package snappy
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"testing"
+
+	"github.com/oy3o/codec"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBlock(t *testing.T) {
+	// A literal "abc" and an overlapping 1-byte-offset copy of 8 bytes.
+	out, err := Decode(nil, []byte{0x0b, 0x08, 'a', 'b', 'c', 0x11, 0x03}, 100)
+	require.NoError(t, err)
+	assert.Equal(t, "abcabcabcab", string(out))
+	assert.Equal(t, []byte{0}, Encode(nil, nil))
+
+	random := make([]byte, 70000)
+	rand.New(rand.NewSource(1)).Read(random)
+	inputs := [][]byte{
+		nil,
+		[]byte("tiny"),
+		bytes.Repeat([]byte{'a'}, 1000),
+		bytes.Repeat([]byte("abcdefghij"), 30000),
+		append(bytes.Repeat([]byte("x"), 300), random...),
+	}
+	for _, in := range inputs {
+		c := Encode(nil, in)
+		assert.LessOrEqual(t, len(c), MaxEncodedLen(len(in)))
+		n, err := DecodedLen(c)
+		require.NoError(t, err)
+		assert.Equal(t, len(in), n)
+		out, err := Decode(nil, c, len(in))
+		require.NoError(t, err)
+		assert.True(t, bytes.Equal(in, out))
+	}
+	assert.Less(t, len(Encode(nil, bytes.Repeat([]byte("abcdefghij"), 30000))), 15000)
+
+	c := Encode(nil, bytes.Repeat([]byte{'a'}, 1000))
+	_, err = Decode(nil, c, 999)
+	assert.ErrorIs(t, err, codec.ErrLengthOverflow)
+	_, err = Decode(nil, c[:len(c)-1], 1000)
+	assert.ErrorIs(t, err, ErrCorrupt)
+	_, err = Decode(nil, []byte{0x04, 0x0d, 0x05}, 100) // copy before the start
+	assert.ErrorIs(t, err, ErrCorrupt)
+}
+
+func TestFrame(t *testing.T) {
+	in := bytes.Repeat([]byte("message set "), 10000)
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	_, err := w.Write(in)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	_, err = w.Write([]byte{1})
+	assert.ErrorIs(t, err, codec.ErrClosed)
+	assert.Equal(t, "\xff\x06\x00\x00sNaPpY", buf.String()[:10])
+	assert.Less(t, buf.Len(), len(in)/10)
+	frame := bytes.Clone(buf.Bytes())
+
+	// Padding, a skippable chunk and an uncompressed chunk follow.
+	buf.Write([]byte{chunkPadding, 2, 0, 0, 0, 0, 0x80, 1, 0, 0, 9})
+	buf.Write([]byte{chunkUncompressed, 7, 0, 0, 0, 0, 0, 0, 'h', 'i', '!'})
+	tail := buf.Bytes()[buf.Len()-7:]
+	crc := maskedCRC([]byte("hi!"))
+	tail[0], tail[1], tail[2], tail[3] = byte(crc), byte(crc>>8), byte(crc>>16), byte(crc>>24)
+	out, err := io.ReadAll(NewReader(&buf))
+	require.NoError(t, err)
+	assert.Equal(t, append(bytes.Clone(in), "hi!"...), out)
+
+	corrupt := bytes.Clone(frame)
+	corrupt[15] ^= 1 // the checksum of the first chunk
+	_, err = io.ReadAll(NewReader(bytes.NewReader(corrupt)))
+	assert.ErrorIs(t, err, codec.ErrChecksumMismatch)
+	_, err = io.ReadAll(NewReader(bytes.NewReader(frame[:len(frame)-3])))
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	_, err = io.ReadAll(NewReader(bytes.NewReader(frame[10:])))
+	assert.ErrorIs(t, err, ErrCorrupt)
+	_, err = io.ReadAll(NewReader(bytes.NewReader(append(frame[:10:10], 0x02, 0, 0, 0))))
+	assert.ErrorIs(t, err, ErrCorrupt)
+}
+
+func TestXerial(t *testing.T) {
+	in := bytes.Repeat([]byte("kafka record "), 5000)
+	x := EncodeXerial(nil, in, 32<<10)
+	assert.Equal(t, xerialMagic, x[:8])
+	out, err := DecodeXerial(nil, x, len(in))
+	require.NoError(t, err)
+	assert.Equal(t, in, out)
+	_, err = DecodeXerial(nil, x, len(in)-1)
+	assert.ErrorIs(t, err, codec.ErrLengthOverflow)
+	_, err = DecodeXerial(nil, x[:len(x)-5], len(in))
+	assert.ErrorIs(t, err, ErrCorrupt)
+
+	// Raw blocks pass through.
+	out, err = DecodeXerial(nil, Encode(nil, []byte("raw")), 3)
+	require.NoError(t, err)
+	assert.Equal(t, "raw", string(out))
+}
+
+func TestRegistered(t *testing.T) {
+	var buf bytes.Buffer
+	f := codec.NewFramer(&buf, &buf).WithCompression(codec.CompressSnappy)
+	payload := bytes.Repeat([]byte("frame "), 500)
+	require.NoError(t, f.WriteFrame(payload))
+	assert.Less(t, buf.Len(), len(payload)/4)
+	p, err := f.ReadFrame()
+	require.NoError(t, err)
+	assert.Equal(t, payload, p)
+
+	buf.Reset()
+	w, _ := codec.NewWriter(&buf)
+	cw, err := codec.CompressWriter(w, "snappy")
+	require.NoError(t, err)
+	cw.WriteBytes(payload)
+	require.NoError(t, cw.Close())
+	require.NoError(t, w.Flush())
+
+	r, _ := codec.NewReader(&buf)
+	cr, err := codec.CompressReader(r, "snappy")
+	require.NoError(t, err)
+	out, err := io.ReadAll(cr)
+	require.NoError(t, err)
+	assert.Equal(t, payload, out)
+}
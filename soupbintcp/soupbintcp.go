@@ -0,0 +1,213 @@
+// Package soupbintcp reads and writes the packets of SoupBinTCP, the session
+// protocol carrying sequenced messages such as ITCH and OUCH over TCP. A
+// packet is a big-endian uint16 length, counting the type byte and payload,
+// followed by the type byte and payload. The login packets are encoded here;
+// the messages inside data packets are left to the caller.
+package soupbintcp
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/oy3o/codec"
+)
+
+// Packet types sent by the server.
+const (
+	Debug           byte = '+' // both directions
+	LoginAccepted   byte = 'A'
+	LoginRejected   byte = 'J'
+	SequencedData   byte = 'S'
+	ServerHeartbeat byte = 'H'
+	EndOfSession    byte = 'Z'
+)
+
+// Packet types sent by the client.
+const (
+	LoginRequest    byte = 'L'
+	UnsequencedData byte = 'U'
+	ClientHeartbeat byte = 'R'
+	LogoutRequest   byte = 'O'
+)
+
+// Reject reasons of a LoginRejected packet.
+const (
+	NotAuthorized       byte = 'A'
+	SessionNotAvailable byte = 'S'
+)
+
+// MAX_PAYLOAD is the largest payload a packet length can declare.
+const MAX_PAYLOAD = math.MaxUint16 - 1
+
+var (
+	// ErrInvalidPacket indicates a packet of length 0, or a login packet of
+	// the wrong size or with malformed fields.
+	ErrInvalidPacket = errors.New("soupbintcp: invalid packet")
+)
+
+// Packet is a packet.
+type Packet struct {
+	Type    byte
+	Payload []byte
+}
+
+// ReadPacket reads a packet. A Reader at the end of the stream returns
+// io.EOF.
+func ReadPacket(r *codec.Reader) (Packet, error) {
+	var size [2]byte
+	r.ReadBytesTo(size[:])
+	if err := r.Err(); err != nil {
+		return Packet{}, err
+	}
+	n := int(codec.BE.Uint16(size[:]))
+	if n == 0 {
+		return Packet{}, fmt.Errorf("%w: length 0", ErrInvalidPacket)
+	}
+	var typ byte
+	r.ReadUint8(&typ)
+	payload := r.ReadBytes(n - 1)
+	if err := r.Err(); err != nil {
+		return Packet{}, unexpected(err)
+	}
+	return Packet{Type: typ, Payload: payload}, nil
+}
+
+// WritePacket writes a packet.
+func WritePacket(w *codec.Writer, p Packet) error {
+	if len(p.Payload) > MAX_PAYLOAD {
+		return fmt.Errorf("%w: payload of %d bytes", codec.ErrLengthOverflow, len(p.Payload))
+	}
+	var head [3]byte
+	codec.BE.PutUint16(head[:], uint16(1+len(p.Payload)))
+	head[2] = p.Type
+	w.WriteBytes(head[:])
+	w.WriteBytes(p.Payload)
+	return w.Err()
+}
+
+// Packets iterates over the packets of r up to the end of the stream,
+// stopping after the first error.
+func Packets(r *codec.Reader) iter.Seq2[Packet, error] {
+	return func(yield func(Packet, error) bool) {
+		for {
+			p, err := ReadPacket(r)
+			if err == io.EOF {
+				return
+			}
+			if !yield(p, err) || err != nil {
+				return
+			}
+		}
+	}
+}
+
+func unexpected(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// Login is the payload of a LoginRequest packet. An empty Session asks for
+// the current session, and a Sequence of 0 for the next message to be sent.
+type Login struct {
+	Username string // up to 6 bytes
+	Password string // up to 10 bytes
+	Session  string // up to 10 bytes
+	Sequence uint64
+}
+
+// Accepted is the payload of a LoginAccepted packet: the session joined and
+// the sequence number of the next message.
+type Accepted struct {
+	Session  string
+	Sequence uint64
+}
+
+// alpha appends s left-justified in a field of n bytes padded with spaces.
+func alpha(dst []byte, s string, n int) ([]byte, error) {
+	if len(s) > n {
+		return nil, fmt.Errorf("%w: %q over %d bytes", ErrInvalidPacket, s, n)
+	}
+	dst = append(dst, s...)
+	for range n - len(s) {
+		dst = append(dst, ' ')
+	}
+	return dst, nil
+}
+
+// numeric appends v right-justified in a field of 20 bytes padded with
+// spaces.
+func numeric(dst []byte, v uint64) []byte {
+	s := strconv.FormatUint(v, 10)
+	for range 20 - len(s) {
+		dst = append(dst, ' ')
+	}
+	return append(dst, s...)
+}
+
+func parseNumeric(field []byte) (uint64, error) {
+	v, err := strconv.ParseUint(strings.TrimSpace(string(field)), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w: sequence %q", ErrInvalidPacket, field)
+	}
+	return v, nil
+}
+
+// AppendLogin appends the payload of a LoginRequest packet to dst.
+func AppendLogin(dst []byte, l Login) ([]byte, error) {
+	var err error
+	if dst, err = alpha(dst, l.Username, 6); err != nil {
+		return nil, err
+	}
+	if dst, err = alpha(dst, l.Password, 10); err != nil {
+		return nil, err
+	}
+	if dst, err = alpha(dst, l.Session, 10); err != nil {
+		return nil, err
+	}
+	return numeric(dst, l.Sequence), nil
+}
+
+// ParseLogin parses the payload of a LoginRequest packet.
+func ParseLogin(payload []byte) (Login, error) {
+	if len(payload) != 46 {
+		return Login{}, fmt.Errorf("%w: login request of %d bytes", ErrInvalidPacket, len(payload))
+	}
+	seq, err := parseNumeric(payload[26:])
+	if err != nil {
+		return Login{}, err
+	}
+	return Login{
+		Username: strings.TrimRight(string(payload[:6]), " "),
+		Password: strings.TrimRight(string(payload[6:16]), " "),
+		Session:  strings.TrimRight(string(payload[16:26]), " "),
+		Sequence: seq,
+	}, nil
+}
+
+// AppendAccepted appends the payload of a LoginAccepted packet to dst.
+func AppendAccepted(dst []byte, a Accepted) ([]byte, error) {
+	dst, err := alpha(dst, a.Session, 10)
+	if err != nil {
+		return nil, err
+	}
+	return numeric(dst, a.Sequence), nil
+}
+
+// ParseAccepted parses the payload of a LoginAccepted packet.
+func ParseAccepted(payload []byte) (Accepted, error) {
+	if len(payload) != 30 {
+		return Accepted{}, fmt.Errorf("%w: login accepted of %d bytes", ErrInvalidPacket, len(payload))
+	}
+	seq, err := parseNumeric(payload[10:])
+	if err != nil {
+		return Accepted{}, err
+	}
+	return Accepted{Session: strings.TrimRight(string(payload[:10]), " "), Sequence: seq}, nil
+}
@@ -0,0 +1,60 @@
+//go:build test
+
+package soupbintcp
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/oy3o/codec"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPackets(t *testing.T) {
+	login, err := AppendLogin(nil, Login{Username: "user", Password: "secret", Sequence: 12})
+	require.NoError(t, err)
+	assert.Len(t, login, 46)
+	assert.Equal(t, "user  secret                                12", string(login))
+
+	var buf bytes.Buffer
+	w, _ := codec.NewWriter(&buf)
+	require.NoError(t, WritePacket(w, Packet{Type: LoginRequest, Payload: login}))
+	require.NoError(t, WritePacket(w, Packet{Type: ClientHeartbeat}))
+	require.NoError(t, WritePacket(w, Packet{Type: UnsequencedData, Payload: []byte("msg")}))
+	require.NoError(t, w.Flush())
+	assert.Equal(t, []byte{0, 47, 'L'}, buf.Bytes()[:3])
+
+	r, _ := codec.NewReader(bytes.NewReader(buf.Bytes()))
+	var got []Packet
+	for p, err := range Packets(r) {
+		require.NoError(t, err)
+		got = append(got, p)
+	}
+	require.Len(t, got, 3)
+	assert.Equal(t, ClientHeartbeat, got[1].Type)
+	assert.Empty(t, got[1].Payload)
+	assert.Equal(t, "msg", string(got[2].Payload))
+
+	l, err := ParseLogin(got[0].Payload)
+	require.NoError(t, err)
+	assert.Equal(t, Login{Username: "user", Password: "secret", Sequence: 12}, l)
+
+	r, _ = codec.NewReader(bytes.NewReader(buf.Bytes()[:10]))
+	_, err = ReadPacket(r)
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+}
+
+func TestAccepted(t *testing.T) {
+	b, err := AppendAccepted(nil, Accepted{Session: "S1", Sequence: 1})
+	require.NoError(t, err)
+	a, err := ParseAccepted(b)
+	require.NoError(t, err)
+	assert.Equal(t, Accepted{Session: "S1", Sequence: 1}, a)
+
+	_, err = AppendLogin(nil, Login{Username: "toolonguser"})
+	assert.ErrorIs(t, err, ErrInvalidPacket)
+	_, err = ParseAccepted(b[:29])
+	assert.ErrorIs(t, err, ErrInvalidPacket)
+}
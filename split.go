@@ -0,0 +1,94 @@
+package codec
+
+import (
+	"bytes"
+	"io"
+)
+
+// SplitBoundary locates the first offset at or after off where a reader can
+// safely start decoding. It returns size if no such offset exists.
+type SplitBoundary func(r io.ReaderAt, off, size int64) (int64, error)
+
+// SyncBoundary returns a SplitBoundary for sync-marked containers. The returned
+// offsets point at the start of a marker, so a split reader should begin with
+// Reader.AlignSync.
+func SyncBoundary(m SyncMarker) SplitBoundary {
+	return func(r io.ReaderAt, off, size int64) (int64, error) {
+		if len(m) == 0 {
+			return size, ErrNoSyncMarker
+		}
+
+		bufPtr := bufPool.Get().(*[]byte)
+		defer bufPool.Put(bufPtr)
+		buf := *bufPtr
+
+		// Consecutive chunks overlap by len(m)-1 bytes so markers straddling
+		// a chunk boundary are still found.
+		for off < size {
+			n, err := r.ReadAt(buf[:min(int64(len(buf)), size-off)], off)
+			if i := bytes.Index(buf[:n], m); i >= 0 {
+				return off + int64(i), nil
+			}
+			if err != nil && err != io.EOF {
+				return size, err
+			}
+			if n < len(m) || off+int64(n) >= size {
+				break
+			}
+			off += int64(n - len(m) + 1)
+		}
+		return size, nil
+	}
+}
+
+// BlockBoundary returns a SplitBoundary for containers made of fixed-size blocks.
+func BlockBoundary(block int64) SplitBoundary {
+	return func(_ io.ReaderAt, off, size int64) (int64, error) {
+		if block <= 1 {
+			return off, nil
+		}
+		return min((off+block-1)/block*block, size), nil
+	}
+}
+
+// SplitPoints divides a container of the given size into at most desiredSplits
+// ranges whose starting offsets are safe decode positions, so that independent
+// workers can process a single large file in parallel.
+//
+// The returned offsets are strictly increasing and always start with 0; split i
+// covers [points[i], points[i+1]) and the last split ends at size. Fewer splits
+// are returned when boundaries are sparser than requested.
+func SplitPoints(r io.ReaderAt, size int64, desiredSplits int, boundary SplitBoundary) ([]int64, error) {
+	points := []int64{0}
+	for i := 1; i < desiredSplits; i++ {
+		nominal := size * int64(i) / int64(desiredSplits)
+		if nominal <= points[len(points)-1] {
+			continue
+		}
+		off, err := boundary(r, nominal, size)
+		if err != nil {
+			return points, err
+		}
+		if off >= size {
+			break
+		}
+		if off > points[len(points)-1] {
+			points = append(points, off)
+		}
+	}
+	return points, nil
+}
+
+// SplitSections returns a bounded reader for every split described by points,
+// as produced by SplitPoints.
+func SplitSections(r io.ReaderAt, size int64, points []int64) []*io.SectionReader {
+	sections := make([]*io.SectionReader, len(points))
+	for i, start := range points {
+		end := size
+		if i+1 < len(points) {
+			end = points[i+1]
+		}
+		sections[i] = io.NewSectionReader(r, start, end-start)
+	}
+	return sections
+}
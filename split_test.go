@@ -0,0 +1,55 @@
+//go:build test
+
+package codec
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitPoints(t *testing.T) {
+	marker := NewSyncMarker()
+
+	// Write 64 frames of 1000 bytes, each preceded by the sync marker.
+	var buf bytes.Buffer
+	w, _ := NewWriter(&buf)
+	for i := 0; i < 64; i++ {
+		w.WriteSync(marker)
+		w.WriteBytes(bytes.Repeat([]byte{byte(i)}, 1000))
+	}
+	_, err := w.Result()
+	require.NoError(t, err)
+
+	data := bytes.NewReader(buf.Bytes())
+	size := int64(buf.Len())
+	points, err := SplitPoints(data, size, 4, SyncBoundary(marker))
+	require.NoError(t, err)
+	require.Len(t, points, 4)
+
+	frames := 0
+	for _, section := range SplitSections(data, size, points) {
+		r, _ := NewReaderSize(section, 4096)
+		for {
+			skipped, err := r.AlignSync(marker)
+			if err == io.EOF {
+				break
+			}
+			require.NoError(t, err)
+			assert.Zero(t, skipped, "splits must start at a marker")
+			r.ReadBytes(1000)
+			require.NoError(t, r.Err())
+			frames++
+		}
+	}
+	assert.Equal(t, 64, frames)
+}
+
+func TestSplitPointsBlocks(t *testing.T) {
+	points, err := SplitPoints(nil, 10000, 3, BlockBoundary(4096))
+	require.NoError(t, err)
+	assert.Equal(t, []int64{0, 4096, 8192}, points)
+}
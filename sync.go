@@ -0,0 +1,41 @@
+package codec
+
+import "crypto/rand"
+
+// SYNC_SIZE is the length of markers created by NewSyncMarker.
+const SYNC_SIZE = 16
+
+// SyncMarker is a byte pattern emitted before frames so that a reader can align
+// to a frame boundary from an arbitrary position, either to recover from
+// corruption or to start processing in the middle of a large file
+// (in the spirit of Avro container sync markers).
+//
+// Markers should be long and random enough that they are unlikely to appear
+// inside frame payloads.
+type SyncMarker []byte
+
+// NewSyncMarker returns a random SYNC_SIZE-byte marker.
+func NewSyncMarker() SyncMarker {
+	m := make(SyncMarker, SYNC_SIZE)
+	_, _ = rand.Read(m)
+	return m
+}
+
+// WriteSync emits the sync marker m.
+func (w *Writer) WriteSync(m SyncMarker) {
+	w.WriteBytes(m)
+}
+
+// AlignSync consumes bytes up to and including the next occurrence of m,
+// leaving the reader positioned at the start of the following frame.
+// It returns the number of bytes skipped before the marker. Unlike Resync, it
+// does not report the skipped range to the callback set by WithResync.
+func (r *Reader) AlignSync(m SyncMarker) (int64, error) {
+	if len(m) == 0 {
+		return 0, ErrNoSyncMarker
+	}
+	if r.err != nil {
+		return 0, r.err
+	}
+	return r.skipPast(m, nil)
+}
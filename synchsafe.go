@@ -0,0 +1,55 @@
+package codec
+
+import "fmt"
+
+// MAX_SYNCHSAFE32 is the largest value of a 4-byte synchsafe integer.
+const MAX_SYNCHSAFE32 = 1<<28 - 1
+
+// Synchsafe integers are big-endian with 7 bits per byte and the top bit of
+// every byte clear, so they never contain an MPEG audio sync pattern. ID3v2
+// stores its tag and frame sizes this way.
+
+// PutSynchsafe32 encodes v, at most MAX_SYNCHSAFE32, into b[:4].
+func PutSynchsafe32(b []byte, v uint32) {
+	_ = b[3]
+	b[0], b[1], b[2], b[3] = byte(v>>21)&0x7F, byte(v>>14)&0x7F, byte(v>>7)&0x7F, byte(v)&0x7F
+}
+
+// Synchsafe32 decodes b[:4], failing with ErrInvalidSynchsafe if a byte has
+// its top bit set.
+func Synchsafe32(b []byte) (uint32, error) {
+	_ = b[3]
+	if (b[0]|b[1]|b[2]|b[3])&0x80 != 0 {
+		return 0, fmt.Errorf("%w: % x", ErrInvalidSynchsafe, b[:4])
+	}
+	return uint32(b[0])<<21 | uint32(b[1])<<14 | uint32(b[2])<<7 | uint32(b[3]), nil
+}
+
+// ReadSynchsafe32 reads a 4-byte synchsafe integer.
+func (r *Reader) ReadSynchsafe32(dest *uint32) {
+	buf := r.readFull(4)
+	if r.err != nil {
+		return
+	}
+	v, err := Synchsafe32(buf)
+	if err != nil {
+		r.setError(err)
+		return
+	}
+	*dest = v
+	if r.trace != nil {
+		r.traced("synchsafe32", 4, v)
+	}
+}
+
+// WriteSynchsafe32 writes v as a 4-byte synchsafe integer, failing with
+// ErrLengthOverflow if it exceeds MAX_SYNCHSAFE32.
+func (w *Writer) WriteSynchsafe32(v uint32) {
+	if v > MAX_SYNCHSAFE32 {
+		w.setError(fmt.Errorf("%w: %d exceeds the synchsafe range", ErrLengthOverflow, v))
+		return
+	}
+	var buf [4]byte
+	PutSynchsafe32(buf[:], v)
+	w.WriteBytes(buf[:])
+}
@@ -0,0 +1,102 @@
+package codec
+
+import (
+	"fmt"
+	"strings"
+)
+
+// WriteASCII7 writes s as packed 7-bit ASCII, one character per 7 bits.
+// With LSBFirst this is the septet packing of SMS and GSM 03.38 user data;
+// with MSBFirst the big-endian packing found in telemetry formats. Pending
+// bits are not aligned, so callers decide how the final byte is padded.
+func (b *BitWriter) WriteASCII7(s string) {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 {
+			b.w.setError(fmt.Errorf("%w: byte %#02x at %d is not 7-bit ASCII", ErrInvalidText, s[i], i))
+			return
+		}
+		b.WriteBits(uint64(s[i]), 7)
+	}
+}
+
+// ReadASCII7 reads n characters of packed 7-bit ASCII.
+func (b *BitReader) ReadASCII7(dest *string, n int) {
+	buf := make([]byte, n)
+	for i := range buf {
+		buf[i] = byte(b.ReadBits(7))
+	}
+	if b.r.err == nil {
+		*dest = string(buf)
+	}
+}
+
+// radix50 is the DEC RADIX-50 alphabet as used on the PDP-11; code 29 is
+// unassigned and conventionally rendered as '%'.
+const radix50 = " ABCDEFGHIJKLMNOPQRSTUVWXYZ$.%0123456789"
+
+// EncodeRadix50 packs s into DEC RADIX-50 words of three characters each,
+// padding the last word with spaces. Only the characters of the RADIX-50
+// alphabet (space, A-Z, $, ., % and 0-9) are accepted.
+func EncodeRadix50(s string) ([]uint16, error) {
+	words := make([]uint16, 0, (len(s)+2)/3)
+	for i := 0; i < len(s); i += 3 {
+		var w uint16
+		for j := i; j < i+3; j++ {
+			var c int
+			if j < len(s) {
+				if c = strings.IndexByte(radix50, s[j]); c < 0 {
+					return nil, fmt.Errorf("%w: %q at %d is not RADIX-50", ErrInvalidText, s[j], j)
+				}
+			}
+			w = w*40 + uint16(c)
+		}
+		words = append(words, w)
+	}
+	return words, nil
+}
+
+// DecodeRadix50 unpacks DEC RADIX-50 words, dropping trailing spaces.
+// Words above the largest valid value 0xF9FF fail with ErrInvalidText.
+func DecodeRadix50(words []uint16) (string, error) {
+	buf := make([]byte, 0, 3*len(words))
+	for _, w := range words {
+		if w > 40*40*40-1 {
+			return "", fmt.Errorf("%w: RADIX-50 word %#04x", ErrInvalidText, w)
+		}
+		buf = append(buf, radix50[w/1600], radix50[w/40%40], radix50[w%40])
+	}
+	return strings.TrimRight(string(buf), " "), nil
+}
+
+// WriteRadix50 writes s as DEC RADIX-50 words in the Writer's byte order.
+func (w *Writer) WriteRadix50(s string) {
+	if w.err != nil {
+		return
+	}
+	words, err := EncodeRadix50(s)
+	if err != nil {
+		w.setError(err)
+		return
+	}
+	for _, word := range words {
+		w.WriteUint16(word)
+	}
+}
+
+// ReadRadix50 reads n DEC RADIX-50 words, three characters each, in the
+// Reader's byte order. Trailing spaces are dropped.
+func (r *Reader) ReadRadix50(dest *string, n int) {
+	words := make([]uint16, n)
+	for i := range words {
+		r.ReadUint16(&words[i])
+	}
+	if r.err != nil {
+		return
+	}
+	s, err := DecodeRadix50(words)
+	if err != nil {
+		r.setError(err)
+		return
+	}
+	*dest = s
+}
@@ -0,0 +1,59 @@
+//go:build test
+
+package codec
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestASCII7(t *testing.T) {
+	// Septet packing of GSM 03.38 user data.
+	var buf bytes.Buffer
+	w, _ := NewWriter(&buf)
+	bw := NewBitWriter(w, LSBFirst)
+	bw.WriteASCII7("hellohello")
+	bw.AlignByte()
+	require.NoError(t, w.Flush())
+	assert.Equal(t, "e8329bfd4697d9ec37", hex.EncodeToString(buf.Bytes()))
+
+	r, _ := NewReader(&buf)
+	var s string
+	NewBitReader(r, LSBFirst).ReadASCII7(&s, 10)
+	require.NoError(t, r.Err())
+	assert.Equal(t, "hellohello", s)
+
+	w, _ = NewWriter(&bytes.Buffer{})
+	NewBitWriter(w, MSBFirst).WriteASCII7("café")
+	assert.ErrorIs(t, w.Err(), ErrInvalidText)
+}
+
+func TestRadix50(t *testing.T) {
+	words, err := EncodeRadix50("ABC1$")
+	require.NoError(t, err)
+	assert.Equal(t, []uint16{1*1600 + 2*40 + 3, 31*1600 + 27*40}, words)
+
+	s, err := DecodeRadix50(words)
+	require.NoError(t, err)
+	assert.Equal(t, "ABC1$", s)
+
+	_, err = EncodeRadix50("abc")
+	assert.ErrorIs(t, err, ErrInvalidText)
+	_, err = DecodeRadix50([]uint16{0xFA00})
+	assert.ErrorIs(t, err, ErrInvalidText)
+
+	var buf bytes.Buffer
+	w, _ := NewWriter(&buf)
+	w.WithByteOrder(LE).WriteRadix50("SWAP.SYS")
+	require.NoError(t, w.Flush())
+	assert.Equal(t, 6, buf.Len())
+
+	r, _ := NewReader(&buf)
+	r.WithByteOrder(LE).ReadRadix50(&s, 3)
+	require.NoError(t, r.Err())
+	assert.Equal(t, "SWAP.SYS", s)
+}
@@ -0,0 +1,255 @@
+package texture
+
+import (
+	"encoding/binary"
+	"fmt"
+	"iter"
+
+	"github.com/oy3o/codec"
+)
+
+// DDS header flags, pixel format flags and capabilities.
+const (
+	DDSDCaps        = 0x1
+	DDSDHeight      = 0x2
+	DDSDWidth       = 0x4
+	DDSDPitch       = 0x8
+	DDSDPixelFormat = 0x1000
+	DDSDMipMapCount = 0x20000
+	DDSDLinearSize  = 0x80000
+	DDSDDepth       = 0x800000
+
+	DDPFAlphaPixels = 0x1
+	DDPFFourCC      = 0x4
+	DDPFRGB         = 0x40
+	DDPFLuminance   = 0x20000
+
+	DDSCapsComplex = 0x8
+	DDSCapsTexture = 0x1000
+	DDSCapsMipMap  = 0x400000
+
+	DDSCaps2Cubemap = 0x200
+	DDSCaps2Volume  = 0x200000
+)
+
+// DDS_HEADER_SIZE is the size of the magic and DDS_HEADER; a DX10 extension
+// adds DDS_DX10_SIZE bytes.
+const (
+	DDS_HEADER_SIZE = 128
+	DDS_DX10_SIZE   = 20
+)
+
+var (
+	ddsMagic  = codec.FourCC{'D', 'D', 'S', ' '}
+	ddsDX10   = codec.FourCC{'D', 'X', '1', '0'}
+	ddsFourCC = map[codec.FourCC]BlockFormat{
+		{'D', 'X', 'T', '1'}: {4, 4, 8},
+		{'D', 'X', 'T', '2'}: {4, 4, 16},
+		{'D', 'X', 'T', '3'}: {4, 4, 16},
+		{'D', 'X', 'T', '4'}: {4, 4, 16},
+		{'D', 'X', 'T', '5'}: {4, 4, 16},
+		{'A', 'T', 'I', '1'}: {4, 4, 8},
+		{'B', 'C', '4', 'U'}: {4, 4, 8},
+		{'B', 'C', '4', 'S'}: {4, 4, 8},
+		{'A', 'T', 'I', '2'}: {4, 4, 16},
+		{'B', 'C', '5', 'U'}: {4, 4, 16},
+		{'B', 'C', '5', 'S'}: {4, 4, 16},
+	}
+)
+
+// DDSPixelFormat is the DDS_PIXELFORMAT structure.
+type DDSPixelFormat struct {
+	Flags       uint32
+	FourCC      codec.FourCC
+	RGBBitCount uint32
+	RBitMask    uint32
+	GBitMask    uint32
+	BBitMask    uint32
+	ABitMask    uint32
+}
+
+// DDSHeaderDX10 is the DDS_HEADER_DXT10 extension present when the pixel
+// format's FourCC is "DX10".
+type DDSHeaderDX10 struct {
+	DXGIFormat        uint32
+	ResourceDimension uint32
+	MiscFlag          uint32
+	ArraySize         uint32
+	MiscFlags2        uint32
+}
+
+// DDSHeader is a DirectDraw Surface header.
+type DDSHeader struct {
+	Flags             uint32
+	Height            uint32
+	Width             uint32
+	PitchOrLinearSize uint32
+	Depth             uint32
+	MipMapCount       uint32
+	PixelFormat       DDSPixelFormat
+	Caps              uint32
+	Caps2             uint32
+	Caps3             uint32
+	Caps4             uint32
+	DX10              *DDSHeaderDX10 // nil unless the FourCC is "DX10"
+}
+
+// Size returns the encoded size of the header including the magic.
+func (h *DDSHeader) Size() int {
+	if h.DX10 != nil {
+		return DDS_HEADER_SIZE + DDS_DX10_SIZE
+	}
+	return DDS_HEADER_SIZE
+}
+
+// ReadDDS reads the magic and header of a DDS file.
+func ReadDDS(r *codec.Reader) (*DDSHeader, error) {
+	buf, err := readHeader(r, DDS_HEADER_SIZE)
+	if err != nil {
+		return nil, err
+	}
+	if codec.FourCC(buf[:4]) != ddsMagic {
+		return nil, fmt.Errorf("%w: DDS magic %q", codec.ErrMagicMismatch, buf[:4])
+	}
+	f := fields{buf[4:], binary.LittleEndian}
+	if size := f.u32(); size != 124 {
+		return nil, fmt.Errorf("%w: DDS header size %d", ErrInvalidHeader, size)
+	}
+	h := &DDSHeader{
+		Flags:             f.u32(),
+		Height:            f.u32(),
+		Width:             f.u32(),
+		PitchOrLinearSize: f.u32(),
+		Depth:             f.u32(),
+		MipMapCount:       f.u32(),
+	}
+	f.b = f.b[11*4:] // reserved
+	if size := f.u32(); size != 32 {
+		return nil, fmt.Errorf("%w: DDS pixel format size %d", ErrInvalidHeader, size)
+	}
+	h.PixelFormat.Flags = f.u32()
+	h.PixelFormat.FourCC = codec.FourCC(f.b[:4])
+	f.b = f.b[4:]
+	h.PixelFormat.RGBBitCount = f.u32()
+	h.PixelFormat.RBitMask = f.u32()
+	h.PixelFormat.GBitMask = f.u32()
+	h.PixelFormat.BBitMask = f.u32()
+	h.PixelFormat.ABitMask = f.u32()
+	h.Caps, h.Caps2, h.Caps3, h.Caps4 = f.u32(), f.u32(), f.u32(), f.u32()
+
+	if h.PixelFormat.Flags&DDPFFourCC != 0 && h.PixelFormat.FourCC == ddsDX10 {
+		if buf, err = readHeader(r, DDS_DX10_SIZE); err != nil {
+			return nil, err
+		}
+		f = fields{buf, binary.LittleEndian}
+		h.DX10 = &DDSHeaderDX10{f.u32(), f.u32(), f.u32(), f.u32(), f.u32()}
+	}
+	return h, nil
+}
+
+// WriteDDS writes the magic and header of a DDS file.
+func WriteDDS(w *codec.Writer, h *DDSHeader) {
+	buf := make([]byte, 0, h.Size())
+	buf = append(buf, ddsMagic[:]...)
+	le := binary.LittleEndian
+	buf = appendUint32s(buf, le, 124, h.Flags, h.Height, h.Width, h.PitchOrLinearSize, h.Depth, h.MipMapCount)
+	buf = append(buf, make([]byte, 11*4)...)
+	pf := &h.PixelFormat
+	buf = appendUint32s(buf, le, 32, pf.Flags)
+	buf = append(buf, pf.FourCC[:]...)
+	buf = appendUint32s(buf, le, pf.RGBBitCount, pf.RBitMask, pf.GBitMask, pf.BBitMask, pf.ABitMask)
+	buf = appendUint32s(buf, le, h.Caps, h.Caps2, h.Caps3, h.Caps4, 0)
+	if h.DX10 != nil {
+		x := h.DX10
+		buf = appendUint32s(buf, le, x.DXGIFormat, x.ResourceDimension, x.MiscFlag, x.ArraySize, x.MiscFlags2)
+	}
+	w.WriteBytes(buf)
+}
+
+// dxgiBlocks maps DXGI_FORMAT values to their block layout.
+var dxgiBlocks = map[uint32]BlockFormat{
+	2: {1, 1, 16}, 10: {1, 1, 8}, 11: {1, 1, 8}, 24: {1, 1, 4}, 28: {1, 1, 4},
+	29: {1, 1, 4}, 41: {1, 1, 4}, 49: {1, 1, 2}, 54: {1, 1, 2}, 56: {1, 1, 2},
+	61: {1, 1, 1}, 87: {1, 1, 4}, 88: {1, 1, 4}, 91: {1, 1, 4},
+	70: {4, 4, 8}, 71: {4, 4, 8}, 72: {4, 4, 8}, // BC1
+	73: {4, 4, 16}, 74: {4, 4, 16}, 75: {4, 4, 16}, // BC2
+	76: {4, 4, 16}, 77: {4, 4, 16}, 78: {4, 4, 16}, // BC3
+	79: {4, 4, 8}, 80: {4, 4, 8}, 81: {4, 4, 8}, // BC4
+	82: {4, 4, 16}, 83: {4, 4, 16}, 84: {4, 4, 16}, // BC5
+	94: {4, 4, 16}, 95: {4, 4, 16}, 96: {4, 4, 16}, // BC6H
+	97: {4, 4, 16}, 98: {4, 4, 16}, 99: {4, 4, 16}, // BC7
+}
+
+// Format returns the block layout of the header's pixel format.
+func (h *DDSHeader) Format() (BlockFormat, error) {
+	pf := &h.PixelFormat
+	switch {
+	case h.DX10 != nil:
+		if f, ok := dxgiBlocks[h.DX10.DXGIFormat]; ok {
+			return f, nil
+		}
+		return BlockFormat{}, fmt.Errorf("%w: DXGI format %d", ErrUnsupportedFormat, h.DX10.DXGIFormat)
+	case pf.Flags&DDPFFourCC != 0:
+		if f, ok := ddsFourCC[pf.FourCC]; ok {
+			return f, nil
+		}
+		return BlockFormat{}, fmt.Errorf("%w: FourCC %q", ErrUnsupportedFormat, pf.FourCC)
+	case pf.RGBBitCount%8 == 0 && pf.RGBBitCount > 0:
+		return BlockFormat{1, 1, int(pf.RGBBitCount / 8)}, nil
+	}
+	return BlockFormat{}, fmt.Errorf("%w: %d bits per pixel", ErrUnsupportedFormat, pf.RGBBitCount)
+}
+
+// Levels returns an iterator over the images following the header, in file
+// order: every mip level of the first array layer or cube face, then of the
+// next. Each Body is valid until the next iteration; unread data is skipped.
+func (h *DDSHeader) Levels(r *codec.Reader) iter.Seq2[Level, error] {
+	return func(yield func(Level, error) bool) {
+		format, err := h.Format()
+		if err != nil {
+			yield(Level{}, err)
+			return
+		}
+		mips, err := checkLevels(h.MipMapCount)
+		if err != nil {
+			yield(Level{}, err)
+			return
+		}
+		layers, faces := 1, 1
+		if h.DX10 != nil {
+			layers = max(1, int(h.DX10.ArraySize))
+		}
+		if h.Caps2&DDSCaps2Cubemap != 0 {
+			faces = 6
+		}
+		depth := 1
+		if h.Caps2&DDSCaps2Volume != 0 {
+			depth = int(h.Depth)
+		}
+
+		ls := levels{r: r}
+		for layer := range layers {
+			for face := range faces {
+				for mip := range mips {
+					lv := Level{
+						Level: mip, Layer: layer, Face: face,
+						Width:  LevelDim(int(h.Width), mip),
+						Height: LevelDim(int(h.Height), mip),
+						Depth:  LevelDim(depth, mip),
+					}
+					lv.Size = format.Size(lv.Width, lv.Height, lv.Depth)
+					if lv.Body, err = ls.next(lv.Size); err != nil {
+						yield(Level{}, err)
+						return
+					}
+					if !yield(lv, nil) {
+						return
+					}
+				}
+			}
+		}
+		if err := ls.skip(); err != nil {
+			yield(Level{}, err)
+		}
+	}
+}
@@ -0,0 +1,286 @@
+package texture
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"iter"
+
+	"github.com/oy3o/codec"
+)
+
+var (
+	ktxIdentifier  = [12]byte{0xAB, 'K', 'T', 'X', ' ', '1', '1', 0xBB, '\r', '\n', 0x1A, '\n'}
+	ktx2Identifier = [12]byte{0xAB, 'K', 'T', 'X', ' ', '2', '0', 0xBB, '\r', '\n', 0x1A, '\n'}
+)
+
+const (
+	// KTX_HEADER_SIZE is the size of a KTX header before its key/value data.
+	KTX_HEADER_SIZE = 64
+	// KTX2_HEADER_SIZE is the size of a KTX2 header and index before the level index.
+	KTX2_HEADER_SIZE = 80
+	// MAX_KEY_VALUE_SIZE bounds the key/value data read with a header.
+	MAX_KEY_VALUE_SIZE = 1 << 20
+
+	ktxEndianness = 0x04030201
+)
+
+// KTXHeader is the header of a KTX 1.1 file. Order is the byte order the
+// file was written in, detected from its endianness field.
+type KTXHeader struct {
+	Order                 binary.ByteOrder
+	GLType                uint32
+	GLTypeSize            uint32
+	GLFormat              uint32
+	GLInternalFormat      uint32
+	GLBaseInternalFormat  uint32
+	PixelWidth            uint32
+	PixelHeight           uint32
+	PixelDepth            uint32
+	NumberOfArrayElements uint32
+	NumberOfFaces         uint32
+	NumberOfMipmapLevels  uint32
+	KeyValueData          []byte // raw key/value data, see KeyValues
+}
+
+// ReadKTX reads the header and key/value data of a KTX 1.1 file.
+func ReadKTX(r *codec.Reader) (*KTXHeader, error) {
+	buf, err := readHeader(r, KTX_HEADER_SIZE)
+	if err != nil {
+		return nil, err
+	}
+	if [12]byte(buf[:12]) != ktxIdentifier {
+		return nil, fmt.Errorf("%w: KTX identifier %x", codec.ErrMagicMismatch, buf[:12])
+	}
+	h := &KTXHeader{Order: binary.LittleEndian}
+	switch e := binary.LittleEndian.Uint32(buf[12:]); e {
+	case ktxEndianness:
+	case 0x01020304:
+		h.Order = binary.BigEndian
+	default:
+		return nil, fmt.Errorf("%w: KTX endianness %#08x", ErrInvalidHeader, e)
+	}
+	f := fields{buf[16:], h.Order}
+	h.GLType, h.GLTypeSize, h.GLFormat = f.u32(), f.u32(), f.u32()
+	h.GLInternalFormat, h.GLBaseInternalFormat = f.u32(), f.u32()
+	h.PixelWidth, h.PixelHeight, h.PixelDepth = f.u32(), f.u32(), f.u32()
+	h.NumberOfArrayElements, h.NumberOfFaces, h.NumberOfMipmapLevels = f.u32(), f.u32(), f.u32()
+	kvd := f.u32()
+	if kvd > MAX_KEY_VALUE_SIZE {
+		return nil, fmt.Errorf("%w: %d bytes of key/value data", ErrInvalidHeader, kvd)
+	}
+	if h.KeyValueData, err = readHeader(r, int(kvd)); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// WriteKTX writes the header and key/value data of a KTX 1.1 file. A nil
+// Order writes little-endian.
+func WriteKTX(w *codec.Writer, h *KTXHeader) {
+	order := h.Order
+	if order == nil {
+		order = binary.LittleEndian
+	}
+	buf := make([]byte, 0, KTX_HEADER_SIZE+len(h.KeyValueData))
+	buf = append(buf, ktxIdentifier[:]...)
+	buf = appendUint32s(buf, order, ktxEndianness,
+		h.GLType, h.GLTypeSize, h.GLFormat, h.GLInternalFormat, h.GLBaseInternalFormat,
+		h.PixelWidth, h.PixelHeight, h.PixelDepth,
+		h.NumberOfArrayElements, h.NumberOfFaces, h.NumberOfMipmapLevels, uint32(len(h.KeyValueData)))
+	buf = append(buf, h.KeyValueData...)
+	w.WriteBytes(buf)
+}
+
+// KeyValues parses the key/value data into a map from key to value.
+func (h *KTXHeader) KeyValues() (map[string][]byte, error) {
+	return parseKeyValues(h.KeyValueData, h.Order)
+}
+
+// parseKeyValues parses KTX key/value data: entries of a uint32 size, a
+// NUL-terminated key and a value, each padded to 4 bytes.
+func parseKeyValues(b []byte, order binary.ByteOrder) (map[string][]byte, error) {
+	kv := make(map[string][]byte)
+	for len(b) > 0 {
+		if len(b) < 4 {
+			return nil, fmt.Errorf("%w: key/value entry", codec.ErrTruncatedData)
+		}
+		n := int(order.Uint32(b))
+		b = b[4:]
+		if n > len(b) {
+			return nil, fmt.Errorf("%w: key/value entry of %d bytes", codec.ErrTruncatedData, n)
+		}
+		entry := b[:n]
+		key, value, ok := bytes.Cut(entry, []byte{0})
+		if !ok {
+			return nil, fmt.Errorf("%w: key without terminator", ErrInvalidHeader)
+		}
+		kv[string(key)] = value
+		b = b[min(len(b), codec.Roundup(n, 4)):]
+	}
+	return kv, nil
+}
+
+// Levels returns an iterator over the images following the header. Each mip
+// level is preceded by its imageSize; the faces of a non-array cubemap are
+// yielded separately, any other level as a single image holding all array
+// layers and faces. Each Body is valid until the next iteration; unread data
+// is skipped.
+func (h *KTXHeader) Levels(r *codec.Reader) iter.Seq2[Level, error] {
+	return func(yield func(Level, error) bool) {
+		mips, err := checkLevels(h.NumberOfMipmapLevels)
+		if err != nil {
+			yield(Level{}, err)
+			return
+		}
+		faces := 1
+		if h.NumberOfFaces == 6 && h.NumberOfArrayElements == 0 {
+			faces = 6
+		}
+		ls := levels{r: r}
+		var size [4]byte
+		for mip := range mips {
+			if err := ls.skip(); err != nil {
+				yield(Level{}, err)
+				return
+			}
+			if _, err := io.ReadFull(r, size[:]); err != nil {
+				yield(Level{}, err)
+				return
+			}
+			n := int64(h.Order.Uint32(size[:]))
+			for face := range faces {
+				lv := Level{
+					Level: mip, Face: face,
+					Width:  LevelDim(int(h.PixelWidth), mip),
+					Height: LevelDim(int(h.PixelHeight), mip),
+					Depth:  LevelDim(int(h.PixelDepth), mip),
+					Size:   n,
+				}
+				if lv.Body, err = ls.next(n); err != nil {
+					yield(Level{}, err)
+					return
+				}
+				if !yield(lv, nil) {
+					return
+				}
+				// Cube and mip padding align the next image to 4 bytes.
+				if err := ls.skip(); err != nil {
+					yield(Level{}, err)
+					return
+				}
+				if _, err := codec.Discard(r, codec.Roundup(n, 4)-n); err != nil {
+					yield(Level{}, err)
+					return
+				}
+			}
+		}
+	}
+}
+
+// KTX2Level is an entry of the KTX2 level index.
+type KTX2Level struct {
+	Offset             uint64
+	Length             uint64
+	UncompressedLength uint64
+}
+
+// KTX2Header is the header, index and level index of a KTX2 file.
+type KTX2Header struct {
+	VkFormat               uint32
+	TypeSize               uint32
+	PixelWidth             uint32
+	PixelHeight            uint32
+	PixelDepth             uint32
+	LayerCount             uint32
+	FaceCount              uint32
+	LevelCount             uint32
+	SupercompressionScheme uint32
+	DFDOffset, DFDLength   uint32
+	KVDOffset, KVDLength   uint32
+	SGDOffset, SGDLength   uint64
+	Levels                 []KTX2Level // index 0 is the base level
+}
+
+// Size returns the encoded size of the header and level index.
+func (h *KTX2Header) Size() int {
+	return KTX2_HEADER_SIZE + 24*len(h.Levels)
+}
+
+// ReadKTX2 reads the header, index and level index of a KTX2 file.
+func ReadKTX2(r *codec.Reader) (*KTX2Header, error) {
+	buf, err := readHeader(r, KTX2_HEADER_SIZE)
+	if err != nil {
+		return nil, err
+	}
+	if [12]byte(buf[:12]) != ktx2Identifier {
+		return nil, fmt.Errorf("%w: KTX2 identifier %x", codec.ErrMagicMismatch, buf[:12])
+	}
+	f := fields{buf[12:], binary.LittleEndian}
+	h := &KTX2Header{}
+	h.VkFormat, h.TypeSize = f.u32(), f.u32()
+	h.PixelWidth, h.PixelHeight, h.PixelDepth = f.u32(), f.u32(), f.u32()
+	h.LayerCount, h.FaceCount, h.LevelCount = f.u32(), f.u32(), f.u32()
+	h.SupercompressionScheme = f.u32()
+	h.DFDOffset, h.DFDLength = f.u32(), f.u32()
+	h.KVDOffset, h.KVDLength = f.u32(), f.u32()
+	h.SGDOffset, h.SGDLength = f.u64(), f.u64()
+	n, err := checkLevels(h.LevelCount)
+	if err != nil {
+		return nil, err
+	}
+	if buf, err = readHeader(r, 24*n); err != nil {
+		return nil, err
+	}
+	f = fields{buf, binary.LittleEndian}
+	h.Levels = make([]KTX2Level, n)
+	for i := range h.Levels {
+		h.Levels[i] = KTX2Level{f.u64(), f.u64(), f.u64()}
+	}
+	return h, nil
+}
+
+// WriteKTX2 writes the header, index and level index of a KTX2 file. The
+// caller lays out the sections the offsets refer to.
+func WriteKTX2(w *codec.Writer, h *KTX2Header) {
+	le := binary.LittleEndian
+	buf := make([]byte, 0, h.Size())
+	buf = append(buf, ktx2Identifier[:]...)
+	buf = appendUint32s(buf, le, h.VkFormat, h.TypeSize, h.PixelWidth, h.PixelHeight, h.PixelDepth,
+		h.LayerCount, h.FaceCount, h.LevelCount, h.SupercompressionScheme,
+		h.DFDOffset, h.DFDLength, h.KVDOffset, h.KVDLength)
+	buf = le.AppendUint64(buf, h.SGDOffset)
+	buf = le.AppendUint64(buf, h.SGDLength)
+	for _, l := range h.Levels {
+		buf = le.AppendUint64(buf, l.Offset)
+		buf = le.AppendUint64(buf, l.Length)
+		buf = le.AppendUint64(buf, l.UncompressedLength)
+	}
+	w.WriteBytes(buf)
+}
+
+// LevelReader returns a reader bounded to the data of one mip level, which
+// holds all its layers and faces, possibly supercompressed.
+func (h *KTX2Header) LevelReader(ra io.ReaderAt, level int) (*io.SectionReader, error) {
+	if level < 0 || level >= len(h.Levels) {
+		return nil, fmt.Errorf("%w: level %d of %d", ErrInvalidHeader, level, len(h.Levels))
+	}
+	l := h.Levels[level]
+	if l.Offset > 1<<62 || l.Length > 1<<62 {
+		return nil, fmt.Errorf("%w: level %d at %d+%d", ErrInvalidHeader, level, l.Offset, l.Length)
+	}
+	return io.NewSectionReader(ra, int64(l.Offset), int64(l.Length)), nil
+}
+
+// KeyValues reads and parses the key/value data of the file.
+func (h *KTX2Header) KeyValues(ra io.ReaderAt) (map[string][]byte, error) {
+	if h.KVDLength > MAX_KEY_VALUE_SIZE {
+		return nil, fmt.Errorf("%w: %d bytes of key/value data", ErrInvalidHeader, h.KVDLength)
+	}
+	buf := make([]byte, h.KVDLength)
+	if _, err := ra.ReadAt(buf, int64(h.KVDOffset)); err != nil {
+		return nil, err
+	}
+	return parseKeyValues(buf, binary.LittleEndian)
+}
@@ -0,0 +1,162 @@
+// Package texture reads and writes the headers of the DDS, KTX and KTX2
+// texture containers on top of codec.Reader and codec.Writer, computes mip
+// level sizes and exposes each level's image data as a bounded reader, so
+// asset pipelines can inspect and repack textures without decoding pixels.
+//
+// A header cut short fails with io.ErrUnexpectedEOF, and one with out of
+// range fields, such as more than MAX_LEVELS mip levels, with
+// ErrInvalidHeader. Level bodies stream from the Reader, so moving on to the
+// next level skips whatever is left of the previous one.
+package texture
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/oy3o/codec"
+)
+
+var (
+	// ErrInvalidHeader indicates a header with inconsistent or out of range fields.
+	ErrInvalidHeader = errors.New("texture: invalid header")
+
+	// ErrUnsupportedFormat indicates a pixel format whose block size is unknown.
+	ErrUnsupportedFormat = errors.New("texture: unsupported pixel format")
+)
+
+// MAX_LEVELS bounds the number of mip levels, enough for a 2^31 texel edge.
+const MAX_LEVELS = 32
+
+// BlockFormat describes how a pixel format stores texels: blocks of Width by
+// Height texels of Bytes bytes each. Uncompressed formats use 1x1 blocks.
+type BlockFormat struct {
+	Width, Height int
+	Bytes         int
+}
+
+// LevelDim returns the extent of mip level of a dimension of size n.
+func LevelDim(n, level int) int {
+	return max(1, n>>level)
+}
+
+// Size returns the number of bytes of an image of the given dimensions.
+func (f BlockFormat) Size(width, height, depth int) int64 {
+	bw := (width + f.Width - 1) / f.Width
+	bh := (height + f.Height - 1) / f.Height
+	return int64(bw) * int64(bh) * int64(max(1, depth)) * int64(f.Bytes)
+}
+
+// LevelSizes returns the size of each of levels mip levels of an image.
+func (f BlockFormat) LevelSizes(width, height, depth, levels int) []int64 {
+	sizes := make([]int64, levels)
+	for i := range sizes {
+		sizes[i] = f.Size(LevelDim(width, i), LevelDim(height, i), LevelDim(depth, i))
+	}
+	return sizes
+}
+
+// Level is the image data of one mip level of one array layer or cube face.
+// Body streams exactly Size bytes.
+type Level struct {
+	Level, Layer, Face   int
+	Width, Height, Depth int
+	Size                 int64
+	Body                 io.Reader
+}
+
+// readHeader reads n bytes of a fixed-size header.
+func readHeader(r *codec.Reader, n int) ([]byte, error) {
+	buf := make([]byte, n)
+	r.ReadBytesTo(buf)
+	if err := r.Err(); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return buf, nil
+}
+
+// fields decodes consecutive integers of a header.
+type fields struct {
+	b     []byte
+	order binary.ByteOrder
+}
+
+func (f *fields) u32() uint32 {
+	v := f.order.Uint32(f.b)
+	f.b = f.b[4:]
+	return v
+}
+
+func (f *fields) u64() uint64 {
+	v := f.order.Uint64(f.b)
+	f.b = f.b[8:]
+	return v
+}
+
+// appendUint32s appends vs in order.
+func appendUint32s(b []byte, order binary.ByteOrder, vs ...uint32) []byte {
+	var buf [4]byte
+	for _, v := range vs {
+		order.PutUint32(buf[:], v)
+		b = append(b, buf[:]...)
+	}
+	return b
+}
+
+// body streams exactly n bytes of r, failing with io.ErrUnexpectedEOF if r
+// ends first.
+type body struct {
+	r io.Reader
+	n int64
+}
+
+func (b *body) Read(p []byte) (int, error) {
+	if b.n <= 0 {
+		return 0, io.EOF
+	}
+	if int64(len(p)) > b.n {
+		p = p[:b.n]
+	}
+	n, err := b.r.Read(p)
+	b.n -= int64(n)
+	if err == io.EOF && b.n > 0 {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+// levels hands out level bodies in file order, skipping whatever the caller
+// left unread of the previous one.
+type levels struct {
+	r    io.Reader
+	prev *body
+}
+
+func (l *levels) next(n int64) (io.Reader, error) {
+	if err := l.skip(); err != nil {
+		return nil, err
+	}
+	l.prev = &body{r: l.r, n: n}
+	return l.prev, nil
+}
+
+func (l *levels) skip() error {
+	if l.prev == nil {
+		return nil
+	}
+	_, err := io.Copy(io.Discard, l.prev)
+	l.prev = nil
+	return err
+}
+
+// checkLevels validates a mip level count, 0 meaning 1.
+func checkLevels(n uint32) (int, error) {
+	if n > MAX_LEVELS {
+		return 0, fmt.Errorf("%w: %d mip levels", ErrInvalidHeader, n)
+	}
+	return max(1, int(n)), nil
+}
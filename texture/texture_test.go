@@ -0,0 +1,153 @@
+//go:build test
+
+package texture
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"testing"
+
+	"github.com/oy3o/codec"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBlockFormat(t *testing.T) {
+	bc1 := BlockFormat{4, 4, 8}
+	assert.Equal(t, []int64{128, 32, 8, 8, 8}, bc1.LevelSizes(16, 16, 1, 5))
+	rgba := BlockFormat{1, 1, 4}
+	assert.Equal(t, []int64{4 * 5 * 3 * 2, 4 * 2 * 1 * 1, 4}, rgba.LevelSizes(5, 3, 2, 3))
+}
+
+func TestDDS(t *testing.T) {
+	h := &DDSHeader{
+		Flags:       DDSDCaps | DDSDHeight | DDSDWidth | DDSDPixelFormat | DDSDMipMapCount,
+		Width:       8,
+		Height:      4,
+		MipMapCount: 3,
+		PixelFormat: DDSPixelFormat{Flags: DDPFFourCC, FourCC: codec.FourCC{'D', 'X', '1', '0'}},
+		Caps:        DDSCapsTexture | DDSCapsMipMap | DDSCapsComplex,
+		DX10:        &DDSHeaderDX10{DXGIFormat: 71, ResourceDimension: 3, ArraySize: 2},
+	}
+	var buf bytes.Buffer
+	w, _ := codec.NewWriter(&buf)
+	WriteDDS(w, h)
+	require.NoError(t, w.Flush())
+	require.Equal(t, h.Size(), buf.Len())
+	// Two layers of BC1 levels 8x4, 4x2 and 2x1: 16, 8 and 8 bytes.
+	for layer := range 2 {
+		for _, n := range []int{16, 8, 8} {
+			buf.Write(bytes.Repeat([]byte{byte(layer)}, n))
+		}
+	}
+
+	r, _ := codec.NewReader(bytes.NewReader(buf.Bytes()))
+	got, err := ReadDDS(r)
+	require.NoError(t, err)
+	assert.Equal(t, h, got)
+
+	var sizes []int64
+	for lv, err := range got.Levels(r) {
+		require.NoError(t, err)
+		sizes = append(sizes, lv.Size)
+		if lv.Level == 1 {
+			data, err := io.ReadAll(lv.Body)
+			require.NoError(t, err)
+			assert.Equal(t, bytes.Repeat([]byte{byte(lv.Layer)}, 8), data)
+		}
+	}
+	assert.Equal(t, []int64{16, 8, 8, 16, 8, 8}, sizes)
+
+	r, _ = codec.NewReader(bytes.NewReader(buf.Bytes()[:buf.Len()-1]))
+	got, err = ReadDDS(r)
+	require.NoError(t, err)
+	var last error
+	for _, err := range got.Levels(r) {
+		last = err
+	}
+	assert.ErrorIs(t, last, io.ErrUnexpectedEOF)
+
+	r, _ = codec.NewReader(bytes.NewReader(make([]byte, DDS_HEADER_SIZE)))
+	_, err = ReadDDS(r)
+	assert.ErrorIs(t, err, codec.ErrMagicMismatch)
+}
+
+func TestKTX(t *testing.T) {
+	kv := binary.BigEndian.AppendUint32(nil, 12)
+	kv = append(kv, "KTXorient\x00r\x00"...)
+	h := &KTXHeader{
+		Order:                binary.BigEndian,
+		GLInternalFormat:     0x8058,
+		PixelWidth:           2,
+		PixelHeight:          2,
+		NumberOfFaces:        6,
+		NumberOfMipmapLevels: 2,
+		KeyValueData:         kv,
+	}
+	var buf bytes.Buffer
+	w, _ := codec.NewWriter(&buf)
+	WriteKTX(w, h)
+	for _, n := range []uint32{16, 4} {
+		w.WithByteOrder(binary.BigEndian).WriteUint32(n)
+		for face := range 6 {
+			w.WriteBytes(bytes.Repeat([]byte{byte(face)}, int(n)))
+		}
+	}
+	require.NoError(t, w.Flush())
+
+	r, _ := codec.NewReader(&buf)
+	got, err := ReadKTX(r)
+	require.NoError(t, err)
+	assert.Equal(t, h, got)
+	pairs, err := got.KeyValues()
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]byte{"KTXorient": []byte("r\x00")}, pairs)
+
+	count := 0
+	for lv, err := range got.Levels(r) {
+		require.NoError(t, err)
+		if lv.Level == 1 && lv.Face == 5 {
+			data, err := io.ReadAll(lv.Body)
+			require.NoError(t, err)
+			assert.Equal(t, []byte{5, 5, 5, 5}, data)
+			assert.Equal(t, 1, lv.Width)
+		}
+		count++
+	}
+	assert.Equal(t, 12, count)
+	_, err = r.ReadByte()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestKTX2(t *testing.T) {
+	h := &KTX2Header{
+		VkFormat:    37,
+		TypeSize:    1,
+		PixelWidth:  4,
+		PixelHeight: 4,
+		FaceCount:   1,
+		LevelCount:  2,
+		Levels:      []KTX2Level{{Offset: 140, Length: 64, UncompressedLength: 64}, {Offset: 128, Length: 12, UncompressedLength: 16}},
+	}
+	var buf bytes.Buffer
+	w, _ := codec.NewWriter(&buf)
+	WriteKTX2(w, h)
+	w.WriteZeros(int64(128 - h.Size()))
+	w.WriteBytes(bytes.Repeat([]byte{1}, 12))
+	w.WriteBytes(bytes.Repeat([]byte{0}, 64))
+	require.NoError(t, w.Flush())
+
+	r, _ := codec.NewReader(bytes.NewReader(buf.Bytes()))
+	got, err := ReadKTX2(r)
+	require.NoError(t, err)
+	assert.Equal(t, h, got)
+
+	level, err := got.LevelReader(bytes.NewReader(buf.Bytes()), 1)
+	require.NoError(t, err)
+	data, err := io.ReadAll(level)
+	require.NoError(t, err)
+	assert.Equal(t, bytes.Repeat([]byte{1}, 12), data)
+	_, err = got.LevelReader(bytes.NewReader(buf.Bytes()), 2)
+	assert.ErrorIs(t, err, ErrInvalidHeader)
+}
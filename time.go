@@ -0,0 +1,182 @@
+package codec
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// TimeFormat selects the on-disk representation used by ReadTime and WriteTime.
+type TimeFormat int
+
+const (
+	// TimeUnix32 stores whole seconds since the Unix epoch as a uint32.
+	TimeUnix32 TimeFormat = iota
+	// TimeUnix64 stores whole seconds since the Unix epoch as a uint64.
+	TimeUnix64
+	// TimeUnixMilli stores milliseconds since the Unix epoch as an int64.
+	TimeUnixMilli
+	// TimeUnixNano stores nanoseconds since the Unix epoch as an int64.
+	TimeUnixNano
+	// TimeFiletime stores 100-nanosecond intervals since 1601-01-01 as a uint64 (Windows FILETIME).
+	TimeFiletime
+	// TimeDOS stores an MS-DOS date/time pair with 2-second resolution as a uint32
+	// (date in the high 16 bits, time in the low 16 bits, as found in ZIP headers).
+	TimeDOS
+)
+
+// filetimeEpochDelta is the number of 100ns intervals between 1601-01-01 and 1970-01-01.
+const filetimeEpochDelta = 116444736000000000
+
+// Size returns the encoded size of the format in bytes.
+func (f TimeFormat) Size() int {
+	switch f {
+	case TimeUnix32, TimeDOS:
+		return 4
+	default:
+		return 8
+	}
+}
+
+// ReadTime reads a timestamp in the given format. Decoded values are in UTC.
+func (r *Reader) ReadTime(dest *time.Time, format TimeFormat) {
+	switch format {
+	case TimeUnix32:
+		var v uint32
+		r.ReadUint32(&v)
+		if r.err == nil {
+			*dest = time.Unix(int64(v), 0).UTC()
+		}
+	case TimeUnix64:
+		var v uint64
+		r.ReadUint64(&v)
+		if r.err == nil {
+			if v > math.MaxInt64 {
+				r.setError(fmt.Errorf("%w: unix64 %d", ErrTimeOutOfRange, v))
+				return
+			}
+			*dest = time.Unix(int64(v), 0).UTC()
+		}
+	case TimeUnixMilli:
+		var v int64
+		r.ReadInt64(&v)
+		if r.err == nil {
+			*dest = time.UnixMilli(v).UTC()
+		}
+	case TimeUnixNano:
+		var v int64
+		r.ReadInt64(&v)
+		if r.err == nil {
+			*dest = time.Unix(0, v).UTC()
+		}
+	case TimeFiletime:
+		var v uint64
+		r.ReadUint64(&v)
+		if r.err == nil {
+			// Larger values do not fit int64, so shifting them to the Unix
+			// epoch would overflow.
+			if v > math.MaxInt64 {
+				r.setError(fmt.Errorf("%w: filetime %d", ErrTimeOutOfRange, v))
+				return
+			}
+			ticks := int64(v) - filetimeEpochDelta
+			*dest = time.Unix(ticks/1e7, ticks%1e7*100).UTC()
+		}
+	case TimeDOS:
+		var v uint32
+		r.ReadUint32(&v)
+		if r.err == nil {
+			d, t := int(v>>16), int(v&0xFFFF)
+			month, day, hour, minute, second := time.Month(d>>5&0xF), d&0x1F, t>>11, t>>5&0x3F, t&0x1F*2
+			dt := time.Date(d>>9+1980, month, day, hour, minute, second, 0, time.UTC)
+			// time.Date normalizes out of range fields, such as month 0 or
+			// February 30, so any change means the stored date is invalid.
+			if dt.Month() != month || dt.Day() != day || dt.Hour() != hour || dt.Minute() != minute || dt.Second() != second {
+				r.setError(fmt.Errorf("%w: dos date/time %#08x", ErrTimeOutOfRange, v))
+				return
+			}
+			*dest = dt
+		}
+	default:
+		r.setError(fmt.Errorf("%w: %d", ErrInvalidTimeFormat, format))
+	}
+}
+
+// WriteTime writes v in the given format. Values that cannot be represented
+// by the format (out of range, or finer than its resolution) latch ErrTimeOutOfRange
+// instead of being silently truncated.
+func (w *Writer) WriteTime(v time.Time, format TimeFormat) {
+	if w.err != nil {
+		return
+	}
+	switch format {
+	case TimeUnix32:
+		sec := v.Unix()
+		if sec < 0 || sec > 1<<32-1 || v.Nanosecond() != 0 {
+			w.setError(fmt.Errorf("%w: %v as unix32", ErrTimeOutOfRange, v))
+			return
+		}
+		w.WriteUint32(uint32(sec))
+	case TimeUnix64:
+		sec := v.Unix()
+		if sec < 0 || v.Nanosecond() != 0 {
+			w.setError(fmt.Errorf("%w: %v as unix64", ErrTimeOutOfRange, v))
+			return
+		}
+		w.WriteUint64(uint64(sec))
+	case TimeUnixMilli:
+		if v.Nanosecond()%1e6 != 0 {
+			w.setError(fmt.Errorf("%w: %v as unix milliseconds", ErrTimeOutOfRange, v))
+			return
+		}
+		w.WriteInt64(v.UnixMilli())
+	case TimeUnixNano:
+		// UnixNano is undefined outside the int64 range of nanoseconds (years 1678-2262).
+		if v.Before(time.Unix(0, -1<<63)) || v.After(time.Unix(0, 1<<63-1)) {
+			w.setError(fmt.Errorf("%w: %v as unix nanoseconds", ErrTimeOutOfRange, v))
+			return
+		}
+		w.WriteInt64(v.UnixNano())
+	case TimeFiletime:
+		sec := v.Unix()
+		if v.Nanosecond()%100 != 0 || sec < -filetimeEpochDelta/10000000 || sec > (1<<63-1-filetimeEpochDelta)/10000000 {
+			w.setError(fmt.Errorf("%w: %v as filetime", ErrTimeOutOfRange, v))
+			return
+		}
+		w.WriteUint64(uint64(sec*1e7 + int64(v.Nanosecond()/100) + filetimeEpochDelta))
+	case TimeDOS:
+		v = v.UTC()
+		if v.Year() < 1980 || v.Year() > 2107 || v.Second()%2 != 0 || v.Nanosecond() != 0 {
+			w.setError(fmt.Errorf("%w: %v as dos date/time", ErrTimeOutOfRange, v))
+			return
+		}
+		d := uint32(v.Year()-1980)<<9 | uint32(v.Month())<<5 | uint32(v.Day())
+		t := uint32(v.Hour())<<11 | uint32(v.Minute())<<5 | uint32(v.Second()/2)
+		w.WriteUint32(d<<16 | t)
+	default:
+		w.setError(fmt.Errorf("%w: %d", ErrInvalidTimeFormat, format))
+	}
+}
+
+// ReadDuration reads a signed 64-bit count of unit and stores it as a time.Duration.
+// For example, a field holding milliseconds is read with unit time.Millisecond.
+func (r *Reader) ReadDuration(dest *time.Duration, unit time.Duration) {
+	var v int64
+	r.ReadInt64(&v)
+	if r.err == nil {
+		*dest = time.Duration(v) * unit
+	}
+}
+
+// WriteDuration writes d as a signed 64-bit count of unit. Durations that are not
+// a whole multiple of unit latch ErrTimeOutOfRange.
+func (w *Writer) WriteDuration(d time.Duration, unit time.Duration) {
+	if w.err != nil {
+		return
+	}
+	if unit <= 0 || d%unit != 0 {
+		w.setError(fmt.Errorf("%w: %v in units of %v", ErrTimeOutOfRange, d, unit))
+		return
+	}
+	w.WriteInt64(int64(d / unit))
+}
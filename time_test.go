@@ -0,0 +1,84 @@
+//go:build test
+
+package codec
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTimeRoundTrip(t *testing.T) {
+	cases := []struct {
+		format TimeFormat
+		value  time.Time
+	}{
+		{TimeUnix32, time.Date(2024, 2, 29, 12, 30, 15, 0, time.UTC)},
+		{TimeUnix64, time.Date(2200, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{TimeUnixMilli, time.Date(1969, 7, 20, 20, 17, 40, 123e6, time.UTC)},
+		{TimeUnixNano, time.Date(2024, 2, 29, 12, 30, 15, 123456789, time.UTC)},
+		{TimeFiletime, time.Date(1601, 1, 1, 0, 0, 0, 100, time.UTC)},
+		{TimeFiletime, time.Date(2024, 2, 29, 12, 30, 15, 1234500, time.UTC)},
+		{TimeDOS, time.Date(2024, 2, 29, 12, 30, 14, 0, time.UTC)},
+	}
+	for _, c := range cases {
+		var buf bytes.Buffer
+		w, _ := NewWriter(&buf)
+		w.WriteTime(c.value, c.format)
+		n, err := w.Result()
+		require.NoError(t, err)
+		assert.EqualValues(t, c.format.Size(), n)
+
+		var got time.Time
+		r, _ := NewReader(&buf)
+		r.ReadTime(&got, c.format)
+		require.NoError(t, r.Err())
+		assert.True(t, c.value.Equal(got), "format %d: want %v, got %v", c.format, c.value, got)
+	}
+}
+
+func TestTimeOutOfRange(t *testing.T) {
+	w, _ := NewWriter(&bytes.Buffer{})
+	w.WriteTime(time.Date(2024, 1, 1, 0, 0, 1, 0, time.UTC), TimeDOS) // odd seconds
+	assert.ErrorIs(t, w.Err(), ErrTimeOutOfRange)
+
+	w, _ = NewWriter(&bytes.Buffer{})
+	w.WriteDuration(1500*time.Microsecond, time.Millisecond)
+	assert.ErrorIs(t, w.Err(), ErrTimeOutOfRange)
+
+	// 64-bit values beyond int64 are rejected on read.
+	for _, format := range []TimeFormat{TimeUnix64, TimeFiletime} {
+		got := time.Unix(1, 0)
+		r, _ := NewReader(bytes.NewReader([]byte{0x80, 0, 0, 0, 0, 0, 0, 0}))
+		r.ReadTime(&got, format)
+		assert.ErrorIs(t, r.Err(), ErrTimeOutOfRange, "format %d", format)
+		assert.Equal(t, time.Unix(1, 0), got)
+	}
+}
+
+func TestTimeDOSInvalid(t *testing.T) {
+	date := func(year, month, day int) uint32 { return uint32(year-1980)<<25 | uint32(month)<<21 | uint32(day)<<16 }
+	for _, v := range []uint32{
+		date(2024, 0, 1),          // month 0
+		date(2024, 13, 1),         // month 13
+		date(2024, 1, 0),          // day 0
+		date(2023, 2, 29),         // not a leap year
+		date(2024, 1, 1) | 24<<11, // hour 24
+		date(2024, 1, 1) | 60<<5,  // minute 60
+		date(2024, 1, 1) | 30,     // second 60
+	} {
+		var buf bytes.Buffer
+		w, _ := NewWriter(&buf)
+		w.WriteUint32(v)
+		require.NoError(t, w.Flush())
+
+		got := time.Unix(1, 0)
+		r, _ := NewReader(&buf)
+		r.ReadTime(&got, TimeDOS)
+		assert.ErrorIs(t, r.Err(), ErrTimeOutOfRange, "%#08x", v)
+		assert.Equal(t, time.Unix(1, 0), got, "destination must be left unchanged")
+	}
+}
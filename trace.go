@@ -0,0 +1,25 @@
+package codec
+
+// TraceFunc receives a primitive read of a Reader: the operation, such as
+// "uint32" or "bytes", the offset the value starts at, its size in bytes and
+// the decoded value. Logging every call gives a trace that can be diffed
+// against the trace of a known-good input to find where parsing diverged.
+type TraceFunc func(op string, off int64, n int, v any)
+
+// WithTrace makes the Reader report its successful primitive reads (bools,
+// integers, byte slices and strings) to fn, and returns the Reader for
+// chaining. A nil fn turns tracing off.
+//
+//	r.WithTrace(func(op string, off int64, n int, v any) {
+//		log.Printf("%#06x %-6s %d %v", off, op, n, v)
+//	})
+func (r *Reader) WithTrace(fn TraceFunc) *Reader {
+	r.trace = fn
+	return r
+}
+
+// traced reports a read of n bytes ending at the current offset. Callers
+// check r.trace first so untraced reads do not box v.
+func (r *Reader) traced(op string, n int, v any) {
+	r.trace(op, r.count-int64(n), n, v)
+}
@@ -0,0 +1,118 @@
+package codec
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/bits"
+)
+
+// RecordLayout describes a fixed-size record as the byte widths of its scalar
+// fields in wire order. Fields of width 1 are byte order neutral.
+type RecordLayout []int
+
+// Size returns the size of a record in bytes.
+func (l RecordLayout) Size() int {
+	n := 0
+	for _, w := range l {
+		n += w
+	}
+	return n
+}
+
+// homogeneous returns the common field width if all fields share it, or 0.
+func (l RecordLayout) homogeneous() int {
+	if len(l) == 0 {
+		return 0
+	}
+	for _, w := range l[1:] {
+		if w != l[0] {
+			return 0
+		}
+	}
+	return l[0]
+}
+
+// swapRecords reverses the byte order of every field of the records in buf.
+func (l RecordLayout) swapRecords(buf []byte) {
+	// Bulk path: arrays of a single scalar type swap with a tight loop.
+	switch l.homogeneous() {
+	case 1:
+		return
+	case 2:
+		for i := 0; i+2 <= len(buf); i += 2 {
+			binary.LittleEndian.PutUint16(buf[i:], bits.ReverseBytes16(binary.LittleEndian.Uint16(buf[i:])))
+		}
+		return
+	case 4:
+		for i := 0; i+4 <= len(buf); i += 4 {
+			binary.LittleEndian.PutUint32(buf[i:], bits.ReverseBytes32(binary.LittleEndian.Uint32(buf[i:])))
+		}
+		return
+	case 8:
+		for i := 0; i+8 <= len(buf); i += 8 {
+			binary.LittleEndian.PutUint64(buf[i:], bits.ReverseBytes64(binary.LittleEndian.Uint64(buf[i:])))
+		}
+		return
+	}
+
+	size := l.Size()
+	for rec := 0; rec+size <= len(buf); rec += size {
+		off := rec
+		for _, w := range l {
+			field := buf[off : off+w]
+			for i, j := 0, w-1; i < j; i, j = i+1, j-1 {
+				field[i], field[j] = field[j], field[i]
+			}
+			off += w
+		}
+	}
+}
+
+// TranscodeOrder streams fixed-layout records from src to dst, swapping the byte
+// order of every field. Converting between big- and little-endian archives this
+// way avoids a full decode/encode cycle per record.
+//
+// It returns the number of bytes written. A trailing partial record is reported
+// as ErrTruncatedData after all complete records have been written.
+func TranscodeOrder(dst io.Writer, src io.Reader, layout RecordLayout) (int64, error) {
+	size := layout.Size()
+	if size <= 0 {
+		return 0, fmt.Errorf("%w: empty record layout", ErrInvalidTag)
+	}
+
+	bufPtr := bufPool.Get().(*[]byte)
+	defer bufPool.Put(bufPtr)
+	buf := *bufPtr
+	if len(buf) < size {
+		buf = make([]byte, size)
+	}
+	buf = buf[:len(buf)/size*size]
+
+	var written int64
+	for {
+		n, err := io.ReadFull(src, buf)
+		whole := n / size * size
+		if whole > 0 {
+			layout.swapRecords(buf[:whole])
+			m, werr := dst.Write(buf[:whole])
+			written += int64(m)
+			if werr != nil {
+				return written, werr
+			}
+		}
+		switch err {
+		case nil:
+			continue
+		case io.EOF:
+			return written, nil
+		case io.ErrUnexpectedEOF:
+			if n != whole {
+				return written, fmt.Errorf("%w: %d trailing bytes", ErrTruncatedData, n-whole)
+			}
+			return written, nil
+		default:
+			return written, err
+		}
+	}
+}
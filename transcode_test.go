@@ -0,0 +1,104 @@
+//go:build test && !tinygo && !codec_tiny
+
+package codec
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type transcodeRecord struct {
+	A uint16
+	B [2]uint32
+	C int8
+	D float64
+}
+
+func TestTranscodeOrder(t *testing.T) {
+	layout, err := LayoutOf[transcodeRecord]()
+	require.NoError(t, err)
+	assert.Equal(t, RecordLayout{2, 4, 4, 1, 8}, layout)
+
+	records := make([]transcodeRecord, 5000)
+	for i := range records {
+		records[i] = transcodeRecord{A: uint16(i), B: [2]uint32{uint32(i), ^uint32(i)}, C: int8(i), D: float64(i) / 3}
+	}
+	var be, le bytes.Buffer
+	require.NoError(t, binary.Write(&be, binary.BigEndian, records))
+	require.NoError(t, binary.Write(&le, binary.LittleEndian, records))
+
+	var out bytes.Buffer
+	n, err := TranscodeOrder(&out, &be, layout)
+	require.NoError(t, err)
+	assert.EqualValues(t, le.Len(), n)
+	assert.Equal(t, le.Bytes(), out.Bytes())
+
+	// Homogeneous arrays take the bulk path.
+	out.Reset()
+	_, err = TranscodeOrder(&out, bytes.NewReader([]byte{1, 2, 3, 4, 5, 6, 7, 8}), RecordLayout{4})
+	require.NoError(t, err)
+	assert.Equal(t, []byte{4, 3, 2, 1, 8, 7, 6, 5}, out.Bytes())
+
+	_, err = TranscodeOrder(&out, bytes.NewReader([]byte{1, 2, 3}), RecordLayout{2})
+	assert.ErrorIs(t, err, ErrTruncatedData)
+}
+
+func TestCopyRecords(t *testing.T) {
+	layout, err := LayoutOf[transcodeRecord]()
+	require.NoError(t, err)
+
+	records := make([]transcodeRecord, 3000)
+	for i := range records {
+		records[i] = transcodeRecord{A: uint16(i), B: [2]uint32{uint32(i), 7}, C: int8(-i), D: float64(i)}
+	}
+	var src bytes.Buffer
+	require.NoError(t, binary.Write(&src, Order, records))
+
+	even := func(r Record) bool { return r.Uint(0)%2 == 0 }
+	var out bytes.Buffer
+	n, err := CopyRecords(&out, bytes.NewReader(src.Bytes()), layout, even, nil)
+	require.NoError(t, err)
+	assert.EqualValues(t, 1500*layout.Size(), n)
+
+	got := make([]transcodeRecord, 1500)
+	require.NoError(t, binary.Read(&out, Order, got))
+	assert.Equal(t, records[2], got[1])
+
+	// Project the float and the signed byte, in that order.
+	out.Reset()
+	tail := func(r Record) bool { return r.Float(4) >= 2900 && r.Int(3) < 0 }
+	_, err = CopyRecords(&out, bytes.NewReader(src.Bytes()), layout, tail, []int{4, 3})
+	require.NoError(t, err)
+	assert.Equal(t, 45*9, out.Len())
+	var first struct {
+		D float64
+		C int8
+	}
+	require.NoError(t, binary.Read(&out, Order, &first))
+	assert.Equal(t, 2900.0, first.D)
+	assert.Equal(t, int8(-84), first.C)
+}
+
+func TestRecordsIterator(t *testing.T) {
+	data := []byte{0, 1, 0xAA, 0, 2, 0xBB, 0, 3}
+	var seen []uint64
+	var err error
+	for rec, e := range Records(bytes.NewReader(data), RecordLayout{2, 1}) {
+		if e != nil {
+			err = e
+			break
+		}
+		seen = append(seen, rec.Uint(0))
+	}
+	assert.Equal(t, []uint64{1, 2}, seen)
+	assert.ErrorIs(t, err, ErrTruncatedData)
+
+	// Breaking out early stops reading.
+	for range Records(bytes.NewReader(data[:6]), RecordLayout{2, 1}) {
+		break
+	}
+}
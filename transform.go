@@ -0,0 +1,53 @@
+//go:build !tinygo && !codec_tiny
+
+package codec
+
+import (
+	"fmt"
+
+	"github.com/puzpuzpuz/xsync/v4"
+)
+
+// Transformer rewrites the encoded bytes of a single field, allowing sensitive
+// fields to be encrypted, masked or tokenized at the codec layer.
+//
+// Fields of a Fixed payload have a fixed width, so transformations operate
+// in place and must preserve the length of the field (e.g., a stream cipher
+// or format-preserving encryption).
+type Transformer interface {
+	// Encode transforms the plain field bytes before they are written.
+	Encode(field []byte) error
+	// Decode reverses Encode after the field bytes have been read.
+	Decode(field []byte) error
+}
+
+// transformers holds the registered transformers by name.
+var transformers = xsync.NewMap[string, Transformer]()
+
+// RegisterTransformer makes t available to fields tagged `codec:"transform:<name>"`.
+// Registering a name again replaces the previous transformer.
+func RegisterTransformer(name string, t Transformer) {
+	transformers.Store(name, t)
+}
+
+// applyTransforms runs the registered transformers of the layout over buf,
+// which must hold the complete encoding of the payload.
+func (l *fixedLayout) applyTransforms(buf []byte, encode bool) error {
+	for _, f := range l.transforms {
+		t, ok := transformers.Load(f.opts.transform)
+		if !ok {
+			return fmt.Errorf("%w: %q on field %s", ErrUnknownTransformer, f.opts.transform, f.name)
+		}
+		field := buf[f.offset : f.offset+f.size]
+		var err error
+		if encode {
+			err = t.Encode(field)
+		} else {
+			err = t.Decode(field)
+		}
+		if err != nil {
+			return fmt.Errorf("codec: transform %q on field %s: %w", f.opts.transform, f.name, err)
+		}
+	}
+	return nil
+}
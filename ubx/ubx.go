@@ -0,0 +1,129 @@
+// Package ubx reads and writes the binary frames of the u-blox UBX protocol
+// spoken by GNSS receivers: two sync characters, a message class and id, a
+// little-endian uint16 payload length, the payload and an 8-bit Fletcher
+// checksum over everything after the sync characters. Payloads are left to
+// the caller.
+package ubx
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"math"
+
+	"github.com/oy3o/codec"
+)
+
+const (
+	// SYNC1 and SYNC2 open every frame.
+	SYNC1 = 0xB5
+	SYNC2 = 0x62
+	// MAX_PAYLOAD is the largest payload the length field holds.
+	MAX_PAYLOAD = math.MaxUint16
+)
+
+// Message classes.
+const (
+	ClassNAV uint8 = 0x01
+	ClassRXM uint8 = 0x02
+	ClassINF uint8 = 0x04
+	ClassACK uint8 = 0x05
+	ClassCFG uint8 = 0x06
+	ClassUPD uint8 = 0x09
+	ClassMON uint8 = 0x0A
+	ClassTIM uint8 = 0x0D
+	ClassESF uint8 = 0x10
+	ClassMGA uint8 = 0x13
+	ClassLOG uint8 = 0x21
+	ClassSEC uint8 = 0x27
+	ClassHNR uint8 = 0x28
+)
+
+// Sync is the marker to pass to Reader.WithResync, so a stream interleaving
+// NMEA sentences or garbage can be scanned for the next frame.
+var Sync = []byte{SYNC1, SYNC2}
+
+var (
+	// ErrSync indicates a frame that does not start with the sync
+	// characters.
+	ErrSync = errors.New("ubx: missing sync characters")
+
+	// ErrChecksum indicates a frame whose checksum does not match.
+	ErrChecksum = errors.New("ubx: checksum mismatch")
+)
+
+// Frame is a UBX frame.
+type Frame struct {
+	Class   uint8
+	ID      uint8
+	Payload []byte
+}
+
+// ReadFrame reads a frame and verifies its checksum. A Reader at the end of
+// the stream returns io.EOF.
+func ReadFrame(r *codec.Reader) (*Frame, error) {
+	var head [6]byte
+	r.ReadBytesTo(head[:2])
+	if err := r.Err(); err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(head[:2], Sync) {
+		return nil, fmt.Errorf("%w: % x", ErrSync, head[:2])
+	}
+	r.ReadBytesTo(head[2:])
+	n := int(codec.LE.Uint16(head[4:]))
+	f := &Frame{Class: head[2], ID: head[3], Payload: r.ReadBytes(n)}
+	var sum [2]byte
+	r.ReadBytesTo(sum[:])
+	if err := r.Err(); err != nil {
+		return nil, unexpected(err)
+	}
+	c := codec.NewFletcher8()
+	c.Write(head[2:])
+	c.Write(f.Payload)
+	if want := c.Sum(nil); !bytes.Equal(sum[:], want) {
+		return nil, fmt.Errorf("%w: % x, computed % x", ErrChecksum, sum, want)
+	}
+	return f, nil
+}
+
+// WriteFrame writes a frame with its checksum.
+func WriteFrame(w *codec.Writer, f *Frame) error {
+	if len(f.Payload) > MAX_PAYLOAD {
+		return fmt.Errorf("%w: payload of %d bytes", codec.ErrLengthOverflow, len(f.Payload))
+	}
+	head := []byte{SYNC1, SYNC2, f.Class, f.ID, 0, 0}
+	codec.LE.PutUint16(head[4:], uint16(len(f.Payload)))
+	c := codec.NewFletcher8()
+	c.Write(head[2:])
+	c.Write(f.Payload)
+	w.WriteBytes(head)
+	w.WriteBytes(f.Payload)
+	w.WriteBytes(c.Sum(nil))
+	return w.Err()
+}
+
+// Frames iterates over the frames of r up to the end of the stream,
+// stopping after the first error.
+func Frames(r *codec.Reader) iter.Seq2[*Frame, error] {
+	return func(yield func(*Frame, error) bool) {
+		for {
+			f, err := ReadFrame(r)
+			if err == io.EOF {
+				return
+			}
+			if !yield(f, err) || err != nil {
+				return
+			}
+		}
+	}
+}
+
+func unexpected(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,50 @@
+//go:build test
+
+package ubx
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/oy3o/codec"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setRate is a UBX-CFG-MSG frame enabling NAV-PVT, from the u-blox
+// interface description.
+var setRate = []byte{0xb5, 0x62, 0x06, 0x01, 0x03, 0x00, 0xf1, 0x00, 0x01, 0xfc, 0x13}
+
+func TestFrames(t *testing.T) {
+	var buf bytes.Buffer
+	w, _ := codec.NewWriter(&buf)
+	require.NoError(t, WriteFrame(w, &Frame{Class: ClassCFG, ID: 0x01, Payload: []byte{0xf1, 0x00, 0x01}}))
+	require.NoError(t, WriteFrame(w, &Frame{Class: ClassNAV, ID: 0x07}))
+	require.NoError(t, w.Flush())
+	assert.Equal(t, setRate, buf.Bytes()[:len(setRate)])
+
+	r, _ := codec.NewReader(bytes.NewReader(buf.Bytes()))
+	var got []*Frame
+	for f, err := range Frames(r) {
+		require.NoError(t, err)
+		got = append(got, f)
+	}
+	require.Len(t, got, 2)
+	assert.Equal(t, ClassNAV, got[1].Class)
+	assert.Empty(t, got[1].Payload)
+
+	bad := bytes.Clone(setRate)
+	bad[6] = 0xf0
+	r, _ = codec.NewReader(bytes.NewReader(bad))
+	_, err := ReadFrame(r)
+	assert.ErrorIs(t, err, ErrChecksum)
+
+	r, _ = codec.NewReader(bytes.NewReader([]byte("$GPGGA")))
+	_, err = ReadFrame(r)
+	assert.ErrorIs(t, err, ErrSync)
+
+	r, _ = codec.NewReader(bytes.NewReader(setRate[:8]))
+	_, err = ReadFrame(r)
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+}
@@ -0,0 +1,279 @@
+package codec
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"sync/atomic"
+)
+
+// TagFormat selects how the discriminator of a Union is encoded.
+type TagFormat int
+
+const (
+	TagU8      TagFormat = iota // one byte
+	TagU16                      // two bytes in the order of the Reader or Writer, Order otherwise
+	TagUvarint                  // unsigned LEB128 varint
+)
+
+// size returns the encoded size of tag.
+func (f TagFormat) size(tag uint64) int {
+	switch f {
+	case TagU8:
+		return 1
+	case TagU16:
+		return 2
+	default:
+		var buf [binary.MaxVarintLen64]byte
+		return binary.PutUvarint(buf[:], tag)
+	}
+}
+
+// max returns the largest tag representable in the format.
+func (f TagFormat) max() uint64 {
+	switch f {
+	case TagU8:
+		return math.MaxUint8
+	case TagU16:
+		return math.MaxUint16
+	default:
+		return math.MaxUint64
+	}
+}
+
+// UnionSchema maps the discriminator tags of a variant record to constructors
+// of the Codec used for each variant. Register all variants before use; a schema
+// is safe for concurrent use once populated.
+type UnionSchema struct {
+	format TagFormat
+	ctors  map[uint64]func() Codec
+	types  map[reflect.Type][]uint64
+}
+
+// NewUnionSchema creates an empty schema whose tags are encoded in format.
+func NewUnionSchema(format TagFormat) *UnionSchema {
+	return &UnionSchema{
+		format: format,
+		ctors:  make(map[uint64]func() Codec),
+		types:  make(map[reflect.Type][]uint64),
+	}
+}
+
+// Register associates tag with a constructor returning an empty variant value,
+// and returns the schema for chaining. It panics if tag is registered twice or
+// does not fit the tag format, as both are programming errors.
+func (s *UnionSchema) Register(tag uint64, ctor func() Codec) *UnionSchema {
+	if tag > s.format.max() {
+		panic(fmt.Sprintf("codec: union tag %d does not fit the tag format", tag))
+	}
+	if _, ok := s.ctors[tag]; ok {
+		panic(fmt.Sprintf("codec: union tag %d registered twice", tag))
+	}
+	s.ctors[tag] = ctor
+	t := reflect.TypeOf(ctor())
+	s.types[t] = append(s.types[t], tag)
+	return s
+}
+
+// New returns a Union holding v, with the tag derived from the type of v.
+func (s *UnionSchema) New(v Codec) *Union {
+	return &Union{Schema: s, Value: v}
+}
+
+// Union is a discriminated union: a tag followed by the encoding of the variant
+// it selects. Encoders write the tag automatically, and decoders construct the
+// registered variant for the tag they read.
+type Union struct {
+	Schema *UnionSchema
+	// Tag is the discriminator. On encode it is only consulted when the type of
+	// Value is registered under several tags; otherwise it is derived from Value.
+	Tag   uint64
+	Value Codec
+}
+
+// Statically assert that Union implements Codec.
+var _ Codec = (*Union)(nil)
+
+// tag resolves the discriminator to encode for the current Value.
+func (u *Union) tag() (uint64, error) {
+	if u.Value == nil {
+		return 0, fmt.Errorf("%w: nil union value", ErrUnknownVariant)
+	}
+	tags := u.Schema.types[reflect.TypeOf(u.Value)]
+	switch {
+	case len(tags) == 1:
+		return tags[0], nil
+	case len(tags) > 1:
+		for _, tag := range tags {
+			if tag == u.Tag {
+				return tag, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("%w: %T (tag %d)", ErrUnknownVariant, u.Value, u.Tag)
+}
+
+// Size returns the size of the tag plus the size of the variant.
+func (u *Union) Size() int {
+	tag, err := u.tag()
+	if err != nil {
+		return 0
+	}
+	return u.Schema.format.size(tag) + u.Value.Size()
+}
+
+// WriteTo writes the tag followed by the variant and updates Tag.
+func (u *Union) WriteTo(w io.Writer) (int64, error) {
+	tag, err := u.tag()
+	if err != nil {
+		return 0, err
+	}
+	u.Tag = tag
+
+	var buf [binary.MaxVarintLen64]byte
+	var hdr []byte
+	switch u.Schema.format {
+	case TagU8:
+		hdr = append(buf[:0], byte(tag))
+	case TagU16:
+		hdr = buf[:2]
+		streamOrder(w).PutUint16(hdr, uint16(tag))
+	default:
+		hdr = binary.AppendUvarint(buf[:0], tag)
+	}
+	n, err := w.Write(hdr)
+	if err != nil {
+		return int64(n), err
+	}
+	m, err := u.Value.WriteTo(w)
+	return int64(n) + m, err
+}
+
+// ReadFrom reads a tag and decodes the variant it selects into a new Value.
+// It never reads beyond the end of the variant.
+func (u *Union) ReadFrom(r io.Reader) (int64, error) {
+	e := &exactReader{r: r, order: streamOrder(r)}
+	var tag uint64
+	var err error
+	switch u.Schema.format {
+	case TagU8:
+		var b byte
+		b, err = e.ReadByte()
+		tag = uint64(b)
+	case TagU16:
+		var buf [2]byte
+		err = e.readFull(buf[:])
+		tag = uint64(e.order.Uint16(buf[:]))
+	default:
+		tag, err = binary.ReadUvarint(e)
+	}
+	if err != nil {
+		if err == io.EOF && e.n > 0 {
+			err = io.ErrUnexpectedEOF
+		}
+		return e.n, err
+	}
+
+	ctor, ok := u.Schema.ctors[tag]
+	if !ok {
+		return e.n, fmt.Errorf("%w: tag %d", ErrUnknownVariant, tag)
+	}
+	v := ctor()
+	n, err := v.ReadFrom(r)
+	if err != nil {
+		return e.n + n, err
+	}
+	u.Tag, u.Value = tag, v
+	return e.n + n, nil
+}
+
+// --- Boilerplate implementations ---
+
+func (u *Union) MarshalBinary() ([]byte, error) {
+	return MarshalBinaryGeneric(u)
+}
+
+func (u *Union) UnmarshalBinary(data []byte) error {
+	return UnmarshalBinaryGeneric(u, data)
+}
+
+func (u *Union) MarshalTo(buf []byte) (int, error) {
+	return MarshalToGeneric(u, buf)
+}
+
+func (u *Union) MarshalAppend(dst []byte) ([]byte, error) {
+	return MarshalAppendGeneric(u, dst)
+}
+
+// Validate checks that every constructor of the schema returns a non-nil
+// variant, catching broken definitions before they are put in service.
+func (s *UnionSchema) Validate() error {
+	for tag, ctor := range s.ctors {
+		if v := ctor(); v == nil || reflect.ValueOf(v).Kind() == reflect.Pointer && reflect.ValueOf(v).IsNil() {
+			return fmt.Errorf("%w: constructor of tag %d returned nil", ErrInvalidSchema, tag)
+		}
+	}
+	return nil
+}
+
+// compatible reports why data written with old could not be read with s.
+func (s *UnionSchema) compatible(old *UnionSchema) error {
+	if s.format != old.format {
+		return fmt.Errorf("%w: tag format changed", ErrInvalidSchema)
+	}
+	for tag, ctor := range old.ctors {
+		next, ok := s.ctors[tag]
+		if !ok {
+			return fmt.Errorf("%w: tag %d removed", ErrInvalidSchema, tag)
+		}
+		if was, is := reflect.TypeOf(ctor()), reflect.TypeOf(next()); was != is {
+			return fmt.Errorf("%w: tag %d changed from %s to %s", ErrInvalidSchema, tag, was, is)
+		}
+	}
+	return nil
+}
+
+// UnionRegistry holds the current UnionSchema of a long-running service and
+// lets it be replaced at runtime, for instance when new message definitions
+// are loaded. Replacement is atomic: Unions created before it keep the schema
+// they were created with, so in-flight decodes finish on the old version.
+type UnionRegistry struct {
+	cur atomic.Pointer[UnionSchema]
+}
+
+// NewUnionRegistry returns a registry serving s.
+func NewUnionRegistry(s *UnionSchema) (*UnionRegistry, error) {
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+	r := &UnionRegistry{}
+	r.cur.Store(s)
+	return r, nil
+}
+
+// Schema returns the current schema.
+func (r *UnionRegistry) Schema() *UnionSchema { return r.cur.Load() }
+
+// New returns an empty Union for decoding with the current schema.
+func (r *UnionRegistry) New() *Union { return &Union{Schema: r.cur.Load()} }
+
+// Replace validates s and atomically makes it the current schema. A schema
+// must keep every tag of the current one with the same variant type and tag
+// format, so data written under the old version stays readable; new tags may
+// be added freely. On error the current schema is unchanged.
+func (r *UnionRegistry) Replace(s *UnionSchema) error {
+	if err := s.Validate(); err != nil {
+		return err
+	}
+	for {
+		old := r.cur.Load()
+		if err := s.compatible(old); err != nil {
+			return err
+		}
+		if r.cur.CompareAndSwap(old, s) {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,84 @@
+//go:build test && !tinygo && !codec_tiny
+
+package codec
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type unionPing struct{ Seq uint16 }
+
+func TestUnion(t *testing.T) {
+	schema := NewUnionSchema(TagUvarint).
+		Register(1, func() Codec { return &Fixed[unionPing]{} }).
+		Register(300, func() Codec { return &mockCodec{} }).
+		Register(301, func() Codec { return &mockCodec{} })
+
+	ping := schema.New(&Fixed[unionPing]{Payload: unionPing{Seq: 7}})
+	data, err := ping.MarshalBinary()
+	require.NoError(t, err)
+	assert.Equal(t, []byte{1, 0, 7}, data)
+
+	// Types registered under several tags need an explicit Tag.
+	_, err = schema.New(&mockCodec{}).MarshalBinary()
+	assert.ErrorIs(t, err, ErrUnknownVariant)
+	msg := &Union{Schema: schema, Tag: 301, Value: &mockCodec{Payload: mockPayload{ID: 1}}}
+	data, err = msg.MarshalBinary()
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0xAD, 0x02, 0, 0, 0, 1, 0, 0, 0, 0}, data)
+
+	// Decoding a stream of unions stops exactly at each boundary.
+	var stream bytes.Buffer
+	_, err = ping.WriteTo(&stream)
+	require.NoError(t, err)
+	_, err = msg.WriteTo(&stream)
+	require.NoError(t, err)
+
+	out := &Union{Schema: schema}
+	_, err = out.ReadFrom(&stream)
+	require.NoError(t, err)
+	assert.Equal(t, ping.Value, out.Value)
+	_, err = out.ReadFrom(&stream)
+	require.NoError(t, err)
+	assert.EqualValues(t, 301, out.Tag)
+	assert.Equal(t, msg.Value, out.Value)
+
+	assert.ErrorIs(t, out.UnmarshalBinary([]byte{2, 0, 0}), ErrUnknownVariant)
+	assert.Panics(t, func() { NewUnionSchema(TagU8).Register(256, func() Codec { return &mockCodec{} }) })
+}
+
+func TestUnionRegistry(t *testing.T) {
+	v1 := NewUnionSchema(TagU8).Register(1, func() Codec { return &Fixed[unionPing]{} })
+	reg, err := NewUnionRegistry(v1)
+	require.NoError(t, err)
+
+	data, err := v1.New(&Fixed[unionPing]{Payload: unionPing{Seq: 9}}).MarshalBinary()
+	require.NoError(t, err)
+	inflight := reg.New()
+
+	v2 := NewUnionSchema(TagU8).
+		Register(1, func() Codec { return &Fixed[unionPing]{} }).
+		Register(2, func() Codec { return &mockCodec{} })
+	require.NoError(t, reg.Replace(v2))
+	assert.Same(t, v2, reg.Schema())
+	assert.Same(t, v1, inflight.Schema)
+
+	// Old data stays readable and new variants are known.
+	u := reg.New()
+	require.NoError(t, u.UnmarshalBinary(data))
+	assert.EqualValues(t, 9, u.Value.(*Fixed[unionPing]).Payload.Seq)
+	require.NoError(t, reg.New().UnmarshalBinary([]byte{2, 0, 0, 0, 1, 0, 0, 0, 0}))
+
+	removed := NewUnionSchema(TagU8).Register(2, func() Codec { return &mockCodec{} })
+	assert.ErrorIs(t, reg.Replace(removed), ErrInvalidSchema)
+	retyped := NewUnionSchema(TagU8).Register(1, func() Codec { return &mockCodec{} })
+	assert.ErrorIs(t, reg.Replace(retyped), ErrInvalidSchema)
+	assert.ErrorIs(t, reg.Replace(NewUnionSchema(TagU16)), ErrInvalidSchema)
+	broken := NewUnionSchema(TagU8).Register(1, func() Codec { return (*Fixed[unionPing])(nil) })
+	assert.ErrorIs(t, reg.Replace(broken), ErrInvalidSchema)
+	assert.Same(t, v2, reg.Schema())
+}
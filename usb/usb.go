@@ -0,0 +1,234 @@
+//go:build !tinygo && !codec_tiny
+
+// Package usb decodes and encodes the standard USB descriptors: device,
+// configuration, interface, endpoint and interface association. Each
+// descriptor is a bLength byte, a bDescriptorType byte and a fixed
+// little-endian body, which package usb reads as a codec.Union tagged by the
+// type within a codec.Reader section of bLength bytes. Bodies longer than
+// their standard layout, such as those of audio endpoints, have their extra
+// bytes skipped, and descriptors of types the schema does not know are
+// skipped whole, so class-specific descriptors interleaved in a
+// configuration do not stop the parse. Register them in a copy of Standard
+// to decode them too.
+package usb
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+
+	"github.com/oy3o/codec"
+)
+
+// Descriptor types.
+const (
+	DEVICE                = 0x01
+	CONFIGURATION         = 0x02
+	STRING                = 0x03
+	INTERFACE             = 0x04
+	ENDPOINT              = 0x05
+	DEVICE_QUALIFIER      = 0x06
+	INTERFACE_ASSOCIATION = 0x0B
+	BOS                   = 0x0F
+	HID                   = 0x21
+)
+
+// HEADER_SIZE is the size of the bLength and bDescriptorType fields.
+const HEADER_SIZE = 2
+
+var (
+	// ErrInvalidDescriptor indicates a bLength shorter than the header, or
+	// than the standard layout of the descriptor type.
+	ErrInvalidDescriptor = errors.New("usb: invalid descriptor")
+
+	// ErrUnknownDescriptor indicates a descriptor type the schema does not
+	// know. ReadDescriptor has skipped it.
+	ErrUnknownDescriptor = errors.New("usb: unknown descriptor type")
+)
+
+// BCD is a binary-coded decimal version, such as 0x0200 for USB 2.0.
+type BCD uint16
+
+func (b BCD) String() string { return fmt.Sprintf("%x.%02x", uint16(b)>>8, uint16(b)&0xFF) }
+
+// Device is the body of a device descriptor.
+type Device struct {
+	USB               BCD
+	Class             uint8
+	SubClass          uint8
+	Protocol          uint8
+	MaxPacketSize0    uint8
+	Vendor            uint16
+	Product           uint16
+	Device            BCD
+	ManufacturerIndex uint8
+	ProductIndex      uint8
+	SerialIndex       uint8
+	NumConfigurations uint8
+}
+
+// Configuration is the body of a configuration descriptor. TotalLength
+// covers the configuration and all the descriptors following it.
+type Configuration struct {
+	TotalLength        uint16
+	NumInterfaces      uint8
+	ConfigurationValue uint8
+	ConfigurationIndex uint8
+	Attributes         uint8
+	MaxPower           uint8 // in units of 2 mA, or 8 mA at SuperSpeed
+}
+
+// Attributes bits of a configuration.
+const (
+	SELF_POWERED  = 0x40
+	REMOTE_WAKEUP = 0x20
+)
+
+// SelfPowered reports whether the configuration powers the device itself.
+func (c *Configuration) SelfPowered() bool { return c.Attributes&SELF_POWERED != 0 }
+
+// RemoteWakeup reports whether the configuration supports remote wakeup.
+func (c *Configuration) RemoteWakeup() bool { return c.Attributes&REMOTE_WAKEUP != 0 }
+
+// Interface is the body of an interface descriptor.
+type Interface struct {
+	Number           uint8
+	AlternateSetting uint8
+	NumEndpoints     uint8
+	Class            uint8
+	SubClass         uint8
+	Protocol         uint8
+	InterfaceIndex   uint8
+}
+
+// Endpoint is the body of an endpoint descriptor.
+type Endpoint struct {
+	Address       uint8
+	Attributes    uint8
+	MaxPacketSize uint16
+	Interval      uint8
+}
+
+// Transfer types of an endpoint.
+const (
+	CONTROL     = 0b00
+	ISOCHRONOUS = 0b01
+	BULK        = 0b10
+	INTERRUPT   = 0b11
+)
+
+// Number returns the endpoint number.
+func (e *Endpoint) Number() uint8 { return e.Address & 0x0F }
+
+// In reports whether the endpoint transfers from device to host.
+func (e *Endpoint) In() bool { return e.Address&0x80 != 0 }
+
+// TransferType returns the transfer type, one of CONTROL, ISOCHRONOUS, BULK
+// and INTERRUPT.
+func (e *Endpoint) TransferType() uint8 { return e.Attributes & 0b11 }
+
+// PacketSize returns the largest packet of the endpoint, without the
+// additional transactions per microframe of high-speed endpoints.
+func (e *Endpoint) PacketSize() int { return int(e.MaxPacketSize & 0x7FF) }
+
+// InterfaceAssociation is the body of an interface association descriptor,
+// grouping the interfaces of one function.
+type InterfaceAssociation struct {
+	FirstInterface uint8
+	InterfaceCount uint8
+	Class          uint8
+	SubClass       uint8
+	Protocol       uint8
+	FunctionIndex  uint8
+}
+
+// fixed returns a constructor of little-endian codec.Fixed descriptor bodies.
+func fixed[T any]() func() codec.Codec {
+	return func() codec.Codec { return (&codec.Fixed[T]{}).WithByteOrder(codec.LE) }
+}
+
+// Standard is the schema of the standard descriptors. Its variants are
+// *codec.Fixed[Device], *codec.Fixed[Configuration], *codec.Fixed[Interface],
+// *codec.Fixed[Endpoint] and *codec.Fixed[InterfaceAssociation].
+var Standard = NewSchema()
+
+// NewSchema returns a schema of the standard descriptors, to which
+// class-specific descriptors can be added with Register.
+func NewSchema() *codec.UnionSchema {
+	return codec.NewUnionSchema(codec.TagU8).
+		Register(DEVICE, fixed[Device]()).
+		Register(CONFIGURATION, fixed[Configuration]()).
+		Register(INTERFACE, fixed[Interface]()).
+		Register(ENDPOINT, fixed[Endpoint]()).
+		Register(INTERFACE_ASSOCIATION, fixed[InterfaceAssociation]())
+}
+
+// ReadDescriptor reads a descriptor, decoding its body with the variant s
+// registers for its type. Bytes past the variant are skipped, as is the
+// whole descriptor when s does not know its type, in which case the error
+// is ErrUnknownDescriptor. Either way r is left at the next descriptor. A
+// Reader at the end of the stream returns io.EOF.
+func ReadDescriptor(r *codec.Reader, s *codec.UnionSchema) (*codec.Union, error) {
+	var length uint8
+	r.ReadUint8(&length)
+	if err := r.Err(); err != nil {
+		return nil, err
+	}
+	if length < HEADER_SIZE {
+		return nil, fmt.Errorf("%w: bLength %d", ErrInvalidDescriptor, length)
+	}
+	sec := r.Section(int64(length) - 1)
+	u := &codec.Union{Schema: s}
+	_, err := u.ReadFrom(sec)
+	if cerr := sec.Close(); cerr != nil {
+		return nil, unexpected(cerr)
+	}
+	switch {
+	case errors.Is(err, codec.ErrUnknownVariant):
+		return nil, fmt.Errorf("%w: %v", ErrUnknownDescriptor, err)
+	case err == io.EOF || err == io.ErrUnexpectedEOF:
+		return nil, fmt.Errorf("%w: bLength %d too short for its type", ErrInvalidDescriptor, length)
+	case err != nil:
+		return nil, err
+	}
+	return u, nil
+}
+
+// Descriptors iterates over the descriptors of r up to the end of the
+// stream, skipping those of types s does not know and stopping after the
+// first error.
+func Descriptors(r *codec.Reader, s *codec.UnionSchema) iter.Seq2[*codec.Union, error] {
+	return func(yield func(*codec.Union, error) bool) {
+		for {
+			u, err := ReadDescriptor(r, s)
+			if err == io.EOF {
+				return
+			}
+			if errors.Is(err, ErrUnknownDescriptor) {
+				continue
+			}
+			if !yield(u, err) || err != nil {
+				return
+			}
+		}
+	}
+}
+
+// AppendDescriptor appends v, preceded by its bLength and the type s
+// registers it under, to dst.
+func AppendDescriptor(dst []byte, s *codec.UnionSchema, v codec.Codec) ([]byte, error) {
+	u := s.New(v)
+	size := 1 + u.Size()
+	if size > 0xFF {
+		return nil, fmt.Errorf("%w: descriptor of %d bytes", codec.ErrLengthOverflow, size)
+	}
+	return u.MarshalAppend(append(dst, byte(size)))
+}
+
+func unexpected(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,85 @@
+//go:build test && !tinygo && !codec_tiny
+
+package usb
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+
+	"github.com/oy3o/codec"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// device is the device descriptor of a Linux USB 2.0 root hub.
+var device, _ = hex.DecodeString("12010002090001406b1d0200060603020101")
+
+// config is a configuration with a HID interface: its HID class descriptor
+// is unknown to Standard, and its endpoint has two bytes of audio-style
+// extension past the standard layout.
+var config, _ = hex.DecodeString("" +
+	"09022400010100a032" +
+	"090400000103010100" +
+	"092111010001223f00" +
+	"0905810308000a0000")
+
+func TestReadDevice(t *testing.T) {
+	r, _ := codec.NewReader(bytes.NewReader(device))
+	u, err := ReadDescriptor(r, Standard)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(DEVICE), u.Tag)
+	d := u.Value.(*codec.Fixed[Device]).Payload
+	assert.Equal(t, "2.00", d.USB.String())
+	assert.Equal(t, uint16(0x1d6b), d.Vendor)
+	assert.Equal(t, uint16(0x0002), d.Product)
+	assert.Equal(t, "6.06", d.Device.String())
+	assert.Equal(t, uint8(1), d.NumConfigurations)
+
+	b, err := AppendDescriptor(nil, Standard, u.Value)
+	require.NoError(t, err)
+	assert.Equal(t, device, b)
+}
+
+func TestDescriptors(t *testing.T) {
+	r, _ := codec.NewReader(bytes.NewReader(config))
+	var types []uint64
+	var values []codec.Codec
+	for u, err := range Descriptors(r, Standard) {
+		require.NoError(t, err)
+		types = append(types, u.Tag)
+		values = append(values, u.Value)
+	}
+	assert.Equal(t, []uint64{CONFIGURATION, INTERFACE, ENDPOINT}, types)
+	assert.Equal(t, int64(len(config)), r.Count())
+
+	c := values[0].(*codec.Fixed[Configuration]).Payload
+	assert.Equal(t, uint16(len(config)), c.TotalLength)
+	assert.False(t, c.SelfPowered())
+	assert.True(t, c.RemoteWakeup())
+	assert.Equal(t, uint8(3), values[1].(*codec.Fixed[Interface]).Payload.Class)
+
+	e := values[2].(*codec.Fixed[Endpoint]).Payload
+	assert.Equal(t, uint8(1), e.Number())
+	assert.True(t, e.In())
+	assert.Equal(t, uint8(INTERRUPT), e.TransferType())
+	assert.Equal(t, 8, e.PacketSize())
+	assert.Equal(t, uint8(10), e.Interval)
+}
+
+func TestReadDescriptorErrors(t *testing.T) {
+	r, _ := codec.NewReader(bytes.NewReader(config[18:]))
+	_, err := ReadDescriptor(r, Standard)
+	assert.ErrorIs(t, err, ErrUnknownDescriptor)
+	assert.Equal(t, int64(9), r.Count())
+
+	// An interface descriptor too short for its layout.
+	r, _ = codec.NewReader(bytes.NewReader([]byte{4, INTERFACE, 0, 0, 7, ENDPOINT}))
+	_, err = ReadDescriptor(r, Standard)
+	assert.ErrorIs(t, err, ErrInvalidDescriptor)
+	assert.Equal(t, int64(4), r.Count())
+
+	r, _ = codec.NewReader(bytes.NewReader([]byte{1}))
+	_, err = ReadDescriptor(r, Standard)
+	assert.ErrorIs(t, err, ErrInvalidDescriptor)
+}
@@ -12,10 +12,25 @@ import (
 var (
 	BE = binary.BigEndian
 	LE = binary.LittleEndian
-	// Order is default binary order
+	// Order is default binary order. Libraries should not rely on it, since
+	// any package in the program may change it; pass WithOrder to
+	// NewReaderOpts or NewWriterOpts, and give codecs such as Fixed their
+	// own order with WithByteOrder, instead.
 	Order = BE
 )
 
+// streamOrder returns the byte order of s if it is a Reader or Writer, and
+// Order otherwise, so codecs follow the order the stream was configured with.
+func streamOrder(s any) binary.ByteOrder {
+	switch s := s.(type) {
+	case *Reader:
+		return s.order
+	case *Writer:
+		return s.order
+	}
+	return Order
+}
+
 const BUFFER_SIZE = 4096
 
 var (
@@ -42,37 +57,57 @@ func Discard(r io.Reader, n int64) (int64, error) {
 // Roundup rounds n up to the nearest multiple of align.
 func Roundup[T constraints.Integer](n, align T) T { return (n + (align - 1)) &^ (align - 1) }
 
-// MAX_PADDING defines the maximum number of trailing bytes to check.
+// MAX_PADDING defines the default maximum number of trailing bytes to check.
 // This prevents an Out-Of-Memory error if a parsing bug leaves a large
 // amount of data in the reader. Anything larger is considered a protocol error.
+// Formats that legally pad further, such as to 64KB sector boundaries, raise
+// the limit with Reader.WithMaxPadding or the *Limit variants of the checks.
 const MAX_PADDING = 1024 // 1KB
 
 // CheckTrailingNotZeros verifies that any remaining bytes in a reader are all zero.
 // This is critical for parsers to ensure the entire expected payload was consumed
 // and no garbage data follows, which could indicate a bug or a malicious payload.
+// At most MAX_PADDING bytes may remain, or the limit set by Reader.WithMaxPadding
+// when r is a *Reader.
 func CheckTrailingNotZeros(r io.Reader) error {
+	limit := int64(MAX_PADDING)
+	if reader, ok := r.(*Reader); ok && reader.maxPadding > 0 {
+		limit = reader.maxPadding
+	}
+	return CheckTrailingNotZerosLimit(r, limit)
+}
+
+// CheckTrailingNotZerosLimit is CheckTrailingNotZeros allowing at most limit
+// trailing bytes.
+func CheckTrailingNotZerosLimit(r io.Reader, limit int64) error {
 	// Fast path for a common reader type to avoid any allocations.
 	if reader, ok := r.(*BytesReader); ok && reader.Available() == 0 {
 		return nil
 	}
 
 	// Use a LimitedReader to enforce our heuristic limit. We read up to
-	// `maxExpectedPadding + 1` bytes; if the read succeeds, we know there was
+	// `limit + 1` bytes; if the read succeeds, we know there was
 	// too much data.
-	lr := &io.LimitedReader{R: r, N: MAX_PADDING + 1}
+	lr := &io.LimitedReader{R: r, N: limit + 1}
 
 	trailingData, err := io.ReadAll(lr)
 	if err != nil {
 		return err
 	}
 
-	return CheckBufferNotZeros(trailingData)
+	return CheckBufferNotZerosLimit(trailingData, limit)
 }
 
 func CheckBufferNotZeros(trailingData []byte) error {
+	return CheckBufferNotZerosLimit(trailingData, MAX_PADDING)
+}
+
+// CheckBufferNotZerosLimit is CheckBufferNotZeros allowing at most limit
+// trailing bytes.
+func CheckBufferNotZerosLimit(trailingData []byte, limit int64) error {
 	// Heuristic check: Did we read more than the allowed padding size?
-	if len(trailingData) > MAX_PADDING {
-		return fmt.Errorf("%w: exceeds maximum expected size of %d bytes", ErrTrailingData, MAX_PADDING)
+	if int64(len(trailingData)) > limit {
+		return fmt.Errorf("%w: exceeds maximum expected size of %d bytes", ErrTrailingData, limit)
 	}
 
 	// Check if the data we did read contains non-zero bytes.
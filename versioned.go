@@ -0,0 +1,161 @@
+//go:build !tinygo && !codec_tiny
+
+package codec
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"reflect"
+)
+
+// VERSION_SIZE is the size in bytes of the version number preceding a Versioned payload.
+const VERSION_SIZE = 2
+
+// Versioned is a Codec for fixed-size payloads whose layout evolves over time.
+// The encoding is a uint16 version number in Order followed by the payload
+// fields present in that version.
+//
+// Fields tagged `codec:"since=N"` were added in version N; untagged fields are
+// present since version 0. Decoding an older version leaves the fields it
+// lacks at their zero value, so new code keeps reading old data. Versions newer
+// than the latest since tag are rejected with ErrUnknownVersion.
+//
+//	type Header struct {
+//		ID    uint32
+//		Flags uint16 `codec:"since=1"`
+//		TTL   uint32 `codec:"since=2"`
+//	}
+type Versioned[Payload any] struct {
+	// Version selects the layout written by the encoders and reports the
+	// layout read by the decoders. Zero-valued Versioned codecs encode version 0.
+	Version uint16
+	Payload Payload
+	order   binary.ByteOrder // set by WithByteOrder, nil for Order
+}
+
+// Statically assert that Versioned implements Codec.
+var _ Codec = (*Versioned[struct{}])(nil)
+
+// NewVersioned returns a Versioned codec for p at the latest version.
+func NewVersioned[Payload any](p Payload) *Versioned[Payload] {
+	return &Versioned[Payload]{Version: uint16(layoutOf(reflect.TypeOf((*Payload)(nil)).Elem()).latest), Payload: p}
+}
+
+// WithByteOrder sets the byte order of the version and payload in place of
+// the global Order and returns the configured Versioned for chaining.
+func (c *Versioned[Payload]) WithByteOrder(order binary.ByteOrder) *Versioned[Payload] {
+	c.order = order
+	return c
+}
+
+// byteOrder returns the configured byte order, defaulting to Order.
+func (c *Versioned[Payload]) byteOrder() binary.ByteOrder {
+	if c.order == nil {
+		return Order
+	}
+	return c.order
+}
+
+// writeToOrder implements orderInheritor.
+func (c *Versioned[Payload]) writeToOrder(w io.Writer, order binary.ByteOrder) (int64, error) {
+	if c.order != nil {
+		return c.WriteTo(w)
+	}
+	// Encode a copy, so items shared between writers are never modified.
+	tmp := Versioned[Payload]{Version: c.Version, Payload: c.Payload, order: order}
+	return tmp.WriteTo(w)
+}
+
+// inheritOrder implements orderInheritor.
+func (c *Versioned[Payload]) inheritOrder(order binary.ByteOrder) {
+	if c.order == nil {
+		c.order = order
+	}
+}
+
+// layout returns the layout of the payload at version v.
+func (c *Versioned[Payload]) layout(v uint16) (*fixedLayout, error) {
+	t := reflect.TypeOf((*Payload)(nil)).Elem()
+	if latest := layoutOf(t).latest; int(v) > latest {
+		return nil, fmt.Errorf("%w: %d (latest %d)", ErrUnknownVersion, v, latest)
+	}
+	l := versionLayoutOf(t, int(v))
+	return l, l.err
+}
+
+// Size returns the encoded size at the current Version.
+func (c *Versioned[Payload]) Size() int {
+	l, err := c.layout(c.Version)
+	if err != nil {
+		return VERSION_SIZE
+	}
+	return VERSION_SIZE + l.size
+}
+
+// MarshalTo encodes the version and the fields present in it into p.
+func (c *Versioned[Payload]) MarshalTo(p []byte) (int, error) {
+	l, err := c.layout(c.Version)
+	if err != nil {
+		return 0, err
+	}
+	if len(p) < VERSION_SIZE+l.size {
+		return 0, io.ErrShortWrite
+	}
+	c.byteOrder().PutUint16(p, c.Version)
+	n, err := l.encode(p[VERSION_SIZE:], c.byteOrder(), reflect.ValueOf(&c.Payload).Elem())
+	return VERSION_SIZE + n, err
+}
+
+// ReadFrom reads the version and exactly the payload bytes of that version.
+func (c *Versioned[Payload]) ReadFrom(r io.Reader) (int64, error) {
+	var hdr [VERSION_SIZE]byte
+	if n, err := io.ReadFull(r, hdr[:]); err != nil {
+		return int64(n), err
+	}
+	version := c.byteOrder().Uint16(hdr[:])
+	l, err := c.layout(version)
+	if err != nil {
+		return VERSION_SIZE, err
+	}
+	buf := make([]byte, l.size)
+	if n, err := io.ReadFull(r, buf); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return int64(VERSION_SIZE + n), err
+	}
+	if err := l.applyTransforms(buf, false); err != nil {
+		return int64(VERSION_SIZE + l.size), err
+	}
+
+	var p Payload
+	if err := l.decode(buf, c.byteOrder(), reflect.ValueOf(&p).Elem()); err != nil {
+		return int64(VERSION_SIZE + l.size), err
+	}
+	c.Version, c.Payload = version, p
+	return int64(VERSION_SIZE + l.size), nil
+}
+
+// --- Boilerplate implementations ---
+
+func (c *Versioned[Payload]) MarshalBinary() ([]byte, error) {
+	buf := make([]byte, c.Size())
+	n, err := c.MarshalTo(buf)
+	if err != nil {
+		return nil, err
+	}
+	return buf[:n], nil
+}
+
+func (c *Versioned[Payload]) UnmarshalBinary(data []byte) error {
+	return UnmarshalBinaryGeneric(c, data)
+}
+
+func (c *Versioned[Payload]) WriteTo(w io.Writer) (int64, error) {
+	return WriteToGeneric(c, w)
+}
+
+func (c *Versioned[Payload]) MarshalAppend(dst []byte) ([]byte, error) {
+	return MarshalAppendGeneric(c, dst)
+}
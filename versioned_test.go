@@ -0,0 +1,42 @@
+//go:build test && !tinygo && !codec_tiny
+
+package codec
+
+import (
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type versionedHeader struct {
+	ID    uint32
+	Flags uint16 `codec:"since=1"`
+	TTL   uint32 `codec:"since=2"`
+}
+
+func TestVersioned(t *testing.T) {
+	latest := NewVersioned(versionedHeader{ID: 7, Flags: 3, TTL: 60})
+	assert.EqualValues(t, 2, latest.Version)
+	assert.Equal(t, 12, latest.Size())
+
+	data, err := latest.MarshalBinary()
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0, 2, 0, 0, 0, 7, 0, 3, 0, 0, 0, 60}, data)
+
+	var out Versioned[versionedHeader]
+	require.NoError(t, out.UnmarshalBinary(data))
+	assert.Equal(t, *latest, out)
+
+	// An old writer only knew version 1; the new reader zero-fills TTL.
+	old := &Versioned[versionedHeader]{Version: 1, Payload: versionedHeader{ID: 7, Flags: 3, TTL: 60}}
+	data, err = old.MarshalBinary()
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0, 1, 0, 0, 0, 7, 0, 3}, data)
+	require.NoError(t, out.UnmarshalBinary(data))
+	assert.Equal(t, Versioned[versionedHeader]{Version: 1, Payload: versionedHeader{ID: 7, Flags: 3}}, out)
+
+	assert.ErrorIs(t, out.UnmarshalBinary([]byte{0, 3, 0, 0, 0, 7}), ErrUnknownVersion)
+	assert.ErrorIs(t, out.UnmarshalBinary([]byte{0, 2, 0, 0}), io.ErrUnexpectedEOF)
+}
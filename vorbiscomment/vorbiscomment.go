@@ -0,0 +1,175 @@
+// Package vorbiscomment reads and writes Vorbis comments, the metadata of
+// Ogg Vorbis, Opus and FLAC files: a vendor string and a list of
+// NAME=value fields, all little-endian length-prefixed UTF-8.
+//
+// The block is the same in every container; Ogg Vorbis follows it with a
+// framing bit and Opus precedes it with "OpusTags", both left to the caller.
+package vorbiscomment
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/oy3o/codec"
+)
+
+// MAX_LENGTH bounds the strings read, which may hold base64 pictures.
+const MAX_LENGTH = 16 << 20
+
+// ErrInvalidComment indicates a field without '=' or with an invalid name.
+var ErrInvalidComment = errors.New("vorbiscomment: invalid comment")
+
+// Field is a comment field. Names are case-insensitive ASCII.
+type Field struct {
+	Name  string
+	Value string
+}
+
+// Comments is a Vorbis comment block.
+type Comments struct {
+	Vendor string
+	Fields []Field
+}
+
+// Get returns the value of the first field named name, or "".
+func (c *Comments) Get(name string) string {
+	for _, f := range c.Fields {
+		if strings.EqualFold(f.Name, name) {
+			return f.Value
+		}
+	}
+	return ""
+}
+
+// GetAll returns the values of the fields named name, in order.
+func (c *Comments) GetAll(name string) []string {
+	var values []string
+	for _, f := range c.Fields {
+		if strings.EqualFold(f.Name, name) {
+			values = append(values, f.Value)
+		}
+	}
+	return values
+}
+
+// Add appends a field.
+func (c *Comments) Add(name, value string) {
+	c.Fields = append(c.Fields, Field{Name: name, Value: value})
+}
+
+// Del removes the fields named name.
+func (c *Comments) Del(name string) {
+	fields := c.Fields[:0]
+	for _, f := range c.Fields {
+		if !strings.EqualFold(f.Name, name) {
+			fields = append(fields, f)
+		}
+	}
+	c.Fields = fields
+}
+
+// Set replaces the fields named name with one holding value.
+func (c *Comments) Set(name, value string) {
+	c.Del(name)
+	c.Add(name, value)
+}
+
+// validName reports whether name is printable ASCII without '='.
+func validName(name string) bool {
+	for i := 0; i < len(name); i++ {
+		if name[i] < 0x20 || name[i] > 0x7D || name[i] == '=' {
+			return false
+		}
+	}
+	return name != ""
+}
+
+func unexpected(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+func readUint32(r *codec.Reader) (uint32, error) {
+	var b [4]byte
+	r.ReadBytesTo(b[:])
+	if err := r.Err(); err != nil {
+		return 0, unexpected(err)
+	}
+	return binary.LittleEndian.Uint32(b[:]), nil
+}
+
+func readString(r *codec.Reader) (string, error) {
+	n, err := readUint32(r)
+	if err != nil {
+		return "", err
+	}
+	if n > MAX_LENGTH {
+		return "", fmt.Errorf("%w: string of %d bytes", codec.ErrLengthOverflow, n)
+	}
+	var s string
+	r.ReadString(&s, int(n))
+	if err := r.Err(); err != nil {
+		return "", unexpected(err)
+	}
+	if !utf8.ValidString(s) {
+		return "", fmt.Errorf("%w: %q", codec.ErrInvalidText, s)
+	}
+	return s, nil
+}
+
+// ReadComments reads a comment block.
+func ReadComments(r *codec.Reader) (*Comments, error) {
+	c := &Comments{}
+	var err error
+	if c.Vendor, err = readString(r); err != nil {
+		return nil, err
+	}
+	count, err := readUint32(r)
+	if err != nil {
+		return nil, err
+	}
+	// Every field takes at least its length, so bound the preallocation.
+	c.Fields = make([]Field, 0, min(count, 1024))
+	for range count {
+		s, err := readString(r)
+		if err != nil {
+			return nil, err
+		}
+		name, value, ok := strings.Cut(s, "=")
+		if !ok || !validName(name) {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidComment, s)
+		}
+		c.Fields = append(c.Fields, Field{Name: name, Value: value})
+	}
+	return c, nil
+}
+
+func writeString(w *codec.Writer, s string) {
+	var b [4]byte
+	binary.LittleEndian.PutUint32(b[:], uint32(len(s)))
+	w.WriteBytes(b[:])
+	w.WriteString(s)
+}
+
+// WriteComments writes a comment block.
+func WriteComments(w *codec.Writer, c *Comments) error {
+	for _, f := range c.Fields {
+		if !validName(f.Name) {
+			return fmt.Errorf("%w: field name %q", ErrInvalidComment, f.Name)
+		}
+	}
+	writeString(w, c.Vendor)
+	var b [4]byte
+	binary.LittleEndian.PutUint32(b[:], uint32(len(c.Fields)))
+	w.WriteBytes(b[:])
+	for _, f := range c.Fields {
+		writeString(w, f.Name+"="+f.Value)
+	}
+	return w.Err()
+}
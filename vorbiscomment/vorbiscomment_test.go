@@ -0,0 +1,44 @@
+//go:build test
+
+package vorbiscomment
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/oy3o/codec"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestComments(t *testing.T) {
+	c := &Comments{Vendor: "Xiph.Org libVorbis I 20200704"}
+	c.Add("ARTIST", "Ada")
+	c.Add("artist", "Grace")
+	c.Set("TITLE", "x=y")
+	assert.Equal(t, "Ada", c.Get("Artist"))
+	assert.Equal(t, []string{"Ada", "Grace"}, c.GetAll("ARTIST"))
+
+	var buf bytes.Buffer
+	w, _ := codec.NewWriter(&buf)
+	require.NoError(t, WriteComments(w, c))
+	require.NoError(t, w.Flush())
+	assert.Equal(t, []byte{29, 0, 0, 0}, buf.Bytes()[:4])
+	assert.Equal(t, []byte{3, 0, 0, 0, 10, 0, 0, 0}, buf.Bytes()[33:41])
+
+	r, _ := codec.NewReader(&buf)
+	got, err := ReadComments(r)
+	require.NoError(t, err)
+	assert.Equal(t, c, got)
+	assert.Equal(t, "x=y", got.Get("title"))
+
+	c.Del("artist")
+	assert.Len(t, c.Fields, 1)
+
+	c.Add("BAD=NAME", "")
+	assert.ErrorIs(t, WriteComments(w, c), ErrInvalidComment)
+
+	r, _ = codec.NewReader(bytes.NewReader([]byte{0, 0, 0, 0, 1, 0, 0, 0, 2, 0, 0, 0, 'n', 'o'}))
+	_, err = ReadComments(r)
+	assert.ErrorIs(t, err, ErrInvalidComment)
+}
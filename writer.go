@@ -4,7 +4,11 @@ import (
 	"bufio"
 	"bytes"
 	"encoding/binary"
+	"hash"
 	"io"
+	"unicode/utf16"
+
+	"golang.org/x/text/encoding"
 )
 
 type writer interface {
@@ -30,6 +34,21 @@ type Writer struct {
 	err   error // first error encountered. Subsequent writes become no-ops.
 	depth int
 	order binary.ByteOrder
+
+	patch  func(pos int64, p []byte) error // rewrites written bytes, nil if unsupported.
+	labels map[string]int64                // positions recorded by MarkLabel.
+	relocs []relocation                    // offsets written by WriteOffset, fixed up by Resolve.
+	held   int                             // size of the buffer allocated by this Writer.
+	hold   *hold                           // output held back by Reserve, nil if none.
+
+	prefixes []*Placeholder // open regions of BeginLengthPrefixed.
+
+	charset encoding.Encoding // text encoding of WriteText, nil for UTF-8.
+
+	hash hash.Hash // fed every written byte, set by WithHash.
+
+	context []string // path pushed by PushContext.
+	failed  failure  // where err was latched.
 }
 
 var _ WriterPro = (*Writer)(nil)
@@ -45,7 +64,7 @@ func NewWriterSize(w io.Writer, size int) (*Writer, error) {
 	// Reuse the underlying buffer if it's already a compatible Writer.
 	case *Writer:
 		if bw.w.Size() >= size {
-			return &Writer{w: bw.w, depth: bw.depth + 1, order: Order}, nil
+			return &Writer{w: bw.w, depth: bw.depth + 1, order: bw.order, patch: bw.patchFrom(bw.count)}, nil
 		}
 
 	// prevent unpredictable double-buffering.
@@ -57,13 +76,21 @@ func NewWriterSize(w io.Writer, size int) (*Writer, error) {
 
 	// underlying is a buf so we don't need buffering
 	case *BytesWriter:
-		return &Writer{w: bw, order: Order}, nil
+		return &Writer{w: bw, order: Order, patch: bytesPatcher(bw.B, bw.N)}, nil
 	case *bytes.Buffer:
-		return &Writer{w: &bytesBufferWriterAdapter{bw}, order: Order}, nil
+		origin := bw.Len()
+		return &Writer{w: &bytesBufferWriterAdapter{bw}, order: Order, patch: func(pos int64, p []byte) error {
+			return bytesPatcher(bw.Bytes(), origin)(pos, p)
+		}}, nil
 	}
 
 	// default use bufio
-	return &Writer{w: &bufioWriterAdapter{bufio.NewWriterSize(w, size)}, order: Order}, nil
+	bw := bufio.NewWriterSize(w, size)
+	cw := &Writer{w: &bufioWriterAdapter{bw}, order: Order, held: bw.Size()}
+	if wa, ok := w.(io.WriterAt); ok {
+		cw.patch = cw.writerAtPatcher(wa)
+	}
+	return cw, nil
 }
 
 // NewWriter creates a new Writer with a default buffer size.
@@ -118,7 +145,7 @@ func (w *Writer) ReadFrom(r io.Reader) (int64, error) {
 
 func (w *Writer) Size() int    { return w.w.Size() }
 func (w *Writer) Count() int64 { return w.count }
-func (w *Writer) Err() error   { return w.err }
+func (w *Writer) Err() error   { return w.failed.wrap(w.err) }
 
 // setError records the first non-nil error.
 // This preserves the root cause of a failure chain instead of a later,
@@ -126,13 +153,14 @@ func (w *Writer) Err() error   { return w.err }
 func (w *Writer) setError(err error) {
 	if w.err == nil && err != nil {
 		w.err = err
+		w.failed = locate(w.count, w.context)
 	}
 }
 
 // Result flushes the buffer and returns the final count and error state.
 func (w *Writer) Result() (int64, error) {
 	w.Flush()
-	return w.count, w.err
+	return w.count, w.failed.wrap(w.err)
 }
 
 // Flush writes any buffered data to the underlying io.Writer.
@@ -143,6 +171,10 @@ func (w *Writer) Flush() error {
 		return w.err
 	}
 	err := w.w.Flush()
+	if w.hold != nil && err == nil {
+		// Flush what was written before holding started.
+		err = w.hold.sink.Flush()
+	}
 	w.setError(err)
 	return err
 }
@@ -203,7 +235,7 @@ func (w *Writer) WriteBool(v bool) {
 	if err == nil {
 		w.count++
 	} else {
-		w.err = err
+		w.setError(err)
 	}
 }
 
@@ -215,7 +247,7 @@ func (w *Writer) WriteByte(v byte) error {
 	if err == nil {
 		w.count++
 	} else {
-		w.err = err
+		w.setError(err)
 	}
 	return err
 }
@@ -228,7 +260,7 @@ func (w *Writer) WriteUint8(v uint8) {
 	if err == nil {
 		w.count++
 	} else {
-		w.err = err
+		w.setError(err)
 	}
 }
 
@@ -241,6 +273,22 @@ func (w *Writer) WriteUint16(v uint16) {
 	_, _ = w.Write(buf[:])
 }
 
+// WriteUint24 writes the low 24 bits of v in 3 bytes, as used by RTMP and
+// MySQL headers. Higher bits are ignored.
+func (w *Writer) WriteUint24(v uint32) {
+	if w.err != nil {
+		return
+	}
+	var buf [4]byte
+	if w.order == LE {
+		LE.PutUint32(buf[:], v)
+		_, _ = w.Write(buf[:3])
+	} else {
+		BE.PutUint32(buf[:], v)
+		_, _ = w.Write(buf[1:])
+	}
+}
+
 func (w *Writer) WriteUint32(v uint32) {
 	if w.err != nil {
 		return
@@ -267,7 +315,7 @@ func (w *Writer) WriteInt8(v int8) {
 	if err == nil {
 		w.count++
 	} else {
-		w.err = err
+		w.setError(err)
 	}
 }
 
@@ -297,3 +345,32 @@ func (w *Writer) WriteInt64(v int64) {
 	w.order.PutUint64(buf[:], uint64(v))
 	_, _ = w.Write(buf[:])
 }
+
+// WriteUTF16String writes s encoded as UTF-16 in the given byte order.
+// Runes outside the Basic Multilingual Plane are written as surrogate pairs.
+// If withBOM is set, a byte order mark is written first; if nullTerminate is set,
+// a trailing null word is appended, matching what ReadUTF16StringUntilNull expects.
+func (w *Writer) WriteUTF16String(s string, order binary.ByteOrder, withBOM bool, nullTerminate bool) {
+	if w.err != nil {
+		return
+	}
+	var buf [4]byte
+	if withBOM {
+		order.PutUint16(buf[:], 0xFEFF)
+		_, _ = w.Write(buf[:2])
+	}
+	for _, r := range s {
+		if r >= 0x10000 {
+			r1, r2 := utf16.EncodeRune(r)
+			order.PutUint16(buf[:], uint16(r1))
+			order.PutUint16(buf[2:], uint16(r2))
+			_, _ = w.Write(buf[:4])
+		} else {
+			order.PutUint16(buf[:], uint16(r))
+			_, _ = w.Write(buf[:2])
+		}
+	}
+	if nullTerminate {
+		_, _ = w.Write(empty[:2])
+	}
+}
@@ -0,0 +1,227 @@
+package codec
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// bytesPatcher patches bytes written to b starting at origin.
+func bytesPatcher(b []byte, origin int) func(pos int64, p []byte) error {
+	return func(pos int64, p []byte) error {
+		start := int64(origin) + pos
+		if pos < 0 || start+int64(len(p)) > int64(len(b)) {
+			return fmt.Errorf("%w: patch at %d", ErrInvalidSeek, pos)
+		}
+		copy(b[start:], p)
+		return nil
+	}
+}
+
+// writerAtPatcher patches through io.WriterAt, relative to the position of the
+// destination when the Writer was created. Buffered bytes are flushed first.
+func (w *Writer) writerAtPatcher(wa io.WriterAt) func(pos int64, p []byte) error {
+	var origin int64
+	if s, ok := wa.(io.Seeker); ok {
+		origin, _ = s.Seek(0, io.SeekCurrent)
+	}
+	return func(pos int64, p []byte) error {
+		if pos < 0 {
+			return fmt.Errorf("%w: patch at %d", ErrInvalidSeek, pos)
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+		_, err := wa.WriteAt(p, origin+pos)
+		return err
+	}
+}
+
+// patchFrom returns the patch function of a Writer nested at offset base of w.
+func (w *Writer) patchFrom(base int64) func(pos int64, p []byte) error {
+	if w.patch == nil {
+		return nil
+	}
+	return func(pos int64, p []byte) error {
+		return w.patch(base+pos, p)
+	}
+}
+
+// CanPatch reports whether already written bytes can be rewritten with Patch.
+// This holds for Writers over a BytesWriter, a bytes.Buffer that is not read
+// from meanwhile, or a destination implementing io.WriterAt such as *os.File.
+func (w *Writer) CanPatch() bool { return w.patch != nil }
+
+// Patch overwrites previously written bytes at offset pos, counted from the
+// creation of the Writer. It fails with ErrNotPatchable if the destination
+// does not support rewriting. Errors are latched like write errors.
+func (w *Writer) Patch(pos int64, p []byte) error {
+	if w.err != nil {
+		return w.err
+	}
+	if w.patch == nil {
+		w.setError(ErrNotPatchable)
+		return w.err
+	}
+	w.setError(w.patch(pos, p))
+	return w.err
+}
+
+// hold buffers the output of a Writer whose destination cannot be patched,
+// while placeholders reserved in it are unset.
+type hold struct {
+	sink  WriterPro
+	patch func(pos int64, p []byte) error // of the sink
+	buf   bytes.Buffer
+	base  int64 // count when holding started
+	open  int   // unset placeholders
+}
+
+// Placeholder is a region reserved by Writer.Reserve, to be filled in once
+// its value is known, such as a length prefix written before its body.
+type Placeholder struct {
+	w    *Writer
+	pos  int64
+	n    int
+	held bool // the Writer holds its output until the placeholder is set
+	set  bool
+}
+
+// Reserve writes n zero bytes to be filled in later through the returned
+// Placeholder. Writers that CanPatch fill them in place. Others hold back
+// everything written from the first unset placeholder on, in memory, and
+// write it out when the last one is set; Flush does not write it out
+// meanwhile.
+func (w *Writer) Reserve(n int) *Placeholder {
+	p := &Placeholder{w: w, pos: w.count, n: n}
+	if w.err != nil || n <= 0 {
+		return p
+	}
+	if w.patch == nil || w.hold != nil {
+		if w.hold == nil {
+			h := &hold{sink: w.w, patch: w.patch, base: w.count}
+			w.hold = h
+			w.w = &bytesBufferWriterAdapter{&h.buf}
+			w.patch = func(pos int64, b []byte) error {
+				if pos < h.base {
+					return ErrNotPatchable
+				}
+				return bytesPatcher(h.buf.Bytes(), 0)(pos-h.base, b)
+			}
+		}
+		w.hold.open++
+		p.held = true
+	}
+	w.WriteZeros(int64(n))
+	return p
+}
+
+// release writes out what a Writer held, and returns to its destination.
+func (w *Writer) release() {
+	h := w.hold
+	w.w, w.patch, w.hold = h.sink, h.patch, nil
+	_, err := h.sink.Write(h.buf.Bytes())
+	w.setError(err)
+}
+
+// Offset returns the offset of the placeholder, counted from the creation
+// of the Writer like Count.
+func (p *Placeholder) Offset() int64 { return p.pos }
+
+// SetBytes fills the placeholder with b, which must have its size. Errors are
+// latched by the Writer.
+func (p *Placeholder) SetBytes(b []byte) error {
+	w := p.w
+	if w.err != nil {
+		return w.err
+	}
+	if len(b) != p.n {
+		w.setError(fmt.Errorf("%w: %d bytes into %d", ErrPlaceholderSize, len(b), p.n))
+		return w.err
+	}
+	if err := w.Patch(p.pos, b); err != nil {
+		return err
+	}
+	if p.held && !p.set {
+		p.set = true
+		if w.hold.open--; w.hold.open == 0 {
+			w.release()
+		}
+	}
+	return w.err
+}
+
+// SetUint8 fills a 1-byte placeholder.
+func (p *Placeholder) SetUint8(v uint8) error { return p.SetBytes([]byte{v}) }
+
+// SetUint16 fills a 2-byte placeholder in the byte order of the Writer.
+func (p *Placeholder) SetUint16(v uint16) error {
+	b := make([]byte, 2)
+	p.w.order.PutUint16(b, v)
+	return p.SetBytes(b)
+}
+
+// SetUint24 fills a 3-byte placeholder in the byte order of the Writer.
+func (p *Placeholder) SetUint24(v uint32) error {
+	if p.w.order == LE {
+		return p.SetBytes([]byte{byte(v), byte(v >> 8), byte(v >> 16)})
+	}
+	return p.SetBytes([]byte{byte(v >> 16), byte(v >> 8), byte(v)})
+}
+
+// SetUint32 fills a 4-byte placeholder in the byte order of the Writer.
+func (p *Placeholder) SetUint32(v uint32) error {
+	b := make([]byte, 4)
+	p.w.order.PutUint32(b, v)
+	return p.SetBytes(b)
+}
+
+// SetUint64 fills an 8-byte placeholder in the byte order of the Writer.
+func (p *Placeholder) SetUint64(v uint64) error {
+	b := make([]byte, 8)
+	p.w.order.PutUint64(b, v)
+	return p.SetBytes(b)
+}
+
+// BeginLengthPrefixed reserves a width byte length prefix, 1, 2, 3, 4 or 8,
+// for the region written until the matching EndLengthPrefixed. Regions nest.
+func (w *Writer) BeginLengthPrefixed(width int) {
+	switch width {
+	case 1, 2, 3, 4, 8:
+	default:
+		w.setError(fmt.Errorf("%w: length prefix of %d bytes", ErrLengthOverflow, width))
+		return
+	}
+	w.prefixes = append(w.prefixes, w.Reserve(width))
+}
+
+// EndLengthPrefixed ends the innermost region begun by BeginLengthPrefixed,
+// filling its prefix with the number of bytes written since, excluding the
+// prefix itself. Errors are latched.
+func (w *Writer) EndLengthPrefixed() error {
+	if w.err != nil {
+		return w.err
+	}
+	if len(w.prefixes) == 0 {
+		w.setError(ErrNoLengthPrefix)
+		return w.err
+	}
+	p := w.prefixes[len(w.prefixes)-1]
+	w.prefixes = w.prefixes[:len(w.prefixes)-1]
+	n := uint64(w.count - p.pos - int64(p.n))
+	if p.n < 8 && n >= 1<<(8*p.n) {
+		w.setError(fmt.Errorf("%w: %d bytes under a %d byte prefix", ErrLengthOverflow, n, p.n))
+		return w.err
+	}
+	switch p.n {
+	case 1:
+		return p.SetUint8(uint8(n))
+	case 2:
+		return p.SetUint16(uint16(n))
+	case 3:
+		return p.SetUint24(uint32(n))
+	case 4:
+		return p.SetUint32(uint32(n))
+	}
+	return p.SetUint64(n)
+}
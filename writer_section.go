@@ -0,0 +1,82 @@
+package codec
+
+import "io"
+
+// SectionWriter writes to a section of an io.WriterAt, as io.SectionReader
+// reads one, so independent regions of a preallocated file, such as
+// fixed-size slots or a directory table, can each get their own writer.
+// Writes past the end of the section write what fits and fail with
+// ErrSectionFull; wrapped in a Writer, the error is latched like any other.
+//
+// A SectionWriter implements io.WriterAt and io.Seeker, so a Writer over it
+// can Patch and fill placeholders in place.
+type SectionWriter struct {
+	w     io.WriterAt
+	base  int64
+	off   int64
+	limit int64
+}
+
+// NewSectionWriter returns a SectionWriter writing to w at offset off, for
+// at most n bytes.
+func NewSectionWriter(w io.WriterAt, off, n int64) *SectionWriter {
+	return &SectionWriter{w: w, base: off, off: off, limit: off + n}
+}
+
+// Size returns the size of the section in bytes.
+func (s *SectionWriter) Size() int64 { return s.limit - s.base }
+
+// Outer returns the underlying WriterAt, and the offset and size of the
+// section.
+func (s *SectionWriter) Outer() (w io.WriterAt, off, n int64) {
+	return s.w, s.base, s.limit - s.base
+}
+
+func (s *SectionWriter) Write(p []byte) (int, error) {
+	n, err := s.WriteAt(p, s.off-s.base)
+	s.off += int64(n)
+	return n, err
+}
+
+// WriteAt writes p at offset off within the section.
+func (s *SectionWriter) WriteAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, ErrInvalidSeek
+	}
+	off += s.base
+	if off >= s.limit {
+		if len(p) == 0 {
+			return 0, nil
+		}
+		return 0, ErrSectionFull
+	}
+	var full bool
+	if room := s.limit - off; int64(len(p)) > room {
+		p, full = p[:room], true
+	}
+	n, err := s.w.WriteAt(p, off)
+	if err == nil && full {
+		err = ErrSectionFull
+	}
+	return n, err
+}
+
+// Seek implements io.Seeker, relative to the section. Seeking past the end
+// is allowed, but writing there fails.
+func (s *SectionWriter) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+		offset += s.base
+	case io.SeekCurrent:
+		offset += s.off
+	case io.SeekEnd:
+		offset += s.limit
+	default:
+		return s.off - s.base, ErrInvalidWhence
+	}
+	if offset < s.base {
+		return s.off - s.base, ErrInvalidSeek
+	}
+	s.off = offset
+	return offset - s.base, nil
+}